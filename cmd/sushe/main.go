@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"strings"
@@ -12,8 +13,15 @@ import (
 
 	"github.com/fitz123/sushe/internal/api"
 	"github.com/fitz123/sushe/internal/bot"
+	"github.com/fitz123/sushe/internal/config"
+	"github.com/fitz123/sushe/internal/downloader"
 	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/errsink"
+	"github.com/fitz123/sushe/internal/format"
+	"github.com/fitz123/sushe/internal/health"
 	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/secrets"
+	"github.com/fitz123/sushe/internal/upload"
 	tele "gopkg.in/telebot.v3"
 )
 
@@ -44,15 +52,81 @@ func loadEnvFile(path string) {
 	}
 }
 
+// openCredentials hands the configured cookies and netrc files to the engine.
+// A file that can't be opened is skipped with an error, so a bad key only
+// costs logins, not the whole bot. Decrypted copies left by a crashed run
+// are removed first; the live ones are sealed back after each yt-dlp call.
+func openCredentials(eng *engine.Engine) *secrets.Files {
+	secrets.RemoveStale()
+	key, err := secrets.Key()
+	if err != nil {
+		logger.Error("Failed to read secrets key, credential files stay unencrypted", "error", err)
+	}
+	files := secrets.NewFiles(key)
+
+	open := func(env string) string {
+		src := config.String(env, "")
+		if src == "" {
+			return ""
+		}
+		path, err := files.Open(src)
+		if err != nil {
+			logger.Error("Ignoring credential file", "env", env, "error", err)
+			return ""
+		}
+		return path
+	}
+	seal := func() {
+		if err := files.Seal(); err != nil {
+			logger.Warn("Failed to seal refreshed credential files", "error", err)
+		}
+	}
+	eng.SetCredentials(open("SUSHE_COOKIES_FILE"), open("SUSHE_NETRC_FILE"), seal)
+	return files
+}
+
 func main() {
 	// Load .env file (env vars from systemd take precedence)
 	loadEnvFile(".env")
 
-	// Initialize logger
-	logger.Init("debug")
+	// Initialize logger (debug also reports each job's files at cleanup)
+	logger.Init(config.String("SUSHE_LOG_LEVEL", "debug"))
+
+	// Optional log file next to stdout, so history survives container restarts
+	var logFile *logger.RotatingFile
+	if path := config.String("SUSHE_LOG_FILE", ""); path != "" {
+		f, err := logger.OpenRotatingFile(path,
+			int64(config.Int("SUSHE_LOG_MAX_SIZE_MB", 100))*1024*1024,
+			config.Duration("SUSHE_LOG_MAX_AGE", 24*time.Hour),
+			config.Int("SUSHE_LOG_MAX_BACKUPS", 7))
+		if err != nil {
+			logger.Error("Failed to open log file, logging to stdout only", "error", err)
+		} else {
+			logger.TeeTo(f)
+			logFile = f
+		}
+	}
+
+	// Optional error tracker for handler panics and sites that keep failing
+	var sentry *errsink.Sentry
+	var errorSink errsink.Sink // nil unless Sentry is set up
+	if dsn, err := secrets.Get("SUSHE_SENTRY_DSN"); err != nil {
+		logger.Error("Failed to read Sentry DSN, error reporting disabled", "error", err)
+	} else if dsn != "" {
+		sentry, err = errsink.NewSentry(dsn, config.String("SUSHE_SENTRY_ENVIRONMENT", ""))
+		if err != nil {
+			logger.Error("Error reporting disabled", "error", err)
+		} else {
+			errorSink = sentry
+		}
+	}
 
-	// Get token from environment
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	// Get token from environment (or TELEGRAM_BOT_TOKEN_FILE)
+	token, err := secrets.Get("TELEGRAM_BOT_TOKEN")
+	if err != nil {
+		logger.Error("Failed to read bot token", "error", err)
+		os.Exit(1)
+	}
 	if token == "" {
 		logger.Error("TELEGRAM_BOT_TOKEN environment variable not set")
 		os.Exit(1)
@@ -72,7 +146,8 @@ func main() {
 	}
 
 	// Initialize the bot with local API server
-	// Custom HTTP client with long timeout for large file uploads (up to 2GB via local Bot API)
+	// Custom HTTP client with long timeout for large file uploads (up to 2GB via local Bot API);
+	// its transport fixes the name telebot sends the spoiler flag under
 	botPref := tele.Settings{
 		Token:  token,
 		Poller: &tele.LongPoller{
@@ -80,7 +155,7 @@ func main() {
 			AllowedUpdates: []string{"message", "edited_message", "channel_post", "callback_query"},
 		},
 		URL:    apiURL,
-		Client: &http.Client{Timeout: 60 * time.Minute},
+		Client: &http.Client{Timeout: 60 * time.Minute, Transport: upload.FixSpoilerParam(nil)},
 	}
 
 	botInstance, err := tele.NewBot(botPref)
@@ -89,21 +164,105 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Load allowed users whitelist from env
+	// Redundant local Bot API servers: uploads are spread over these and
+	// TELEGRAM_API_URL, failing over while one is down. Updates are still
+	// polled from TELEGRAM_API_URL only.
+	apiURLs := []string{apiURL}
+	var pool *upload.Pool
+	var poolStop chan struct{}
+	if extra := config.String("SUSHE_UPLOAD_API_URLS", ""); extra != "" {
+		bots := []*tele.Bot{botInstance}
+		for _, u := range strings.Split(extra, ",") {
+			if u = strings.TrimSpace(u); u == "" {
+				continue
+			}
+			b, err := tele.NewBot(tele.Settings{Token: token, URL: u, Client: botPref.Client, Offline: true})
+			if err != nil {
+				logger.Error("Failed to create bot for upload API server", "url", u, "error", err)
+				continue
+			}
+			bots = append(bots, b)
+			apiURLs = append(apiURLs, u)
+		}
+		pool = upload.NewPool(bots...)
+		poolStop = make(chan struct{})
+		go pool.Run(config.Duration("SUSHE_API_HEALTH_INTERVAL", upload.DefaultHealthInterval), poolStop)
+		logger.Info("Spreading uploads over Bot API servers", "servers", len(bots))
+	}
+
+	// Load the whitelist seed, admins and allowed chats from env (admins are always allowed)
 	allowedUsers := bot.LoadAllowedUsers()
+	admins := bot.LoadAdmins()
+	allowedChats := bot.LoadAllowedChats()
+
+	// The official Bot API server only takes uploads up to 50MB and can't
+	// read our disk: size splits and compression for it and upload files
+	officialAPI := strings.Contains(apiURL, "api.telegram.org")
+	uploads := upload.NewSender(botInstance, officialAPI)
+	uploads.SetPool(pool) // nil without SUSHE_UPLOAD_API_URLS
+	uploadLimit := downloader.LocalAPIUploadLimit
+	if officialAPI {
+		uploadLimit = downloader.OfficialAPIUploadLimit
+	}
+	if mb := config.Int("SUSHE_UPLOAD_LIMIT_MB", 0); mb > 0 {
+		uploadLimit = int64(mb) * 1000 * 1000
+	}
+	logger.Info("Upload limit", "limit", format.Size(uploadLimit), "api", apiURL)
+
+	// Constrain yt-dlp/ffmpeg: own process group, restricted env, optional limits
+	sandbox := downloader.Sandbox{
+		MemoryMax: config.String("SUSHE_SUBPROCESS_MEMORY", ""),
+		CPUQuota:  config.String("SUSHE_SUBPROCESS_CPU", ""),
+	}
+	for _, key := range strings.Split(config.String("SUSHE_SUBPROCESS_ENV", ""), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			sandbox.Env = append(sandbox.Env, key)
+		}
+	}
 
 	// Create shared download engine
 	eng := engine.NewEngine()
+	eng.SetSandbox(sandbox)
+	eng.SetUploadLimit(uploadLimit)
+	eng.SetPlaylistLimit(config.Int("SUSHE_MAX_PLAYLIST", eng.PlaylistLimit()))
+	eng.SetCompressOvershoot(config.Int("SUSHE_COMPRESS_OVERSHOOT", downloader.DefaultCompressOvershoot))
+	eng.SetSplitChapters(config.Bool("SUSHE_SPLIT_CHAPTERS", false))
+	eng.SetTorrents(config.Bool("SUSHE_TORRENTS", false))
+	if path := config.String("SUSHE_YTDLP_CONFIG", ""); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			logger.Warn("Ignoring SUSHE_YTDLP_CONFIG", "error", err)
+		} else {
+			eng.SetYtdlpConfig(path)
+		}
+	}
+	if spec := config.String("SUSHE_BANDWIDTH_SCHEDULE", ""); spec != "" {
+		if sched, err := downloader.ParseBandwidthSchedule(spec); err != nil {
+			logger.Warn("Invalid SUSHE_BANDWIDTH_SCHEDULE, downloading at full speed", "error", err)
+		} else {
+			eng.SetBandwidthSchedule(sched)
+		}
+	}
+
+	// Cookies and netrc for sites that need a login, encrypted at rest with SUSHE_SECRETS_KEY
+	credentials := openCredentials(eng)
+	defer func() {
+		if err := credentials.Close(); err != nil {
+			logger.Error("Failed to save credential files", "error", err)
+		}
+	}()
 
 	// Initialize bot service
-	botService := bot.NewBotService(botInstance, eng, allowedUsers)
+	botService := bot.NewBotService(botInstance, uploads, eng, errorSink, allowedUsers, admins, allowedChats)
 
 	// Start the bot
 	go botService.Start()
 	logger.Info("Sushe bot started")
 
 	// Start HTTP API server if SUSHE_API_TOKEN is set
-	apiToken := os.Getenv("SUSHE_API_TOKEN")
+	apiToken, err := secrets.Get("SUSHE_API_TOKEN")
+	if err != nil {
+		logger.Error("Failed to read API token, HTTP API disabled", "error", err)
+	}
 	apiPort := os.Getenv("SUSHE_API_PORT")
 	if apiPort == "" {
 		apiPort = "8082"
@@ -112,7 +271,9 @@ func main() {
 	var httpServer *http.Server
 	var apiService *api.APIService
 	if apiToken != "" {
-		apiService = api.NewAPIService(eng, botInstance, apiToken)
+		apiService = api.NewAPIService(eng, uploads, apiToken)
+		apiService.SetProgress(botService.Progress())
+		apiService.SetMaintenance(botService.Maintenance)
 		httpServer = &http.Server{
 			Addr:              ":" + apiPort,
 			Handler:           apiService.Handler(),
@@ -128,6 +289,59 @@ func main() {
 		logger.Info("HTTP API disabled (SUSHE_API_TOKEN not set)")
 	}
 
+	// Liveness/readiness probes for container orchestration
+	var healthServer *http.Server
+	if healthPort := config.String("SUSHE_HEALTH_PORT", ""); healthPort != "" {
+		checks := []health.Check{health.Bot(botInstance)}
+		for _, u := range apiURLs {
+			checks = append(checks, health.Reachable(u))
+		}
+		minFree := int64(config.Int("SUSHE_HEALTH_MIN_FREE_MB", 2048)) * 1024 * 1024
+		checks = append(checks,
+			health.DiskSpace(downloader.DownloadDir, minFree),
+			health.Command("yt-dlp"),
+			health.Command("ffmpeg"),
+			health.Command("ffprobe"),
+		)
+		if eng.Torrents() {
+			checks = append(checks, health.Command("aria2c"))
+		}
+		healthServer = &http.Server{
+			Addr:              ":" + healthPort,
+			Handler:           health.Handler(checks...),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			logger.Info("Health server starting", "port", healthPort)
+			if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("Health server error", "error", err)
+			}
+		}()
+	}
+
+	// Profiling for operators chasing CPU or memory use during re-encodes.
+	// Off by default and bound to localhost unless SUSHE_PPROF_ADDR says otherwise.
+	var pprofServer *http.Server
+	if config.Bool("SUSHE_PPROF", false) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		pprofServer = &http.Server{
+			Addr:              config.String("SUSHE_PPROF_ADDR", "127.0.0.1:6060"),
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			logger.Info("pprof server starting", "addr", pprofServer.Addr)
+			if err := pprofServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("pprof server error", "error", err)
+			}
+		}()
+	}
+
 	// Handle shutdown signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
@@ -147,7 +361,22 @@ func main() {
 	if apiService != nil {
 		apiService.Close()
 	}
+	if healthServer != nil {
+		healthServer.Close()
+	}
+	if pprofServer != nil {
+		pprofServer.Close()
+	}
 
+	if poolStop != nil {
+		close(poolStop)
+	}
 	botService.Stop()
 	logger.Info("Bot stopped")
+	if sentry != nil {
+		sentry.Close(5 * time.Second)
+	}
+	if logFile != nil {
+		logFile.Close()
+	}
 }
@@ -23,8 +23,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	d := downloader.New()
+
 	fmt.Println("=== Testing GetMediaInfo ===")
-	mediaInfo, err := downloader.GetMediaInfo(testFile)
+	mediaInfo, err := d.GetMediaInfo(testFile)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -35,22 +37,22 @@ func main() {
 	fmt.Printf("Dimensions: %dx%d\n", mediaInfo.Width, mediaInfo.Height)
 	fmt.Printf("Bitrate: %d bps\n", mediaInfo.Bitrate)
 
+	limits := downloader.NewUploadLimits(downloader.LocalAPIUploadLimit)
 	fmt.Println("\n=== Testing NeedsSplit ===")
-	fmt.Printf("NeedsSplit (actual): %v\n", downloader.NeedsSplit(info.Size()))
-	fmt.Printf("NeedsSplit (1GB): %v\n", downloader.NeedsSplit(1*1024*1024*1024))
-	fmt.Printf("NeedsSplit (2GB): %v\n", downloader.NeedsSplit(2*1024*1024*1024))
-	fmt.Printf("NeedsSplit (5GB): %v\n", downloader.NeedsSplit(5*1024*1024*1024))
+	fmt.Printf("NeedsSplit (actual): %v\n", limits.NeedsSplit(info.Size()))
+	fmt.Printf("NeedsSplit (1GB): %v\n", limits.NeedsSplit(1*1024*1024*1024))
+	fmt.Printf("NeedsSplit (2GB): %v\n", limits.NeedsSplit(2*1024*1024*1024))
+	fmt.Printf("NeedsSplit (5GB): %v\n", limits.NeedsSplit(5*1024*1024*1024))
 
 	fmt.Println("\n=== Testing CalculateNumParts ===")
-	fmt.Printf("Parts for 1GB: %d\n", downloader.CalculateNumParts(1*1024*1024*1024))
-	fmt.Printf("Parts for 2GB: %d\n", downloader.CalculateNumParts(2*1024*1024*1024))
-	fmt.Printf("Parts for 5GB: %d\n", downloader.CalculateNumParts(5*1024*1024*1024))
-	fmt.Printf("Parts for 10GB: %d\n", downloader.CalculateNumParts(10*1024*1024*1024))
+	fmt.Printf("Parts for 1GB: %d\n", limits.CalculateNumParts(1*1024*1024*1024))
+	fmt.Printf("Parts for 2GB: %d\n", limits.CalculateNumParts(2*1024*1024*1024))
+	fmt.Printf("Parts for 5GB: %d\n", limits.CalculateNumParts(5*1024*1024*1024))
+	fmt.Printf("Parts for 10GB: %d\n", limits.CalculateNumParts(10*1024*1024*1024))
 
 	fmt.Println("\n=== Testing SplitVideo ===")
 	fmt.Println("(This will split the 10-second video into 1 part since it's small)")
 
-	d := downloader.New()
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
@@ -71,7 +73,7 @@ func main() {
 	fmt.Printf("\nSplit completed in %.2f seconds\n", elapsed.Seconds())
 	fmt.Printf("Created %d part(s):\n", len(parts))
 	for _, p := range parts {
-		partInfo, _ := downloader.GetMediaInfo(p.FilePath)
+		partInfo, _ := d.GetMediaInfo(p.FilePath)
 		duration := 0.0
 		if partInfo != nil {
 			duration = partInfo.Duration
@@ -12,25 +12,32 @@ import (
 
 	"github.com/fitz123/sushe/internal/engine"
 	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/progress"
 	"github.com/fitz123/sushe/internal/upload"
 	tele "gopkg.in/telebot.v3"
 )
 
 // APIService handles HTTP API requests for video downloads.
 type APIService struct {
-	engine *engine.Engine
-	bot    *tele.Bot
-	token  string
-	dedup  *dedupGuard
+	engine  *engine.Engine
+	uploads *upload.Sender
+	token   string
+	dedup   *dedupGuard
+
+	// Job progress streamed to the dashboard, nil until SetProgress
+	progress *progress.Bus
+
+	// Reports the bot's maintenance mode, nil until SetMaintenance
+	maintenance func() (bool, time.Time)
 }
 
 // NewAPIService creates a new API service.
-func NewAPIService(eng *engine.Engine, bot *tele.Bot, token string) *APIService {
+func NewAPIService(eng *engine.Engine, uploads *upload.Sender, token string) *APIService {
 	return &APIService{
-		engine: eng,
-		bot:    bot,
-		token:  token,
-		dedup:  newDedupGuard(),
+		engine:  eng,
+		uploads: uploads,
+		token:   token,
+		dedup:   newDedupGuard(),
 	}
 }
 
@@ -43,6 +50,8 @@ func (s *APIService) Close() {
 func (s *APIService) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/download", s.handleDownload)
+	mux.HandleFunc("/api/events", s.handleEvents)
+	mux.HandleFunc("/dashboard", s.handleDashboard)
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -73,6 +82,10 @@ func (s *APIService) handleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.inMaintenance(w) {
+		return
+	}
+
 	// Parse request (limit body to 1MB to prevent DoS)
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
 	var req DownloadRequest
@@ -294,20 +307,20 @@ func (s *APIService) uploadResult(result *engine.ProcessResult, req DownloadRequ
 }
 
 // uploadSingleFile uploads a single video file.
-// Uses file:// URI so the local Bot API server reads directly from disk,
-// avoiding HTTP multipart upload timeouts/EOF on large files.
+// Files are passed with upload.Sender.LocalFile.
 func (s *APIService) uploadSingleFile(result *engine.ProcessResult, filePath, fileName, caption string, recipient tele.Recipient, opts *tele.SendOptions) (int, error) {
 	video := &tele.Video{
-		File:      tele.FromURL("file://" + filePath),
+		File:      s.uploads.LocalFile(filePath),
 		FileName:  fileName,
 		Caption:   caption,
 		Width:     result.Width,
 		Height:    result.Height,
 		Duration:  int(result.Duration),
 		Streaming: true,
+		Thumbnail: s.uploads.Thumbnail(result.ThumbnailPath),
 	}
 
-	msg, err := upload.SendWithRetry(s.bot, recipient, video, opts)
+	msg, err := s.uploads.Send(recipient, video, opts)
 	if err != nil {
 		return 0, err
 	}
@@ -316,23 +329,24 @@ func (s *APIService) uploadSingleFile(result *engine.ProcessResult, filePath, fi
 }
 
 // uploadSplitParts uploads split video parts sequentially, threading each as a reply.
-// Uses file:// URI so the local Bot API server reads directly from disk.
+// Files are passed with upload.Sender.LocalFile.
 func (s *APIService) uploadSplitParts(result *engine.ProcessResult, recipient tele.Recipient, baseOpts *tele.SendOptions) (int, error) {
 	var firstMsgID int
 	var prevMsg *tele.Message
 
 	for _, part := range result.Parts {
-		caption := fmt.Sprintf("%s\n\nPart %d/%d", result.Title, part.PartNum, len(result.Parts))
+		caption := fmt.Sprintf("%s\n\n%s", result.Title, part.Label(len(result.Parts)))
 		partFileName := fmt.Sprintf("%s_part%d.mp4", strings.TrimSuffix(result.FileName, ".mp4"), part.PartNum)
 
 		video := &tele.Video{
-			File:      tele.FromURL("file://" + part.FilePath),
+			File:      s.uploads.LocalFile(part.FilePath),
 			FileName:  partFileName,
 			Caption:   caption,
 			Width:     result.Width,
 			Height:    result.Height,
 			Duration:  int(result.Duration),
 			Streaming: true,
+			Thumbnail: s.uploads.Thumbnail(part.ThumbnailPath),
 		}
 
 		opts := &tele.SendOptions{}
@@ -343,7 +357,7 @@ func (s *APIService) uploadSplitParts(result *engine.ProcessResult, recipient te
 			opts.ReplyTo = prevMsg
 		}
 
-		msg, err := upload.SendWithRetry(s.bot, recipient, video, opts)
+		msg, err := s.uploads.Send(recipient, video, opts)
 		if err != nil {
 			return firstMsgID, fmt.Errorf("failed to upload part %d: %w", part.PartNum, err)
 		}
@@ -369,3 +383,26 @@ func writeJSON(w http.ResponseWriter, flusher http.Flusher, v interface{}) {
 	flusher.Flush()
 }
 
+
+// SetMaintenance makes /api/download decline requests while maintenance
+// reports the bot in maintenance mode.
+func (s *APIService) SetMaintenance(maintenance func() (on bool, until time.Time)) {
+	s.maintenance = maintenance
+}
+
+// inMaintenance answers 503 with a Retry-After of the expected end if the
+// bot is in maintenance mode.
+func (s *APIService) inMaintenance(w http.ResponseWriter) bool {
+	if s.maintenance == nil {
+		return false
+	}
+	on, until := s.maintenance()
+	if !on {
+		return false
+	}
+	if wait := time.Until(until); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	}
+	http.Error(w, `{"status":"error","ok":false,"error":"maintenance"}`, http.StatusServiceUnavailable)
+	return true
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fitz123/sushe/internal/engine"
 	"github.com/fitz123/sushe/internal/logger"
@@ -115,6 +117,34 @@ func TestInvalidJSON(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "invalid JSON")
 }
 
+func TestMaintenanceDeclines(t *testing.T) {
+	svc := newTestService(t)
+	until := time.Now().Add(30 * time.Minute)
+	svc.SetMaintenance(func() (bool, time.Time) { return true, until })
+	handler := svc.Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/download", strings.NewReader(`{"url":"https://example.com","chat_id":123}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer test-secret-token")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "maintenance")
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, 1800, retry, 5)
+
+	// Off again: the request gets past the check
+	svc.SetMaintenance(func() (bool, time.Time) { return false, time.Time{} })
+	req = httptest.NewRequest(http.MethodPost, "/api/download", strings.NewReader(`{"chat_id":123}`))
+	req.Header.Set("Authorization", "Bearer test-secret-token")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestMethodNotAllowed(t *testing.T) {
 	svc := newTestService(t)
 	handler := svc.Handler()
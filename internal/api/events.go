@@ -0,0 +1,176 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/progress"
+)
+
+// eventsKeepAlive is how often an idle event stream gets a comment line, so
+// proxies don't close it.
+const eventsKeepAlive = 15 * time.Second
+
+// SetProgress streams bus's job progress at /api/events and the dashboard.
+func (s *APIService) SetProgress(bus *progress.Bus) {
+	s.progress = bus
+}
+
+// streamAuthorized checks the API token of an event stream request: the
+// Authorization header, or a token query parameter since browsers'
+// EventSource can't set headers.
+func (s *APIService) streamAuthorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = auth[7:]
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// handleEvents streams job progress as Server-Sent Events: first the
+// latest event of every running job, then each event as it happens. An
+// optional chat_id parameter limits the stream to one chat.
+func (s *APIService) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.streamAuthorized(r) {
+		http.Error(w, `{"status":"error","ok":false,"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if s.progress == nil {
+		http.Error(w, `{"status":"error","ok":false,"error":"progress streaming unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+	var chatID int64
+	if v := r.URL.Query().Get("chat_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, `{"status":"error","ok":false,"error":"invalid chat_id"}`, http.StatusBadRequest)
+			return
+		}
+		chatID = id
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"status":"error","ok":false,"error":"streaming not supported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := s.progress.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range s.progress.Running() {
+		if chatID == 0 || e.ChatID == chatID {
+			writeEvent(w, e)
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if chatID != 0 && e.ChatID != chatID {
+				continue
+			}
+			writeEvent(w, e)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes e as a "progress" event, or "result" once the job is done.
+func writeEvent(w http.ResponseWriter, e progress.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	name := "progress"
+	if e.Done() {
+		name = "result"
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+}
+
+// handleDashboard serves the web dashboard: a page listing running jobs,
+// updated live from /api/events. The API token comes after # in the URL
+// (/dashboard#<token>) so it isn't sent to the server or logged.
+func (s *APIService) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardHTML))
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>sushe</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+td, th { padding: .4em; border-bottom: 1px solid #ddd; text-align: left; vertical-align: top; }
+td.status { white-space: pre-line; }
+progress { width: 10em; }
+.completed { color: #080; } .failed { color: #b00; } .cancelled { color: #888; }
+</style>
+</head>
+<body>
+<h1>Downloads</h1>
+<p id="state">Connecting...</p>
+<table>
+<thead><tr><th>URL</th><th>Chat</th><th>User</th><th>Progress</th><th>Status</th></tr></thead>
+<tbody id="jobs"></tbody>
+</table>
+<script>
+const rows = {};
+function row(e) {
+  let tr = rows[e.job_id];
+  if (!tr) {
+    tr = document.createElement("tr");
+    tr.innerHTML = "<td class=url></td><td class=chat></td><td class=user></td><td><progress max=100></progress></td><td class=status></td>";
+    document.getElementById("jobs").prepend(tr);
+    rows[e.job_id] = tr;
+  }
+  tr.querySelector(".url").textContent = e.url;
+  tr.querySelector(".chat").textContent = e.chat_id;
+  tr.querySelector(".user").textContent = e.user || "";
+  return tr;
+}
+const source = new EventSource("/api/events?token=" + encodeURIComponent(location.hash.slice(1)));
+source.onopen = () => document.getElementById("state").textContent = "Live";
+source.onerror = () => document.getElementById("state").textContent = "Disconnected, retrying...";
+source.addEventListener("progress", m => {
+  const e = JSON.parse(m.data), tr = row(e);
+  tr.querySelector("progress").value = e.percent || 0;
+  tr.querySelector(".status").textContent = e.status || e.phase;
+});
+source.addEventListener("result", m => {
+  const e = JSON.parse(m.data), tr = row(e);
+  tr.querySelector("progress").value = e.result === "completed" ? 100 : tr.querySelector("progress").value;
+  const status = tr.querySelector(".status");
+  status.className = "status " + e.result;
+  status.textContent = e.error ? e.result + ": " + e.error : e.result;
+});
+</script>
+</body>
+</html>
+`
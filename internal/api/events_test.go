@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/progress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsAuth(t *testing.T) {
+	svc := newTestService(t)
+	svc.SetProgress(progress.NewBus())
+	handler := svc.Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/events?token=wrong", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/events?token=test-secret-token&chat_id=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestEventsUnavailable(t *testing.T) {
+	svc := newTestService(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+	req.Header.Set("Authorization", "Bearer test-secret-token")
+	w := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestEventsStream(t *testing.T) {
+	bus := progress.NewBus()
+	svc := newTestService(t)
+	svc.SetProgress(bus)
+	srv := httptest.NewServer(svc.Handler())
+	defer srv.Close()
+
+	// Already running before the browser connects
+	bus.Publish(progress.Event{JobID: "a", ChatID: 1, Phase: "downloading", Percent: 10, Status: "Downloading: 10%"})
+	bus.Publish(progress.Event{JobID: "other", ChatID: 2, Phase: "downloading"})
+
+	resp, err := http.Get(srv.URL + "/api/events?token=test-secret-token&chat_id=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				lines <- line
+			}
+		}
+		close(lines)
+	}()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+			return ""
+		}
+	}
+
+	assert.Equal(t, "event: progress", next())
+	assert.Contains(t, next(), `"status":"Downloading: 10%"`)
+
+	// Other chats are filtered out
+	bus.Publish(progress.Event{JobID: "other", ChatID: 2, Result: "completed"})
+	bus.Publish(progress.Event{JobID: "a", ChatID: 1, Result: "failed", Error: "boom"})
+	assert.Equal(t, "event: result", next())
+	data := next()
+	assert.True(t, strings.HasPrefix(data, "data: "))
+	assert.Contains(t, data, `"job_id":"a"`)
+	assert.Contains(t, data, `"error":"boom"`)
+}
+
+func TestDashboardPage(t *testing.T) {
+	svc := newTestService(t)
+	w := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "EventSource")
+}
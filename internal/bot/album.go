@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/upload"
+	tele "gopkg.in/telebot.v3"
+)
+
+// maxAlbumSize is the most items Telegram takes in one media group.
+const maxAlbumSize = 10
+
+// uploadAlbum sends the photos and videos of a multi-item post (image post,
+// carousel, gallery) as media groups of up to ten, the first captioned with
+// the post's title, each group replying to the previous one.
+func (bs *BotService) uploadAlbum(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult) error {
+	total := len(result.Media)
+	var prevMsg *tele.Message
+	for start := 0; start < total; start += maxAlbumSize {
+		end := min(start+maxAlbumSize, total)
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading items %d–%d of %d...\n%s", start+1, end, total, result.Title),
+			cancelMarkup(job.ID))
+
+		album := make(tele.Album, 0, end-start)
+		for i, item := range result.Media[start:end] {
+			caption := ""
+			if start == 0 && i == 0 {
+				caption = jobCaption(job, withJobCaption(job, result.Title), "")
+			}
+			if item.IsVideo {
+				album = append(album, &tele.Video{
+					File:      bs.uploads.LocalFile(item.Path),
+					Caption:   caption,
+					Width:     item.Width,
+					Height:    item.Height,
+					Duration:  int(item.Duration),
+					Streaming: true,
+				})
+			} else {
+				album = append(album, &tele.Photo{File: bs.uploads.LocalFile(item.Path), Caption: caption})
+			}
+		}
+
+		opts := &tele.SendOptions{ThreadID: deliveryThread(job), ReplyTo: prevMsg}
+		sent, err := upload.Retry(func() (*tele.Message, error) {
+			return bs.uploads.Through(func(b *tele.Bot) (*tele.Message, error) {
+				msgs, err := b.SendAlbum(deliveryChat(job), album, opts)
+				if err != nil {
+					return nil, err
+				}
+				return &msgs[0], nil
+			})
+		})
+		if err != nil {
+			bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+			return err
+		}
+		prevMsg = sent
+	}
+
+	bs.bot.Delete(statusMsg)
+
+	jobLog(job).Info("Successfully sent album",
+		"title", result.Title,
+		"items", total,
+		"user", job.Username,
+	)
+	return nil
+}
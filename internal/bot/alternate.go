@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// alternateSkipDomains are sources where searching YouTube for an alternative
+// source is pointless.
+var alternateSkipDomains = map[string]bool{
+	"youtube.com":   true,
+	"m.youtube.com": true,
+	"youtu.be":      true,
+}
+
+// offerAlternate searches for the failed job's video on YouTube by page title and,
+// if found, offers it with a confirmation button, labeled as an alternative source.
+func (bs *BotService) offerAlternate(job *queue.Job) {
+	if alternateSkipDomains[urlDomain(job.URL)] {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	title, err := bs.unshortener.PageTitle(ctx, job.URL)
+	if err != nil {
+		jobLog(job).Debug("No page title to search for", "url", job.URL, "error", err)
+		return
+	}
+	entry, err := bs.engine.SearchYouTube(ctx, title)
+	if err != nil {
+		jobLog(job).Debug("No alternative source found", "url", job.URL, "error", err)
+		return
+	}
+
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data("Download alternative", "alternate", entry.ID)))
+
+	text := fmt.Sprintf("Possible alternative source (YouTube, not the original link):\n%s\n%s",
+		entry.Title, entry.URL)
+	opts := &tele.SendOptions{ThreadID: job.ThreadID, ReplyMarkup: markup, DisableWebPagePreview: true}
+	if job.StatusMsgID != 0 {
+		opts.ReplyTo = &tele.Message{ID: job.StatusMsgID, Chat: jobChat(job)}
+	}
+	if _, err := bs.bot.Send(jobChat(job), text, opts); err != nil {
+		jobLog(job).Debug("Failed to send alternative source", "error", err)
+	}
+}
+
+// handleAlternateButton queues the confirmed alternative source.
+func (bs *BotService) handleAlternateButton(c tele.Context) error {
+	videoID := c.Callback().Data
+	if videoID == "" {
+		return c.Respond()
+	}
+	if msg := c.Message(); msg != nil {
+		bs.bot.EditReplyMarkup(msg, nil)
+	}
+	if err := bs.enqueue(c, "https://www.youtube.com/watch?v="+videoID, ""); err != nil {
+		logger.Error("Failed to queue alternative source", "video_id", videoID, "error", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to queue download"})
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "Queued alternative source"})
+}
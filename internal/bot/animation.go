@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// uploadAnimation sends a result converted from an animated GIF/WebP, or a
+// short clip without sound, as a Telegram animation, which autoplays and
+// loops silently inline.
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadAnimation(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult) error {
+	bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading...\n%s | %s",
+		result.Title, bs.locale.Size(result.FileSize)), cancelMarkup(job.ID))
+
+	animation := &tele.Animation{
+		File:     bs.uploads.LocalFile(result.FilePath),
+		FileName: result.FileName,
+		Caption:  jobCaption(job, videoCaption(job, result), ""),
+		Width:    result.Width,
+		Height:   result.Height,
+		Duration: int(result.Duration),
+		MIME:     animationMIME(result.FilePath),
+	}
+	sentMsg, err := bs.uploads.Send(deliveryChat(job), animation, &tele.SendOptions{ThreadID: deliveryThread(job)})
+	if err != nil {
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+		return err
+	}
+	bs.rememberUpload(job, result, sentMsg)
+
+	bs.bot.Delete(statusMsg)
+
+	jobLog(job).Info("Successfully processed animation",
+		"title", result.Title,
+		"size", result.FileSize,
+		"user", job.Username,
+	)
+	return nil
+}
+
+// animationMIME returns the MIME type of an animation file: a GIF for very
+// short /gif clips, an MP4 otherwise.
+func animationMIME(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".gif") {
+		return "image/gif"
+	}
+	return "video/mp4"
+}
@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// handleArchive handles /archive <url>: downloads an MKV keeping every audio
+// track, subtitle track and attachment, and sends it as a document.
+func (bs *BotService) handleArchive(c tele.Context) error {
+	return bs.enqueueCommand(c, "/archive", qualityArchive)
+}
+
+// uploadDocument uploads an archive result (or an oversized video the user
+// wanted as files) as a document, so Telegram keeps it untouched. Split parts
+// are sent as a reply chain.
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadDocument(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult) error {
+	parts := result.Parts
+	if !result.IsSplit {
+		parts = []engine.PartResult{{FilePath: result.FilePath, PartNum: 1, FileSize: result.FileSize}}
+	}
+
+	ext := filepath.Ext(result.FilePath)
+	mime := "video/mp4"
+	if ext == ".mkv" {
+		mime = "video/x-matroska"
+	}
+
+	var prevMsg *tele.Message
+	var sent []*tele.Message
+	for _, part := range parts {
+		caption, label := result.Title, ""
+		fileName := result.FileName
+		if len(parts) > 1 {
+			label = part.Label(len(parts))
+			caption = fmt.Sprintf("%s\n\n%s", result.Title, label)
+			fileName = fmt.Sprintf("%s_part%d%s", result.Title, part.PartNum, ext)
+		}
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading Part %d/%d...\n%s | %s",
+			part.PartNum, len(parts), result.Title, bs.locale.Size(part.FileSize)), cancelMarkup(job.ID))
+
+		doc := &tele.Document{
+			File:     bs.uploads.LocalFile(part.FilePath),
+			FileName: fileName,
+			Caption:  jobCaption(job, withJobCaption(job, caption), label),
+			MIME:     mime,
+			// Send as a plain file; don't let Telegram convert it to a video
+			DisableTypeDetection: true,
+		}
+
+		opts := &tele.SendOptions{ThreadID: deliveryThread(job), ReplyTo: prevMsg}
+		sentMsg, err := bs.uploads.Send(deliveryChat(job), doc, opts)
+		if err != nil {
+			bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+			return err
+		}
+		prevMsg = sentMsg
+		sent = append(sent, sentMsg)
+	}
+
+	// Document parts of a regular video would be served to later plain
+	// requests for the same URL, so only archives are cached
+	if result.IsArchive {
+		bs.rememberUpload(job, result, sent...)
+	}
+	bs.bot.Delete(statusMsg)
+
+	jobLog(job).Info("Successfully uploaded document",
+		"title", result.Title,
+		"size", result.FileSize,
+		"parts", len(parts),
+		"user", job.Username,
+	)
+	return nil
+}
@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// cleanup removes a job's work directory. In debug mode it also logs the
+// files the job produced, and with SUSHE_ARTIFACT_REPORT_DM messages the
+// admins when some of them couldn't be removed.
+func (bs *BotService) cleanup(job *queue.Job, result *engine.ProcessResult) {
+	if !logger.DebugEnabled() && !bs.artifactDM {
+		bs.engine.Cleanup(result)
+		return
+	}
+	report := bs.engine.CleanupReport(result)
+	left := report.Leftovers()
+	if len(left) == 0 {
+		return
+	}
+	jobLog(job).Warn("Job left files behind", "dir", report.WorkDir, "files", len(left))
+	if !bs.artifactDM {
+		return
+	}
+	text := fmt.Sprintf("Job %s (%s) left %d files behind:\n\n%s", job.ID, job.URL, len(left), report)
+	for adminID := range bs.admins {
+		if _, err := bs.bot.Send(&tele.User{ID: adminID}, text, &tele.SendOptions{DisableWebPagePreview: true}); err != nil {
+			jobLog(job).Debug("Failed to send artifact report", "admin", adminID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// handleAudio handles /audio <url>: like /dl, but extracts the audio as MP3.
+func (bs *BotService) handleAudio(c tele.Context) error {
+	return bs.enqueueCommand(c, "/audio", qualityAudio)
+}
+
+// handleVoice handles /voice <url>: extracts the audio as OGG/Opus and sends
+// it as a voice message.
+func (bs *BotService) handleVoice(c tele.Context) error {
+	return bs.enqueueCommand(c, "/voice", qualityVoice)
+}
+
+// uploadVoice sends a voice-mode result as a Telegram voice message.
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadVoice(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult) error {
+	bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading...\n%s | %s",
+		result.Title, bs.locale.Size(result.FileSize)), cancelMarkup(job.ID))
+
+	voice := &tele.Voice{
+		File:     bs.uploads.LocalFile(result.FilePath),
+		Caption:  jobCaption(job, withJobCaption(job, result.Title), ""),
+		MIME:     "audio/ogg",
+		Duration: int(result.Duration),
+	}
+	sentMsg, err := bs.uploads.Send(deliveryChat(job), voice, &tele.SendOptions{ThreadID: deliveryThread(job)})
+	if err != nil {
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+		return err
+	}
+	bs.rememberUpload(job, result, sentMsg)
+
+	bs.bot.Delete(statusMsg)
+
+	jobLog(job).Info("Successfully processed voice message",
+		"title", result.Title,
+		"size", result.FileSize,
+		"user", job.Username,
+	)
+	return nil
+}
+
+// uploadAudio uploads an audio-only result as Telegram audio. Long audio split
+// into chapters is sent as a reply chain, one track per chapter.
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadAudio(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult) error {
+	parts := result.Parts
+	if !result.IsSplit {
+		parts = []engine.PartResult{{FilePath: result.FilePath, PartNum: 1, FileSize: result.FileSize}}
+	}
+
+	var prevMsg *tele.Message
+	var sent []*tele.Message
+	for _, part := range parts {
+		title := result.Title
+		if len(parts) > 1 {
+			title = fmt.Sprintf("%s (Part %d/%d)", result.Title, part.PartNum, len(parts))
+		}
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading...\n%s | %s",
+			title, bs.locale.Size(part.FileSize)), cancelMarkup(job.ID))
+
+		audio := &tele.Audio{
+			File:      bs.uploads.LocalFile(part.FilePath),
+			FileName:  fmt.Sprintf("%s.mp3", title),
+			Title:     title,
+			Performer: result.Performer,
+			Duration:  int(bs.audioDuration(part.FilePath, result.Duration, len(parts))),
+		}
+
+		opts := &tele.SendOptions{ThreadID: deliveryThread(job), ReplyTo: prevMsg}
+		sentMsg, err := bs.uploads.Send(deliveryChat(job), audio, opts)
+		if err != nil {
+			bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+			return err
+		}
+		prevMsg = sentMsg
+		sent = append(sent, sentMsg)
+	}
+
+	bs.rememberUpload(job, result, sent...)
+	bs.bot.Delete(statusMsg)
+
+	jobLog(job).Info("Successfully processed audio",
+		"title", result.Title,
+		"size", result.FileSize,
+		"parts", len(parts),
+		"user", job.Username,
+	)
+	return nil
+}
+
+// audioDuration returns the duration of one audio part. Chapters are probed;
+// if that fails the total is divided evenly.
+func (bs *BotService) audioDuration(path string, total float64, parts int) float64 {
+	if parts <= 1 {
+		return total
+	}
+	if info, err := bs.engine.MediaInfo(path); err == nil {
+		return info.Duration
+	}
+	return total / float64(parts)
+}
@@ -1,28 +1,92 @@
 package bot
 
 import (
+	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
 	tele "gopkg.in/telebot.v3"
 )
 
+// Role is what a sender may do, resolved by AuthMiddleware for each update
+// and read by handlers with roleOf.
+type Role int
+
+const (
+	RoleNone  Role = iota // not allowed; handlers never see it
+	RoleUser              // on the whitelist, or writing in a chat of SUSHE_ALLOWED_CHATS
+	RoleAdmin             // in SUSHE_ADMINS: management commands, no per-user job limit
+)
+
+// roleKey is the tele.Context key AuthMiddleware stores the Role under.
+const roleKey = "role"
+
+// roleOf returns the sender's role resolved by AuthMiddleware.
+func roleOf(c tele.Context) Role {
+	role, _ := c.Get(roleKey).(Role)
+	return role
+}
+
+// isAdmin reports whether userID is a bot admin (SUSHE_ADMINS), for checks
+// about a user other than the sender, such as a job's requester. Checks on
+// the sender use roleOf.
+func (bs *BotService) isAdmin(userID int64) bool {
+	_, ok := bs.admins[userID]
+	return ok
+}
+
+// stillAuthorized reports whether userID may still use the bot in chatID,
+// for jobs started without a message from them (/subscribe, /later): a
+// /deny since they were set up stops them.
+func (bs *BotService) stillAuthorized(userID, chatID int64) bool {
+	return resolveRole(&tele.User{ID: userID}, &tele.Chat{ID: chatID}, bs.allowedUsers, bs.admins, bs.allowedChats) != RoleNone
+}
+
 // AllowedUsers holds the set of authorized Telegram user IDs.
 // If empty or nil, NO users are allowed (fail-closed).
 type AllowedUsers map[int64]struct{}
 
-// LoadAllowedUsers parses the SUSHE_ALLOWED_USERS env variable.
+// LoadAllowedUsers parses the SUSHE_ALLOWED_USERS env variable, the seed of
+// the whitelist (see newWhitelist).
 // Expected format: comma-separated user IDs, e.g. "123456789,987654321"
 func LoadAllowedUsers() AllowedUsers {
-	raw := os.Getenv("SUSHE_ALLOWED_USERS")
-	if raw == "" {
-		logger.Warn("SUSHE_ALLOWED_USERS not set — all access denied (fail-closed)")
-		return make(AllowedUsers) // empty non-nil map = deny all
+	return parseIDs(os.Getenv("SUSHE_ALLOWED_USERS"), "SUSHE_ALLOWED_USERS")
+}
+
+// LoadAdmins parses the SUSHE_ADMINS env variable (same format as
+// SUSHE_ALLOWED_USERS). Admins see management commands such as /feedback.
+// If unset, there are no admins.
+func LoadAdmins() AllowedUsers {
+	admins := parseIDs(os.Getenv("SUSHE_ADMINS"), "SUSHE_ADMINS")
+	if len(admins) > 0 {
+		logger.Info("Loaded admins", "count", len(admins))
+	}
+	return admins
+}
+
+// AllowedChats holds the Telegram chats whose members may all use the bot.
+type AllowedChats map[int64]struct{}
+
+// LoadAllowedChats parses the SUSHE_ALLOWED_CHATS env variable: comma-separated
+// chat IDs, e.g. "-1001234567890" for a supergroup. If unset, access is
+// decided by user alone.
+func LoadAllowedChats() AllowedChats {
+	chats := AllowedChats(parseIDs(os.Getenv("SUSHE_ALLOWED_CHATS"), "SUSHE_ALLOWED_CHATS"))
+	if len(chats) > 0 {
+		logger.Info("Loaded allowed chats", "count", len(chats))
 	}
+	return chats
+}
 
-	allowed := make(AllowedUsers)
+// parseIDs parses a comma-separated list of Telegram user or chat IDs,
+// skipping (and logging) invalid entries.
+func parseIDs(raw, envName string) AllowedUsers {
+	ids := make(AllowedUsers)
 	for _, s := range strings.Split(raw, ",") {
 		s = strings.TrimSpace(s)
 		if s == "" {
@@ -30,24 +94,254 @@ func LoadAllowedUsers() AllowedUsers {
 		}
 		id, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			logger.Warn("Invalid user ID in SUSHE_ALLOWED_USERS, skipping", "value", s, "error", err)
+			logger.Warn("Invalid ID in "+envName+", skipping", "value", s, "error", err)
 			continue
 		}
-		allowed[id] = struct{}{}
+		ids[id] = struct{}{}
+	}
+	return ids
+}
+
+// whitelist is the set of allowed users, changed by admins with /allow and
+// /deny and kept in allowed_users.json so the changes survive restarts.
+// SUSHE_ALLOWED_USERS only seeds it while that file doesn't exist. Every
+// change is made under the file's lock on top of what the file holds then,
+// so another process editing it in between isn't overwritten.
+type whitelist struct {
+	mu    sync.RWMutex
+	path  string
+	users AllowedUsers
+}
+
+// newWhitelist loads the whitelist from path, or starts it from seed (and
+// saves it) if there is no file yet. An unreadable file falls back to seed
+// without overwriting it.
+func newWhitelist(path string, seed AllowedUsers) *whitelist {
+	w := &whitelist{path: path, users: make(AllowedUsers)}
+	for id := range seed {
+		w.users[id] = struct{}{}
+	}
+	unlock, err := store.Lock(path)
+	if err != nil {
+		logger.Error("Failed to lock whitelist, using SUSHE_ALLOWED_USERS", "error", err)
+		return w
+	}
+	defer unlock()
+
+	users, err := readWhitelist(path)
+	switch {
+	case err != nil:
+		logger.Error("Failed to load whitelist, using SUSHE_ALLOWED_USERS", "error", err)
+	case users == nil:
+		if err := store.SaveJSON(path, sortedIDs(w.users)); err != nil {
+			logger.Warn("Failed to save whitelist", "error", err)
+		}
+	default:
+		w.users = users
+		if len(seed) > 0 {
+			logger.Info("Whitelist loaded from file, SUSHE_ALLOWED_USERS only seeds a new one", "path", path)
+		}
+	}
+	if len(w.users) == 0 {
+		logger.Warn("Whitelist is empty — only admins and allowed chats have access (fail-closed)")
+	} else {
+		logger.Info("Loaded allowed users whitelist", "count", len(w.users))
+	}
+	return w
+}
+
+// readWhitelist reads the whitelist file at path; nil without a file.
+func readWhitelist(path string) (AllowedUsers, error) {
+	var ids []int64
+	if err := store.LoadJSON(path, &ids); err != nil || ids == nil {
+		return nil, err
+	}
+	return idSet(ids), nil
+}
+
+func idSet(ids []int64) AllowedUsers {
+	users := make(AllowedUsers, len(ids))
+	for _, id := range ids {
+		users[id] = struct{}{}
+	}
+	return users
+}
+
+// allowed reports whether userID is on the whitelist.
+func (w *whitelist) allowed(userID int64) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok := w.users[userID]
+	return ok
+}
+
+// add puts userID on the whitelist and reports whether it was missing.
+func (w *whitelist) add(userID int64) (bool, error) {
+	return w.update(func(users AllowedUsers) bool {
+		if _, ok := users[userID]; ok {
+			return false
+		}
+		users[userID] = struct{}{}
+		return true
+	})
+}
+
+// remove takes userID off the whitelist and reports whether it was on it.
+func (w *whitelist) remove(userID int64) (bool, error) {
+	return w.update(func(users AllowedUsers) bool {
+		if _, ok := users[userID]; !ok {
+			return false
+		}
+		delete(users, userID)
+		return true
+	})
+}
+
+// update applies change to the whitelist as the file holds it now (the
+// in-memory list without a file) and saves the result (store.Update). On
+// any error the whitelist is left as it was, in memory and on disk.
+func (w *whitelist) update(change func(AllowedUsers) bool) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ids := sortedIDs(w.users)
+	var changed bool
+	err := store.Update(w.path, &ids, func() error {
+		users := idSet(ids)
+		changed = change(users)
+		ids = sortedIDs(users)
+		return nil
+	})
+	if err != nil {
+		return false, err
 	}
+	w.users = idSet(ids)
+	return changed, nil
+}
 
-	if len(allowed) == 0 {
-		logger.Warn("SUSHE_ALLOWED_USERS contains no valid IDs — all access denied (fail-closed)")
-		return allowed // empty non-nil map = deny all
+// reload replaces the whitelist with the file's contents, e.g. after it was
+// edited by hand. A missing or unreadable file leaves the whitelist as it is.
+func (w *whitelist) reload() (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	unlock, err := store.Lock(w.path)
+	if err != nil {
+		return 0, err
 	}
+	defer unlock()
 
-	logger.Info("Loaded allowed users whitelist", "count", len(allowed))
-	return allowed
+	users, err := readWhitelist(w.path)
+	if err != nil {
+		return 0, err
+	}
+	if users == nil {
+		return 0, fmt.Errorf("%s doesn't exist", w.path)
+	}
+	w.users = users
+	return len(users), nil
+}
+
+// ids returns the whitelisted user IDs in ascending order.
+func (w *whitelist) ids() []int64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return sortedIDs(w.users)
 }
 
-// AuthMiddleware returns a telebot middleware that restricts access to whitelisted users.
-// If allowedUsers is nil or empty, NO users are permitted (fail-closed).
-func AuthMiddleware(allowedUsers AllowedUsers) tele.MiddlewareFunc {
+func sortedIDs(users AllowedUsers) []int64 {
+	ids := make([]int64, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// handleAllow handles /allow [user_id] for bot admins: adds a user to the
+// whitelist, or lists it without an argument. Replying to a user's message
+// with /allow adds its sender; /allow reload rereads allowed_users.json.
+func (bs *BotService) handleAllow(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+	if strings.EqualFold(strings.TrimSpace(c.Message().Payload), "reload") {
+		count, err := bs.allowedUsers.reload()
+		if err != nil {
+			logger.Error("Failed to reload whitelist", "error", err)
+			return c.Send("❌ Failed to reload the whitelist, keeping the current one: " + err.Error())
+		}
+		logger.Info("Whitelist reloaded", "count", count, "by", c.Sender().ID)
+		return c.Send(fmt.Sprintf("🔄 Whitelist reloaded: %d allowed users.", count))
+	}
+	userID, ok := targetUserID(c)
+	if !ok {
+		ids := bs.allowedUsers.ids()
+		if len(ids) == 0 {
+			return c.Send("The whitelist is empty; only admins can use the bot. Usage: /allow <user id>, /deny <user id>")
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d allowed users:\n", len(ids))
+		for _, id := range ids {
+			fmt.Fprintf(&b, "- %d\n", id)
+		}
+		b.WriteString("\n/allow <user id> adds one, /deny <user id> removes one, /allow reload rereads the file.")
+		return c.Send(b.String())
+	}
+	added, err := bs.allowedUsers.add(userID)
+	if err != nil {
+		logger.Error("Failed to update whitelist", "error", err)
+		return c.Send("❌ Failed to save the whitelist, nothing changed: " + err.Error())
+	}
+	if !added {
+		return c.Send(fmt.Sprintf("User %d is already allowed.", userID))
+	}
+	logger.Info("User allowed", "user", userID, "by", c.Sender().ID)
+	return c.Send(fmt.Sprintf("✅ User %d can now use the bot.", userID))
+}
+
+// handleDeny handles /deny <user_id> for bot admins: removes a user from
+// the whitelist. Admins keep their access either way.
+func (bs *BotService) handleDeny(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+	userID, ok := targetUserID(c)
+	if !ok {
+		return c.Send("Usage: /deny <user id>, or reply to the user's message with /deny")
+	}
+	removed, err := bs.allowedUsers.remove(userID)
+	if err != nil {
+		logger.Error("Failed to update whitelist", "error", err)
+		return c.Send("❌ Failed to save the whitelist, nothing changed: " + err.Error())
+	}
+	if !removed {
+		return c.Send(fmt.Sprintf("User %d isn't on the whitelist.", userID))
+	}
+	logger.Info("User denied", "user", userID, "by", c.Sender().ID)
+	text := fmt.Sprintf("🚫 User %d can no longer use the bot.", userID)
+	if bs.isAdmin(userID) {
+		text += " They are an admin (SUSHE_ADMINS), so they keep access."
+	}
+	return c.Send(text)
+}
+
+// targetUserID is the user an /allow or /deny is about: the ID given as
+// its argument, else the sender of the message it replies to.
+func targetUserID(c tele.Context) (int64, bool) {
+	if arg := strings.TrimSpace(c.Message().Payload); arg != "" {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		return id, err == nil
+	}
+	if reply := c.Message().ReplyTo; reply != nil && reply.Sender != nil && !reply.Sender.IsBot {
+		return reply.Sender.ID, true
+	}
+	return 0, false
+}
+
+// AuthMiddleware returns a telebot middleware that restricts access to
+// admins, whitelisted users and every member of the allowed chats, and
+// stores the sender's Role in the context. If all are empty, NO users are
+// permitted (fail-closed).
+func AuthMiddleware(allowedUsers *whitelist, admins AllowedUsers, chats AllowedChats) tele.MiddlewareFunc {
 	return func(next tele.HandlerFunc) tele.HandlerFunc {
 		return func(c tele.Context) error {
 
@@ -66,7 +360,8 @@ func AuthMiddleware(allowedUsers AllowedUsers) tele.MiddlewareFunc {
 				return nil // no sender info, skip silently
 			}
 
-			if _, ok := allowedUsers[sender.ID]; ok {
+			if role := resolveRole(sender, chat, allowedUsers, admins, chats); role != RoleNone {
+				c.Set(roleKey, role)
 				return next(c)
 			}
 
@@ -75,12 +370,29 @@ func AuthMiddleware(allowedUsers AllowedUsers) tele.MiddlewareFunc {
 			if username == "" {
 				username = strings.TrimSpace(sender.FirstName + " " + sender.LastName)
 			}
-			logger.Warn("Unauthorized access attempt",
-				"user_id", sender.ID,
-				"username", username,
-			)
+			attrs := []any{"user_id", sender.ID, "username", username}
+			if chat != nil && chat.Type != tele.ChatPrivate {
+				attrs = append(attrs, "chat_id", chat.ID)
+			}
+			logger.Warn("Unauthorized access attempt", attrs...)
 
 			return nil // silently ignore
 		}
 	}
 }
+
+// resolveRole returns the highest role the sender has in chat.
+func resolveRole(sender *tele.User, chat *tele.Chat, allowedUsers *whitelist, admins AllowedUsers, chats AllowedChats) Role {
+	if _, ok := admins[sender.ID]; ok {
+		return RoleAdmin
+	}
+	if allowedUsers.allowed(sender.ID) {
+		return RoleUser
+	}
+	if chat != nil {
+		if _, ok := chats[chat.ID]; ok {
+			return RoleUser
+		}
+	}
+	return RoleNone
+}
@@ -0,0 +1,305 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/store"
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	// backfillTick is how often an idle queue is topped up from backfills.
+	backfillTick = 5 * time.Second
+
+	// backfillListTimeout bounds listing a channel's uploads.
+	backfillListTimeout = 10 * time.Minute
+)
+
+// backfill is a channel archival in progress: its uploads not queued yet
+// and counts for the status message.
+type backfill struct {
+	ID          string   `json:"id"`
+	Channel     string   `json:"channel"`
+	ChatID      int64    `json:"chat_id"`
+	ThreadID    int      `json:"thread_id,omitempty"`
+	UserID      int64    `json:"user_id"`
+	Username    string   `json:"username,omitempty"`
+	StatusMsgID int      `json:"status_msg_id,omitempty"`
+	Pending     []string `json:"pending"`
+	Total       int      `json:"total"`
+	Queued      int      `json:"queued"`
+	Skipped     int      `json:"skipped"`
+}
+
+// status is the text of a backfill's status message.
+func (b *backfill) status() string {
+	if len(b.Pending) == 0 {
+		return fmt.Sprintf("📼 Backfill of %s done: %d queued, %d skipped as already downloaded.", b.Channel, b.Queued, b.Skipped)
+	}
+	return fmt.Sprintf("📼 Backfill of %s: %d of %d queued, %d skipped. New downloads go first. /backfill cancel %s stops it.",
+		b.Channel, b.Queued, b.Total, b.Skipped, b.ID)
+}
+
+// backfills are the running backfills, persisted so they resume after a
+// restart.
+type backfills struct {
+	mu   sync.Mutex
+	path string
+	list []*backfill
+}
+
+func newBackfills(path string) *backfills {
+	b := &backfills{path: path}
+	if err := store.LoadJSON(path, &b.list); err != nil {
+		logger.Warn("Failed to load backfills", "error", err)
+	}
+	return b
+}
+
+func (b *backfills) add(bf *backfill) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.list = append(b.list, bf)
+	b.saveLocked()
+}
+
+// remove drops backfill id and returns it, nil if there is none.
+func (b *backfills) remove(id string) *backfill {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, bf := range b.list {
+		if bf.ID == id {
+			b.list = append(b.list[:i], b.list[i+1:]...)
+			b.saveLocked()
+			return bf
+		}
+	}
+	return nil
+}
+
+// next pops the next upload to queue, taking turns between backfills, and
+// returns it with a snapshot of its backfill. ok is false if there is none.
+func (b *backfills) next() (url string, bf backfill, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.list) == 0 || len(b.list[0].Pending) == 0 {
+		return "", backfill{}, false
+	}
+	cur := b.list[0]
+	url, cur.Pending = cur.Pending[0], cur.Pending[1:]
+	// Rotate so every backfill gets its turn
+	b.list = append(b.list[1:], cur)
+	b.saveLocked()
+	return url, *cur, true
+}
+
+// count records a queued or skipped upload of backfill id and returns its
+// updated state; a finished backfill is dropped.
+func (b *backfills) count(id string, skipped bool) (backfill, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, bf := range b.list {
+		if bf.ID != id {
+			continue
+		}
+		if skipped {
+			bf.Skipped++
+		} else {
+			bf.Queued++
+		}
+		if len(bf.Pending) == 0 {
+			b.list = append(b.list[:i], b.list[i+1:]...)
+		}
+		b.saveLocked()
+		return *bf, true
+	}
+	return backfill{}, false
+}
+
+// snapshot returns copies of the running backfills.
+func (b *backfills) snapshot() []backfill {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := make([]backfill, len(b.list))
+	for i, bf := range b.list {
+		list[i] = *bf
+	}
+	return list
+}
+
+// saveLocked persists the backfills. Must hold b.mu.
+func (b *backfills) saveLocked() {
+	if err := store.SaveJSON(b.path, b.list); err != nil {
+		logger.Warn("Failed to save backfills", "error", err)
+	}
+}
+
+// handleBackfill handles /backfill <channel-url> [since YYYY-MM-DD] for bot
+// admins: lists the channel's uploads and queues them at low priority, one
+// at a time while the queue is otherwise idle, skipping those already
+// downloaded. /backfill lists running backfills, /backfill cancel <id>
+// stops one.
+func (bs *BotService) handleBackfill(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return c.Send("Only bot admins can backfill channels.")
+	}
+
+	args := strings.Fields(c.Message().Payload)
+	switch {
+	case len(args) == 0:
+		list := bs.backfills.snapshot()
+		if len(list) == 0 {
+			return c.Send("No backfills running. Usage: /backfill <channel url> [since YYYY-MM-DD]")
+		}
+		var lines []string
+		for _, bf := range list {
+			lines = append(lines, bf.status())
+		}
+		return c.Send(strings.Join(lines, "\n\n"), &tele.SendOptions{DisableWebPagePreview: true})
+	case len(args) == 2 && strings.EqualFold(args[0], "cancel"):
+		bf := bs.backfills.remove(args[1])
+		if bf == nil {
+			return c.Send("No backfill with that ID.")
+		}
+		return c.Send(fmt.Sprintf("Backfill of %s stopped after %d of %d uploads. Queued downloads still run; /cancel stops them.",
+			bf.Channel, bf.Queued, bf.Total))
+	case len(args) > 2:
+		return c.Send("Usage: /backfill <channel url> [since YYYY-MM-DD]")
+	}
+
+	urls := downloader.ExtractURLs(args[0])
+	if len(urls) == 0 {
+		return c.Send("Usage: /backfill <channel url> [since YYYY-MM-DD]")
+	}
+	channel := urls[0]
+	var since time.Time
+	if len(args) == 2 {
+		var err error
+		if since, err = time.Parse("2006-01-02", args[1]); err != nil {
+			return c.Send("Invalid date, use YYYY-MM-DD, e.g. /backfill <channel url> 2024-01-01")
+		}
+	}
+
+	msg, err := bs.bot.Send(c.Chat(), "📼 Listing the channel's uploads...",
+		&tele.SendOptions{ThreadID: c.Message().ThreadID, DisableWebPagePreview: true})
+	if err != nil {
+		return err
+	}
+	bf := &backfill{
+		ID:          queue.NewJobID(),
+		Channel:     channel,
+		ChatID:      c.Chat().ID,
+		ThreadID:    c.Message().ThreadID,
+		UserID:      c.Sender().ID,
+		Username:    c.Sender().Username,
+		StatusMsgID: msg.ID,
+	}
+	go bs.startBackfill(bf, since, msg)
+	return nil
+}
+
+// startBackfill lists the channel and hands its uploads, oldest first, to
+// the backfill loop.
+func (bs *BotService) startBackfill(bf *backfill, since time.Time, msg *tele.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), backfillListTimeout)
+	defer cancel()
+	videos, err := bs.engine.ListChannel(ctx, bf.Channel, since)
+	if err != nil || len(videos) == 0 {
+		logger.Warn("Failed to list channel for backfill", "channel", bf.Channel, "error", err)
+		bs.bot.Edit(msg, fmt.Sprintf("📼 Found no uploads to backfill in %s.", bf.Channel))
+		return
+	}
+
+	// Archive in upload order
+	for i := len(videos) - 1; i >= 0; i-- {
+		bf.Pending = append(bf.Pending, videos[i].URL)
+	}
+	bf.Total = len(bf.Pending)
+	bs.backfills.add(bf)
+	logger.Info("Backfill started", "channel", bf.Channel, "videos", bf.Total, "since", since, "user", bf.UserID)
+	bs.bot.Edit(msg, bf.status(), &tele.SendOptions{DisableWebPagePreview: true})
+}
+
+// runBackfills queues backfill uploads while no other job waits, until the
+// bot stops.
+func (bs *BotService) runBackfills() {
+	ticker := time.NewTicker(backfillTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-bs.stop:
+			return
+		}
+		if bs.maintenance.get().On {
+			continue
+		}
+		// Skip already downloaded uploads until one is queued
+		for {
+			if pending, running := bs.queue.Len(); pending > 0 || running >= bs.queue.Workers() {
+				break
+			}
+			if !bs.feedBackfill() {
+				break
+			}
+		}
+	}
+}
+
+// feedBackfill takes the next backfill upload and queues it, unless it
+// was already downloaded, recently failed or is queued. Returns true if it
+// skipped the upload, so the caller may go on with the next one.
+func (bs *BotService) feedBackfill() bool {
+	url, bf, ok := bs.backfills.next()
+	if !ok {
+		return false
+	}
+	job := &queue.Job{
+		ID:          queue.NewJobID(),
+		URL:         url,
+		ChatID:      bf.ChatID,
+		ThreadID:    bf.ThreadID,
+		UserID:      bf.UserID,
+		Username:    bf.Username,
+		LowPriority: true,
+		Captions:    bs.captions.get(bf.UserID),
+	}
+
+	skip := bs.isQueued(url)
+	if !skip {
+		_, skip = bs.fileCache.Get(cacheKey(job))
+	}
+	if !skip {
+		_, skip = bs.failures.Get(url)
+	}
+	if !skip {
+		if err := bs.submit(job); err != nil {
+			logger.Warn("Failed to queue backfill upload", "url", url, "error", err)
+		}
+	}
+
+	state, ok := bs.backfills.count(bf.ID, skip)
+	if ok && (len(state.Pending) == 0 || !skip) {
+		bs.edits.Wait(context.Background(), state.ChatID)
+		bs.bot.Edit(&tele.Message{ID: state.StatusMsgID, Chat: &tele.Chat{ID: state.ChatID}}, state.status(),
+			&tele.SendOptions{DisableWebPagePreview: true})
+	}
+	return skip
+}
+
+// isQueued reports whether a job for url is waiting or running.
+func (bs *BotService) isQueued(url string) bool {
+	for _, job := range bs.queue.Jobs() {
+		if job.URL == url {
+			return true
+		}
+	}
+	return false
+}
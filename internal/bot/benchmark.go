@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	tele "gopkg.in/telebot.v3"
+)
+
+// benchmarkTimeout bounds a whole /benchmark run; slow presets on a weak
+// CPU take minutes each.
+const benchmarkTimeout = 30 * time.Minute
+
+// pipelineProfiles are the settings the bot encodes with today, marked in
+// the /benchmark results.
+var pipelineProfiles = map[string]string{
+	"libx264 fast":   "re-encodes",
+	"libx264 medium": "compression",
+}
+
+// handleBenchmark handles /benchmark for bot admins: encodes a test clip
+// with every encoder and preset this ffmpeg has and reports their speed
+// and size, to pick encoder settings for the hardware.
+func (bs *BotService) handleBenchmark(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+	if !bs.benchmarkRunning.CompareAndSwap(false, true) {
+		return c.Send("A benchmark is already running.")
+	}
+
+	text := fmt.Sprintf("⏱ Encoding a %d-second 720p test clip with each encoder...", downloader.BenchmarkDuration)
+	if _, running := bs.queue.Len(); running > 0 {
+		text += fmt.Sprintf("\n%d downloads are running and share the CPU, so speeds may read low.", running)
+	}
+	msg, err := bs.bot.Send(c.Chat(), text, &tele.SendOptions{ThreadID: c.Message().ThreadID})
+	if err != nil {
+		bs.benchmarkRunning.Store(false)
+		return err
+	}
+	go bs.runBenchmark(msg)
+	return nil
+}
+
+// runBenchmark runs the encodes, keeping msg updated, and replaces it with
+// the results.
+func (bs *BotService) runBenchmark(msg *tele.Message) {
+	defer bs.benchmarkRunning.Store(false)
+	ctx, cancel := context.WithTimeout(context.Background(), benchmarkTimeout)
+	defer cancel()
+
+	profiles, err := bs.engine.BenchmarkProfiles(ctx)
+	if err != nil {
+		logger.Warn("Benchmark failed", "error", err)
+		bs.bot.Edit(msg, fmt.Sprintf("❌ Benchmark failed: %v", err))
+		return
+	}
+	logger.Info("Benchmark started", "profiles", len(profiles))
+	results, err := bs.engine.Benchmark(ctx, profiles, func(i int, p downloader.EncoderProfile) {
+		bs.edits.Wait(context.Background(), msg.Chat.ID)
+		bs.bot.Edit(msg, fmt.Sprintf("⏱ Benchmark %d/%d: %s...", i+1, len(profiles), p.Name()))
+	})
+	if err != nil {
+		logger.Warn("Benchmark failed", "error", err, "done", len(results))
+		bs.bot.Edit(msg, fmt.Sprintf("❌ Benchmark failed after %d of %d encodes: %v", len(results), len(profiles), err))
+		return
+	}
+	logger.Info("Benchmark done", "profiles", len(results))
+	bs.bot.Edit(msg, bs.benchmarkReport(results))
+}
+
+// benchmarkReport lists each profile's speed and size, with the fastest
+// and smallest working ones called out.
+func (bs *BotService) benchmarkReport(results []downloader.BenchmarkResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "⏱ Encoder benchmark (%d-second 720p clip)\n\n", downloader.BenchmarkDuration)
+	var fastest, smallest *downloader.BenchmarkResult
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil {
+			fmt.Fprintf(&b, "✗ %s — failed: %v\n", r.Profile.Name(), r.Err)
+			continue
+		}
+		fmt.Fprintf(&b, "• %s — %.1f× real time, %s", r.Profile.Name(), r.Speed(), bs.locale.Size(r.Size))
+		if use, ok := pipelineProfiles[r.Profile.Name()]; ok {
+			fmt.Fprintf(&b, " (used for %s)", use)
+		}
+		b.WriteString("\n")
+		if fastest == nil || r.Elapsed < fastest.Elapsed {
+			fastest = r
+		}
+		if smallest == nil || r.Size < smallest.Size {
+			smallest = r
+		}
+	}
+	if fastest == nil {
+		b.WriteString("\nNo encoder worked.")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\nFastest: %s. Smallest: %s.", fastest.Profile.Name(), smallest.Profile.Name())
+	return b.String()
+}
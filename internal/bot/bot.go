@@ -2,52 +2,380 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/fitz123/sushe/internal/config"
 	"github.com/fitz123/sushe/internal/downloader"
 	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/errsink"
+	"github.com/fitz123/sushe/internal/failcache"
+	"github.com/fitz123/sushe/internal/feed"
+	"github.com/fitz123/sushe/internal/filecache"
+	"github.com/fitz123/sushe/internal/format"
+	"github.com/fitz123/sushe/internal/history"
 	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/progress"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/quota"
+	"github.com/fitz123/sushe/internal/ratelimit"
+	"github.com/fitz123/sushe/internal/schedule"
+	"github.com/fitz123/sushe/internal/store"
+	"github.com/fitz123/sushe/internal/subscription"
 	"github.com/fitz123/sushe/internal/upload"
+	"github.com/fitz123/sushe/internal/webhook"
 	tele "gopkg.in/telebot.v3"
 )
 
 type BotService struct {
 	bot          *tele.Bot
+	uploads      *upload.Sender
 	engine       *engine.Engine
-	allowedUsers AllowedUsers
+	allowedUsers *whitelist
+	admins       AllowedUsers
+	allowedChats AllowedChats
+	stats        *jobStats
+	queue        *queue.Queue
+
+	// Failure feedback buttons (SUSHE_FAILURE_FEEDBACK)
+	askFeedback bool
+	feedback    *feedbackStats
+
+	// Offer YouTube alternatives for failed links (SUSHE_ALTERNATE_SEARCH)
+	alternateSearch bool
+
+	// Group downloads above this size need confirmation (SUSHE_GROUP_CONFIRM_MB)
+	groupConfirmSize int64
+	confirmations    *pendingJobs
+
+	// Torrent requests of non-admins waiting for an admin's approval, and
+	// how long a torrent job may run (SUSHE_TORRENTS, SUSHE_TORRENT_TIMEOUT)
+	torrentApprovals *pendingJobs
+	torrentTimeout   time.Duration
+
+	// Ask for a quality before downloading; 0 disables (SUSHE_QUALITY_PROMPT)
+	qualityTimeout time.Duration
+	qualityPicks   *pendingJobs
+
+	// Ask how to deliver videos over the upload limit; 0 disables (SUSHE_OVERSIZE_PROMPT)
+	oversizeTimeout time.Duration
+	oversizePicks   *pendingJobs
+
+	// Live stream recording (SUSHE_LIVE_MAX_MINUTES, SUSHE_LIVE_PROMPT)
+	liveMaxMinutes    int
+	livePromptTimeout time.Duration
+	livePicks         *pendingJobs
+
+	// Clips whose full video is cached, waiting for clip or full video
+	fullVideoPicks *pendingJobs
+
+	phases *jobPhases
+
+	// Status message edits shared by all jobs (SUSHE_CHAT_EDITS_PER_MIN, SUSHE_GLOBAL_MSGS_PER_SEC)
+	edits *ratelimit.Governor
+
+	// Per-user subtitle language set with /subs
+	subtitles *settingsStore[int64, subtitlePref]
+
+	// Per-chat cap on split parts set with /maxparts
+	partLimits *settingsStore[int64, int]
+
+	// Document versions offered for videos Telegram degraded on upload
+	fileOffers *pendingJobs
+
+	// Short-link resolution and host blocklist (SUSHE_BLOCKED_HOSTS, SUSHE_BLOCKLIST_FILE)
+	unshortener *downloader.Unshortener
+
+	// Telegram file_ids of past uploads, keyed by canonical URL and mode
+	fileCache *filecache.Cache
+
+	// Each user's delivered downloads, searched with /find (SUSHE_HISTORY_SIZE)
+	history *history.History
+
+	// Each user's downloads today against SUSHE_DAILY_DOWNLOADS and SUSHE_DAILY_GB
+	quota *quota.Tracker
+
+	// Links that recently failed for good, answered without a download (SUSHE_FAILURE_COOLDOWN)
+	failures *failcache.Cache
+
+	// Pinned per-chat status messages managed with /dashboard
+	dashboards *dashboards
+
+	// Message users about new changelog entries after an upgrade (SUSHE_ANNOUNCE_UPDATES)
+	announceUpdates bool
+
+	// Job events posted to SUSHE_WEBHOOK_URL; nil when unset
+	hooks *webhook.Sender
+
+	// Job progress for the web dashboard
+	progress *progress.Bus
+
+	// Named per-user settings saved with /preset
+	presets *presetStore
+
+	// Recent metadata probes, shared by prompts and parallel multi-link probing
+	probes *probeCache
+
+	// Message admins the files a job left behind (SUSHE_ARTIFACT_REPORT_DM)
+	artifactDM bool
+
+	// Admin user messaged about failed jobs (SUSHE_ERROR_REPORT_DM); 0 for none
+	errorReportTo int64
+
+	// Error tracker for handler panics and sites that keep failing; nil for none
+	errorSink errsink.Sink
+
+	// Sites failing in a row, sent to the error sink at SUSHE_SENTRY_REPEAT_FAILURES
+	extractionFailures *extractionFailures
+
+	// Downloads queued for later with /later; times of day are in location
+	// (SUSHE_TIMEZONE)
+	schedule *schedule.Schedule
+	location *time.Location
+
+	// Number and unit formatting in messages (SUSHE_LOCALE)
+	locale format.Locale
+
+	// Media library that delivered videos are filed into (SUSHE_LIBRARY_DIR)
+	libraryDir string
+
+	// Channel archivals started with /backfill
+	backfills *backfills
+
+	// Per-user /captions setting
+	captions *settingsStore[int64, string]
+
+	// Per-user /target chat that their downloads are posted to
+	targets *settingsStore[int64, int64]
+
+	// Per-chat /fanout chats that its videos are also posted to
+	fanOuts *settingsStore[int64, []int64]
+
+	// /maintenance: decline new downloads while the bot is being worked on
+	maintenance *maintenanceSwitch
+
+	// Per-group /spoiler mode, and the thumbnail classifier behind its auto
+	// mode (SUSHE_NSFW_URL, SUSHE_NSFW_COMMAND); nsfw is nil when unset
+	spoilers *settingsStore[int64, string]
+	nsfw     *nsfwDetector
+
+	// Channels watched for new uploads with /subscribe
+	subscriptions *subscription.Watches
+
+	// RSS/Atom feeds whose new items are downloaded into a chat
+	// (SUSHE_FEEDS); feedJob holds that chat, topic and quality
+	feeds   *feed.Watcher
+	feedJob queue.Job
+
+	// Set while a /benchmark runs, so runs don't overlap and skew each other
+	benchmarkRunning atomic.Bool
+
+	// Set while a /speedtest runs, so runs don't halve each other's results
+	speedTestRunning atomic.Bool
+
+	// Set while an /update runs, so the binary isn't replaced concurrently
+	updateRunning atomic.Bool
+
+	// Closed by Stop to end background loops
+	stop chan struct{}
 }
 
-func NewBotService(bot *tele.Bot, eng *engine.Engine, allowedUsers AllowedUsers) *BotService {
+func NewBotService(bot *tele.Bot, uploads *upload.Sender, eng *engine.Engine, errorSink errsink.Sink, allowedUsers, admins AllowedUsers, allowedChats AllowedChats) *BotService {
 	bs := &BotService{
 		bot:          bot,
+		uploads:      uploads,
 		engine:       eng,
-		allowedUsers: allowedUsers,
+		allowedUsers: newWhitelist(store.Path("allowed_users.json"), allowedUsers),
+		admins:       admins,
+		allowedChats: allowedChats,
+		stats:        newJobStats(),
+		askFeedback:  config.Bool("SUSHE_FAILURE_FEEDBACK", false),
+		feedback:     newFeedbackStats(),
+
+		alternateSearch: config.Bool("SUSHE_ALTERNATE_SEARCH", false),
+
+		groupConfirmSize: int64(config.Int("SUSHE_GROUP_CONFIRM_MB", 0)) * 1024 * 1024,
+		confirmations:    newPendingJobs(),
+		torrentApprovals: newPendingJobs(),
+		torrentTimeout:   config.Duration("SUSHE_TORRENT_TIMEOUT", 2*time.Hour),
+
+		qualityTimeout: config.Duration("SUSHE_QUALITY_PROMPT", 0),
+		qualityPicks:   newPendingJobs(),
+
+		oversizeTimeout:   config.Duration("SUSHE_OVERSIZE_PROMPT", 0),
+		oversizePicks:     newPendingJobs(),
+		liveMaxMinutes:    config.Int("SUSHE_LIVE_MAX_MINUTES", 30),
+		livePromptTimeout: config.Duration("SUSHE_LIVE_PROMPT", time.Minute),
+		livePicks:         newPendingJobs(),
+		fullVideoPicks:    newPendingJobs(),
+
+		phases:     newJobPhases(),
+		fileOffers: newPendingJobs(),
+		edits: ratelimit.New(ratelimit.Config{
+			PerChat:   config.Int("SUSHE_CHAT_EDITS_PER_MIN", 20),
+			ChatBurst: statusBurst,
+			Global:    config.Int("SUSHE_GLOBAL_MSGS_PER_SEC", 30),
+		}),
+
+		unshortener: downloader.NewUnshortener(loadBlockedHosts()),
+		fileCache:   filecache.New(store.Path("filecache.json"), config.Int("SUSHE_FILE_CACHE_SIZE", filecache.DefaultMaxEntries)),
+		history:     history.New(store.Path("history.jsonl"), config.Int("SUSHE_HISTORY_SIZE", history.DefaultMaxPerUser)),
+		quota:       quota.New(store.Path("quota.json"), dailyLimits()),
+		failures:    failcache.New(store.Path("failures.json"), config.Duration("SUSHE_FAILURE_COOLDOWN", failcache.DefaultCooldown)),
+
+		subtitles:       newSubtitlePrefs(store.Path("subtitles.json")),
+		partLimits:      newChatPartLimits(store.Path("maxparts.json")),
+		presets:         newPresetStore(store.Path("presets.json")),
+		dashboards:      newDashboards(store.Path("dashboards.json")),
+		announceUpdates: config.Bool("SUSHE_ANNOUNCE_UPDATES", false),
+
+		hooks:      newWebhookSender(),
+		progress:   progress.NewBus(),
+		probes:     newProbeCache(),
+		artifactDM: config.Bool("SUSHE_ARTIFACT_REPORT_DM", false),
+
+		errorReportTo:      int64(config.Int("SUSHE_ERROR_REPORT_DM", 0)),
+		errorSink:          errorSink,
+		extractionFailures: newExtractionFailures(config.Int("SUSHE_SENTRY_REPEAT_FAILURES", 3)),
+
+		schedule: schedule.New(store.Path("schedule.json")),
+		location: loadLocation(config.String("SUSHE_TIMEZONE", "")),
+		locale:   loadLocale(config.String("SUSHE_LOCALE", "")),
+		stop:     make(chan struct{}),
+
+		backfills:     newBackfills(store.Path("backfills.json")),
+		subscriptions: subscription.NewWatches(store.Path("subscriptions.json")),
+		captions:      newCaptionPrefs(store.Path("captions.json")),
+		targets:       newTargetPrefs(store.Path("targets.json")),
+		fanOuts:       newFanOutPrefs(store.Path("fanout.json")),
+		maintenance:   newMaintenanceSwitch(store.Path("maintenance.json"), config.Bool("SUSHE_MAINTENANCE", false)),
+		spoilers:      newSpoilerPrefs(store.Path("spoilers.json")),
+		nsfw:          newNSFWDetector(),
+
+		libraryDir: config.String("SUSHE_LIBRARY_DIR", ""),
+	}
+	bs.feeds, bs.feedJob = newFeedWatcher()
+
+	domainLimits, err := queue.ParseDomainLimits(config.String("SUSHE_DOMAIN_LIMITS", ""))
+	if err != nil {
+		logger.Warn("Ignoring SUSHE_DOMAIN_LIMITS", "error", err)
 	}
+	bs.queue = queue.New(queue.Config{
+		Workers:    config.Int("SUSHE_WORKERS", queue.DefaultWorkers),
+		StateFile:  store.Path("jobs.json"),
+		MaxPending: config.Int("SUSHE_MAX_QUEUE", 50),
+		MaxPerUser: config.Int("SUSHE_MAX_USER_JOBS", 5),
+		Unlimited:  bs.admins,
+
+		DomainLimits:      domainLimits,
+		MaxRunningPerUser: config.Int("SUSHE_MAX_USER_RUNNING", 0),
+
+		Errors: errorSink,
+	}, bs.runJob)
 	bs.registerHandlers()
 	return bs
 }
 
+// loadLocale returns the formatting locale for a SUSHE_LOCALE tag, English
+// if it is empty or unknown.
+func loadLocale(tag string) format.Locale {
+	if tag == "" {
+		return format.English
+	}
+	locale, ok := format.Lookup(tag)
+	if !ok {
+		logger.Warn("Unknown SUSHE_LOCALE, using English", "locale", tag)
+		return format.English
+	}
+	return locale
+}
+
 func (bs *BotService) Start() {
+	bs.queue.Start()
+	bs.refreshDashboards()
+	go bs.runSchedule()
+	go bs.runBackfills()
+	go bs.runSubscriptions()
+	go bs.feeds.Run(bs.stop, bs.queueFeedItem)
+	if bs.announceUpdates {
+		go bs.sendUpdateAnnouncement()
+	}
 	bs.bot.Start()
 }
 
 func (bs *BotService) Stop() {
+	close(bs.stop)
 	bs.bot.Stop()
+	bs.queue.Stop()
+	bs.hooks.Close(webhookDrainTimeout)
 }
 
 func (bs *BotService) registerHandlers() {
 	// Apply auth middleware to restrict access to whitelisted users
-	bs.bot.Use(AuthMiddleware(bs.allowedUsers))
+	bs.bot.Use(AuthMiddleware(bs.allowedUsers, bs.admins, bs.allowedChats))
 
 	bs.bot.Handle("/start", bs.handleStart)
 	bs.bot.Handle("/help", bs.handleHelp)
 	bs.bot.Handle("/dl", bs.handleDL)
+	bs.bot.Handle("/audio", bs.handleAudio)
+	bs.bot.Handle("/archive", bs.handleArchive)
+	bs.bot.Handle("/voice", bs.handleVoice)
+	bs.bot.Handle("/clip", bs.handleClip)
+	bs.bot.Handle("/gif", bs.handleGIF)
+	bs.bot.Handle("/frames", bs.handleFrames)
+	bs.bot.Handle("/note", bs.handleNote)
+	bs.bot.Handle("/preset", bs.handlePreset)
+	bs.bot.Handle("/stats", bs.handleStats)
+	bs.bot.Handle("/globalstats", bs.handleGlobalStats)
+	bs.bot.Handle("/feedback", bs.handleFeedbackReport)
+	bs.bot.Handle("/simulate", bs.handleSimulate)
+	bs.bot.Handle("/maintenance", bs.handleMaintenance)
+	bs.bot.Handle("/benchmark", bs.handleBenchmark)
+	bs.bot.Handle("/speedtest", bs.handleSpeedTest)
+	bs.bot.Handle("/allow", bs.handleAllow)
+	bs.bot.Handle("/adduser", bs.handleAllow)
+	bs.bot.Handle("/update", bs.handleUpdate)
+	bs.bot.Handle("/deny", bs.handleDeny)
+	bs.bot.Handle("/status", bs.handleStatus)
+	bs.bot.Handle(&tele.Btn{Unique: "feedback"}, bs.handleFeedbackButton)
+	bs.bot.Handle(&tele.Btn{Unique: "alternate"}, bs.handleAlternateButton)
+	bs.bot.Handle(&tele.Btn{Unique: "asfile"}, bs.handleAsFileButton)
+	bs.bot.Handle("/cancel", bs.handleCancel)
+	bs.bot.Handle("/queue", bs.handleQueue)
+	bs.bot.Handle("/find", bs.handleFind)
+	bs.bot.Handle("/quota", bs.handleQuota)
+	bs.bot.Handle("/whatsnew", bs.handleWhatsNew)
+	bs.bot.Handle("/dashboard", bs.handleDashboard)
+	bs.bot.Handle("/subs", bs.handleSubs)
+	bs.bot.Handle("/maxparts", bs.handleMaxParts)
+	bs.bot.Handle("/spoiler", bs.handleSpoiler)
+	bs.bot.Handle("/later", bs.handleLater)
+	bs.bot.Handle("/backfill", bs.handleBackfill)
+	bs.bot.Handle("/subscribe", bs.handleSubscribe)
+	bs.bot.Handle("/captions", bs.handleCaptions)
+	bs.bot.Handle("/target", bs.handleTarget)
+	bs.bot.Handle("/fanout", bs.handleFanOut)
+	bs.bot.Handle("/unsubscribe", bs.handleUnsubscribe)
+	bs.bot.Handle("/mirror", bs.handleMirrorTo)
+	bs.bot.Handle(&tele.Btn{Unique: "cancel"}, bs.handleCancelButton)
+	bs.bot.Handle(&tele.Btn{Unique: "pause"}, bs.handlePauseButton)
+	bs.bot.Handle(&tele.Btn{Unique: "resend"}, bs.handleResendButton)
+	bs.bot.Handle(&tele.Btn{Unique: "confirm"}, bs.handleConfirmButton)
+	bs.bot.Handle(&tele.Btn{Unique: "decline"}, bs.handleConfirmButton)
+	bs.bot.Handle(&tele.Btn{Unique: "torrentok"}, bs.handleTorrentButton)
+	bs.bot.Handle(&tele.Btn{Unique: "torrentno"}, bs.handleTorrentButton)
+	bs.bot.Handle(&tele.Btn{Unique: "quality"}, bs.handleQualityButton)
+	bs.bot.Handle(&tele.Btn{Unique: "oversize"}, bs.handleOversizeButton)
+	bs.bot.Handle(&tele.Btn{Unique: "live"}, bs.handleLiveButton)
+	bs.bot.Handle(&tele.Btn{Unique: "fullvideo"}, bs.handleFullVideoButton)
 
 	// Handle all text messages to auto-detect URLs
 	bs.bot.Handle(tele.OnText, bs.handleText)
+	bs.bot.Handle(tele.OnDocument, bs.handleDocument)
 }
 
 func (bs *BotService) handleStart(c tele.Context) error {
@@ -65,6 +393,17 @@ func (bs *BotService) handleStart(c tele.Context) error {
 	)
 }
 
+// uploadLimitNote explains why videos are split or compressed when the bot
+// runs against the official Bot API server, whose 50MB limit is far below
+// what users expect; "" for a local server.
+func (bs *BotService) uploadLimitNote() string {
+	limit := bs.engine.UploadLimits().File
+	if limit > downloader.OfficialAPIUploadLimit {
+		return ""
+	}
+	return fmt.Sprintf("\n(Telegram limits bots on its public Bot API to %s per file)", bs.locale.Size(limit))
+}
+
 func (bs *BotService) handleHelp(c tele.Context) error {
 	return c.Send(
 		"How to use Sushe:\n\n" +
@@ -73,17 +412,62 @@ func (bs *BotService) handleHelp(c tele.Context) error {
 			"3. Receive the video(s) directly in Telegram\n\n" +
 			"Supported platforms include YouTube, Twitter, TikTok, Instagram, Reddit, Vimeo, and many others.\n\n" +
 			"Features:\n" +
-			"- Videos over 1.9GB are automatically split into parts\n" +
+			fmt.Sprintf("- Videos over %s are automatically split into parts%s\n", bs.locale.Size(bs.engine.UploadLimits().Upload), bs.uploadLimitNote()) +
 			"- Parts are threaded as replies for easy viewing\n" +
-			"- Playlist support (max 50 videos per playlist)\n" +
+			fmt.Sprintf("- Playlist support (max %d videos per playlist)\n", bs.engine.PlaylistLimit()) +
 			"- Playlist videos are threaded as reply chain\n" +
-			"- Max resolution: 1080p\n\n" +
+			"- Max resolution: 1080p\n" +
+			"- Add \"stab\" after a link to stabilize shaky footage\n" +
+			torrentHelp(bs.engine.Torrents()) + "\n" +
+			"Commands:\n" +
+			"- /audio <url> — extract the audio as MP3\n" +
+			"- /voice <url> — send the audio as a voice message\n" +
+			"- /archive <url> — MKV with all audio/subtitle tracks, sent as a file\n" +
+			"- /clip <url> <start> <end> — just that part of the video, e.g. 1:30 2:15\n" +
+			"- /gif <url> [<start> <end>] — a short video (or part of one) as a silent looping GIF\n" +
+			"- /note <url> [<start> <end>] — up to a minute as a round video note\n" +
+			"- /frames <url> [count | times...] — screenshots as an album, e.g. 8 or 0:30 1:15\n" +
+			"- /subs <lang|auto|off> — also send subtitles as an .srt file with your videos (auto: in your language)\n" +
+			"- /subs <lang> burn — burn subtitles into the video instead\n" +
+			"- /captions <full|parts|off|description> — how much text your videos come with\n" +
+			"- /target <channel|off> — post what you download here to your channel instead\n" +
+			"- /fanout <chats...|off> — also post videos downloaded here to several chats, uploaded once\n" +
+			"- /preset save <name>: <options> — save settings, then /preset use <name> <url>\n" +
+			"- /mirror <chat> — reply to a file I sent to post it in another chat, no re-upload\n" +
+			"- /later <HH:MM|delay> <url> — download at a later time, e.g. /later 22:00 <url>\n" +
+			"- /subscribe <channel> [@chat] [interval] [quality] — download a channel's new uploads as they appear\n" +
+			"- /queue — your downloads, their progress and estimated wait; pause a download to free the bandwidth\n" +
+			"- /find <words> — search your past downloads by title or channel and get them again\n" +
+			quotaHelp(bs.quota.Enabled()) +
+			"- /status — whether the bot is taking downloads and how busy it is\n" +
+			"- /cancel [id] — cancel your downloads\n" +
+			"- /dashboard [off] — pinned daily stats for this chat (chat admins)\n" +
+			"- /maxparts <n|off> — compress videos that would be split into more parts (chat admins)\n" +
+			"- /spoiler <auto|always|off> — hide videos that look NSFW (or all of them) behind a spoiler (chat admins)\n" +
+			adminHelp(roleOf(c)) +
+			"- /whatsnew — recent changes\n\n" +
 			"Playlist Limitations:\n" +
-			"- Max 50 videos per playlist\n" +
+			fmt.Sprintf("- Max %d videos per playlist\n", bs.engine.PlaylistLimit()) +
 			"- Videos longer than 2 hours are skipped",
 	)
 }
 
+// adminHelp is the /help section on bot admin commands, shown to bot
+// admins only.
+func adminHelp(role Role) string {
+	if role != RoleAdmin {
+		return ""
+	}
+	return "- /backfill <channel> [YYYY-MM-DD] — archive a channel's uploads in the background (bot admins)\n" +
+		"- /maintenance on [<HH:MM|delay>] [reason] | off — decline new downloads for a while (bot admins)\n" +
+		"- /benchmark — compare the speed and output size of this server's video encoders (bot admins)\n" +
+		"- /speedtest — measure this server's download speed and upload speed to the Bot API server (bot admins)\n" +
+		"- /adduser <user id> | /deny <user id> — let a user use the bot or not; /allow lists who can, /allow reload rereads the file (bot admins)\n" +
+		"- /globalstats — jobs, queue, access and the busiest users (bot admins)\n" +
+		"- /quota <user id> [reset|unlimited|limited] — see or override a user's daily quota (bot admins)\n" +
+		"- /update — update yt-dlp, the first thing to try when a site stops working (bot admins)\n"
+}
+
 // handleDL handles the /dl command with GENERAL topic guard
 func (bs *BotService) handleDL(c tele.Context) error {
 	// GENERAL topic guard (Bot API bug #447)
@@ -99,16 +483,12 @@ func (bs *BotService) handleDL(c tele.Context) error {
 		return c.Send("Usage: /dl <video URL>")
 	}
 
-	urls := downloader.ExtractURLs(text)
+	urls := bs.messageLinks(text)
 	if len(urls) == 0 {
 		return c.Send("No video URL detected. Send a valid link after /dl")
 	}
 
-	for _, url := range urls {
-		if err := bs.processURL(c, url); err != nil {
-			logger.Error("Failed to process URL", "url", url, "error", err)
-		}
-	}
+	bs.enqueueLinks(c, text, urls)
 
 	return nil
 }
@@ -128,7 +508,7 @@ func (bs *BotService) handleText(c tele.Context) error {
 	text := c.Text()
 
 	// Extract URLs from the message
-	urls := downloader.ExtractURLs(text)
+	urls := bs.messageLinks(text)
 	if len(urls) == 0 {
 		// No URLs found — only send help in private chats
 		if c.Chat() != nil && c.Chat().Type == tele.ChatPrivate && !strings.HasPrefix(text, "/") {
@@ -137,29 +517,122 @@ func (bs *BotService) handleText(c tele.Context) error {
 		return nil
 	}
 
-	// Process each URL (usually just one)
-	for _, url := range urls {
-		if err := bs.processURL(c, url); err != nil {
-			logger.Error("Failed to process URL", "url", url, "error", err)
-			// Error already sent to user in processURL
-		}
-	}
+	// Queue each URL (usually just one)
+	bs.enqueueLinks(c, text, urls)
 
 	return nil
 }
 
-func (bs *BotService) processURL(c tele.Context, url string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+// runJob is the queue handler: it downloads a job's URL via the engine and
+// uploads the result via telebot, reporting progress on the job's status message.
+func (bs *BotService) runJob(parent context.Context, job *queue.Job) (err error) {
+	// A live recording gets its length on top; torrents depend on their swarm
+	timeout := 15*time.Minute + time.Duration(job.LiveMinutes)*time.Minute
+	if downloader.IsTorrentURL(job.URL) {
+		timeout = bs.torrentTimeout
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
+	url := job.URL
+	// Last lines yt-dlp and ffmpeg print, for the admin failure report
+	tail := &downloader.OutputTail{}
+	ctx = downloader.WithOutputTail(ctx, tail)
+
+	bs.phases.set(job.ID, "Starting")
+	defer bs.phases.clear(job.ID)
+	bs.emitPhase(job, "starting")
+	bs.publishProgress(job, "starting", 0, "Starting")
+
+	defer func() {
+		bs.emitResult(job, err, queue.IsCancelled(parent), parent.Err() != nil)
+		bs.publishResult(job, err, queue.IsCancelled(parent), parent.Err() != nil)
+		if job.Scheduled && parent.Err() == nil {
+			bs.notifyScheduled(job, err)
+		}
+		if err != nil && queue.IsCancelled(parent) {
+			bs.jobStatus(job, "Download cancelled.")
+			return
+		}
+		if err == nil {
+			bs.failures.Delete(job.URL)
+			bs.extractionFailures.succeed(urlDomain(job.URL))
+			bs.confirmDelivery(job)
+			return
+		}
+		// Failures caused by the bot shutting down say nothing about the link
+		if parent.Err() != nil {
+			return
+		}
+		if !strings.HasPrefix(job.URL, simulatePrefix) {
+			bs.failures.Record(job.URL, err)
+		}
+		bs.reportError(job, err, tail)
+		bs.captureExtractionFailure(job, err, tail)
+		// Ask what went wrong and look for alternative sources
+		if bs.askFeedback {
+			bs.askFailureFeedback(job)
+		}
+		if bs.alternateSearch {
+			go bs.offerAlternate(job)
+		}
+	}()
+
+	// /simulate jobs stay out of the resource stats
+	if strings.HasPrefix(url, simulatePrefix) {
+		return bs.runSimulation(ctx, job)
+	}
 
-	// First check if this is a playlist
-	isPlaylist, playlistInfo, _ := bs.engine.IsPlaylist(ctx, url)
-	if isPlaylist && playlistInfo != nil {
-		return bs.processPlaylist(c, url, playlistInfo)
+	// Track peak RSS and CPU time of all yt-dlp/ffmpeg subprocesses for this job
+	usage := &downloader.Usage{}
+	ctx = downloader.WithUsage(ctx, usage)
+	pauser := &downloader.Pauser{}
+	ctx = downloader.WithPauser(ctx, pauser)
+	bs.phases.setPauser(job.ID, pauser)
+	startedAt := time.Now()
+	defer func() {
+		bs.stats.record(usage, time.Since(startedAt), err)
+		bs.recordDashboard(job.ChatID, func(d *dashboard) {
+			if err != nil {
+				d.Failed++
+			} else {
+				d.Downloads++
+			}
+		})
+		jobLog(job).Info("Job resource usage",
+			"ok", err == nil,
+			"peakRSS", usage.PeakRSS(),
+			"cpuSeconds", usage.CPUTime().Seconds(),
+			"processes", usage.Processes(),
+		)
+	}()
+
+	// First check if this is a playlist. On gallery sites a "playlist" is a
+	// post with several items (Instagram carousel, Reddit gallery): those go
+	// out as one album, with the playlist path as the fallback.
+	opts := jobOptions(job)
+	opts.Probed = bs.probes.peek(url)
+	var isPlaylist bool
+	var playlistInfo *downloader.PlaylistInfo
+	if !downloader.IsTorrentURL(url) {
+		isPlaylist, playlistInfo, _ = bs.engine.IsPlaylist(ctx, url)
+	}
+	opts.Album = isPlaylist && downloader.IsGalleryURL(url) && opts.PlainVideo()
+	if opts.PlainVideo() {
+		opts.MaxParts = bs.partLimits.get(job.ChatID)
+	}
+	if isPlaylist && playlistInfo != nil && !opts.Album {
+		return bs.processPlaylist(ctx, job, url, playlistInfo)
 	}
 
 	// Not a playlist, process as single video
-	statusMsg, err := bs.bot.Send(c.Chat(), "Starting download...", &tele.SendOptions{ThreadID: c.Message().ThreadID})
+	startText := "Starting download..."
+	if job.Restored {
+		startText = "Bot restarted, resuming download..."
+	}
+	if job.LiveMinutes > 0 {
+		startText = fmt.Sprintf("🔴 Recording the live stream for up to %d min...", job.LiveMinutes)
+	}
+	statusMsg, err := bs.jobStatus(job, startText, cancelMarkup(job.ID))
 	if err != nil {
 		return err
 	}
@@ -185,9 +658,9 @@ func (bs *BotService) processURL(c tele.Context, url string) error {
 		switch phase {
 		case "downloading":
 			if detail != "" {
-				statusText = fmt.Sprintf("Downloading: %.0f%% | %s", percent, detail)
+				statusText = fmt.Sprintf("Downloading: %s | %s", bs.locale.Percent(percent), detail)
 			} else {
-				statusText = fmt.Sprintf("Downloading: %.0f%%", percent)
+				statusText = fmt.Sprintf("Downloading: %s", bs.locale.Percent(percent))
 			}
 		case "merging":
 			statusText = "Merging video and audio..."
@@ -195,20 +668,31 @@ func (bs *BotService) processURL(c tele.Context, url string) error {
 			if detail != "" && percent == 0 {
 				statusText = fmt.Sprintf("Downloaded %s format, converting to H.264...", strings.ToUpper(detail))
 			} else {
-				statusText = fmt.Sprintf("Converting to H.264: %.0f%%", percent)
+				statusText = fmt.Sprintf("Converting to H.264: %s", bs.locale.Percent(percent))
 			}
+		case "compressing":
+			statusText = fmt.Sprintf("Compressing to fit the upload limit: %s%s", bs.locale.Percent(percent), bs.uploadLimitNote())
+		case "stabilizing":
+			statusText = fmt.Sprintf("Analyzing camera shake: %s", bs.locale.Percent(percent))
 		case "splitting":
 			if detail != "" {
-				statusText = fmt.Sprintf("Splitting video: %s (%.0f%%)", detail, percent)
+				statusText = fmt.Sprintf("Splitting video: %s (%s)%s", detail, bs.locale.Percent(percent), bs.uploadLimitNote())
 			} else {
-				statusText = fmt.Sprintf("Splitting video: %.0f%%", percent)
+				statusText = fmt.Sprintf("Splitting video: %s%s", bs.locale.Percent(percent), bs.uploadLimitNote())
 			}
 		default:
 			statusText = "Processing..."
 		}
+		bs.phases.set(job.ID, statusText)
+		bs.emitPhase(job, phase)
+		bs.publishProgress(job, phase, percent, statusText)
 
-		if _, err := bs.bot.Edit(statusMsg, statusText); err != nil {
-			logger.Debug("Failed to update status message", "error", err)
+		// Over the chat's edit budget: skip this update, a later one will show
+		if !bs.edits.Allow(job.ChatID) {
+			return
+		}
+		if _, err := bs.bot.Edit(statusMsg, statusText, cancelMarkup(job.ID)); err != nil {
+			jobLog(job).Debug("Failed to update status message", "error", err)
 		} else {
 			lastUpdate = now
 			lastPercent = percent
@@ -216,49 +700,128 @@ func (bs *BotService) processURL(c tele.Context, url string) error {
 	}
 
 	// Download and process via engine
-	result, err := bs.engine.Process(ctx, url, progressCb)
+	result, err := bs.engine.ProcessWithOptions(ctx, url, opts, progressCb)
+	if err != nil && opts.Album && playlistInfo != nil && ctx.Err() == nil {
+		jobLog(job).Warn("Album download failed, processing the post as a playlist", "url", url, "error", err)
+		bs.bot.Delete(statusMsg)
+		return bs.processPlaylist(ctx, job, url, playlistInfo)
+	}
 	if err != nil {
-		bs.bot.Edit(statusMsg, fmt.Sprintf("Download failed: %v", err))
+		text := fmt.Sprintf("Download failed: %v", err)
+		if errors.Is(err, downloader.ErrAnimationTooLong) {
+			text = fmt.Sprintf("This video is too long for a GIF (max %.0f seconds). Pick a part of it: /gif <url> <start> <end>",
+				downloader.MaxAnimationDuration)
+		}
+		if errors.Is(err, downloader.ErrTooManyParts) {
+			text = partLimitText(opts.MaxParts)
+		}
+		bs.editStatus(job, statusMsg, text)
+		return err
+	}
+	defer bs.cleanup(job, result)
+	if bs.libraryDir != "" {
+		defer func() {
+			if err == nil {
+				bs.addToLibrary(job, result)
+			}
+		}()
+	}
+
+	// Cancelled after the last subprocess finished: don't start uploading
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	defer bs.engine.Cleanup(result)
 
 	// Upload
+	bs.phases.set(job.ID, "Uploading")
+	bs.emitPhase(job, "uploading")
+	if result.IsAudio {
+		return bs.uploadAudio(job, statusMsg, result)
+	}
+	if result.IsVoice {
+		return bs.uploadVoice(job, statusMsg, result)
+	}
+	if result.IsAnimation {
+		return bs.uploadAnimation(job, statusMsg, result)
+	}
+	if result.IsVideoNote {
+		return bs.uploadVideoNote(job, statusMsg, result)
+	}
+	if len(result.FramePaths) > 0 {
+		return bs.uploadFrames(job, statusMsg, result)
+	}
+	if len(result.Media) > 0 {
+		return bs.uploadAlbum(job, statusMsg, result)
+	}
+	if result.IsArchive || result.IsDocument {
+		return bs.uploadDocument(job, statusMsg, result)
+	}
 	if result.IsSplit {
-		return bs.uploadSplitVideo(c, statusMsg, result, nil)
+		return bs.uploadSplitVideo(job, statusMsg, result, nil)
 	}
-	return bs.uploadSingleVideo(c, statusMsg, result)
+	return bs.uploadSingleVideo(job, statusMsg, result)
 }
 
 // processPlaylist handles downloading and uploading playlist videos
-func (bs *BotService) processPlaylist(c tele.Context, playlistURL string, playlistInfo *downloader.PlaylistInfo) error {
+func (bs *BotService) processPlaylist(ctx context.Context, job *queue.Job, playlistURL string, playlistInfo *downloader.PlaylistInfo) error {
 	playlistMsg := fmt.Sprintf("Playlist: %s — %d videos", playlistInfo.Title, playlistInfo.PlaylistCount)
-	statusMsg, err := bs.bot.Send(c.Chat(), playlistMsg, &tele.SendOptions{ThreadID: c.Message().ThreadID})
+	if playlistInfo.TotalCount > playlistInfo.PlaylistCount {
+		playlistMsg += fmt.Sprintf(" (first %d of %d)", playlistInfo.PlaylistCount, playlistInfo.TotalCount)
+	}
+	statusMsg, err := bs.jobStatus(job, playlistMsg, cancelMarkup(job.ID))
 	if err != nil {
 		return err
 	}
+	// The quota was checked for the link; each video counts against it
+	budget, ok := bs.playlistBudget(job)
+	if !ok {
+		bs.editStatus(job, statusMsg, bs.quotaExceeded(job.UserID))
+		return nil
+	}
 
-	// Progress callback for playlist downloads
+	// Progress callback for playlist downloads: one combined message with the
+	// overall progress on top and the current video below
+	var lastUpdate time.Time
+	var mu sync.Mutex
 	progressCb := func(videoNum, totalVideos int, phase string, percent float64) {
+		mu.Lock()
+		defer mu.Unlock()
+		bs.emitPhase(job, phase)
+		if time.Since(lastUpdate) < 2*time.Second && percent < 100 {
+			return
+		}
+
 		var statusText string
 		switch phase {
 		case "downloading":
-			statusText = fmt.Sprintf("Video %d/%d: Downloading %.0f%%", videoNum, totalVideos, percent)
+			statusText = fmt.Sprintf("Video %d/%d: Downloading %s", videoNum, totalVideos, bs.locale.Percent(percent))
 		case "encoding":
-			statusText = fmt.Sprintf("Video %d/%d: Converting to H.264: %.0f%%", videoNum, totalVideos, percent)
+			statusText = fmt.Sprintf("Video %d/%d: Converting to H.264: %s", videoNum, totalVideos, bs.locale.Percent(percent))
+		case "compressing":
+			statusText = fmt.Sprintf("Video %d/%d: Compressing: %s", videoNum, totalVideos, bs.locale.Percent(percent))
+		case "stabilizing":
+			statusText = fmt.Sprintf("Video %d/%d: Analyzing camera shake: %s", videoNum, totalVideos, bs.locale.Percent(percent))
 		case "splitting":
-			statusText = fmt.Sprintf("Video %d/%d: Splitting: %.0f%%", videoNum, totalVideos, percent)
+			statusText = fmt.Sprintf("Video %d/%d: Splitting: %s", videoNum, totalVideos, bs.locale.Percent(percent))
 		default:
 			statusText = fmt.Sprintf("Video %d/%d: Processing...", videoNum, totalVideos)
 		}
-		bs.bot.Edit(statusMsg, statusText)
+		bs.phases.set(job.ID, statusText)
+
+		overall := (float64(videoNum-1) + percent/100) / float64(totalVideos) * 100
+		header := fmt.Sprintf("%s\nOverall: %s", playlistMsg, bs.locale.Percent(overall))
+		bs.publishProgress(job, phase, overall, header+"\n"+statusText)
+		if !bs.edits.Allow(job.ChatID) {
+			return
+		}
+		if _, err := bs.bot.Edit(statusMsg, header+"\n"+statusText, cancelMarkup(job.ID)); err == nil {
+			lastUpdate = time.Now()
+		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
-	defer cancel()
-	results, err := bs.engine.ProcessPlaylist(ctx, playlistURL, progressCb)
+	results, err := bs.engine.ProcessPlaylistWithin(ctx, playlistURL, budget, progressCb)
 	if err != nil {
-		bs.bot.Edit(statusMsg, fmt.Sprintf("Playlist download failed: %v", err))
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Playlist download failed: %v", err))
 		return err
 	}
 
@@ -267,163 +830,236 @@ func (bs *BotService) processPlaylist(c tele.Context, playlistURL string, playli
 	for i, result := range results {
 		videoNum := i + 1
 
+		// Cancelled: drop the remaining results instead of uploading them
+		if err := ctx.Err(); err != nil {
+			for _, r := range results[i:] {
+				bs.cleanup(job, r)
+			}
+			return err
+		}
+
+		// The user's other jobs may have used up the quota meanwhile
+		if _, ok := bs.playlistBudget(job); !ok {
+			for _, r := range results[i:] {
+				bs.cleanup(job, r)
+			}
+			bs.editStatus(job, statusMsg, "Playlist stopped early. "+bs.quotaExceeded(job.UserID))
+			return nil
+		}
+
 		// Update status for upload phase
-		bs.bot.Edit(statusMsg, fmt.Sprintf("Video %d/%d: Uploading...\n%s | %s",
-			videoNum, len(results), result.Title, formatSize(result.FileSize)))
+		bs.phases.set(job.ID, fmt.Sprintf("Video %d/%d: Uploading", videoNum, len(results)))
+		bs.emitPhase(job, "uploading")
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Video %d/%d: Uploading...\n%s | %s",
+			videoNum, len(results), result.Title, bs.locale.Size(result.FileSize)), cancelMarkup(job.ID))
 
 		var uploadedMsg *tele.Message
 		var uploadErr error
 
 		if result.IsSplit {
-			uploadedMsg, uploadErr = bs.uploadPlaylistSplitVideo(c, statusMsg, result, videoNum, len(results), lastReplyMsg)
+			uploadedMsg, uploadErr = bs.uploadPlaylistSplitVideo(job, statusMsg, result, videoNum, len(results), lastReplyMsg)
 		} else {
-			uploadedMsg, uploadErr = bs.uploadPlaylistSingleVideo(c, statusMsg, result, videoNum, len(results), lastReplyMsg)
+			uploadedMsg, uploadErr = bs.uploadPlaylistSingleVideo(job, statusMsg, result, videoNum, len(results), lastReplyMsg)
 		}
 
-		bs.engine.Cleanup(result)
+		bs.cleanup(job, result)
 
 		if uploadErr != nil {
-			logger.Error("Failed to upload playlist video", "index", i, "title", result.Title, "error", uploadErr)
-			bs.bot.Edit(statusMsg, fmt.Sprintf("Video %d/%d: Upload failed - %v\n%s",
-				videoNum, len(results), uploadErr, result.Title))
+			jobLog(job).Error("Failed to upload playlist video", "index", i, "title", result.Title, "error", uploadErr)
+			bs.editStatus(job, statusMsg, fmt.Sprintf("Video %d/%d: Upload failed - %v\n%s",
+				videoNum, len(results), uploadErr, result.Title), cancelMarkup(job.ID))
 			time.Sleep(2 * time.Second)
 			continue
 		}
 
 		lastReplyMsg = uploadedMsg
+		bs.quota.Add(job.UserID, result.FileSize)
 
-		logger.Info("Successfully processed playlist video",
+		jobLog(job).Info("Successfully processed playlist video",
 			"index", i+1,
 			"title", result.Title,
 			"size", result.FileSize,
-			"user", c.Sender().Username)
+			"user", job.Username)
 	}
 
-	bs.bot.Delete(statusMsg)
+	if _, ok := bs.playlistBudget(job); !ok && len(results) < playlistInfo.PlaylistCount {
+		bs.editStatus(job, statusMsg, "Playlist stopped early. "+bs.quotaExceeded(job.UserID))
+	} else {
+		bs.bot.Delete(statusMsg)
+	}
 
-	logger.Info("Successfully processed playlist",
+	jobLog(job).Info("Successfully processed playlist",
 		"title", playlistInfo.Title,
 		"videos", playlistInfo.PlaylistCount,
-		"user", c.Sender().Username)
+		"user", job.Username)
 
 	return nil
 }
 
 // uploadSingleVideo uploads a non-split video result.
-// Uses file:// URI so the local Bot API server reads directly from disk,
-// avoiding HTTP multipart upload timeouts/EOF on large files.
-func (bs *BotService) uploadSingleVideo(c tele.Context, statusMsg *tele.Message, result *engine.ProcessResult) error {
-	sendOpts := &tele.SendOptions{ThreadID: c.Message().ThreadID}
-	bs.bot.Edit(statusMsg, fmt.Sprintf("Uploading...\n%s | %s",
-		result.Title, formatSize(result.FileSize)))
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadSingleVideo(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult) error {
+	job.NSFW = bs.classify(job, result.ThumbnailPath)
+	sendOpts := &tele.SendOptions{ThreadID: deliveryThread(job), HasSpoiler: bs.spoilerIn(deliveryChat(job).ID, job.NSFW)}
+	bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading...\n%s | %s",
+		result.Title, bs.locale.Size(result.FileSize)), cancelMarkup(job.ID))
 
 	video := &tele.Video{
-		File:      tele.FromURL("file://" + result.FilePath),
+		File:      bs.uploads.LocalFile(result.FilePath),
 		FileName:  result.FileName,
-		Caption:   result.Title,
+		Caption:   jobCaption(job, videoCaption(job, result), ""),
 		Width:     result.Width,
 		Height:    result.Height,
 		Duration:  int(result.Duration),
 		Streaming: true,
+		Thumbnail: bs.uploads.Thumbnail(result.ThumbnailPath),
 	}
 
-	_, err := upload.SendWithRetry(bs.bot, c.Chat(), video, sendOpts)
+	sentMsg, err := bs.uploads.Send(deliveryChat(job), video, sendOpts)
 	if err != nil {
-		bs.bot.Edit(statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
 		return err
 	}
+	subs := bs.sendSubtitles(job, sentMsg, result)
+	bs.sendDescription(job, sentMsg)
+	bs.rememberUpload(job, result, append([]*tele.Message{sentMsg}, subs...)...)
+	bs.fanOut(job, append([]*tele.Message{sentMsg}, subs...)...)
 
 	bs.bot.Delete(statusMsg)
+	bs.verifyUpload(job, sentMsg, result)
 
-	logger.Info("Successfully processed video",
+	jobLog(job).Info("Successfully processed video",
 		"title", result.Title,
 		"size", result.FileSize,
-		"user", c.Sender().Username,
+		"user", job.Username,
 	)
 
 	return nil
 }
 
+// videoCaption is a video's caption: its title, plus the range of the source
+// it covers for clips, links with a timestamp and live recordings, and the
+// job's preset caption.
+func videoCaption(job *queue.Job, result *engine.ProcessResult) string {
+	seconds := func(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+	caption := result.Title
+	switch {
+	case job.LiveMinutes > 0:
+		caption = fmt.Sprintf("%s\n\n🔴 Live recording, up to %d min", result.Title, job.LiveMinutes)
+	case result.EndTime > 0:
+		caption = fmt.Sprintf("%s\n\n✂️ %s–%s", result.Title,
+			format.Clock(seconds(result.StartTime)), format.Clock(seconds(result.EndTime)))
+	case result.StartTime > 0:
+		caption = fmt.Sprintf("%s\n\n▶ From %s", result.Title, format.Clock(seconds(result.StartTime)))
+	}
+	return withJobCaption(job, caption)
+}
+
+// withJobCaption appends the job's preset caption, if any, to caption.
+func withJobCaption(job *queue.Job, caption string) string {
+	if job.Caption == "" {
+		return caption
+	}
+	return caption + "\n\n" + job.Caption
+}
+
 // uploadSplitVideo uploads a split video (multiple parts) with threading.
-// Uses file:// URI so the local Bot API server reads directly from disk.
-func (bs *BotService) uploadSplitVideo(c tele.Context, statusMsg *tele.Message, result *engine.ProcessResult, replyTo *tele.Message) error {
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadSplitVideo(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult, replyTo *tele.Message) error {
 	totalParts := len(result.Parts)
 	var prevMsg *tele.Message = replyTo
+	var sent []*tele.Message
+	job.NSFW = bs.classify(job, result.ThumbnailPath)
+	spoiler := bs.spoilerIn(deliveryChat(job).ID, job.NSFW)
 
 	for _, part := range result.Parts {
 		partNum := part.PartNum
-		bs.bot.Edit(statusMsg, fmt.Sprintf("Uploading Part %d/%d...\n%s | %s",
-			partNum, totalParts, result.Title, formatSize(part.FileSize)))
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading Part %d/%d...\n%s | %s",
+			partNum, totalParts, result.Title, bs.locale.Size(part.FileSize)), cancelMarkup(job.ID))
 
-		caption := fmt.Sprintf("%s\n\nPart %d/%d", result.Title, partNum, totalParts)
+		label := part.Label(totalParts)
+		caption := jobCaption(job, fmt.Sprintf("%s\n\n%s", videoCaption(job, result), label), label)
 		partFileName := fmt.Sprintf("%s_part%d.mp4", strings.TrimSuffix(result.FileName, ".mp4"), partNum)
 
 		video := &tele.Video{
-			File:      tele.FromURL("file://" + part.FilePath),
+			File:      bs.uploads.LocalFile(part.FilePath),
 			FileName:  partFileName,
 			Caption:   caption,
 			Width:     result.Width,
 			Height:    result.Height,
 			Duration:  int(result.Duration),
 			Streaming: true,
+			Thumbnail: bs.uploads.Thumbnail(part.ThumbnailPath),
 		}
 
-		opts := &tele.SendOptions{ThreadID: c.Message().ThreadID}
+		opts := &tele.SendOptions{ThreadID: deliveryThread(job), HasSpoiler: spoiler}
 		if prevMsg != nil {
 			opts.ReplyTo = prevMsg
 		}
 
-		sentMsg, err := upload.SendWithRetry(bs.bot, c.Chat(), video, opts)
+		sentMsg, err := bs.uploads.Send(deliveryChat(job), video, opts)
 		if err != nil {
-			bs.bot.Edit(statusMsg, fmt.Sprintf("Failed to upload part %d: %v", partNum, err))
+			bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload part %d: %v", partNum, err))
 			return err
 		}
 
 		prevMsg = sentMsg
+		sent = append(sent, sentMsg)
 
-		logger.Info("Uploaded video part",
+		jobLog(job).Info("Uploaded video part",
 			"part", partNum,
 			"total", totalParts,
 			"size", part.FileSize,
 		)
 	}
 
+	bs.sendDescription(job, sent[0])
+	sent = append(sent, bs.sendSubtitles(job, prevMsg, result)...)
+	bs.rememberUpload(job, result, sent...)
+	bs.fanOut(job, sent...)
 	bs.bot.Delete(statusMsg)
+	// Parts share the source's dimensions; checking the first is enough
+	bs.verifyUpload(job, sent[0], result)
 
-	logger.Info("Successfully processed split video",
+	jobLog(job).Info("Successfully processed split video",
 		"title", result.Title,
 		"totalSize", result.FileSize,
 		"parts", totalParts,
-		"user", c.Sender().Username,
+		"user", job.Username,
 	)
 
 	return nil
 }
 
 // uploadPlaylistSingleVideo uploads a single video from a playlist.
-// Uses file:// URI so the local Bot API server reads directly from disk.
-func (bs *BotService) uploadPlaylistSingleVideo(c tele.Context, statusMsg *tele.Message, result *engine.ProcessResult, videoNum, totalVideos int, replyTo *tele.Message) (*tele.Message, error) {
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadPlaylistSingleVideo(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult, videoNum, totalVideos int, replyTo *tele.Message) (*tele.Message, error) {
 	statusText := fmt.Sprintf("Video %d/%d: Uploading...\n%s | %s",
-		videoNum, totalVideos, result.Title, formatSize(result.FileSize))
-	bs.bot.Edit(statusMsg, statusText)
+		videoNum, totalVideos, result.Title, bs.locale.Size(result.FileSize))
+	bs.editStatus(job, statusMsg, statusText, cancelMarkup(job.ID))
 
-	caption := fmt.Sprintf("%s\n\nVideo %d/%d", result.Title, videoNum, totalVideos)
+	label := fmt.Sprintf("Video %d/%d", videoNum, totalVideos)
+	caption := jobCaption(job, fmt.Sprintf("%s\n\n%s", result.Title, label), label)
 	video := &tele.Video{
-		File:      tele.FromURL("file://" + result.FilePath),
+		File:      bs.uploads.LocalFile(result.FilePath),
 		FileName:  result.FileName,
 		Caption:   caption,
 		Width:     result.Width,
 		Height:    result.Height,
 		Duration:  int(result.Duration),
 		Streaming: true,
+		Thumbnail: bs.uploads.Thumbnail(result.ThumbnailPath),
 	}
 
-	opts := &tele.SendOptions{ThreadID: c.Message().ThreadID}
+	opts := &tele.SendOptions{
+		ThreadID:   deliveryThread(job),
+		HasSpoiler: bs.spoilerIn(deliveryChat(job).ID, bs.classify(job, result.ThumbnailPath)),
+	}
 	if replyTo != nil {
 		opts.ReplyTo = replyTo
 	}
 
-	sentMsg, err := upload.SendWithRetry(bs.bot, c.Chat(), video, opts)
+	sentMsg, err := bs.uploads.Send(deliveryChat(job), video, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload: %w", err)
 	}
@@ -432,32 +1068,35 @@ func (bs *BotService) uploadPlaylistSingleVideo(c tele.Context, statusMsg *tele.
 }
 
 // uploadPlaylistSplitVideo uploads a split video from a playlist (multiple parts).
-// Uses file:// URI so the local Bot API server reads directly from disk.
-func (bs *BotService) uploadPlaylistSplitVideo(c tele.Context, statusMsg *tele.Message, result *engine.ProcessResult, videoNum, totalVideos int, replyTo *tele.Message) (*tele.Message, error) {
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadPlaylistSplitVideo(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult, videoNum, totalVideos int, replyTo *tele.Message) (*tele.Message, error) {
 	totalParts := len(result.Parts)
 	var lastPartMsg *tele.Message
 	var firstPartMsg *tele.Message
+	spoiler := bs.spoilerIn(deliveryChat(job).ID, bs.classify(job, result.ThumbnailPath))
 
 	for _, part := range result.Parts {
 		partNum := part.PartNum
 		statusText := fmt.Sprintf("Video %d/%d: Uploading Part %d/%d...\n%s | %s",
-			videoNum, totalVideos, partNum, totalParts, result.Title, formatSize(part.FileSize))
-		bs.bot.Edit(statusMsg, statusText)
+			videoNum, totalVideos, partNum, totalParts, result.Title, bs.locale.Size(part.FileSize))
+		bs.editStatus(job, statusMsg, statusText, cancelMarkup(job.ID))
 
-		caption := fmt.Sprintf("%s\n\nVideo %d/%d - Part %d/%d", result.Title, videoNum, totalVideos, partNum, totalParts)
+		label := fmt.Sprintf("Video %d/%d - Part %d/%d", videoNum, totalVideos, partNum, totalParts)
+		caption := jobCaption(job, fmt.Sprintf("%s\n\n%s", result.Title, label), label)
 		partFileName := fmt.Sprintf("%s_part%d.mp4", strings.TrimSuffix(result.FileName, ".mp4"), partNum)
 
 		video := &tele.Video{
-			File:      tele.FromURL("file://" + part.FilePath),
+			File:      bs.uploads.LocalFile(part.FilePath),
 			FileName:  partFileName,
 			Caption:   caption,
 			Width:     result.Width,
 			Height:    result.Height,
 			Duration:  int(result.Duration),
 			Streaming: true,
+			Thumbnail: bs.uploads.Thumbnail(part.ThumbnailPath),
 		}
 
-		opts := &tele.SendOptions{ThreadID: c.Message().ThreadID}
+		opts := &tele.SendOptions{ThreadID: deliveryThread(job), HasSpoiler: spoiler}
 		if partNum == 1 {
 			if replyTo != nil {
 				opts.ReplyTo = replyTo
@@ -468,7 +1107,7 @@ func (bs *BotService) uploadPlaylistSplitVideo(c tele.Context, statusMsg *tele.M
 			}
 		}
 
-		sentMsg, err := upload.SendWithRetry(bs.bot, c.Chat(), video, opts)
+		sentMsg, err := bs.uploads.Send(deliveryChat(job), video, opts)
 		if err != nil {
 			return lastPartMsg, fmt.Errorf("failed to upload part %d: %v", partNum, err)
 		}
@@ -478,7 +1117,7 @@ func (bs *BotService) uploadPlaylistSplitVideo(c tele.Context, statusMsg *tele.M
 		}
 		lastPartMsg = sentMsg
 
-		logger.Info("Uploaded playlist video part",
+		jobLog(job).Info("Uploaded playlist video part",
 			"video", videoNum,
 			"part", partNum,
 			"totalParts", totalParts,
@@ -491,17 +1130,3 @@ func (bs *BotService) uploadPlaylistSplitVideo(c tele.Context, statusMsg *tele.M
 	}
 	return lastPartMsg, nil
 }
-
-// formatSize formats bytes into human readable format
-func formatSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
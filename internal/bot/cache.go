@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/filecache"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// cachedFile extracts the uploaded file of a sent message.
+func cachedFile(msg *tele.Message) (filecache.File, bool) {
+	if msg == nil {
+		return filecache.File{}, false
+	}
+	switch {
+	case msg.Video != nil:
+		return filecache.File{Kind: "video", FileID: msg.Video.FileID, Caption: msg.Caption}, true
+	case msg.Audio != nil:
+		return filecache.File{Kind: "audio", FileID: msg.Audio.FileID, Caption: msg.Caption}, true
+	case msg.Voice != nil:
+		return filecache.File{Kind: "voice", FileID: msg.Voice.FileID, Caption: msg.Caption}, true
+	case msg.VideoNote != nil:
+		return filecache.File{Kind: "videonote", FileID: msg.VideoNote.FileID}, true
+	// Animation messages also carry a Document; check Animation first
+	case msg.Animation != nil:
+		return filecache.File{Kind: "animation", FileID: msg.Animation.FileID, Caption: msg.Caption}, true
+	case msg.Document != nil:
+		return filecache.File{Kind: "document", FileID: msg.Document.FileID, Caption: msg.Caption}, true
+	}
+	return filecache.File{}, false
+}
+
+// cachedMedia builds a sendable for a cached file_id, no upload needed.
+func cachedMedia(file filecache.File) interface{} {
+	f := tele.File{FileID: file.FileID}
+	switch file.Kind {
+	case "video":
+		return &tele.Video{File: f, Caption: file.Caption, Streaming: true}
+	case "audio":
+		return &tele.Audio{File: f, Caption: file.Caption}
+	case "voice":
+		return &tele.Voice{File: f, Caption: file.Caption}
+	case "animation":
+		return &tele.Animation{File: f, Caption: file.Caption}
+	case "videonote":
+		return &tele.VideoNote{File: f}
+	}
+	return &tele.Document{File: f, Caption: file.Caption}
+}
+
+// cacheKey identifies a job's result in the file cache. Videos sent with
+// subtitles (or with them burned in) or stabilized are cached apart from the
+// same video without them, and so are downloads starting at a link timestamp
+// (the canonical URL drops it). Automatic subtitles depend on the
+// requester's language.
+func cacheKey(job *queue.Job) string {
+	mode := job.Quality
+	opts := jobOptions(job)
+	if lang := opts.SubtitleLang; lang != "" {
+		if lang == downloader.SubtitleAuto {
+			lang += "-" + strings.ToLower(opts.SubtitlePrefer)
+		}
+		if opts.BurnSubtitles {
+			mode += "+burn:" + lang
+		} else {
+			mode += "+subs:" + lang
+		}
+	}
+	if opts.Stabilize {
+		mode += "+stab"
+	}
+	if opts.Start > 0 || opts.End > 0 {
+		mode += "+section:" + strconv.FormatFloat(opts.Start, 'f', -1, 64) + "-" + strconv.FormatFloat(opts.End, 'f', -1, 64)
+	}
+	return filecache.Key(job.URL, mode)
+}
+
+// rememberUpload records the file_ids of a job's uploaded messages so the
+// same request can later be answered without downloading, and adds them to
+// the requester's /find history and daily quota usage.
+func (bs *BotService) rememberUpload(job *queue.Job, result *engine.ProcessResult, sent ...*tele.Message) {
+	bs.quota.Add(job.UserID, result.FileSize)
+	var files []filecache.File
+	for _, msg := range sent {
+		file, ok := cachedFile(msg)
+		if !ok {
+			return // don't cache a partial result
+		}
+		file.NSFW = job.NSFW
+		files = append(files, file)
+	}
+	bs.recordHistory(job, result.Title, bs.uploader(job, result), files)
+
+	// A recording is a snapshot of the stream, not the link's content
+	if job.LiveMinutes > 0 {
+		return
+	}
+	// Keep full captions in the cache, others may want them
+	if job.Captions != "" && job.Captions != captionsDescription {
+		return
+	}
+	bs.fileCache.Put(cacheKey(job), files)
+}
+
+// sendCached re-sends previously uploaded files for the job's URL. Returns
+// false if there is no cache entry or Telegram rejected the cached file_id,
+// in which case the job should be downloaded normally.
+func (bs *BotService) sendCached(job *queue.Job) bool {
+	key := cacheKey(job)
+	entry, ok := bs.fileCache.Get(key)
+	if !ok {
+		return false
+	}
+
+	var first, prevMsg *tele.Message
+	for i, file := range entry.Files {
+		file.Caption = cachedCaption(job, file.Caption, len(entry.Files))
+		opts := &tele.SendOptions{ThreadID: deliveryThread(job), ReplyTo: prevMsg, HasSpoiler: bs.spoilerIn(deliveryChat(job).ID, file.NSFW)}
+		sentMsg, err := bs.uploads.Send(deliveryChat(job), cachedMedia(file), opts)
+		if err != nil {
+			jobLog(job).Warn("Cached file_id rejected, dropping cache entry", "url", job.URL, "error", err)
+			bs.fileCache.Delete(key)
+			// Nothing sent yet: fall back to a normal download
+			return i > 0
+		}
+		if first == nil {
+			first = sentMsg
+		}
+		prevMsg = sentMsg
+	}
+	if entry.Files[0].Kind == "video" {
+		go bs.sendDescription(job, first)
+	}
+
+	bs.recordHistory(job, cachedTitle(entry.Files), "", entry.Files)
+	jobLog(job).Info("Served from file cache", "url", job.URL, "files", len(entry.Files), "user", job.Username)
+	bs.confirmDelivery(job)
+	bs.recordDashboard(job.ChatID, func(d *dashboard) { d.CacheHits++ })
+	return true
+}
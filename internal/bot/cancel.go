@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// cancelMarkup returns the inline keyboard with a Cancel button shown on a
+// job's status message while it is queued or running.
+func cancelMarkup(jobID string) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data("Cancel", "cancel", jobID)))
+	return markup
+}
+
+// cancelJob cancels a job on behalf of the sender of c. Only the submitter
+// (or an admin) may cancel. Pending jobs have their status message updated here; running
+// jobs update it themselves once their context is cancelled.
+func (bs *BotService) cancelJob(c tele.Context, jobID string) (bool, error) {
+	userID := c.Sender().ID
+	var found bool
+	for _, job := range bs.queue.Jobs() {
+		if job.ID != jobID {
+			continue
+		}
+		found = true
+		if job.UserID != userID && roleOf(c) != RoleAdmin {
+			return false, fmt.Errorf("only the requester can cancel this download")
+		}
+		running := bs.queue.IsRunning(job.ID)
+		if !bs.queue.Cancel(job.ID) {
+			return false, nil
+		}
+		if !running {
+			bs.bot.Edit(&tele.Message{ID: job.StatusMsgID, Chat: jobChat(&job)}, "Download cancelled.")
+		}
+		jobLog(&job).Info("Job cancelled", "url", job.URL, "by", userID)
+	}
+	return found, nil
+}
+
+// handleCancelButton handles the inline Cancel button on status messages.
+func (bs *BotService) handleCancelButton(c tele.Context) error {
+	ok, err := bs.cancelJob(c, c.Callback().Data)
+	switch {
+	case err != nil:
+		return c.Respond(&tele.CallbackResponse{Text: err.Error(), ShowAlert: true})
+	case !ok:
+		return c.Respond(&tele.CallbackResponse{Text: "This download has already finished"})
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "Cancelling..."})
+}
+
+// handleCancel handles /cancel [job-id]: without an argument it cancels all of
+// the caller's jobs in this chat.
+func (bs *BotService) handleCancel(c tele.Context) error {
+	jobID := strings.TrimSpace(c.Message().Payload)
+
+	var cancelled int
+	for _, job := range bs.queue.Jobs() {
+		if jobID != "" && job.ID != jobID {
+			continue
+		}
+		if jobID == "" && (job.UserID != c.Sender().ID || job.ChatID != c.Chat().ID) {
+			continue
+		}
+		ok, err := bs.cancelJob(c, job.ID)
+		if err != nil {
+			return c.Send(err.Error())
+		}
+		if ok {
+			cancelled++
+		}
+	}
+
+	if cancelled == 0 {
+		return c.Send("Nothing to cancel.")
+	}
+	return c.Send(fmt.Sprintf("Cancelled %d download(s).", cancelled))
+}
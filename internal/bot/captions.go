@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Caption settings (/captions), stored in queue.Job.Captions. The default,
+// "", is the full caption.
+const (
+	captionsParts = "parts" // only "Part 2/5" (or "Video 3/10") on split and playlist uploads
+	captionsNone  = "none"  // no caption at all
+
+	// full captions, plus the video's description and chapters in a
+	// message replying to it (see sendDescription)
+	captionsDescription = "description"
+)
+
+// newCaptionPrefs stores each user's /captions setting, persisted so it
+// survives restarts. Full captions, "", aren't stored.
+func newCaptionPrefs(path string) *settingsStore[int64, string] {
+	return newSettingsStore[int64](path, "caption preferences", func(mode string) bool { return mode == "" })
+}
+
+// handleCaptions handles /captions [full|parts|off|description]: shows or sets how much
+// caption the caller's uploads get.
+func (bs *BotService) handleCaptions(c tele.Context) error {
+	userID := c.Sender().ID
+	switch strings.ToLower(strings.TrimSpace(c.Message().Payload)) {
+	case "":
+		switch bs.captions.get(userID) {
+		case captionsParts:
+			return c.Send("Captions: only part numbers of split videos. Send /captions full or /captions off to change.")
+		case captionsNone:
+			return c.Send("Captions: off, videos come without any text. Send /captions full or /captions parts to change.")
+		case captionsDescription:
+			return c.Send("Captions: full, and videos are followed by their description and chapters. Send /captions full to drop the description.")
+		}
+		return c.Send("Captions: full (title and details). Send /captions parts to keep only \"Part 2/5\" on split videos, " +
+			"/captions off for none at all, or /captions description to also get each video's description and chapters.")
+	case "full", "on":
+		if err := bs.captions.set(userID, ""); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Your uploads will have full captions.")
+	case captionsParts:
+		if err := bs.captions.set(userID, captionsParts); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Your uploads will only be captioned with their part number when split.")
+	case "off", captionsNone:
+		if err := bs.captions.set(userID, captionsNone); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Your uploads will come without captions.")
+	case captionsDescription, "desc":
+		if err := bs.captions.set(userID, captionsDescription); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Your videos will have full captions, followed by a reply with their description and chapters.")
+	}
+	return c.Send("Usage: /captions <full|parts|off|description>")
+}
+
+// jobCaption applies the job's caption setting to an upload's caption:
+// full is the whole caption, label its part or playlist position (e.g.
+// "Part 2/5"), "" for uploads that are neither.
+func jobCaption(job *queue.Job, full, label string) string {
+	switch job.Captions {
+	case captionsNone:
+		return ""
+	case captionsParts:
+		return label
+	}
+	return full
+}
+
+// cachedCaption applies the job's caption setting to a cached file's full
+// caption, whose last paragraph is the position label of a split part.
+func cachedCaption(job *queue.Job, caption string, files int) string {
+	if job.Captions == captionsParts && files > 1 {
+		if i := strings.LastIndex(caption, "\n\n"); i >= 0 && strings.HasPrefix(caption[i+2:], "Part ") {
+			return caption[i+2:]
+		}
+	}
+	return jobCaption(job, caption, "")
+}
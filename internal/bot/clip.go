@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/format"
+	"github.com/fitz123/sushe/internal/logger"
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	clipUsage = "Usage: /clip <video URL> <start> <end>\n" +
+		"Times as seconds, 1:30 or 1m30s, e.g. /clip https://youtu.be/... 1:30 2:15"
+	gifUsage = "Usage: /gif <video URL> [<start> <end>]\n" +
+		"Videos up to a minute long, or a part of a longer one, e.g. /gif https://youtu.be/... 0:12 0:18"
+)
+
+// handleClip handles /clip <url> <start> <end>: downloads only that range of
+// the video, re-encoded around the cuts so it starts and ends exactly there.
+func (bs *BotService) handleClip(c tele.Context) error {
+	return bs.enqueueRange(c, "/clip", "", clipUsage, true)
+}
+
+// handleGIF handles /gif <url> [<start> <end>]: converts a short video, or a
+// range of one, to a silent looping animation.
+func (bs *BotService) handleGIF(c tele.Context) error {
+	return bs.enqueueRange(c, "/gif", qualityGIF, gifUsage, false)
+}
+
+// enqueueRange queues the URL of a "<url> <start> <end>" payload with quality,
+// limited to that time range. The range is optional unless required.
+func (bs *BotService) enqueueRange(c tele.Context, command, quality, usage string, required bool) error {
+	// GENERAL topic guard (Bot API bug #447)
+	if c.Message() != nil && c.Chat() != nil && c.Chat().Type != tele.ChatPrivate {
+		threadID := c.Message().ThreadID
+		if threadID == 0 || threadID == 1 {
+			return c.Send(fmt.Sprintf("⚠️ Please use %s in a named topic (not General)", command))
+		}
+	}
+
+	args := strings.Fields(c.Message().Payload)
+	if len(args) != 3 && (required || len(args) != 1) {
+		return c.Send(usage)
+	}
+	urls := downloader.ExtractURLs(args[0])
+	if len(urls) != 1 {
+		return c.Send(usage)
+	}
+	job := newJob(c, urls[0])
+	job.Quality = quality
+	if len(args) == 1 {
+		return bs.enqueueJob(c, job)
+	}
+
+	start, ok1 := downloader.ParseTimestamp(args[1])
+	end, ok2 := downloader.ParseTimestamp(args[2])
+	if !ok1 || !ok2 {
+		return c.Send(usage)
+	}
+	if end <= start {
+		return c.Send(fmt.Sprintf("The clip must end after it starts (%s → %s).",
+			format.Clock(clipDuration(start)), format.Clock(clipDuration(end))))
+	}
+	job.ClipStart, job.ClipEnd = start, end
+	logger.Info("Range requested", "command", command, "url", job.URL, "start", start, "end", end, "user", job.UserID)
+	return bs.enqueueJob(c, job)
+}
+
+// clipDuration converts a clip offset in seconds to a duration.
+func clipDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
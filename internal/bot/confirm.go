@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	// confirmProbeTimeout bounds the metadata lookup done before asking for confirmation.
+	confirmProbeTimeout = time.Minute
+	// confirmTTL is how long a confirmation prompt stays valid.
+	confirmTTL = 10 * time.Minute
+)
+
+// pendingJobs holds jobs waiting for a user decision (a quality pick or a
+// large-download confirmation) before they are queued.
+type pendingJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*queue.Job
+}
+
+func newPendingJobs() *pendingJobs {
+	return &pendingJobs{jobs: make(map[string]*queue.Job)}
+}
+
+func (p *pendingJobs) add(job *queue.Job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs[job.ID] = job
+}
+
+// peek returns the pending job without removing it.
+func (p *pendingJobs) peek(jobID string) *queue.Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.jobs[jobID]
+}
+
+// take removes and returns the pending job, or nil if it was already handled or expired.
+func (p *pendingJobs) take(jobID string) *queue.Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job := p.jobs[jobID]
+	delete(p.jobs, jobID)
+	return job
+}
+
+// needsConfirmation reports whether a job must be size-checked before it is
+// queued: only jobs from groups (negative chat IDs) when a threshold is set.
+func (bs *BotService) needsConfirmation(job *queue.Job) bool {
+	return bs.groupConfirmSize > 0 && job.ChatID < 0
+}
+
+// confirmLarge probes the job's expected size and either submits it right away
+// or, if it exceeds the group threshold, asks for confirmation first.
+func (bs *BotService) confirmLarge(job *queue.Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), confirmProbeTimeout)
+	defer cancel()
+
+	info, err := bs.probe(ctx, job.URL)
+	if err != nil {
+		// Let the download itself report the problem.
+		jobLog(job).Debug("Size probe failed, queueing without confirmation", "url", job.URL, "error", err)
+		return bs.submit(job)
+	}
+	if info.FileSize <= bs.groupConfirmSize {
+		return bs.submit(job)
+	}
+
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		markup.Data(fmt.Sprintf("Download (%s)", bs.locale.Size(info.FileSize)), "confirm", job.ID),
+		markup.Data("Cancel", "decline", job.ID),
+	))
+	text := fmt.Sprintf("%s is about %s. The requester or a chat admin must confirm this download.",
+		info.Title, bs.locale.Size(info.FileSize))
+	msg, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, ReplyMarkup: markup})
+	if err != nil {
+		return err
+	}
+
+	bs.confirmations.add(job)
+	time.AfterFunc(confirmTTL, func() {
+		if bs.confirmations.take(job.ID) != nil {
+			bs.bot.Edit(msg, "Confirmation expired, download not started.")
+		}
+	})
+	jobLog(job).Info("Large group download awaiting confirmation", "url", job.URL, "size", info.FileSize)
+	return nil
+}
+
+// canConfirm reports whether user may approve or decline job: the requester,
+// a bot admin or an administrator of the chat.
+func (bs *BotService) canConfirm(job *queue.Job, user *tele.User) bool {
+	return job.UserID == user.ID || bs.isChatAdmin(jobChat(job), user)
+}
+
+// isChatAdmin reports whether user is a bot admin or an administrator of chat.
+func (bs *BotService) isChatAdmin(chat *tele.Chat, user *tele.User) bool {
+	if bs.isAdmin(user.ID) {
+		return true
+	}
+	member, err := bs.bot.ChatMemberOf(chat, user)
+	if err != nil {
+		logger.Debug("Failed to look up chat member", "chat", chat.ID, "user", user.ID, "error", err)
+		return false
+	}
+	return member.Role == tele.Administrator || member.Role == tele.Creator
+}
+
+// handleConfirmButton handles both the confirm and decline buttons of a
+// large-download prompt.
+func (bs *BotService) handleConfirmButton(c tele.Context) error {
+	jobID := c.Callback().Data
+	confirmed := c.Callback().Unique == "confirm"
+
+	job := bs.confirmations.peek(jobID)
+	if job == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "This request has expired"})
+	}
+	if !bs.canConfirm(job, c.Sender()) {
+		return c.Respond(&tele.CallbackResponse{Text: "Only the requester or a chat admin can do this", ShowAlert: true})
+	}
+	if bs.confirmations.take(jobID) == nil {
+		return c.Respond()
+	}
+
+	if !confirmed {
+		c.Edit("Download declined.")
+		return c.Respond()
+	}
+
+	c.Delete()
+	if err := bs.submit(job); err != nil {
+		jobLog(job).Error("Failed to queue confirmed download", "error", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to queue download"})
+	}
+	jobLog(job).Info("Large group download confirmed", "by", c.Sender().ID)
+	return c.Respond(&tele.CallbackResponse{Text: "Download queued"})
+}
@@ -0,0 +1,246 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+	tele "gopkg.in/telebot.v3"
+)
+
+// dashboardDebounce is how long dashboard updates are batched before the
+// pinned message is edited, to stay well under Telegram's edit rate limits.
+const dashboardDebounce = 15 * time.Second
+
+// dashboard is a chat's pinned status message and today's counters.
+type dashboard struct {
+	MessageID int    `json:"message_id"`
+	Day       string `json:"day"` // Local date the counters belong to
+	Downloads int    `json:"downloads"`
+	Failed    int    `json:"failed"`
+	CacheHits int    `json:"cache_hits"`
+}
+
+// rollover resets the counters when the day has changed.
+func (d *dashboard) rollover(today string) {
+	if d.Day != today {
+		*d = dashboard{MessageID: d.MessageID, Day: today}
+	}
+}
+
+// dashboards tracks the chats with a dashboard, persisted so the message
+// keeps updating after a restart.
+type dashboards struct {
+	mu     sync.Mutex
+	path   string
+	chats  map[int64]*dashboard
+	timers map[int64]*time.Timer
+}
+
+func newDashboards(path string) *dashboards {
+	d := &dashboards{path: path, chats: make(map[int64]*dashboard), timers: make(map[int64]*time.Timer)}
+	if err := store.LoadJSON(path, &d.chats); err != nil {
+		logger.Warn("Failed to load dashboards", "error", err)
+	}
+	return d
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+// enable registers messageID as chatID's dashboard and returns the previous
+// dashboard message, if any.
+func (d *dashboards) enable(chatID int64, messageID int) (previous int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.chats[chatID]
+	if !ok {
+		entry = &dashboard{Day: today()}
+		d.chats[chatID] = entry
+	}
+	previous, entry.MessageID = entry.MessageID, messageID
+	d.saveLocked()
+	return previous
+}
+
+// disable removes chatID's dashboard and returns its message ID (0 if none).
+func (d *dashboards) disable(chatID int64) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.chats[chatID]
+	if !ok {
+		return 0
+	}
+	delete(d.chats, chatID)
+	if t := d.timers[chatID]; t != nil {
+		t.Stop()
+		delete(d.timers, chatID)
+	}
+	d.saveLocked()
+	return entry.MessageID
+}
+
+// count applies fn to chatID's counters. Chats without a dashboard are not
+// tracked. Returns whether the chat has a dashboard.
+func (d *dashboards) count(chatID int64, fn func(*dashboard)) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.chats[chatID]
+	if !ok {
+		return false
+	}
+	entry.rollover(today())
+	fn(entry)
+	d.saveLocked()
+	return true
+}
+
+// get returns a copy of chatID's dashboard.
+func (d *dashboards) get(chatID int64) (dashboard, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.chats[chatID]
+	if !ok {
+		return dashboard{}, false
+	}
+	entry.rollover(today())
+	return *entry, true
+}
+
+// chatIDs returns the chats with a dashboard.
+func (d *dashboards) chatIDs() []int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ids := make([]int64, 0, len(d.chats))
+	for id := range d.chats {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// schedule calls refresh for chatID after dashboardDebounce, unless a
+// refresh is already pending.
+func (d *dashboards) schedule(chatID int64, refresh func(int64)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.chats[chatID]; !ok || d.timers[chatID] != nil {
+		return
+	}
+	d.timers[chatID] = time.AfterFunc(dashboardDebounce, func() {
+		d.mu.Lock()
+		delete(d.timers, chatID)
+		d.mu.Unlock()
+		refresh(chatID)
+	})
+}
+
+// saveLocked persists the dashboards. Must hold d.mu.
+func (d *dashboards) saveLocked() {
+	if err := store.SaveJSON(d.path, d.chats); err != nil {
+		logger.Warn("Failed to save dashboards", "error", err)
+	}
+}
+
+// recordDashboard counts a finished job (or a cache hit) on its chat's
+// dashboard and schedules a refresh.
+func (bs *BotService) recordDashboard(chatID int64, fn func(*dashboard)) {
+	if bs.dashboards.count(chatID, fn) {
+		bs.dashboards.schedule(chatID, bs.refreshDashboard)
+	}
+}
+
+// touchDashboard schedules a refresh of a chat's dashboard, e.g. after the
+// queue changed.
+func (bs *BotService) touchDashboard(chatID int64) {
+	bs.dashboards.schedule(chatID, bs.refreshDashboard)
+}
+
+// renderDashboard builds the dashboard text for a chat.
+func (bs *BotService) renderDashboard(chatID int64, d dashboard) string {
+	var running, waiting, chatJobs int
+	for _, job := range bs.queue.Jobs() {
+		if bs.queue.IsRunning(job.ID) {
+			running++
+		} else {
+			waiting++
+		}
+		if job.ChatID == chatID {
+			chatJobs++
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("📊 Sushe dashboard\n\n")
+	fmt.Fprintf(&b, "Today: %d downloaded, %d failed, %d from cache\n", d.Downloads, d.Failed, d.CacheHits)
+	fmt.Fprintf(&b, "Queue: %d running, %d waiting (%d from this chat)\n", running, waiting, chatJobs)
+	fmt.Fprintf(&b, "\nUpdated %s", time.Now().Format("15:04"))
+	return b.String()
+}
+
+// refreshDashboard edits a chat's dashboard message. A deleted message
+// turns the dashboard off.
+func (bs *BotService) refreshDashboard(chatID int64) {
+	d, ok := bs.dashboards.get(chatID)
+	if !ok {
+		return
+	}
+	msg := &tele.Message{ID: d.MessageID, Chat: &tele.Chat{ID: chatID}}
+	_, err := bs.bot.Edit(msg, bs.renderDashboard(chatID, d))
+	switch {
+	case err == nil, errors.Is(err, tele.ErrSameMessageContent), errors.Is(err, tele.ErrMessageNotModified):
+	// telebot has no sentinel for a deleted message being edited
+	case errors.Is(err, tele.ErrChatNotFound), strings.Contains(err.Error(), "message to edit not found"):
+		logger.Info("Dashboard message is gone, disabling", "chat", chatID)
+		bs.dashboards.disable(chatID)
+	default:
+		logger.Debug("Failed to update dashboard", "chat", chatID, "error", err)
+	}
+}
+
+// refreshDashboards refreshes every dashboard, e.g. after a restart so the
+// counters and queue state are current again.
+func (bs *BotService) refreshDashboards() {
+	for _, chatID := range bs.dashboards.chatIDs() {
+		bs.touchDashboard(chatID)
+	}
+}
+
+// handleDashboard handles /dashboard [off]: posts and pins a status message
+// that the bot keeps updated with today's downloads, queue and cache hits.
+// In groups only chat admins can manage it.
+func (bs *BotService) handleDashboard(c tele.Context) error {
+	if c.Chat().Type != tele.ChatPrivate && !bs.isChatAdmin(c.Chat(), c.Sender()) {
+		return c.Send("Only chat admins can manage the dashboard.")
+	}
+
+	if strings.TrimSpace(c.Message().Payload) == "off" {
+		msgID := bs.dashboards.disable(c.Chat().ID)
+		if msgID == 0 {
+			return c.Send("There is no dashboard in this chat.")
+		}
+		old := &tele.Message{ID: msgID, Chat: c.Chat()}
+		bs.bot.Unpin(c.Chat(), msgID)
+		bs.bot.Edit(old, "📊 Dashboard turned off.")
+		return nil
+	}
+
+	d, _ := bs.dashboards.get(c.Chat().ID)
+	msg, err := bs.bot.Send(c.Chat(), bs.renderDashboard(c.Chat().ID, d), &tele.SendOptions{ThreadID: c.Message().ThreadID})
+	if err != nil {
+		return err
+	}
+	if previous := bs.dashboards.enable(c.Chat().ID, msg.ID); previous != 0 {
+		bs.bot.Unpin(c.Chat(), previous)
+		bs.bot.Delete(&tele.Message{ID: previous, Chat: c.Chat()})
+	}
+	if err := bs.bot.Pin(msg, tele.Silent); err != nil {
+		logger.Debug("Failed to pin dashboard", "chat", c.Chat().ID, "error", err)
+		return c.Send("Dashboard created, but I couldn't pin it. Give me the \"Pin messages\" right or pin it yourself.")
+	}
+	return nil
+}
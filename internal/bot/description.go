@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/format"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// descriptionChunkSize keeps description messages under Telegram's
+// 4096-character message limit.
+const descriptionChunkSize = 4000
+
+// sendDescription sends the video's description, with its chapters as a
+// list of timestamps, in reply to the uploaded video for /captions
+// description. A long description takes several messages.
+func (bs *BotService) sendDescription(job *queue.Job, video *tele.Message) {
+	if job.Captions != captionsDescription || video == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), confirmProbeTimeout)
+	defer cancel()
+	info, err := bs.probe(ctx, job.URL)
+	if err != nil {
+		jobLog(job).Warn("Failed to fetch description", "error", err)
+		return
+	}
+	text := descriptionText(info)
+	if text == "" {
+		return
+	}
+	for _, chunk := range format.Chunks(text, descriptionChunkSize) {
+		opts := &tele.SendOptions{ThreadID: deliveryThread(job), ReplyTo: video, DisableWebPagePreview: true}
+		if _, err := bs.bot.Send(deliveryChat(job), chunk, opts); err != nil {
+			jobLog(job).Warn("Failed to send description", "error", err)
+			return
+		}
+	}
+}
+
+// descriptionText formats a video's description: the title, the text, and
+// the chapters as "1:30 — Installing" lines. Timestamp lines are taken out
+// of the text into the chapter list; the source's own chapters win when it
+// has them. "" when there is neither text nor chapters.
+func descriptionText(info *downloader.VideoInfo) string {
+	chapters := info.ChapterList
+	written, body := downloader.DescriptionChapters(info.Description)
+	if len(chapters) == 0 {
+		chapters = written
+	}
+	body = strings.TrimSpace(body)
+	if body == "" && len(chapters) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("📝 " + info.Title)
+	if body != "" {
+		b.WriteString("\n\n" + body)
+	}
+	if len(chapters) > 0 {
+		b.WriteString("\n\n📑 Chapters:")
+		for i, c := range chapters {
+			title := c.Title
+			if title == "" {
+				title = fmt.Sprintf("Chapter %d", i+1)
+			}
+			fmt.Fprintf(&b, "\n%s — %s", format.Clock(time.Duration(c.Start*float64(time.Second))), title)
+		}
+	}
+	return b.String()
+}
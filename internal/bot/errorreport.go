@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/errsink"
+	"github.com/fitz123/sushe/internal/failcache"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// errorReportOutputMax keeps a report's output within Telegram's message
+// length limit, next to the link and error.
+const errorReportOutputMax = 3000
+
+// reportError messages the SUSHE_ERROR_REPORT_DM admin about a failed job:
+// its link, the phase it failed in, the error, and the last lines yt-dlp
+// and ffmpeg printed.
+func (bs *BotService) reportError(job *queue.Job, err error, tail *downloader.OutputTail) {
+	if bs.errorReportTo == 0 {
+		return
+	}
+	phase := bs.failedPhase(job)
+	text := fmt.Sprintf("❌ Job %s failed while %s\n%s\nRequested by %s (%d)\n\nError: %v",
+		job.ID, phase, job.URL, job.Username, job.UserID, err)
+	if output := tail.String(); output != "" {
+		text += "\n\nOutput:\n" + trimOutput(output)
+	}
+	to := &tele.User{ID: bs.errorReportTo}
+	if _, err := bs.bot.Send(to, text, &tele.SendOptions{DisableWebPagePreview: true}); err != nil {
+		jobLog(job).Warn("Failed to send error report", "admin", bs.errorReportTo, "error", err)
+	}
+}
+
+// failedPhase is the pipeline phase job was in when it failed.
+func (bs *BotService) failedPhase(job *queue.Job) string {
+	if p, ok := bs.phases.get(job.ID); ok && p.kind != "" {
+		return p.kind
+	}
+	return "starting"
+}
+
+// trimOutput keeps the last errorReportOutputMax bytes of tool output.
+func trimOutput(output string) string {
+	if len(output) > errorReportOutputMax {
+		return "…" + strings.ToValidUTF8(output[len(output)-errorReportOutputMax:], "")
+	}
+	return output
+}
+
+// extractionFailures counts each site's extraction failures in a row, so
+// the error sink hears about a site yt-dlp keeps failing on (a broken
+// extractor, a block) rather than about every flaky link.
+type extractionFailures struct {
+	mu        sync.Mutex
+	streaks   map[string]int
+	threshold int // SUSHE_SENTRY_REPEAT_FAILURES, 0 = never report
+}
+
+func newExtractionFailures(threshold int) *extractionFailures {
+	return &extractionFailures{streaks: make(map[string]int), threshold: threshold}
+}
+
+// fail counts a failure on site and reports whether the streak just
+// reached the threshold; the count then starts over.
+func (f *extractionFailures) fail(site string) bool {
+	if f.threshold <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.streaks[site]++
+	if f.streaks[site] < f.threshold {
+		return false
+	}
+	delete(f.streaks, site)
+	return true
+}
+
+// succeed ends site's streak.
+func (f *extractionFailures) succeed(site string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.streaks, site)
+}
+
+// captureExtractionFailure sends the error sink a failed job's context
+// once its site has failed SUSHE_SENTRY_REPEAT_FAILURES times in a row
+// before or while downloading. Failures that are the link's fault
+// (removed, private, ...) don't count.
+func (bs *BotService) captureExtractionFailure(job *queue.Job, err error, tail *downloader.OutputTail) {
+	if strings.HasPrefix(job.URL, simulatePrefix) {
+		return
+	}
+	phase := bs.failedPhase(job)
+	if phase != "starting" && phase != "downloading" {
+		return
+	}
+	if failcache.Classify(err.Error()) != "" {
+		return
+	}
+	site := urlDomain(job.URL)
+	if site == "" || !bs.extractionFailures.fail(site) {
+		return
+	}
+	jobLog(job).Warn("Site keeps failing, reporting to error sink", "site", site, "failures", bs.extractionFailures.threshold)
+	errsink.Capture(bs.errorSink, errsink.Event{
+		Type:    "extraction",
+		Message: fmt.Sprintf("%s failed %d times in a row: %v", site, bs.extractionFailures.threshold, err),
+		Tags:    map[string]string{"job": job.ID, "site": site, "phase": phase},
+		Extra:   map[string]string{"url": job.URL, "user": job.Username, "output": trimOutput(tail.String())},
+	})
+}
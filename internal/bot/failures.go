@@ -0,0 +1,29 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// answerFailed replies from the failure cache if the job's link recently
+// failed for a permanent reason, and reports whether it did.
+func (bs *BotService) answerFailed(job *queue.Job) bool {
+	entry, ok := bs.failures.Get(job.URL)
+	if !ok {
+		return false
+	}
+
+	text := fmt.Sprintf("%s — last checked %s ago. It can be retried in %s.",
+		entry.Class.Describe(),
+		bs.locale.Wait(time.Since(entry.Checked)),
+		bs.locale.Wait(time.Until(bs.failures.RetryAt(entry))))
+	if _, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, DisableWebPagePreview: true}); err != nil {
+		jobLog(job).Warn("Failed to send cached failure", "url", job.URL, "error", err)
+		return false
+	}
+	jobLog(job).Info("Answered from failure cache", "url", job.URL, "class", entry.Class, "user", job.Username)
+	return true
+}
@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// maxFanOut is the most chats a chat's videos are also delivered to.
+const maxFanOut = 10
+
+// newFanOutPrefs stores each chat's /fanout chats (requesting chat ID →
+// extra delivery chat IDs), persisted so they survive restarts. Chats
+// without any aren't stored.
+func newFanOutPrefs(path string) *settingsStore[int64, []int64] {
+	return newSettingsStore[int64](path, "fan-out chats", func(chats []int64) bool { return len(chats) == 0 })
+}
+
+// handleFanOut handles /fanout [<chat>...|off]: videos downloaded in this
+// chat are also posted to each listed chat (ones the caller administers,
+// where the bot may post). They are uploaded once; the other chats get the
+// same Telegram file_id. In groups only chat admins can set it.
+func (bs *BotService) handleFanOut(c tele.Context) error {
+	refs := strings.Fields(c.Message().Payload)
+	chatID := c.Chat().ID
+
+	if len(refs) == 0 {
+		chats := bs.fanOuts.get(chatID)
+		if len(chats) == 0 {
+			return c.Send("Videos downloaded here are only delivered here. " +
+				"Send /fanout @channel1 @channel2 to post them to those chats too.")
+		}
+		ids := make([]string, len(chats))
+		for i, id := range chats {
+			ids[i] = fmt.Sprint(id)
+		}
+		return c.Send(fmt.Sprintf("Videos downloaded here are also posted to chats %s. Send /fanout off to stop.", strings.Join(ids, ", ")))
+	}
+	if c.Chat().Type != tele.ChatPrivate && !bs.isChatAdmin(c.Chat(), c.Sender()) {
+		return c.Send("Only chat admins can change where videos are posted.")
+	}
+
+	if len(refs) == 1 && strings.EqualFold(refs[0], "off") {
+		if err := bs.fanOuts.set(chatID, nil); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Videos downloaded here are only delivered here again.")
+	}
+	if len(refs) > maxFanOut {
+		return c.Send(fmt.Sprintf("You can post to at most %d chats.", maxFanOut))
+	}
+
+	var chats []int64
+	var labels []string
+	for _, ref := range refs {
+		chat, err := bs.mirrorTarget(ref)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Can't find %s. Add me to it first.", ref))
+		}
+		if !bs.canMirrorTo(chat, c.Sender()) {
+			return c.Send(fmt.Sprintf("You can only post to chats you administer, and %s isn't one.", chatLabel(chat)))
+		}
+		if !bs.canPost(chat) {
+			return c.Send(fmt.Sprintf("I can't post in %s. Make me an admin allowed to post messages there, then try again.", chatLabel(chat)))
+		}
+		if chat.ID == chatID || containsID(chats, chat.ID) {
+			continue
+		}
+		chats = append(chats, chat.ID)
+		labels = append(labels, chatLabel(chat))
+	}
+	if len(chats) == 0 {
+		return c.Send("Videos downloaded here are already delivered here.")
+	}
+	if err := bs.fanOuts.set(chatID, chats); err != nil {
+		return settingNotSaved(c)
+	}
+	logger.Info("Fan-out chats set", "chat", chatID, "user", c.Sender().ID, "targets", chats)
+	return c.Send(fmt.Sprintf("Videos downloaded here will also be posted to %s, uploaded only once.", strings.Join(labels, ", ")))
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// fanOut re-sends a job's uploaded messages to the /fanout chats of the
+// chat it was requested in, by file_id: Telegram already has the files.
+// A chat that fails is logged and skipped.
+func (bs *BotService) fanOut(job *queue.Job, sent ...*tele.Message) {
+	chats := bs.fanOuts.get(job.ChatID)
+	if len(chats) == 0 {
+		return
+	}
+	delivered := deliveryChat(job).ID
+	for _, chatID := range chats {
+		if chatID == delivered {
+			continue
+		}
+		to := &tele.Chat{ID: chatID}
+		var prevMsg *tele.Message
+		for _, msg := range sent {
+			file, ok := cachedFile(msg)
+			if !ok {
+				continue
+			}
+			next, err := bs.uploads.Send(to, cachedMedia(file), &tele.SendOptions{ReplyTo: prevMsg, HasSpoiler: bs.spoilerIn(chatID, job.NSFW)})
+			if err != nil {
+				jobLog(job).Warn("Failed to fan out video", "chat", chatID, "error", err)
+				break
+			}
+			prevMsg = next
+		}
+	}
+	jobLog(job).Info("Fanned out video", "chats", len(chats))
+}
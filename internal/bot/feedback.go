@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Failure feedback reasons offered as buttons after a failed job.
+var feedbackReasons = []struct {
+	key   string
+	label string
+}{
+	{"dead", "Link is dead"},
+	{"login", "Site needs login"},
+	{"other", "Other"},
+}
+
+// feedbackStats aggregates failure feedback answers per source domain.
+type feedbackStats struct {
+	mu     sync.Mutex
+	counts map[string]map[string]int // domain -> reason -> count
+}
+
+func newFeedbackStats() *feedbackStats {
+	return &feedbackStats{counts: make(map[string]map[string]int)}
+}
+
+func (f *feedbackStats) add(domain, reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts[domain] == nil {
+		f.counts[domain] = make(map[string]int)
+	}
+	f.counts[domain][reason]++
+}
+
+// String renders per-reason totals followed by domains sorted by answer count.
+func (f *feedbackStats) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.counts) == 0 {
+		return "No failure feedback collected yet."
+	}
+
+	totals := make(map[string]int)
+	type domainCount struct {
+		domain string
+		total  int
+	}
+	var domains []domainCount
+	for domain, reasons := range f.counts {
+		dc := domainCount{domain: domain}
+		for reason, n := range reasons {
+			totals[reason] += n
+			dc.total += n
+		}
+		domains = append(domains, dc)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if domains[i].total != domains[j].total {
+			return domains[i].total > domains[j].total
+		}
+		return domains[i].domain < domains[j].domain
+	})
+
+	var b strings.Builder
+	b.WriteString("Failure feedback:\n")
+	for _, r := range feedbackReasons {
+		fmt.Fprintf(&b, "- %s: %d\n", r.label, totals[r.key])
+	}
+	b.WriteString("\nBy site:\n")
+	for _, dc := range domains {
+		reasons := f.counts[dc.domain]
+		fmt.Fprintf(&b, "- %s: %d (dead %d, login %d, other %d)\n",
+			dc.domain, dc.total, reasons["dead"], reasons["login"], reasons["other"])
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// urlDomain returns the host of rawURL without a leading "www.", or "" if unparsable.
+func urlDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// askFailureFeedback attaches feedback buttons to a failed job's status message.
+func (bs *BotService) askFailureFeedback(job *queue.Job) {
+	domain := urlDomain(job.URL)
+	if len(domain) > 40 {
+		domain = domain[:40] // callback data is limited to 64 bytes
+	}
+
+	markup := &tele.ReplyMarkup{}
+	var row tele.Row
+	for _, r := range feedbackReasons {
+		row = append(row, markup.Data(r.label, "feedback", r.key, domain))
+	}
+	markup.Inline(row)
+
+	msg := &tele.Message{ID: job.StatusMsgID, Chat: jobChat(job)}
+	if _, err := bs.bot.EditReplyMarkup(msg, markup); err != nil {
+		jobLog(job).Debug("Failed to attach feedback buttons", "error", err)
+	}
+}
+
+// handleFeedbackButton records a feedback answer and removes the buttons.
+func (bs *BotService) handleFeedbackButton(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Respond()
+	}
+	bs.feedback.add(args[1], args[0])
+	logger.Info("Failure feedback", "domain", args[1], "reason", args[0], "user_id", c.Sender().ID)
+
+	if msg := c.Message(); msg != nil {
+		bs.bot.EditReplyMarkup(msg, nil)
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "Thanks for the feedback!"})
+}
+
+// handleFeedbackReport shows aggregated failure feedback to admins.
+func (bs *BotService) handleFeedbackReport(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+	return c.Send(bs.feedback.String())
+}
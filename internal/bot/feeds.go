@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"errors"
+
+	"github.com/fitz123/sushe/internal/config"
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/feed"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+// newFeedWatcher watches the SUSHE_FEEDS feeds for new items to download
+// into SUSHE_FEED_CHAT, or returns nil if either is unset. The returned job
+// is the template for feed downloads: chat, topic and quality.
+func newFeedWatcher() (*feed.Watcher, queue.Job) {
+	urls := feed.ParseURLs(config.String("SUSHE_FEEDS", ""))
+	if len(urls) == 0 {
+		return nil, queue.Job{}
+	}
+	template := queue.Job{
+		ChatID:   int64(config.Int("SUSHE_FEED_CHAT", 0)),
+		ThreadID: config.Int("SUSHE_FEED_TOPIC", 0),
+		Username: "feed",
+		Quality:  config.String("SUSHE_FEED_QUALITY", ""),
+	}
+	if template.ChatID == 0 {
+		logger.Warn("SUSHE_FEEDS set without SUSHE_FEED_CHAT, feeds disabled")
+		return nil, queue.Job{}
+	}
+	logger.Info("Watching feeds", "feeds", len(urls), "chat", template.ChatID)
+	return feed.New(feed.Config{
+		URLs:      urls,
+		Interval:  config.Duration("SUSHE_FEED_INTERVAL", feed.DefaultInterval),
+		StatePath: store.Path("feeds.json"),
+	}), template
+}
+
+// queueFeedItem downloads a new feed item into the feed chat like a link
+// sent there.
+func (bs *BotService) queueFeedItem(feedURL string, item feed.Item) {
+	job := bs.feedJob
+	job.ID = queue.NewJobID()
+	resolved, err := bs.resolveURL(item.Link)
+	if errors.Is(err, downloader.ErrBlockedURL) {
+		logger.Warn("Blocked feed link", "feed", feedURL, "url", item.Link, "error", err)
+		return
+	}
+	if err != nil {
+		logger.Warn("Skipping feed item", "feed", feedURL, "url", item.Link, "error", err)
+		return
+	}
+	job.URL = resolved
+	if err := bs.dispatch(&job); err != nil {
+		logger.Error("Failed to queue feed item", "feed", feedURL, "url", item.Link, "error", err)
+		return
+	}
+	jobLog(&job).Info("Queued feed item", "feed", feedURL, "title", item.Title)
+}
@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/filecache"
+	"github.com/fitz123/sushe/internal/history"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// findLimit is how many matches /find lists.
+const findLimit = 10
+
+// recordHistory adds a delivered job to its requester's /find history.
+// Feed and subscription jobs, run for no one in particular, are left out.
+func (bs *BotService) recordHistory(job *queue.Job, title, uploader string, files []filecache.File) {
+	if job.UserID == 0 || title == "" {
+		return
+	}
+	bs.history.Add(job.UserID, history.Entry{
+		ID:       job.ID,
+		URL:      job.URL,
+		Title:    title,
+		Uploader: uploader,
+		Files:    files,
+	})
+}
+
+// uploader is who posted job's video: the artist tag of audio, else the
+// channel a probe of the link reported, "" if neither is known.
+func (bs *BotService) uploader(job *queue.Job, result *engine.ProcessResult) string {
+	if result.Performer != "" {
+		return result.Performer
+	}
+	if info := bs.probes.peek(job.URL); info != nil {
+		return info.Uploader
+	}
+	return ""
+}
+
+// cachedTitle is the title of a cached upload: the first line of its
+// caption, which is always a full one in the cache.
+func cachedTitle(files []filecache.File) string {
+	if len(files) == 0 {
+		return ""
+	}
+	title, _, _ := strings.Cut(files[0].Caption, "\n")
+	return title
+}
+
+// handleFind handles /find <keywords>: lists the sender's past downloads
+// whose title or uploader match, with buttons that send them again.
+func (bs *BotService) handleFind(c tele.Context) error {
+	query := strings.TrimSpace(c.Message().Payload)
+	userID := c.Sender().ID
+	if query == "" {
+		return c.Send(fmt.Sprintf("Usage: /find <words from a title or channel>\nYou have %d downloads to search.", bs.history.Len(userID)))
+	}
+	found := bs.history.Search(userID, query, findLimit)
+	if len(found) == 0 {
+		return c.Send("Nothing in your downloads matches \"" + query + "\".")
+	}
+
+	var text strings.Builder
+	markup := &tele.ReplyMarkup{}
+	var row []tele.Btn
+	var rows []tele.Row
+	for i, e := range found {
+		fmt.Fprintf(&text, "%d. %s", i+1, e.Title)
+		if e.Uploader != "" {
+			fmt.Fprintf(&text, " — %s", e.Uploader)
+		}
+		fmt.Fprintf(&text, " (%s)\n", e.Time.In(bs.location).Format("2 Jan 2006"))
+		row = append(row, markup.Data(strconv.Itoa(i+1), "resend", e.ID))
+		if len(row) == 5 {
+			rows, row = append(rows, markup.Row(row...)), nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, markup.Row(row...))
+	}
+	markup.Inline(rows...)
+	text.WriteString("\nTap a number to get it again.")
+	return c.Send(text.String(), &tele.SendOptions{DisableWebPagePreview: true, ReplyMarkup: markup})
+}
+
+// handleResendButton sends a /find match again by file_id, parts chained
+// as replies like the original upload.
+func (bs *BotService) handleResendButton(c tele.Context) error {
+	entry, ok := bs.history.Get(c.Sender().ID, c.Data())
+	if !ok {
+		return c.Respond(&tele.CallbackResponse{Text: "This download is no longer in your history"})
+	}
+	var prevMsg *tele.Message
+	for _, file := range entry.Files {
+		opts := &tele.SendOptions{ReplyTo: prevMsg, HasSpoiler: bs.spoilerIn(c.Chat().ID, file.NSFW)}
+		if c.Message() != nil {
+			opts.ThreadID = c.Message().ThreadID
+		}
+		sent, err := bs.uploads.Send(c.Chat(), cachedMedia(file), opts)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Telegram no longer has this file; send the link again", ShowAlert: true})
+		}
+		prevMsg = sent
+	}
+	return c.Respond()
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/format"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/upload"
+	tele "gopkg.in/telebot.v3"
+)
+
+var framesUsage = fmt.Sprintf("Usage: /frames <video URL> [count | times...]\n"+
+	"A count up to %d gives evenly spaced screenshots (default %d); times pick exact moments, e.g. /frames https://youtu.be/... 0:30 1:15 2m",
+	downloader.MaxFrames, downloader.DefaultFrames)
+
+// handleFrames handles /frames <url> [count | times...]: extracts evenly
+// spaced frames, or frames at the given times, and sends them as an album.
+func (bs *BotService) handleFrames(c tele.Context) error {
+	// GENERAL topic guard (Bot API bug #447)
+	if c.Message() != nil && c.Chat() != nil && c.Chat().Type != tele.ChatPrivate {
+		threadID := c.Message().ThreadID
+		if threadID == 0 || threadID == 1 {
+			return c.Send("⚠️ Please use /frames in a named topic (not General)")
+		}
+	}
+
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		return c.Send(framesUsage)
+	}
+	urls := downloader.ExtractURLs(args[0])
+	if len(urls) != 1 {
+		return c.Send(framesUsage)
+	}
+	count, times, ok := parseFrameArgs(args[1:])
+	if !ok {
+		return c.Send(framesUsage)
+	}
+
+	job := newJob(c, urls[0])
+	job.Quality = qualityFrames
+	job.Frames, job.FrameTimes = count, times
+	return bs.enqueueJob(c, job)
+}
+
+// parseFrameArgs reads the arguments after the URL: nothing (the default
+// count), a single count up to MaxFrames, or up to MaxFrames times. A lone
+// number is a count; write "0:05" for a frame at five seconds.
+func parseFrameArgs(args []string) (count int, times []float64, ok bool) {
+	if len(args) == 0 {
+		return downloader.DefaultFrames, nil, true
+	}
+	if len(args) == 1 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			return n, nil, n >= 1 && n <= downloader.MaxFrames
+		}
+	}
+	if len(args) > downloader.MaxFrames {
+		return 0, nil, false
+	}
+	for _, a := range args {
+		t, ok := downloader.ParseTimestamp(a)
+		if !ok {
+			return 0, nil, false
+		}
+		times = append(times, t)
+	}
+	return 0, times, true
+}
+
+// uploadFrames sends a job's extracted frames as one photo album, each
+// captioned with its time and the first also with the video title.
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadFrames(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult) error {
+	bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading %d frames...\n%s", len(result.FramePaths), result.Title),
+		cancelMarkup(job.ID))
+
+	album := make(tele.Album, len(result.FramePaths))
+	for i, path := range result.FramePaths {
+		caption := format.Clock(clipDuration(result.FrameTimes[i]))
+		if i == 0 {
+			caption = fmt.Sprintf("%s\n\n%s", result.Title, caption)
+		}
+		album[i] = &tele.Photo{File: bs.uploads.LocalFile(path), Caption: caption}
+	}
+
+	_, err := upload.Retry(func() (*tele.Message, error) {
+		return bs.uploads.Through(func(b *tele.Bot) (*tele.Message, error) {
+			msgs, err := b.SendAlbum(deliveryChat(job), album, &tele.SendOptions{ThreadID: deliveryThread(job)})
+			if err != nil {
+				return nil, err
+			}
+			return &msgs[0], nil
+		})
+	})
+	if err != nil {
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+		return err
+	}
+
+	bs.bot.Delete(statusMsg)
+
+	jobLog(job).Info("Successfully sent frames",
+		"title", result.Title,
+		"frames", len(result.FramePaths),
+		"user", job.Username,
+	)
+	return nil
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/filecache"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// fullVideoTimeout is how long the clip or full video choice waits before
+// downloading the clip. Stays under probeTTL so the button reuses the probe.
+const fullVideoTimeout = time.Minute
+
+// isClipLink reports whether url is a YouTube clip, a section of another
+// video. Shorts need no check: they share the watch?v= cache entry of the
+// same video (filecache.CanonicalURL).
+func isClipLink(rawURL string) bool {
+	u, err := url.Parse(filecache.CanonicalURL(rawURL))
+	return err == nil && u.Host == "youtube.com" && strings.HasPrefix(u.Path, "/clip/")
+}
+
+// needsFullVideoCheck reports whether to look up the video a job's clip
+// was cut from, for a requester who can answer the prompt.
+func needsFullVideoCheck(job *queue.Job) bool {
+	return job.UserID != 0 && plainVideoQuality(job.Quality) && job.ClipEnd == 0 && isClipLink(job.URL)
+}
+
+// fullVideoJob returns a copy of job for the full video a clip was cut
+// from, per its probe (yt-dlp reports the source's webpage URL), or nil if
+// that isn't a different, cached video.
+func (bs *BotService) fullVideoJob(ctx context.Context, job *queue.Job) *queue.Job {
+	info, err := bs.probe(ctx, job.URL)
+	if err != nil || info.WebpageURL == "" || filecache.CanonicalURL(info.WebpageURL) == filecache.CanonicalURL(job.URL) {
+		return nil
+	}
+	full := *job
+	full.URL = info.WebpageURL
+	if _, ok := bs.fileCache.Get(cacheKey(&full)); !ok {
+		return nil
+	}
+	return &full
+}
+
+// askFullVideo offers the full video instead of a clip when the full one is
+// cached and can be sent right away. Without a pick within fullVideoTimeout
+// the clip is downloaded.
+func (bs *BotService) askFullVideo(job *queue.Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), confirmProbeTimeout)
+	defer cancel()
+	if bs.fullVideoJob(ctx, job) == nil {
+		return bs.askDelivery(job)
+	}
+
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		markup.Data("✂️ Send the clip", "fullvideo", job.ID, "clip"),
+		markup.Data("🎬 Full video (instant)", "fullvideo", job.ID, "full"),
+	))
+	text := "This clip is part of a video I already have. Send the clip, or the full video right away?"
+	msg, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, ReplyMarkup: markup})
+	if err != nil {
+		return err
+	}
+
+	bs.fullVideoPicks.add(job)
+	time.AfterFunc(fullVideoTimeout, func() {
+		if bs.fullVideoPicks.take(job.ID) == nil {
+			return
+		}
+		bs.bot.Delete(msg)
+		if err := bs.askDelivery(job); err != nil {
+			jobLog(job).Error("Failed to queue clip after timeout", "error", err)
+		}
+	})
+	return nil
+}
+
+// handleFullVideoButton sends the full video from the cache, or downloads
+// the clip, as picked by the requester.
+func (bs *BotService) handleFullVideoButton(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Respond()
+	}
+	job := bs.fullVideoPicks.peek(args[0])
+	if job == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "This request has expired"})
+	}
+	if job.UserID != c.Sender().ID {
+		return c.Respond(&tele.CallbackResponse{Text: "Only the requester can choose", ShowAlert: true})
+	}
+	if bs.fullVideoPicks.take(job.ID) == nil {
+		return c.Respond()
+	}
+	c.Delete()
+
+	if args[1] == "full" {
+		ctx, cancel := context.WithTimeout(context.Background(), confirmProbeTimeout)
+		defer cancel()
+		if full := bs.fullVideoJob(ctx, job); full != nil && bs.sendCached(full) {
+			jobLog(job).Info("Sent full video instead of clip", "url", full.URL, "user", job.Username)
+			return c.Respond()
+		}
+		jobLog(job).Info("Full video no longer cached, downloading clip")
+	}
+	if err := bs.askDelivery(job); err != nil {
+		jobLog(job).Error("Failed to queue clip", "error", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to queue download"})
+	}
+	return c.Respond()
+}
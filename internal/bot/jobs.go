@@ -0,0 +1,291 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/webhook"
+	tele "gopkg.in/telebot.v3"
+)
+
+// enqueue creates a job for url from the incoming message, posts its status
+// message and submits it to the worker pool. Depending on settings the user
+// is first asked for a quality, and large group downloads need confirmation.
+//
+// quality preselects the job's Job.Quality and skips the quality prompt; pass
+// "" for the default.
+func (bs *BotService) enqueue(c tele.Context, url, quality string) error {
+	job := newJob(c, url)
+	job.Quality = quality
+	return bs.enqueueJob(c, job)
+}
+
+// enqueueJob is enqueue for a job built by the caller, e.g. with a clip range.
+func (bs *BotService) enqueueJob(c tele.Context, job *queue.Job) error {
+	url, quality := job.URL, job.Quality
+	// Subtitles set by a preset win over the /subs setting
+	if job.Subtitles == "" {
+		subs := bs.subtitles.get(job.UserID)
+		job.Subtitles, job.BurnSubtitles = subs.Lang, subs.Burn
+	}
+	job.Captions = bs.captions.get(job.UserID)
+	bs.applyTarget(job)
+
+	resolved, err := bs.resolveURL(url)
+	if errors.Is(err, downloader.ErrBlockedURL) {
+		jobLog(job).Warn("Blocked link", "url", url, "user", job.UserID, "error", err)
+		_, err := bs.bot.Send(c.Chat(), "This link points to a blocked site and won't be downloaded.",
+			&tele.SendOptions{ThreadID: job.ThreadID})
+		return err
+	}
+	if errors.Is(err, errUnresolvedLink) {
+		_, err := bs.bot.Send(c.Chat(), "Couldn't follow this short link. Send the full link instead.",
+			&tele.SendOptions{ThreadID: job.ThreadID})
+		return err
+	}
+	job.URL = resolved
+
+	if m := bs.maintenance.get(); m.On {
+		return bs.declineInMaintenance(job, m)
+	}
+
+	// Over the quota, only what's in the file cache is sent
+	if !bs.quotaAllows(job) {
+		if bs.sendCached(job) {
+			return nil
+		}
+		jobLog(job).Info("Job over daily quota", "url", job.URL, "user", job.UserID)
+		_, err := bs.bot.Send(c.Chat(), bs.quotaExceeded(job.UserID), &tele.SendOptions{ThreadID: job.ThreadID})
+		return err
+	}
+
+	if downloader.IsTorrentURL(job.URL) {
+		if err := bs.queue.Admit(job.UserID); err != nil {
+			_, err := bs.bot.Send(c.Chat(), bs.rejection(err), &tele.SendOptions{ThreadID: job.ThreadID})
+			return err
+		}
+		return bs.approveTorrent(job)
+	}
+
+	// Audio rooms (Twitter Spaces) have no video: go straight to audio
+	if quality == "" && downloader.IsAudioRoom(job.URL) {
+		quality = qualityAudio
+		job.Quality = quality
+	}
+
+	if err := bs.queue.Admit(job.UserID); err != nil {
+		jobLog(job).Info("Job rejected", "url", job.URL, "user", job.UserID, "reason", err)
+		_, err := bs.bot.Send(c.Chat(), bs.rejection(err), &tele.SendOptions{ThreadID: job.ThreadID})
+		return err
+	}
+	if bs.qualityTimeout > 0 && quality == "" {
+		return bs.askQuality(job)
+	}
+	return bs.dispatch(job)
+}
+
+// enqueueLinks queues the URLs found in a message's text, applying the
+// flags written next to them (e.g. "stab" to stabilize shaky footage).
+func (bs *BotService) enqueueLinks(c tele.Context, text string, urls []string) {
+	stabilize := hasFlag(text, "stab")
+	bs.prefetchProbes(c.Chat().ID, urls)
+	for _, url := range urls {
+		job := newJob(c, url)
+		job.Stabilize = stabilize
+		if err := bs.enqueueJob(c, job); err != nil {
+			logger.Error("Failed to queue URL", "url", url, "error", err)
+		}
+	}
+}
+
+// hasFlag reports whether text contains flag as a separate word.
+func hasFlag(text, flag string) bool {
+	for _, word := range strings.Fields(text) {
+		if strings.EqualFold(word, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueCommand handles download commands with a fixed output mode
+// (/audio, /archive, ...): it queues every URL in the payload with quality.
+func (bs *BotService) enqueueCommand(c tele.Context, command, quality string) error {
+	// GENERAL topic guard (Bot API bug #447)
+	if c.Message() != nil && c.Chat() != nil && c.Chat().Type != tele.ChatPrivate {
+		threadID := c.Message().ThreadID
+		if threadID == 0 || threadID == 1 {
+			return c.Send(fmt.Sprintf("⚠️ Please use %s in a named topic (not General)", command))
+		}
+	}
+
+	urls := downloader.ExtractURLs(c.Message().Payload)
+	if len(urls) == 0 {
+		return c.Send(fmt.Sprintf("Usage: %s <video URL>", command))
+	}
+
+	for _, url := range urls {
+		if err := bs.enqueue(c, url, quality); err != nil {
+			logger.Error("Failed to queue URL", "url", url, "error", err)
+		}
+	}
+	return nil
+}
+
+// dispatch submits a job, asking how long to record live streams and how to
+// deliver oversized videos, and holding large group downloads for
+// confirmation first. Requests already in the file cache, or links that
+// recently failed for good, are answered right away.
+func (bs *BotService) dispatch(job *queue.Job) error {
+	if bs.sendCached(job) || bs.answerFailed(job) {
+		return nil
+	}
+	if needsFullVideoCheck(job) {
+		return bs.askFullVideo(job)
+	}
+	if bs.needsLiveCheck(job) {
+		return bs.askLive(job)
+	}
+	return bs.askDelivery(job)
+}
+
+// askDelivery submits a job, asking how to deliver oversized videos first.
+func (bs *BotService) askDelivery(job *queue.Job) error {
+	if bs.needsOversizeChoice(job) {
+		return bs.askOversize(job)
+	}
+	return bs.confirmAndSubmit(job)
+}
+
+// confirmAndSubmit submits a job, holding large group downloads for confirmation.
+func (bs *BotService) confirmAndSubmit(job *queue.Job) error {
+	if bs.needsConfirmation(job) {
+		return bs.confirmLarge(job)
+	}
+	return bs.submit(job)
+}
+
+// newJob builds a job for url from the chat, topic and sender of c.
+func newJob(c tele.Context, url string) *queue.Job {
+	job := &queue.Job{
+		ID:     queue.NewJobID(),
+		URL:    url,
+		ChatID: c.Chat().ID,
+	}
+	if c.Message() != nil {
+		job.ThreadID = c.Message().ThreadID
+	}
+	if sender := c.Sender(); sender != nil {
+		job.UserID = sender.ID
+		job.Username = sender.Username
+		job.LanguageCode = sender.LanguageCode
+		if job.Username == "" {
+			job.Username = strings.TrimSpace(sender.FirstName + " " + sender.LastName)
+		}
+	}
+	return job
+}
+
+// jobLog returns a logger that tags each line with the job's ID, so a
+// download's lines can be found from request to upload.
+func jobLog(job *queue.Job) *slog.Logger {
+	return logger.With("job", job.ID)
+}
+
+// submit posts the job's status message and hands the job to the worker pool.
+func (bs *BotService) submit(job *queue.Job) error {
+	// Picked from a prompt or queued by a feed after maintenance began
+	if m := bs.maintenance.get(); m.On {
+		return bs.declineInMaintenance(job, m)
+	}
+	bs.edits.Wait(context.Background(), job.ChatID)
+	statusMsg, err := bs.bot.Send(jobChat(job), "Queued...", &tele.SendOptions{
+		ThreadID:    job.ThreadID,
+		ReplyMarkup: cancelMarkup(job.ID),
+	})
+	if err != nil {
+		return err
+	}
+	job.StatusMsgID = statusMsg.ID
+
+	position, err := bs.queue.Submit(job)
+	if err != nil {
+		bs.editStatus(job, statusMsg, bs.rejection(err))
+		return err
+	}
+	if position > 0 {
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Queued (position %d)...", position), cancelMarkup(job.ID))
+	}
+	event := jobEvent(webhook.Submitted, job)
+	event.Position = position
+	bs.hooks.Emit(event)
+	bs.touchDashboard(job.ChatID)
+	return nil
+}
+
+// rejection explains why a job was not accepted, including the current load
+// and expected wait when the queue is at capacity.
+func (bs *BotService) rejection(err error) string {
+	pending, running := bs.queue.Len()
+	load := fmt.Sprintf("%d running, %d waiting", running, pending)
+	if avg := bs.stats.avgDuration(); avg > 0 {
+		wait := queue.EstimateWait(pending+running, bs.queue.Workers(), avg)
+		load += fmt.Sprintf(", expected wait ~%s", bs.locale.Wait(wait))
+	}
+
+	switch {
+	case errors.Is(err, queue.ErrUserLimit):
+		return fmt.Sprintf("You already have too many downloads queued (%s). Wait for one to finish or /cancel one.", load)
+	case errors.Is(err, queue.ErrQueueFull):
+		return fmt.Sprintf("The bot is at capacity right now (%s). Please try again later.", load)
+	case errors.Is(err, queue.ErrStopped):
+		return "The bot is restarting, please try again in a minute."
+	}
+	return fmt.Sprintf("Failed to queue download: %v", err)
+}
+
+// statusBurst is how many status edits a chat may get back to back before
+// the per-minute rate applies.
+const statusBurst = 3
+
+// editStatus edits a job's status message once the chat's edit budget allows
+// it. Unlike progress updates, which are dropped when over budget, these
+// phase changes and errors always go through, just delayed.
+func (bs *BotService) editStatus(job *queue.Job, msg *tele.Message, what interface{}, opts ...interface{}) (*tele.Message, error) {
+	bs.edits.Wait(context.Background(), job.ChatID)
+	return bs.bot.Edit(msg, what, opts...)
+}
+
+// jobChat returns the chat a job was submitted from.
+func jobChat(job *queue.Job) *tele.Chat {
+	return &tele.Chat{ID: job.ChatID}
+}
+
+// jobStatus sets the text of the job's status message, posting a new one if
+// the job has none yet, and returns it for further edits. Extra opts (e.g. a
+// reply markup) are passed to Edit/Send.
+func (bs *BotService) jobStatus(job *queue.Job, text string, opts ...interface{}) (*tele.Message, error) {
+	bs.edits.Wait(context.Background(), job.ChatID)
+	if job.StatusMsgID != 0 {
+		msg := &tele.Message{ID: job.StatusMsgID, Chat: jobChat(job)}
+		if _, err := bs.bot.Edit(msg, text, opts...); err == nil || errors.Is(err, tele.ErrSameMessageContent) {
+			return msg, nil
+		}
+	}
+	// SendOptions go first: telebot replaces everything set before one,
+	// including a reply markup
+	opts = append([]interface{}{&tele.SendOptions{ThreadID: job.ThreadID}}, opts...)
+	msg, err := bs.bot.Send(jobChat(job), text, opts...)
+	if err != nil {
+		return nil, err
+	}
+	job.StatusMsgID = msg.ID
+	bs.queue.SetStatusMsg(job.ID, msg.ID)
+	return msg, nil
+}
@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/schedule"
+	tele "gopkg.in/telebot.v3"
+)
+
+// scheduleTick is how often the schedule is checked for due downloads.
+const scheduleTick = 30 * time.Second
+
+// handleLater handles /later <time> <url>: queues the download at a time of
+// day (e.g. 22:00, off-peak) or after a delay (e.g. 2h). /later lists the
+// caller's scheduled downloads and /later cancel <id> drops one.
+func (bs *BotService) handleLater(c tele.Context) error {
+	// GENERAL topic guard (Bot API bug #447)
+	if c.Chat().Type != tele.ChatPrivate && c.Message() != nil {
+		if threadID := c.Message().ThreadID; threadID == 0 || threadID == 1 {
+			return c.Send("⚠️ Please use /later in a named topic (not General)")
+		}
+	}
+
+	args := strings.Fields(c.Message().Payload)
+	switch {
+	case len(args) == 0:
+		return c.Send(bs.renderSchedule(c.Sender().ID))
+	case len(args) == 2 && strings.EqualFold(args[0], "cancel"):
+		if !bs.schedule.Cancel(c.Sender().ID, args[1]) {
+			return c.Send("No scheduled download with that ID. Send /later to list yours.")
+		}
+		return c.Send("Scheduled download cancelled.")
+	}
+
+	usage := "Usage: /later <HH:MM|delay> <url>, e.g. /later 22:00 <url> or /later 2h <url>"
+	at, err := schedule.ParseAt(args[0], time.Now().In(bs.location))
+	if err != nil {
+		return c.Send(fmt.Sprintf("%v\n%s", err, usage))
+	}
+	urls := downloader.ExtractURLs(strings.Join(args[1:], " "))
+	if len(urls) == 0 {
+		return c.Send(usage)
+	}
+
+	for _, url := range urls {
+		job := newJob(c, url)
+		job.Scheduled = true
+		subs := bs.subtitles.get(job.UserID)
+		job.Subtitles, job.BurnSubtitles = subs.Lang, subs.Burn
+		job.Captions = bs.captions.get(job.UserID)
+		bs.applyTarget(job)
+
+		resolved, err := bs.resolveURL(url)
+		if errors.Is(err, downloader.ErrBlockedURL) {
+			logger.Warn("Blocked link", "url", url, "user", job.UserID, "error", err)
+			return c.Send("This link points to a blocked site and won't be downloaded.")
+		}
+		if errors.Is(err, errUnresolvedLink) {
+			return c.Send("Couldn't follow this short link. Send the full link instead.")
+		}
+		job.URL = resolved
+
+		if err := bs.schedule.Add(job, at); err != nil {
+			if errors.Is(err, schedule.ErrUserLimit) {
+				return c.Send(fmt.Sprintf("You already have %d downloads scheduled. Cancel one with /later cancel <id>.", schedule.MaxPerUser))
+			}
+			return err
+		}
+		jobLog(job).Info("Download scheduled", "url", job.URL, "at", at, "user", job.Username)
+	}
+
+	return c.Send(fmt.Sprintf("⏰ Scheduled for %s (in %s). I'll let you know when it's done. /later lists your scheduled downloads.",
+		at.Format("Mon 15:04"), bs.locale.Duration(time.Until(at).Round(time.Minute))))
+}
+
+// loadLocation returns the time zone named name (e.g. "Europe/Berlin") for
+// /later times of day, the server's local time if name is empty or unknown.
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn("Ignoring SUSHE_TIMEZONE", "error", err)
+		return time.Local
+	}
+	return loc
+}
+
+// renderSchedule lists userID's scheduled downloads.
+func (bs *BotService) renderSchedule(userID int64) string {
+	entries := bs.schedule.List(userID)
+	if len(entries) == 0 {
+		return "You have no scheduled downloads. Send /later <HH:MM|delay> <url> to schedule one."
+	}
+	var b strings.Builder
+	b.WriteString("⏰ Scheduled downloads:\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n%s — %s\n%s\n", e.At.In(bs.location).Format("Mon 15:04"), e.Job.ID, e.Job.URL)
+	}
+	b.WriteString("\nSend /later cancel <id> to drop one.")
+	return b.String()
+}
+
+// runSchedule queues scheduled downloads when their time comes, until the
+// bot stops. Downloads due while the bot was down or in maintenance start
+// right away.
+func (bs *BotService) runSchedule() {
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+	for {
+		if !bs.maintenance.get().On {
+			for _, e := range bs.schedule.Due(time.Now()) {
+				bs.startScheduled(e.Job)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-bs.stop:
+			return
+		}
+	}
+}
+
+// startScheduled queues a scheduled download like a fresh request, without
+// the quality prompt: nobody may be around to answer it.
+func (bs *BotService) startScheduled(job *queue.Job) {
+	if !bs.stillAuthorized(job.UserID, job.ChatID) {
+		jobLog(job).Info("Dropping scheduled download of unauthorized user", "url", job.URL, "user", job.UserID)
+		return
+	}
+	jobLog(job).Info("Starting scheduled download", "url", job.URL, "user", job.Username)
+	if !bs.quotaAllows(job) {
+		jobLog(job).Info("Scheduled job over daily quota", "url", job.URL, "user", job.UserID)
+		bs.bot.Send(jobChat(job), "⏰ Your scheduled download couldn't start. "+bs.quotaExceeded(job.UserID), &tele.SendOptions{ThreadID: job.ThreadID})
+		return
+	}
+	if err := bs.queue.Admit(job.UserID); err != nil {
+		jobLog(job).Info("Scheduled job rejected", "url", job.URL, "user", job.UserID, "reason", err)
+		bs.bot.Send(jobChat(job), "⏰ Your scheduled download couldn't start: "+bs.rejection(err), &tele.SendOptions{ThreadID: job.ThreadID})
+		return
+	}
+	if err := bs.dispatch(job); err != nil {
+		jobLog(job).Error("Failed to queue scheduled download", "error", err)
+	}
+}
+
+// notifyScheduled tells the requester that their scheduled download finished.
+func (bs *BotService) notifyScheduled(job *queue.Job, err error) {
+	text := "⏰ Your scheduled download is done: " + job.URL
+	if err != nil {
+		text = fmt.Sprintf("⏰ Your scheduled download failed: %s\n%v", job.URL, err)
+	}
+	if _, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, DisableWebPagePreview: true}); err != nil {
+		jobLog(job).Warn("Failed to send scheduled download notice", "error", err)
+	}
+}
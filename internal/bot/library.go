@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"path/filepath"
+
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/library"
+	"github.com/fitz123/sushe/internal/queue"
+)
+
+// addToLibrary files a delivered video (or its parts, or an /archive MKV)
+// into SUSHE_LIBRARY_DIR, episodes of a series under show and season
+// folders. Audio, animations, stills and albums stay out. Failures are
+// logged; the video has already been delivered.
+func (bs *BotService) addToLibrary(job *queue.Job, result *engine.ProcessResult) {
+	if result.IsAudio || result.IsVoice || result.IsAnimation || result.IsVideoNote ||
+		len(result.FramePaths) > 0 || len(result.Media) > 0 {
+		return
+	}
+
+	files := map[string]int{result.FilePath: 0}
+	if result.IsSplit {
+		files = make(map[string]int, len(result.Parts))
+		for _, part := range result.Parts {
+			files[part.FilePath] = part.PartNum
+		}
+	}
+	for src, part := range files {
+		dest := library.Path(bs.libraryDir, result.Title, filepath.Ext(src), part)
+		if err := library.Store(src, dest); err != nil {
+			jobLog(job).Warn("Failed to add video to library", "file", dest, "error", err)
+			continue
+		}
+		jobLog(job).Info("Added video to library", "file", dest)
+	}
+}
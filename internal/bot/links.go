@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/config"
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// loadBlockedHosts reads the host blocklist from SUSHE_BLOCKED_HOSTS
+// (comma-separated) and SUSHE_BLOCKLIST_FILE (one host per line, hosts-file
+// format accepted).
+func loadBlockedHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(config.String("SUSHE_BLOCKED_HOSTS", ""), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if path := config.String("SUSHE_BLOCKLIST_FILE", ""); path != "" {
+		fileHosts, err := downloader.LoadHostList(path)
+		if err != nil {
+			logger.Warn("Failed to load blocklist", "path", path, "error", err)
+		}
+		hosts = append(hosts, fileHosts...)
+	}
+	if len(hosts) > 0 {
+		logger.Info("Loaded host blocklist", "count", len(hosts))
+	}
+	return hosts
+}
+
+// errUnresolvedLink is returned for short links that couldn't be resolved.
+// They are refused rather than handed to yt-dlp, which would follow the
+// redirects without the blocklist and private address checks.
+var errUnresolvedLink = errors.New("short link couldn't be resolved")
+
+// resolveURL expands links on known shorteners (bit.ly, t.co, ...) to their
+// final URL and applies the host blocklist. Other links are returned as they
+// are.
+func (bs *BotService) resolveURL(url string) (string, error) {
+	if bs.unshortener.IsBlocked(url) {
+		return url, downloader.ErrBlockedURL
+	}
+	if !downloader.IsShortLink(url) {
+		return url, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	resolved, err := bs.unshortener.Resolve(ctx, url)
+	if errors.Is(err, downloader.ErrBlockedURL) {
+		return url, err
+	}
+	if err != nil {
+		logger.Warn("Failed to resolve short link", "url", url, "error", err)
+		return url, fmt.Errorf("%w: %v", errUnresolvedLink, err)
+	}
+	logger.Info("Resolved short link", "url", url, "resolved", resolved)
+	return resolved, nil
+}
@@ -0,0 +1,117 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// liveChoices are the recording lengths offered for live streams, in
+// minutes; those over the configured maximum are left out.
+var liveChoices = []int{5, 15, 30, 60, 120}
+
+// needsLiveCheck reports whether to probe a job for an ongoing live stream
+// and ask how long to record it. Like the oversize choice, only plain video
+// downloads qualify.
+func (bs *BotService) needsLiveCheck(job *queue.Job) bool {
+	return bs.liveMaxMinutes > 0 && job.LiveMinutes == 0 && plainVideoQuality(job.Quality) && job.ClipEnd == 0
+}
+
+// liveMinutesOptions returns the recording lengths offered up to max
+// minutes, max itself always among them.
+func liveMinutesOptions(max int) []int {
+	var options []int
+	for _, m := range liveChoices {
+		if m < max {
+			options = append(options, m)
+		}
+	}
+	return append(options, max)
+}
+
+// askLive probes the job and, if it is an ongoing live stream, asks how many
+// minutes to record from now. Without a pick within livePromptTimeout (or
+// without the prompt) the stream is recorded for liveMaxMinutes. Other
+// links continue to the oversize choice.
+func (bs *BotService) askLive(job *queue.Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), confirmProbeTimeout)
+	defer cancel()
+
+	info, err := bs.probe(ctx, job.URL)
+	if err != nil || !info.IsLive {
+		if err != nil {
+			jobLog(job).Debug("Live probe failed, downloading as a video", "url", job.URL, "error", err)
+		}
+		return bs.askDelivery(job)
+	}
+
+	jobLog(job).Info("Live stream detected", "url", job.URL, "user", job.Username)
+	if bs.livePromptTimeout <= 0 {
+		job.LiveMinutes = bs.liveMaxMinutes
+		return bs.confirmAndSubmit(job)
+	}
+
+	markup := &tele.ReplyMarkup{}
+	var buttons []tele.Btn
+	for _, m := range liveMinutesOptions(bs.liveMaxMinutes) {
+		buttons = append(buttons, markup.Data(fmt.Sprintf("%d min", m), "live", job.ID, strconv.Itoa(m)))
+	}
+	markup.Inline(markup.Split(3, buttons)...)
+
+	text := fmt.Sprintf("🔴 %s is live. How long should I record it, starting now? (%d min in %s)",
+		info.Title, bs.liveMaxMinutes, bs.locale.Duration(bs.livePromptTimeout))
+	msg, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, ReplyMarkup: markup})
+	if err != nil {
+		return err
+	}
+
+	bs.livePicks.add(job)
+	time.AfterFunc(bs.livePromptTimeout, func() {
+		if bs.livePicks.take(job.ID) == nil {
+			return
+		}
+		bs.bot.Delete(msg)
+		job.LiveMinutes = bs.liveMaxMinutes
+		if err := bs.confirmAndSubmit(job); err != nil {
+			jobLog(job).Error("Failed to queue recording after live timeout", "error", err)
+		}
+	})
+	return nil
+}
+
+// handleLiveButton queues a live recording of the length picked by its
+// requester.
+func (bs *BotService) handleLiveButton(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Respond()
+	}
+	jobID := args[0]
+	minutes, err := strconv.Atoi(args[1])
+	if err != nil || minutes <= 0 || minutes > bs.liveMaxMinutes {
+		return c.Respond()
+	}
+
+	job := bs.livePicks.peek(jobID)
+	if job == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "This request has expired"})
+	}
+	if job.UserID != c.Sender().ID {
+		return c.Respond(&tele.CallbackResponse{Text: "Only the requester can choose", ShowAlert: true})
+	}
+	if bs.livePicks.take(jobID) == nil {
+		return c.Respond()
+	}
+
+	job.LiveMinutes = minutes
+	c.Delete()
+	if err := bs.confirmAndSubmit(job); err != nil {
+		jobLog(job).Error("Failed to queue recording", "error", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to queue recording"})
+	}
+	return c.Respond()
+}
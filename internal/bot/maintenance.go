@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/schedule"
+	"github.com/fitz123/sushe/internal/store"
+	tele "gopkg.in/telebot.v3"
+)
+
+// maintenance is the /maintenance state: while On, the bot answers /status
+// and sends videos it already has, but declines new downloads.
+type maintenance struct {
+	On     bool      `json:"on"`
+	Until  time.Time `json:"until,omitempty"` // expected end, zero if unknown
+	Reason string    `json:"reason,omitempty"`
+}
+
+// maintenanceSwitch holds the maintenance state, persisted so a restart
+// during planned maintenance keeps declining downloads.
+type maintenanceSwitch struct {
+	mu    sync.Mutex
+	path  string
+	state maintenance
+}
+
+// newMaintenanceSwitch loads the saved state; on forces maintenance mode
+// at startup (SUSHE_MAINTENANCE).
+func newMaintenanceSwitch(path string, on bool) *maintenanceSwitch {
+	m := &maintenanceSwitch{path: path}
+	if err := store.LoadJSON(path, &m.state); err != nil {
+		logger.Warn("Failed to load maintenance state", "error", err)
+	}
+	if on {
+		m.state.On = true
+	}
+	if m.state.On {
+		logger.Info("Starting in maintenance mode", "until", m.state.Until, "reason", m.state.Reason)
+	}
+	return m
+}
+
+func (m *maintenanceSwitch) get() maintenance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+func (m *maintenanceSwitch) set(state maintenance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := store.Update(m.path, &m.state, func() error {
+		m.state = state
+		return nil
+	})
+	if err != nil {
+		logger.Warn("Failed to save maintenance state", "error", err)
+		return errSettingNotSaved
+	}
+	return nil
+}
+
+// Maintenance reports whether the bot is in maintenance mode and when it
+// is expected to end (zero if unknown), for the HTTP API.
+func (bs *BotService) Maintenance() (bool, time.Time) {
+	m := bs.maintenance.get()
+	return m.On, m.Until
+}
+
+// maintenanceText explains that new downloads are declined, with the
+// expected end and reason if the admin gave them.
+func (bs *BotService) maintenanceText(m maintenance) string {
+	text := "🛠 The bot is under maintenance and isn't taking new downloads right now."
+	switch {
+	case m.Until.IsZero():
+	case time.Until(m.Until) > 0:
+		text += fmt.Sprintf(" Expected back around %s (in %s).",
+			m.Until.In(bs.location).Format("Mon 15:04"), bs.locale.Duration(time.Until(m.Until).Round(time.Minute)))
+	default:
+		text += " It should be back any minute."
+	}
+	if m.Reason != "" {
+		text += "\nReason: " + m.Reason
+	}
+	return text + "\nVideos I've sent before are still delivered right away."
+}
+
+// declineInMaintenance answers a job during maintenance: from the file
+// cache if possible, otherwise with the maintenance notice.
+func (bs *BotService) declineInMaintenance(job *queue.Job, m maintenance) error {
+	if bs.sendCached(job) || bs.answerFailed(job) {
+		return nil
+	}
+	jobLog(job).Info("Download declined for maintenance", "url", job.URL, "user", job.UserID)
+	_, err := bs.bot.Send(jobChat(job), bs.maintenanceText(m), &tele.SendOptions{ThreadID: job.ThreadID})
+	return err
+}
+
+// handleMaintenance handles /maintenance [on [<HH:MM|delay>] [reason]|off]
+// for bot admins. /maintenance alone shows the current state.
+func (bs *BotService) handleMaintenance(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		m := bs.maintenance.get()
+		if !m.On {
+			return c.Send("Maintenance mode is off. Usage: /maintenance on [<HH:MM|delay>] [reason] or /maintenance off")
+		}
+		return c.Send("Maintenance mode is on. Users see:\n\n" + bs.maintenanceText(m))
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "off":
+		if err := bs.maintenance.set(maintenance{}); err != nil {
+			return settingNotSaved(c)
+		}
+		logger.Info("Maintenance mode off", "user", c.Sender().ID)
+		return c.Send("✅ Maintenance mode is off, downloads are accepted again.")
+	case "on":
+	default:
+		return c.Send("Usage: /maintenance on [<HH:MM|delay>] [reason] or /maintenance off")
+	}
+
+	m := maintenance{On: true}
+	args = args[1:]
+	if len(args) > 0 {
+		if until, err := schedule.ParseAt(args[0], time.Now().In(bs.location)); err == nil {
+			m.Until = until
+			args = args[1:]
+		}
+	}
+	m.Reason = strings.Join(args, " ")
+	if err := bs.maintenance.set(m); err != nil {
+		return settingNotSaved(c)
+	}
+	logger.Info("Maintenance mode on", "user", c.Sender().ID, "until", m.Until, "reason", m.Reason)
+	return c.Send("🛠 Maintenance mode is on. Running downloads finish; new ones are declined with:\n\n" + bs.maintenanceText(m))
+}
+
+// handleStatus handles /status: whether the bot takes downloads and how
+// busy it is.
+func (bs *BotService) handleStatus(c tele.Context) error {
+	var b strings.Builder
+	if m := bs.maintenance.get(); m.On {
+		b.WriteString(bs.maintenanceText(m))
+	} else {
+		b.WriteString("✅ The bot is up and taking downloads.")
+	}
+
+	pending, running := bs.queue.Len()
+	fmt.Fprintf(&b, "\n\nQueue: %d running, %d waiting", running, pending)
+	if avg := bs.stats.avgDuration(); avg > 0 && pending > 0 {
+		wait := queue.EstimateWait(pending+running, bs.queue.Workers(), avg)
+		fmt.Fprintf(&b, ", expected wait ~%s", bs.locale.Wait(wait))
+	}
+	return c.Send(b.String())
+}
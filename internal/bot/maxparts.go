@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// maxPartsLimit is the highest /maxparts value; more parts than this is
+// as good as no cap.
+const maxPartsLimit = 20
+
+// newChatPartLimits stores each chat's /maxparts cap, persisted so it
+// survives restarts. 0, no cap, isn't stored.
+func newChatPartLimits(path string) *settingsStore[int64, int] {
+	return newSettingsStore[int64](path, "part limits", func(limit int) bool { return limit == 0 })
+}
+
+// handleMaxParts handles /maxparts [n|off]: shows or sets the most parts an
+// oversized video may be split into in this chat. Videos that would need
+// more are compressed to fit instead. In groups only chat admins can set it.
+func (bs *BotService) handleMaxParts(c tele.Context) error {
+	arg := strings.TrimSpace(c.Message().Payload)
+	chatID := c.Chat().ID
+
+	if arg == "" {
+		if limit := bs.partLimits.get(chatID); limit > 0 {
+			return c.Send(fmt.Sprintf("Oversized videos are split into at most %d parts here; longer ones are compressed to fit. "+
+				"Send /maxparts off to remove the cap.", limit))
+		}
+		return c.Send("Oversized videos are split into as many parts as they need. " +
+			"Send /maxparts <n>, e.g. /maxparts 3, to compress videos that would need more.")
+	}
+	if c.Chat().Type != tele.ChatPrivate && !bs.isChatAdmin(c.Chat(), c.Sender()) {
+		return c.Send("Only chat admins can change the part limit.")
+	}
+
+	if strings.EqualFold(arg, "off") {
+		if err := bs.partLimits.set(chatID, 0); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Part limit removed.")
+	}
+	limit, err := strconv.Atoi(arg)
+	if err != nil || limit < 1 || limit > maxPartsLimit {
+		return c.Send(fmt.Sprintf("Usage: /maxparts <1-%d> or /maxparts off", maxPartsLimit))
+	}
+	if err := bs.partLimits.set(chatID, limit); err != nil {
+		return settingNotSaved(c)
+	}
+	if limit == 1 {
+		return c.Send("Oversized videos will be compressed into one file; those too long for that fail instead of being split.")
+	}
+	return c.Send(fmt.Sprintf("Oversized videos will be split into at most %d parts; "+
+		"longer ones are compressed to fit, and those too long for that fail.", limit))
+}
+
+// partLimitText explains a failed download of a video over the chat's
+// part cap.
+func partLimitText(maxParts int) string {
+	return fmt.Sprintf("This video would need more than %d parts (the /maxparts limit here), "+
+		"and it's too long to compress that far. Pick a lower quality, or ask an admin to raise the limit.",
+		maxParts)
+}
+
+// partLimitNote describes the chat's part cap for the oversize prompt, ""
+// if the video fits within it.
+func (bs *BotService) partLimitNote(fileSize int64, maxParts int) string {
+	if !bs.engine.UploadLimits().TooManyParts(fileSize, maxParts) {
+		return ""
+	}
+	return fmt.Sprintf("\nThis chat allows at most %d parts, so splitting compresses it first.", maxParts)
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// needsOversizeChoice reports whether to probe a job's size and, if it is
+// over the upload limit, ask how to deliver it. Only plain video downloads
+// qualify: audio, voice and archives have their own splitting, and
+// animations are short by definition.
+func (bs *BotService) needsOversizeChoice(job *queue.Job) bool {
+	return bs.oversizeTimeout > 0 && job.Oversize == "" && job.LiveMinutes == 0 && plainVideoQuality(job.Quality)
+}
+
+// plainVideoQuality reports whether a Job.Quality downloads the video as a
+// video: the default or a height cap.
+func plainVideoQuality(quality string) bool {
+	switch quality {
+	case qualityAudio, qualityVoice, qualityArchive, qualityGIF, qualityFrames, qualityNote:
+		return false
+	}
+	return true
+}
+
+// askOversize probes the job's expected size and, for videos over the upload
+// limit, offers splitting into parts (or on chapter boundaries, if the
+// source has chapters), compressing into one file or sending the parts as
+// files. Without a pick within oversizeTimeout the job proceeds
+// with the automatic choice (compress if close, split otherwise).
+func (bs *BotService) askOversize(job *queue.Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), confirmProbeTimeout)
+	defer cancel()
+
+	info, err := bs.probe(ctx, job.URL)
+	if err != nil || !bs.engine.UploadLimits().NeedsSplit(info.FileSize) {
+		if err != nil {
+			jobLog(job).Debug("Size probe failed, skipping oversize prompt", "url", job.URL, "error", err)
+		}
+		return bs.confirmAndSubmit(job)
+	}
+
+	// Over the chat's part cap, the engine compresses to fit before splitting
+	maxParts := bs.partLimits.get(job.ChatID)
+	numParts := bs.engine.UploadLimits().CalculateNumParts(info.FileSize)
+	if bs.engine.UploadLimits().TooManyParts(info.FileSize, maxParts) {
+		numParts = maxParts
+	}
+	markup := &tele.ReplyMarkup{}
+	rows := []tele.Row{markup.Row(markup.Data(fmt.Sprintf("Split into %d parts", numParts), "oversize", job.ID, downloader.OversizeSplit))}
+	if info.Chapters > 1 {
+		rows = append(rows, markup.Row(markup.Data(fmt.Sprintf("Split by chapters (%d)", info.Chapters), "oversize", job.ID, downloader.OversizeChapters)))
+	}
+	// Only offer compression when the result would still be watchable
+	if _, err := downloader.CompressionBitrate(bs.engine.UploadLimits().Compress, info.Duration); err == nil {
+		rows = append(rows, markup.Row(markup.Data("Compress to fit", "oversize", job.ID, downloader.OversizeCompress)))
+	}
+	rows = append(rows, markup.Row(markup.Data("Send as document parts", "oversize", job.ID, downloader.OversizeDocument)))
+	markup.Inline(rows...)
+
+	text := fmt.Sprintf("%s is about %s, over the %s upload limit. How should it be sent? (automatic in %s)%s",
+		info.Title, bs.locale.Size(info.FileSize), bs.locale.Size(bs.engine.UploadLimits().Upload), bs.locale.Duration(bs.oversizeTimeout),
+		bs.uploadLimitNote()+bs.partLimitNote(info.FileSize, maxParts))
+	msg, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, ReplyMarkup: markup})
+	if err != nil {
+		return err
+	}
+
+	bs.oversizePicks.add(job)
+	time.AfterFunc(bs.oversizeTimeout, func() {
+		if bs.oversizePicks.take(job.ID) == nil {
+			return
+		}
+		bs.bot.Delete(msg)
+		if err := bs.confirmAndSubmit(job); err != nil {
+			jobLog(job).Error("Failed to queue download after oversize timeout", "error", err)
+		}
+	})
+	return nil
+}
+
+// handleOversizeButton queues a job with the delivery picked by its requester.
+func (bs *BotService) handleOversizeButton(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Respond()
+	}
+	jobID, choice := args[0], args[1]
+
+	job := bs.oversizePicks.peek(jobID)
+	if job == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "This request has expired"})
+	}
+	if job.UserID != c.Sender().ID {
+		return c.Respond(&tele.CallbackResponse{Text: "Only the requester can choose", ShowAlert: true})
+	}
+	if bs.oversizePicks.take(jobID) == nil {
+		return c.Respond()
+	}
+
+	job.Oversize = choice
+	c.Delete()
+	if err := bs.confirmAndSubmit(job); err != nil {
+		jobLog(job).Error("Failed to queue download", "error", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to queue download"})
+	}
+	return c.Respond()
+}
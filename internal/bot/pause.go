@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// pauseLimit is how long a download stays paused before it resumes by
+// itself: the job's time limit keeps running meanwhile.
+const pauseLimit = 5 * time.Minute
+
+// handlePauseButton pauses or resumes a running download from the /queue
+// view, for its requester or an admin, then refreshes the view.
+func (bs *BotService) handlePauseButton(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Respond()
+	}
+	job, ok := bs.runningJob(args[0])
+	phase, _ := bs.phases.get(args[0])
+	if !ok || !phase.pauser.Active() {
+		return c.Respond(&tele.CallbackResponse{Text: "This download is no longer running"})
+	}
+	if job.UserID != c.Sender().ID && roleOf(c) != RoleAdmin {
+		return c.Respond(&tele.CallbackResponse{Text: "Only the requester can pause this download", ShowAlert: true})
+	}
+
+	status := &tele.Message{ID: job.StatusMsgID, Chat: jobChat(&job)}
+	if args[1] == "resume" {
+		if err := phase.pauser.Resume(); err != nil {
+			jobLog(&job).Error("Failed to resume download", "error", err)
+			return c.Respond(&tele.CallbackResponse{Text: "Failed to resume the download"})
+		}
+		jobLog(&job).Info("Download resumed", "by", c.Sender().ID)
+		bs.phases.set(job.ID, "Downloading")
+		bs.editStatus(&job, status, "Resuming download...", cancelMarkup(job.ID))
+	} else {
+		if err := phase.pauser.Pause(); err != nil {
+			if !errors.Is(err, downloader.ErrNothingToPause) {
+				jobLog(&job).Error("Failed to pause download", "error", err)
+			}
+			return c.Respond(&tele.CallbackResponse{Text: "Failed to pause the download"})
+		}
+		jobLog(&job).Info("Download paused", "by", c.Sender().ID)
+		bs.editStatus(&job, status, "⏸ Download paused. Resume it from /queue; it resumes by itself in "+bs.locale.Duration(pauseLimit)+".", cancelMarkup(job.ID))
+		bs.resumeLater(job, phase.pauser)
+	}
+
+	text, markup := bs.renderQueue(c.Sender().ID)
+	c.Edit(text, &tele.SendOptions{DisableWebPagePreview: true, ReplyMarkup: markup})
+	return c.Respond()
+}
+
+// resumeLater resumes a paused download once it has been paused for
+// pauseLimit, unless it was resumed (or resumed and paused again) since.
+func (bs *BotService) resumeLater(job queue.Job, pauser *downloader.Pauser) {
+	_, since := pauser.Paused()
+	time.AfterFunc(pauseLimit, func() {
+		paused, at := pauser.Paused()
+		if !paused || !at.Equal(since) {
+			return
+		}
+		if err := pauser.Resume(); err != nil {
+			jobLog(&job).Error("Failed to resume download", "error", err)
+			return
+		}
+		jobLog(&job).Info("Paused download resumed after limit")
+		bs.phases.set(job.ID, "Downloading")
+		bs.editStatus(&job, &tele.Message{ID: job.StatusMsgID, Chat: jobChat(&job)}, "Resuming download...", cancelMarkup(job.ID))
+	})
+}
+
+// runningJob returns the running job with the given ID.
+func (bs *BotService) runningJob(jobID string) (queue.Job, bool) {
+	for _, job := range bs.queue.Jobs() {
+		if job.ID == jobID && bs.queue.IsRunning(job.ID) {
+			return job, true
+		}
+	}
+	return queue.Job{}, false
+}
@@ -0,0 +1,300 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/store"
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	maxPresetsPerUser = 20
+	maxPresetCaption  = 200
+)
+
+var presetNameRe = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+const presetUsage = "Usage:\n" +
+	"/preset save <name>: <options> — e.g. /preset save hd: 1080 chapters subs=en\n" +
+	"/preset use <name> <url> — download with a preset\n" +
+	"/preset delete <name>\n" +
+	"/preset — list your presets\n\n" +
+	"Options:\n" +
+	"- 480, 720, 1080, audio, voice, archive, gif — format\n" +
+	"- split, nosplit, chapters, doc — delivery of videos over the upload limit\n" +
+	"- subs=<lang>, burn — subtitles as .srt, or burned in\n" +
+	"- stab — stabilize shaky footage\n" +
+	"- caption=<text> — added to the caption (the rest of the line)"
+
+// preset is a named set of job settings a user saved with /preset.
+type preset struct {
+	Quality       string `json:"quality,omitempty"`
+	Oversize      string `json:"oversize,omitempty"`
+	Subtitles     string `json:"subtitles,omitempty"`
+	BurnSubtitles bool   `json:"burn_subtitles,omitempty"`
+	Stabilize     bool   `json:"stabilize,omitempty"`
+	Caption       string `json:"caption,omitempty"`
+}
+
+// apply copies the preset's settings onto job.
+func (p preset) apply(job *queue.Job) {
+	job.Quality = p.Quality
+	job.Oversize = p.Oversize
+	job.Subtitles, job.BurnSubtitles = p.Subtitles, p.BurnSubtitles
+	job.Stabilize = p.Stabilize
+	job.Caption = p.Caption
+}
+
+// String lists the preset's options in the form /preset save accepts.
+func (p preset) String() string {
+	var opts []string
+	if p.Quality != "" {
+		opts = append(opts, p.Quality)
+	}
+	switch p.Oversize {
+	case downloader.OversizeCompress:
+		opts = append(opts, "nosplit")
+	case downloader.OversizeDocument:
+		opts = append(opts, "doc")
+	case "":
+	default:
+		opts = append(opts, p.Oversize)
+	}
+	if p.Subtitles != "" {
+		opts = append(opts, "subs="+p.Subtitles)
+		if p.BurnSubtitles {
+			opts = append(opts, "burn")
+		}
+	}
+	if p.Stabilize {
+		opts = append(opts, "stab")
+	}
+	if p.Caption != "" {
+		opts = append(opts, "caption="+p.Caption)
+	}
+	if len(opts) == 0 {
+		return "(defaults)"
+	}
+	return strings.Join(opts, " ")
+}
+
+// parsePreset parses /preset save options, e.g. "720 nosplit subs=en".
+func parsePreset(s string) (preset, error) {
+	var p preset
+	if i := strings.Index(s, "caption="); i >= 0 {
+		p.Caption = strings.TrimSpace(s[i+len("caption="):])
+		if len([]rune(p.Caption)) > maxPresetCaption {
+			return p, fmt.Errorf("caption is longer than %d characters", maxPresetCaption)
+		}
+		s = s[:i]
+	}
+
+	for _, opt := range strings.Fields(strings.ToLower(s)) {
+		switch opt {
+		case "480", "720", "1080", qualityAudio, qualityVoice, qualityArchive, qualityGIF:
+			p.Quality = opt
+		case "split":
+			p.Oversize = downloader.OversizeSplit
+		case "nosplit", "compress":
+			p.Oversize = downloader.OversizeCompress
+		case "chapters":
+			p.Oversize = downloader.OversizeChapters
+		case "doc", "document":
+			p.Oversize = downloader.OversizeDocument
+		case "burn":
+			p.BurnSubtitles = true
+		case "stab":
+			p.Stabilize = true
+		default:
+			lang, ok := strings.CutPrefix(opt, "subs=")
+			if !ok || (lang != downloader.SubtitleAuto && !downloader.ValidSubtitleLang(lang)) {
+				return p, fmt.Errorf("unknown option %q", opt)
+			}
+			p.Subtitles = lang
+		}
+	}
+	if p.BurnSubtitles && p.Subtitles == "" {
+		return p, fmt.Errorf("burn needs subs=<lang>")
+	}
+	return p, nil
+}
+
+// presetStore holds each user's named presets, persisted so they survive
+// restarts.
+type presetStore struct {
+	mu      sync.Mutex
+	path    string
+	presets map[int64]map[string]preset
+}
+
+func newPresetStore(path string) *presetStore {
+	s := &presetStore{path: path, presets: make(map[int64]map[string]preset)}
+	if err := store.LoadJSON(path, &s.presets); err != nil {
+		logger.Warn("Failed to load presets", "error", err)
+	}
+	return s
+}
+
+// get returns userID's preset called name.
+func (s *presetStore) get(userID int64, name string) (preset, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.presets[userID][name]
+	return p, ok
+}
+
+// list returns userID's presets by name.
+func (s *presetStore) list(userID int64) map[string]preset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]preset, len(s.presets[userID]))
+	for name, p := range s.presets[userID] {
+		out[name] = p
+	}
+	return out
+}
+
+// save stores p as userID's preset called name, replacing any with that name.
+func (s *presetStore) save(userID int64, name string, p preset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func() error {
+		user := s.presets[userID]
+		if user == nil {
+			user = make(map[string]preset)
+			s.presets[userID] = user
+		}
+		if _, exists := user[name]; !exists && len(user) >= maxPresetsPerUser {
+			return fmt.Errorf("you already have %d presets; delete one first", maxPresetsPerUser)
+		}
+		user[name] = p
+		return nil
+	})
+}
+
+// remove deletes userID's preset called name and reports whether it existed.
+func (s *presetStore) remove(userID int64, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed bool
+	err := s.update(func() error {
+		if _, removed = s.presets[userID][name]; !removed {
+			return nil
+		}
+		delete(s.presets[userID], name)
+		if len(s.presets[userID]) == 0 {
+			delete(s.presets, userID)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// update applies fn to the presets as saved on disk and saves the result.
+// Callers hold s.mu.
+func (s *presetStore) update(fn func() error) error {
+	var fnErr error
+	err := store.Update(s.path, &s.presets, func() error {
+		if s.presets == nil {
+			s.presets = make(map[int64]map[string]preset)
+		}
+		fnErr = fn()
+		return fnErr
+	})
+	if err != nil && err != fnErr {
+		logger.Warn("Failed to save presets", "error", err)
+		return errSettingNotSaved
+	}
+	return err
+}
+
+// handlePreset handles /preset [save|use|delete ...]: named combinations of
+// format, delivery, subtitle and caption settings, stored per user.
+func (bs *BotService) handlePreset(c tele.Context) error {
+	userID := c.Sender().ID
+	sub, rest, _ := strings.Cut(strings.TrimSpace(c.Message().Payload), " ")
+	rest = strings.TrimSpace(rest)
+
+	switch strings.ToLower(sub) {
+	case "":
+		presets := bs.presets.list(userID)
+		if len(presets) == 0 {
+			return c.Send("You have no presets yet.\n\n" + presetUsage)
+		}
+		names := make([]string, 0, len(presets))
+		for name := range presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		b.WriteString("Your presets:\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "- %s: %s\n", name, presets[name])
+		}
+		b.WriteString("\nUse one with /preset use <name> <url>")
+		return c.Send(b.String())
+
+	case "save":
+		name, opts, _ := strings.Cut(rest, " ")
+		name = strings.ToLower(strings.TrimSuffix(name, ":"))
+		if !presetNameRe.MatchString(name) {
+			return c.Send("Preset names are up to 32 letters, digits, - or _.\n\n" + presetUsage)
+		}
+		p, err := parsePreset(strings.TrimPrefix(strings.TrimSpace(opts), ":"))
+		if err != nil {
+			return c.Send(fmt.Sprintf("Invalid preset: %v\n\n%s", err, presetUsage))
+		}
+		if err := bs.presets.save(userID, name, p); errors.Is(err, errSettingNotSaved) {
+			return settingNotSaved(c)
+		} else if err != nil {
+			return c.Send(err.Error())
+		}
+		return c.Send(fmt.Sprintf("Preset %s saved: %s", name, p))
+
+	case "use":
+		// GENERAL topic guard (Bot API bug #447)
+		if c.Chat() != nil && c.Chat().Type != tele.ChatPrivate {
+			if threadID := c.Message().ThreadID; threadID == 0 || threadID == 1 {
+				return c.Send("⚠️ Please use /preset use in a named topic (not General)")
+			}
+		}
+		name, urlText, _ := strings.Cut(rest, " ")
+		name = strings.ToLower(name)
+		p, ok := bs.presets.get(userID, name)
+		if !ok {
+			return c.Send(fmt.Sprintf("You have no preset called %q. Send /preset to list yours.", name))
+		}
+		urls := downloader.ExtractURLs(urlText)
+		if len(urls) == 0 {
+			return c.Send("Usage: /preset use <name> <url>")
+		}
+		for _, url := range urls {
+			job := newJob(c, url)
+			p.apply(job)
+			if err := bs.enqueueJob(c, job); err != nil {
+				logger.Error("Failed to queue URL", "url", url, "error", err)
+			}
+		}
+		return nil
+
+	case "delete", "rm":
+		name := strings.ToLower(rest)
+		removed, err := bs.presets.remove(userID, name)
+		if err != nil {
+			return settingNotSaved(c)
+		}
+		if !removed {
+			return c.Send(fmt.Sprintf("You have no preset called %q.", name))
+		}
+		return c.Send(fmt.Sprintf("Preset %s deleted.", name))
+	}
+	return c.Send(presetUsage)
+}
@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+)
+
+const (
+	// probeTTL is how long a probe result is reused by later prompts for
+	// the same URL (quality, live, oversize and group confirmation).
+	probeTTL = 2 * time.Minute
+
+	// maxParallelProbes bounds the yt-dlp probes started for one message.
+	maxParallelProbes = 4
+)
+
+// probeResult is a finished or in-flight metadata probe.
+type probeResult struct {
+	done    chan struct{}
+	info    *downloader.VideoInfo
+	err     error
+	expires time.Time
+}
+
+// probeCache shares yt-dlp metadata probes between the prompts shown for a
+// URL and lets a multi-link message probe all its links at once.
+type probeCache struct {
+	mu      sync.Mutex
+	results map[string]*probeResult
+}
+
+func newProbeCache() *probeCache {
+	return &probeCache{results: make(map[string]*probeResult)}
+}
+
+// probe returns url's metadata, reusing a recent or in-flight probe. The
+// probe itself runs with confirmProbeTimeout; ctx only bounds the wait.
+func (bs *BotService) probe(ctx context.Context, url string) (*downloader.VideoInfo, error) {
+	r := bs.probes.start(url, func() (*downloader.VideoInfo, error) {
+		probeCtx, cancel := context.WithTimeout(context.Background(), confirmProbeTimeout)
+		defer cancel()
+		return bs.engine.Probe(probeCtx, url)
+	})
+	select {
+	case <-r.done:
+		return r.info, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// start returns the probe for url, running fn in the background unless a
+// fresh or in-flight one exists.
+func (p *probeCache) start(url string, fn func() (*downloader.VideoInfo, error)) *probeResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for key, r := range p.results {
+		// In-flight probes have no expiry yet
+		if !r.expires.IsZero() && r.expires.Before(now) {
+			delete(p.results, key)
+		}
+	}
+	if r, ok := p.results[url]; ok {
+		return r
+	}
+
+	r := &probeResult{done: make(chan struct{})}
+	p.results[url] = r
+	go func() {
+		info, err := fn()
+		p.mu.Lock()
+		r.info, r.err = info, err
+		r.expires = time.Now().Add(probeTTL)
+		p.mu.Unlock()
+		close(r.done)
+	}()
+	return r
+}
+
+// peek returns url's probed metadata if a probe for it finished, even an
+// expired one not swept yet, without starting one.
+func (p *probeCache) peek(url string) *downloader.VideoInfo {
+	p.mu.Lock()
+	r, ok := p.results[url]
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return r.info
+	default:
+		return nil
+	}
+}
+
+// promptsProbe reports whether links queued in chatID may be probed first: a
+// quality, oversize or group size prompt is enabled there, or live streams
+// are detected.
+func (bs *BotService) promptsProbe(chatID int64) bool {
+	return bs.qualityTimeout > 0 || bs.oversizeTimeout > 0 || bs.liveMaxMinutes > 0 ||
+		bs.needsConfirmation(&queue.Job{ChatID: chatID})
+}
+
+// prefetchProbes starts probing every link of a multi-link message at once,
+// so the prompts enqueue shows for them one by one come from finished probes
+// instead of each waiting for its own. Downloads are still queued in order.
+func (bs *BotService) prefetchProbes(chatID int64, urls []string) {
+	if len(urls) < 2 || !bs.promptsProbe(chatID) {
+		return
+	}
+	logger.Debug("Probing links in parallel", "count", len(urls), "chat", chatID)
+
+	sem := make(chan struct{}, maxParallelProbes)
+	for _, url := range urls {
+		if downloader.IsTorrentURL(url) {
+			continue
+		}
+		go func(url string) {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			resolved, err := bs.resolveURL(url)
+			if err != nil {
+				return
+			}
+			bs.probe(context.Background(), resolved)
+		}(url)
+	}
+}
@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"github.com/fitz123/sushe/internal/progress"
+	"github.com/fitz123/sushe/internal/queue"
+)
+
+// Progress returns the bus the bot publishes job progress to, for the web
+// dashboard's event stream.
+func (bs *BotService) Progress() *progress.Bus {
+	return bs.progress
+}
+
+// publishProgress publishes the progress shown in a job's status message.
+func (bs *BotService) publishProgress(job *queue.Job, phase string, percent float64, status string) {
+	bs.progress.Publish(progress.Event{
+		JobID:   job.ID,
+		URL:     job.URL,
+		ChatID:  job.ChatID,
+		User:    job.Username,
+		Phase:   phase,
+		Percent: percent,
+		Status:  status,
+	})
+}
+
+// publishResult publishes how a finished job ended. Like webhooks, jobs
+// interrupted by shutdown publish nothing.
+func (bs *BotService) publishResult(job *queue.Job, err error, cancelled, shutdown bool) {
+	e := progress.Event{JobID: job.ID, URL: job.URL, ChatID: job.ChatID, User: job.Username}
+	switch {
+	case err == nil:
+		e.Result = "completed"
+	case cancelled:
+		e.Result = "cancelled"
+	case !shutdown:
+		e.Result = "failed"
+		e.Error = err.Error()
+	default:
+		return
+	}
+	bs.progress.Publish(e)
+}
@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Job.Quality values for output modes other than a height cap.
+const (
+	qualityAudio   = "audio"
+	qualityArchive = "archive"
+	qualityVoice   = "voice"
+	qualityGIF     = "gif"
+	qualityFrames  = "frames"
+	qualityNote    = "note"
+)
+
+// qualityHeights are the resolutions offered in the quality keyboard.
+var qualityHeights = []int{480, 720, 1080}
+
+// jobOptions converts a job's quality pick into downloader options. A start
+// timestamp in the link (?t=, #t=) skips to that point, except for archives,
+// which keep the whole source, and frames, whose offsets are absolute. A /clip
+// range takes precedence.
+func jobOptions(job *queue.Job) downloader.Options {
+	var opts downloader.Options
+	switch job.Quality {
+	case qualityAudio:
+		opts = downloader.Options{AudioOnly: true}
+	case qualityArchive:
+		return downloader.Options{Archive: true}
+	case qualityFrames:
+		return downloader.Options{Frames: job.Frames, FrameTimes: job.FrameTimes}
+	case qualityVoice:
+		opts = downloader.Options{Voice: true}
+	case qualityNote:
+		opts = downloader.Options{VideoNote: true, MaxHeight: 720}
+	case qualityGIF:
+		// Animations are scaled down anyway; don't fetch more than 720p
+		opts = downloader.Options{Animate: true, MaxHeight: 720}
+	default:
+		height, _ := strconv.Atoi(job.Quality)
+		opts = downloader.Options{
+			MaxHeight:      height,
+			Oversize:       job.Oversize,
+			SubtitleLang:   job.Subtitles,
+			SubtitlePrefer: job.LanguageCode,
+			BurnSubtitles:  job.BurnSubtitles,
+			Stabilize:      job.Stabilize,
+			Record:         time.Duration(job.LiveMinutes) * time.Minute,
+		}
+	}
+	if job.ClipEnd > 0 {
+		opts.Start, opts.End, opts.ExactCuts = job.ClipStart, job.ClipEnd, true
+	} else {
+		opts.Start = downloader.StartTime(job.URL)
+	}
+	if opts.VideoNote && opts.End == 0 {
+		// Only the first minute ends up in the note; don't fetch the rest
+		opts.End = opts.Start + downloader.VideoNoteMaxDuration
+	}
+	return opts
+}
+
+// askQuality probes the job's URL and offers the resolutions the source has,
+// plus audio only and an archive copy. Without a pick within qualityTimeout
+// the job proceeds with the default quality. Sources with no video formats
+// (or that fail to probe) skip the prompt.
+func (bs *BotService) askQuality(job *queue.Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), confirmProbeTimeout)
+	defer cancel()
+
+	info, err := bs.probe(ctx, job.URL)
+	if err != nil || len(info.Heights) == 0 {
+		if err != nil {
+			jobLog(job).Debug("Quality probe failed, using default quality", "url", job.URL, "error", err)
+		}
+		return bs.dispatch(job)
+	}
+
+	markup := &tele.ReplyMarkup{}
+	var buttons []tele.Btn
+	maxHeight := info.Heights[len(info.Heights)-1]
+	for _, h := range qualityHeights {
+		// Offer a resolution if the source has it or something larger
+		if h <= maxHeight || h == qualityHeights[0] {
+			buttons = append(buttons, markup.Data(fmt.Sprintf("%dp", h), "quality", job.ID, strconv.Itoa(h)))
+		}
+	}
+	markup.Inline(
+		markup.Row(buttons...),
+		markup.Row(
+			markup.Data("Audio only", "quality", job.ID, qualityAudio),
+			markup.Data("Archive (MKV, all tracks)", "quality", job.ID, qualityArchive),
+		),
+	)
+
+	text := fmt.Sprintf("%s\nChoose quality (best up to 1080p in %s if you don't pick):",
+		info.Title, bs.locale.Duration(bs.qualityTimeout))
+	msg, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, ReplyMarkup: markup})
+	if err != nil {
+		return err
+	}
+
+	bs.qualityPicks.add(job)
+	time.AfterFunc(bs.qualityTimeout, func() {
+		if bs.qualityPicks.take(job.ID) == nil {
+			return
+		}
+		bs.bot.Delete(msg)
+		if err := bs.dispatch(job); err != nil {
+			jobLog(job).Error("Failed to queue download after quality timeout", "error", err)
+		}
+	})
+	return nil
+}
+
+// handleQualityButton queues a job at the quality picked by its requester.
+func (bs *BotService) handleQualityButton(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Respond()
+	}
+	jobID, quality := args[0], args[1]
+
+	job := bs.qualityPicks.peek(jobID)
+	if job == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "This request has expired"})
+	}
+	if job.UserID != c.Sender().ID {
+		return c.Respond(&tele.CallbackResponse{Text: "Only the requester can choose the quality", ShowAlert: true})
+	}
+	if bs.qualityPicks.take(jobID) == nil {
+		return c.Respond()
+	}
+
+	job.Quality = quality
+	c.Delete()
+	if err := bs.dispatch(job); err != nil {
+		jobLog(job).Error("Failed to queue download", "error", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to queue download"})
+	}
+	return c.Respond()
+}
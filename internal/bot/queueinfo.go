@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// jobPhase is the last reported state of a running job.
+type jobPhase struct {
+	text    string
+	kind    string // Pipeline phase (downloading, encoding, ...) last sent to the webhook
+	started time.Time
+	pauser  *downloader.Pauser // pauses the job's download from /queue
+}
+
+// jobPhases tracks what each running job is doing, for /queue.
+type jobPhases struct {
+	mu     sync.Mutex
+	phases map[string]jobPhase
+}
+
+func newJobPhases() *jobPhases {
+	return &jobPhases{phases: make(map[string]jobPhase)}
+}
+
+// set records the current phase of a job; the start time is kept from the first call.
+func (p *jobPhases) set(jobID, text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase, ok := p.phases[jobID]
+	if !ok {
+		phase.started = time.Now()
+	}
+	phase.text = text
+	p.phases[jobID] = phase
+}
+
+// changeKind records the job's pipeline phase and reports whether it differs
+// from the previous one.
+func (p *jobPhases) changeKind(jobID, kind string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase, ok := p.phases[jobID]
+	if !ok {
+		phase.started = time.Now()
+	}
+	if phase.kind == kind {
+		return false
+	}
+	phase.kind = kind
+	p.phases[jobID] = phase
+	return true
+}
+
+// setPauser records what pauses the job's download.
+func (p *jobPhases) setPauser(jobID string, pauser *downloader.Pauser) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase, ok := p.phases[jobID]
+	if !ok {
+		phase.started = time.Now()
+	}
+	phase.pauser = pauser
+	p.phases[jobID] = phase
+}
+
+func (p *jobPhases) get(jobID string) (jobPhase, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase, ok := p.phases[jobID]
+	return phase, ok
+}
+
+func (p *jobPhases) clear(jobID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.phases, jobID)
+}
+
+// handleQueue shows the caller's running and queued jobs with their current
+// phase and an estimated wait based on the average duration of recent jobs.
+// Running downloads get Pause/Resume buttons.
+func (bs *BotService) handleQueue(c tele.Context) error {
+	text, markup := bs.renderQueue(c.Sender().ID)
+	return c.Send(text, &tele.SendOptions{DisableWebPagePreview: true, ReplyMarkup: markup})
+}
+
+// renderQueue returns the /queue view for userID, with a Pause or Resume
+// button per download of theirs that is running.
+func (bs *BotService) renderQueue(userID int64) (string, *tele.ReplyMarkup) {
+	jobs := bs.queue.Jobs()
+	avg := bs.stats.avgDuration()
+
+	var running, waiting int
+	var lines []string
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for ahead, job := range jobs {
+		isRunning := bs.queue.IsRunning(job.ID)
+		if isRunning {
+			running++
+		} else {
+			waiting++
+		}
+		if job.UserID != userID {
+			continue
+		}
+
+		if isRunning {
+			line := fmt.Sprintf("• %s — running", job.URL)
+			if phase, ok := bs.phases.get(job.ID); ok {
+				line = fmt.Sprintf("• %s — %s", job.URL, phase.text)
+				if paused, _ := phase.pauser.Paused(); paused {
+					line = fmt.Sprintf("• %s — ⏸ paused", job.URL)
+					rows = append(rows, markup.Row(markup.Data("▶ Resume "+job.ID, "pause", job.ID, "resume")))
+				} else {
+					if avg > 0 {
+						if left := avg - time.Since(phase.started); left > 0 {
+							line += fmt.Sprintf(", ~%s left", bs.locale.Wait(left))
+						}
+					}
+					if phase.pauser.Active() {
+						rows = append(rows, markup.Row(markup.Data("⏸ Pause "+job.ID, "pause", job.ID, "pause")))
+					}
+				}
+			}
+			lines = append(lines, line+fmt.Sprintf(" (id %s)", job.ID))
+			continue
+		}
+
+		line := fmt.Sprintf("• %s — #%d in queue", job.URL, waiting)
+		if avg > 0 {
+			line += fmt.Sprintf(", starts in ~%s", bs.locale.Wait(queue.EstimateWait(ahead, bs.queue.Workers(), avg)))
+		}
+		lines = append(lines, line+fmt.Sprintf(" (id %s)", job.ID))
+	}
+
+	header := fmt.Sprintf("Queue: %d running, %d waiting (%d workers)", running, waiting, bs.queue.Workers())
+	if len(lines) == 0 {
+		return header + "\n\nYou have no downloads in the queue.", nil
+	}
+	if len(rows) == 0 {
+		markup = nil
+	} else {
+		markup.Inline(rows...)
+	}
+	return header + "\n\nYour downloads:\n" + strings.Join(lines, "\n"), markup
+}
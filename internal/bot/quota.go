@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/config"
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/quota"
+	tele "gopkg.in/telebot.v3"
+)
+
+// dailyLimits reads the daily quota settings. SUSHE_DAILY_GB may be
+// fractional ("0.5").
+func dailyLimits() quota.Limits {
+	return quota.Limits{
+		Downloads: config.Int("SUSHE_DAILY_DOWNLOADS", 0),
+		Bytes:     int64(config.Float("SUSHE_DAILY_GB", 0) * (1 << 30)),
+	}
+}
+
+// quotaAllows reports whether the job's requester may start another
+// download today (SUSHE_DAILY_DOWNLOADS, SUSHE_DAILY_GB). Bot admins have
+// no quota.
+func (bs *BotService) quotaAllows(job *queue.Job) bool {
+	if !bs.quota.Enabled() {
+		return true
+	}
+	if bs.isAdmin(job.UserID) {
+		return true
+	}
+	return bs.quota.Allowed(job.UserID, bs.otherJobs(job))
+}
+
+// playlistBudget returns what the job's requester has left of today's
+// quota for the videos of a playlist, their other queued jobs set aside.
+// ok is false when nothing is left. Bot admins have no quota.
+func (bs *BotService) playlistBudget(job *queue.Job) (budget engine.PlaylistBudget, ok bool) {
+	if !bs.quota.Enabled() {
+		return budget, true
+	}
+	if bs.isAdmin(job.UserID) {
+		return budget, true
+	}
+	left, ok := bs.quota.Remaining(job.UserID, bs.otherJobs(job))
+	return engine.PlaylistBudget{Videos: left.Downloads, Bytes: left.Bytes}, ok
+}
+
+// otherJobs counts the job's requester's queued and running jobs besides
+// job itself.
+func (bs *BotService) otherJobs(job *queue.Job) int {
+	n := 0
+	for _, j := range bs.queue.Jobs() {
+		if j.UserID == job.UserID && j.ID != job.ID {
+			n++
+		}
+	}
+	return n
+}
+
+// quotaHelp is the /help line on /quota, "" without a quota.
+func quotaHelp(enabled bool) string {
+	if !enabled {
+		return ""
+	}
+	return "- /quota — how much of your daily download quota you've used\n"
+}
+
+// quotaExceeded is the answer to a request over the daily quota.
+func (bs *BotService) quotaExceeded(userID int64) string {
+	reset := bs.quota.ResetAt()
+	return fmt.Sprintf("📊 You've used up your daily quota (%s). It resets at %s (in %s).",
+		bs.quotaUsage(userID), reset.Format("15:04"), bs.locale.Wait(time.Until(reset)))
+}
+
+// quotaUsage describes userID's usage against the limits, e.g. "10 of 10
+// downloads, 1.2 GB of 2.0 GB".
+func (bs *BotService) quotaUsage(userID int64) string {
+	usage, limits := bs.quota.Get(userID), bs.quota.Limits()
+	var parts []string
+	if limits.Downloads > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d downloads", usage.Downloads, limits.Downloads))
+	}
+	if limits.Bytes > 0 {
+		parts = append(parts, fmt.Sprintf("%s of %s", bs.locale.Size(usage.Bytes), bs.locale.Size(limits.Bytes)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// handleQuota handles /quota: shows the caller's usage today. Bot admins
+// can also see another user's with /quota <user id>, clear it with
+// /quota <user id> reset, and exempt them with /quota <user id> unlimited
+// (undone with /quota <user id> limited).
+func (bs *BotService) handleQuota(c tele.Context) error {
+	if !bs.quota.Enabled() {
+		return c.Send("There is no daily download quota on this bot.")
+	}
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		return c.Send(bs.quotaStatus(c.Sender().ID))
+	}
+	if roleOf(c) != RoleAdmin {
+		return c.Send("Usage: /quota")
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || len(args) > 2 {
+		return c.Send("Usage: /quota <user id> [reset|unlimited|limited]")
+	}
+	if len(args) == 1 {
+		return c.Send(bs.quotaStatus(userID))
+	}
+	switch strings.ToLower(args[1]) {
+	case "reset":
+		bs.quota.Reset(userID)
+		logger.Info("Quota reset", "user", userID, "by", c.Sender().ID)
+		return c.Send(fmt.Sprintf("User %d's usage today is cleared.", userID))
+	case "unlimited":
+		bs.quota.SetUnlimited(userID, true)
+		logger.Info("Quota lifted", "user", userID, "by", c.Sender().ID)
+		return c.Send(fmt.Sprintf("User %d has no daily quota now. /quota %d limited undoes this.", userID, userID))
+	case "limited":
+		bs.quota.SetUnlimited(userID, false)
+		logger.Info("Quota restored", "user", userID, "by", c.Sender().ID)
+		return c.Send(fmt.Sprintf("User %d has the daily quota again.", userID))
+	}
+	return c.Send("Usage: /quota <user id> [reset|unlimited|limited]")
+}
+
+// quotaStatus describes userID's quota for /quota.
+func (bs *BotService) quotaStatus(userID int64) string {
+	if bs.isAdmin(userID) || bs.quota.Unlimited(userID) {
+		return fmt.Sprintf("📊 No daily quota applies (used today: %s).", bs.quotaUsage(userID))
+	}
+	reset := bs.quota.ResetAt()
+	return fmt.Sprintf("📊 Used today: %s. Resets at %s (in %s).",
+		bs.quotaUsage(userID), reset.Format("15:04"), bs.locale.Wait(time.Until(reset)))
+}
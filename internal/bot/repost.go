@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/filecache"
+	"github.com/fitz123/sushe/internal/logger"
+	tele "gopkg.in/telebot.v3"
+)
+
+const mirrorUsage = "Usage:\n" +
+	"- reply to a file I sent with /mirror <chat> [new caption]\n" +
+	"- /mirror <chat> <url> [new caption] for a link I already delivered\n" +
+	"<chat> is a chat ID or @username."
+
+// handleMirrorTo handles /mirror: re-posts an already delivered video (the
+// replied-to message, or the file cache entry of a URL) to another chat by
+// its Telegram file_id — no download, no upload. An optional caption
+// replaces the original on the first file.
+func (bs *BotService) handleMirrorTo(c tele.Context) error {
+	payload := strings.TrimSpace(c.Message().Payload)
+	fields := strings.Fields(payload)
+	if len(fields) == 0 {
+		return c.Send(mirrorUsage)
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(payload, fields[0]))
+
+	target, err := bs.mirrorTarget(fields[0])
+	if err != nil {
+		return c.Send(fmt.Sprintf("Can't find chat %s: make sure I'm a member there.", fields[0]))
+	}
+	if !bs.canMirrorTo(target, c.Sender()) {
+		return c.Send("You can only mirror to your own private chat or chats you administer.")
+	}
+
+	var files []filecache.File
+	if reply := c.Message().ReplyTo; reply != nil {
+		file, ok := cachedFile(reply)
+		if !ok || reply.Sender == nil || reply.Sender.ID != bs.bot.Me.ID {
+			return c.Send("Reply to a video or file I sent.")
+		}
+		files = []filecache.File{file}
+	} else {
+		urls := downloader.ExtractURLs(rest)
+		if len(urls) == 0 {
+			return c.Send(mirrorUsage)
+		}
+		entry, ok := bs.fileCache.Get(filecache.Key(urls[0], ""))
+		if !ok {
+			return c.Send("I haven't delivered that link yet (or it expired from the cache). Download it first.")
+		}
+		files = entry.Files
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, urls[0]))
+	}
+	if rest != "" {
+		files[0].Caption = rest
+	}
+
+	var prevMsg *tele.Message
+	for _, file := range files {
+		sent, err := bs.uploads.Send(target, cachedMedia(file), &tele.SendOptions{ReplyTo: prevMsg})
+		if err != nil {
+			logger.Warn("Failed to mirror file", "chat", target.ID, "user", c.Sender().ID, "error", err)
+			return c.Send(fmt.Sprintf("Failed to post to %s: %v", chatLabel(target), err))
+		}
+		prevMsg = sent
+	}
+
+	logger.Info("Mirrored delivered files", "chat", target.ID, "files", len(files), "user", c.Sender().ID)
+	return c.Send(fmt.Sprintf("Posted to %s.", chatLabel(target)))
+}
+
+// mirrorTarget resolves a chat ID or @username.
+func (bs *BotService) mirrorTarget(ref string) (*tele.Chat, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return bs.bot.ChatByID(id)
+	}
+	return bs.bot.ChatByUsername(ref)
+}
+
+// canMirrorTo reports whether user may post into chat: their own private
+// chat, a chat they administer, or any chat for bot admins.
+func (bs *BotService) canMirrorTo(chat *tele.Chat, user *tele.User) bool {
+	if chat.Type == tele.ChatPrivate {
+		return chat.ID == user.ID || bs.isAdmin(user.ID)
+	}
+	return bs.isChatAdmin(chat, user)
+}
+
+// chatLabel names a chat for replies: its title, @username or ID.
+func chatLabel(chat *tele.Chat) string {
+	switch {
+	case chat.Title != "":
+		return chat.Title
+	case chat.Username != "":
+		return "@" + chat.Username
+	}
+	return strconv.FormatInt(chat.ID, 10)
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+	tele "gopkg.in/telebot.v3"
+)
+
+// errSettingNotSaved is returned by the setting stores when the change
+// couldn't be written; the setting keeps its saved value.
+var errSettingNotSaved = errors.New("setting not saved")
+
+// settingNotSaved answers a command whose setting couldn't be saved.
+func settingNotSaved(c tele.Context) error {
+	return c.Send("❌ Couldn't save that setting, it is unchanged. Please try again later.")
+}
+
+// settingsStore holds a setting per user or chat, persisted to a JSON file
+// so it survives restarts. Keys whose value isUnset (off, the default) are
+// removed rather than stored. get rereads the file when it changed since it
+// was last read or written, so a setting saved by another instance sharing
+// the data directory applies here too.
+type settingsStore[K comparable, V any] struct {
+	mu      sync.Mutex
+	path    string
+	what    string // e.g. "caption preferences", for log messages
+	values  map[K]V
+	modTime time.Time // of the file as values were last read or written
+	isUnset func(V) bool
+}
+
+func newSettingsStore[K comparable, V any](path, what string, isUnset func(V) bool) *settingsStore[K, V] {
+	s := &settingsStore[K, V]{path: path, what: what, values: make(map[K]V), isUnset: isUnset}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+	return s
+}
+
+// get returns key's setting, the zero value if it has none.
+func (s *settingsStore[K, V]) get(key K) V {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+	return s.values[key]
+}
+
+// set stores key's setting; an unset value removes it.
+func (s *settingsStore[K, V]) set(key K, value V) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := store.Update(s.path, &s.values, func() error {
+		if s.values == nil {
+			s.values = make(map[K]V)
+		}
+		if s.isUnset(value) {
+			delete(s.values, key)
+		} else {
+			s.values[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Warn("Failed to save "+s.what, "error", err)
+		return errSettingNotSaved
+	}
+	s.modTime = s.fileModTime()
+	return nil
+}
+
+// reloadLocked loads the file again if it changed since modTime. A file
+// that can't be read keeps the settings in memory. Must hold s.mu.
+func (s *settingsStore[K, V]) reloadLocked() {
+	if s.path == "" {
+		return
+	}
+	modTime := s.fileModTime()
+	if modTime.Equal(s.modTime) {
+		return
+	}
+	values := make(map[K]V)
+	if err := store.LoadJSON(s.path, &values); err != nil {
+		logger.Warn("Failed to load "+s.what, "error", err)
+		return
+	}
+	s.values, s.modTime = values, modTime
+}
+
+// fileModTime returns the file's modification time, zero if it is missing.
+func (s *settingsStore[K, V]) fileModTime() time.Time {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,30 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingsStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "captions.json")
+	a := newCaptionPrefs(path)
+	b := newCaptionPrefs(path)
+
+	require.NoError(t, a.set(1, captionsParts))
+	assert.Equal(t, captionsParts, a.get(1))
+	assert.Equal(t, captionsParts, b.get(1), "another instance's change is read")
+
+	require.NoError(t, b.set(2, captionsNone))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.Equal(t, captionsNone, a.get(2))
+
+	require.NoError(t, a.set(1, ""))
+	assert.Equal(t, "", b.get(1), "unset values are removed")
+	assert.Equal(t, captionsNone, newCaptionPrefs(path).get(2))
+}
@@ -0,0 +1,117 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/upload"
+	tele "gopkg.in/telebot.v3"
+)
+
+// simulatePrefix marks job URLs that run a /simulate scenario instead of a download.
+const simulatePrefix = "simulate://"
+
+// errSimulated is the injected failure of a /simulate scenario.
+var errSimulated = errors.New("simulated failure")
+
+// simulatedFaults are the /simulate scenarios.
+var simulatedFaults = map[string]string{
+	"download": "the download fails",
+	"encode":   "the encode times out and is killed",
+	"upload":   "the first upload attempt gets a 429",
+}
+
+// handleSimulate queues a job that injects a failure at a chosen phase, so
+// admins can check retries, cleanup and notifications in the production setup.
+func (bs *BotService) handleSimulate(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+
+	fault := strings.TrimSpace(c.Message().Payload)
+	if _, ok := simulatedFaults[fault]; !ok {
+		var b strings.Builder
+		b.WriteString("Usage: /simulate <phase>\n")
+		for _, name := range []string{"download", "encode", "upload"} {
+			fmt.Fprintf(&b, "- %s — %s\n", name, simulatedFaults[name])
+		}
+		return c.Send(strings.TrimSuffix(b.String(), "\n"))
+	}
+
+	logger.Info("Simulating failure", "fault", fault, "user", c.Sender().ID)
+	return bs.submit(newJob(c, simulatePrefix+fault))
+}
+
+// runSimulation runs a /simulate job through the same status message,
+// cleanup and error reporting as a real download.
+func (bs *BotService) runSimulation(ctx context.Context, job *queue.Job) error {
+	fault := strings.TrimPrefix(job.URL, simulatePrefix)
+	statusMsg, err := bs.jobStatus(job, fmt.Sprintf("Simulating: %s...", simulatedFaults[fault]), cancelMarkup(job.ID))
+	if err != nil {
+		return err
+	}
+
+	switch fault {
+	case "download":
+		bs.phases.set(job.ID, "Downloading")
+		bs.emitPhase(job, "downloading")
+		err := fmt.Errorf("download: %w", errSimulated)
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Download failed: %v", err))
+		return err
+
+	case "encode":
+		bs.phases.set(job.ID, "Encoding")
+		bs.emitPhase(job, "encoding")
+		encodeCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_, err := bs.engine.ProcessSynthetic(encodeCtx, nil)
+		if err == nil {
+			err = fmt.Errorf("encode finished before the timeout: %w", errSimulated)
+		}
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Download failed: %v", err))
+		return err
+
+	case "upload":
+		bs.phases.set(job.ID, "Encoding")
+		bs.emitPhase(job, "encoding")
+		result, err := bs.engine.ProcessSynthetic(ctx, nil)
+		if err != nil {
+			bs.editStatus(job, statusMsg, fmt.Sprintf("Download failed: %v", err))
+			return err
+		}
+		defer bs.cleanup(job, result)
+
+		bs.phases.set(job.ID, "Uploading")
+		bs.emitPhase(job, "uploading")
+		bs.editStatus(job, statusMsg, "Uploading (first attempt gets a 429)...", cancelMarkup(job.ID))
+		flooded := false
+		_, err = upload.Retry(func() (*tele.Message, error) {
+			if !flooded {
+				flooded = true
+				return nil, tele.FloodError{RetryAfter: 1}
+			}
+			return bs.bot.Send(jobChat(job), &tele.Video{
+				File:      bs.uploads.LocalFile(result.FilePath),
+				FileName:  result.FileName,
+				Caption:   "Simulated upload (retried after 429)",
+				Width:     result.Width,
+				Height:    result.Height,
+				Duration:  downloader.SyntheticDuration,
+				Streaming: true,
+			}, &tele.SendOptions{ThreadID: job.ThreadID})
+		})
+		if err != nil {
+			bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+			return err
+		}
+		bs.bot.Delete(statusMsg)
+		return nil
+	}
+	return fmt.Errorf("unknown simulation %q", fault)
+}
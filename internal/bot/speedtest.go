@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/config"
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	// speedTestDownloadTime bounds the download half of /speedtest.
+	speedTestDownloadTime = 15 * time.Second
+
+	// speedTestUploadSize is the file uploaded to the Bot API server, small
+	// enough for the official server's 50 MB limit.
+	speedTestUploadSize = 20 * 1024 * 1024
+)
+
+// handleSpeedTest handles /speedtest for bot admins: measures download
+// throughput from a reference URL (SUSHE_SPEEDTEST_URL) and upload
+// throughput to the Bot API server, to tell a slow network from a slow
+// site or pipeline.
+func (bs *BotService) handleSpeedTest(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+	if !bs.speedTestRunning.CompareAndSwap(false, true) {
+		return c.Send("A speed test is already running.")
+	}
+
+	text := "📶 Measuring download speed..."
+	if _, running := bs.queue.Len(); running > 0 {
+		text += fmt.Sprintf("\n%d downloads are running and share the bandwidth, so speeds may read low.", running)
+	}
+	msg, err := bs.bot.Send(c.Chat(), text, &tele.SendOptions{ThreadID: c.Message().ThreadID})
+	if err != nil {
+		bs.speedTestRunning.Store(false)
+		return err
+	}
+	go bs.runSpeedTest(msg)
+	return nil
+}
+
+// runSpeedTest measures both directions, keeping msg updated, and replaces
+// it with the results.
+func (bs *BotService) runSpeedTest(msg *tele.Message) {
+	defer bs.speedTestRunning.Store(false)
+	refURL := config.String("SUSHE_SPEEDTEST_URL", downloader.DefaultSpeedTestURL)
+
+	down, downErr := downloader.MeasureDownload(context.Background(), refURL, speedTestDownloadTime)
+	bs.edits.Wait(context.Background(), msg.Chat.ID)
+	bs.bot.Edit(msg, "📶 Measuring upload speed to the Bot API server...")
+	up, upErr := bs.measureUpload(msg)
+
+	logger.Info("Speed test done",
+		"download_bps", int64(down.BytesPerSec()), "download_error", downErr,
+		"upload_bps", int64(up.BytesPerSec()), "upload_error", upErr)
+	bs.bot.Edit(msg, bs.speedTestReport(refURL, down, downErr, up, upErr), &tele.SendOptions{DisableWebPagePreview: true})
+}
+
+// measureUpload uploads speedTestUploadSize random bytes to msg's chat as a
+// document through the Bot API server, deleting it right after, and
+// reports the throughput. Random data, so nothing on the way can compress
+// it.
+func (bs *BotService) measureUpload(msg *tele.Message) (downloader.Throughput, error) {
+	doc := &tele.Document{
+		File:     tele.FromReader(io.LimitReader(rand.Reader, speedTestUploadSize)),
+		FileName: "speedtest.bin",
+	}
+	start := time.Now()
+	sent, err := bs.bot.Send(msg.Chat, doc, &tele.SendOptions{ThreadID: msg.ThreadID, ReplyTo: msg, DisableNotification: true})
+	result := downloader.Throughput{Bytes: speedTestUploadSize, Elapsed: time.Since(start)}
+	if err != nil {
+		return downloader.Throughput{}, err
+	}
+	if err := bs.bot.Delete(sent); err != nil {
+		logger.Warn("Failed to delete speed test upload", "error", err)
+	}
+	return result, nil
+}
+
+// speedTestReport formats the /speedtest results.
+func (bs *BotService) speedTestReport(refURL string, down downloader.Throughput, downErr error, up downloader.Throughput, upErr error) string {
+	var b strings.Builder
+	b.WriteString("📶 Speed test\n\n")
+	if downErr != nil {
+		fmt.Fprintf(&b, "⬇️ Download: failed — %v\n", downErr)
+	} else {
+		fmt.Fprintf(&b, "⬇️ Download: %s (%s in %s)\n",
+			bs.locale.Speed(down.BytesPerSec()), bs.locale.Size(down.Bytes), bs.locale.Duration(down.Elapsed))
+	}
+	if upErr != nil {
+		fmt.Fprintf(&b, "⬆️ Upload to Bot API: failed — %v\n", upErr)
+	} else {
+		fmt.Fprintf(&b, "⬆️ Upload to Bot API: %s (%s in %s)\n",
+			bs.locale.Speed(up.BytesPerSec()), bs.locale.Size(up.Bytes), bs.locale.Duration(up.Elapsed))
+	}
+	fmt.Fprintf(&b, "\nReference: %s", refURL)
+	return b.String()
+}
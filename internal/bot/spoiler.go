@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/config"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/nsfw"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/secrets"
+	tele "gopkg.in/telebot.v3"
+)
+
+// classifyTimeout bounds one thumbnail classification; uploads don't wait
+// longer than this for a verdict.
+const classifyTimeout = 15 * time.Second
+
+// /spoiler modes. Groups without a setting use spoilerAuto.
+const (
+	spoilerAuto   = "auto"   // spoiler videos the classifier flags
+	spoilerAlways = "always" // spoiler every video
+	spoilerOff    = "off"
+)
+
+// newSpoilerPrefs stores each group's /spoiler mode, persisted so it
+// survives restarts. spoilerAuto isn't stored: groups without a mode
+// read "", which counts as auto.
+func newSpoilerPrefs(path string) *settingsStore[int64, string] {
+	return newSettingsStore[int64](path, "spoiler settings", func(mode string) bool { return mode == "" || mode == spoilerAuto })
+}
+
+// nsfwDetector flags thumbnails whose classifier score reaches threshold.
+type nsfwDetector struct {
+	classifier nsfw.Classifier
+	threshold  float64
+}
+
+// newNSFWDetector classifies thumbnails with the endpoint at SUSHE_NSFW_URL
+// (bearer token SUSHE_NSFW_TOKEN) or the command SUSHE_NSFW_COMMAND, and
+// flags scores of at least SUSHE_NSFW_THRESHOLD percent. Returns nil
+// (detection off) if neither is set.
+func newNSFWDetector() *nsfwDetector {
+	var classifier nsfw.Classifier
+	if url := config.String("SUSHE_NSFW_URL", ""); url != "" {
+		token, err := secrets.Get("SUSHE_NSFW_TOKEN")
+		if err != nil {
+			logger.Error("Failed to read NSFW classifier token, detection disabled", "error", err)
+			return nil
+		}
+		classifier = nsfw.HTTP{URL: url, Token: token}
+	} else if command := config.String("SUSHE_NSFW_COMMAND", ""); command != "" {
+		classifier = nsfw.Command{Path: command}
+	} else {
+		return nil
+	}
+	return &nsfwDetector{
+		classifier: classifier,
+		threshold:  float64(config.Int("SUSHE_NSFW_THRESHOLD", 80)) / 100,
+	}
+}
+
+// classify reports whether the classifier flags job's video by its
+// thumbnail. A failed classification counts as safe.
+func (bs *BotService) classify(job *queue.Job, thumbnailPath string) bool {
+	if bs.nsfw == nil || thumbnailPath == "" {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), classifyTimeout)
+	defer cancel()
+	score, err := bs.nsfw.classifier.Score(ctx, thumbnailPath)
+	if err != nil {
+		jobLog(job).Warn("Failed to classify thumbnail", "error", err)
+		return false
+	}
+	flagged := score >= bs.nsfw.threshold
+	if flagged {
+		jobLog(job).Info("Thumbnail flagged as NSFW", "score", score)
+	}
+	return flagged
+}
+
+// spoilerIn reports whether a video goes out as a spoiler in chatID, given
+// whether it was flagged. Private chats never get spoilers.
+func (bs *BotService) spoilerIn(chatID int64, flagged bool) bool {
+	if chatID > 0 {
+		return false
+	}
+	switch bs.spoilers.get(chatID) {
+	case spoilerAlways:
+		return true
+	case spoilerOff:
+		return false
+	}
+	return flagged
+}
+
+// handleSpoiler handles /spoiler [auto|always|off]: shows or sets whether
+// videos in this group are hidden behind a spoiler. Only chat admins can
+// set it.
+func (bs *BotService) handleSpoiler(c tele.Context) error {
+	if c.Chat().Type == tele.ChatPrivate {
+		return c.Send("Spoilers are only used in groups; send /spoiler there.")
+	}
+	arg := strings.ToLower(strings.TrimSpace(c.Message().Payload))
+	chatID := c.Chat().ID
+
+	if arg == "" {
+		var text string
+		switch bs.spoilers.get(chatID) {
+		case spoilerAlways:
+			text = "Every video here is sent as a spoiler."
+		case spoilerOff:
+			text = "Videos here are never sent as spoilers."
+		default:
+			text = "Videos that look NSFW by their thumbnail are sent as spoilers here."
+			if bs.nsfw == nil {
+				text = "Videos here would be sent as spoilers when they look NSFW, but no classifier is configured, so none are."
+			}
+		}
+		return c.Send(text + "\nUsage: /spoiler auto|always|off")
+	}
+	if !bs.isChatAdmin(c.Chat(), c.Sender()) {
+		return c.Send("Only chat admins can change the spoiler setting.")
+	}
+
+	switch arg {
+	case spoilerAuto:
+		if err := bs.spoilers.set(chatID, spoilerAuto); err != nil {
+			return settingNotSaved(c)
+		}
+		if bs.nsfw == nil {
+			return c.Send("Spoiler setting reset. No NSFW classifier is configured, so no video is spoilered automatically.")
+		}
+		return c.Send("Videos that look NSFW will be sent as spoilers.")
+	case spoilerAlways:
+		if err := bs.spoilers.set(chatID, spoilerAlways); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Every video will be sent as a spoiler.")
+	case spoilerOff:
+		if err := bs.spoilers.set(chatID, spoilerOff); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Videos will no longer be sent as spoilers.")
+	}
+	return c.Send("Usage: /spoiler auto|always|off")
+}
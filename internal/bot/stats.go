@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/format"
+	tele "gopkg.in/telebot.v3"
+)
+
+// jobStats aggregates per-job outcomes and subprocess resource usage since startup.
+type jobStats struct {
+	mu        sync.Mutex
+	started   time.Time
+	completed int
+	failed    int
+	cpuTime   time.Duration
+	peakRSS   int64
+	runTime   time.Duration // wall-clock time of completed jobs, for queue ETAs
+}
+
+func newJobStats() *jobStats {
+	return &jobStats{started: time.Now()}
+}
+
+// record adds a finished job's outcome, wall-clock time and resource usage.
+func (s *jobStats) record(usage *downloader.Usage, elapsed time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failed++
+	} else {
+		s.completed++
+		s.runTime += elapsed
+	}
+	s.cpuTime += usage.CPUTime()
+	if rss := usage.PeakRSS(); rss > s.peakRSS {
+		s.peakRSS = rss
+	}
+}
+
+// avgDuration returns the average wall-clock time of completed jobs, or 0
+// if none have completed yet.
+func (s *jobStats) avgDuration() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.completed == 0 {
+		return 0
+	}
+	return s.runTime / time.Duration(s.completed)
+}
+
+// render reports uptime, job counts and resource usage, formatted for l.
+func (s *jobStats) render(l format.Locale) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var avgCPU time.Duration
+	if total := s.completed + s.failed; total > 0 {
+		avgCPU = s.cpuTime / time.Duration(total)
+	}
+
+	return fmt.Sprintf("Uptime: %s\n"+
+		"Jobs: %d completed, %d failed\n"+
+		"CPU time: %s total, %s avg per job\n"+
+		"Peak subprocess RSS: %s",
+		l.Duration(time.Since(s.started)),
+		s.completed, s.failed,
+		l.Duration(s.cpuTime), l.Duration(avgCPU),
+		l.Size(s.peakRSS))
+}
+
+// handleStats shows job counts and subprocess resource usage since startup.
+func (bs *BotService) handleStats(c tele.Context) error {
+	return c.Send(bs.stats.render(bs.locale))
+}
+
+// globalStatsTop is how many of the busiest users /globalstats lists.
+const globalStatsTop = 10
+
+// handleGlobalStats handles /globalstats for bot admins: /stats plus the
+// queue, who has access and the busiest users of the past week.
+func (bs *BotService) handleGlobalStats(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("🌐 Global stats\n\n")
+	b.WriteString(bs.stats.render(bs.locale))
+	pending, running := bs.queue.Len()
+	fmt.Fprintf(&b, "\nQueue: %d waiting, %d running on %d workers", pending, running, bs.queue.Workers())
+	fmt.Fprintf(&b, "\nAccess: %d whitelisted users, %d admins, %d allowed chats",
+		len(bs.allowedUsers.ids()), len(bs.admins), len(bs.allowedChats))
+
+	top := bs.history.Top(time.Now().AddDate(0, 0, -7), globalStatsTop)
+	if len(top) == 0 {
+		b.WriteString("\n\nNo downloads delivered in the past 7 days.")
+		return c.Send(b.String())
+	}
+	b.WriteString("\n\nMost downloads, past 7 days:")
+	for i, u := range top {
+		fmt.Fprintf(&b, "\n%d. %d — %d", i+1, u.UserID, u.Downloads)
+	}
+	return c.Send(b.String())
+}
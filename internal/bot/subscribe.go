@@ -0,0 +1,325 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/subscription"
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	// subscriptionTick is how often subscriptions are checked for being due.
+	subscriptionTick = time.Minute
+
+	// subscriptionUploads is how many of a channel's newest uploads each
+	// check lists.
+	subscriptionUploads = 15
+
+	// subscriptionCheckTimeout bounds listing a channel's newest uploads.
+	subscriptionCheckTimeout = 2 * time.Minute
+)
+
+const subscribeUsage = "Usage: /subscribe <channel url> [@chat] [interval] [480|720|1080|audio], e.g. /subscribe <url> 30m audio\n" +
+	"/subscribe <id> [interval] [quality] changes a subscription, /unsubscribe <id> ends it.\n" +
+	"/subscribe import [settings] in reply to an OPML or CSV file adds its channels, /subscribe export [csv] sends yours as a file."
+
+// watchSettings are the optional /subscribe arguments after the channel.
+type watchSettings struct {
+	target   string // chat ID or @username, "" for the current chat
+	interval time.Duration
+	quality  string // "default" resets to the default quality
+}
+
+// parseWatchSettings parses the /subscribe arguments after the channel URL
+// or subscription ID, in any order.
+func (bs *BotService) parseWatchSettings(args []string) (watchSettings, error) {
+	var s watchSettings
+	for _, arg := range args {
+		switch {
+		case arg == qualityAudio || arg == "default":
+			s.quality = arg
+		case strings.HasPrefix(arg, "@"):
+			s.target = arg
+		default:
+			if n, err := strconv.Atoi(strings.TrimSuffix(arg, "p")); err == nil && n > 0 && n <= 4320 {
+				s.quality = strconv.Itoa(n)
+				continue
+			}
+			if _, err := strconv.ParseInt(arg, 10, 64); err == nil {
+				s.target = arg
+				continue
+			}
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return s, fmt.Errorf("unknown setting %q", arg)
+			}
+			if d < subscription.MinInterval {
+				return s, fmt.Errorf("checking more often than every %s isn't allowed", bs.locale.Duration(subscription.MinInterval))
+			}
+			s.interval = d
+		}
+	}
+	return s, nil
+}
+
+// handleSubscribe handles /subscribe <channel url> [settings]: new uploads
+// of the channel are downloaded and posted to this chat (or @chat) as they
+// appear. /subscribe lists the caller's subscriptions, /subscribe <id>
+// [settings] changes one, /subscribe import and /subscribe export move them
+// from and to OPML or CSV files.
+func (bs *BotService) handleSubscribe(c tele.Context) error {
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		return c.Send(bs.renderSubscriptions(c.Sender().ID), &tele.SendOptions{DisableWebPagePreview: true})
+	}
+	switch strings.ToLower(args[0]) {
+	case "import":
+		return bs.handleSubscribeImport(c, c.Message().ReplyTo, args[1:])
+	case "export":
+		return bs.handleSubscribeExport(c, args[1:])
+	}
+	settings, err := bs.parseWatchSettings(args[1:])
+	if err != nil {
+		return c.Send(fmt.Sprintf("%v\n%s", err, subscribeUsage))
+	}
+
+	urls := downloader.ExtractURLs(args[0])
+	chatID, threadID, refusal := bs.subscribeDestination(c, settings, len(urls) > 0)
+	if refusal != "" {
+		return c.Send(refusal)
+	}
+	if len(urls) == 0 {
+		return bs.updateSubscription(c, args[0], settings, chatID, threadID)
+	}
+
+	w := subscription.Watch{
+		ID:       queue.NewJobID(),
+		URL:      urls[0],
+		ChatID:   chatID,
+		ThreadID: threadID,
+		UserID:   c.Sender().ID,
+		Username: newJob(c, urls[0]).Username,
+		Interval: settings.interval,
+	}
+	if settings.quality != "default" {
+		w.Quality = settings.quality
+	}
+	msg, err := bs.bot.Send(c.Chat(), "📺 Checking the channel...",
+		&tele.SendOptions{ThreadID: c.Message().ThreadID, DisableWebPagePreview: true})
+	if err != nil {
+		return err
+	}
+	go bs.startSubscription(w, msg)
+	return nil
+}
+
+// subscribeDestination resolves the chat and topic a subscription posts
+// to: settings' @chat, else the current chat. A non-empty refusal is the
+// answer when it can't be used; newTopic applies the General topic guard
+// that only new subscriptions in the current chat need.
+func (bs *BotService) subscribeDestination(c tele.Context, settings watchSettings, newTopic bool) (chatID int64, threadID int, refusal string) {
+	if settings.target != "" {
+		chat, err := bs.mirrorTarget(settings.target)
+		if err != nil {
+			return 0, 0, fmt.Sprintf("Can't find %s. Add me to it first.", settings.target)
+		}
+		if !bs.canMirrorTo(chat, c.Sender()) {
+			return 0, 0, fmt.Sprintf("You can only subscribe chats you administer, and %s isn't one.", chatLabel(chat))
+		}
+		return chat.ID, 0, ""
+	}
+	chatID, threadID = c.Chat().ID, c.Message().ThreadID
+	// GENERAL topic guard (Bot API bug #447)
+	if newTopic && c.Chat().Type != tele.ChatPrivate && (threadID == 0 || threadID == 1) {
+		return 0, 0, "⚠️ Please use /subscribe in a named topic (not General), or name a target @chat"
+	}
+	return chatID, threadID, ""
+}
+
+// listUploads returns the URLs of the channel's newest uploads, newest
+// first, for a new subscription to count as seen.
+func (bs *BotService) listUploads(url string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionCheckTimeout)
+	defer cancel()
+	videos, err := bs.engine.LatestUploads(ctx, url, subscriptionUploads)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, len(videos))
+	for i, v := range videos {
+		urls[i] = v.URL
+	}
+	return urls, nil
+}
+
+// startSubscription lists the channel's current uploads, which count as
+// seen, and adds the subscription.
+func (bs *BotService) startSubscription(w subscription.Watch, msg *tele.Message) {
+	seen, err := bs.listUploads(w.URL)
+	if err != nil || len(seen) == 0 {
+		logger.Warn("Failed to list channel for subscription", "url", w.URL, "error", err)
+		bs.bot.Edit(msg, "📺 Couldn't list uploads of that channel. Is it a channel or playlist link?")
+		return
+	}
+	w.Seen = seen
+	w.Checked = time.Now()
+
+	if err := bs.subscriptions.Add(w); err != nil {
+		text := "📺 You're already subscribed to that channel here."
+		if errors.Is(err, subscription.ErrUserLimit) {
+			text = fmt.Sprintf("📺 You already have %d subscriptions. End one with /unsubscribe <id>.", subscription.MaxPerUser)
+		}
+		bs.bot.Edit(msg, text)
+		return
+	}
+	logger.Info("Subscribed to channel", "id", w.ID, "url", w.URL, "chat", w.ChatID, "user", w.UserID)
+	bs.bot.Edit(msg, fmt.Sprintf("📺 Subscribed (%s). New uploads will be downloaded as they appear; /unsubscribe %s ends it.",
+		bs.watchSummary(w), w.ID), &tele.SendOptions{DisableWebPagePreview: true})
+}
+
+// updateSubscription applies new settings to the caller's subscription id.
+func (bs *BotService) updateSubscription(c tele.Context, id string, settings watchSettings, chatID int64, threadID int) error {
+	w, ok := bs.subscriptions.Update(c.Sender().ID, id, func(w *subscription.Watch) {
+		if settings.target != "" {
+			w.ChatID, w.ThreadID = chatID, threadID
+		}
+		if settings.interval > 0 {
+			w.Interval = settings.interval
+		}
+		switch settings.quality {
+		case "":
+		case "default":
+			w.Quality = ""
+		default:
+			w.Quality = settings.quality
+		}
+	})
+	if !ok {
+		return c.Send("No subscription with that ID. Send /subscribe to list yours.\n" + subscribeUsage)
+	}
+	return c.Send(fmt.Sprintf("📺 Subscription updated: %s", bs.watchSummary(w)), &tele.SendOptions{DisableWebPagePreview: true})
+}
+
+// handleUnsubscribe handles /unsubscribe <id>.
+func (bs *BotService) handleUnsubscribe(c tele.Context) error {
+	id := strings.TrimSpace(c.Message().Payload)
+	if id == "" {
+		return c.Send("Usage: /unsubscribe <id>. Send /subscribe to list your subscriptions.")
+	}
+	w, ok := bs.subscriptions.Remove(c.Sender().ID, id)
+	if !ok {
+		return c.Send("No subscription with that ID. Send /subscribe to list yours.")
+	}
+	logger.Info("Unsubscribed from channel", "id", w.ID, "url", w.URL, "user", w.UserID)
+	return c.Send("📺 Unsubscribed from "+w.URL, &tele.SendOptions{DisableWebPagePreview: true})
+}
+
+// renderSubscriptions lists userID's subscriptions.
+func (bs *BotService) renderSubscriptions(userID int64) string {
+	list := bs.subscriptions.List(userID)
+	if len(list) == 0 {
+		return "You have no subscriptions.\n" + subscribeUsage
+	}
+	var b strings.Builder
+	b.WriteString("📺 Subscriptions:\n")
+	for _, w := range list {
+		fmt.Fprintf(&b, "\n%s — %s\n%s\n", w.ID, bs.watchSummary(w), w.URL)
+	}
+	b.WriteString("\n" + subscribeUsage)
+	return b.String()
+}
+
+// watchSummary describes a subscription's settings.
+func (bs *BotService) watchSummary(w subscription.Watch) string {
+	quality := "default quality"
+	switch w.Quality {
+	case "":
+	case qualityAudio:
+		quality = "audio only"
+	default:
+		quality = w.Quality + "p"
+	}
+	return fmt.Sprintf("every %s, %s, to chat %d", bs.locale.Duration(w.Interval), quality, w.ChatID)
+}
+
+// runSubscriptions checks subscribed channels when they are due, until the
+// bot stops.
+func (bs *BotService) runSubscriptions() {
+	ticker := time.NewTicker(subscriptionTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-bs.stop:
+			return
+		}
+		if bs.maintenance.get().On {
+			continue // checked once maintenance ends
+		}
+		for _, w := range bs.subscriptions.Due(time.Now()) {
+			bs.checkSubscription(w)
+		}
+	}
+}
+
+// checkSubscription lists a channel's newest uploads and queues those not
+// seen before, oldest first.
+func (bs *BotService) checkSubscription(w subscription.Watch) {
+	if !bs.stillAuthorized(w.UserID, w.ChatID) {
+		logger.Info("Skipping subscription of unauthorized user", "id", w.ID, "user", w.UserID)
+		bs.subscriptions.Checked(w.ID, nil, time.Now())
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionCheckTimeout)
+	defer cancel()
+	videos, err := bs.engine.LatestUploads(ctx, w.URL, subscriptionUploads)
+	if err != nil {
+		// Try again after the interval
+		logger.Warn("Failed to check subscription", "id", w.ID, "url", w.URL, "error", err)
+	}
+	urls := make([]string, len(videos))
+	for i, v := range videos {
+		urls[i] = v.URL
+	}
+	fresh := bs.subscriptions.Checked(w.ID, urls, time.Now())
+
+	for i := len(fresh) - 1; i >= 0; i-- {
+		job := &queue.Job{
+			ID:       queue.NewJobID(),
+			URL:      fresh[i],
+			ChatID:   w.ChatID,
+			ThreadID: w.ThreadID,
+			UserID:   w.UserID,
+			Username: w.Username,
+			Quality:  w.Quality,
+		}
+		subs := bs.subtitles.get(job.UserID)
+		job.Subtitles, job.BurnSubtitles = subs.Lang, subs.Burn
+		job.Captions = bs.captions.get(job.UserID)
+		jobLog(job).Info("New upload in subscription", "id", w.ID, "url", job.URL)
+
+		if !bs.quotaAllows(job) {
+			jobLog(job).Info("Subscription download over daily quota", "url", job.URL, "user", job.UserID)
+			bs.bot.Send(jobChat(job), fmt.Sprintf("📺 New upload %s couldn't be queued. %s", job.URL, bs.quotaExceeded(job.UserID)),
+				&tele.SendOptions{ThreadID: job.ThreadID})
+			continue
+		}
+		if err := bs.queue.Admit(job.UserID); err != nil {
+			logger.Info("Subscription download rejected", "url", job.URL, "user", job.UserID, "reason", err)
+			bs.bot.Send(jobChat(job), fmt.Sprintf("📺 New upload %s couldn't be queued: %s", job.URL, bs.rejection(err)),
+				&tele.SendOptions{ThreadID: job.ThreadID})
+			continue
+		}
+		if err := bs.dispatch(job); err != nil {
+			jobLog(job).Error("Failed to queue subscription download", "error", err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package bot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/subscription"
+	tele "gopkg.in/telebot.v3"
+)
+
+// maxImportSize caps the OPML or CSV file /subscribe import reads.
+const maxImportSize = 1 << 20
+
+const subscribeImportUsage = "Reply to an OPML or CSV file with /subscribe import [@chat] [interval] [quality], " +
+	"or send the file with that as its caption."
+
+// importResult is what /subscribe import did with each entry of a file.
+type importResult struct {
+	added      int
+	duplicates int
+	failed     []string // URLs whose uploads couldn't be listed
+	overLimit  int      // entries left out at subscription.MaxPerUser
+}
+
+// handleDocument handles uploaded files: an OPML or CSV file captioned
+// /subscribe import [settings] is imported. Other files are ignored.
+func (bs *BotService) handleDocument(c tele.Context) error {
+	args, ok := importCaption(c.Message().Caption)
+	if !ok {
+		return nil
+	}
+	return bs.handleSubscribeImport(c, c.Message(), args)
+}
+
+// importCaption returns the settings of a "/subscribe import [settings]"
+// caption, false for any other caption.
+func importCaption(caption string) ([]string, bool) {
+	fields := strings.Fields(caption)
+	if len(fields) < 2 || !strings.EqualFold(fields[1], "import") {
+		return nil, false
+	}
+	command, _, _ := strings.Cut(fields[0], "@")
+	if command != "/subscribe" {
+		return nil, false
+	}
+	return fields[2:], true
+}
+
+// handleSubscribeImport subscribes the caller to every channel or feed in
+// the OPML or CSV file of docMsg, with the given /subscribe settings.
+// Listing each channel takes a while, so it runs in the background and
+// reports in a status message.
+func (bs *BotService) handleSubscribeImport(c tele.Context, docMsg *tele.Message, args []string) error {
+	if docMsg == nil || docMsg.Document == nil {
+		return c.Send(subscribeImportUsage)
+	}
+	settings, err := bs.parseWatchSettings(args)
+	if err != nil {
+		return c.Send(fmt.Sprintf("%v\n%s", err, subscribeImportUsage))
+	}
+	chatID, threadID, refusal := bs.subscribeDestination(c, settings, true)
+	if refusal != "" {
+		return c.Send(refusal)
+	}
+
+	doc := docMsg.Document
+	if doc.FileSize > maxImportSize {
+		return c.Send("📺 That file is too large for a subscription list.")
+	}
+	r, err := bs.bot.File(&doc.File)
+	if err != nil {
+		logger.Warn("Failed to fetch subscriptions file", "error", err)
+		return c.Send("📺 Couldn't fetch that file, please try again.")
+	}
+	subs, err := subscription.Parse(r, doc.FileName)
+	r.Close()
+	if err != nil {
+		return c.Send(fmt.Sprintf("📺 Couldn't read subscriptions from that file: %v", err))
+	}
+
+	base := subscription.Watch{
+		ChatID:   chatID,
+		ThreadID: threadID,
+		UserID:   c.Sender().ID,
+		Username: newJob(c, "").Username,
+		Interval: settings.interval,
+	}
+	if settings.quality != "default" {
+		base.Quality = settings.quality
+	}
+	msg, err := bs.bot.Send(c.Chat(), fmt.Sprintf("📺 Importing %d subscriptions...", len(subs)),
+		&tele.SendOptions{ThreadID: c.Message().ThreadID})
+	if err != nil {
+		return err
+	}
+	go func() {
+		result := bs.importSubscriptions(subs, base, bs.listUploads)
+		logger.Info("Imported subscriptions", "user", base.UserID, "entries", len(subs), "added", result.added,
+			"duplicates", result.duplicates, "failed", len(result.failed), "over_limit", result.overLimit)
+		bs.bot.Edit(msg, importReport(result, len(subs)), &tele.SendOptions{DisableWebPagePreview: true})
+	}()
+	return nil
+}
+
+// importSubscriptions adds a subscription like base for each of subs, with
+// the uploads list returns counting as seen, like /subscribe does for one.
+func (bs *BotService) importSubscriptions(subs []subscription.Subscription, base subscription.Watch, list func(url string) ([]string, error)) importResult {
+	var result importResult
+	for i, sub := range subs {
+		if len(bs.subscriptions.List(base.UserID)) >= subscription.MaxPerUser {
+			result.overLimit = len(subs) - i
+			break
+		}
+		w := base
+		w.ID = queue.NewJobID()
+		w.URL = subscription.ChannelURL(sub.URL)
+		seen, err := list(w.URL)
+		if err != nil || len(seen) == 0 {
+			logger.Warn("Failed to list channel for imported subscription", "url", w.URL, "error", err)
+			result.failed = append(result.failed, sub.URL)
+			continue
+		}
+		w.Seen = seen
+		w.Checked = time.Now()
+
+		switch err := bs.subscriptions.Add(w); {
+		case errors.Is(err, subscription.ErrUserLimit):
+			result.overLimit = len(subs) - i
+			return result
+		case err != nil:
+			result.duplicates++
+		default:
+			result.added++
+		}
+	}
+	return result
+}
+
+// importReport describes the outcome of importing total entries.
+func importReport(result importResult, total int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📺 Imported %d of %d subscriptions.", result.added, total)
+	if result.duplicates > 0 {
+		fmt.Fprintf(&b, "\n%d were already subscribed here.", result.duplicates)
+	}
+	if result.overLimit > 0 {
+		fmt.Fprintf(&b, "\n%d were left out: you can have at most %d subscriptions.", result.overLimit, subscription.MaxPerUser)
+	}
+	if len(result.failed) > 0 {
+		b.WriteString("\nCouldn't list uploads of:")
+		for _, url := range result.failed {
+			b.WriteString("\n- " + url)
+		}
+	}
+	if result.added > 0 {
+		b.WriteString("\n\nSend /subscribe to see them.")
+	}
+	return b.String()
+}
+
+// handleSubscribeExport handles /subscribe export [opml|csv]: sends the
+// caller's subscriptions as a file other watcher tools can import.
+func (bs *BotService) handleSubscribeExport(c tele.Context, args []string) error {
+	asCSV := len(args) > 0 && strings.EqualFold(args[0], "csv")
+	list := bs.subscriptions.List(c.Sender().ID)
+	if len(list) == 0 {
+		return c.Send("You have no subscriptions to export.")
+	}
+	data, name, err := exportSubscriptions(list, asCSV)
+	if err != nil {
+		logger.Error("Failed to export subscriptions", "error", err)
+		return c.Send("📺 Couldn't export your subscriptions, please try again.")
+	}
+	return c.Send(&tele.Document{
+		File:     tele.FromReader(bytes.NewReader(data)),
+		FileName: name,
+		Caption:  fmt.Sprintf("📺 %d subscriptions", len(list)),
+	})
+}
+
+// exportSubscriptions encodes watches as OPML, or CSV if asCSV, and
+// returns the file name to send it under.
+func exportSubscriptions(watches []subscription.Watch, asCSV bool) ([]byte, string, error) {
+	subs := make([]subscription.Subscription, len(watches))
+	for i, w := range watches {
+		subs[i] = subscription.Subscription{URL: w.URL}
+	}
+	var buf bytes.Buffer
+	if asCSV {
+		err := subscription.WriteCSV(&buf, subs)
+		return buf.Bytes(), "subscriptions.csv", err
+	}
+	err := subscription.WriteOPML(&buf, subs)
+	return buf.Bytes(), "subscriptions.opml", err
+}
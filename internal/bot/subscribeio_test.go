@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/subscription"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func TestImportCaption(t *testing.T) {
+	args, ok := importCaption("/subscribe import 30m audio")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"30m", "audio"}, args)
+
+	_, ok = importCaption("/subscribe@sushebot IMPORT")
+	assert.True(t, ok)
+	_, ok = importCaption("my subscriptions")
+	assert.False(t, ok)
+	_, ok = importCaption("/subscribe export")
+	assert.False(t, ok)
+}
+
+func TestImportSubscriptions(t *testing.T) {
+	bs := &BotService{subscriptions: subscription.NewWatches("")}
+	opml := `<opml version="2.0"><body>
+		<outline text="A" xmlUrl="https://www.youtube.com/feeds/videos.xml?channel_id=UCa"/>
+		<outline text="B" xmlUrl="https://www.youtube.com/@b"/>
+		<outline text="Broken" xmlUrl="https://example.com/broken"/>
+	</body></opml>`
+	subs, err := subscription.Parse(bytes.NewReader([]byte(opml)), "feeds.opml")
+	require.NoError(t, err)
+
+	var listed []string
+	list := func(url string) ([]string, error) {
+		listed = append(listed, url)
+		if url == "https://example.com/broken" {
+			return nil, errors.New("not a channel")
+		}
+		return []string{url + "/v1", url + "/v2"}, nil
+	}
+	base := subscription.Watch{ChatID: 10, UserID: 1, Quality: "720"}
+	result := bs.importSubscriptions(subs, base, list)
+
+	assert.Equal(t, 2, result.added)
+	assert.Equal(t, []string{"https://example.com/broken"}, result.failed)
+	assert.Equal(t, "https://www.youtube.com/channel/UCa", listed[0], "feed URLs become channel pages")
+
+	watches := bs.subscriptions.List(1)
+	require.Len(t, watches, 2)
+	assert.Equal(t, "https://www.youtube.com/@b", watches[1].URL)
+	assert.Equal(t, int64(10), watches[1].ChatID)
+	assert.Equal(t, "720", watches[1].Quality)
+	assert.Equal(t, []string{"https://www.youtube.com/@b/v1", "https://www.youtube.com/@b/v2"}, watches[1].Seen,
+		"current uploads count as seen")
+	assert.NotEqual(t, watches[0].ID, watches[1].ID)
+
+	// Importing the same file again adds nothing
+	result = bs.importSubscriptions(subs, base, list)
+	assert.Equal(t, 0, result.added)
+	assert.Equal(t, 2, result.duplicates)
+}
+
+func TestImportSubscriptionsStopsAtLimit(t *testing.T) {
+	bs := &BotService{subscriptions: subscription.NewWatches("")}
+	var subs []subscription.Subscription
+	for i := 0; i < subscription.MaxPerUser+3; i++ {
+		subs = append(subs, subscription.Subscription{URL: fmt.Sprintf("https://www.youtube.com/@c%d", i)})
+	}
+	list := func(url string) ([]string, error) { return []string{url + "/v"}, nil }
+
+	result := bs.importSubscriptions(subs, subscription.Watch{ChatID: 10, UserID: 1}, list)
+	assert.Equal(t, subscription.MaxPerUser, result.added)
+	assert.Equal(t, 3, result.overLimit)
+	assert.Contains(t, importReport(result, len(subs)), "3 were left out")
+}
+
+func TestExportSubscriptions(t *testing.T) {
+	watches := []subscription.Watch{
+		{URL: "https://www.youtube.com/@a"},
+		{URL: "https://www.youtube.com/channel/UCb"},
+	}
+
+	data, name, err := exportSubscriptions(watches, false)
+	require.NoError(t, err)
+	assert.Equal(t, "subscriptions.opml", name)
+	subs, err := subscription.Parse(bytes.NewReader(data), name)
+	require.NoError(t, err)
+	assert.Equal(t, []string{watches[0].URL, watches[1].URL}, []string{subs[0].URL, subs[1].URL})
+
+	data, name, err = exportSubscriptions(watches, true)
+	require.NoError(t, err)
+	assert.Equal(t, "subscriptions.csv", name)
+	subs, err = subscription.Parse(bytes.NewReader(data), name)
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	assert.Equal(t, watches[1].URL, subs[1].URL)
+}
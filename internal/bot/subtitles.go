@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// subtitlePref is a user's /subs setting.
+type subtitlePref struct {
+	Lang string `json:"lang"`
+	Burn bool   `json:"burn,omitempty"` // Burn into the video instead of sending .srt files
+}
+
+// newSubtitlePrefs stores each user's subtitle setting, persisted so it
+// survives restarts. An empty Lang, subtitles off, isn't stored.
+func newSubtitlePrefs(path string) *settingsStore[int64, subtitlePref] {
+	return newSettingsStore[int64](path, "subtitle preferences", func(pref subtitlePref) bool { return pref.Lang == "" })
+}
+
+// handleSubs handles /subs [lang|auto [burn]|off]: shows or sets the language
+// whose subtitles are sent as .srt files along with the caller's videos, or
+// with "burn", hardcoded into the picture for players without subtitles.
+// "auto" picks a language per video (see downloader.SubtitleAuto).
+func (bs *BotService) handleSubs(c tele.Context) error {
+	args := strings.Fields(c.Message().Payload)
+	userID := c.Sender().ID
+
+	switch {
+	case len(args) == 0:
+		pref := bs.subtitles.get(userID)
+		switch {
+		case pref.Lang == "":
+			return c.Send("Subtitles are off. Send /subs <language code>, e.g. /subs en, to get .srt files with your videos, " +
+				"/subs auto to get them in your Telegram language, or /subs en burn to have them burned into the picture.")
+		case pref.Lang == downloader.SubtitleAuto:
+			return c.Send("Subtitles: automatic — " + autoSubtitlesText(c.Sender()) + ". Send /subs off to stop, or /subs <language> to pick one.")
+		case pref.Burn:
+			return c.Send(fmt.Sprintf("Subtitles: %s, burned into the video. Send /subs off to stop, or /subs <language> to get .srt files instead.", pref.Lang))
+		}
+		return c.Send(fmt.Sprintf("Subtitles: %s. Send /subs off to stop, or /subs <language> to change.", pref.Lang))
+	case len(args) == 1 && strings.EqualFold(args[0], "off"):
+		if err := bs.subtitles.set(userID, subtitlePref{}); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Subtitles turned off.")
+	}
+	if strings.EqualFold(args[0], downloader.SubtitleAuto) {
+		args[0] = downloader.SubtitleAuto
+	} else if !downloader.ValidSubtitleLang(args[0]) {
+		args = nil
+	}
+	if len(args) == 0 || len(args) > 2 || len(args) == 2 && !strings.EqualFold(args[1], "burn") {
+		return c.Send("Usage: /subs <language code|auto> [burn] (e.g. en, de, pt-BR) or /subs off")
+	}
+
+	pref := subtitlePref{Lang: args[0], Burn: len(args) == 2}
+	if err := bs.subtitles.set(userID, pref); err != nil {
+		return settingNotSaved(c)
+	}
+	if pref.Lang == downloader.SubtitleAuto {
+		return c.Send("Subtitles set to automatic: " + autoSubtitlesText(c.Sender()) + ".")
+	}
+	if pref.Burn {
+		return c.Send(fmt.Sprintf("Subtitles set to %s, burned into the video. This re-encodes every video, so downloads take longer.", pref.Lang))
+	}
+	return c.Send(fmt.Sprintf("Subtitles set to %s. Videos that have them will come with an .srt file.", pref.Lang))
+}
+
+// autoSubtitlesText explains which language /subs auto picks for user.
+func autoSubtitlesText(user *tele.User) string {
+	if user.LanguageCode == "" {
+		return "in the video's original language, else English"
+	}
+	return fmt.Sprintf("in your Telegram language (%s), else the video's original language, else English", user.LanguageCode)
+}
+
+// sendSubtitles sends a result's subtitle files as documents in reply to the
+// uploaded video and returns the sent messages. Failures are logged; the
+// video has already been delivered. When /subs auto found no suitable
+// language, it lists the ones the video has instead.
+func (bs *BotService) sendSubtitles(job *queue.Job, replyTo *tele.Message, result *engine.ProcessResult) []*tele.Message {
+	if len(result.SubtitleChoices) > 0 {
+		text := fmt.Sprintf("No subtitles in your language, the original language or English. This video has: %s. "+
+			"Send /subs <code> to pick one, then the link again.", strings.Join(result.SubtitleChoices, ", "))
+		if _, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, ReplyTo: replyTo}); err != nil {
+			jobLog(job).Warn("Failed to list subtitle languages", "error", err)
+		}
+	}
+	var sent []*tele.Message
+	for _, path := range result.SubtitlePaths {
+		lang := downloader.SubtitleLang(path)
+		doc := &tele.Document{
+			File:     bs.uploads.LocalFile(path),
+			FileName: fmt.Sprintf("%s.%s.srt", result.Title, lang),
+			Caption:  fmt.Sprintf("Subtitles (%s)", lang),
+			MIME:     "application/x-subrip",
+		}
+		opts := &tele.SendOptions{ThreadID: deliveryThread(job), ReplyTo: replyTo}
+		msg, err := bs.uploads.Send(deliveryChat(job), doc, opts)
+		if err != nil {
+			jobLog(job).Warn("Failed to send subtitles", "lang", lang, "error", err)
+			continue
+		}
+		sent = append(sent, msg)
+	}
+	return sent
+}
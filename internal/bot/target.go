@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// newTargetPrefs stores each user's /target chat (user ID → chat ID),
+// persisted so it survives restarts. 0, uploads go where they were asked
+// for, isn't stored.
+func newTargetPrefs(path string) *settingsStore[int64, int64] {
+	return newSettingsStore[int64](path, "target chats", func(chatID int64) bool { return chatID == 0 })
+}
+
+// handleTarget handles /target [<chat>|off]: downloads the caller requests
+// in their private chat with the bot are posted to chat (a channel or group
+// they administer, where the bot may post) instead.
+func (bs *BotService) handleTarget(c tele.Context) error {
+	userID := c.Sender().ID
+	ref := strings.TrimSpace(c.Message().Payload)
+	switch strings.ToLower(ref) {
+	case "":
+		target := bs.targets.get(userID)
+		if target == 0 {
+			return c.Send("Your downloads arrive here. Send /target @yourchannel to have them posted to a channel or group you run.")
+		}
+		return c.Send(fmt.Sprintf("Downloads you request here are posted to chat %d. Send /target off to get them here again.", target))
+	case "off":
+		if err := bs.targets.set(userID, 0); err != nil {
+			return settingNotSaved(c)
+		}
+		return c.Send("Your downloads arrive here again.")
+	}
+
+	chat, err := bs.mirrorTarget(ref)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Can't find %s. Add me to it first.", ref))
+	}
+	if !bs.canMirrorTo(chat, c.Sender()) {
+		return c.Send(fmt.Sprintf("You can only target chats you administer, and %s isn't one.", chatLabel(chat)))
+	}
+	if !bs.canPost(chat) {
+		return c.Send(fmt.Sprintf("I can't post in %s. Make me an admin allowed to post messages there, then try again.", chatLabel(chat)))
+	}
+	if err := bs.targets.set(userID, chat.ID); err != nil {
+		return settingNotSaved(c)
+	}
+	logger.Info("Target chat set", "user", userID, "chat", chat.ID)
+	return c.Send(fmt.Sprintf("Downloads you request here will be posted to %s. Status updates stay here; /target off undoes it.", chatLabel(chat)))
+}
+
+// canPost reports whether the bot may send media to chat.
+func (bs *BotService) canPost(chat *tele.Chat) bool {
+	if chat.Type == tele.ChatPrivate {
+		return true
+	}
+	member, err := bs.bot.ChatMemberOf(chat, bs.bot.Me)
+	if err != nil {
+		logger.Debug("Failed to look up bot membership", "chat", chat.ID, "error", err)
+		return false
+	}
+	switch member.Role {
+	case tele.Creator:
+		return true
+	case tele.Administrator:
+		return chat.Type != tele.ChatChannel || member.CanPostMessages
+	case tele.Member:
+		return chat.Type != tele.ChatChannel
+	case tele.Restricted:
+		return member.CanSendMessages && member.CanSendVideos
+	}
+	return false
+}
+
+// applyTarget sends a job requested in a private chat to the requester's
+// /target chat, if they have one.
+func (bs *BotService) applyTarget(job *queue.Job) {
+	if job.ChatID == job.UserID {
+		job.TargetChatID = bs.targets.get(job.UserID)
+	}
+}
+
+// deliveryChat is where a job's results are uploaded: its target chat, else
+// the chat it was requested in.
+func deliveryChat(job *queue.Job) *tele.Chat {
+	if job.TargetChatID != 0 {
+		return &tele.Chat{ID: job.TargetChatID}
+	}
+	return jobChat(job)
+}
+
+// deliveryThread is the topic of deliveryChat that results go to.
+func deliveryThread(job *queue.Job) int {
+	if job.TargetChatID != 0 {
+		return 0
+	}
+	return job.ThreadID
+}
+
+// confirmDelivery tells the requester where the results of a job delivered
+// to a target chat went, since nothing arrives in their chat.
+func (bs *BotService) confirmDelivery(job *queue.Job) {
+	if job.TargetChatID == 0 {
+		return
+	}
+	text := fmt.Sprintf("✅ Posted to chat %d: %s", job.TargetChatID, job.URL)
+	if _, err := bs.bot.Send(jobChat(job), text, &tele.SendOptions{ThreadID: job.ThreadID, DisableWebPagePreview: true}); err != nil {
+		jobLog(job).Warn("Failed to confirm delivery", "error", err)
+	}
+}
@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// torrentApprovalTTL is how long a torrent request waits for an admin.
+const torrentApprovalTTL = 24 * time.Hour
+
+// messageLinks returns the links of a message to download: its http(s)
+// URLs, and its magnet links when torrents are enabled.
+func (bs *BotService) messageLinks(text string) []string {
+	urls := downloader.ExtractURLs(text)
+	if bs.engine.Torrents() {
+		urls = append(urls, downloader.ExtractMagnets(text)...)
+	}
+	return urls
+}
+
+// torrentHelp is the /help line on torrents, "" when they are disabled.
+func torrentHelp(enabled bool) string {
+	if !enabled {
+		return ""
+	}
+	return "- Magnet links and .torrent files: the largest video is sent, once a bot admin approves\n"
+}
+
+// approveTorrent queues a torrent job: right away for bot admins, after a
+// bot admin approves it for everyone else. Torrents are delivered as
+// videos whatever mode was asked for.
+func (bs *BotService) approveTorrent(job *queue.Job) error {
+	if !bs.engine.Torrents() {
+		_, err := bs.bot.Send(jobChat(job), "Torrent downloads aren't enabled on this bot.", &tele.SendOptions{ThreadID: job.ThreadID})
+		return err
+	}
+	job.Quality = ""
+	if bs.isAdmin(job.UserID) {
+		return bs.submit(job)
+	}
+
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		markup.Data("Approve", "torrentok", job.ID),
+		markup.Data("Decline", "torrentno", job.ID),
+	))
+	text := fmt.Sprintf("🧲 %s (%d) wants to download a torrent:\n%s", job.Username, job.UserID, job.URL)
+	asked := 0
+	for adminID := range bs.admins {
+		if _, err := bs.bot.Send(&tele.User{ID: adminID}, text, &tele.SendOptions{ReplyMarkup: markup, DisableWebPagePreview: true}); err != nil {
+			jobLog(job).Warn("Failed to ask admin to approve torrent", "admin", adminID, "error", err)
+			continue
+		}
+		asked++
+	}
+	if asked == 0 {
+		_, err := bs.bot.Send(jobChat(job), "Torrent downloads need a bot admin's approval, and none could be reached.", &tele.SendOptions{ThreadID: job.ThreadID})
+		return err
+	}
+
+	bs.torrentApprovals.add(job)
+	time.AfterFunc(torrentApprovalTTL, func() {
+		if bs.torrentApprovals.take(job.ID) != nil {
+			bs.bot.Send(jobChat(job), "No bot admin approved the torrent download in time.", &tele.SendOptions{ThreadID: job.ThreadID})
+		}
+	})
+	jobLog(job).Info("Torrent awaiting admin approval", "url", job.URL, "user", job.UserID)
+	_, err := bs.bot.Send(jobChat(job), "🧲 Torrent downloads need a bot admin's approval. I've asked them and will start once one does.",
+		&tele.SendOptions{ThreadID: job.ThreadID})
+	return err
+}
+
+// handleTorrentButton handles the approve and decline buttons sent to
+// bot admins for a torrent request. The first admin to answer decides.
+func (bs *BotService) handleTorrentButton(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return c.Respond(&tele.CallbackResponse{Text: "Only bot admins can do this", ShowAlert: true})
+	}
+	job := bs.torrentApprovals.take(c.Data())
+	if job == nil {
+		c.Edit(c.Message().Text + "\n\nAlready answered or expired.")
+		return c.Respond()
+	}
+
+	if c.Callback().Unique == "torrentno" {
+		c.Edit(c.Message().Text + "\n\n❌ Declined.")
+		bs.bot.Send(jobChat(job), "A bot admin declined the torrent download.", &tele.SendOptions{ThreadID: job.ThreadID})
+		jobLog(job).Info("Torrent declined", "by", c.Sender().ID)
+		return c.Respond()
+	}
+
+	// The requester may have used up their quota while the torrent waited
+	if !bs.quotaAllows(job) {
+		c.Edit(c.Message().Text + "\n\n✅ Approved, but the requester is over their daily quota now.")
+		jobLog(job).Info("Approved torrent over daily quota", "url", job.URL, "user", job.UserID)
+		bs.bot.Send(jobChat(job), "🧲 Your torrent download was approved but couldn't start. "+bs.quotaExceeded(job.UserID),
+			&tele.SendOptions{ThreadID: job.ThreadID})
+		return c.Respond(&tele.CallbackResponse{Text: "Requester is over their daily quota"})
+	}
+
+	c.Edit(c.Message().Text + "\n\n✅ Approved.")
+	if err := bs.submit(job); err != nil {
+		jobLog(job).Error("Failed to queue approved torrent", "error", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to queue download"})
+	}
+	jobLog(job).Info("Torrent approved", "by", c.Sender().ID)
+	return c.Respond(&tele.CallbackResponse{Text: "Download queued"})
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/config"
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/logger"
+	tele "gopkg.in/telebot.v3"
+)
+
+// updateTimeout bounds an /update run, downloads of the new release
+// included.
+const updateTimeout = 5 * time.Minute
+
+// handleUpdate handles /update for bot admins: updates yt-dlp with
+// SUSHE_UPDATE_COMMAND and reports the version before and after. Sites
+// break and get fixed in yt-dlp often, so this is the usual first step when
+// a site stops working.
+func (bs *BotService) handleUpdate(c tele.Context) error {
+	if roleOf(c) != RoleAdmin {
+		return nil
+	}
+	if !bs.updateRunning.CompareAndSwap(false, true) {
+		return c.Send("An update is already running.")
+	}
+	msg, err := bs.bot.Send(c.Chat(), "🔄 Updating yt-dlp...", &tele.SendOptions{ThreadID: c.Message().ThreadID})
+	if err != nil {
+		bs.updateRunning.Store(false)
+		return err
+	}
+	go bs.runUpdate(msg, c.Sender().ID)
+	return nil
+}
+
+// runUpdate runs the update and replaces msg with its outcome.
+func (bs *BotService) runUpdate(msg *tele.Message, adminID int64) {
+	defer bs.updateRunning.Store(false)
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+
+	before, _ := bs.engine.YtdlpVersion(ctx)
+	output, err := downloader.UpdateYtdlp(ctx, config.String("SUSHE_UPDATE_COMMAND", downloader.DefaultUpdateCommand))
+	if err != nil {
+		logger.Warn("yt-dlp update failed", "by", adminID, "error", err, "output", output)
+		bs.bot.Edit(msg, fmt.Sprintf("❌ Update failed: %v\n\n%s", err, trimOutput(output)))
+		return
+	}
+	after, err := bs.engine.YtdlpVersion(ctx)
+	if err != nil {
+		after = "unknown"
+	}
+	logger.Info("yt-dlp updated", "by", adminID, "from", before, "to", after)
+
+	text := fmt.Sprintf("✅ yt-dlp is up to date: %s", after)
+	if before != "" && before != after {
+		text = fmt.Sprintf("✅ yt-dlp updated: %s → %s", before, after)
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		text += "\n\n" + trimOutput(output)
+	}
+	bs.bot.Edit(msg, text)
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+// fileOfferTTL is how long the "send original as file" button stays usable.
+const fileOfferTTL = 24 * time.Hour
+
+// uploadProblem compares the message Telegram sent back with the uploaded
+// video and explains what was lost, or returns "" if it arrived intact.
+func uploadProblem(sent *tele.Message, result *engine.ProcessResult) string {
+	if sent == nil {
+		return ""
+	}
+	if sent.Video == nil {
+		return "Telegram delivered this as a file instead of a streamable video."
+	}
+	v := sent.Video
+	if v.Width > 0 && v.Height > 0 && result.Width > 0 && result.Height > 0 &&
+		(v.Width < result.Width || v.Height < result.Height) {
+		return fmt.Sprintf("Telegram shows this at %dx%d; the downloaded video is %dx%d.",
+			v.Width, v.Height, result.Width, result.Height)
+	}
+	return ""
+}
+
+// verifyUpload checks that a sent video kept its quality. If Telegram turned
+// it into a file or shrank it, a note is posted under it with a button to
+// get the original as a document (an /archive download of the same URL).
+func (bs *BotService) verifyUpload(job *queue.Job, sent *tele.Message, result *engine.ProcessResult) {
+	problem := uploadProblem(sent, result)
+	if problem == "" {
+		return
+	}
+	jobLog(job).Warn("Upload arrived degraded", "url", job.URL, "problem", problem)
+
+	offer := *job
+	offer.ID = queue.NewJobID()
+	offer.Quality = qualityArchive
+	offer.Oversize = ""
+	offer.StatusMsgID = 0
+
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data("Send original as file", "asfile", offer.ID)))
+	opts := &tele.SendOptions{ThreadID: deliveryThread(job), ReplyTo: sent, ReplyMarkup: markup}
+	if _, err := bs.bot.Send(deliveryChat(job), problem, opts); err != nil {
+		jobLog(job).Debug("Failed to send upload note", "error", err)
+		return
+	}
+
+	bs.fileOffers.add(&offer)
+	time.AfterFunc(fileOfferTTL, func() { bs.fileOffers.take(offer.ID) })
+}
+
+// handleAsFileButton queues the document version offered by verifyUpload.
+func (bs *BotService) handleAsFileButton(c tele.Context) error {
+	jobID := c.Callback().Data
+	job := bs.fileOffers.peek(jobID)
+	if job == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "This offer has expired"})
+	}
+	if job.UserID != c.Sender().ID {
+		return c.Respond(&tele.CallbackResponse{Text: "Only the requester can choose", ShowAlert: true})
+	}
+	if bs.fileOffers.take(jobID) == nil {
+		return c.Respond()
+	}
+	if msg := c.Message(); msg != nil {
+		bs.bot.EditReplyMarkup(msg, nil)
+	}
+
+	if err := bs.queue.Admit(job.UserID); err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: bs.rejection(err), ShowAlert: true})
+	}
+	if err := bs.dispatch(job); err != nil {
+		jobLog(job).Error("Failed to queue document version", "error", err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to queue download"})
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "Queued the original file"})
+}
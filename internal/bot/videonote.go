@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/engine"
+	"github.com/fitz123/sushe/internal/queue"
+	tele "gopkg.in/telebot.v3"
+)
+
+var noteUsage = fmt.Sprintf("Usage: /note <video URL> [<start> <end>]\n"+
+	"Sends up to %d seconds as a round video, from the start or the given range, e.g. /note https://youtu.be/... 1:00 1:30",
+	downloader.VideoNoteMaxDuration)
+
+// handleNote handles /note <url> [<start> <end>]: sends up to a minute of the
+// video, cropped to a square, as a round video note.
+func (bs *BotService) handleNote(c tele.Context) error {
+	return bs.enqueueRange(c, "/note", qualityNote, noteUsage, false)
+}
+
+// uploadVideoNote sends a note-mode result as a Telegram video note. Video
+// notes have no caption, so the title only shows in the status message.
+// Files are passed with upload.Sender.LocalFile.
+func (bs *BotService) uploadVideoNote(job *queue.Job, statusMsg *tele.Message, result *engine.ProcessResult) error {
+	bs.editStatus(job, statusMsg, fmt.Sprintf("Uploading...\n%s | %s",
+		result.Title, bs.locale.Size(result.FileSize)), cancelMarkup(job.ID))
+
+	note := &tele.VideoNote{
+		File:      bs.uploads.LocalFile(result.FilePath),
+		Duration:  int(result.Duration),
+		Length:    downloader.VideoNoteSize,
+		Thumbnail: bs.uploads.Thumbnail(result.ThumbnailPath),
+	}
+	sentMsg, err := bs.uploads.Send(deliveryChat(job), note, &tele.SendOptions{ThreadID: deliveryThread(job)})
+	if err != nil {
+		bs.editStatus(job, statusMsg, fmt.Sprintf("Failed to upload: %v", err))
+		return err
+	}
+	bs.rememberUpload(job, result, sentMsg)
+
+	bs.bot.Delete(statusMsg)
+
+	jobLog(job).Info("Successfully processed video note",
+		"title", result.Title,
+		"size", result.FileSize,
+		"user", job.Username,
+	)
+	return nil
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/fitz123/sushe/internal/config"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/secrets"
+	"github.com/fitz123/sushe/internal/webhook"
+)
+
+// webhookDrainTimeout is how long shutdown waits for queued webhook events.
+const webhookDrainTimeout = 5 * time.Second
+
+// newWebhookSender posts job events to SUSHE_WEBHOOK_URL, signed with
+// SUSHE_WEBHOOK_SECRET if set. Returns nil (webhooks off) if the URL is
+// unset or the secret can't be read.
+func newWebhookSender() *webhook.Sender {
+	url := config.String("SUSHE_WEBHOOK_URL", "")
+	if url == "" {
+		return nil
+	}
+	secret, err := secrets.Get("SUSHE_WEBHOOK_SECRET")
+	if err != nil {
+		logger.Error("Failed to read webhook secret, webhooks disabled", "error", err)
+		return nil
+	}
+	return webhook.New(webhook.Config{URL: url, Secret: secret})
+}
+
+// jobEvent builds a webhook event about job.
+func jobEvent(t webhook.Type, job *queue.Job) webhook.Event {
+	return webhook.Event{
+		Type:     t,
+		JobID:    job.ID,
+		URL:      job.URL,
+		ChatID:   job.ChatID,
+		UserID:   job.UserID,
+		Username: job.Username,
+	}
+}
+
+// emitPhase posts a job.phase event when the job enters a new pipeline
+// phase (starting, downloading, encoding, splitting, uploading, ...), not on
+// every progress update.
+func (bs *BotService) emitPhase(job *queue.Job, phase string) {
+	if bs.hooks == nil || !bs.phases.changeKind(job.ID, phase) {
+		return
+	}
+	e := jobEvent(webhook.PhaseChanged, job)
+	e.Phase = phase
+	bs.hooks.Emit(e)
+}
+
+// emitResult posts how a finished job ended. Jobs interrupted by shutdown
+// resume after the restart and report nothing.
+func (bs *BotService) emitResult(job *queue.Job, err error, cancelled, shutdown bool) {
+	switch {
+	case err == nil:
+		bs.hooks.Emit(jobEvent(webhook.Completed, job))
+	case cancelled:
+		bs.hooks.Emit(jobEvent(webhook.Cancelled, job))
+	case !shutdown:
+		e := jobEvent(webhook.Failed, job)
+		e.Error = err.Error()
+		bs.hooks.Emit(e)
+	}
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"github.com/fitz123/sushe/internal/changelog"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+	tele "gopkg.in/telebot.v3"
+)
+
+// whatsNewShown is how many releases /whatsnew lists.
+const whatsNewShown = 3
+
+// announcedState records the last changelog version broadcast to users.
+type announcedState struct {
+	Version string `json:"version"`
+}
+
+// handleWhatsNew shows the most recent changelog entries.
+func (bs *BotService) handleWhatsNew(c tele.Context) error {
+	entries := changelog.Entries
+	if len(entries) > whatsNewShown {
+		entries = entries[:whatsNewShown]
+	}
+	if len(entries) == 0 {
+		return c.Send("No changes recorded yet.")
+	}
+	return c.Send("What's new:\n\n" + changelog.Format(entries))
+}
+
+// sendUpdateAnnouncement sends allowed users the changelog entries added since the
+// last announcement, once per version. The first run only records the
+// current version, so a fresh install doesn't message anyone.
+func (bs *BotService) sendUpdateAnnouncement() {
+	latest := changelog.Latest()
+	if latest == "" {
+		return
+	}
+
+	path := store.Path("announced.json")
+	var state announcedState
+	if err := store.LoadJSON(path, &state); err != nil {
+		logger.Warn("Failed to load announcement state", "error", err)
+		return
+	}
+	if state.Version == latest {
+		return
+	}
+
+	if state.Version != "" {
+		text := "Sushe has been updated:\n\n" + changelog.Format(changelog.Since(state.Version)) +
+			"\n\nSee /help for all commands."
+		sent := 0
+		users := bs.allowedUsers.ids()
+		for id := range bs.admins {
+			if !bs.allowedUsers.allowed(id) {
+				users = append(users, id)
+			}
+		}
+		for _, userID := range users {
+			if _, err := bs.bot.Send(&tele.User{ID: userID}, text); err != nil {
+				logger.Warn("Failed to send update announcement", "user", userID, "error", err)
+				continue
+			}
+			sent++
+		}
+		logger.Info("Sent update announcement", "version", latest, "users", sent)
+	}
+
+	if err := store.SaveJSON(path, announcedState{Version: latest}); err != nil {
+		logger.Warn("Failed to save announcement state", "error", err)
+	}
+}
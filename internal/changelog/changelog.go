@@ -0,0 +1,111 @@
+// Package changelog is the user-facing list of bot changes, compiled into the
+// binary so /whatsnew and the post-upgrade announcement always match the
+// running version. Add an entry at the top when shipping a user-visible change.
+package changelog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Entry describes one release.
+type Entry struct {
+	Version string
+	Date    string // YYYY-MM-DD
+	Changes []string
+}
+
+// Entries lists releases, newest first.
+var Entries = []Entry{
+	{
+		Version: "1.2.0",
+		Date:    "2026-10-15",
+		Changes: []string{
+			"/spoiler: videos that look NSFW are hidden behind a spoiler in groups (chat admins can make it every video, or none)",
+			"Pause a running download from /queue when something urgent needs the bandwidth, and resume it later",
+			"/status shows whether the bot is taking downloads; during planned maintenance new downloads are declined with the expected end, while videos sent before still arrive",
+			"A YouTube clip of a video I already sent offers the full video instantly, or the clip as usual",
+			"/subs auto sends subtitles in your Telegram language, else the video's original language or English, and lists the languages a video has when none of those fit",
+			"The web dashboard (/dashboard of the HTTP API) shows downloads and their progress live",
+			"/fanout @channel1 @channel2 also posts the videos downloaded in a chat to those chats, uploading each only once",
+			"/target @yourchannel posts the videos you download in private chat to your channel",
+			"Links pasted inside brackets, markdown or right before punctuation are picked up correctly",
+			"/captions off sends your videos without any text; /captions parts keeps just the part numbers of split videos",
+			"/subscribe <channel> downloads a YouTube or Twitch channel's new uploads as they appear, here or into a chat you run",
+			"/later 22:00 <url> downloads a video at a later time and tells you when it's done",
+			"Links to an ongoing live stream record it for as long as you pick, then send the recording",
+			"/maxparts caps how many parts a large video is split into in a chat; longer videos are compressed to fit",
+			"Videos just over the size limit are compressed into one file instead of split",
+			"Direct links to video files (.mp4, .webm, .m3u8 streams, ...) download even when no site is behind them",
+			"Photo posts, Instagram carousels and Reddit galleries arrive as one album of all their photos and videos",
+			"On Telegram's public Bot API, videos are split or compressed into 50MB pieces up front instead of failing after a long upload",
+			"Large videos with chapters can be split on chapter boundaries, each part captioned with its chapter",
+			"Animated GIF/WebP links arrive as looping animations",
+			"Short clips without sound autoplay inline like GIFs",
+			"Twitter/X Spaces links are delivered as MP3, split into hour-long chapters when long",
+			"/dashboard pins a message with today's downloads, queue and cache hits for the chat",
+			"Videos and split parts come with a preview thumbnail instead of a grey square",
+			"/clip <url> <start> <end> downloads just the part of a video you want",
+			"Add \"stab\" after a link to stabilize shaky phone videos",
+			"/preset saves your favourite download settings under a name",
+			"/note sends a video as a round video message",
+			"/frames sends screenshots of a video as an album",
+			"/gif turns a short video, or part of one, into a looping GIF",
+			"Links with a timestamp (?t=) start the video from that point",
+			"/mirror <chat> posts a file the bot already sent into another chat instantly",
+			"/subs <language> sends subtitles as an .srt file with your videos; /subs <language> burn hardcodes them into the picture",
+			"Sending a link that was just found removed or private gets an instant answer instead of another attempt",
+			"If Telegram shrinks a video or turns it into a file, a note explains why and offers the original as a document",
+		},
+	},
+	{
+		Version: "1.1.0",
+		Date:    "2026-10-15",
+		Changes: []string{
+			"Downloads now run in a queue: /queue shows your position and estimated wait, /cancel stops a download",
+			"/audio <url> extracts MP3, /voice <url> sends a voice message, /archive <url> keeps all audio and subtitle tracks in an MKV",
+			"Links from common shorteners (bit.ly, t.co, ...) are expanded automatically",
+			"Links someone already downloaded are sent instantly from cache",
+			"Playlists show overall progress",
+			"/whatsnew shows this list",
+		},
+	},
+}
+
+// Latest returns the newest version, or "" if there are no entries.
+func Latest() string {
+	if len(Entries) == 0 {
+		return ""
+	}
+	return Entries[0].Version
+}
+
+// Since returns the entries newer than version, newest first. An unknown or
+// empty version returns only the latest entry, so a first announcement
+// doesn't replay the whole history.
+func Since(version string) []Entry {
+	for i, e := range Entries {
+		if e.Version == version {
+			return Entries[:i]
+		}
+	}
+	if len(Entries) == 0 {
+		return nil
+	}
+	return Entries[:1]
+}
+
+// Format renders entries as a plain-text message.
+func Format(entries []Entry) string {
+	var b strings.Builder
+	for i, e := range entries {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "Version %s (%s)\n", e.Version, e.Date)
+		for _, c := range e.Changes {
+			fmt.Fprintf(&b, "• %s\n", c)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,43 @@
+package changelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withEntries(t *testing.T, entries []Entry) {
+	saved := Entries
+	Entries = entries
+	t.Cleanup(func() { Entries = saved })
+}
+
+func TestSince(t *testing.T) {
+	withEntries(t, []Entry{{Version: "1.2.0"}, {Version: "1.1.0"}, {Version: "1.0.0"}})
+
+	assert.Equal(t, "1.2.0", Latest())
+	assert.Len(t, Since("1.0.0"), 2)
+	assert.Empty(t, Since("1.2.0"))
+	// Unknown versions get only the latest entry
+	assert.Equal(t, []Entry{{Version: "1.2.0"}}, Since(""))
+	assert.Equal(t, []Entry{{Version: "1.2.0"}}, Since("0.9.0"))
+}
+
+func TestFormat(t *testing.T) {
+	text := Format([]Entry{
+		{Version: "1.1.0", Date: "2026-10-15", Changes: []string{"a", "b"}},
+		{Version: "1.0.0", Date: "2026-01-01", Changes: []string{"c"}},
+	})
+	assert.Equal(t, "Version 1.1.0 (2026-10-15)\n• a\n• b\n\nVersion 1.0.0 (2026-01-01)\n• c", text)
+}
+
+func TestEntriesAreWellFormed(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, e := range Entries {
+		assert.NotEmpty(t, e.Version)
+		assert.Regexp(t, `^\d{4}-\d{2}-\d{2}$`, e.Date)
+		assert.NotEmpty(t, e.Changes, e.Version)
+		assert.False(t, seen[e.Version], "duplicate version %s", e.Version)
+		seen[e.Version] = true
+	}
+}
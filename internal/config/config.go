@@ -0,0 +1,83 @@
+// Package config reads optional settings from environment variables.
+// Invalid values are logged and replaced by the default, so a typo in the
+// service unit never prevents the bot from starting.
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// String returns the trimmed value of key, or def if unset or empty.
+func String(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int returns key parsed as an integer, or def if unset or invalid.
+func Int(key string, def int) int {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		logger.Warn("Invalid integer in environment, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// Float returns key parsed as a decimal number ("1.5"), or def if unset or
+// invalid.
+func Float(key string, def float64) float64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		logger.Warn("Invalid number in environment, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return f
+}
+
+// Bool returns key parsed as a boolean (1/true/yes/on), or def if unset or invalid.
+func Bool(key string, def bool) bool {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
+	switch v {
+	case "":
+		return def
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	logger.Warn("Invalid boolean in environment, using default", "key", key, "value", v, "default", def)
+	return def
+}
+
+// Duration returns key parsed as a Go duration ("90s", "15m") or a plain
+// number of seconds, or def if unset or invalid.
+func Duration(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logger.Warn("Invalid duration in environment, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return d
+}
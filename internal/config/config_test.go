@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	logger.Init("error")
+}
+
+func TestString(t *testing.T) {
+	t.Setenv("SUSHE_TEST_STR", "  value ")
+	assert.Equal(t, "value", String("SUSHE_TEST_STR", "def"))
+	assert.Equal(t, "def", String("SUSHE_TEST_UNSET", "def"))
+}
+
+func TestInt(t *testing.T) {
+	t.Setenv("SUSHE_TEST_INT", "4")
+	assert.Equal(t, 4, Int("SUSHE_TEST_INT", 2))
+
+	t.Setenv("SUSHE_TEST_INT", "four")
+	assert.Equal(t, 2, Int("SUSHE_TEST_INT", 2))
+	assert.Equal(t, 2, Int("SUSHE_TEST_UNSET", 2))
+}
+
+func TestFloat(t *testing.T) {
+	t.Setenv("SUSHE_TEST_FLOAT", "1.5")
+	assert.Equal(t, 1.5, Float("SUSHE_TEST_FLOAT", 2))
+
+	t.Setenv("SUSHE_TEST_FLOAT", "3")
+	assert.Equal(t, 3.0, Float("SUSHE_TEST_FLOAT", 2))
+
+	t.Setenv("SUSHE_TEST_FLOAT", "lots")
+	assert.Equal(t, 2.0, Float("SUSHE_TEST_FLOAT", 2))
+}
+
+func TestBool(t *testing.T) {
+	tests := []struct {
+		value string
+		def   bool
+		want  bool
+	}{
+		{"1", false, true},
+		{"yes", false, true},
+		{"off", true, false},
+		{"", true, true},
+		{"maybe", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("SUSHE_TEST_BOOL", tt.value)
+			assert.Equal(t, tt.want, Bool("SUSHE_TEST_BOOL", tt.def))
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	t.Setenv("SUSHE_TEST_DUR", "90")
+	assert.Equal(t, 90*time.Second, Duration("SUSHE_TEST_DUR", time.Minute))
+
+	t.Setenv("SUSHE_TEST_DUR", "15m")
+	assert.Equal(t, 15*time.Minute, Duration("SUSHE_TEST_DUR", time.Minute))
+
+	t.Setenv("SUSHE_TEST_DUR", "soon")
+	assert.Equal(t, time.Minute, Duration("SUSHE_TEST_DUR", time.Minute))
+}
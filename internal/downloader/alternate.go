@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+var (
+	ogTitleRe    = regexp.MustCompile(`(?is)<meta[^>]+property=["']og:title["'][^>]*content=["']([^"']+)["']`)
+	ogTitleRevRe = regexp.MustCompile(`(?is)<meta[^>]+content=["']([^"']+)["'][^>]*property=["']og:title["']`)
+	htmlTitleRe  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// PageTitle downloads the start of an HTML page and returns its og:title
+// (or <title>). Used to look up alternative sources of videos that yt-dlp
+// can't fetch. The page's redirects are followed with Resolve, so like a
+// short link it can't lead to a blocked host or a private address.
+func (u *Unshortener) PageTitle(ctx context.Context, pageURL string) (string, error) {
+	final, err := u.Resolve(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, final, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; sushe-bot)")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch page: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 512*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read page: %w", err)
+	}
+
+	title := extractPageTitle(string(body))
+	if title == "" {
+		return "", fmt.Errorf("no title found on page")
+	}
+	return title, nil
+}
+
+// extractPageTitle returns the og:title meta tag, falling back to <title>.
+func extractPageTitle(page string) string {
+	for _, re := range []*regexp.Regexp{ogTitleRe, ogTitleRevRe, htmlTitleRe} {
+		if m := re.FindStringSubmatch(page); m != nil {
+			title := strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+			if title != "" {
+				return title
+			}
+		}
+	}
+	return ""
+}
+
+// SearchYouTube returns the top YouTube search result for query.
+func (d *Downloader) SearchYouTube(ctx context.Context, query string) (*PlaylistEntry, error) {
+	args := []string{
+		"--flat-playlist",
+		"--dump-json",
+		"--no-warnings",
+		"ytsearch1:" + query,
+	}
+
+	logger.FromContext(ctx).Debug("Searching YouTube", "query", query)
+
+	cmd := d.ytdlp(ctx, args...)
+	output, err := cmd.Output()
+	d.ytdlpDone(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("youtube search failed: %w", err)
+	}
+
+	line := strings.TrimSpace(string(output))
+	if line == "" {
+		return nil, fmt.Errorf("no search results")
+	}
+
+	var entry PlaylistEntry
+	if err := json.Unmarshal([]byte(strings.SplitN(line, "\n", 2)[0]), &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse search result: %w", err)
+	}
+	if entry.ID == "" {
+		return nil, fmt.Errorf("no search results")
+	}
+	if entry.URL == "" {
+		entry.URL = "https://www.youtube.com/watch?v=" + entry.ID
+	}
+	return &entry, nil
+}
@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractPageTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{"og:title", `<html><head><title>Site | Ignored</title><meta property="og:title" content="Storm hits coast &amp; city"></head>`, "Storm hits coast & city"},
+		{"og:title content first", `<meta content='Reversed order' property='og:title'/>`, "Reversed order"},
+		{"title fallback", "<html><head><title>\n  Plain   title\n</title></head>", "Plain title"},
+		{"nothing", "<html><body>no title</body></html>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractPageTitle(tt.page); got != tt.want {
+				t.Errorf("extractPageTitle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageTitle(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "https://evil.example/x", http.StatusFound)
+			return
+		}
+		w.Write([]byte(`<html><head><title>Storm hits coast</title></head></html>`))
+	}))
+	defer srv.Close()
+
+	// Loopback is refused by default, like any private address
+	u := NewUnshortener([]string{"evil.example"})
+	if _, err := u.PageTitle(context.Background(), srv.URL); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("loopback: err = %v, want ErrBlockedURL", err)
+	}
+
+	u.allowPrivate = true
+	title, err := u.PageTitle(context.Background(), srv.URL)
+	if err != nil || title != "Storm hits coast" {
+		t.Errorf("PageTitle() = %q, %v", title, err)
+	}
+	if _, err := u.PageTitle(context.Background(), srv.URL+"/moved"); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("blocked redirect: err = %v, want ErrBlockedURL", err)
+	}
+}
@@ -0,0 +1,178 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// MaxAnimationDuration is the longest video, in seconds, that
+	// VideoToAnimation converts; longer ones need a time range.
+	MaxAnimationDuration = 60.0
+
+	// gifMaxDuration is the longest clip, in seconds, rendered as a real GIF
+	// rather than an MP4 animation.
+	gifMaxDuration = 4.0
+
+	// maxGIFSize is the largest GIF kept; bigger ones fall back to MP4.
+	maxGIFSize = 8 << 20
+
+	// animationMaxWidth caps the width of converted animations.
+	animationMaxWidth = 720
+)
+
+// ErrAnimationTooLong is returned by VideoToAnimation for videos over
+// MaxAnimationDuration.
+var ErrAnimationTooLong = errors.New("video too long for an animation")
+
+// animatedImageCodecs are ffprobe codec names of animated image formats.
+var animatedImageCodecs = map[string]bool{
+	"gif":  true,
+	"webp": true,
+	"apng": true,
+}
+
+// IsAnimatedImage reports whether filePath is an animated image (GIF, WebP,
+// APNG) rather than a video, judged by its extension or probed codec.
+func (d *Downloader) IsAnimatedImage(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".gif", ".webp", ".apng":
+		return true
+	}
+	codec, err := d.GetVideoCodec(filePath)
+	return err == nil && animatedImageCodecs[codec]
+}
+
+// ConvertAnimation converts an animated image to a silent H.264 MP4, the
+// format Telegram plays inline as an animation. Returns the new file's path.
+func (d *Downloader) ConvertAnimation(ctx context.Context, filePath string) (string, error) {
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(dir, baseName+"_anim.mp4")
+
+	args := []string{
+		"-i", filePath,
+		"-an",
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		// yuv420p needs even dimensions
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+		"-movflags", "+faststart",
+		"-y",
+		outPath,
+	}
+	logger.FromContext(ctx).Debug("Running ffmpeg animation conversion", "args", args)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Error("ffmpeg animation conversion failed", "error", err, "output", string(output))
+		return "", fmt.Errorf("failed to convert animation: %w", err)
+	}
+	return outPath, nil
+}
+
+// VideoToAnimation converts a short video to a silent animation: a
+// palette-optimized GIF for clips up to gifMaxDuration (when it stays under
+// maxGIFSize), an H.264 MP4 without audio otherwise. Returns the new file's
+// path, or ErrAnimationTooLong.
+func (d *Downloader) VideoToAnimation(ctx context.Context, filePath string) (string, error) {
+	info, err := d.GetMediaInfo(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get media info: %w", err)
+	}
+	if info.Duration > MaxAnimationDuration {
+		return "", fmt.Errorf("%w (%.0fs)", ErrAnimationTooLong, info.Duration)
+	}
+
+	if info.Duration > 0 && info.Duration <= gifMaxDuration {
+		gifPath, err := d.renderGIF(ctx, filePath)
+		if err == nil {
+			if st, statErr := os.Stat(gifPath); statErr == nil && st.Size() <= maxGIFSize {
+				return gifPath, nil
+			}
+			logger.FromContext(ctx).Info("GIF too large, falling back to MP4 animation", "file", filePath)
+			os.Remove(gifPath)
+		} else {
+			logger.FromContext(ctx).Warn("GIF rendering failed, falling back to MP4 animation", "error", err)
+		}
+	}
+
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(dir, baseName+"_anim.mp4")
+	args := []string{
+		"-i", filePath,
+		"-an",
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-vf", fmt.Sprintf("scale='min(%d,iw)':-2", animationMaxWidth),
+		"-movflags", "+faststart",
+		"-y",
+		outPath,
+	}
+	logger.FromContext(ctx).Debug("Running ffmpeg video to animation", "args", args)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Error("ffmpeg video to animation failed", "error", err, "output", string(output))
+		return "", fmt.Errorf("failed to convert video to animation: %w", err)
+	}
+	return outPath, nil
+}
+
+// renderGIF renders filePath as a GIF with a palette generated from the clip
+// itself, which looks far better than ffmpeg's default palette.
+func (d *Downloader) renderGIF(ctx context.Context, filePath string) (string, error) {
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(dir, baseName+".gif")
+
+	cmd := d.command(ctx, "ffmpeg", gifArgs(filePath, outPath)...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Debug("ffmpeg GIF rendering failed", "error", err, "output", string(output))
+		return "", fmt.Errorf("failed to render GIF: %w", err)
+	}
+	return outPath, nil
+}
+
+// gifArgs returns the ffmpeg arguments for a palette-optimized GIF: 15 fps,
+// at most 480px wide, palettegen and paletteuse in a single pass.
+func gifArgs(input, output string) []string {
+	filter := "fps=15,scale='min(480,iw)':-1:flags=lanczos,split[a][b];" +
+		"[a]palettegen=stats_mode=diff[p];[b][p]paletteuse=dither=bayer:bayer_scale=5"
+	return []string{"-i", input, "-an", "-filter_complex", filter, "-loop", "0", "-y", output}
+}
+
+// animationResult describes a converted animation at path.
+func (d *Downloader) animationResult(path, title string) (*DownloadResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat animation: %w", err)
+	}
+	result := &DownloadResult{
+		FilePath:    path,
+		FileName:    filepath.Base(path),
+		Title:       title,
+		FileSize:    info.Size(),
+		ContentType: getContentType(path),
+		IsAnimation: true,
+	}
+	if mediaInfo, _ := d.GetMediaInfo(path); mediaInfo != nil {
+		result.Duration = mediaInfo.Duration
+		result.Width = mediaInfo.Width
+		result.Height = mediaInfo.Height
+	}
+	return result, nil
+}
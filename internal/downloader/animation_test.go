@@ -0,0 +1,32 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsAnimatedImageByExtension(t *testing.T) {
+	d := &Downloader{}
+	for _, name := range []string{"clip.gif", "clip.GIF", "clip.webp", "clip.apng"} {
+		if !d.IsAnimatedImage("/nonexistent/" + name) {
+			t.Errorf("IsAnimatedImage(%q) = false, want true", name)
+		}
+	}
+	// Unknown files fall back to probing, which fails for a missing file
+	if d.IsAnimatedImage("/nonexistent/clip.mp4") {
+		t.Error("IsAnimatedImage(clip.mp4) = true, want false")
+	}
+}
+
+func TestGIFArgs(t *testing.T) {
+	args := gifArgs("/tmp/in.mp4", "/tmp/in.gif")
+	joined := strings.Join(args, " ")
+	for _, want := range []string{"palettegen", "paletteuse", "-an", "/tmp/in.gif"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("gifArgs = %v, missing %s", args, want)
+		}
+	}
+	if got := getContentType("/tmp/in.gif"); got != "image/gif" {
+		t.Errorf("getContentType(.gif) = %q, want image/gif", got)
+	}
+}
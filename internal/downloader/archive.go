@@ -0,0 +1,82 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// SplitArchive splits an archive-mode MKV into parts of approximately
+// the Split limit. Unlike SplitVideo it always stream-copies and maps every
+// stream, so each part keeps all audio and subtitle tracks.
+func (d *Downloader) SplitArchive(ctx context.Context, filePath string, progressCb ProgressCallback) ([]PartInfo, error) {
+	mediaInfo, err := d.GetMediaInfo(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media info: %w", err)
+	}
+	if mediaInfo.Duration <= 0 {
+		return nil, fmt.Errorf("invalid video duration: %f", mediaInfo.Duration)
+	}
+
+	// Parts together take about as much space as the source
+	if err := ensureFreeSpace(filepath.Dir(filePath), mediaInfo.FileSize); err != nil {
+		return nil, err
+	}
+
+	numParts := d.limits.CalculateNumParts(mediaInfo.FileSize)
+	segmentDuration := mediaInfo.Duration / float64(numParts)
+
+	logger.FromContext(ctx).Info("Splitting archive",
+		"fileSize", mediaInfo.FileSize,
+		"duration", mediaInfo.Duration,
+		"numParts", numParts,
+	)
+	if progressCb != nil {
+		progressCb(Progress{Phase: "splitting", PartNum: 1, TotalParts: numParts})
+	}
+
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	args := []string{
+		"-i", filePath,
+		"-map", "0",
+		"-c", "copy",
+		"-f", "segment",
+		"-segment_time", fmt.Sprintf("%.2f", segmentDuration),
+		"-reset_timestamps", "1",
+		"-y",
+		filepath.Join(dir, baseName+"_part%03d.mkv"),
+	}
+	logger.FromContext(ctx).Debug("Running ffmpeg archive split", "args", args)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Error("ffmpeg archive split failed", "error", err, "output", string(output))
+		return nil, fmt.Errorf("ffmpeg archive split failed: %w", err)
+	}
+
+	partFiles, err := filepath.Glob(filepath.Join(dir, baseName+"_part*.mkv"))
+	if err != nil || len(partFiles) == 0 {
+		return nil, fmt.Errorf("no split parts found")
+	}
+	sort.Strings(partFiles)
+
+	var parts []PartInfo
+	for i, partFile := range partFiles {
+		info, err := os.Stat(partFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat archive part: %w", err)
+		}
+		parts = append(parts, PartInfo{FilePath: partFile, PartNum: i + 1, FileSize: info.Size()})
+	}
+
+	logger.FromContext(ctx).Info("Archive split complete", "numParts", len(parts))
+	return parts, nil
+}
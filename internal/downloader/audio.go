@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// MaxAudioDuration is the longest audio file sent as a single track.
+	// Longer extractions (audiobooks, long podcasts) are split into chapters.
+	MaxAudioDuration = 90 * time.Minute
+	// AudioChapterDuration is the target length of each audio chapter.
+	AudioChapterDuration = 60 * time.Minute
+)
+
+// AudioSegment is a time range of a long audio file.
+type AudioSegment struct {
+	Start    float64 // seconds
+	Duration float64 // seconds
+}
+
+// NeedsAudioSplit returns true if audio of the given duration (seconds)
+// should be split into chapters.
+func NeedsAudioSplit(duration float64) bool {
+	return duration > MaxAudioDuration.Seconds()
+}
+
+// AudioSegments divides duration (seconds) into equal segments of at most
+// chapter length, so the last chapter is not a short leftover.
+func AudioSegments(duration float64, chapter time.Duration) []AudioSegment {
+	if duration <= 0 || chapter <= 0 {
+		return nil
+	}
+	n := int(math.Ceil(duration / chapter.Seconds()))
+	length := duration / float64(n)
+
+	segments := make([]AudioSegment, n)
+	for i := range segments {
+		segments[i] = AudioSegment{Start: float64(i) * length, Duration: length}
+	}
+	// Let the last segment run to the end to absorb rounding
+	segments[n-1].Duration = duration - segments[n-1].Start
+	return segments
+}
+
+// SplitAudio splits a long audio file into chapter-sized files using stream
+// copy. Each part is tagged with a sequential track number (i/N) and a
+// "title (Part i/N)" title so players keep them in order.
+func (d *Downloader) SplitAudio(ctx context.Context, filePath, title string, progressCb ProgressCallback) ([]PartInfo, error) {
+	mediaInfo, err := d.GetMediaInfo(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media info: %w", err)
+	}
+	segments := AudioSegments(mediaInfo.Duration, AudioChapterDuration)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid audio duration: %f", mediaInfo.Duration)
+	}
+
+	// Parts together take about as much space as the source
+	if err := ensureFreeSpace(filepath.Dir(filePath), mediaInfo.FileSize); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("Splitting audio",
+		"duration", mediaInfo.Duration,
+		"numParts", len(segments),
+		"segmentDuration", segments[0].Duration,
+	)
+
+	dir := filepath.Dir(filePath)
+	ext := filepath.Ext(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), ext)
+
+	var parts []PartInfo
+	for i, seg := range segments {
+		partNum := i + 1
+		if progressCb != nil {
+			progressCb(Progress{
+				Phase:      "splitting",
+				Percent:    float64(i) / float64(len(segments)) * 100,
+				PartNum:    partNum,
+				TotalParts: len(segments),
+			})
+		}
+
+		outPath := filepath.Join(dir, fmt.Sprintf("%s_part%03d%s", baseName, partNum, ext))
+		args := []string{
+			"-ss", fmt.Sprintf("%.2f", seg.Start),
+			"-t", fmt.Sprintf("%.2f", seg.Duration),
+			"-i", filePath,
+			"-map", "0:a",
+			"-c", "copy",
+			"-metadata", fmt.Sprintf("track=%d/%d", partNum, len(segments)),
+			"-metadata", fmt.Sprintf("title=%s (Part %d/%d)", title, partNum, len(segments)),
+			"-y",
+			outPath,
+		}
+		logger.FromContext(ctx).Debug("Running ffmpeg audio split", "args", args)
+
+		cmd := d.command(ctx, "ffmpeg", args...)
+		output, err := combinedOutput(ctx, cmd)
+		recordUsage(ctx, cmd)
+		if err != nil {
+			logger.FromContext(ctx).Error("ffmpeg audio split failed", "part", partNum, "error", err, "output", string(output))
+			for _, p := range parts {
+				os.Remove(p.FilePath)
+			}
+			return nil, fmt.Errorf("ffmpeg audio split failed on part %d: %w", partNum, err)
+		}
+
+		info, err := os.Stat(outPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat audio part %d: %w", partNum, err)
+		}
+		parts = append(parts, PartInfo{FilePath: outPath, PartNum: partNum, FileSize: info.Size()})
+	}
+
+	logger.FromContext(ctx).Info("Audio split complete", "numParts", len(parts))
+	return parts, nil
+}
@@ -0,0 +1,53 @@
+package downloader
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNeedsAudioSplit(t *testing.T) {
+	if NeedsAudioSplit(MaxAudioDuration.Seconds()) {
+		t.Error("audio exactly at MaxAudioDuration should not be split")
+	}
+	if !NeedsAudioSplit(MaxAudioDuration.Seconds() + 1) {
+		t.Error("audio over MaxAudioDuration should be split")
+	}
+}
+
+func TestAudioSegments(t *testing.T) {
+	tests := []struct {
+		name      string
+		duration  float64
+		wantParts int
+	}{
+		{"shorter than a chapter", 1800, 1},
+		{"exactly one chapter", 3600, 1},
+		{"just over one chapter", 3601, 2},
+		{"10 hour audiobook", 36000, 10},
+		{"zero duration", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := AudioSegments(tt.duration, time.Hour)
+			if len(segments) != tt.wantParts {
+				t.Fatalf("AudioSegments(%v) returned %d segments, want %d", tt.duration, len(segments), tt.wantParts)
+			}
+
+			var total float64
+			for i, seg := range segments {
+				if seg.Duration > time.Hour.Seconds()+0.001 {
+					t.Errorf("segment %d is %.1fs, longer than a chapter", i, seg.Duration)
+				}
+				if math.Abs(seg.Start-total) > 0.001 {
+					t.Errorf("segment %d starts at %.2f, want %.2f", i, seg.Start, total)
+				}
+				total += seg.Duration
+			}
+			if math.Abs(total-tt.duration) > 0.001 {
+				t.Errorf("segments cover %.2fs, want %.2fs", total, tt.duration)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package downloader
+
+import "regexp"
+
+// audioRoomRe matches recordings of live audio rooms (Twitter/X Spaces),
+// which are audio-only HLS streams often several hours long.
+var audioRoomRe = regexp.MustCompile(`^https?://(?:(?:www|mobile)\.)?(?:twitter|x)\.com/i/spaces/[A-Za-z0-9]+`)
+
+// audioRoomFragments is the number of HLS fragments fetched in parallel for
+// audio rooms; their playlists have thousands of tiny segments.
+const audioRoomFragments = "8"
+
+// IsAudioRoom reports whether url is an audio room recording that should go
+// through the audio pipeline instead of the video one.
+func IsAudioRoom(url string) bool {
+	return audioRoomRe.MatchString(url)
+}
@@ -0,0 +1,22 @@
+package downloader
+
+import "testing"
+
+func TestIsAudioRoom(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://twitter.com/i/spaces/1eaKbrPAqbwKX", true},
+		{"https://x.com/i/spaces/1eaKbrPAqbwKX?s=20", true},
+		{"https://mobile.twitter.com/i/spaces/1eaKbrPAqbwKX", true},
+		{"https://x.com/someone/status/123456", false},
+		{"https://www.youtube.com/watch?v=abc", false},
+		{"https://evil.example/?u=https://x.com/i/spaces/abc", false},
+	}
+	for _, tt := range tests {
+		if got := IsAudioRoom(tt.url); got != tt.want {
+			t.Errorf("IsAudioRoom(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package downloader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BandwidthWindow limits downloads to Rate bytes/s (0 = unlimited) between
+// Start and End, given as time of day. A window may wrap past midnight.
+type BandwidthWindow struct {
+	Start, End time.Duration
+	Rate       int64
+}
+
+// contains reports whether the time of day tod falls in the window.
+func (w BandwidthWindow) contains(tod time.Duration) bool {
+	if w.Start <= w.End {
+		return tod >= w.Start && tod < w.End
+	}
+	return tod >= w.Start || tod < w.End
+}
+
+// BandwidthSchedule picks the download rate limit by time of day: the first
+// window containing the time wins, Default applies outside all windows.
+type BandwidthSchedule struct {
+	Windows []BandwidthWindow
+	Default int64
+}
+
+// RateAt returns the limit in bytes/s at t (in t's location), 0 if unlimited.
+func (s BandwidthSchedule) RateAt(t time.Time) int64 {
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	for _, w := range s.Windows {
+		if w.contains(tod) {
+			return w.Rate
+		}
+	}
+	return s.Default
+}
+
+// ParseBandwidthSchedule parses comma-separated rules "HH:MM-HH:MM=RATE",
+// plus an optional "*=RATE" for the rest of the day, e.g.
+// "01:00-08:00=0,*=2M" (full speed at night, 2 MiB/s otherwise). RATE is
+// bytes per second with an optional K/M/G suffix; 0 or "unlimited" means no
+// limit.
+func ParseBandwidthSchedule(s string) (BandwidthSchedule, error) {
+	var sched BandwidthSchedule
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		span, rateStr, ok := strings.Cut(rule, "=")
+		if !ok {
+			return BandwidthSchedule{}, fmt.Errorf("bandwidth rule %q: want HH:MM-HH:MM=RATE or *=RATE", rule)
+		}
+		rate, err := parseRate(rateStr)
+		if err != nil {
+			return BandwidthSchedule{}, fmt.Errorf("bandwidth rule %q: %w", rule, err)
+		}
+		span = strings.TrimSpace(span)
+		if span == "*" {
+			sched.Default = rate
+			continue
+		}
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return BandwidthSchedule{}, fmt.Errorf("bandwidth rule %q: want HH:MM-HH:MM", rule)
+		}
+		start, err := parseTimeOfDay(from)
+		if err != nil {
+			return BandwidthSchedule{}, fmt.Errorf("bandwidth rule %q: %w", rule, err)
+		}
+		end, err := parseTimeOfDay(to)
+		if err != nil {
+			return BandwidthSchedule{}, fmt.Errorf("bandwidth rule %q: %w", rule, err)
+		}
+		sched.Windows = append(sched.Windows, BandwidthWindow{Start: start, End: end, Rate: rate})
+	}
+	return sched, nil
+}
+
+// parseTimeOfDay parses "HH:MM" ("24:00" is the end of the day).
+func parseTimeOfDay(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseRate parses a rate such as "500K", "2M" or "2MB/s" into bytes per
+// second (binary multiples, as yt-dlp uses).
+func parseRate(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if v == "UNLIMITED" {
+		return 0, nil
+	}
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "/S"), "B")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(v, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(v, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(v, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// SetBandwidthSchedule limits yt-dlp's download rate by time of day. The
+// rate is picked when each yt-dlp run starts and kept for that run.
+func (d *Downloader) SetBandwidthSchedule(s BandwidthSchedule) {
+	d.bandwidth = s
+}
+
+// limitRateArgs returns the yt-dlp --limit-rate flag for the current time,
+// or nothing when downloads are unlimited.
+func (d *Downloader) limitRateArgs(now time.Time) []string {
+	rate := d.bandwidth.RateAt(now)
+	if rate <= 0 {
+		return nil
+	}
+	return []string{"--limit-rate", strconv.FormatInt(rate, 10)}
+}
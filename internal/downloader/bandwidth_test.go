@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBandwidthSchedule(t *testing.T) {
+	sched, err := ParseBandwidthSchedule("01:00-08:00=0, 22:00-01:00=512K, *=2M")
+	if err != nil {
+		t.Fatalf("ParseBandwidthSchedule: %v", err)
+	}
+
+	at := func(hhmm string) time.Time {
+		tod, _ := time.Parse("15:04", hhmm)
+		return time.Date(2024, 5, 1, tod.Hour(), tod.Minute(), 0, 0, time.Local)
+	}
+	tests := []struct {
+		at   string
+		want int64
+	}{
+		{"03:00", 0},
+		{"08:00", 2 << 20},
+		{"12:30", 2 << 20},
+		{"23:00", 512 << 10},
+		{"00:30", 512 << 10},
+		{"01:00", 0},
+	}
+	for _, tt := range tests {
+		if got := sched.RateAt(at(tt.at)); got != tt.want {
+			t.Errorf("RateAt(%s) = %d, want %d", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestParseBandwidthScheduleErrors(t *testing.T) {
+	for _, spec := range []string{"01:00-08:00", "1-8=2M", "01:00-08:00=fast", "*=-1"} {
+		if _, err := ParseBandwidthSchedule(spec); err == nil {
+			t.Errorf("ParseBandwidthSchedule(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := map[string]int64{"2M": 2 << 20, "1.5m": 3 << 19, "500KB/s": 500 << 10, "1000": 1000, "unlimited": 0, "0": 0}
+	for in, want := range tests {
+		if got, err := parseRate(in); err != nil || got != want {
+			t.Errorf("parseRate(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+}
+
+func TestLimitRateArgs(t *testing.T) {
+	d := &Downloader{}
+	if args := d.limitRateArgs(time.Now()); len(args) != 0 {
+		t.Errorf("limitRateArgs without schedule = %v, want none", args)
+	}
+	d.SetBandwidthSchedule(BandwidthSchedule{Default: 2 << 20})
+	if got := strings.Join(d.limitRateArgs(time.Now()), " "); got != "--limit-rate 2097152" {
+		t.Errorf("limitRateArgs = %q, want --limit-rate 2097152", got)
+	}
+}
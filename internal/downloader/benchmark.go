@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// BenchmarkDuration is the length of the /benchmark test clip in seconds.
+const BenchmarkDuration = 30
+
+// EncoderProfile is one H.264 encoder setting tried by Benchmark.
+type EncoderProfile struct {
+	Encoder string   // ffmpeg encoder name, e.g. libx264 or h264_nvenc
+	Preset  string   // "" for encoders without presets
+	Args    []string // rate control and device options next to -c:v
+	Input   []string // options before -i (hardware devices)
+}
+
+// Name is how the profile is shown: "libx264 fast".
+func (p EncoderProfile) Name() string {
+	if p.Preset == "" {
+		return p.Encoder
+	}
+	return p.Encoder + " " + p.Preset
+}
+
+// benchmarkProfiles are the candidates, fastest preset of each encoder
+// first. The pipeline uses libx264 fast for re-encodes and medium for
+// compression; hardware encoders are tried if ffmpeg lists them.
+var benchmarkProfiles = []EncoderProfile{
+	{Encoder: "libx264", Preset: "ultrafast", Args: []string{"-crf", "23"}},
+	{Encoder: "libx264", Preset: "veryfast", Args: []string{"-crf", "23"}},
+	{Encoder: "libx264", Preset: "fast", Args: []string{"-crf", "23"}},
+	{Encoder: "libx264", Preset: "medium", Args: []string{"-crf", "23"}},
+	{Encoder: "libx264", Preset: "slow", Args: []string{"-crf", "23"}},
+	{Encoder: "h264_nvenc", Preset: "p1", Args: []string{"-cq", "23"}},
+	{Encoder: "h264_nvenc", Preset: "p4", Args: []string{"-cq", "23"}},
+	{Encoder: "h264_nvenc", Preset: "p7", Args: []string{"-cq", "23"}},
+	{Encoder: "h264_qsv", Preset: "veryfast", Args: []string{"-global_quality", "23"}},
+	{Encoder: "h264_qsv", Preset: "medium", Args: []string{"-global_quality", "23"}},
+	{Encoder: "h264_vaapi", Args: []string{"-vf", "format=nv12,hwupload", "-qp", "23"},
+		Input: []string{"-vaapi_device", "/dev/dri/renderD128"}},
+	{Encoder: "h264_videotoolbox", Args: []string{"-q:v", "65"}},
+	{Encoder: "h264_amf", Args: []string{"-rc", "cqp", "-qp_i", "23", "-qp_p", "23"}},
+	{Encoder: "h264_v4l2m2m", Args: []string{"-b:v", "4M"}},
+}
+
+// BenchmarkResult is how one profile did on the test clip.
+type BenchmarkResult struct {
+	Profile EncoderProfile
+	Elapsed time.Duration
+	Size    int64 // bytes of the encoded clip
+	Err     error // the encoder is listed but failed (no device, no driver)
+}
+
+// Speed is how many times faster than real time the profile encoded.
+func (r BenchmarkResult) Speed() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return BenchmarkDuration / r.Elapsed.Seconds()
+}
+
+// BenchmarkProfiles returns the profiles whose encoder this ffmpeg build has.
+func (d *Downloader) BenchmarkProfiles(ctx context.Context) ([]EncoderProfile, error) {
+	cmd := d.command(ctx, "ffmpeg", "-hide_banner", "-encoders")
+	output, err := combinedOutput(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ffmpeg encoders: %w", err)
+	}
+	available := parseEncoders(output)
+	var profiles []EncoderProfile
+	for _, p := range benchmarkProfiles {
+		if available[p.Encoder] {
+			profiles = append(profiles, p)
+		}
+	}
+	return profiles, nil
+}
+
+// parseEncoders reads the encoder names of "ffmpeg -encoders" output, whose
+// entries look like " V....D libx264   libx264 H.264 / AVC ...".
+func parseEncoders(output []byte) map[string]bool {
+	encoders := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	listing := false
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// The legend above the list ends with a " ------" line
+		if len(fields) == 1 && strings.HasPrefix(fields[0], "---") {
+			listing = true
+			continue
+		}
+		if listing && len(fields) >= 2 {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
+
+// Benchmark encodes a generated 30-second 720p clip with each profile in
+// turn, calling progress before each, and reports their speed and size.
+// One at a time, so the results aren't skewed by each other; jobs running
+// meanwhile still share the CPU.
+func (d *Downloader) Benchmark(ctx context.Context, profiles []EncoderProfile, progress func(i int, p EncoderProfile)) ([]BenchmarkResult, error) {
+	workDir, err := d.newWorkDir("benchmark")
+	if err != nil {
+		return nil, err
+	}
+	defer d.RemoveWorkDir(workDir)
+
+	source := filepath.Join(workDir, "source.mp4")
+	if err := d.benchmarkSource(ctx, source); err != nil {
+		return nil, err
+	}
+
+	var results []BenchmarkResult
+	for i, p := range profiles {
+		if progress != nil {
+			progress(i, p)
+		}
+		output := filepath.Join(workDir, fmt.Sprintf("%d.mp4", i))
+		start := time.Now()
+		cmd := d.command(ctx, "ffmpeg", benchmarkArgs(p, source, output)...)
+		out, err := combinedOutput(ctx, cmd)
+		result := BenchmarkResult{Profile: p, Elapsed: time.Since(start)}
+		if err != nil {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			logger.FromContext(ctx).Debug("Benchmark encode failed", "profile", p.Name(), "error", err, "output", string(out))
+			result.Err = fmt.Errorf("%w: %s", err, lastLine(out))
+		} else if info, err := os.Stat(output); err != nil {
+			result.Err = err
+		} else {
+			result.Size = info.Size()
+		}
+		os.Remove(output)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// benchmarkSource writes the test clip: moving test pattern and a tone,
+// encoded near-losslessly so every profile starts from the same decode.
+func (d *Downloader) benchmarkSource(ctx context.Context, path string) error {
+	args := []string{
+		"-f", "lavfi", "-i", fmt.Sprintf("testsrc2=size=1280x720:rate=30:duration=%d", BenchmarkDuration),
+		"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=440:duration=%d", BenchmarkDuration),
+		"-c:v", "libx264", "-preset", "ultrafast", "-crf", "10", "-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-y",
+		path,
+	}
+	cmd := d.command(ctx, "ffmpeg", args...)
+	output, err := combinedOutput(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Error("ffmpeg benchmark clip failed", "error", err, "output", string(output))
+		return fmt.Errorf("failed to generate benchmark clip: %w", err)
+	}
+	return nil
+}
+
+// benchmarkArgs returns the ffmpeg arguments encoding source with p,
+// audio copied so only the video encode is timed.
+func benchmarkArgs(p EncoderProfile, source, output string) []string {
+	args := append([]string{"-hide_banner"}, p.Input...)
+	args = append(args, "-i", source, "-c:v", p.Encoder)
+	if p.Preset != "" {
+		args = append(args, "-preset", p.Preset)
+	}
+	args = append(args, p.Args...)
+	if p.Encoder != "h264_vaapi" {
+		args = append(args, "-pix_fmt", "yuv420p")
+	}
+	return append(args, "-c:a", "copy", "-y", output)
+}
+
+// lastLine returns the last non-empty line of tool output.
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
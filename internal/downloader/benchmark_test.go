@@ -0,0 +1,43 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEncoders(t *testing.T) {
+	output := []byte(`Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ V....D h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)
+ A....D aac                  AAC (Advanced Audio Coding)
+`)
+	encoders := parseEncoders(output)
+	assert.True(t, encoders["libx264"])
+	assert.True(t, encoders["h264_nvenc"])
+	assert.True(t, encoders["aac"])
+	assert.False(t, encoders["="], "legend lines are not encoders")
+	assert.Len(t, encoders, 3)
+}
+
+func TestBenchmarkArgs(t *testing.T) {
+	args := benchmarkArgs(EncoderProfile{Encoder: "libx264", Preset: "fast", Args: []string{"-crf", "23"}}, "in.mp4", "out.mp4")
+	assert.Equal(t, []string{"-hide_banner", "-i", "in.mp4", "-c:v", "libx264", "-preset", "fast", "-crf", "23",
+		"-pix_fmt", "yuv420p", "-c:a", "copy", "-y", "out.mp4"}, args)
+
+	args = benchmarkArgs(EncoderProfile{Encoder: "h264_vaapi", Args: []string{"-qp", "23"},
+		Input: []string{"-vaapi_device", "/dev/dri/renderD128"}}, "in.mp4", "out.mp4")
+	assert.Equal(t, []string{"-hide_banner", "-vaapi_device", "/dev/dri/renderD128", "-i", "in.mp4", "-c:v", "h264_vaapi",
+		"-qp", "23", "-c:a", "copy", "-y", "out.mp4"}, args)
+}
+
+func TestBenchmarkResultSpeed(t *testing.T) {
+	assert.Equal(t, 3.0, BenchmarkResult{Elapsed: 10 * time.Second}.Speed())
+	assert.Equal(t, 0.0, BenchmarkResult{}.Speed())
+	assert.Equal(t, "h264_videotoolbox", EncoderProfile{Encoder: "h264_videotoolbox"}.Name())
+	assert.Equal(t, "libx264 slow", EncoderProfile{Encoder: "libx264", Preset: "slow"}.Name())
+}
@@ -0,0 +1,40 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseChannelEntries(t *testing.T) {
+	output := []byte(`{"id": "new", "title": "Newest", "url": "https://www.youtube.com/watch?v=new", "upload_date": "20260301"}
+{"id": "ts", "title": "Timestamped", "url": "https://www.youtube.com/watch?v=ts", "timestamp": 1767225600}
+{"id": "undated", "title": "No date", "url": "https://www.youtube.com/watch?v=undated"}
+{"id": "old", "title": "Old", "url": "https://www.youtube.com/watch?v=old", "upload_date": "20190101"}
+{"id": "new", "title": "Newest", "url": "https://www.youtube.com/watch?v=new", "upload_date": "20260301"}
+{"id": "rel", "title": "Relative", "url": "rel", "webpage_url": "https://vimeo.com/rel"}
+not json
+`)
+
+	all := parseChannelEntries(output, time.Time{})
+	if len(all) != 5 {
+		t.Fatalf("got %d videos, want 5 (repeat dropped): %+v", len(all), all)
+	}
+	if all[4].URL != "https://vimeo.com/rel" {
+		t.Errorf("URL = %q, want the webpage URL for a non-HTTP url", all[4].URL)
+	}
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC); !all[0].Uploaded.Equal(want) {
+		t.Errorf("Uploaded = %v, want %v", all[0].Uploaded, want)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !all[1].Uploaded.Equal(want) {
+		t.Errorf("Uploaded = %v, want %v from the timestamp", all[1].Uploaded, want)
+	}
+
+	since := parseChannelEntries(output, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	var ids []string
+	for _, v := range since {
+		ids = append(ids, v.ID)
+	}
+	if len(ids) != 4 || ids[3] != "rel" || ids[2] != "undated" {
+		t.Errorf("since 2025: %v, want everything but the old upload", ids)
+	}
+}
@@ -0,0 +1,190 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// ErrNoChapters is returned by SplitByChapters for files without at least two
+// chapters; callers split by size instead.
+var ErrNoChapters = errors.New("no chapters to split on")
+
+// Chapter is a titled time range from a file's chapter metadata.
+type Chapter struct {
+	Start float64 // seconds
+	End   float64 // seconds
+	Title string
+}
+
+// GetChapters returns the chapters embedded in filePath (yt-dlp
+// --embed-chapters), in order.
+func (d *Downloader) GetChapters(ctx context.Context, filePath string) ([]Chapter, error) {
+	cmd := d.command(ctx, "ffprobe", "-v", "error", "-show_chapters", "-of", "json", filePath)
+	output, err := cmd.Output()
+	recordUsage(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe chapters failed: %w", err)
+	}
+	return parseChapters(output)
+}
+
+// parseChapters converts ffprobe -show_chapters JSON into chapters. Chapters
+// without a title are named by their number.
+func parseChapters(data []byte) ([]Chapter, error) {
+	var raw struct {
+		Chapters []struct {
+			StartTime string `json:"start_time"`
+			EndTime   string `json:"end_time"`
+			Tags      struct {
+				Title string `json:"title"`
+			} `json:"tags"`
+		} `json:"chapters"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe chapters: %w", err)
+	}
+
+	chapters := make([]Chapter, 0, len(raw.Chapters))
+	for i, c := range raw.Chapters {
+		start, err1 := strconv.ParseFloat(c.StartTime, 64)
+		end, err2 := strconv.ParseFloat(c.EndTime, 64)
+		if err1 != nil || err2 != nil || end <= start {
+			continue
+		}
+		title := c.Tags.Title
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		chapters = append(chapters, Chapter{Start: start, End: end, Title: title})
+	}
+	return chapters, nil
+}
+
+// chapterPart is a planned part of a chapter split.
+type chapterPart struct {
+	Start float64
+	Title string
+}
+
+// planChapterParts groups consecutive chapters into parts whose size,
+// estimated from the file's average bitrate, stays under targetSize. A part
+// of several chapters is titled "First – Last"; a chapter too large on its
+// own is cut evenly into "Title (1/N)" pieces.
+func planChapterParts(chapters []Chapter, duration float64, fileSize, targetSize int64) []chapterPart {
+	if len(chapters) == 0 || duration <= 0 || fileSize <= 0 {
+		return nil
+	}
+	maxDuration := float64(targetSize) / (float64(fileSize) / duration)
+
+	var parts []chapterPart
+	var group []Chapter
+	flush := func() {
+		if len(group) == 0 {
+			return
+		}
+		title := group[0].Title
+		if len(group) > 1 {
+			title += " – " + group[len(group)-1].Title
+		}
+		parts = append(parts, chapterPart{Start: group[0].Start, Title: title})
+		group = nil
+	}
+
+	for _, ch := range chapters {
+		length := ch.End - ch.Start
+		if length > maxDuration {
+			flush()
+			n := int(math.Ceil(length / maxDuration))
+			for i := 0; i < n; i++ {
+				parts = append(parts, chapterPart{
+					Start: ch.Start + float64(i)*length/float64(n),
+					Title: fmt.Sprintf("%s (%d/%d)", ch.Title, i+1, n),
+				})
+			}
+			continue
+		}
+		if len(group) > 0 && ch.End-group[0].Start > maxDuration {
+			flush()
+		}
+		group = append(group, ch)
+	}
+	flush()
+
+	// The first part also covers anything before the first chapter
+	parts[0].Start = 0
+	return parts
+}
+
+// SplitByChapters splits an oversized video on its chapter boundaries with
+// stream copy, grouping short chapters so each part fits the upload limit. Parts
+// carry their chapter titles. Returns ErrNoChapters if the file has fewer
+// than two chapters; any other failure (incompatible codecs, a part over the
+// limit) is an error too, and callers fall back to SplitVideo.
+func (d *Downloader) SplitByChapters(ctx context.Context, filePath string, progressCb ProgressCallback) ([]PartInfo, error) {
+	chapters, err := d.GetChapters(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(chapters) < 2 {
+		return nil, ErrNoChapters
+	}
+
+	mediaInfo, err := d.probeMedia(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	videoCodec, _ := d.GetVideoCodec(filePath)
+	audioCodec, _ := d.GetAudioCodec(filePath)
+	pixFmt, _ := d.GetPixelFormat(filePath)
+	if !CanStreamCopy(videoCodec, audioCodec, pixFmt) {
+		return nil, fmt.Errorf("chapter split needs stream copy, got %s/%s/%s", videoCodec, audioCodec, pixFmt)
+	}
+
+	// Parts together take about as much space as the source
+	if err := ensureFreeSpace(filepath.Dir(filePath), mediaInfo.FileSize); err != nil {
+		return nil, err
+	}
+
+	plan := planChapterParts(chapters, mediaInfo.Duration, mediaInfo.FileSize, d.limits.SizeSplit)
+	if len(plan) < 2 {
+		return nil, ErrNoChapters
+	}
+	cuts := make([]float64, len(plan)-1)
+	for i, p := range plan[1:] {
+		cuts[i] = p.Start
+	}
+	logger.FromContext(ctx).Info("Splitting video by chapters", "chapters", len(chapters), "numParts", len(plan), "cuts", cuts)
+
+	segmentDuration := mediaInfo.Duration / float64(len(plan))
+	args := splitArgs(filePath, segmentDuration, cuts, true)
+	parts, err := d.runSplit(ctx, filePath, args, mediaInfo.Duration, segmentDuration, len(plan), progressCb)
+	if err != nil {
+		return nil, err
+	}
+
+	discard := func(reason error) ([]PartInfo, error) {
+		for _, p := range parts {
+			os.Remove(p.FilePath)
+		}
+		return nil, reason
+	}
+	// Cuts snap to keyframes, so sparse keyframes can merge two parts
+	if len(parts) != len(plan) {
+		return discard(fmt.Errorf("chapter split made %d parts, planned %d", len(parts), len(plan)))
+	}
+	if oversized := d.limits.oversizedParts(parts); len(oversized) > 0 {
+		return discard(fmt.Errorf("chapter parts %v exceed the upload limit", oversized))
+	}
+	for i := range parts {
+		parts[i].Title = plan[i].Title
+	}
+	return parts, nil
+}
@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseChapters(t *testing.T) {
+	data := []byte(`{"chapters": [
+		{"start_time": "0.000000", "end_time": "60.000000", "tags": {"title": "Intro"}},
+		{"start_time": "60.000000", "end_time": "300.500000", "tags": {}},
+		{"start_time": "bad", "end_time": "400.000000", "tags": {"title": "Broken"}}
+	]}`)
+	chapters, err := parseChapters(data)
+	if err != nil {
+		t.Fatalf("parseChapters: %v", err)
+	}
+	if len(chapters) != 2 {
+		t.Fatalf("got %d chapters, want 2: %+v", len(chapters), chapters)
+	}
+	if chapters[0].Title != "Intro" || chapters[1].Title != "Chapter 2" || chapters[1].End != 300.5 {
+		t.Errorf("unexpected chapters: %+v", chapters)
+	}
+
+	if _, err := parseChapters([]byte("not json")); err == nil {
+		t.Error("parseChapters(invalid) succeeded, want error")
+	}
+}
+
+func TestPlanChapterParts(t *testing.T) {
+	// 1 byte per second of video, parts of at most 100 bytes (100s)
+	chapters := []Chapter{
+		{Start: 5, End: 40, Title: "Intro"},
+		{Start: 40, End: 90, Title: "Setup"},
+		{Start: 90, End: 150, Title: "Build"},
+		{Start: 150, End: 400, Title: "Long talk"},
+		{Start: 400, End: 420, Title: "Outro"},
+	}
+	parts := planChapterParts(chapters, 420, 420, 100)
+
+	want := []chapterPart{
+		{Start: 0, Title: "Intro – Setup"},
+		{Start: 90, Title: "Build"},
+		{Start: 150, Title: "Long talk (1/3)"},
+		{Start: 150 + 250.0/3, Title: "Long talk (2/3)"},
+		{Start: 150 + 500.0/3, Title: "Long talk (3/3)"},
+		{Start: 400, Title: "Outro"},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d: %+v", len(parts), len(want), parts)
+	}
+	for i := range want {
+		if parts[i].Title != want[i].Title || math.Abs(parts[i].Start-want[i].Start) > 1e-9 {
+			t.Errorf("part %d = %+v, want %+v", i, parts[i], want[i])
+		}
+	}
+
+	if parts := planChapterParts(nil, 420, 420, 100); parts != nil {
+		t.Errorf("planChapterParts(nil) = %+v, want nil", parts)
+	}
+}
@@ -0,0 +1,202 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// DefaultCompressOvershoot is the default ShouldCompress threshold, in
+	// percent over the upload limit.
+	DefaultCompressOvershoot = 10
+
+	compressAudioKbps    = 128
+	minCompressVideoKbps = 200
+	muxOverhead          = 0.98 // Share of the target left after MP4 container overhead
+)
+
+// ShouldCompress reports whether a video of fileSize is over the Upload
+// limit by no more than maxOvershoot percent, so re-encoding it into a
+// single file beats splitting it. A maxOvershoot of 0 disables compression.
+func (l UploadLimits) ShouldCompress(fileSize int64, maxOvershoot int) bool {
+	if maxOvershoot <= 0 || !l.NeedsSplit(fileSize) {
+		return false
+	}
+	return fileSize <= l.Upload+l.Upload/100*int64(maxOvershoot)
+}
+
+// ErrTooManyParts means a video would need more parts than Options.MaxParts
+// allows and can't be compressed enough to need fewer.
+var ErrTooManyParts = errors.New("video needs more parts than allowed")
+
+// TooManyParts reports whether a video of fileSize would be split into more
+// than maxParts parts; a maxParts of 0 or less is no cap.
+func (l UploadLimits) TooManyParts(fileSize int64, maxParts int) bool {
+	return maxParts > 0 && l.NeedsSplit(fileSize) && l.CalculateNumParts(fileSize) > maxParts
+}
+
+// PartLimitTarget returns the size to compress a video to so it is split
+// into at most maxParts parts: Compress (one file) for a cap of one.
+func (l UploadLimits) PartLimitTarget(maxParts int) int64 {
+	if maxParts <= 1 {
+		return l.Compress
+	}
+	return int64(maxParts) * (l.Split / 100 * 97)
+}
+
+// CompressionBitrate returns the video bitrate in kbit/s that fits a video of
+// the given duration into targetSize bytes next to a compressAudioKbps audio
+// track.
+func CompressionBitrate(targetSize int64, duration float64) (int, error) {
+	if duration <= 0 {
+		return 0, fmt.Errorf("invalid video duration: %f", duration)
+	}
+	totalKbps := float64(targetSize) * 8 * muxOverhead / duration / 1000
+	videoKbps := int(totalKbps) - compressAudioKbps
+	if videoKbps < minCompressVideoKbps {
+		return 0, fmt.Errorf("video too long to fit %d bytes: %d kbit/s", targetSize, videoKbps)
+	}
+	return videoKbps, nil
+}
+
+// CompressToSize re-encodes a video with a two-pass x264 encode at the
+// bitrate that fits it into targetSize bytes. Returns the path of the new
+// file (the original is kept); fails if the result still doesn't fit.
+func (d *Downloader) CompressToSize(ctx context.Context, filePath string, targetSize int64, progressCb ProgressCallback) (string, error) {
+	mediaInfo, err := d.probeMedia(ctx, filePath)
+	if err != nil {
+		return "", err
+	}
+	videoKbps, err := CompressionBitrate(targetSize, mediaInfo.Duration)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Dir(filePath)
+	if err := ensureFreeSpace(dir, targetSize); err != nil {
+		return "", err
+	}
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outputPath := filepath.Join(dir, baseName+"_compressed.mp4")
+	passLog := filepath.Join(dir, "x264pass")
+
+	logger.FromContext(ctx).Info("Compressing to fit upload limit",
+		"input", filePath,
+		"fileSize", mediaInfo.FileSize,
+		"targetSize", targetSize,
+		"videoKbps", videoKbps,
+	)
+
+	video := []string{
+		"-i", filePath,
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-b:v", fmt.Sprintf("%dk", videoKbps),
+		"-pix_fmt", "yuv420p",
+		"-passlogfile", passLog,
+	}
+	passes := [][]string{
+		append(append([]string{}, video...), "-pass", "1", "-an", "-f", "null", "-y", os.DevNull),
+		append(append([]string{}, video...), "-pass", "2",
+			"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", compressAudioKbps),
+			"-movflags", "+faststart",
+			"-y", outputPath),
+	}
+	for i, args := range passes {
+		logger.FromContext(ctx).Debug("Running ffmpeg compression pass", "pass", i+1, "args", args)
+		cmd := d.command(ctx, "ffmpeg", args...)
+		err := runFFmpegProgress(ctx, cmd, mediaInfo.Duration, func(percent float64) {
+			if progressCb != nil {
+				progressCb(Progress{Phase: "compressing", Percent: (float64(i)*100 + percent) / 2})
+			}
+		})
+		recordUsage(ctx, cmd)
+		if err != nil {
+			os.Remove(outputPath)
+			return "", fmt.Errorf("ffmpeg compression pass %d failed: %w", i+1, err)
+		}
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat compressed video: %w", err)
+	}
+	if d.limits.NeedsSplit(info.Size()) {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("compressed video is still too large: %d bytes", info.Size())
+	}
+
+	logger.FromContext(ctx).Info("Compression complete", "output", outputPath, "size", info.Size())
+	return outputPath, nil
+}
+
+var ffmpegTimeRe = regexp.MustCompile(`time=(\d+):(\d+):(\d+\.?\d*)`)
+
+// runFFmpegProgress runs an ffmpeg command, reporting the percentage of
+// duration processed from its stderr. The last stderr lines are logged on
+// failure and go to the job's OutputTail.
+func runFFmpegProgress(ctx context.Context, cmd *exec.Cmd, duration float64, onPercent func(float64)) error {
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	var tail []string
+	scanner := bufio.NewScanner(stderr)
+	// ffmpeg separates progress updates with \r
+	scanner.Split(scanCRLF)
+	for scanner.Scan() {
+		line := scanner.Text()
+		OutputTailFrom(ctx).add("ffmpeg", line)
+		if tail = append(tail, line); len(tail) > 10 {
+			tail = tail[1:]
+		}
+		matches := ffmpegTimeRe.FindStringSubmatch(line)
+		if matches == nil || duration <= 0 {
+			continue
+		}
+		var hours, mins int
+		var secs float64
+		fmt.Sscanf(matches[1], "%d", &hours)
+		fmt.Sscanf(matches[2], "%d", &mins)
+		fmt.Sscanf(matches[3], "%f", &secs)
+		percent := (float64(hours*3600+mins*60) + secs) / duration * 100
+		if percent > 100 {
+			percent = 100
+		}
+		onPercent(percent)
+	}
+	io.Copy(io.Discard, stderr)
+
+	if err := cmd.Wait(); err != nil {
+		logger.FromContext(ctx).Error("ffmpeg failed", "error", err, "output", strings.Join(tail, "\n"))
+		return err
+	}
+	return nil
+}
+
+// scanCRLF is a bufio.SplitFunc that splits on \n or \r.
+func scanCRLF(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, b := range data {
+		if b == '\n' || b == '\r' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,100 @@
+package downloader
+
+import "testing"
+
+func TestShouldCompress(t *testing.T) {
+	tests := []struct {
+		name      string
+		fileSize  int64
+		overshoot int
+		want      bool
+	}{
+		{"fits already", local.Upload, 10, false},
+		{"slightly over", local.Upload + 1, 10, true},
+		{"at the threshold", local.Upload + local.Upload/10, 10, true},
+		{"over the threshold", local.Upload + local.Upload/10 + 1, 10, false},
+		{"disabled", local.Upload + 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := local.ShouldCompress(tt.fileSize, tt.overshoot); got != tt.want {
+				t.Errorf("ShouldCompress(%d, %d) = %v, want %v", tt.fileSize, tt.overshoot, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressionBitrate(t *testing.T) {
+	// One hour into the local Compress target: ~4.3 Mbit/s total
+	kbps, err := CompressionBitrate(local.Compress, 3600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	totalBytes := float64(kbps+compressAudioKbps) * 1000 / 8 * 3600
+	if totalBytes > float64(local.Compress) {
+		t.Errorf("bitrate %dk overshoots the target: %.0f > %d bytes", kbps, totalBytes, local.Compress)
+	}
+	if totalBytes < float64(local.Compress)*0.95 {
+		t.Errorf("bitrate %dk wastes too much of the target: %.0f bytes", kbps, totalBytes)
+	}
+
+	if _, err := CompressionBitrate(local.Compress, 0); err == nil {
+		t.Error("expected error for zero duration")
+	}
+	// 2GB over 24 hours leaves under minCompressVideoKbps for video
+	if _, err := CompressionBitrate(local.Compress, 24*3600); err == nil {
+		t.Error("expected error when the bitrate would be too low")
+	}
+}
+
+func TestNewUploadLimits(t *testing.T) {
+	// The defaults are the local Bot API server's
+	d := &Downloader{}
+	d.SetUploadLimit(LocalAPIUploadLimit)
+	if got := d.Limits(); got.Upload != 1900*1024*1024 || got.Split != 1700*1024*1024 {
+		t.Fatalf("local limits = %d/%d", got.Upload, got.Split)
+	}
+
+	official := NewUploadLimits(OfficialAPIUploadLimit)
+	if official.File != 50*1000*1000 {
+		t.Errorf("File = %d", official.File)
+	}
+	if official.Upload != 47_500_000 {
+		t.Errorf("Upload = %d, want 47500000", official.Upload)
+	}
+	if !(official.SizeSplit < official.Upload && official.Compress < official.Upload && official.Split < official.Upload) {
+		t.Errorf("targets not under Upload: split %d, size split %d, compress %d",
+			official.Split, official.SizeSplit, official.Compress)
+	}
+	if !official.NeedsSplit(60 * 1000 * 1000) {
+		t.Error("NeedsSplit(60MB) = false under the official API limit")
+	}
+}
+
+func TestTooManyParts(t *testing.T) {
+	three := 3 * local.Split
+	if local.TooManyParts(three, 3) {
+		t.Error("three parts allowed by a cap of 3")
+	}
+	if !local.TooManyParts(three+1, 3) {
+		t.Error("four parts not caught by a cap of 3")
+	}
+	if local.TooManyParts(three+1, 0) {
+		t.Error("a cap of 0 limits parts")
+	}
+	if local.TooManyParts(local.Upload, 1) {
+		t.Error("a file that fits is too many parts")
+	}
+}
+
+func TestPartLimitTarget(t *testing.T) {
+	if got := local.PartLimitTarget(1); got != local.Compress {
+		t.Errorf("PartLimitTarget(1) = %d, want Compress", got)
+	}
+	for _, n := range []int{2, 3, 10} {
+		target := local.PartLimitTarget(n)
+		if parts := local.CalculateNumParts(target); parts > n {
+			t.Errorf("PartLimitTarget(%d) = %d bytes, splits into %d parts", n, target, parts)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// SetCredentials makes every yt-dlp call use the given Netscape cookies file
+// and netrc file (for sites that need a login). Empty paths are ignored.
+// used, if not nil, runs after each yt-dlp call that was given the files,
+// as yt-dlp may have refreshed the cookies.
+func (d *Downloader) SetCredentials(cookiesFile, netrcFile string, used func()) {
+	d.cookiesFile = cookiesFile
+	d.netrcFile = netrcFile
+	d.credentialsUsed = used
+}
+
+// credentialArgs returns the yt-dlp flags for the configured credentials.
+func (d *Downloader) credentialArgs() []string {
+	var args []string
+	if d.cookiesFile != "" {
+		args = append(args, "--cookies", d.cookiesFile)
+	}
+	if d.netrcFile != "" {
+		args = append(args, "--netrc", "--netrc-location", d.netrcFile)
+	}
+	return args
+}
+
+// ytdlpDone records the resource usage of a finished yt-dlp command and
+// reports the use of the credential files, if it was given any.
+func (d *Downloader) ytdlpDone(ctx context.Context, cmd *exec.Cmd) {
+	recordUsage(ctx, cmd)
+	if d.credentialsUsed != nil && len(d.credentialArgs()) > 0 {
+		d.credentialsUsed()
+	}
+}
+
+// ytdlp builds a yt-dlp command with the config, credential and rate limit
+// flags prepended. They are kept out of the logged args so file locations
+// don't end up in logs. It runs with the bot's empty shared HOME; downloads
+// use ytdlpIn for one of their own.
+func (d *Downloader) ytdlp(ctx context.Context, args ...string) *exec.Cmd {
+	prefix := append(d.configArgs(), d.credentialArgs()...)
+	prefix = append(prefix, d.limitRateArgs(time.Now())...)
+	cmd := d.command(ctx, "yt-dlp", append(prefix, args...)...)
+	if home, err := d.sharedHome(); err != nil {
+		logger.FromContext(ctx).Warn("Failed to create yt-dlp home", "dir", home, "error", err)
+	} else {
+		setEnv(cmd, "HOME", home)
+	}
+	return cmd
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// "0:00 Intro", "- 1:02:03 – Outro", "(12:34) Q&A"
+	leadingTimestampRe = regexp.MustCompile(`^[-–—•*\s]*[(\[]?((?:\d{1,2}:)?\d{1,2}:\d{2})[)\]]?\s*[-–—:|.]?\s*(.*)$`)
+	// "Intro 0:00", "Outro - (1:02:03)"
+	trailingTimestampRe = regexp.MustCompile(`^[-–—•*\s]*(.*?)\s*[-–—:|]?\s*[(\[]?((?:\d{1,2}:)?\d{1,2}:\d{2})[)\]]?$`)
+)
+
+// DescriptionChapters splits a video description into the chapter list
+// written in it, one timestamped line per chapter, and the rest of the
+// text. Fewer than two timestamps aren't a chapter list and stay in the
+// text.
+func DescriptionChapters(description string) ([]Chapter, string) {
+	var chapters []Chapter
+	var rest []string
+	for _, line := range strings.Split(description, "\n") {
+		start, title, ok := timestampLine(strings.TrimSpace(line))
+		if !ok {
+			rest = append(rest, line)
+			continue
+		}
+		if n := len(chapters); n > 0 && chapters[n-1].End == 0 && start > chapters[n-1].Start {
+			chapters[n-1].End = start
+		}
+		chapters = append(chapters, Chapter{Start: start, Title: title})
+	}
+	if len(chapters) < 2 {
+		return nil, description
+	}
+	return chapters, collapseBlankLines(strings.Join(rest, "\n"))
+}
+
+// timestampLine parses a chapter line of a description: a timestamp with a
+// title before or after it.
+func timestampLine(line string) (start float64, title string, ok bool) {
+	if m := leadingTimestampRe.FindStringSubmatch(line); m != nil {
+		start, ok = parseTimestamp(m[1])
+		return start, strings.TrimSpace(m[2]), ok
+	}
+	if m := trailingTimestampRe.FindStringSubmatch(line); m != nil && m[1] != "" {
+		start, ok = parseTimestamp(m[2])
+		return start, strings.TrimSpace(m[1]), ok
+	}
+	return 0, "", false
+}
+
+// parseTimestamp converts "1:02:03" or "12:34" to seconds.
+func parseTimestamp(s string) (float64, bool) {
+	var seconds int
+	for _, field := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return float64(seconds), true
+}
+
+// collapseBlankLines trims text and squeezes the runs of blank lines left
+// where chapter lines were taken out.
+func collapseBlankLines(text string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.TrimSpace(line) == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
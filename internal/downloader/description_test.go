@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescriptionChapters(t *testing.T) {
+	description := `A tour of the new release.
+
+Chapters:
+0:00 Intro
+(1:30) - Installing
+12:05 | Configuration
+Wrap-up 1:02:03
+
+Links: https://example.com`
+
+	chapters, rest := DescriptionChapters(description)
+	assert.Equal(t, []Chapter{
+		{Start: 0, End: 90, Title: "Intro"},
+		{Start: 90, End: 725, Title: "Installing"},
+		{Start: 725, End: 3723, Title: "Configuration"},
+		{Start: 3723, Title: "Wrap-up"},
+	}, chapters)
+	assert.Equal(t, "A tour of the new release.\n\nChapters:\n\nLinks: https://example.com", rest)
+}
+
+func TestDescriptionChaptersSingleTimestamp(t *testing.T) {
+	description := "Skip to 2:30 for the demo\n3:15"
+	chapters, rest := DescriptionChapters(description)
+	assert.Nil(t, chapters)
+	assert.Equal(t, description, rest)
+}
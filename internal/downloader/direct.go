@@ -0,0 +1,299 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// directAttempts is how often a direct download is started or resumed
+	// before giving up.
+	directAttempts = 4
+
+	directHeadTimeout = 15 * time.Second
+)
+
+// directRetryDelay is the pause before resuming, doubled each time.
+var directRetryDelay = 2 * time.Second
+
+// directExts are the URL path extensions fetched directly instead of
+// through yt-dlp.
+var directExts = map[string]bool{
+	".mp4": true, ".m4v": true, ".mov": true, ".webm": true, ".mkv": true, ".m3u8": true,
+}
+
+// unsafeFileChars are replaced in file names taken from URLs.
+var unsafeFileChars = regexp.MustCompile(`[^\w.\-]+`)
+
+// directClient fetches direct media URLs; like the unshortener it refuses
+// private addresses, including after redirects.
+var directClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, Control: refusePrivate}).DialContext,
+		TLSHandshakeTimeout:   30 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	},
+}
+
+// IsDirectMediaURL reports whether rawURL points at a media file or HLS
+// manifest by its extension, e.g. https://cdn.example.com/clip.mp4.
+func IsDirectMediaURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return directExts[strings.ToLower(path.Ext(u.Path))]
+}
+
+// IsUnsupportedURL reports whether err is yt-dlp finding no extractor for
+// a link.
+func IsUnsupportedURL(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "unsupported url")
+}
+
+// isHLS reports whether rawURL is an HLS manifest.
+func isHLS(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && strings.EqualFold(path.Ext(u.Path), ".m3u8")
+}
+
+// isMediaType reports whether a Content-Type is a video or an HLS manifest.
+func isMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "video/") ||
+		mediaType == "application/vnd.apple.mpegurl" || mediaType == "application/x-mpegurl"
+}
+
+// IsMediaResponse reports whether rawURL serves a video (by a HEAD
+// request's Content-Type), for links yt-dlp refused that have no telling
+// extension.
+func (d *Downloader) IsMediaResponse(ctx context.Context, rawURL string) bool {
+	ctx, cancel := context.WithTimeout(ctx, directHeadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := directClient.Do(req)
+	if err != nil {
+		logger.FromContext(ctx).Debug("HEAD request failed", "url", rawURL, "error", err)
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && isMediaType(resp.Header.Get("Content-Type"))
+}
+
+// fetchDirect downloads rawURL itself into workDir: HLS manifests with
+// ffmpeg, anything else with a resumable HTTP GET.
+func (d *Downloader) fetchDirect(ctx context.Context, rawURL, workDir string, opts Options, progressCb ProgressCallback) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout+opts.Record)
+	defer cancel()
+
+	name := directFileName(rawURL)
+	logger.FromContext(ctx).Info("Downloading directly", "url", rawURL, "file", name)
+	if isHLS(rawURL) {
+		return d.fetchHLS(ctx, rawURL, filepath.Join(workDir, strings.TrimSuffix(name, filepath.Ext(name))+".mp4"), opts.Record, progressCb)
+	}
+	return d.fetchHTTP(ctx, rawURL, filepath.Join(workDir, name), progressCb)
+}
+
+// directFileName returns a safe file name for rawURL's last path segment,
+// "video.mp4" if it has none.
+func directFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "video.mp4"
+	}
+	base := unsafeFileChars.ReplaceAllString(path.Base(u.Path), "_")
+	ext := strings.ToLower(path.Ext(base))
+	stem := strings.Trim(strings.TrimSuffix(base, path.Ext(base)), "._")
+	if stem == "" {
+		stem = "video"
+	}
+	if len(stem) > 100 {
+		stem = stem[:100]
+	}
+	if !directExts[ext] {
+		ext = ".mp4"
+	}
+	return stem + ext
+}
+
+// fetchHTTP GETs rawURL into dest, resuming with a Range request after a
+// dropped connection if the server supports it.
+func (d *Downloader) fetchHTTP(ctx context.Context, rawURL, dest string, progressCb ProgressCallback) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	var written, total int64 = 0, -1
+	var lastErr error
+	delay := directRetryDelay
+	for attempt := 1; attempt <= directAttempts; attempt++ {
+		if attempt > 1 {
+			logger.FromContext(ctx).Warn("Direct download interrupted, resuming", "url", rawURL, "at", written, "attempt", attempt, "error", lastErr)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay *= 2
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return fmt.Errorf("invalid URL: %w", err)
+		}
+		if written > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", written))
+		}
+		resp, err := directClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			// Full body: the first attempt, or a server that ignores Range
+			if written > 0 {
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					resp.Body.Close()
+					return err
+				}
+				f.Truncate(0)
+				written = 0
+			}
+			total = resp.ContentLength
+			if total > 0 {
+				if err := ensureFreeSpace(filepath.Dir(dest), d.limits.EstimateDiskNeeds(total, 0)); err != nil {
+					resp.Body.Close()
+					return err
+				}
+			}
+		case resp.StatusCode == http.StatusPartialContent && written > 0:
+			if total < 0 {
+				total = contentRangeTotal(resp.Header.Get("Content-Range"))
+			}
+		default:
+			resp.Body.Close()
+			return fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+		}
+
+		n, err := io.Copy(f, &progressReader{r: resp.Body, read: written, total: total, cb: progressCb})
+		resp.Body.Close()
+		written += n
+		if err == nil && (total < 0 || written >= total) {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("download failed after %d attempts: %w", directAttempts, lastErr)
+}
+
+// contentRangeTotal returns the complete length from a Content-Range header
+// ("bytes 100-999/1000"), or -1 if unknown.
+func contentRangeTotal(header string) int64 {
+	_, size, ok := strings.Cut(header, "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// progressReader reports download progress of a direct download in whole
+// percent steps.
+type progressReader struct {
+	r           io.Reader
+	read, total int64
+	lastPercent int
+	cb          ProgressCallback
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.cb != nil && p.total > 0 {
+		if percent := int(p.read * 100 / p.total); percent > p.lastPercent {
+			p.lastPercent = percent
+			p.cb(Progress{Phase: "downloading", Percent: float64(percent)})
+		}
+	}
+	return n, err
+}
+
+// fetchHLS remuxes an HLS stream into dest with ffmpeg, without re-encoding.
+// A live stream is recorded for at most record.
+func (d *Downloader) fetchHLS(ctx context.Context, manifestURL, dest string, record time.Duration, progressCb ProgressCallback) error {
+	var duration float64
+	if info, err := d.GetMediaInfo(manifestURL); err == nil {
+		duration = info.Duration
+	}
+	if record > 0 {
+		duration = record.Seconds()
+	}
+	cmd := d.command(ctx, "ffmpeg", hlsArgs(manifestURL, dest, record)...)
+	err := runFFmpegProgress(ctx, cmd, duration, func(percent float64) {
+		if progressCb != nil {
+			progressCb(Progress{Phase: "downloading", Percent: percent})
+		}
+	})
+	recordUsage(ctx, cmd)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("download failed: ffmpeg: %w", err)
+	}
+	return nil
+}
+
+// hlsArgs returns the ffmpeg arguments that copy an HLS stream into an MP4,
+// stopping after record if it is set. Only network protocols are allowed,
+// so a manifest can't read local files.
+func hlsArgs(manifestURL, dest string, record time.Duration) []string {
+	args := []string{"-protocol_whitelist", "http,https,tls,tcp,crypto"}
+	if record > 0 {
+		args = append(args, "-t", fmt.Sprintf("%d", int(record.Seconds())))
+	}
+	return append(args,
+		"-i", manifestURL,
+		"-c", "copy",
+		"-bsf:a", "aac_adtstoasc",
+		"-movflags", "+faststart",
+		"-y",
+		dest,
+	)
+}
@@ -0,0 +1,149 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+func init() {
+	logger.Init("error")
+}
+
+func TestIsDirectMediaURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://cdn.example.com/videos/clip.mp4", true},
+		{"https://cdn.example.com/live/index.M3U8?token=abc", true},
+		{"http://example.com/a/b.webm#t=10", true},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", false},
+		{"https://example.com/page.html?file=clip.mp4", false},
+		{"ftp://example.com/clip.mp4", false},
+	}
+	for _, tt := range tests {
+		if got := IsDirectMediaURL(tt.url); got != tt.want {
+			t.Errorf("IsDirectMediaURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestDirectFileName(t *testing.T) {
+	tests := map[string]string{
+		"https://cdn.example.com/videos/My%20Clip.mp4":  "My_Clip.mp4",
+		"https://cdn.example.com/stream/index.m3u8?x=1": "index.m3u8",
+		"https://cdn.example.com/":                      "video.mp4",
+		"https://cdn.example.com/download?id=5":         "download.mp4",
+	}
+	for url, want := range tests {
+		if got := directFileName(url); got != want {
+			t.Errorf("directFileName(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestIsMediaType(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"video/mp4":                     true,
+		"application/vnd.apple.mpegURL": true,
+		"text/html; charset=utf-8":      false,
+		"application/octet-stream":      false,
+	} {
+		if got := isMediaType(ct); got != want {
+			t.Errorf("isMediaType(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+func TestContentRangeTotal(t *testing.T) {
+	if got := contentRangeTotal("bytes 100-999/1000"); got != 1000 {
+		t.Errorf("contentRangeTotal = %d, want 1000", got)
+	}
+	if got := contentRangeTotal("bytes 100-999/*"); got != -1 {
+		t.Errorf("contentRangeTotal(unknown) = %d, want -1", got)
+	}
+}
+
+func TestFetchHTTPResumes(t *testing.T) {
+	body := strings.Repeat("0123456789", 100)
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Range"))
+		var start int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start); err == nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(body)-1, len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(body[start:]))
+			return
+		}
+		// First request: promise everything, send half and drop the connection
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.Write([]byte(body[:len(body)/2]))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	oldClient, oldDelay := directClient, directRetryDelay
+	directClient, directRetryDelay = srv.Client(), time.Millisecond
+	defer func() { directClient, directRetryDelay = oldClient, oldDelay }()
+
+	dest := filepath.Join(t.TempDir(), "clip.mp4")
+	var lastPercent float64
+	err := (&Downloader{limits: local}).fetchHTTP(context.Background(), srv.URL+"/clip.mp4", dest, func(p Progress) { lastPercent = p.Percent })
+	if err != nil {
+		t.Fatalf("fetchHTTP: %v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if string(got) != body {
+		t.Errorf("downloaded %d bytes, want the %d byte body", len(got), len(body))
+	}
+	if len(requests) != 2 || requests[1] != fmt.Sprintf("bytes=%d-", len(body)/2) {
+		t.Errorf("requests = %q, want a full GET and a resume from the middle", requests)
+	}
+	if lastPercent != 100 {
+		t.Errorf("last progress = %v, want 100", lastPercent)
+	}
+}
+
+func TestFetchHTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	oldClient := directClient
+	directClient = srv.Client()
+	defer func() { directClient = oldClient }()
+
+	err := (&Downloader{limits: local}).fetchHTTP(context.Background(), srv.URL+"/gone.mp4", filepath.Join(t.TempDir(), "gone.mp4"), nil)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("err = %v, want HTTP 404", err)
+	}
+}
+
+func TestIsUnsupportedURL(t *testing.T) {
+	if !IsUnsupportedURL(fmt.Errorf("download failed: exit status 1: ERROR: Unsupported URL: https://example.com/v")) {
+		t.Error("yt-dlp's unsupported URL error not recognized")
+	}
+	if IsUnsupportedURL(nil) {
+		t.Error("nil error recognized")
+	}
+}
+
+func TestHLSArgsRecord(t *testing.T) {
+	args := strings.Join(hlsArgs("https://cdn.example.com/live.m3u8", "/tmp/out.mp4", 10*time.Minute), " ")
+	if !strings.Contains(args, "-t 600 -i https://cdn.example.com/live.m3u8") {
+		t.Errorf("args = %q, want the recording limit before the input", args)
+	}
+	if args := strings.Join(hlsArgs("https://cdn.example.com/vod.m3u8", "/tmp/out.mp4", 0), " "); strings.Contains(args, "-t ") {
+		t.Errorf("args = %q, want no limit for a regular stream", args)
+	}
+}
@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// minFreeSpace is kept free on top of any estimate so the host doesn't run dry.
+const minFreeSpace = 200 * 1024 * 1024
+
+// ampleFreeSpace is free space at which the download pre-check doesn't
+// probe the source: ten local upload limits hold the pipeline of a source
+// of 5GB even at the highest EstimateDiskNeeds factor. Bigger sources are
+// still caught by the per-stage checks.
+const ampleFreeSpace = 10 * LocalAPIUploadLimit
+
+// ErrInsufficientSpace is returned when the download directory can't hold
+// the intermediate files of a pipeline stage.
+var ErrInsufficientSpace = errors.New("not enough disk space")
+
+// EstimateDiskNeeds estimates peak disk usage for processing a source of the
+// given size: the original plus a faststart/re-encoded copy, one more copy for
+// high-resolution sources (4K/8K re-encodes can exceed the input), and one more
+// for split parts.
+func (l UploadLimits) EstimateDiskNeeds(sourceSize int64, height int) int64 {
+	factor := int64(2)
+	if height > 1080 {
+		factor++
+	}
+	if l.NeedsSplit(sourceSize) {
+		factor++
+	}
+	return sourceSize * factor
+}
+
+// FreeDiskSpace returns the number of bytes available to unprivileged users in dir.
+func FreeDiskSpace(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, fmt.Errorf("statfs %s: %w", dir, err)
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+// ensureFreeSpace returns ErrInsufficientSpace if dir has less than need bytes
+// (plus minFreeSpace) available. If free space can't be determined, the check
+// is skipped rather than blocking the pipeline.
+func ensureFreeSpace(dir string, need int64) error {
+	free, err := FreeDiskSpace(dir)
+	if err != nil {
+		return nil
+	}
+	if free < need+minFreeSpace {
+		return fmt.Errorf("%w: need %d MB, %d MB available", ErrInsufficientSpace,
+			(need+minFreeSpace)>>20, free>>20)
+	}
+	return nil
+}
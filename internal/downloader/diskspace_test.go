@@ -0,0 +1,54 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEstimateDiskNeeds(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	tests := []struct {
+		name   string
+		size   int64
+		height int
+		want   int64
+	}{
+		{"1080p small", 1 * gb, 1080, 2 * gb},
+		{"4K small", 1 * gb, 2160, 3 * gb},
+		{"1080p needs split", 3 * gb, 1080, 9 * gb},
+		{"8K needs split", 3 * gb, 4320, 12 * gb},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := local.EstimateDiskNeeds(tt.size, tt.height); got != tt.want {
+				t.Errorf("EstimateDiskNeeds(%d, %d) = %d, want %d", tt.size, tt.height, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := ensureFreeSpace(dir, 0); err != nil {
+		t.Fatalf("ensureFreeSpace(0) = %v, want nil", err)
+	}
+	err := ensureFreeSpace(dir, 1<<62)
+	if !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("ensureFreeSpace(huge) = %v, want ErrInsufficientSpace", err)
+	}
+}
+
+func TestPrecheckDiskSpaceUsesProbed(t *testing.T) {
+	d := &Downloader{downloadDir: t.TempDir(), limits: local}
+	ctx := context.Background()
+
+	// An earlier probe is used as is, whatever the free space
+	err := d.precheckDiskSpace(ctx, "https://example.com/v", &VideoInfo{FileSize: 1 << 60})
+	if !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("huge probed source: err = %v, want ErrInsufficientSpace", err)
+	}
+	if err := d.precheckDiskSpace(ctx, "https://example.com/v", &VideoInfo{FileSize: 1 << 20}); err != nil {
+		t.Errorf("small probed source: err = %v", err)
+	}
+}
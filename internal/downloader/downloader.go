@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,10 +35,12 @@ type Progress struct {
 type ProgressCallback func(Progress)
 
 const (
-	// Local Bot API server allows up to 2GB uploads
-	MaxFileSize    = 2000 * 1024 * 1024 // 2GB in bytes
-	MaxUploadSize  = 1900 * 1024 * 1024 // 1.9GB - threshold for whether to split
-	MaxSplitSize   = 1700 * 1024 * 1024 // 1.7GB - split target with keyframe overshoot margin
+	// LocalAPIUploadLimit is the upload limit of a local Bot API server (2GB).
+	LocalAPIUploadLimit int64 = 2000 * 1024 * 1024
+
+	// OfficialAPIUploadLimit is the upload limit bots get on api.telegram.org.
+	OfficialAPIUploadLimit int64 = 50 * 1000 * 1000
+
 	DownloadDir    = "/tmp/sushe"
 	DefaultTimeout = 60 * time.Minute // Increased for long videos
 	
@@ -46,6 +49,41 @@ const (
 	MaxVideoDuration  = 2 * time.Hour  // Skip videos longer than 2 hours
 )
 
+// UploadLimits are the upload sizes derived from the Bot API server's
+// per-file limit (see NewUploadLimits).
+type UploadLimits struct {
+	File   int64 // the server's per-file limit, 2GB for a local server
+	Upload int64 // 1.9GB - threshold for whether to split
+	Split  int64 // 1.7GB - split target with keyframe overshoot margin
+
+	// SizeSplit is the part size aimed for when cutting by packet sizes.
+	// Cuts land exactly on keyframes, so only container overhead needs
+	// headroom.
+	SizeSplit int64
+
+	// Compress is the size CompressToSize aims for when fitting a video
+	// under Upload: two-pass encodes land within a few percent of the
+	// requested bitrate, so leave some room.
+	Compress int64
+}
+
+// NewUploadLimits derives the split and compression targets for a Bot API
+// server's per-file upload limit in bytes.
+func NewUploadLimits(limit int64) UploadLimits {
+	upload := limit / 100 * 95
+	return UploadLimits{
+		File:      limit,
+		Upload:    upload,
+		Split:     limit / 100 * 85,
+		SizeSplit: upload / 100 * 95,
+		Compress:  upload / 100 * 97,
+	}
+}
+
+// defaultFormat prefers H.264 (avc1) video + AAC audio sources to avoid re-encoding.
+// Falls back to any codec if H.264 not available.
+const defaultFormat = "bestvideo[vcodec^=avc1][height<=1080]+bestaudio[acodec^=mp4a]/bestvideo[vcodec^=avc][height<=1080]+bestaudio/bestvideo[height<=1080]+bestaudio/best[height<=1080]/best"
+
 // MediaInfo contains video metadata from ffprobe
 type MediaInfo struct {
 	Duration float64 // seconds
@@ -53,6 +91,8 @@ type MediaInfo struct {
 	FileSize int64   // bytes
 	Width    int     // video width in pixels
 	Height   int     // video height in pixels
+	Title    string  // title tag, if any
+	Artist   string  // artist tag, if any
 }
 
 // PartInfo describes a split video part
@@ -60,6 +100,7 @@ type PartInfo struct {
 	FilePath string
 	PartNum  int
 	FileSize int64
+	Title    string // chapter title(s) for chapter splits, "" otherwise
 }
 
 // PlaylistInfo contains information about a playlist
@@ -67,6 +108,7 @@ type PlaylistInfo struct {
 	ID           string            `json:"id"`
 	Title        string            `json:"title"`
 	PlaylistCount int              `json:"playlist_count"`
+	TotalCount   int               // videos in the source playlist, before limits and filtering
 	Entries      []PlaylistEntry   `json:"entries"`
 }
 
@@ -76,6 +118,7 @@ type PlaylistEntry struct {
 	Title    string  `json:"title"`
 	URL      string  `json:"url"`
 	Duration float64 `json:"duration"`
+	Index    int     `json:"-"` // 1-based position in the source playlist
 }
 
 // DownloadResult contains the result of a download operation
@@ -88,14 +131,49 @@ type DownloadResult struct {
 	Width       int // video width in pixels
 	Height      int // video height in pixels
 	ContentType string
+	Performer   string     // artist/uploader tag (audio only)
+	IsAnimation bool       // animated GIF/WebP or short silent clip as a silent MP4
+	IsVideoNote bool       // square MP4 for a Telegram video note
 	IsSplit     bool       // true if video was split into parts
 	Parts       []PartInfo // split parts (only if IsSplit is true)
 	Error       error
+
+	ThumbnailPath   string // platform thumbnail as a small JPEG, "" if unavailable (video only)
+	BurnedSubtitles string  // language of the subtitles burned into the video, "" if none
+	StartTime       float64 // offset in the source the download starts at (Options.Start), seconds
+	EndTime         float64 // offset in the source the download ends at (Options.End), 0 if at the end
+
+	FramePaths []string  // JPEG stills for Options.Frames/FrameTimes; the video is not for delivery then
+	FrameTimes []float64 // offset of each frame in seconds
+
+	Media []MediaItem // photos and videos of a multi-item post (gallery-dl), delivered as an album
 }
 
 type Downloader struct {
-	downloadDir string
-	timeout     time.Duration
+	downloadDir   string
+	timeout       time.Duration
+	playlistLimit int
+	limits        UploadLimits // see SetUploadLimit
+
+	// Credential files passed to yt-dlp (see SetCredentials)
+	cookiesFile     string
+	netrcFile       string
+	credentialsUsed func()
+
+	// The only yt-dlp config file loaded (see SetYtdlpConfig)
+	ytdlpConfig string
+
+	// Time-of-day download rate limits (see SetBandwidthSchedule)
+	bandwidth BandwidthSchedule
+
+	// Work directories of running downloads (see newWorkDir)
+	workDirs workDirRegistry
+
+	// Magnet links and .torrent files are downloaded (see SetTorrents)
+	torrents bool
+
+	// Constraints of every subprocess run (see SetSandbox)
+	sandbox Sandbox
 }
 
 func New() *Downloader {
@@ -103,11 +181,38 @@ func New() *Downloader {
 	os.MkdirAll(DownloadDir, 0755)
 
 	return &Downloader{
-		downloadDir: DownloadDir,
-		timeout:     DefaultTimeout,
+		downloadDir:   DownloadDir,
+		timeout:       DefaultTimeout,
+		playlistLimit: MaxPlaylistVideos,
+		limits:        NewUploadLimits(LocalAPIUploadLimit),
+	}
+}
+
+// SetUploadLimit sets the Bot API server's per-file upload limit in bytes,
+// and with it the split and compression targets. Call it before any
+// download starts.
+func (d *Downloader) SetUploadLimit(limit int64) {
+	d.limits = NewUploadLimits(limit)
+}
+
+// Limits returns the upload sizes videos are split and compressed for.
+func (d *Downloader) Limits() UploadLimits {
+	return d.limits
+}
+
+// SetPlaylistLimit caps how many videos are taken from a playlist.
+// Values below 1 keep the current limit.
+func (d *Downloader) SetPlaylistLimit(n int) {
+	if n > 0 {
+		d.playlistLimit = n
 	}
 }
 
+// PlaylistLimit returns the maximum number of videos taken from a playlist.
+func (d *Downloader) PlaylistLimit() int {
+	return d.playlistLimit
+}
+
 // Download downloads a video from the given URL using yt-dlp
 func (d *Downloader) Download(ctx context.Context, url string) (*DownloadResult, error) {
 	return d.DownloadWithProgress(ctx, url, nil)
@@ -115,87 +220,290 @@ func (d *Downloader) Download(ctx context.Context, url string) (*DownloadResult,
 
 // DownloadWithProgress downloads a video and reports progress via callback
 func (d *Downloader) DownloadWithProgress(ctx context.Context, url string, progressCb ProgressCallback) (*DownloadResult, error) {
+	return d.DownloadWithOptions(ctx, url, Options{}, progressCb)
+}
+
+// DownloadWithOptions downloads a video (or, with AudioOnly, its audio as MP3)
+// at the quality selected by opts and reports progress via callback
+func (d *Downloader) DownloadWithOptions(ctx context.Context, url string, opts Options, progressCb ProgressCallback) (*DownloadResult, error) {
+	if opts.Album {
+		return d.DownloadGallery(ctx, url, progressCb)
+	}
+	if d.torrents && IsTorrentURL(url) {
+		opts.Torrent = true
+	} else if IsDirectMediaURL(url) && opts.directOK() {
+		opts.Direct = true
+	}
+	result, err := d.download(ctx, url, opts, progressCb)
+	// Raw media links without a telling extension: yt-dlp may refuse them
+	if IsUnsupportedURL(err) && !opts.Direct && opts.directOK() && ctx.Err() == nil && d.IsMediaResponse(ctx, url) {
+		logger.FromContext(ctx).Info("yt-dlp refused a media link, downloading directly", "url", url)
+		opts.Direct = true
+		result, err = d.download(ctx, url, opts, progressCb)
+	}
+	// Formats can change between the probe and the download (live-ish
+	// content): retry once with IDs from a fresh format list
+	if IsFormatUnavailable(err) && opts.Format == "" && !opts.Archive && !opts.Direct && ctx.Err() == nil {
+		logger.FromContext(ctx).Warn("Requested format not available, refreshing format list", "url", url)
+		selector, refreshErr := d.RefreshFormat(ctx, url, opts)
+		if refreshErr != nil {
+			logger.FromContext(ctx).Warn("Failed to refresh formats", "url", url, "error", refreshErr)
+			return nil, err
+		}
+		opts.Format = selector
+		result, err = d.download(ctx, url, opts, progressCb)
+	}
+	// Photo posts (Instagram, Twitter, Reddit, ...) have no video for yt-dlp
+	if IsNoVideo(err) && IsGalleryURL(url) && opts.PlainVideo() && ctx.Err() == nil {
+		logger.FromContext(ctx).Info("No video in post, trying gallery-dl", "url", url)
+		gallery, galleryErr := d.DownloadGallery(ctx, url, progressCb)
+		if galleryErr == nil {
+			return gallery, nil
+		}
+		logger.FromContext(ctx).Warn("gallery-dl failed", "url", url, "error", galleryErr)
+	}
+	if result != nil && !opts.Archive {
+		result.StartTime, result.EndTime = opts.Start, opts.End
+	}
+	return result, err
+}
+
+// download is one DownloadWithOptions attempt.
+func (d *Downloader) download(ctx context.Context, url string, opts Options, progressCb ProgressCallback) (*DownloadResult, error) {
+	// Fail early if the source (plus re-encode and split copies) won't fit
+	// on disk; direct downloads check their Content-Length instead, torrents
+	// the size of the file they pick
+	if !opts.Direct && !opts.Torrent {
+		if err := d.precheckDiskSpace(ctx, url, opts.Probed); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create unique subdirectory for this download
-	downloadID := fmt.Sprintf("%d", time.Now().UnixNano())
-	workDir := filepath.Join(d.downloadDir, downloadID)
-	if err := os.MkdirAll(workDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	workDir, err := d.newWorkDir(url)
+	if err != nil {
+		return nil, err
 	}
 
-	// Output template
-	outputTemplate := filepath.Join(workDir, "%(title).100s.%(ext)s")
+	fetch := d.runYtdlp
+	switch {
+	case opts.Direct:
+		fetch = d.fetchDirect
+	case opts.Torrent:
+		fetch = d.fetchTorrent
+	}
+	if err := fetch(ctx, url, workDir, opts, progressCb); err != nil {
+		d.RemoveWorkDir(workDir)
+		return nil, err
+	}
 
-	// Build yt-dlp command
-	// Use --newline for parseable progress output
-	// Prefer H.264 sources to avoid re-encoding, but accept any codec (will re-encode later if needed)
-	args := []string{
-		"--no-playlist",
-		// Prefer H.264 (avc1) video + AAC audio sources to avoid re-encoding
-		// Falls back to any codec if H.264 not available
-		"-f", "bestvideo[vcodec^=avc1][height<=1080]+bestaudio[acodec^=mp4a]/bestvideo[vcodec^=avc][height<=1080]+bestaudio/bestvideo[height<=1080]+bestaudio/best[height<=1080]/best",
-		"--merge-output-format", "mp4",
-		// NO forced re-encoding here - we check codec after download and re-encode only if needed
-		"-o", outputTemplate,
-		"--no-warnings",
-		"--progress",
-		"--newline",
-		url,
+	// Find the downloaded file
+	files, _ := filepath.Glob(filepath.Join(workDir, "*"))
+	files = skipHome(files)
+	_, thumbSrc := splitThumbnail(files)
+	filePath, err := downloadedFile(workDir, files)
+	if err != nil {
+		d.RemoveWorkDir(workDir)
+		return nil, err
 	}
 
-	logger.Debug("Running yt-dlp", "args", args)
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		d.RemoveWorkDir(workDir)
+		return nil, fmt.Errorf("failed to stat downloaded file: %w", err)
+	}
 
-	// Create context with timeout
-	cmdCtx, cancel := context.WithTimeout(ctx, d.timeout)
-	defer cancel()
+	fileName := filepath.Base(filePath)
+	title := strings.TrimSuffix(fileName, filepath.Ext(fileName))
 
-	cmd := exec.CommandContext(cmdCtx, "yt-dlp", args...)
-	cmd.Dir = workDir
+	// Animated images (Imgur/Reddit GIFs) become silent MP4 animations
+	if !opts.AudioOnly && !opts.Voice && !opts.Archive && !opts.WantsFrames() && d.IsAnimatedImage(filePath) {
+		animPath, err := d.ConvertAnimation(ctx, filePath)
+		if err != nil {
+			d.RemoveWorkDir(workDir)
+			return nil, err
+		}
+		os.Remove(filePath)
+		result, err := d.animationResult(animPath, title)
+		if err != nil {
+			d.RemoveWorkDir(workDir)
+			return nil, err
+		}
+		return result, nil
+	}
 
-	// If we have a progress callback, stream output; otherwise use simple execution
-	if progressCb != nil {
-		if err := d.runWithProgress(cmd, progressCb); err != nil {
-			logger.Error("yt-dlp failed", "error", err)
-			os.RemoveAll(workDir)
-			return nil, fmt.Errorf("download failed: %w", err)
+	// /frames: extract stills and keep the video only to clean up with them
+	if opts.WantsFrames() {
+		times := opts.FrameTimes
+		if len(times) == 0 {
+			info, err := d.GetMediaInfo(filePath)
+			if err != nil {
+				d.RemoveWorkDir(workDir)
+				return nil, fmt.Errorf("failed to get media info: %w", err)
+			}
+			times = FrameTimes(info.Duration, opts.Frames)
 		}
-	} else {
-		output, err := cmd.CombinedOutput()
+		frames, used, err := d.ExtractFrames(ctx, filePath, times)
 		if err != nil {
-			logger.Error("yt-dlp failed", "error", err, "output", string(output))
-			os.RemoveAll(workDir)
-			return nil, fmt.Errorf("download failed: %w - %s", err, string(output))
+			d.RemoveWorkDir(workDir)
+			return nil, err
+		}
+		return &DownloadResult{
+			FilePath:    filePath,
+			FileName:    fileName,
+			Title:       title,
+			FileSize:    fileInfo.Size(),
+			ContentType: getContentType(filePath),
+			FramePaths:  frames,
+			FrameTimes:  used,
+		}, nil
+	}
+
+	// /note: a round video, square and at most a minute long
+	if opts.VideoNote {
+		notePath, err := d.ConvertToVideoNote(ctx, filePath)
+		if err != nil {
+			d.RemoveWorkDir(workDir)
+			return nil, err
+		}
+		os.Remove(filePath)
+		noteInfo, err := os.Stat(notePath)
+		if err != nil {
+			d.RemoveWorkDir(workDir)
+			return nil, fmt.Errorf("failed to stat video note: %w", err)
+		}
+		result := &DownloadResult{
+			FilePath:    notePath,
+			FileName:    filepath.Base(notePath),
+			Title:       title,
+			FileSize:    noteInfo.Size(),
+			ContentType: "video/mp4",
+			IsVideoNote: true,
+			Width:       VideoNoteSize,
+			Height:      VideoNoteSize,
+		}
+		if mediaInfo, _ := d.GetMediaInfo(notePath); mediaInfo != nil {
+			result.Duration = mediaInfo.Duration
 		}
+		return result, nil
 	}
 
-	// Find the downloaded file
-	files, err := filepath.Glob(filepath.Join(workDir, "*"))
-	if err != nil || len(files) == 0 {
-		os.RemoveAll(workDir)
-		return nil, fmt.Errorf("no file downloaded")
+	// /gif: any video becomes a silent animation
+	if opts.Animate {
+		animPath, err := d.VideoToAnimation(ctx, filePath)
+		if err != nil {
+			d.RemoveWorkDir(workDir)
+			return nil, err
+		}
+		os.Remove(filePath)
+		result, err := d.animationResult(animPath, title)
+		if err != nil {
+			d.RemoveWorkDir(workDir)
+			return nil, err
+		}
+		return result, nil
 	}
 
-	filePath := files[0]
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		os.RemoveAll(workDir)
-		return nil, fmt.Errorf("failed to stat downloaded file: %w", err)
+	if opts.Voice {
+		voicePath, err := d.ConvertToVoice(ctx, filePath)
+		if err != nil {
+			d.RemoveWorkDir(workDir)
+			return nil, err
+		}
+		os.Remove(filePath)
+		filePath = voicePath
+		if fileInfo, err = os.Stat(filePath); err != nil {
+			d.RemoveWorkDir(workDir)
+			return nil, fmt.Errorf("failed to stat voice file: %w", err)
+		}
+		fileName = filepath.Base(filePath)
 	}
 
-	fileName := filepath.Base(filePath)
-	title := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	// Audio needs no codec checks or faststart
+	if opts.AudioOnly || opts.Voice {
+		result := &DownloadResult{
+			FilePath:    filePath,
+			FileName:    fileName,
+			Title:       title,
+			FileSize:    fileInfo.Size(),
+			ContentType: getContentType(filePath),
+		}
+		if mediaInfo, _ := d.GetMediaInfo(filePath); mediaInfo != nil {
+			result.Duration = mediaInfo.Duration
+			result.Performer = mediaInfo.Artist
+			// The file name is truncated to 100 chars; the tag has the full title
+			if mediaInfo.Title != "" {
+				result.Title = mediaInfo.Title
+			}
+		}
+		return result, nil
+	}
+
+	// Archive copies are delivered as-is: no re-encode, no faststart
+	if opts.Archive {
+		result := &DownloadResult{
+			FilePath:    filePath,
+			FileName:    fileName,
+			Title:       title,
+			FileSize:    fileInfo.Size(),
+			ContentType: getContentType(filePath),
+		}
+		if mediaInfo, _ := d.GetMediaInfo(filePath); mediaInfo != nil {
+			result.Duration = mediaInfo.Duration
+			result.Width = mediaInfo.Width
+			result.Height = mediaInfo.Height
+		}
+		return result, nil
+	}
 
 	// Check video codec - re-encode if not H.264 compatible
-	codec, err := GetVideoCodec(filePath)
+	codec, err := d.GetVideoCodec(filePath)
 	if err != nil {
-		logger.Warn("Failed to get video codec, assuming needs re-encoding", "error", err)
+		logger.FromContext(ctx).Warn("Failed to get video codec, assuming needs re-encoding", "error", err)
 		codec = "unknown"
 	}
 
-	logger.Info("Downloaded video codec", "codec", codec, "file", fileName)
+	logger.FromContext(ctx).Info("Downloaded video codec", "codec", codec, "file", fileName)
+
+	// Burning subtitles in or stabilizing needs a re-encode even for H.264 sources
+	var burnPath string
+	if opts.BurnSubtitles && opts.SubtitleLang != "" {
+		burnPath = d.burnableSubtitle(ctx, url, workDir, opts.SubtitleLang)
+	}
+
+	// H.264 already: remux into MP4 with faststart instead of re-encoding.
+	// Covers MKV/WebM containers as well as MP4s that need the moov atom moved
+	// (PiP support). Only the audio is transcoded if it isn't AAC-compatible.
+	needsReencode := !IsH264Compatible(codec) || burnPath != "" || opts.Stabilize
+	if !needsReencode {
+		newPath, err := d.RemuxToMP4(ctx, filePath)
+		if err != nil {
+			if canUploadAsIs(filePath) {
+				logger.FromContext(ctx).Warn("Failed to remux, using original file", "error", err)
+			} else {
+				logger.FromContext(ctx).Warn("Failed to remux, falling back to re-encoding", "error", err)
+				needsReencode = true
+			}
+		} else {
+			// Replace original with the remuxed version
+			os.Remove(filePath)
+			filePath = newPath
+			fileName = filepath.Base(filePath)
+
+			// Update file info
+			fileInfo, err = os.Stat(filePath)
+			if err != nil {
+				d.RemoveWorkDir(workDir)
+				return nil, fmt.Errorf("failed to stat remuxed file: %w", err)
+			}
+
+			logger.FromContext(ctx).Info("Remux complete", "newSize", fileInfo.Size())
+		}
+	}
 
 	// Re-encode if codec is not H.264 compatible (Telegram requires H.264)
-	if !IsH264Compatible(codec) {
-		logger.Info("Re-encoding required", "codec", codec, "target", "h264")
+	if needsReencode {
+		logger.FromContext(ctx).Info("Re-encoding required", "codec", codec, "target", "h264")
 
 		// Notify progress callback about encoding phase
 		if progressCb != nil {
@@ -206,10 +514,24 @@ func (d *Downloader) DownloadWithProgress(ctx context.Context, url string, progr
 			})
 		}
 
+		filters := encodeFilters{SubtitlePath: burnPath}
+		if opts.Stabilize {
+			var duration float64
+			if info, err := d.GetMediaInfo(filePath); err == nil {
+				duration = info.Duration
+			}
+			shake, err := d.detectShake(ctx, filePath, duration, progressCb)
+			if err != nil {
+				d.RemoveWorkDir(workDir)
+				return nil, fmt.Errorf("failed to stabilize: %w", err)
+			}
+			filters.Transforms, filters.Deshake = shake.Transforms, shake.Deshake
+		}
+
 		// Re-encode to H.264
-		newPath, err := d.ReencodeToH264(ctx, filePath, progressCb)
+		newPath, err := d.reencodeToH264(ctx, filePath, filters, progressCb)
 		if err != nil {
-			os.RemoveAll(workDir)
+			d.RemoveWorkDir(workDir)
 			return nil, fmt.Errorf("failed to re-encode to H.264: %w", err)
 		}
 
@@ -221,52 +543,20 @@ func (d *Downloader) DownloadWithProgress(ctx context.Context, url string, progr
 		// Update file info
 		fileInfo, err = os.Stat(filePath)
 		if err != nil {
-			os.RemoveAll(workDir)
+			d.RemoveWorkDir(workDir)
 			return nil, fmt.Errorf("failed to stat re-encoded file: %w", err)
 		}
 
-		logger.Info("Re-encoding complete", "newSize", fileInfo.Size())
-	} else {
-		// Video is already H.264, but apply faststart for better streaming (PiP support)
-		logger.Info("Applying faststart to H.264 video", "codec", codec)
-
-		// Create output file path for faststart version
-		dir := filepath.Dir(filePath)
-		baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
-		fastStartPath := filepath.Join(dir, baseName+"_faststart.mp4")
-
-		// Apply faststart using ffmpeg with copy (no re-encoding)
-		args := []string{
-			"-i", filePath,
-			"-c", "copy",
-			"-movflags", "+faststart",
-			"-y", // Overwrite output
-			fastStartPath,
-		}
-
-		cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			logger.Warn("Failed to apply faststart, using original file", "error", err, "output", string(output))
-		} else {
-			// Replace original with faststart version
-			os.Remove(filePath)
-			filePath = fastStartPath
-			fileName = filepath.Base(filePath)
-
-			// Update file info
-			fileInfo, err = os.Stat(filePath)
-			if err != nil {
-				os.RemoveAll(workDir)
-				return nil, fmt.Errorf("failed to stat faststart file: %w", err)
-			}
+		logger.FromContext(ctx).Info("Re-encoding complete", "newSize", fileInfo.Size())
+	}
 
-			logger.Info("Faststart applied successfully", "newSize", fileInfo.Size())
-		}
+	var burnedLang string
+	if burnPath != "" {
+		burnedLang = SubtitleLang(burnPath)
 	}
 
 	// Get video metadata (duration, dimensions)
-	mediaInfo, _ := GetMediaInfo(filePath)
+	mediaInfo, _ := d.GetMediaInfo(filePath)
 	var duration float64
 	var width, height int
 	if mediaInfo != nil {
@@ -275,6 +565,20 @@ func (d *Downloader) DownloadWithProgress(ctx context.Context, url string, progr
 		height = mediaInfo.Height
 	}
 
+	// Short clips without sound autoplay inline when sent as animations
+	isAnimation := false
+	if d.IsShortSilentClip(ctx, filePath, duration) {
+		animPath, err := d.StripAudio(ctx, filePath)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Failed to strip audio from short clip, sending as video", "error", err)
+		} else if animInfo, err := os.Stat(animPath); err == nil {
+			logger.FromContext(ctx).Info("Sending short silent clip as animation", "duration", duration, "size", animInfo.Size())
+			os.Remove(filePath)
+			filePath, fileInfo, isAnimation = animPath, animInfo, true
+			fileName = filepath.Base(filePath)
+		}
+	}
+
 	return &DownloadResult{
 		FilePath:    filePath,
 		FileName:    fileName,
@@ -284,13 +588,94 @@ func (d *Downloader) DownloadWithProgress(ctx context.Context, url string, progr
 		Width:       width,
 		Height:      height,
 		ContentType: getContentType(filePath),
+		IsAnimation: isAnimation,
 		IsSplit:     false,
 		Parts:       nil,
+
+		ThumbnailPath:   d.platformThumbnail(ctx, thumbSrc),
+		BurnedSubtitles: burnedLang,
 	}, nil
 }
 
-// runWithProgress runs yt-dlp and parses progress output
-func (d *Downloader) runWithProgress(cmd *exec.Cmd, progressCb ProgressCallback) error {
+// runYtdlp downloads url with yt-dlp into workDir: the media file, plus
+// the platform thumbnail for videos.
+func (d *Downloader) runYtdlp(ctx context.Context, url, workDir string, opts Options, progressCb ProgressCallback) error {
+	// Output template
+	outputTemplate := filepath.Join(workDir, "%(title).100s.%(ext)s")
+
+	// Build yt-dlp command
+	// Use --newline for parseable progress output
+	// Prefer H.264 sources to avoid re-encoding, but accept any codec (will re-encode later if needed)
+	args := append([]string{"--no-playlist"}, opts.args()...)
+	if opts.AudioOnly && IsAudioRoom(url) {
+		args = append(args, "--concurrent-fragments", audioRoomFragments)
+	}
+	if !opts.AudioOnly && !opts.Voice && !opts.Archive {
+		args = append(args, writeThumbnailArgs(workDir)...)
+	}
+	args = append(args, outputListArgs(workDir)...)
+	args = append(args,
+		"-o", outputTemplate,
+		"--no-warnings",
+		"--progress",
+		"--newline",
+		url,
+	)
+
+	logger.FromContext(ctx).Debug("Running yt-dlp", "args", args)
+
+	// Create context with timeout; a live recording gets its length on top
+	cmdCtx, cancel := context.WithTimeout(ctx, d.timeout+opts.Record)
+	defer cancel()
+
+	cmd := d.ytdlpIn(cmdCtx, workDir, args...)
+	defer d.ytdlpDone(ctx, cmd)
+
+	// If we have a progress callback, stream output; otherwise use simple execution
+	if progressCb != nil {
+		if err := d.runWithProgress(ctx, cmd, progressCb); err != nil {
+			logger.FromContext(ctx).Error("yt-dlp failed", "error", err)
+			return fmt.Errorf("download failed: %w", err)
+		}
+	} else {
+		output, err := combinedOutput(ctx, cmd)
+		if err != nil {
+			logger.FromContext(ctx).Error("yt-dlp failed", "error", err, "output", string(output))
+			return fmt.Errorf("download failed: %w - %s", err, string(output))
+		}
+	}
+	return nil
+}
+
+// precheckDiskSpace fails early with ErrInsufficientSpace if the whole
+// pipeline for the URL's expected size can't fit in the download directory.
+// The size comes from probed, or a probe of the URL when that is nil and
+// free space isn't ample anyway. Probe failures are not fatal: the size is
+// simply unknown up front and the per-stage checks still apply.
+func (d *Downloader) precheckDiskSpace(ctx context.Context, url string, probed *VideoInfo) error {
+	info := probed
+	if info == nil {
+		if free, err := FreeDiskSpace(d.downloadDir); err != nil || free >= ampleFreeSpace {
+			return nil
+		}
+		var err error
+		if info, err = d.ProbeInfo(ctx, url); err != nil {
+			logger.FromContext(ctx).Debug("Skipping disk space pre-check, probe failed", "error", err)
+			return nil
+		}
+	}
+	if info.FileSize <= 0 {
+		return nil
+	}
+
+	need := d.limits.EstimateDiskNeeds(info.FileSize, info.Height)
+	logger.FromContext(ctx).Debug("Disk space pre-check", "sourceSize", info.FileSize, "height", info.Height, "need", need)
+	return ensureFreeSpace(d.downloadDir, need)
+}
+
+// runWithProgress runs yt-dlp and parses progress output. The job's
+// Pauser (see WithPauser) can pause it meanwhile.
+func (d *Downloader) runWithProgress(ctx context.Context, cmd *exec.Cmd, progressCb ProgressCallback) error {
 	// Regex patterns for parsing yt-dlp output
 	// [download]  45.2% of 50.00MiB at 2.50MiB/s ETA 00:30
 	downloadRe := regexp.MustCompile(`\[download\]\s+(\d+\.?\d*)%\s+of\s+~?(\S+)\s+at\s+(\S+)\s+ETA\s+(\S+)`)
@@ -312,20 +697,29 @@ func (d *Downloader) runWithProgress(cmd *exec.Cmd, progressCb ProgressCallback)
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start yt-dlp: %w", err)
 	}
+	defer PauserFrom(ctx).track(cmd.Process.Pid)()
 
 	// Read both stdout and stderr
 	scanner := bufio.NewScanner(stdout)
+	var errLine string
+	stderrDone := make(chan struct{})
 	go func() {
-		// Drain stderr to prevent blocking
+		defer close(stderrDone)
+		// Drain stderr to prevent blocking; keep the last ERROR line for the caller
 		stderrScanner := bufio.NewScanner(stderr)
 		for stderrScanner.Scan() {
-			logger.Debug("yt-dlp stderr", "line", stderrScanner.Text())
+			line := stderrScanner.Text()
+			logger.FromContext(ctx).Debug("yt-dlp stderr", "line", line)
+			OutputTailFrom(ctx).add("yt-dlp", line)
+			if strings.HasPrefix(line, "ERROR:") {
+				errLine = line
+			}
 		}
 	}()
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		logger.Debug("yt-dlp output", "line", line)
+		logger.FromContext(ctx).Debug("yt-dlp output", "line", line)
 
 		// Parse download progress
 		if matches := downloadRe.FindStringSubmatch(line); matches != nil {
@@ -351,23 +745,34 @@ func (d *Downloader) runWithProgress(cmd *exec.Cmd, progressCb ProgressCallback)
 		}
 	}
 
-	return cmd.Wait()
+	// Wait must not run before the pipes are fully read
+	<-stderrDone
+	if err := cmd.Wait(); err != nil {
+		if errLine != "" {
+			return fmt.Errorf("%w: %s", err, errLine)
+		}
+		return err
+	}
+	return nil
 }
 
 // GetPlaylistInfo checks if a URL is a playlist and returns playlist information
 func (d *Downloader) GetPlaylistInfo(ctx context.Context, url string) (*PlaylistInfo, error) {
 	// Use yt-dlp with --flat-playlist --dump-json to check if it's a playlist
+	// Only enumerate the entries we'd download; huge channels take minutes otherwise
 	args := []string{
 		"--flat-playlist",
 		"--dump-json",
 		"--no-warnings",
+		"--playlist-end", fmt.Sprintf("%d", d.playlistLimit),
 		url,
 	}
 
-	logger.Debug("Checking if URL is playlist", "args", args)
+	logger.FromContext(ctx).Debug("Checking if URL is playlist", "args", args)
 
-	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	cmd := d.ytdlp(ctx, args...)
 	output, err := cmd.Output()
+	d.ytdlpDone(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get playlist info: %w", err)
 	}
@@ -382,6 +787,7 @@ func (d *Downloader) GetPlaylistInfo(ctx context.Context, url string) (*Playlist
 	var entries []PlaylistEntry
 	var playlistTitle string
 	var playlistID string
+	var totalCount int
 
 	for _, line := range lines {
 		if strings.TrimSpace(line) == "" {
@@ -390,7 +796,7 @@ func (d *Downloader) GetPlaylistInfo(ctx context.Context, url string) (*Playlist
 
 		var entry map[string]interface{}
 		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			logger.Warn("Failed to parse playlist entry", "line", line, "error", err)
+			logger.FromContext(ctx).Warn("Failed to parse playlist entry", "line", line, "error", err)
 			continue
 		}
 
@@ -421,12 +827,18 @@ func (d *Downloader) GetPlaylistInfo(ctx context.Context, url string) (*Playlist
 				playlistID = pid
 			}
 		}
+		if totalCount == 0 {
+			if n, ok := entry["playlist_count"].(float64); ok {
+				totalCount = int(n)
+			}
+		}
 
 		entries = append(entries, PlaylistEntry{
 			ID:       id,
 			Title:    title,
 			URL:      url,
 			Duration: duration,
+			Index:    len(entries) + 1,
 		})
 	}
 
@@ -436,16 +848,21 @@ func (d *Downloader) GetPlaylistInfo(ctx context.Context, url string) (*Playlist
 	}
 
 	// Apply playlist limits
-	if len(entries) > MaxPlaylistVideos {
-		logger.Info("Playlist too large, truncating", "total", len(entries), "max", MaxPlaylistVideos)
-		entries = entries[:MaxPlaylistVideos]
+	if len(entries) > d.playlistLimit {
+		entries = entries[:d.playlistLimit]
+	}
+	if totalCount < len(entries) {
+		totalCount = len(entries)
+	}
+	if totalCount > len(entries) {
+		logger.FromContext(ctx).Info("Playlist too large, truncating", "total", totalCount, "max", d.playlistLimit)
 	}
 
 	// Filter out videos that are too long
 	var validEntries []PlaylistEntry
 	for _, entry := range entries {
 		if entry.Duration > 0 && entry.Duration > MaxVideoDuration.Seconds() {
-			logger.Info("Skipping video (too long)", "title", entry.Title, "duration", entry.Duration)
+			logger.FromContext(ctx).Info("Skipping video (too long)", "title", entry.Title, "duration", entry.Duration)
 			continue
 		}
 		validEntries = append(validEntries, entry)
@@ -463,6 +880,7 @@ func (d *Downloader) GetPlaylistInfo(ctx context.Context, url string) (*Playlist
 		ID:           playlistID,
 		Title:        playlistTitle,
 		PlaylistCount: len(validEntries),
+		TotalCount:   totalCount,
 		Entries:      validEntries,
 	}, nil
 }
@@ -470,10 +888,9 @@ func (d *Downloader) GetPlaylistInfo(ctx context.Context, url string) (*Playlist
 // DownloadPlaylistVideo downloads a specific video from a playlist
 func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL string, videoIndex int, progressCb ProgressCallback) (*DownloadResult, error) {
 	// Create unique subdirectory for this download
-	downloadID := fmt.Sprintf("%d", time.Now().UnixNano())
-	workDir := filepath.Join(d.downloadDir, downloadID)
-	if err := os.MkdirAll(workDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	workDir, err := d.newWorkDir(playlistURL)
+	if err != nil {
+		return nil, err
 	}
 
 	// Output template
@@ -483,7 +900,7 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 	// Remove --no-playlist and use --playlist-items to download specific video
 	args := []string{
 		fmt.Sprintf("--playlist-items=%d", videoIndex+1), // yt-dlp uses 1-based indexing
-		"-f", "bestvideo[vcodec^=avc1][height<=1080]+bestaudio[acodec^=mp4a]/bestvideo[vcodec^=avc][height<=1080]+bestaudio/bestvideo[height<=1080]+bestaudio/best[height<=1080]/best",
+		"-f", defaultFormat,
 		"--merge-output-format", "mp4",
 		"-o", outputTemplate,
 		"--no-warnings",
@@ -491,43 +908,47 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 		"--newline",
 		playlistURL,
 	}
+	args = append(writeThumbnailArgs(workDir), args...)
+	args = append(outputListArgs(workDir), args...)
 
-	logger.Debug("Downloading playlist video", "index", videoIndex, "args", args)
+	logger.FromContext(ctx).Debug("Downloading playlist video", "index", videoIndex, "args", args)
 
 	// Create context with timeout
 	cmdCtx, cancel := context.WithTimeout(ctx, d.timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(cmdCtx, "yt-dlp", args...)
-	cmd.Dir = workDir
+	cmd := d.ytdlpIn(cmdCtx, workDir, args...)
+	defer d.ytdlpDone(ctx, cmd)
 
 	// If we have a progress callback, stream output; otherwise use simple execution
 	if progressCb != nil {
-		if err := d.runWithProgress(cmd, progressCb); err != nil {
-			logger.Error("yt-dlp failed for playlist video", "index", videoIndex, "error", err)
-			os.RemoveAll(workDir)
+		if err := d.runWithProgress(ctx, cmd, progressCb); err != nil {
+			logger.FromContext(ctx).Error("yt-dlp failed for playlist video", "index", videoIndex, "error", err)
+			d.RemoveWorkDir(workDir)
 			return nil, fmt.Errorf("download failed: %w", err)
 		}
 	} else {
-		output, err := cmd.CombinedOutput()
+		output, err := combinedOutput(ctx, cmd)
 		if err != nil {
-			logger.Error("yt-dlp failed for playlist video", "index", videoIndex, "error", err, "output", string(output))
-			os.RemoveAll(workDir)
+			logger.FromContext(ctx).Error("yt-dlp failed for playlist video", "index", videoIndex, "error", err, "output", string(output))
+			d.RemoveWorkDir(workDir)
 			return nil, fmt.Errorf("download failed: %w - %s", err, string(output))
 		}
 	}
 
 	// Find the downloaded file
-	files, err := filepath.Glob(filepath.Join(workDir, "*"))
-	if err != nil || len(files) == 0 {
-		os.RemoveAll(workDir)
-		return nil, fmt.Errorf("no file downloaded")
+	files, _ := filepath.Glob(filepath.Join(workDir, "*"))
+	files = skipHome(files)
+	_, thumbSrc := splitThumbnail(files)
+	filePath, err := downloadedFile(workDir, files)
+	if err != nil {
+		d.RemoveWorkDir(workDir)
+		return nil, err
 	}
 
-	filePath := files[0]
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		os.RemoveAll(workDir)
+		d.RemoveWorkDir(workDir)
 		return nil, fmt.Errorf("failed to stat downloaded file: %w", err)
 	}
 
@@ -535,17 +956,17 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 	title := strings.TrimSuffix(fileName, filepath.Ext(fileName))
 
 	// Check video codec and apply same processing as single video download
-	codec, err := GetVideoCodec(filePath)
+	codec, err := d.GetVideoCodec(filePath)
 	if err != nil {
-		logger.Warn("Failed to get video codec, assuming needs re-encoding", "error", err)
+		logger.FromContext(ctx).Warn("Failed to get video codec, assuming needs re-encoding", "error", err)
 		codec = "unknown"
 	}
 
-	logger.Info("Downloaded playlist video codec", "index", videoIndex, "codec", codec, "file", fileName)
+	logger.FromContext(ctx).Info("Downloaded playlist video codec", "index", videoIndex, "codec", codec, "file", fileName)
 
 	// Re-encode if codec is not H.264 compatible (same logic as single video)
 	if !IsH264Compatible(codec) {
-		logger.Info("Re-encoding playlist video required", "index", videoIndex, "codec", codec, "target", "h264")
+		logger.FromContext(ctx).Info("Re-encoding playlist video required", "index", videoIndex, "codec", codec, "target", "h264")
 
 		// Notify progress callback about encoding phase
 		if progressCb != nil {
@@ -559,7 +980,7 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 		// Re-encode to H.264
 		newPath, err := d.ReencodeToH264(ctx, filePath, progressCb)
 		if err != nil {
-			os.RemoveAll(workDir)
+			d.RemoveWorkDir(workDir)
 			return nil, fmt.Errorf("failed to re-encode to H.264: %w", err)
 		}
 
@@ -571,14 +992,14 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 		// Update file info
 		fileInfo, err = os.Stat(filePath)
 		if err != nil {
-			os.RemoveAll(workDir)
+			d.RemoveWorkDir(workDir)
 			return nil, fmt.Errorf("failed to stat re-encoded file: %w", err)
 		}
 
-		logger.Info("Re-encoding complete for playlist video", "index", videoIndex, "newSize", fileInfo.Size())
+		logger.FromContext(ctx).Info("Re-encoding complete for playlist video", "index", videoIndex, "newSize", fileInfo.Size())
 	} else {
 		// Apply faststart for better streaming
-		logger.Info("Applying faststart to playlist video", "index", videoIndex, "codec", codec)
+		logger.FromContext(ctx).Info("Applying faststart to playlist video", "index", videoIndex, "codec", codec)
 
 		dir := filepath.Dir(filePath)
 		baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
@@ -593,10 +1014,15 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 			fastStartPath,
 		}
 
-		cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-		output, err := cmd.CombinedOutput()
+		var output []byte
+		err = ensureFreeSpace(dir, fileInfo.Size())
+		if err == nil {
+			cmd := d.command(ctx, "ffmpeg", args...)
+			output, err = combinedOutput(ctx, cmd)
+			recordUsage(ctx, cmd)
+		}
 		if err != nil {
-			logger.Warn("Failed to apply faststart to playlist video, using original", "index", videoIndex, "error", err, "output", string(output))
+			logger.FromContext(ctx).Warn("Failed to apply faststart to playlist video, using original", "index", videoIndex, "error", err, "output", string(output))
 		} else {
 			// Replace original with faststart version
 			os.Remove(filePath)
@@ -606,16 +1032,16 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 			// Update file info
 			fileInfo, err = os.Stat(filePath)
 			if err != nil {
-				os.RemoveAll(workDir)
+				d.RemoveWorkDir(workDir)
 				return nil, fmt.Errorf("failed to stat faststart file: %w", err)
 			}
 
-			logger.Info("Faststart applied to playlist video", "index", videoIndex, "newSize", fileInfo.Size())
+			logger.FromContext(ctx).Info("Faststart applied to playlist video", "index", videoIndex, "newSize", fileInfo.Size())
 		}
 	}
 
 	// Get video metadata (duration, dimensions)
-	mediaInfo, _ := GetMediaInfo(filePath)
+	mediaInfo, _ := d.GetMediaInfo(filePath)
 	var duration float64
 	var width, height int
 	if mediaInfo != nil {
@@ -635,6 +1061,8 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 		ContentType: getContentType(filePath),
 		IsSplit:     false,
 		Parts:       nil,
+
+		ThumbnailPath: d.platformThumbnail(ctx, thumbSrc),
 	}, nil
 }
 
@@ -642,7 +1070,7 @@ func (d *Downloader) DownloadPlaylistVideo(ctx context.Context, playlistURL stri
 func (d *Downloader) Cleanup(result *DownloadResult) {
 	if result != nil && result.FilePath != "" {
 		dir := filepath.Dir(result.FilePath)
-		os.RemoveAll(dir)
+		d.RemoveWorkDir(dir)
 		logger.Debug("Cleaned up download", "dir", dir)
 	}
 }
@@ -681,20 +1109,6 @@ func IsValidURL(s string) bool {
 	return true
 }
 
-// ExtractURLs extracts all URLs from a message text
-func ExtractURLs(text string) []string {
-	var urls []string
-	words := strings.Fields(text)
-	for _, word := range words {
-		// Clean up common URL wrapping
-		word = strings.Trim(word, "<>()[]\"'")
-		if IsValidURL(word) {
-			urls = append(urls, word)
-		}
-	}
-	return urls
-}
-
 func getContentType(filePath string) string {
 	ext := strings.ToLower(filepath.Ext(filePath))
 	switch ext {
@@ -708,13 +1122,25 @@ func getContentType(filePath string) string {
 		return "video/quicktime"
 	case ".avi":
 		return "video/x-msvideo"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".m4a":
+		return "audio/mp4"
+	case ".ogg":
+		return "audio/ogg"
+	case ".gif":
+		return "image/gif"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
 	default:
 		return "video/mp4"
 	}
 }
 
 // GetMediaInfo uses ffprobe to get video duration, bitrate, and dimensions
-func GetMediaInfo(filePath string) (*MediaInfo, error) {
+func (d *Downloader) GetMediaInfo(filePath string) (*MediaInfo, error) {
 	// Use ffprobe to get video info in JSON format
 	args := []string{
 		"-v", "quiet",
@@ -724,7 +1150,7 @@ func GetMediaInfo(filePath string) (*MediaInfo, error) {
 		filePath,
 	}
 
-	cmd := exec.Command("ffprobe", args...)
+	cmd := d.command(context.Background(), "ffprobe", args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("ffprobe failed: %w", err)
@@ -735,7 +1161,8 @@ func GetMediaInfo(filePath string) (*MediaInfo, error) {
 		Format struct {
 			Duration string `json:"duration"`
 			Size     string `json:"size"`
-			BitRate  string `json:"bit_rate"`
+			BitRate  string            `json:"bit_rate"`
+			Tags     map[string]string `json:"tags"`
 		} `json:"format"`
 		Streams []struct {
 			CodecType string `json:"codec_type"`
@@ -764,17 +1191,29 @@ func GetMediaInfo(filePath string) (*MediaInfo, error) {
 		}
 	}
 
+	// Tag keys are lowercase for MP3/MP4 but uppercase in some containers
+	tag := func(name string) string {
+		for k, v := range result.Format.Tags {
+			if strings.EqualFold(k, name) {
+				return v
+			}
+		}
+		return ""
+	}
+
 	return &MediaInfo{
 		Duration: duration,
 		Bitrate:  bitrate,
 		FileSize: size,
 		Width:    width,
 		Height:   height,
+		Title:    tag("title"),
+		Artist:   tag("artist"),
 	}, nil
 }
 
 // GetVideoCodec returns the video codec name (e.g., "h264", "vp9", "av1")
-func GetVideoCodec(filePath string) (string, error) {
+func (d *Downloader) GetVideoCodec(filePath string) (string, error) {
 	args := []string{
 		"-v", "quiet",
 		"-select_streams", "v:0",
@@ -783,7 +1222,7 @@ func GetVideoCodec(filePath string) (string, error) {
 		filePath,
 	}
 
-	cmd := exec.Command("ffprobe", args...)
+	cmd := d.command(context.Background(), "ffprobe", args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("ffprobe failed: %w", err)
@@ -800,7 +1239,7 @@ func IsH264Compatible(codec string) bool {
 }
 
 // GetAudioCodec returns the audio codec name (e.g., "aac", "opus", "vorbis")
-func GetAudioCodec(filePath string) (string, error) {
+func (d *Downloader) GetAudioCodec(filePath string) (string, error) {
 	args := []string{
 		"-v", "quiet",
 		"-select_streams", "a:0",
@@ -808,7 +1247,7 @@ func GetAudioCodec(filePath string) (string, error) {
 		"-of", "csv=p=0",
 		filePath,
 	}
-	cmd := exec.Command("ffprobe", args...)
+	cmd := d.command(context.Background(), "ffprobe", args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("ffprobe audio codec failed: %w", err)
@@ -817,7 +1256,7 @@ func GetAudioCodec(filePath string) (string, error) {
 }
 
 // GetPixelFormat returns the pixel format (e.g., "yuv420p", "yuv420p10le")
-func GetPixelFormat(filePath string) (string, error) {
+func (d *Downloader) GetPixelFormat(filePath string) (string, error) {
 	args := []string{
 		"-v", "quiet",
 		"-select_streams", "v:0",
@@ -825,7 +1264,7 @@ func GetPixelFormat(filePath string) (string, error) {
 		"-of", "csv=p=0",
 		filePath,
 	}
-	cmd := exec.Command("ffprobe", args...)
+	cmd := d.command(context.Background(), "ffprobe", args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("ffprobe pixel format failed: %w", err)
@@ -855,10 +1294,17 @@ func CanStreamCopy(videoCodec, audioCodec, pixFmt string) bool {
 // ReencodeToH264 converts a video to H.264/AAC format for Telegram compatibility
 // Returns the path to the new file (original file is kept)
 func (d *Downloader) ReencodeToH264(ctx context.Context, filePath string, progressCb ProgressCallback) (string, error) {
-	// Get duration for progress calculation
-	mediaInfo, err := GetMediaInfo(filePath)
+	return d.reencodeToH264(ctx, filePath, encodeFilters{}, progressCb)
+}
+
+// reencodeToH264 is ReencodeToH264 that also applies filters: stabilization
+// and burned-in subtitles.
+func (d *Downloader) reencodeToH264(ctx context.Context, filePath string, filters encodeFilters, progressCb ProgressCallback) (string, error) {
+	// Get duration for progress calculation; ffmpeg copes without one
+	mediaInfo, err := d.probeMedia(ctx, filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to get media info: %w", err)
+		logger.FromContext(ctx).Warn("No duration for encoding progress", "error", err)
+		mediaInfo = &MediaInfo{}
 	}
 
 	// Create output file path
@@ -866,22 +1312,19 @@ func (d *Downloader) ReencodeToH264(ctx context.Context, filePath string, progre
 	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
 	outputPath := filepath.Join(dir, baseName+"_h264.mp4")
 
-	logger.Info("Re-encoding to H.264", "input", filePath, "output", outputPath)
-
-	// Build ffmpeg command
-	args := []string{
-		"-i", filePath,
-		"-c:v", "libx264",
-		"-preset", "fast",
-		"-crf", "23",
-		"-pix_fmt", "yuv420p",
-		"-c:a", "aac",
-		"-movflags", "+faststart",
-		"-y", // Overwrite output
-		outputPath,
+	// Re-encoded output is roughly the size of the input (larger for 4K/8K sources)
+	need := mediaInfo.FileSize
+	if mediaInfo.Height > 1080 {
+		need += need / 2
 	}
+	if err := ensureFreeSpace(dir, need); err != nil {
+		return "", err
+	}
+
+	logger.FromContext(ctx).Info("Re-encoding to H.264", "input", filePath, "output", outputPath, "filters", filters.chain())
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd := d.command(ctx, "ffmpeg", reencodeArgs(filePath, outputPath, filters)...)
+	defer recordUsage(ctx, cmd)
 
 	// Capture stderr for progress parsing
 	stderr, err := cmd.StderrPipe()
@@ -900,6 +1343,7 @@ func (d *Downloader) ReencodeToH264(ctx context.Context, filePath string, progre
 			timeRe := regexp.MustCompile(`time=(\d+):(\d+):(\d+\.?\d*)`)
 			for scanner.Scan() {
 				line := scanner.Text()
+				OutputTailFrom(ctx).add("ffmpeg", line)
 				if matches := timeRe.FindStringSubmatch(line); matches != nil {
 					var hours, mins int
 					var secs float64
@@ -923,7 +1367,8 @@ func (d *Downloader) ReencodeToH264(ctx context.Context, filePath string, progre
 		go func() {
 			scanner := bufio.NewScanner(stderr)
 			for scanner.Scan() {
-				logger.Debug("ffmpeg", "line", scanner.Text())
+				logger.FromContext(ctx).Debug("ffmpeg", "line", scanner.Text())
+				OutputTailFrom(ctx).add("ffmpeg", scanner.Text())
 			}
 		}()
 	}
@@ -932,63 +1377,85 @@ func (d *Downloader) ReencodeToH264(ctx context.Context, filePath string, progre
 		return "", fmt.Errorf("ffmpeg encoding failed: %w", err)
 	}
 
-	logger.Info("Re-encoding complete", "output", outputPath)
+	logger.FromContext(ctx).Info("Re-encoding complete", "output", outputPath)
 	return outputPath, nil
 }
 
-// NeedsSplit returns true if the file is larger than MaxUploadSize
-func NeedsSplit(fileSize int64) bool {
-	return fileSize > MaxUploadSize
+// reencodeArgs returns the ffmpeg arguments for an H.264/AAC re-encode
+// through filters.
+func reencodeArgs(input, output string, filters encodeFilters) []string {
+	args := []string{"-i", input}
+	if chain := filters.chain(); chain != "" {
+		args = append(args, "-vf", chain)
+	}
+	return append(args,
+		"-c:v", "libx264",
+		"-preset", "fast",
+		"-crf", "23",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-movflags", "+faststart",
+		"-y", // Overwrite output
+		output,
+	)
+}
+
+// NeedsSplit returns true if the file is larger than the Upload limit
+func (l UploadLimits) NeedsSplit(fileSize int64) bool {
+	return fileSize > l.Upload
 }
 
 // CalculateNumParts returns the number of parts needed for splitting
-func CalculateNumParts(fileSize int64) int {
-	return int(math.Ceil(float64(fileSize) / float64(MaxSplitSize)))
+func (l UploadLimits) CalculateNumParts(fileSize int64) int {
+	return int(math.Ceil(float64(fileSize) / float64(l.Split)))
 }
 
-// SplitVideo splits a video into parts of approximately MaxSplitSize.
-// Uses stream copy (-c copy) for H264+AAC+8-bit sources (zero RAM overhead).
-// Falls back to full re-encode with memory-safe settings for incompatible codecs.
+// SplitVideo splits a video into parts of approximately the Split limit.
+// Uses stream copy (-c copy) for H264+AAC+8-bit sources (zero RAM overhead),
+// cutting at the keyframe nearest each boundary. If sparse keyframes make a
+// copied part exceed the Upload limit, or the codecs are incompatible, it falls
+// back to a full re-encode with memory-safe settings and forced keyframes.
 func (d *Downloader) SplitVideo(ctx context.Context, filePath string, progressCb ProgressCallback) ([]PartInfo, error) {
-	// Get media info
-	mediaInfo, err := GetMediaInfo(filePath)
+	// Get media info, measuring the duration if the container lacks it
+	mediaInfo, err := d.probeMedia(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get media info: %w", err)
-	}
-
-	if mediaInfo.Duration <= 0 {
-		return nil, fmt.Errorf("invalid video duration: %f", mediaInfo.Duration)
+		return nil, err
 	}
 	if mediaInfo.FileSize <= 0 {
 		return nil, fmt.Errorf("invalid file size from ffprobe: %d", mediaInfo.FileSize)
 	}
 
 	// Detect codecs to determine split strategy
-	videoCodec, err := GetVideoCodec(filePath)
+	videoCodec, err := d.GetVideoCodec(filePath)
 	if err != nil {
-		logger.Warn("Failed to detect video codec, will re-encode", "error", err)
+		logger.FromContext(ctx).Warn("Failed to detect video codec, will re-encode", "error", err)
 		videoCodec = "unknown"
 	}
 
-	audioCodec, err := GetAudioCodec(filePath)
+	audioCodec, err := d.GetAudioCodec(filePath)
 	if err != nil {
-		logger.Warn("Failed to detect audio codec, will re-encode audio", "error", err)
+		logger.FromContext(ctx).Warn("Failed to detect audio codec, will re-encode audio", "error", err)
 		audioCodec = "unknown"
 	}
 
-	pixFmt, err := GetPixelFormat(filePath)
+	pixFmt, err := d.GetPixelFormat(filePath)
 	if err != nil {
-		logger.Warn("Failed to detect pixel format, will re-encode", "error", err)
+		logger.FromContext(ctx).Warn("Failed to detect pixel format, will re-encode", "error", err)
 		pixFmt = "unknown"
 	}
 
 	canStreamCopy := CanStreamCopy(videoCodec, audioCodec, pixFmt)
 
+	// Parts together take about as much space as the source
+	if err := ensureFreeSpace(filepath.Dir(filePath), mediaInfo.FileSize); err != nil {
+		return nil, err
+	}
+
 	// Calculate number of parts and segment duration
-	numParts := CalculateNumParts(mediaInfo.FileSize)
+	numParts := d.limits.CalculateNumParts(mediaInfo.FileSize)
 	segmentDuration := mediaInfo.Duration / float64(numParts)
 
-	logger.Info("Splitting video",
+	logger.FromContext(ctx).Info("Splitting video",
 		"fileSize", mediaInfo.FileSize,
 		"duration", mediaInfo.Duration,
 		"numParts", numParts,
@@ -996,52 +1463,125 @@ func (d *Downloader) SplitVideo(ctx context.Context, filePath string, progressCb
 		"canStreamCopy", canStreamCopy,
 	)
 
-	// Create output pattern
-	dir := filepath.Dir(filePath)
-	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
-	outputPattern := filepath.Join(dir, baseName+"_part%03d.mp4")
-
-	// Build ffmpeg args conditionally
-	var args []string
 	if canStreamCopy {
 		// Branch A: Stream copy — zero RAM, instant split
-		logger.Info("Splitting with stream copy (H264+AAC+8bit)",
+		logger.FromContext(ctx).Info("Splitting with stream copy (H264+AAC+8bit)",
 			"videoCodec", videoCodec, "audioCodec", audioCodec, "pixFmt", pixFmt)
-		args = []string{
-			"-i", filePath,
-			"-c", "copy",
-			"-f", "segment",
-			"-segment_time", fmt.Sprintf("%.2f", segmentDuration),
-			"-segment_format_options", "movflags=+faststart",
-			"-reset_timestamps", "1",
-			"-y",
-			outputPattern,
+		// Cut where the cumulative packet size says a part is full, so VBR
+		// sources don't produce uneven (and oversized) parts
+		cuts, err := d.SizeCutPoints(ctx, filePath, d.limits.SizeSplit)
+		if err == nil && len(cuts) == 0 {
+			err = fmt.Errorf("no cut points for a %d byte file", mediaInfo.FileSize)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			logger.FromContext(ctx).Warn("Failed to plan size-based cuts, splitting by duration", "error", err)
+			cuts = nil
+		} else {
+			numParts = len(cuts) + 1
+			segmentDuration = mediaInfo.Duration / float64(numParts)
+			logger.FromContext(ctx).Info("Planned size-based cut points", "numParts", numParts, "cuts", cuts)
+		}
+		args := splitArgs(filePath, segmentDuration, cuts, true)
+		parts, err := d.runSplit(ctx, filePath, args, mediaInfo.Duration, segmentDuration, numParts, progressCb)
+		if err != nil {
+			return nil, err
+		}
+		oversized := d.limits.oversizedParts(parts)
+		if len(oversized) == 0 {
+			return parts, nil
+		}
+		// Keyframes too far apart to cut near the boundaries: the parts
+		// can't be uploaded, so re-encode with keyframes where we need them
+		logger.FromContext(ctx).Warn("Split part exceeds the upload limit after -c copy split, re-encoding",
+			"parts", oversized, "maxUploadSize", d.limits.Upload, "file", filePath)
+		for _, p := range parts {
+			os.Remove(p.FilePath)
 		}
 	} else {
-		// Branch B: Full re-encode with memory-safe settings
-		logger.Info("Splitting with full re-encode (incompatible source)",
+		logger.FromContext(ctx).Info("Splitting with full re-encode (incompatible source)",
 			"videoCodec", videoCodec, "audioCodec", audioCodec, "pixFmt", pixFmt)
-		args = []string{
-			"-i", filePath,
+	}
+
+	// Branch B: Full re-encode with memory-safe settings
+	numParts = d.limits.CalculateNumParts(mediaInfo.FileSize)
+	segmentDuration = mediaInfo.Duration / float64(numParts)
+	args := splitArgs(filePath, segmentDuration, nil, false)
+	parts, err := d.runSplit(ctx, filePath, args, mediaInfo.Duration, segmentDuration, numParts, progressCb)
+	if err != nil {
+		return nil, err
+	}
+	if oversized := d.limits.oversizedParts(parts); len(oversized) > 0 {
+		logger.FromContext(ctx).Warn("Re-encoded split part exceeds the upload limit",
+			"parts", oversized, "maxUploadSize", d.limits.Upload, "file", filePath)
+	}
+	return parts, nil
+}
+
+// splitArgs returns the ffmpeg arguments that split filePath into
+// baseName_partNNN.mp4 segments at the given cut times, or every
+// segmentDuration seconds if cuts is empty. Stream copy cuts at existing
+// keyframes; re-encoding forces a keyframe at every boundary so the segments
+// come out at the requested length.
+func splitArgs(filePath string, segmentDuration float64, cuts []float64, streamCopy bool) []string {
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outputPattern := filepath.Join(dir, baseName+"_part%03d.mp4")
+	segmentTime := fmt.Sprintf("%.2f", segmentDuration)
+
+	args := []string{"-i", filePath}
+	if streamCopy {
+		args = append(args, "-c", "copy")
+	} else {
+		args = append(args,
 			"-c:v", "libx264",
 			"-preset", "ultrafast",
 			"-crf", "23",
 			"-threads", "1",
 			"-vf", "scale=-2:720",
 			"-pix_fmt", "yuv420p",
+			"-force_key_frames", "expr:gte(t,n_forced*"+segmentTime+")",
 			"-c:a", "aac",
-			"-f", "segment",
-			"-segment_time", fmt.Sprintf("%.2f", segmentDuration),
-			"-segment_format_options", "movflags=+faststart",
-			"-reset_timestamps", "1",
-			"-y",
-			outputPattern,
+		)
+	}
+	args = append(args, "-f", "segment")
+	if len(cuts) > 0 {
+		times := make([]string, len(cuts))
+		for i, c := range cuts {
+			times[i] = strconv.FormatFloat(c, 'f', 6, 64)
 		}
+		args = append(args, "-segment_times", strings.Join(times, ","))
+	} else {
+		args = append(args, "-segment_time", segmentTime)
 	}
+	return append(args,
+		"-segment_format_options", "movflags=+faststart",
+		"-reset_timestamps", "1",
+		"-y",
+		outputPattern,
+	)
+}
 
-	logger.Debug("Running ffmpeg split", "args", args)
+// oversizedParts returns the numbers of parts larger than the Upload limit.
+func (l UploadLimits) oversizedParts(parts []PartInfo) []int {
+	var oversized []int
+	for _, p := range parts {
+		if p.FileSize > l.Upload {
+			oversized = append(oversized, p.PartNum)
+		}
+	}
+	return oversized
+}
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+// runSplit runs an ffmpeg segment split built by splitArgs, reporting
+// progress per part, and returns the created parts in order.
+func (d *Downloader) runSplit(ctx context.Context, filePath string, args []string, duration, segmentDuration float64, numParts int, progressCb ProgressCallback) ([]PartInfo, error) {
+	logger.FromContext(ctx).Debug("Running ffmpeg split", "args", args)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	defer recordUsage(ctx, cmd)
 
 	// Capture stderr for progress parsing
 	stderr, err := cmd.StderrPipe()
@@ -1061,6 +1601,7 @@ func (d *Downloader) SplitVideo(ctx context.Context, filePath string, progressCb
 			timeRe := regexp.MustCompile(`time=(\d+):(\d+):(\d+\.?\d*)`)
 			for scanner.Scan() {
 				line := scanner.Text()
+				OutputTailFrom(ctx).add("ffmpeg", line)
 				if matches := timeRe.FindStringSubmatch(line); matches != nil {
 					var hours, mins int
 					var secs float64
@@ -1068,7 +1609,7 @@ func (d *Downloader) SplitVideo(ctx context.Context, filePath string, progressCb
 					fmt.Sscanf(matches[2], "%d", &mins)
 					fmt.Sscanf(matches[3], "%f", &secs)
 					currentTime := float64(hours*3600+mins*60) + secs
-					percent := (currentTime / mediaInfo.Duration) * 100
+					percent := (currentTime / duration) * 100
 					if percent > 100 {
 						percent = 100
 					}
@@ -1091,7 +1632,8 @@ func (d *Downloader) SplitVideo(ctx context.Context, filePath string, progressCb
 		go func() {
 			scanner := bufio.NewScanner(stderr)
 			for scanner.Scan() {
-				logger.Debug("ffmpeg", "line", scanner.Text())
+				logger.FromContext(ctx).Debug("ffmpeg", "line", scanner.Text())
+				OutputTailFrom(ctx).add("ffmpeg", scanner.Text())
 			}
 		}()
 	}
@@ -1101,6 +1643,8 @@ func (d *Downloader) SplitVideo(ctx context.Context, filePath string, progressCb
 	}
 
 	// Find all created parts
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
 	pattern := filepath.Join(dir, baseName+"_part*.mp4")
 	partFiles, err := filepath.Glob(pattern)
 	if err != nil || len(partFiles) == 0 {
@@ -1113,7 +1657,7 @@ func (d *Downloader) SplitVideo(ctx context.Context, filePath string, progressCb
 	for i, partFile := range partFiles {
 		info, err := os.Stat(partFile)
 		if err != nil {
-			logger.Warn("Failed to stat split part", "file", partFile, "error", err)
+			logger.FromContext(ctx).Warn("Failed to stat split part", "file", partFile, "error", err)
 			continue
 		}
 		parts = append(parts, PartInfo{
@@ -1127,18 +1671,6 @@ func (d *Downloader) SplitVideo(ctx context.Context, filePath string, progressCb
 		return nil, fmt.Errorf("failed to get info for split parts")
 	}
 
-	logger.Info("Split complete", "numParts", len(parts))
-
-	// Warn if any -c copy part exceeds MaxUploadSize (keyframe overshoot)
-	if canStreamCopy {
-		for _, p := range parts {
-			if p.FileSize > MaxUploadSize {
-				logger.Warn("Split part exceeds MaxUploadSize after -c copy split",
-					"part", p.PartNum, "size", p.FileSize,
-					"maxUploadSize", int64(MaxUploadSize), "file", p.FilePath)
-			}
-		}
-	}
-
+	logger.FromContext(ctx).Info("Split complete", "numParts", len(parts))
 	return parts, nil
 }
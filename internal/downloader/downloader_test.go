@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -71,21 +72,24 @@ func TestIs420p(t *testing.T) {
 	}
 }
 
+// local are the limits of a local Bot API server, the default.
+var local = NewUploadLimits(LocalAPIUploadLimit)
+
 func TestCalculateNumParts(t *testing.T) {
 	tests := []struct {
 		name     string
 		fileSize int64
 		want     int
 	}{
-		{"exactly 1.7GB", MaxSplitSize, 1},
-		{"exactly 3.4GB", 2 * MaxSplitSize, 2},
+		{"exactly 1.7GB", local.Split, 1},
+		{"exactly 3.4GB", 2 * local.Split, 2},
 		{"3.5GB needs 3 parts", 3500 * 1024 * 1024, 3},
 		{"1.8GB needs 2 parts", 1800 * 1024 * 1024, 2},
 		{"small file", 100 * 1024 * 1024, 1},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := CalculateNumParts(tt.fileSize); got != tt.want {
+			if got := local.CalculateNumParts(tt.fileSize); got != tt.want {
 				t.Errorf("CalculateNumParts(%d) = %d, want %d", tt.fileSize, got, tt.want)
 			}
 		})
@@ -98,14 +102,14 @@ func TestNeedsSplit(t *testing.T) {
 		fileSize int64
 		want     bool
 	}{
-		{"exactly MaxUploadSize", MaxUploadSize, false},
-		{"one byte over MaxUploadSize", MaxUploadSize + 1, true},
+		{"exactly the Upload limit", local.Upload, false},
+		{"one byte over the Upload limit", local.Upload + 1, true},
 		{"well under threshold", 1024 * 1024 * 1024, false},
 		{"well over threshold", 3 * 1024 * 1024 * 1024, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NeedsSplit(tt.fileSize); got != tt.want {
+			if got := local.NeedsSplit(tt.fileSize); got != tt.want {
 				t.Errorf("NeedsSplit(%d) = %v, want %v", tt.fileSize, got, tt.want)
 			}
 		})
@@ -143,3 +147,169 @@ func TestCanStreamCopyDecision(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitArgs(t *testing.T) {
+	contains := func(args []string, want ...string) bool {
+		for i := 0; i+len(want) <= len(args); i++ {
+			match := true
+			for j := range want {
+				if args[i+j] != want[j] {
+					match = false
+					break
+				}
+			}
+			if match {
+				return true
+			}
+		}
+		return false
+	}
+
+	copyArgs := splitArgs("/tmp/x/video.mp4", 600, nil, true)
+	if !contains(copyArgs, "-c", "copy") {
+		t.Errorf("stream copy split should use -c copy: %v", copyArgs)
+	}
+	if contains(copyArgs, "-c:v", "libx264") {
+		t.Errorf("stream copy split should not re-encode: %v", copyArgs)
+	}
+	if got := copyArgs[len(copyArgs)-1]; got != "/tmp/x/video_part%03d.mp4" {
+		t.Errorf("output pattern = %q", got)
+	}
+
+	encodeArgs := splitArgs("/tmp/x/video.mp4", 600, nil, false)
+	if !contains(encodeArgs, "-force_key_frames", "expr:gte(t,n_forced*600.00)") {
+		t.Errorf("re-encode split should force keyframes at segment boundaries: %v", encodeArgs)
+	}
+	if !contains(encodeArgs, "-segment_time", "600.00") {
+		t.Errorf("missing segment time: %v", encodeArgs)
+	}
+
+	cutArgs := splitArgs("/tmp/x/video.mp4", 600, []float64{512.5, 1100}, true)
+	if !contains(cutArgs, "-segment_times", "512.500000,1100.000000") {
+		t.Errorf("cut points should be passed as -segment_times: %v", cutArgs)
+	}
+	if contains(cutArgs, "-segment_time", "600.00") {
+		t.Errorf("-segment_time should not be combined with cut points: %v", cutArgs)
+	}
+}
+
+func TestOversizedParts(t *testing.T) {
+	parts := []PartInfo{
+		{PartNum: 1, FileSize: local.Split},
+		{PartNum: 2, FileSize: local.Upload + 1},
+		{PartNum: 3, FileSize: local.Upload},
+	}
+	got := local.oversizedParts(parts)
+	if len(got) != 1 || got[0] != 2 {
+		t.Errorf("oversizedParts = %v, want [2]", got)
+	}
+}
+
+func TestFastStartArgs(t *testing.T) {
+	copyAll := strings.Join(fastStartArgs("in.mkv", "out.mp4", false), " ")
+	if copyAll != "-i in.mkv -c:v copy -c:a copy -movflags +faststart -y out.mp4" {
+		t.Errorf("fastStartArgs(copy) = %q", copyAll)
+	}
+
+	aac := strings.Join(fastStartArgs("in.mkv", "out.mp4", true), " ")
+	if !strings.Contains(aac, "-c:v copy -c:a aac") {
+		t.Errorf("fastStartArgs(transcode audio) should copy video and encode AAC: %q", aac)
+	}
+}
+
+func TestCanUploadAsIs(t *testing.T) {
+	tests := map[string]bool{
+		"/tmp/video.mp4":  true,
+		"/tmp/video.MP4":  true,
+		"/tmp/video.mkv":  false,
+		"/tmp/video.webm": false,
+	}
+	for path, want := range tests {
+		if got := canUploadAsIs(path); got != want {
+			t.Errorf("canUploadAsIs(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestThumbnailSeek(t *testing.T) {
+	tests := []struct {
+		duration float64
+		want     float64
+	}{
+		{0, 0},
+		{30, 3},
+		{600, thumbnailMaxSeek},
+	}
+	for _, tt := range tests {
+		if got := thumbnailSeek(tt.duration); got != tt.want {
+			t.Errorf("thumbnailSeek(%v) = %v, want %v", tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestThumbnailArgs(t *testing.T) {
+	args := strings.Join(thumbnailArgs("in.mp4", "out.jpg", 3), " ")
+	if !strings.HasPrefix(args, "-ss 3.00 -i in.mp4 -frames:v 1") {
+		t.Errorf("thumbnailArgs should seek before the input and take one frame: %q", args)
+	}
+	if !strings.Contains(args, "min(320,iw)") || !strings.HasSuffix(args, "out.jpg") {
+		t.Errorf("thumbnailArgs should cap the size at 320px: %q", args)
+	}
+}
+
+func TestSplitThumbnail(t *testing.T) {
+	files := []string{
+		"/work/" + platformThumbName + ".webp",
+		"/work/My Video.mp4",
+	}
+	media, thumb := splitThumbnail(files)
+	if len(media) != 1 || media[0] != "/work/My Video.mp4" {
+		t.Errorf("splitThumbnail media = %v", media)
+	}
+	if thumb != "/work/"+platformThumbName+".webp" {
+		t.Errorf("splitThumbnail thumb = %q", thumb)
+	}
+
+	media, thumb = splitThumbnail([]string{"/work/thumbnail.mp4"})
+	if len(media) != 1 || thumb != "" {
+		t.Errorf("a video titled like a thumbnail must stay media: %v %q", media, thumb)
+	}
+}
+
+func TestWriteThumbnailArgs(t *testing.T) {
+	args := strings.Join(writeThumbnailArgs("/work"), " ")
+	if args != "--write-thumbnail -o thumbnail:/work/"+platformThumbName+".%(ext)s" {
+		t.Errorf("writeThumbnailArgs = %q", args)
+	}
+}
+
+func TestValidSubtitleLang(t *testing.T) {
+	for _, lang := range []string{"en", "pt-BR", "zh-Hans", "fil"} {
+		if !ValidSubtitleLang(lang) {
+			t.Errorf("ValidSubtitleLang(%q) = false, want true", lang)
+		}
+	}
+	for _, lang := range []string{"", "english", "en,ru", "all", "en.*", "-en"} {
+		if ValidSubtitleLang(lang) {
+			t.Errorf("ValidSubtitleLang(%q) = true, want false", lang)
+		}
+	}
+}
+
+func TestSubtitleArgs(t *testing.T) {
+	args := strings.Join(subtitleArgs("/work", "en", "https://example.com/v"), " ")
+	for _, want := range []string{"--skip-download", "--sub-langs en,en-.*", "--convert-subs srt", "subtitle:/work/" + subtitleName + ".%(ext)s"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("subtitleArgs missing %q: %q", want, args)
+		}
+	}
+	if !strings.HasSuffix(args, "https://example.com/v") {
+		t.Errorf("subtitleArgs should end with the URL: %q", args)
+	}
+}
+
+func TestSubtitleLang(t *testing.T) {
+	if got := SubtitleLang("/work/" + subtitleName + ".en-US.srt"); got != "en-US" {
+		t.Errorf("SubtitleLang = %q, want en-US", got)
+	}
+}
@@ -0,0 +1,114 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// IsFormatUnavailable reports whether err is yt-dlp's "Requested format is
+// not available": the format list changed between the probe and the
+// download, as happens with live-ish content.
+func IsFormatUnavailable(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "requested format is not available")
+}
+
+// RefreshFormat fetches url's current format list (without a format
+// selector, so it can't fail the same way) and returns a selector of
+// concrete format IDs for opts, e.g. "137+140".
+func (d *Downloader) RefreshFormat(ctx context.Context, url string, opts Options) (string, error) {
+	cmd := d.ytdlp(ctx, "-J", "--no-playlist", "--no-warnings", url)
+	output, err := cmd.Output()
+	d.ytdlpDone(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh formats: %w", err)
+	}
+
+	var raw ytdlpInfo
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return "", fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+	selector := selectFormat(raw.Formats, opts)
+	if selector == "" {
+		return "", fmt.Errorf("no usable format among %d", len(raw.Formats))
+	}
+	logger.FromContext(ctx).Info("Refreshed format selection", "url", url, "formats", len(raw.Formats), "selector", selector)
+	return selector, nil
+}
+
+// selectFormat picks concrete format IDs from a fresh format list, with the
+// same preferences as defaultFormat: H.264 video up to the height cap plus
+// AAC audio, then any video plus audio, then a combined format. Audio-only
+// modes take the best audio. yt-dlp lists formats worst to best, so later
+// entries win ties.
+func selectFormat(formats []ytdlpFormat, opts Options) string {
+	maxHeight := opts.MaxHeight
+	if maxHeight <= 0 {
+		maxHeight = 1080
+	}
+
+	var video, audio, combined *ytdlpFormat
+	var videoScore, audioScore, combinedScore int
+	for i := range formats {
+		f := &formats[i]
+		hasVideo := f.VCodec != "" && f.VCodec != "none"
+		hasAudio := f.ACodec != "" && f.ACodec != "none"
+		switch {
+		case hasVideo && !hasAudio:
+			if s := videoRank(f, maxHeight); s >= videoScore {
+				video, videoScore = f, s
+			}
+		case hasAudio && !hasVideo:
+			s := 1
+			if strings.HasPrefix(f.ACodec, "mp4a") {
+				s = 2
+			}
+			if s >= audioScore {
+				audio, audioScore = f, s
+			}
+		case hasVideo && hasAudio:
+			if s := videoRank(f, maxHeight); s >= combinedScore {
+				combined, combinedScore = f, s
+			}
+		}
+	}
+
+	switch {
+	case opts.AudioOnly || opts.Voice:
+		if audio != nil {
+			return audio.FormatID
+		}
+		if combined != nil {
+			return combined.FormatID
+		}
+	case video != nil && audio != nil && (combined == nil || videoScore >= combinedScore):
+		return video.FormatID + "+" + audio.FormatID
+	case combined != nil:
+		return combined.FormatID
+	case video != nil:
+		return video.FormatID
+	}
+	return ""
+}
+
+// videoRank scores a video format: within the height cap beats over it,
+// H.264 beats other codecs, then taller is better.
+func videoRank(f *ytdlpFormat, maxHeight int) int {
+	score := 1
+	if f.Height <= maxHeight {
+		score += 1 << 20
+	}
+	if strings.HasPrefix(f.VCodec, "avc") {
+		score += 1 << 16
+	}
+	if f.Height <= maxHeight {
+		score += f.Height
+	} else {
+		// Over the cap: the smallest overshoot is best
+		score += max(0, 1<<16-f.Height)
+	}
+	return score
+}
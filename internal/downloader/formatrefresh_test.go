@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsFormatUnavailable(t *testing.T) {
+	err := errors.New("download failed: exit status 1: ERROR: [youtube] abc: Requested format is not available. Use --list-formats for a list of available formats")
+	if !IsFormatUnavailable(err) {
+		t.Error("IsFormatUnavailable = false for yt-dlp's format error")
+	}
+	if IsFormatUnavailable(errors.New("ERROR: Video unavailable")) || IsFormatUnavailable(nil) {
+		t.Error("IsFormatUnavailable = true for another error")
+	}
+}
+
+func TestSelectFormat(t *testing.T) {
+	formats := []ytdlpFormat{
+		{FormatID: "139", VCodec: "none", ACodec: "mp4a.40.5"},
+		{FormatID: "251", VCodec: "none", ACodec: "opus"},
+		{FormatID: "140", VCodec: "none", ACodec: "mp4a.40.2"},
+		{FormatID: "18", VCodec: "avc1.42001E", ACodec: "mp4a.40.2", Height: 360},
+		{FormatID: "136", VCodec: "avc1.4d401f", ACodec: "none", Height: 720},
+		{FormatID: "247", VCodec: "vp9", ACodec: "none", Height: 720},
+		{FormatID: "137", VCodec: "avc1.640028", ACodec: "none", Height: 1080},
+		{FormatID: "313", VCodec: "vp9", ACodec: "none", Height: 2160},
+	}
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"default", Options{}, "137+140"},
+		{"capped", Options{MaxHeight: 720}, "136+140"},
+		{"audio", Options{AudioOnly: true}, "140"},
+		{"voice", Options{Voice: true}, "140"},
+	}
+	for _, tt := range tests {
+		if got := selectFormat(formats, tt.opts); got != tt.want {
+			t.Errorf("%s: selectFormat = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	combinedOnly := []ytdlpFormat{
+		{FormatID: "hls-480", VCodec: "avc1", ACodec: "mp4a", Height: 480},
+		{FormatID: "hls-1440", VCodec: "avc1", ACodec: "mp4a", Height: 1440},
+	}
+	if got := selectFormat(combinedOnly, Options{}); got != "hls-480" {
+		t.Errorf("combined formats: selectFormat = %q, want hls-480 (within the cap)", got)
+	}
+	if got := selectFormat(nil, Options{}); got != "" {
+		t.Errorf("selectFormat(nil) = %q, want empty", got)
+	}
+}
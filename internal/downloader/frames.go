@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// MaxFrames is the most frames extracted at once: one Telegram album.
+	MaxFrames = 10
+
+	// DefaultFrames is how many frames are extracted when no count is given.
+	DefaultFrames = 6
+)
+
+// FrameTimes returns the offsets (seconds) of count evenly spaced frames in
+// a video of duration seconds, each in the middle of its slice so the first
+// and last aren't black intro/outro frames.
+func FrameTimes(duration float64, count int) []float64 {
+	if duration <= 0 || count <= 0 {
+		return nil
+	}
+	times := make([]float64, count)
+	step := duration / float64(count)
+	for i := range times {
+		times[i] = step * (float64(i) + 0.5)
+	}
+	return times
+}
+
+// ExtractFrames writes a full-resolution JPEG of filePath at each offset
+// (seconds) into its directory. Offsets past the end are skipped; it fails
+// only if no frame could be extracted. Returns the frames and their offsets.
+func (d *Downloader) ExtractFrames(ctx context.Context, filePath string, times []float64) ([]string, []float64, error) {
+	dir := filepath.Dir(filePath)
+	var paths []string
+	var used []float64
+	for i, t := range times {
+		out := filepath.Join(dir, fmt.Sprintf("frame_%02d.jpg", i+1))
+		cmd := d.command(ctx, "ffmpeg", frameArgs(filePath, out, t)...)
+		output, err := combinedOutput(ctx, cmd)
+		recordUsage(ctx, cmd)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			logger.FromContext(ctx).Warn("Failed to extract frame", "file", filePath, "at", t, "error", err, "output", string(output))
+			continue
+		}
+		if _, err := os.Stat(out); err != nil {
+			// Seeking past the end succeeds without writing anything
+			logger.FromContext(ctx).Debug("No frame at offset", "file", filePath, "at", t)
+			continue
+		}
+		paths = append(paths, out)
+		used = append(used, t)
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no frames extracted from %d offsets", len(times))
+	}
+	return paths, used, nil
+}
+
+// frameArgs returns the ffmpeg arguments for one high-quality JPEG at offset
+// seconds, seeking on the input for speed.
+func frameArgs(input, output string, offset float64) []string {
+	return []string{
+		"-ss", strconv.FormatFloat(offset, 'f', 3, 64),
+		"-i", input,
+		"-frames:v", "1",
+		"-q:v", "2",
+		"-y",
+		output,
+	}
+}
@@ -0,0 +1,23 @@
+package downloader
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameTimes(t *testing.T) {
+	if got, want := FrameTimes(60, 3), []float64{10, 30, 50}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FrameTimes(60, 3) = %v, want %v", got, want)
+	}
+	if got := FrameTimes(0, 3); got != nil {
+		t.Errorf("FrameTimes(0, 3) = %v, want nil for unknown duration", got)
+	}
+}
+
+func TestFrameArgs(t *testing.T) {
+	args := frameArgs("/tmp/in.mp4", "/tmp/frame_01.jpg", 12.5)
+	want := []string{"-ss", "12.500", "-i", "/tmp/in.mp4", "-frames:v", "1", "-q:v", "2", "-y", "/tmp/frame_01.jpg"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("frameArgs = %v, want %v", args, want)
+	}
+}
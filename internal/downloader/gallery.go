@@ -0,0 +1,254 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// MaxGalleryItems is the most photos and videos downloaded from one post.
+	MaxGalleryItems = 30
+
+	// maxPhotoSize is Telegram's limit for a photo; bigger images are
+	// re-encoded to fit.
+	maxPhotoSize = 10 * 1024 * 1024
+
+	// photoMaxSide is the longest side of re-encoded images.
+	photoMaxSide = 2560
+)
+
+// galleryRe matches the sites whose posts are often images rather than
+// videos and that gallery-dl can download.
+var galleryRe = regexp.MustCompile(`^https?://(?:[a-z0-9-]+\.)*(?:instagram\.com|twitter\.com|x\.com|reddit\.com|redd\.it|imgur\.com|tumblr\.com|pinterest\.com|flickr\.com|deviantart\.com|bsky\.app)/`)
+
+// noVideoMessages are the yt-dlp errors for posts without a video.
+var noVideoMessages = []string{
+	"no video could be found",
+	"there is no video in this post",
+	"no video formats found",
+	"no media found",
+	"does not contain a video",
+}
+
+// imageExts and videoExts are the gallery-dl downloads delivered as photos
+// and videos; anything else (metadata, audio) is ignored.
+var (
+	imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".heic": true, ".avif": true}
+	videoExts = map[string]bool{".mp4": true, ".mov": true, ".webm": true, ".m4v": true}
+)
+
+// IsGalleryURL reports whether url is on a site gallery-dl handles image
+// posts for.
+func IsGalleryURL(url string) bool {
+	return galleryRe.MatchString(strings.ToLower(url))
+}
+
+// IsNoVideo reports whether err is yt-dlp failing because the post has no
+// video, e.g. a photo tweet or Instagram image post.
+func IsNoVideo(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range noVideoMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaItem is one photo or video of a multi-item post.
+type MediaItem struct {
+	Path     string
+	IsVideo  bool
+	Width    int     // video width in pixels
+	Height   int     // video height in pixels
+	Duration float64 // video duration in seconds
+}
+
+// DownloadGallery downloads the photos and videos of a post with gallery-dl:
+// image posts yt-dlp has no video for, carousels and galleries. Photos
+// Telegram won't take are re-encoded as JPEG, videos in other codecs as
+// H.264; videos over the upload limit are skipped. The result's Media are the
+// items in post order; FilePath is the first of them.
+func (d *Downloader) DownloadGallery(ctx context.Context, postURL string, progressCb ProgressCallback) (*DownloadResult, error) {
+	workDir, err := d.newWorkDir(postURL)
+	if err != nil {
+		return nil, err
+	}
+
+	args := galleryArgs(workDir, postURL)
+	logger.FromContext(ctx).Debug("Running gallery-dl", "args", args)
+	if progressCb != nil {
+		progressCb(Progress{Phase: "downloading"})
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	cmd := d.command(cmdCtx, "gallery-dl", append(d.galleryCredentialArgs(), args...)...)
+	cmd.Dir = workDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	recordUsage(ctx, cmd)
+	if err != nil {
+		d.RemoveWorkDir(workDir)
+		return nil, fmt.Errorf("gallery-dl failed: %w - %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var media []MediaItem
+	var total int64
+	for _, f := range galleryFiles(output) {
+		var item MediaItem
+		var err error
+		switch ext := strings.ToLower(filepath.Ext(f)); {
+		case imageExts[ext]:
+			item.Path, err = d.photoCompatible(ctx, f)
+		case videoExts[ext]:
+			item, err = d.albumVideo(ctx, f)
+		default:
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				d.RemoveWorkDir(workDir)
+				return nil, ctx.Err()
+			}
+			logger.FromContext(ctx).Warn("Skipping post item Telegram can't take", "file", f, "error", err)
+			continue
+		}
+		if fi, err := os.Stat(item.Path); err == nil {
+			total += fi.Size()
+		}
+		media = append(media, item)
+	}
+	if len(media) == 0 {
+		d.RemoveWorkDir(workDir)
+		return nil, fmt.Errorf("no photos or videos in post")
+	}
+	if progressCb != nil {
+		progressCb(Progress{Phase: "downloading", Percent: 100})
+	}
+
+	logger.FromContext(ctx).Info("Downloaded post media", "url", postURL, "items", len(media))
+	return &DownloadResult{
+		FilePath:    media[0].Path,
+		FileName:    filepath.Base(media[0].Path),
+		Title:       galleryTitle(postURL),
+		FileSize:    total,
+		ContentType: getContentType(media[0].Path),
+		Media:       media,
+	}, nil
+}
+
+// albumVideo prepares a downloaded post video for a media group: H.264/AAC
+// (re-encoded if needed) and within the upload limit.
+func (d *Downloader) albumVideo(ctx context.Context, path string) (MediaItem, error) {
+	videoCodec, _ := d.GetVideoCodec(path)
+	audioCodec, _ := d.GetAudioCodec(path)
+	if !IsH264Compatible(videoCodec) || (audioCodec != "" && !IsAACCompatible(audioCodec)) {
+		encoded, err := d.ReencodeToH264(ctx, path, nil)
+		if err != nil {
+			return MediaItem{}, err
+		}
+		os.Remove(path)
+		path = encoded
+	}
+	info, err := d.GetMediaInfo(path)
+	if err != nil {
+		return MediaItem{}, fmt.Errorf("failed to get media info: %w", err)
+	}
+	if info.FileSize > d.limits.Upload {
+		return MediaItem{}, fmt.Errorf("video is %d bytes, over the upload limit", info.FileSize)
+	}
+	return MediaItem{Path: path, IsVideo: true, Width: info.Width, Height: info.Height, Duration: info.Duration}, nil
+}
+
+// galleryArgs returns the gallery-dl arguments that save up to
+// MaxGalleryItems files of postURL directly into dir.
+func galleryArgs(dir, postURL string) []string {
+	return []string{
+		"--range", fmt.Sprintf("1-%d", MaxGalleryItems),
+		"--directory", dir,
+		"--no-part",
+		postURL,
+	}
+}
+
+// galleryFiles returns the files gallery-dl reported on stdout, in post
+// order. It prints one path per file, prefixed with "# " if the file was
+// already there.
+func galleryFiles(output []byte) []string {
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		path := strings.TrimPrefix(strings.TrimSpace(line), "# ")
+		if filepath.IsAbs(path) {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// galleryCredentialArgs returns the gallery-dl flags for the configured
+// cookies file; gallery-dl has no netrc support.
+func (d *Downloader) galleryCredentialArgs() []string {
+	if d.cookiesFile == "" {
+		return nil
+	}
+	return []string{"--cookies", d.cookiesFile}
+}
+
+// galleryTitle names an image post after its site, e.g. "instagram.com post".
+func galleryTitle(postURL string) string {
+	u, err := url.Parse(postURL)
+	if err != nil || u.Host == "" {
+		return "Image post"
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") + " post"
+}
+
+// photoCompatible returns path if Telegram accepts it as a photo (a JPEG or
+// PNG up to maxPhotoSize), otherwise a JPEG re-encode of it next to path.
+func (d *Downloader) photoCompatible(ctx context.Context, path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if (ext == ".jpg" || ext == ".jpeg" || ext == ".png") && fi.Size() <= maxPhotoSize {
+		return path, nil
+	}
+
+	out := strings.TrimSuffix(path, filepath.Ext(path)) + "_photo.jpg"
+	cmd := d.command(ctx, "ffmpeg", photoArgs(path, out)...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert image: %w - %s", err, string(output))
+	}
+	os.Remove(path)
+	return out, nil
+}
+
+// photoArgs returns the ffmpeg arguments that re-encode an image as a JPEG
+// no larger than photoMaxSide on either side.
+func photoArgs(input, output string) []string {
+	side := fmt.Sprintf("%d", photoMaxSide)
+	return []string{
+		"-i", input,
+		"-vf", "scale='min(iw," + side + ")':'min(ih," + side + ")':force_original_aspect_ratio=decrease",
+		"-frames:v", "1",
+		"-q:v", "3",
+		"-y",
+		output,
+	}
+}
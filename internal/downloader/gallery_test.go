@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIsGalleryURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://www.instagram.com/p/C1a2b3c4d5e/", true},
+		{"https://x.com/user/status/1234567890", true},
+		{"https://old.reddit.com/r/pics/comments/abc123/title/", true},
+		{"https://imgur.com/a/AbCdE", true},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", false},
+		{"https://notinstagram.com/p/abc", false},
+	}
+	for _, tt := range tests {
+		if got := IsGalleryURL(tt.url); got != tt.want {
+			t.Errorf("IsGalleryURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestIsNoVideo(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("download failed: exit status 1: ERROR: [twitter] 123: No video could be found in this tweet"), true},
+		{errors.New("download failed: exit status 1: ERROR: [Instagram] C1a2: There is no video in this post"), true},
+		{errors.New("download failed: exit status 1: ERROR: [generic] Unable to download webpage: HTTP Error 404"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsNoVideo(tt.err); got != tt.want {
+			t.Errorf("IsNoVideo(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGalleryFiles(t *testing.T) {
+	output := []byte("/tmp/sushe/1/123_1.jpg\n# /tmp/sushe/1/123_2.png\n\n[twitter][warning] something\n")
+	want := []string{"/tmp/sushe/1/123_1.jpg", "/tmp/sushe/1/123_2.png"}
+	if got := galleryFiles(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("galleryFiles = %v, want %v", got, want)
+	}
+}
+
+func TestGalleryTitle(t *testing.T) {
+	if got := galleryTitle("https://www.instagram.com/p/C1a2b3c4d5e/"); got != "instagram.com post" {
+		t.Errorf("galleryTitle = %q", got)
+	}
+}
+
+func TestPlainVideo(t *testing.T) {
+	if !(Options{MaxHeight: 720}).PlainVideo() {
+		t.Error("a capped video should allow an image post fallback")
+	}
+	for _, o := range []Options{{AudioOnly: true}, {Animate: true}, {Frames: 3}, {Start: 10}} {
+		if o.PlainVideo() {
+			t.Errorf("%+v.PlainVideo() = true", o)
+		}
+	}
+}
+
+func TestPhotoContentType(t *testing.T) {
+	if got := getContentType("/tmp/001.JPG"); got != "image/jpeg" {
+		t.Errorf("getContentType(.JPG) = %q, want image/jpeg", got)
+	}
+	if got := getContentType("/tmp/002.png"); got != "image/png" {
+		t.Errorf("getContentType(.png) = %q, want image/png", got)
+	}
+}
@@ -0,0 +1,151 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// maxPlausibleBitrate is the highest average bitrate (bit/s) taken at face
+// value: a file's size over a duration implying more than this means the
+// container reported the length of a fragment, not of the video.
+const maxPlausibleBitrate = 400_000_000
+
+// probeMedia is GetMediaInfo for the math that divides by a video's length
+// (split points, compression bitrate, progress). Fragmented MP4s and
+// streams saved as they came often carry no duration or bitrate in the
+// container, or only a fragment's, so when the duration is missing or
+// implausible it is measured by reading every packet; if that fails too the
+// container is repaired with a stream-copy remux (in place) and probed
+// again. A missing size is taken from the file and a missing bitrate
+// computed from size and duration. Fails if no duration can be found.
+func (d *Downloader) probeMedia(ctx context.Context, filePath string) (*MediaInfo, error) {
+	info, err := d.GetMediaInfo(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media info: %w", err)
+	}
+	fillFileSize(info, filePath)
+	if plausibleDuration(info) {
+		fillBitrate(info)
+		return info, nil
+	}
+
+	log := logger.FromContext(ctx)
+	log.Warn("ffprobe reported no usable duration, measuring it", "file", filePath, "duration", info.Duration, "size", info.FileSize)
+	if duration, err := d.scannedDuration(ctx, filePath); err != nil {
+		log.Warn("Failed to measure duration from packets", "error", err)
+	} else {
+		info.Duration = duration
+	}
+	if !plausibleDuration(info) {
+		log.Warn("Repairing container with a remux", "file", filePath)
+		if err := d.repairContainer(ctx, filePath); err != nil {
+			return nil, fmt.Errorf("no usable duration and repair failed: %w", err)
+		}
+		if info, err = d.GetMediaInfo(filePath); err != nil {
+			return nil, fmt.Errorf("failed to get media info after repair: %w", err)
+		}
+		fillFileSize(info, filePath)
+		if !plausibleDuration(info) {
+			return nil, fmt.Errorf("invalid video duration after repair: %f", info.Duration)
+		}
+	}
+	info.Bitrate = 0
+	fillBitrate(info)
+	log.Info("Recovered media duration", "file", filePath, "duration", info.Duration, "bitrate", info.Bitrate)
+	return info, nil
+}
+
+// plausibleDuration reports whether info's duration can be trusted for
+// the file's size.
+func plausibleDuration(info *MediaInfo) bool {
+	if info.Duration <= 0 {
+		return false
+	}
+	return float64(info.FileSize)*8/info.Duration <= maxPlausibleBitrate
+}
+
+// fillFileSize takes a size ffprobe didn't report from the file itself.
+func fillFileSize(info *MediaInfo, filePath string) {
+	if info.FileSize > 0 {
+		return
+	}
+	if st, err := os.Stat(filePath); err == nil {
+		info.FileSize = st.Size()
+	}
+}
+
+// fillBitrate computes a bitrate ffprobe didn't report from size and duration.
+func fillBitrate(info *MediaInfo) {
+	if info.Bitrate <= 0 && info.Duration > 0 {
+		info.Bitrate = int64(float64(info.FileSize) * 8 / info.Duration)
+	}
+}
+
+// scannedDuration measures a file's length by passing every packet through
+// ffmpeg's null muxer (no decoding) and reading the last timestamp it
+// reports.
+func (d *Downloader) scannedDuration(ctx context.Context, filePath string) (float64, error) {
+	cmd := d.command(ctx, "ffmpeg", "-nostdin", "-i", filePath, "-map", "0", "-c", "copy", "-f", "null", "-")
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		return 0, fmt.Errorf("ffmpeg scan failed: %w", err)
+	}
+	duration := lastTimestamp(string(output))
+	if duration <= 0 {
+		return 0, fmt.Errorf("no timestamp in ffmpeg output")
+	}
+	return duration, nil
+}
+
+// lastTimestamp returns the last time=HH:MM:SS.ss in ffmpeg's stats
+// output, in seconds; 0 if there is none.
+func lastTimestamp(output string) float64 {
+	matches := ffmpegTimeRe.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0
+	}
+	m := matches[len(matches)-1]
+	var hours, mins int
+	var secs float64
+	fmt.Sscanf(m[1], "%d", &hours)
+	fmt.Sscanf(m[2], "%d", &mins)
+	fmt.Sscanf(m[3], "%f", &secs)
+	return float64(hours*3600+mins*60) + secs
+}
+
+// repairContainer rewrites filePath with a stream-copy remux into the same
+// container, which writes a fresh index with the real duration.
+func (d *Downloader) repairContainer(ctx context.Context, filePath string) error {
+	st, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	dir := filepath.Dir(filePath)
+	if err := ensureFreeSpace(dir, st.Size()); err != nil {
+		return err
+	}
+	ext := filepath.Ext(filePath)
+	outPath := filepath.Join(dir, strings.TrimSuffix(filepath.Base(filePath), ext)+"_repaired"+ext)
+	args := []string{"-nostdin", "-i", filePath, "-map", "0", "-c", "copy"}
+	if strings.EqualFold(ext, ".mp4") {
+		args = append(args, "-movflags", "+faststart")
+	}
+	cmd := d.command(ctx, "ffmpeg", append(args, "-y", outPath)...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		os.Remove(outPath)
+		return fmt.Errorf("ffmpeg repair failed: %w, output: %s", err, string(output))
+	}
+	if err := os.Rename(outPath, filePath); err != nil {
+		os.Remove(outPath)
+		return fmt.Errorf("failed to replace repaired file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlausibleDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		info MediaInfo
+		want bool
+	}{
+		{"missing", MediaInfo{FileSize: 100 << 20}, false},
+		{"normal", MediaInfo{Duration: 600, FileSize: 100 << 20}, true},
+		// A 2 GB file reported as one 2-second fragment
+		{"fragment", MediaInfo{Duration: 2, FileSize: 2 << 30}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plausibleDuration(&tt.info); got != tt.want {
+				t.Errorf("plausibleDuration(%+v) = %v, want %v", tt.info, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillSizeAndBitrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v.mp4")
+	if err := os.WriteFile(path, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := &MediaInfo{Duration: 4}
+	fillFileSize(info, path)
+	fillBitrate(info)
+	if info.FileSize != 1000 || info.Bitrate != 2000 {
+		t.Errorf("size %d, bitrate %d; want 1000, 2000", info.FileSize, info.Bitrate)
+	}
+
+	// Reported values are kept
+	info = &MediaInfo{Duration: 4, FileSize: 10, Bitrate: 5}
+	fillFileSize(info, path)
+	fillBitrate(info)
+	if info.FileSize != 10 || info.Bitrate != 5 {
+		t.Errorf("reported values overwritten: %+v", info)
+	}
+}
+
+func TestLastTimestamp(t *testing.T) {
+	output := "Input #0, mov,mp4,m4a\n" +
+		"frame=  100 fps=0.0 q=-1.0 size=N/A time=00:00:04.00 bitrate=N/A\r" +
+		"frame= 9000 fps=0.0 q=-1.0 Lsize=N/A time=01:02:03.50 bitrate=N/A speed= 900x\n"
+	if got := lastTimestamp(output); got != 3723.5 {
+		t.Errorf("lastTimestamp = %v, want 3723.5", got)
+	}
+	if got := lastTimestamp("no stats here"); got != 0 {
+		t.Errorf("lastTimestamp without stats = %v", got)
+	}
+}
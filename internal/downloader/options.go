@@ -0,0 +1,187 @@
+package downloader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Options select what to download. The zero value is the default behavior:
+// best H.264-preferred video up to 1080p.
+type Options struct {
+	MaxHeight int  // Cap video height (e.g. 480, 720); 0 means the default 1080p cap
+	AudioOnly bool // Extract audio only, converted to MP3
+	Archive   bool // Keep every audio/subtitle track and attachment in an MKV, no re-encoding
+	Voice     bool // Extract audio and convert to OGG/Opus for a Telegram voice message
+	Animate   bool // Convert the video to a silent animation (VideoToAnimation)
+	VideoNote bool // Convert the video to a square video note (ConvertToVideoNote)
+
+	// Frames extracts this many evenly spaced frames as JPEGs instead of
+	// delivering the video; FrameTimes extracts frames at these offsets
+	// (seconds) instead.
+	Frames     int
+	FrameTimes []float64
+
+	// Oversize is how to deliver a video over the upload limit: one of the
+	// Oversize* constants, or "" to compress if close and split otherwise.
+	Oversize string
+
+	// MaxParts caps how many parts an oversized video may be split into;
+	// one needing more is compressed to fit (see PartLimitTarget). 0 means
+	// no cap.
+	MaxParts int
+
+	// SubtitleLang also fetches the video's subtitles in this language as
+	// SRT files (see DownloadSubtitles); "" skips subtitles. SubtitleAuto
+	// is resolved by the engine before the download.
+	SubtitleLang string
+
+	// SubtitlePrefer is the requester's language, tried first for
+	// SubtitleAuto.
+	SubtitlePrefer string
+
+	// BurnSubtitles renders the SubtitleLang track into the picture during
+	// re-encode instead of fetching SRT files, for players without
+	// subtitle support.
+	BurnSubtitles bool
+
+	// Stabilize smooths out camera shake (two-pass vidstab, or deshake)
+	// during a forced re-encode, for shaky phone footage.
+	Stabilize bool
+
+	// SplitChapters splits oversized videos on their chapter boundaries
+	// when they have chapters, as OversizeChapters does, after the usual
+	// compress-if-close check.
+	SplitChapters bool
+
+	// Start and End download only this section, in seconds (yt-dlp
+	// --download-sections); End 0 means to the end of the video.
+	Start, End float64
+
+	// ExactCuts re-encodes around the section boundaries
+	// (--force-keyframes-at-cuts) so a clip starts and ends exactly at
+	// Start/End rather than at the nearest keyframes.
+	ExactCuts bool
+
+	// Album downloads every photo and video of a multi-item post
+	// (Instagram carousel, Reddit gallery) with gallery-dl instead of
+	// yt-dlp, for delivery as one media group (see DownloadGallery).
+	Album bool
+
+	// Direct fetches the URL itself (HTTP GET, or ffmpeg for HLS) instead
+	// of through yt-dlp, for links to raw media files (see IsDirectMediaURL).
+	Direct bool
+
+	// Torrent downloads the largest video file of a magnet link or .torrent
+	// with aria2c (see IsTorrentURL).
+	Torrent bool
+
+	// Record records an ongoing live stream from now for at most this
+	// long, then finalizes the file; 0 for regular videos. Without it a
+	// live stream is recorded until the download times out.
+	Record time.Duration
+
+	// Format overrides the yt-dlp -f selector, e.g. with concrete format IDs
+	// from RefreshFormat; "" derives it from the fields above.
+	Format string
+
+	// Probed is the URL's metadata if the caller already probed it (e.g.
+	// for a quality prompt); the disk space pre-check uses it instead of
+	// probing again. nil to let the pre-check probe when it needs to.
+	Probed *VideoInfo
+}
+
+// Oversize delivery modes for videos over the upload limit.
+const (
+	OversizeSplit    = "split"    // Split into playable video parts
+	OversizeCompress = "compress" // Compress into one file even when far over the limit
+	OversizeDocument = "document" // Split and send the parts as files
+	OversizeChapters = "chapters" // Split on chapter boundaries, parts titled by chapter
+)
+
+// ChapterSplit reports whether oversized videos are split on chapters.
+func (o Options) ChapterSplit() bool {
+	return o.Oversize == OversizeChapters || o.SplitChapters
+}
+
+// WantsFrames reports whether stills are extracted instead of delivering the video.
+func (o Options) WantsFrames() bool {
+	return o.Frames > 0 || len(o.FrameTimes) > 0
+}
+
+// PlainVideo reports whether the whole video is wanted as a video, without
+// conversion to another kind of media. Only then may an image post or an
+// album stand in for it.
+func (o Options) PlainVideo() bool {
+	return !o.AudioOnly && !o.Voice && !o.Archive && !o.Animate && !o.VideoNote &&
+		!o.WantsFrames() && o.Start == 0 && o.End == 0
+}
+
+// directOK reports whether a direct download can serve these options: it
+// has no audio extraction or sections, which need yt-dlp.
+func (o Options) directOK() bool {
+	return !o.AudioOnly && o.Start == 0 && o.End == 0
+}
+
+// args returns the yt-dlp arguments for format selection and output container.
+func (o Options) args() []string {
+	args := []string{"-f", o.format()}
+	switch {
+	case o.AudioOnly:
+		// Embed title/artist tags so the upload can show them
+		args = append(args, "-x", "--audio-format", "mp3", "--embed-metadata")
+	case o.Voice:
+		// Keep the source codec; ConvertToVoice transcodes to Opus afterwards
+		args = append(args, "-x")
+	case o.Archive:
+		args = append(args,
+			"--audio-multistreams",
+			"--merge-output-format", "mkv",
+			"--embed-subs", "--sub-langs", "all,-live_chat",
+			"--embed-thumbnail",
+			"--embed-chapters",
+			"--embed-metadata",
+		)
+	default:
+		// NO forced re-encoding here - we check codec after download and re-encode only if needed
+		args = append(args, "--merge-output-format", "mp4")
+		if o.ChapterSplit() {
+			// Chapter metadata for SplitByChapters
+			args = append(args, "--embed-chapters")
+		}
+	}
+	if o.Record > 0 {
+		// ffmpeg stops reading the stream after Record; without MPEG-TS
+		// (yt-dlp's default for live streams) the result is a regular MP4
+		args = append(args,
+			"--downloader", "ffmpeg",
+			"--downloader-args", fmt.Sprintf("ffmpeg_i:-t %d", int(o.Record.Seconds())),
+			"--no-hls-use-mpegts",
+		)
+	}
+	if !o.Archive && (o.Start > 0 || o.End > 0) {
+		args = append(args, "--download-sections", sectionArg(o.Start, o.End))
+		if o.ExactCuts {
+			args = append(args, "--force-keyframes-at-cuts")
+		}
+	}
+	return args
+}
+
+// format returns the yt-dlp -f selector for the options.
+func (o Options) format() string {
+	if o.Format != "" {
+		return o.Format
+	}
+	if o.AudioOnly || o.Voice {
+		return "bestaudio/best"
+	}
+	if o.Archive {
+		// Best video plus every audio-only format (all languages/dubs)
+		return "bestvideo*+mergeall[vcodec=none]/bestvideo*+bestaudio/best"
+	}
+	if o.MaxHeight <= 0 || o.MaxHeight == 1080 {
+		return defaultFormat
+	}
+	return strings.ReplaceAll(defaultFormat, "height<=1080", fmt.Sprintf("height<=%d", o.MaxHeight))
+}
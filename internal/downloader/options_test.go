@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionsFormat(t *testing.T) {
+	if got := (Options{}).format(); got != defaultFormat {
+		t.Errorf("zero Options format = %q, want defaultFormat", got)
+	}
+	if got := (Options{MaxHeight: 1080}).format(); got != defaultFormat {
+		t.Errorf("1080p format = %q, want defaultFormat", got)
+	}
+
+	got := Options{MaxHeight: 480}.format()
+	if strings.Contains(got, "1080") || !strings.Contains(got, "height<=480") {
+		t.Errorf("480p format = %q, want every height cap at 480", got)
+	}
+
+	if got := (Options{AudioOnly: true, MaxHeight: 720}).format(); got != "bestaudio/best" {
+		t.Errorf("audio format = %q, want bestaudio/best", got)
+	}
+}
+
+func TestOptionsArgs(t *testing.T) {
+	has := func(args []string, want string) bool {
+		for _, a := range args {
+			if a == want {
+				return true
+			}
+		}
+		return false
+	}
+
+	if args := (Options{}).args(); !has(args, "mp4") || has(args, "-x") {
+		t.Errorf("default args = %v, want mp4 merge without extraction", args)
+	}
+	if args := (Options{Oversize: OversizeChapters}).args(); !has(args, "--embed-chapters") {
+		t.Errorf("chapter split args = %v, want --embed-chapters", args)
+	}
+	if args := (Options{AudioOnly: true}).args(); !has(args, "-x") || !has(args, "mp3") {
+		t.Errorf("audio args = %v, want MP3 extraction", args)
+	}
+	if args := (Options{Voice: true}).args(); !has(args, "-x") || has(args, "mp3") {
+		t.Errorf("voice args = %v, want extraction in the source codec", args)
+	}
+	if args := (Options{Start: 90, End: 120, ExactCuts: true}).args(); !has(args, "*90-120") || !has(args, "--force-keyframes-at-cuts") {
+		t.Errorf("clip args = %v, want the section with exact cuts", args)
+	}
+	if args := (Options{Start: 90}).args(); !has(args, "*90-inf") || has(args, "--force-keyframes-at-cuts") {
+		t.Errorf("timestamp args = %v, want the section without re-encoding", args)
+	}
+	if args := (Options{Record: 15 * time.Minute}).args(); !has(args, "ffmpeg_i:-t 900") || !has(args, "--no-hls-use-mpegts") {
+		t.Errorf("live args = %v, want ffmpeg stopping after 900s", args)
+	}
+	if args := (Options{}).args(); has(args, "--downloader") {
+		t.Errorf("default args = %v, want yt-dlp's own downloader", args)
+	}
+	args := Options{Archive: true}.args()
+	for _, want := range []string{"mkv", "--audio-multistreams", "--embed-subs", "--embed-thumbnail"} {
+		if !has(args, want) {
+			t.Errorf("archive args = %v, missing %s", args, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputListName is the file in the work dir yt-dlp appends the final path
+// of each file it produced to (see outputListArgs).
+const outputListName = "_sushe_output_paths.txt"
+
+// sidecarExts are files that end up next to the media in a work dir but are
+// never the download itself: metadata, subtitles, descriptions and
+// leftovers of an interrupted download.
+var sidecarExts = map[string]bool{
+	".json": true, ".description": true, ".part": true, ".ytdl": true, ".temp": true, ".tmp": true,
+	".vtt": true, ".srt": true, ".ass": true, ".lrc": true, ".url": true, ".torrent": true,
+}
+
+// outputListArgs asks yt-dlp to report the path of its output, after
+// merging, conversion and moving, in workDir's output list. --print would
+// imply --quiet and hide the merge and post-processing lines.
+func outputListArgs(workDir string) []string {
+	return []string{"--print-to-file", "after_move:filepath", filepath.Join(workDir, outputListName)}
+}
+
+// downloadedFile returns the media file a fetch left in workDir: the last
+// existing path yt-dlp reported in the output list, or, without one (direct
+// and torrent downloads, older yt-dlp), the largest file that isn't a
+// thumbnail or a sidecar. files is the glob of workDir.
+func downloadedFile(workDir string, files []string) (string, error) {
+	listPath := filepath.Join(workDir, outputListName)
+	defer os.Remove(listPath)
+	if path, ok := reportedOutput(workDir, listPath); ok {
+		return path, nil
+	}
+
+	var best string
+	var bestSize int64 = -1
+	for _, f := range files {
+		name := filepath.Base(f)
+		if name == outputListName || strings.HasPrefix(name, platformThumbName+".") ||
+			sidecarExts[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		info, err := os.Stat(f)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if info.Size() > bestSize {
+			best, bestSize = f, info.Size()
+		}
+	}
+	if best == "" {
+		return "", errors.New("no file downloaded")
+	}
+	return best, nil
+}
+
+// reportedOutput returns the last path in the output list that exists and
+// lies inside workDir.
+func reportedOutput(workDir, listPath string) (string, bool) {
+	data, err := os.ReadFile(listPath)
+	if err != nil {
+		return "", false
+	}
+	var found string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workDir, path)
+		}
+		path = filepath.Clean(path)
+		if !strings.HasPrefix(path, filepath.Clean(workDir)+string(filepath.Separator)) {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			found = path
+		}
+	}
+	return found, found != ""
+}
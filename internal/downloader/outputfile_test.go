@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates files of the given sizes in dir.
+func writeFiles(t *testing.T, dir string, sizes map[string]int) []string {
+	var paths []string
+	for name, size := range sizes {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestDownloadedFileReported(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir, map[string]int{
+		"Video.f137.mp4":  500, // leftover format of a failed merge, larger
+		"Video.mp4":       100,
+		"Video.info.json": 10,
+	})
+	list := "\n" + filepath.Join(dir, "gone.mp4") + "\n/etc/passwd\n" + filepath.Join(dir, "Video.mp4") + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, outputListName), []byte(list), 0644))
+
+	path, err := downloadedFile(dir, files)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "Video.mp4"), path)
+	assert.NoFileExists(t, filepath.Join(dir, outputListName), "list removed")
+}
+
+func TestDownloadedFileFallback(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir, map[string]int{
+		"Video.info.json":           5000,
+		"Video.en.vtt":              4000,
+		platformThumbName + ".webp": 3000,
+		"Video.mp4.part":            2000,
+		"Video.webm":                1000,
+		"Video.description":         900,
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(dir, homeDirName), 0700))
+	files = append(files, filepath.Join(dir, homeDirName))
+
+	path, err := downloadedFile(dir, files)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "Video.webm"), path)
+}
+
+func TestDownloadedFileNone(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir, map[string]int{"Video.info.json": 10})
+	_, err := downloadedFile(dir, files)
+	assert.EqualError(t, err, "no file downloaded")
+}
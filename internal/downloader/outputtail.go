@@ -0,0 +1,118 @@
+package downloader
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// outputTailLines is how many lines of subprocess output an OutputTail keeps.
+	outputTailLines = 20
+	// outputLineMax cuts long lines (ffmpeg stats, JSON dumps) to their end.
+	outputLineMax = 300
+)
+
+// OutputTail keeps the last lines yt-dlp and ffmpeg printed during a job,
+// each tagged with the tool, so a failure can be reported with what they
+// said. The zero value is ready to use; methods on a nil OutputTail do
+// nothing.
+type OutputTail struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// add records one line of tool's output. Of \r-separated progress updates
+// only the last is kept.
+func (t *OutputTail) add(tool, line string) {
+	if t == nil {
+		return
+	}
+	if i := strings.LastIndexByte(strings.TrimRight(line, "\r"), '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	if len(line) > outputLineMax {
+		line = "…" + line[len(line)-outputLineMax:]
+	}
+	line = "[" + tool + "] " + line
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// A progress update replaces the one before it
+	if n := len(t.lines); n > 0 && isProgressLine(line) && isProgressLine(t.lines[n-1]) {
+		t.lines[n-1] = line
+		return
+	}
+	t.lines = append(t.lines, line)
+	if len(t.lines) > outputTailLines {
+		t.lines = t.lines[len(t.lines)-outputTailLines:]
+	}
+}
+
+// isProgressLine reports whether a tagged line is an ffmpeg stats update.
+func isProgressLine(line string) bool {
+	_, rest, _ := strings.Cut(line, "] ")
+	return strings.HasPrefix(rest, "frame=") || strings.HasPrefix(rest, "size=")
+}
+
+// addOutput records the lines of a finished command's output.
+func (t *OutputTail) addOutput(tool string, output []byte) {
+	for _, line := range strings.Split(string(output), "\n") {
+		t.add(tool, line)
+	}
+}
+
+// String returns the recorded lines, oldest first.
+func (t *OutputTail) String() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.lines, "\n")
+}
+
+type outputTailKey struct{}
+
+// WithOutputTail returns a context whose subprocesses record their output in t.
+func WithOutputTail(ctx context.Context, t *OutputTail) context.Context {
+	return context.WithValue(ctx, outputTailKey{}, t)
+}
+
+// OutputTailFrom returns the OutputTail attached to ctx, or nil.
+func OutputTailFrom(ctx context.Context) *OutputTail {
+	t, _ := ctx.Value(outputTailKey{}).(*OutputTail)
+	return t
+}
+
+// combinedOutput is cmd.CombinedOutput, recording the output of a failed
+// run in the job's OutputTail.
+func combinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		OutputTailFrom(ctx).addOutput(toolName(cmd), output)
+	}
+	return output, err
+}
+
+// toolName is the program cmd runs, looking through the systemd-run
+// wrapper of a limited sandbox.
+func toolName(cmd *exec.Cmd) string {
+	args := cmd.Args
+	if len(args) > 0 && filepath.Base(args[0]) == "systemd-run" {
+		for i, arg := range args {
+			if arg == "--" && i+1 < len(args) {
+				return filepath.Base(args[i+1])
+			}
+		}
+	}
+	if len(args) == 0 {
+		return filepath.Base(cmd.Path)
+	}
+	return filepath.Base(args[0])
+}
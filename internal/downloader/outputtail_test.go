@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestOutputTail(t *testing.T) {
+	tail := &OutputTail{}
+	tail.add("ffmpeg", "Input #0, mov,mp4")
+	tail.add("ffmpeg", "frame=  10 time=00:00:01.00\rframe=  20 time=00:00:02.00")
+	tail.add("ffmpeg", "frame=  30 time=00:00:03.00")
+	tail.add("ffmpeg", "   ")
+	tail.add("ffmpeg", "Conversion failed!")
+
+	want := "[ffmpeg] Input #0, mov,mp4\n[ffmpeg] frame=  30 time=00:00:03.00\n[ffmpeg] Conversion failed!"
+	if got := tail.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	for i := 0; i < 2*outputTailLines; i++ {
+		tail.add("yt-dlp", fmt.Sprintf("line %d", i))
+	}
+	lines := strings.Split(tail.String(), "\n")
+	if len(lines) != outputTailLines || lines[len(lines)-1] != fmt.Sprintf("[yt-dlp] line %d", 2*outputTailLines-1) {
+		t.Errorf("kept %d lines ending with %q", len(lines), lines[len(lines)-1])
+	}
+
+	long := strings.Repeat("x", 2*outputLineMax)
+	tail.add("yt-dlp", long)
+	lines = strings.Split(tail.String(), "\n")
+	if got := lines[len(lines)-1]; len(got) > outputLineMax+20 {
+		t.Errorf("long line kept whole: %d bytes", len(got))
+	}
+
+	var none *OutputTail
+	none.add("ffmpeg", "ignored")
+	if none.String() != "" {
+		t.Error("nil OutputTail should record nothing")
+	}
+}
+
+func TestCombinedOutputRecordsFailure(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	d := &Downloader{}
+	tail := &OutputTail{}
+	ctx := WithOutputTail(context.Background(), tail)
+
+	if _, err := combinedOutput(ctx, d.command(ctx, "sh", "-c", "echo fine")); err != nil {
+		t.Fatal(err)
+	}
+	if tail.String() != "" {
+		t.Errorf("successful run recorded %q", tail.String())
+	}
+	if _, err := combinedOutput(ctx, d.command(ctx, "sh", "-c", "echo 'ERROR: boom'; exit 1")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := tail.String(); got != "[sh] ERROR: boom" {
+		t.Errorf("tail = %q", got)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	cmd := exec.Command("systemd-run", "--scope", "-p", "MemoryMax=2G", "--", "yt-dlp", "-f", "best")
+	if got := toolName(cmd); got != "yt-dlp" {
+		t.Errorf("toolName(systemd-run ...) = %q", got)
+	}
+	if got := toolName(exec.Command("/usr/bin/ffmpeg", "-i", "x")); got != "ffmpeg" {
+		t.Errorf("toolName(ffmpeg) = %q", got)
+	}
+}
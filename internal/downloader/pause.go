@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNothingToPause is returned by Pauser.Pause when the job has no
+// download running.
+var ErrNothingToPause = errors.New("no download running")
+
+// Pauser suspends and resumes a job's yt-dlp downloads: their process
+// groups get SIGSTOP, so the connection idles and frees the bandwidth,
+// and SIGCONT to carry on where they were. The zero value is ready to use;
+// methods on a nil Pauser do nothing.
+type Pauser struct {
+	mu       sync.Mutex
+	groups   map[int]struct{} // process groups of running downloads
+	paused   bool
+	pausedAt time.Time
+}
+
+// track registers a started download's process group, stopped right away
+// if the job is paused. The returned func unregisters it.
+func (p *Pauser) track(pid int) func() {
+	if p == nil {
+		return func() {}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.groups == nil {
+		p.groups = make(map[int]struct{})
+	}
+	p.groups[pid] = struct{}{}
+	if p.paused {
+		syscall.Kill(-pid, syscall.SIGSTOP)
+	}
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.groups, pid)
+		// A killed download leaves nothing paused
+		if len(p.groups) == 0 {
+			p.paused = false
+		}
+	}
+}
+
+// Active reports whether a download is running (or paused).
+func (p *Pauser) Active() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.groups) > 0
+}
+
+// Paused reports whether the downloads are paused, and since when.
+func (p *Pauser) Paused() (bool, time.Time) {
+	if p == nil {
+		return false, time.Time{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused, p.pausedAt
+}
+
+// Pause stops the running downloads. ErrNothingToPause if there are none.
+func (p *Pauser) Pause() error {
+	if p == nil {
+		return ErrNothingToPause
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.groups) == 0 {
+		return ErrNothingToPause
+	}
+	if p.paused {
+		return nil
+	}
+	if err := p.signalLocked(syscall.SIGSTOP); err != nil {
+		return err
+	}
+	p.paused, p.pausedAt = true, time.Now()
+	return nil
+}
+
+// Resume continues paused downloads.
+func (p *Pauser) Resume() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		return nil
+	}
+	p.paused = false
+	return p.signalLocked(syscall.SIGCONT)
+}
+
+func (p *Pauser) signalLocked(sig syscall.Signal) error {
+	for pid := range p.groups {
+		if err := syscall.Kill(-pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("failed to signal download %d: %w", pid, err)
+		}
+	}
+	return nil
+}
+
+type pauserKey struct{}
+
+// WithPauser returns a context whose downloads p can pause.
+func WithPauser(ctx context.Context, p *Pauser) context.Context {
+	return context.WithValue(ctx, pauserKey{}, p)
+}
+
+// PauserFrom returns the Pauser attached to ctx, or nil.
+func PauserFrom(ctx context.Context) *Pauser {
+	p, _ := ctx.Value(pauserKey{}).(*Pauser)
+	return p
+}
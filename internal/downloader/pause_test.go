@@ -0,0 +1,88 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processState returns the state letter of pid from /proc (S, R, T, ...).
+func processState(t *testing.T, pid int) string {
+	t.Helper()
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		t.Skipf("no /proc: %v", err)
+	}
+	// pid (comm) state ...
+	fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+	return fields[0]
+}
+
+// waitState polls until pid is in state, failing after a second.
+func waitState(t *testing.T, pid int, state string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if processState(t, pid) == state {
+			return
+		}
+	}
+	t.Fatalf("process %d in state %s, want %s", pid, processState(t, pid), state)
+}
+
+func TestPauserStopsAndContinues(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("no sleep")
+	}
+	p := &Pauser{}
+	if err := p.Pause(); !errors.Is(err, ErrNothingToPause) {
+		t.Errorf("Pause with nothing running = %v", err)
+	}
+
+	d := &Downloader{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := d.command(ctx, "sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cancel()
+		cmd.Wait()
+	}()
+	untrack := p.track(cmd.Process.Pid)
+
+	if err := p.Pause(); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if paused, _ := p.Paused(); !paused {
+		t.Error("not paused after Pause")
+	}
+	waitState(t, cmd.Process.Pid, "T")
+
+	if err := p.Resume(); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	waitState(t, cmd.Process.Pid, "S")
+
+	// Killed while paused: nothing is left paused
+	p.Pause()
+	untrack()
+	if paused, _ := p.Paused(); paused || p.Active() {
+		t.Error("pauser still paused/active after the download ended")
+	}
+}
+
+func TestPauserNil(t *testing.T) {
+	var p *Pauser
+	if p.Active() || p.Resume() != nil {
+		t.Error("nil Pauser should do nothing")
+	}
+	p.track(1)()
+	if PauserFrom(context.Background()) != nil {
+		t.Error("PauserFrom without a Pauser should be nil")
+	}
+}
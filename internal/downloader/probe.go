@@ -0,0 +1,185 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// VideoInfo contains metadata from a yt-dlp probe (-J) without downloading.
+type VideoInfo struct {
+	ID          string
+	Title       string
+	Uploader    string  // channel or account that posted it, "" if unknown
+	Duration    float64 // seconds
+	Width       int
+	Height      int
+	FileSize    int64 // exact or approximate size of the selected format(s), 0 if unknown
+	Extractor   string
+	WebpageURL  string
+	Heights     []int     // distinct video heights offered by the source, ascending
+	Chapters    int       // number of chapters, for offering a chapter split
+	ChapterList []Chapter // the chapters themselves, from the source page
+	Description string    // the uploader's description, "" if none
+	IsLive      bool      // an ongoing live stream, recorded with Options.Record
+	Language    string    // original language of the video, "" if unknown
+	Subtitles   []string  // languages with subtitles: uploaded ones, plus auto-generated in Language
+}
+
+// ytdlpFormat mirrors the per-format fields of yt-dlp's JSON output.
+type ytdlpFormat struct {
+	FormatID       string  `json:"format_id"`
+	Ext            string  `json:"ext"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	VCodec         string  `json:"vcodec"`
+	ACodec         string  `json:"acodec"`
+	FileSize       int64   `json:"filesize"`
+	FileSizeApprox float64 `json:"filesize_approx"`
+}
+
+// size returns the exact file size if known, otherwise the approximation.
+func (f ytdlpFormat) size() int64 {
+	if f.FileSize > 0 {
+		return f.FileSize
+	}
+	return int64(f.FileSizeApprox)
+}
+
+// ytdlpInfo mirrors the subset of yt-dlp's -J output used by the bot.
+type ytdlpInfo struct {
+	ID               string                     `json:"id"`
+	Title            string                     `json:"title"`
+	Uploader         string                     `json:"uploader"`
+	Channel          string                     `json:"channel"`
+	Duration         float64                    `json:"duration"`
+	Width            int                        `json:"width"`
+	Height           int                        `json:"height"`
+	FileSize         int64                      `json:"filesize"`
+	FileSizeApprox   float64                    `json:"filesize_approx"`
+	ExtractorKey     string                     `json:"extractor_key"`
+	WebpageURL       string                     `json:"webpage_url"`
+	RequestedFormats []ytdlpFormat              `json:"requested_formats"`
+	Formats          []ytdlpFormat              `json:"formats"`
+	Chapters         []ytdlpChapter             `json:"chapters"`
+	Description      string                     `json:"description"`
+	IsLive           bool                       `json:"is_live"`
+	Language         string                     `json:"language"`
+	Subtitles        map[string]json.RawMessage `json:"subtitles"`
+	AutoCaptions     map[string]json.RawMessage `json:"automatic_captions"`
+}
+
+// ytdlpChapter mirrors a chapter of yt-dlp's JSON output.
+type ytdlpChapter struct {
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	Title     string  `json:"title"`
+}
+
+// ProbeInfo runs yt-dlp -J with the default format selector and returns
+// metadata for the format that would be downloaded.
+func (d *Downloader) ProbeInfo(ctx context.Context, url string) (*VideoInfo, error) {
+	args := []string{
+		"-J",
+		"--no-playlist",
+		"--no-warnings",
+		"-f", defaultFormat,
+		url,
+	}
+
+	logger.FromContext(ctx).Debug("Probing video info", "args", args)
+
+	cmd := d.ytdlp(ctx, args...)
+	output, err := cmd.Output()
+	d.ytdlpDone(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe video info: %w", err)
+	}
+
+	return parseVideoInfo(output)
+}
+
+// parseVideoInfo converts yt-dlp -J output to VideoInfo. For merged formats
+// (video+audio) the size and dimensions come from requested_formats.
+func parseVideoInfo(data []byte) (*VideoInfo, error) {
+	var raw ytdlpInfo
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+
+	info := &VideoInfo{
+		ID:          raw.ID,
+		Title:       raw.Title,
+		Uploader:    raw.Uploader,
+		Duration:    raw.Duration,
+		Width:       raw.Width,
+		Height:      raw.Height,
+		Extractor:   raw.ExtractorKey,
+		WebpageURL:  raw.WebpageURL,
+		Chapters:    len(raw.Chapters),
+		Description: raw.Description,
+		IsLive:      raw.IsLive,
+		Language:    raw.Language,
+		Subtitles:   subtitleLangs(raw),
+	}
+
+	for _, c := range raw.Chapters {
+		info.ChapterList = append(info.ChapterList, Chapter{Start: c.StartTime, End: c.EndTime, Title: c.Title})
+	}
+	if info.Uploader == "" {
+		info.Uploader = raw.Channel
+	}
+
+	if len(raw.RequestedFormats) > 0 {
+		for _, f := range raw.RequestedFormats {
+			info.FileSize += f.size()
+			if f.Height > info.Height {
+				info.Width = f.Width
+				info.Height = f.Height
+			}
+		}
+	} else {
+		info.FileSize = ytdlpFormat{FileSize: raw.FileSize, FileSizeApprox: raw.FileSizeApprox}.size()
+	}
+
+	seen := make(map[int]bool)
+	for _, f := range raw.Formats {
+		if f.Height > 0 && f.VCodec != "none" && !seen[f.Height] {
+			seen[f.Height] = true
+			info.Heights = append(info.Heights, f.Height)
+		}
+	}
+	sort.Ints(info.Heights)
+
+	return info, nil
+}
+
+// subtitleLangs lists the languages a video has subtitles in: all uploaded
+// ones, and auto-generated ones only in its original language, since the
+// rest are machine translations of those.
+func subtitleLangs(raw ytdlpInfo) []string {
+	seen := make(map[string]bool)
+	var langs []string
+	add := func(lang string) {
+		if ValidSubtitleLang(lang) && !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+	for lang := range raw.Subtitles {
+		add(lang)
+	}
+	if raw.Language != "" {
+		for lang := range raw.AutoCaptions {
+			if strings.EqualFold(baseLang(lang), baseLang(raw.Language)) {
+				add(lang)
+			}
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
@@ -0,0 +1,123 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVideoInfoRequestedFormats(t *testing.T) {
+	data := []byte(`{
+		"id": "abc", "title": "Clip", "duration": 61.5,
+		"extractor_key": "Youtube", "webpage_url": "https://www.youtube.com/watch?v=abc",
+		"requested_formats": [
+			{"format_id": "401", "width": 3840, "height": 2160, "filesize": 1000},
+			{"format_id": "140", "filesize_approx": 200.7}
+		]
+	}`)
+
+	info, err := parseVideoInfo(data)
+	if err != nil {
+		t.Fatalf("parseVideoInfo: %v", err)
+	}
+	if info.FileSize != 1200 {
+		t.Errorf("FileSize = %d, want 1200", info.FileSize)
+	}
+	if info.Width != 3840 || info.Height != 2160 {
+		t.Errorf("dimensions = %dx%d, want 3840x2160", info.Width, info.Height)
+	}
+	if info.Title != "Clip" || info.Duration != 61.5 || info.Extractor != "Youtube" {
+		t.Errorf("unexpected metadata: %+v", info)
+	}
+}
+
+func TestParseVideoInfoSingleFormat(t *testing.T) {
+	info, err := parseVideoInfo([]byte(`{"id": "x", "height": 720, "width": 1280, "filesize_approx": 5000}`))
+	if err != nil {
+		t.Fatalf("parseVideoInfo: %v", err)
+	}
+	if info.FileSize != 5000 || info.Height != 720 {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestParseVideoInfoLive(t *testing.T) {
+	info, err := parseVideoInfo([]byte(`{"id": "x", "is_live": true}`))
+	if err != nil {
+		t.Fatalf("parseVideoInfo: %v", err)
+	}
+	if !info.IsLive {
+		t.Error("IsLive = false for a live stream")
+	}
+}
+
+func TestParseVideoInfoChapters(t *testing.T) {
+	info, err := parseVideoInfo([]byte(`{"id": "x", "description": "about",
+		"chapters": [{"title": "a", "start_time": 0, "end_time": 61.5}, {"title": "b", "start_time": 61.5, "end_time": 90}]}`))
+	if err != nil {
+		t.Fatalf("parseVideoInfo: %v", err)
+	}
+	if info.Chapters != 2 {
+		t.Errorf("Chapters = %d, want 2", info.Chapters)
+	}
+	if len(info.ChapterList) != 2 || info.ChapterList[1] != (Chapter{Start: 61.5, End: 90, Title: "b"}) {
+		t.Errorf("ChapterList = %+v", info.ChapterList)
+	}
+	if info.Description != "about" {
+		t.Errorf("Description = %q, want about", info.Description)
+	}
+}
+
+func TestParseVideoInfoSubtitles(t *testing.T) {
+	info, err := parseVideoInfo([]byte(`{"id": "x", "language": "en",
+		"subtitles": {"fr": [], "de": [], "live_chat": []},
+		"automatic_captions": {"en-orig": [], "en": [], "es": [], "ja": []}}`))
+	if err != nil {
+		t.Fatalf("parseVideoInfo: %v", err)
+	}
+	if info.Language != "en" {
+		t.Errorf("Language = %q, want en", info.Language)
+	}
+	// Uploaded ones and auto-generated ones in the original language only
+	want := "de,en,en-orig,fr"
+	if got := strings.Join(info.Subtitles, ","); got != want {
+		t.Errorf("Subtitles = %s, want %s", got, want)
+	}
+}
+
+func TestParseVideoInfoUploader(t *testing.T) {
+	for data, want := range map[string]string{
+		`{"id": "a", "uploader": "Some Channel", "channel": "Other"}`: "Some Channel",
+		`{"id": "a", "channel": "Only Channel"}`:                      "Only Channel",
+		`{"id": "a"}`:                                                 "",
+	} {
+		info, err := parseVideoInfo([]byte(data))
+		if err != nil {
+			t.Fatalf("parseVideoInfo: %v", err)
+		}
+		if info.Uploader != want {
+			t.Errorf("Uploader of %s = %q, want %q", data, info.Uploader, want)
+		}
+	}
+}
+
+func TestParseVideoInfoInvalid(t *testing.T) {
+	if _, err := parseVideoInfo([]byte("not json")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestParseVideoInfoHeights(t *testing.T) {
+	info, err := parseVideoInfo([]byte(`{"id": "x", "formats": [
+		{"format_id": "140", "vcodec": "none", "acodec": "mp4a"},
+		{"format_id": "137", "height": 1080, "vcodec": "avc1"},
+		{"format_id": "136", "height": 720, "vcodec": "avc1"},
+		{"format_id": "247", "height": 720, "vcodec": "vp9"},
+		{"format_id": "sb0", "height": 90, "vcodec": "none"}
+	]}`))
+	if err != nil {
+		t.Fatalf("parseVideoInfo: %v", err)
+	}
+	if len(info.Heights) != 2 || info.Heights[0] != 720 || info.Heights[1] != 1080 {
+		t.Errorf("Heights = %v, want [720 1080]", info.Heights)
+	}
+}
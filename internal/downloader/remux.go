@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// RemuxToMP4 rewrites an H.264 file as a faststart MP4 without re-encoding
+// the video. Audio that isn't AAC-compatible (Opus, Vorbis, ...) is
+// transcoded to AAC; everything else is stream-copied. Returns the path of
+// the new file; the original is left in place.
+func (d *Downloader) RemuxToMP4(ctx context.Context, filePath string) (string, error) {
+	audioCodec, err := d.GetAudioCodec(filePath)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Failed to get audio codec, copying audio as is", "error", err)
+	}
+	transcodeAudio := audioCodec != "" && !IsAACCompatible(audioCodec)
+
+	logger.FromContext(ctx).Info("Remuxing to MP4",
+		"container", strings.TrimPrefix(filepath.Ext(filePath), "."),
+		"audioCodec", audioCodec,
+		"transcodeAudio", transcodeAudio,
+	)
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	dir := filepath.Dir(filePath)
+	if err := ensureFreeSpace(dir, info.Size()); err != nil {
+		return "", err
+	}
+
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(dir, baseName+"_remux.mp4")
+
+	cmd := d.command(ctx, "ffmpeg", fastStartArgs(filePath, outPath, transcodeAudio)...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg remux failed: %w, output: %s", err, string(output))
+	}
+	return outPath, nil
+}
+
+// canUploadAsIs reports whether a file whose remux failed can still be sent
+// unchanged: Telegram only plays MP4 inline, so other containers have to be
+// re-encoded instead.
+func canUploadAsIs(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), ".mp4")
+}
+
+// fastStartArgs returns the ffmpeg arguments that rewrite an H.264 file as
+// MP4 with the index up front, copying the video. Audio is copied too unless
+// transcodeAudio is set, in which case it is converted to AAC.
+func fastStartArgs(in, out string, transcodeAudio bool) []string {
+	args := []string{"-i", in, "-c:v", "copy"}
+	if transcodeAudio {
+		args = append(args, "-c:a", "aac", "-b:a", "192k")
+	} else {
+		args = append(args, "-c:a", "copy")
+	}
+	return append(args,
+		"-movflags", "+faststart",
+		"-y", // Overwrite output
+		out,
+	)
+}
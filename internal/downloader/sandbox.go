@@ -0,0 +1,112 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// subprocessWaitDelay bounds how long Wait keeps reading the pipes of a
+// killed subprocess whose own children still hold them open.
+const subprocessWaitDelay = 5 * time.Second
+
+// subprocessEnv are the environment variables yt-dlp, ffmpeg and gallery-dl
+// get; everything else, the bot token and secrets key included, is dropped.
+var subprocessEnv = []string{
+	"PATH", "HOME", "USER", "LANG", "LC_ALL", "TZ", "TMPDIR", "XDG_CACHE_HOME",
+	"SSL_CERT_FILE", "SSL_CERT_DIR",
+	"http_proxy", "https_proxy", "no_proxy", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+}
+
+// Sandbox constrains the subprocesses run for downloads. Each runs in its
+// own process group, killed as a whole on cancellation, with a restricted
+// environment and the download directory as its working directory unless
+// it has a work directory of its own.
+type Sandbox struct {
+	// MemoryMax and CPUQuota run each subprocess in a transient systemd
+	// scope with these limits, e.g. "2G" and "200%"; "" for no limit.
+	MemoryMax string
+	CPUQuota  string
+
+	// Env are extra environment variables passed through.
+	Env []string
+}
+
+// SetSandbox sets the constraints of the subprocesses this downloader
+// runs. Resource limits need systemd-run; without it they are skipped with
+// a warning. Call it at startup, before any download.
+func (d *Downloader) SetSandbox(s Sandbox) {
+	if s.limited() {
+		if _, err := exec.LookPath("systemd-run"); err != nil {
+			logger.Warn("systemd-run not found, running subprocesses without memory/CPU limits", "error", err)
+			s.MemoryMax, s.CPUQuota = "", ""
+		}
+	}
+	d.sandbox = s
+}
+
+// limited reports whether the sandbox has resource limits.
+func (s Sandbox) limited() bool {
+	return s.MemoryMax != "" || s.CPUQuota != ""
+}
+
+// wrap returns the command line that runs name with args under the
+// sandbox's resource limits.
+func (s Sandbox) wrap(name string, args []string) (string, []string) {
+	if !s.limited() {
+		return name, args
+	}
+	wrapped := []string{"--scope", "--quiet", "--collect"}
+	if os.Geteuid() != 0 {
+		wrapped = append([]string{"--user"}, wrapped...)
+	}
+	if s.MemoryMax != "" {
+		wrapped = append(wrapped, "-p", "MemoryMax="+s.MemoryMax, "-p", "MemorySwapMax=0")
+	}
+	if s.CPUQuota != "" {
+		wrapped = append(wrapped, "-p", "CPUQuota="+s.CPUQuota)
+	}
+	wrapped = append(wrapped, "--", name)
+	return "systemd-run", append(wrapped, args...)
+}
+
+// environ returns the passed-through part of the bot's environment.
+func (s Sandbox) environ() []string {
+	var env []string
+	for _, key := range append(subprocessEnv, s.Env...) {
+		if v, ok := os.LookupEnv(strings.TrimSpace(key)); ok {
+			env = append(env, key+"="+v)
+		}
+	}
+	return env
+}
+
+// command is exec.CommandContext for yt-dlp, ffmpeg and friends, set up
+// in the sandbox. Callers may still set Dir to a job's work directory.
+func (d *Downloader) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	s := d.sandbox
+	name, args = s.wrap(name, args)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = s.environ()
+	if _, err := os.Stat(DownloadDir); err == nil {
+		cmd.Dir = DownloadDir
+	}
+	// Own process group, so cancellation also kills the ffmpeg a yt-dlp
+	// (or a stuck extractor plugin) started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	cmd.WaitDelay = subprocessWaitDelay
+	return cmd
+}
@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSandboxWrap(t *testing.T) {
+	name, args := Sandbox{}.wrap("ffmpeg", []string{"-i", "in.mp4"})
+	if name != "ffmpeg" || !reflect.DeepEqual(args, []string{"-i", "in.mp4"}) {
+		t.Errorf("unlimited wrap = %s %v", name, args)
+	}
+
+	name, args = Sandbox{MemoryMax: "2G", CPUQuota: "150%"}.wrap("ffmpeg", []string{"-i", "in.mp4"})
+	line := name + " " + strings.Join(args, " ")
+	for _, want := range []string{"systemd-run ", "--scope", "-p MemoryMax=2G", "-p CPUQuota=150%", "-- ffmpeg -i in.mp4"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("wrap = %q, missing %q", line, want)
+		}
+	}
+}
+
+func TestSandboxEnviron(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "secret")
+	t.Setenv("SUSHE_TEST_PASSTHROUGH", "yes")
+	t.Setenv("PATH", "/usr/bin")
+
+	env := Sandbox{Env: []string{"SUSHE_TEST_PASSTHROUGH"}}.environ()
+	joined := strings.Join(env, "\n")
+	if strings.Contains(joined, "secret") {
+		t.Error("bot token leaked into the subprocess environment")
+	}
+	if !strings.Contains(joined, "PATH=/usr/bin") || !strings.Contains(joined, "SUSHE_TEST_PASSTHROUGH=yes") {
+		t.Errorf("environ = %v, want PATH and the extra variable", env)
+	}
+}
+
+func TestCommandKillsProcessGroup(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The child sleep holds stdout open; only a group kill ends it early
+	d := &Downloader{}
+	cmd := d.command(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	start := time.Now()
+	_, err := cmd.Output()
+	if err == nil {
+		t.Fatal("cancelled command succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > subprocessWaitDelay {
+		t.Errorf("command took %v after cancellation", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Errorf("err = %v, want the deadline or a kill", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// ShortClipMaxDuration is the longest video, in seconds, sent as an
+	// autoplaying animation when it has no audible sound.
+	ShortClipMaxDuration = 10.0
+
+	// silenceThreshold is the peak volume (dBFS) at or below which an audio
+	// track counts as silent.
+	silenceThreshold = -50.0
+)
+
+var maxVolumeRe = regexp.MustCompile(`max_volume:\s*(-?[\d.]+|-inf) dB`)
+
+// IsShortSilentClip reports whether filePath is a reaction-style clip: at
+// most ShortClipMaxDuration long with no audio track or only silence.
+// Telegram autoplays such clips inline when sent as an animation.
+func (d *Downloader) IsShortSilentClip(ctx context.Context, filePath string, duration float64) bool {
+	if duration <= 0 || duration > ShortClipMaxDuration {
+		return false
+	}
+	audible, err := d.hasAudibleAudio(ctx, filePath)
+	if err != nil {
+		logger.FromContext(ctx).Debug("Failed to check clip audio, keeping it as a video", "file", filePath, "error", err)
+		return false
+	}
+	return !audible
+}
+
+// hasAudibleAudio reports whether filePath has an audio track peaking above
+// silenceThreshold, measured with ffmpeg's volumedetect filter.
+func (d *Downloader) hasAudibleAudio(ctx context.Context, filePath string) (bool, error) {
+	codec, err := d.GetAudioCodec(filePath)
+	if err != nil {
+		return false, err
+	}
+	if codec == "" {
+		return false, nil
+	}
+
+	cmd := d.command(ctx, "ffmpeg", "-hide_banner", "-i", filePath,
+		"-map", "0:a:0", "-af", "volumedetect", "-f", "null", "-")
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		return false, fmt.Errorf("volumedetect failed: %w", err)
+	}
+	peak, ok := parseMaxVolume(string(output))
+	if !ok {
+		return false, fmt.Errorf("no max_volume in volumedetect output")
+	}
+	return peak > silenceThreshold, nil
+}
+
+// parseMaxVolume extracts the peak volume in dBFS from volumedetect output.
+func parseMaxVolume(output string) (float64, bool) {
+	m := maxVolumeRe.FindStringSubmatch(output)
+	if m == nil {
+		return 0, false
+	}
+	if m[1] == "-inf" {
+		return -1000, true
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	return v, err == nil
+}
+
+// StripAudio copies filePath's video into a silent MP4 with faststart, as
+// Telegram expects for animations. Returns the new file's path.
+func (d *Downloader) StripAudio(ctx context.Context, filePath string) (string, error) {
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(dir, baseName+"_anim.mp4")
+
+	args := []string{
+		"-i", filePath,
+		"-an",
+		"-c:v", "copy",
+		"-movflags", "+faststart",
+		"-y",
+		outPath,
+	}
+	logger.FromContext(ctx).Debug("Running ffmpeg audio strip", "args", args)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Error("ffmpeg audio strip failed", "error", err, "output", string(output))
+		return "", fmt.Errorf("failed to strip audio: %w", err)
+	}
+	return outPath, nil
+}
@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseMaxVolume(t *testing.T) {
+	tests := []struct {
+		output string
+		want   float64
+		ok     bool
+	}{
+		{"[Parsed_volumedetect_0 @ 0x1] mean_volume: -30.2 dB\n[Parsed_volumedetect_0 @ 0x1] max_volume: -12.5 dB", -12.5, true},
+		{"[Parsed_volumedetect_0 @ 0x1] max_volume: 0.0 dB", 0, true},
+		{"[Parsed_volumedetect_0 @ 0x1] max_volume: -inf dB", -1000, true},
+		{"Output file is empty, nothing was encoded", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseMaxVolume(tt.output)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseMaxVolume(%q) = %v, %v, want %v, %v", tt.output, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsShortSilentClipDuration(t *testing.T) {
+	// Long or unknown durations are decided without probing the file
+	d := &Downloader{}
+	for _, duration := range []float64{0, ShortClipMaxDuration + 1, 600} {
+		if d.IsShortSilentClip(context.Background(), "/nonexistent.mp4", duration) {
+			t.Errorf("IsShortSilentClip(duration %v) = true, want false", duration)
+		}
+	}
+}
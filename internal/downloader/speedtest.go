@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultSpeedTestURL is the /speedtest download reference: 100 MB from
+// Cloudflare's edge, close to most servers.
+const DefaultSpeedTestURL = "https://speed.cloudflare.com/__down?bytes=100000000"
+
+// Throughput is how much data a transfer moved in how long.
+type Throughput struct {
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+// BytesPerSec is the average transfer rate.
+func (t Throughput) BytesPerSec() float64 {
+	if t.Elapsed <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) / t.Elapsed.Seconds()
+}
+
+// MeasureDownload downloads rawURL, discarding the body, until it ends or
+// limit passes, and reports the throughput. The bandwidth schedule doesn't
+// apply: this measures the link, not the bot's cap.
+func MeasureDownload(ctx context.Context, rawURL string, limit time.Duration) (Throughput, error) {
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Throughput{}, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Throughput{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Throughput{}, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	// Time the body only, so connection setup doesn't count against the rate
+	start := time.Now()
+	n, err := io.Copy(io.Discard, resp.Body)
+	result := Throughput{Bytes: n, Elapsed: time.Since(start)}
+	// Cut off at limit: what arrived until then is the measurement
+	if err != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return result, fmt.Errorf("download interrupted: %w", err)
+	}
+	if n == 0 {
+		return result, errors.New("no data received")
+	}
+	return result, nil
+}
@@ -0,0 +1,52 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 1<<20)))
+	}))
+	defer srv.Close()
+
+	result, err := MeasureDownload(context.Background(), srv.URL, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<20), result.Bytes)
+	assert.Greater(t, result.BytesPerSec(), 0.0)
+}
+
+func TestMeasureDownloadCutOff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	// The reference outlasting the limit is a measurement, not an error
+	result, err := MeasureDownload(context.Background(), srv.URL, 200*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("partial")), result.Bytes)
+}
+
+func TestMeasureDownloadHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := MeasureDownload(context.Background(), srv.URL, time.Minute)
+	assert.ErrorContains(t, err, "HTTP 404")
+}
+
+func TestThroughputBytesPerSec(t *testing.T) {
+	assert.Equal(t, 2048.0, Throughput{Bytes: 4096, Elapsed: 2 * time.Second}.BytesPerSec())
+	assert.Zero(t, Throughput{Bytes: 4096}.BytesPerSec())
+}
@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// packetInfo is one demuxed packet as reported by ffprobe -show_packets.
+type packetInfo struct {
+	Stream   int
+	Time     float64
+	Size     int64
+	Keyframe bool
+}
+
+// SizeCutPoints returns the keyframe timestamps at which to cut filePath so
+// that every stream-copied part stays under targetSize, computed from the
+// cumulative packet sizes (ffprobe -show_packets) rather than assuming a
+// constant bitrate.
+func (d *Downloader) SizeCutPoints(ctx context.Context, filePath string, targetSize int64) ([]float64, error) {
+	args := []string{
+		"-v", "error",
+		"-show_entries", "packet=stream_index,pts_time,size,flags:stream=index,codec_type",
+		"-of", "json",
+		filePath,
+	}
+	cmd := d.command(ctx, "ffprobe", args...)
+	output, err := cmd.Output()
+	recordUsage(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe packets failed: %w", err)
+	}
+
+	packets, videoStream, err := parsePackets(output)
+	if err != nil {
+		return nil, err
+	}
+	cuts := planCuts(packets, videoStream, targetSize)
+	logger.FromContext(ctx).Debug("Planned size-based cut points", "packets", len(packets), "cuts", cuts)
+	return cuts, nil
+}
+
+// parsePackets converts ffprobe -show_packets JSON into packets and returns
+// the index of the first video stream.
+func parsePackets(data []byte) ([]packetInfo, int, error) {
+	var raw struct {
+		Packets []struct {
+			StreamIndex int    `json:"stream_index"`
+			PtsTime     string `json:"pts_time"`
+			Size        string `json:"size"`
+			Flags       string `json:"flags"`
+		} `json:"packets"`
+		Streams []struct {
+			Index     int    `json:"index"`
+			CodecType string `json:"codec_type"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse ffprobe packets: %w", err)
+	}
+
+	videoStream := -1
+	for _, s := range raw.Streams {
+		if s.CodecType == "video" {
+			videoStream = s.Index
+			break
+		}
+	}
+	if videoStream < 0 {
+		return nil, 0, fmt.Errorf("no video stream found")
+	}
+
+	packets := make([]packetInfo, 0, len(raw.Packets))
+	for _, p := range raw.Packets {
+		size, _ := strconv.ParseInt(p.Size, 10, 64)
+		t, err := strconv.ParseFloat(p.PtsTime, 64)
+		if err != nil {
+			// Packets without a pts (e.g. "N/A") can't be cut points but still count
+			t = -1
+		}
+		packets = append(packets, packetInfo{
+			Stream:   p.StreamIndex,
+			Time:     t,
+			Size:     size,
+			Keyframe: strings.Contains(p.Flags, "K"),
+		})
+	}
+	return packets, videoStream, nil
+}
+
+// planCuts walks packets in file order and cuts at the last video keyframe
+// before the running part size would exceed targetSize. A part with no
+// keyframe in range is cut at the next keyframe and may overshoot; callers
+// verify part sizes afterwards.
+func planCuts(packets []packetInfo, videoStream int, targetSize int64) []float64 {
+	var cuts []float64
+	var partSize, sizeAtKeyframe int64
+	lastCut, keyframe := 0.0, -1.0
+
+	for _, p := range packets {
+		isCandidate := p.Stream == videoStream && p.Keyframe && p.Time > lastCut
+		if isCandidate {
+			if partSize+p.Size > targetSize {
+				// The part is full (or already overshot with no earlier
+				// keyframe to cut at): start the next one here
+				cuts = append(cuts, p.Time)
+				lastCut, keyframe = p.Time, -1
+				partSize = 0
+			} else {
+				keyframe, sizeAtKeyframe = p.Time, partSize
+			}
+		}
+		if !isCandidate && partSize+p.Size > targetSize && keyframe > lastCut {
+			cuts = append(cuts, keyframe)
+			lastCut = keyframe
+			partSize -= sizeAtKeyframe
+			keyframe = -1
+		}
+		partSize += p.Size
+	}
+	return cuts
+}
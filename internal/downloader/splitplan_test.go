@@ -0,0 +1,117 @@
+package downloader
+
+import "testing"
+
+// partSizes returns the total packet size of each part given cut points.
+func partSizes(packets []packetInfo, cuts []float64) []int64 {
+	sizes := make([]int64, len(cuts)+1)
+	part := 0
+	for _, p := range packets {
+		for part < len(cuts) && p.Stream == 0 && p.Keyframe && p.Time >= cuts[part] {
+			part++
+		}
+		sizes[part] += p.Size
+	}
+	return sizes
+}
+
+func TestPlanCutsVariableBitrate(t *testing.T) {
+	// 100 seconds, keyframe every 2s: a quiet first half (10 bytes/s video)
+	// and a busy second half (100 bytes/s), plus 1 byte/s audio
+	var packets []packetInfo
+	for sec := 0; sec < 100; sec++ {
+		size := int64(10)
+		if sec >= 50 {
+			size = 100
+		}
+		packets = append(packets,
+			packetInfo{Stream: 0, Time: float64(sec), Size: size, Keyframe: sec%2 == 0},
+			packetInfo{Stream: 1, Time: float64(sec), Size: 1, Keyframe: true},
+		)
+	}
+
+	const target = 1500
+	cuts := planCuts(packets, 0, target)
+	if len(cuts) == 0 {
+		t.Fatal("expected cut points")
+	}
+	for i, size := range partSizes(packets, cuts) {
+		if size > target {
+			t.Errorf("part %d is %d bytes, over the %d target (cuts %v)", i+1, size, target, cuts)
+		}
+	}
+	for i, c := range cuts {
+		if int(c)%2 != 0 {
+			t.Errorf("cut %d at %.0fs is not on a keyframe", i, c)
+		}
+	}
+	// Equal-duration splitting would put the busy half's data into later
+	// parts; size-based cuts should be closer together there
+	if cuts[0] < 50 {
+		t.Errorf("first cut at %.0fs, want it in the busy second half", cuts[0])
+	}
+}
+
+func TestPlanCutsSparseKeyframes(t *testing.T) {
+	// Keyframes only every 10 packets of 100 bytes: parts overshoot, but
+	// every cut still lands on a keyframe
+	var packets []packetInfo
+	for i := 0; i < 50; i++ {
+		packets = append(packets, packetInfo{Stream: 0, Time: float64(i), Size: 100, Keyframe: i%10 == 0})
+	}
+	cuts := planCuts(packets, 0, 250)
+	want := []float64{10, 20, 30, 40}
+	if len(cuts) != len(want) {
+		t.Fatalf("cuts = %v, want %v", cuts, want)
+	}
+	for i := range want {
+		if cuts[i] != want[i] {
+			t.Errorf("cuts = %v, want %v", cuts, want)
+			break
+		}
+	}
+}
+
+func TestPlanCutsFitsInOnePart(t *testing.T) {
+	packets := []packetInfo{
+		{Stream: 0, Time: 0, Size: 100, Keyframe: true},
+		{Stream: 0, Time: 1, Size: 100},
+	}
+	if cuts := planCuts(packets, 0, 1000); len(cuts) != 0 {
+		t.Errorf("cuts = %v, want none", cuts)
+	}
+}
+
+func TestParsePackets(t *testing.T) {
+	data := []byte(`{
+		"packets": [
+			{"stream_index": 1, "pts_time": "0.000000", "size": "300", "flags": "K__"},
+			{"stream_index": 0, "pts_time": "0.040000", "size": "5000", "flags": "K__"},
+			{"stream_index": 0, "pts_time": "N/A", "size": "20", "flags": "___"}
+		],
+		"streams": [
+			{"index": 0, "codec_type": "video"},
+			{"index": 1, "codec_type": "audio"}
+		]
+	}`)
+	packets, video, err := parsePackets(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if video != 0 {
+		t.Errorf("video stream = %d, want 0", video)
+	}
+	if len(packets) != 3 {
+		t.Fatalf("got %d packets, want 3", len(packets))
+	}
+	if p := packets[1]; !p.Keyframe || p.Size != 5000 || p.Time != 0.04 {
+		t.Errorf("packet 1 = %+v", p)
+	}
+	if packets[2].Time != -1 || packets[2].Keyframe {
+		t.Errorf("packet 2 = %+v", packets[2])
+	}
+
+	if _, _, err := parsePackets([]byte(`{"packets": [], "streams": [{"index": 0, "codec_type": "audio"}]}`)); err == nil {
+		t.Error("expected error without a video stream")
+	}
+}
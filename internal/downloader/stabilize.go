@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// encodeFilters are the optional video filters of the H.264 re-encode, in
+// the order they are applied.
+type encodeFilters struct {
+	Transforms   string // vidstab motion data from detectShake; "" skips vidstab
+	Deshake      bool   // single-pass deshake, when vidstab is unavailable
+	SubtitlePath string // SRT burned into the picture, after stabilizing
+}
+
+// chain returns the -vf filter graph, or "" if no filter applies.
+func (f encodeFilters) chain() string {
+	var filters []string
+	switch {
+	case f.Transforms != "":
+		// Smooth over ~1s of frames, zoom to hide the moving borders and
+		// sharpen what the interpolation softened
+		filters = append(filters,
+			"vidstabtransform=input="+filterValue(f.Transforms)+":smoothing=30:optzoom=1",
+			"unsharp=5:5:0.8:3:3:0.4")
+	case f.Deshake:
+		filters = append(filters, "deshake")
+	}
+	if f.SubtitlePath != "" {
+		filters = append(filters, subtitlesFilter(f.SubtitlePath))
+	}
+	return strings.Join(filters, ",")
+}
+
+// detectShake runs the first stabilization pass (vidstabdetect) over
+// filePath and returns the filters for the second, the re-encode. If this
+// ffmpeg is built without vidstab it falls back to the deshake filter.
+func (d *Downloader) detectShake(ctx context.Context, filePath string, duration float64, progressCb ProgressCallback) (encodeFilters, error) {
+	transforms := filepath.Join(filepath.Dir(filePath), "transforms.trf")
+	args := []string{
+		"-i", filePath,
+		"-vf", "vidstabdetect=shakiness=5:accuracy=15:result=" + filterValue(transforms),
+		"-an",
+		"-f", "null",
+		"-",
+	}
+	logger.FromContext(ctx).Info("Detecting camera shake", "input", filePath)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	err := runFFmpegProgress(ctx, cmd, duration, func(percent float64) {
+		if progressCb != nil {
+			progressCb(Progress{Phase: "stabilizing", Percent: percent})
+		}
+	})
+	recordUsage(ctx, cmd)
+	if err != nil {
+		if ctx.Err() != nil {
+			return encodeFilters{}, ctx.Err()
+		}
+		// Most likely ffmpeg without --enable-libvidstab
+		logger.FromContext(ctx).Warn("vidstabdetect failed, stabilizing with deshake instead", "error", err)
+		return encodeFilters{Deshake: true}, nil
+	}
+	return encodeFilters{Transforms: transforms}, nil
+}
@@ -0,0 +1,23 @@
+package downloader
+
+import "testing"
+
+func TestEncodeFiltersChain(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters encodeFilters
+		want    string
+	}{
+		{"none", encodeFilters{}, ""},
+		{"deshake", encodeFilters{Deshake: true}, "deshake"},
+		{"vidstab", encodeFilters{Transforms: "/w/t.trf"},
+			"vidstabtransform=input=/w/t.trf:smoothing=30:optzoom=1,unsharp=5:5:0.8:3:3:0.4"},
+		{"stabilized subtitles", encodeFilters{Deshake: true, SubtitlePath: "/w/s.srt"},
+			"deshake,subtitles=filename=/w/s.srt"},
+	}
+	for _, tt := range tests {
+		if got := tt.filters.chain(); got != tt.want {
+			t.Errorf("%s: chain() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// subtitleName is the base name subtitle files are written under; yt-dlp
+// appends the language, e.g. "_sushe_subs.en.srt".
+const subtitleName = "_sushe_subs"
+
+// SubtitleAuto as a subtitle language picks one per video with
+// NegotiateSubtitleLang: the requester's language, else the video's
+// original language, else English.
+const SubtitleAuto = "auto"
+
+// subLangRe matches the language codes accepted for subtitles: "en",
+// "pt-BR", "zh-Hans".
+var subLangRe = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})?$`)
+
+// ValidSubtitleLang reports whether lang can be passed to yt-dlp as a
+// subtitle language ("all" is a yt-dlp keyword, not a language).
+func ValidSubtitleLang(lang string) bool {
+	return lang != "all" && subLangRe.MatchString(lang)
+}
+
+// NegotiateSubtitleLang returns the first of prefer that the video has
+// subtitles in, from its available languages: an exact match (case aside),
+// else a regional variant either way ("pt" finds "pt-BR", "pt-br" finds
+// "pt"). Returns "" if none matches.
+func NegotiateSubtitleLang(available []string, prefer ...string) string {
+	for _, want := range prefer {
+		if want == "" {
+			continue
+		}
+		for _, lang := range available {
+			if strings.EqualFold(lang, want) {
+				return lang
+			}
+		}
+		for _, lang := range available {
+			if strings.EqualFold(baseLang(lang), baseLang(want)) {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// baseLang strips the region or script from a language code: "en-US" → "en".
+func baseLang(lang string) string {
+	base, _, _ := strings.Cut(lang, "-")
+	return base
+}
+
+// subtitleArgs returns the yt-dlp arguments that fetch subtitles for lang
+// (uploaded ones preferred, auto-generated as a fallback) as SRT into dir,
+// without downloading the video again.
+func subtitleArgs(dir, lang, url string) []string {
+	return []string{
+		"--skip-download",
+		"--no-playlist",
+		"--write-subs", "--write-auto-subs",
+		// Regional variants too: "en" also finds "en-US" / "en-GB"
+		"--sub-langs", lang + "," + lang + "-.*",
+		"--convert-subs", "srt",
+		"-o", "subtitle:" + filepath.Join(dir, subtitleName+".%(ext)s"),
+		"--no-warnings",
+		url,
+	}
+}
+
+// DownloadSubtitles fetches url's subtitles in lang into dir as SRT files
+// and returns their paths. No subtitles in that language is not an error:
+// the result is simply empty. Runs separately from the video download so a
+// subtitle failure never costs the video.
+func (d *Downloader) DownloadSubtitles(ctx context.Context, url, dir, lang string) ([]string, error) {
+	if !ValidSubtitleLang(lang) {
+		return nil, fmt.Errorf("invalid subtitle language %q", lang)
+	}
+
+	cmd := d.ytdlpIn(ctx, dir, subtitleArgs(dir, lang, url)...)
+	output, err := combinedOutput(ctx, cmd)
+	d.ytdlpDone(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("yt-dlp subtitles failed: %w, output: %s", err, string(output))
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, subtitleName+".*.srt"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	logger.FromContext(ctx).Debug("Downloaded subtitles", "lang", lang, "files", len(paths))
+	return paths, nil
+}
+
+// SubtitleLang returns the language of a subtitle file written by
+// DownloadSubtitles, e.g. "en-US" for "_sushe_subs.en-US.srt".
+func SubtitleLang(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".srt")
+	return strings.TrimPrefix(name, subtitleName+".")
+}
+
+// burnableSubtitle fetches url's subtitles in lang into dir and returns the
+// one to burn in, or "" if there are none. Failures are logged: the video
+// is then delivered without subtitles.
+func (d *Downloader) burnableSubtitle(ctx context.Context, url, dir, lang string) string {
+	paths, err := d.DownloadSubtitles(ctx, url, dir, lang)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Failed to download subtitles to burn in", "url", url, "lang", lang, "error", err)
+		return ""
+	}
+	path := pickSubtitle(paths, lang)
+	if path == "" {
+		logger.FromContext(ctx).Info("No subtitles to burn in", "url", url, "lang", lang)
+	}
+	return path
+}
+
+// pickSubtitle returns the subtitle file for exactly lang if there is one,
+// else the first regional variant, or "" if paths is empty.
+func pickSubtitle(paths []string, lang string) string {
+	for _, p := range paths {
+		if SubtitleLang(p) == lang {
+			return p
+		}
+	}
+	if len(paths) == 0 {
+		return ""
+	}
+	return paths[0]
+}
+
+// subtitlesFilter returns the ffmpeg video filter that renders the SRT file
+// at path.
+func subtitlesFilter(path string) string {
+	return "subtitles=filename=" + filterValue(path)
+}
+
+// filterValue escapes a path for use as a filter option value in a -vf
+// graph. It is escaped twice: once as an option value and once for the
+// filtergraph.
+func filterValue(path string) string {
+	value := strings.NewReplacer(`\`, `\\`, `:`, `\:`, `'`, `\'`).Replace(path)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(value)
+}
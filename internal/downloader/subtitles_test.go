@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPickSubtitle(t *testing.T) {
+	paths := []string{"/w/_sushe_subs.en-GB.srt", "/w/_sushe_subs.en-US.srt", "/w/_sushe_subs.en.srt"}
+	if got := pickSubtitle(paths, "en"); got != "/w/_sushe_subs.en.srt" {
+		t.Errorf("pickSubtitle(en) = %q, want the exact language", got)
+	}
+	if got := pickSubtitle(paths[:2], "en"); got != "/w/_sushe_subs.en-GB.srt" {
+		t.Errorf("pickSubtitle without exact match = %q, want the first variant", got)
+	}
+	if got := pickSubtitle(nil, "en"); got != "" {
+		t.Errorf("pickSubtitle(nil) = %q, want empty", got)
+	}
+}
+
+func TestNegotiateSubtitleLang(t *testing.T) {
+	available := []string{"de", "en-GB", "pt-BR"}
+	tests := []struct {
+		prefer []string
+		want   string
+	}{
+		{[]string{"de"}, "de"},
+		{[]string{"DE"}, "de"},
+		{[]string{"en"}, "en-GB"},        // regional variant of the wanted language
+		{[]string{"pt-br"}, "pt-BR"},     // Telegram sends lowercase regions
+		{[]string{"de-AT"}, "de"},        // the plain language for a regional wish
+		{[]string{"fr", "", "de"}, "de"}, // fallback, skipping unknowns
+		{[]string{"fr", "ja"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := NegotiateSubtitleLang(available, tt.prefer...); got != tt.want {
+			t.Errorf("NegotiateSubtitleLang(%v) = %q, want %q", tt.prefer, got, tt.want)
+		}
+	}
+}
+
+func TestSubtitlesFilter(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/tmp/1/_sushe_subs.en.srt", "subtitles=filename=/tmp/1/_sushe_subs.en.srt"},
+		{"/tmp/a:b/s.srt", `subtitles=filename=/tmp/a\\:b/s.srt`},
+		{"/tmp/it's/s.srt", `subtitles=filename=/tmp/it\\\'s/s.srt`},
+		{"/tmp/[x],y/s.srt", `subtitles=filename=/tmp/\[x\]\,y/s.srt`},
+	}
+	for _, tt := range tests {
+		if got := subtitlesFilter(tt.path); got != tt.want {
+			t.Errorf("subtitlesFilter(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestReencodeArgs(t *testing.T) {
+	plain := strings.Join(reencodeArgs("in.webm", "out.mp4", encodeFilters{}), " ")
+	if strings.Contains(plain, "-vf") {
+		t.Errorf("reencodeArgs without subtitles = %q, want no filter", plain)
+	}
+
+	burn := strings.Join(reencodeArgs("in.webm", "out.mp4", encodeFilters{SubtitlePath: "/w/s.srt"}), " ")
+	if !strings.Contains(burn, "-i in.webm -vf subtitles=filename=/w/s.srt -c:v libx264") {
+		t.Errorf("reencodeArgs with subtitles = %q, want the subtitles filter", burn)
+	}
+	if !strings.HasSuffix(burn, "out.mp4") {
+		t.Errorf("reencodeArgs = %q, want the output last", burn)
+	}
+}
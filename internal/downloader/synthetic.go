@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// SyntheticDuration is the length of the generated test video.
+const SyntheticDuration = 3
+
+// SyntheticVideo encodes a tiny H.264/AAC test clip (color bars and a tone)
+// with ffmpeg instead of downloading anything, for exercising the pipeline
+// end to end. The encode runs at real-time speed, so a context deadline
+// shorter than SyntheticDuration interrupts it mid-encode.
+func (d *Downloader) SyntheticVideo(ctx context.Context, progressCb ProgressCallback) (*DownloadResult, error) {
+	workDir, err := d.newWorkDir("synthetic")
+	if err != nil {
+		return nil, err
+	}
+	filePath := filepath.Join(workDir, "synthetic.mp4")
+
+	if progressCb != nil {
+		progressCb(Progress{Phase: "encoding", Codec: "synthetic"})
+	}
+	args := []string{
+		"-re",
+		"-f", "lavfi", "-i", fmt.Sprintf("testsrc=size=320x240:rate=25:duration=%d", SyntheticDuration),
+		"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=440:duration=%d", SyntheticDuration),
+		"-c:v", "libx264", "-preset", "ultrafast", "-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-movflags", "+faststart",
+		"-y",
+		filePath,
+	}
+	logger.FromContext(ctx).Debug("Running ffmpeg synthetic video", "args", args)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		d.RemoveWorkDir(workDir)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("encoding interrupted: %w", ctx.Err())
+		}
+		logger.FromContext(ctx).Error("ffmpeg synthetic video failed", "error", err, "output", string(output))
+		return nil, fmt.Errorf("failed to generate synthetic video: %w", err)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		d.RemoveWorkDir(workDir)
+		return nil, fmt.Errorf("failed to stat synthetic video: %w", err)
+	}
+	return &DownloadResult{
+		FilePath:    filePath,
+		FileName:    filepath.Base(filePath),
+		Title:       "Synthetic test video",
+		Duration:    SyntheticDuration,
+		FileSize:    info.Size(),
+		Width:       320,
+		Height:      240,
+		ContentType: "video/mp4",
+	}, nil
+}
@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// Telegram ignores video thumbnails over 320px on a side or 200KB.
+const (
+	thumbnailMaxSide = 320
+	thumbnailMaxSeek = 10.0 // seconds
+)
+
+// platformThumbName is the base name yt-dlp writes the source's own
+// thumbnail to, so it can be told apart from the downloaded media.
+const platformThumbName = "_sushe_platform_thumb"
+
+// writeThumbnailArgs asks yt-dlp to also save the platform thumbnail into workDir.
+func writeThumbnailArgs(workDir string) []string {
+	return []string{
+		"--write-thumbnail",
+		"-o", "thumbnail:" + filepath.Join(workDir, platformThumbName+".%(ext)s"),
+	}
+}
+
+// splitThumbnail separates the platform thumbnail written by yt-dlp from the
+// downloaded media files.
+func splitThumbnail(files []string) (media []string, thumb string) {
+	for _, f := range files {
+		if strings.HasPrefix(filepath.Base(f), platformThumbName+".") {
+			thumb = f
+			continue
+		}
+		media = append(media, f)
+	}
+	return media, thumb
+}
+
+// platformThumbnail converts the thumbnail yt-dlp saved (WebP/PNG/JPEG at
+// any size) into a JPEG Telegram accepts. Returns "" if there is none or the
+// conversion fails; the engine then extracts a frame instead.
+func (d *Downloader) platformThumbnail(ctx context.Context, src string) string {
+	if src == "" {
+		return ""
+	}
+	defer os.Remove(src)
+
+	out := filepath.Join(filepath.Dir(src), "platform_thumb.jpg")
+	cmd := d.command(ctx, "ffmpeg", thumbnailArgs(src, out, 0)...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Failed to convert platform thumbnail", "file", src, "error", err, "output", string(output))
+		return ""
+	}
+	return out
+}
+
+// ExtractThumbnail grabs a frame from a video as a JPEG Telegram accepts as
+// a thumbnail, written next to the video. duration (seconds, 0 if unknown)
+// picks a frame a little way in, past black intro frames.
+func (d *Downloader) ExtractThumbnail(ctx context.Context, videoPath string, duration float64) (string, error) {
+	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	thumbPath := filepath.Join(filepath.Dir(videoPath), base+"_thumb.jpg")
+
+	cmd := d.command(ctx, "ffmpeg", thumbnailArgs(videoPath, thumbPath, thumbnailSeek(duration))...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg thumbnail failed: %w, output: %s", err, string(output))
+	}
+	return thumbPath, nil
+}
+
+// thumbnailSeek returns the position of the thumbnail frame: a tenth of the
+// way in, at most thumbnailMaxSeek seconds.
+func thumbnailSeek(duration float64) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return min(duration/10, thumbnailMaxSeek)
+}
+
+func thumbnailArgs(in, out string, seek float64) []string {
+	side := strconv.Itoa(thumbnailMaxSide)
+	return []string{
+		"-ss", strconv.FormatFloat(seek, 'f', 2, 64),
+		"-i", in,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale='min(%s,iw)':'min(%s,ih)':force_original_aspect_ratio=decrease", side, side),
+		"-q:v", "5", // ~10-30KB at 320px, well under the 200KB limit
+		"-y",
+		out,
+	}
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unitTimeRe matches timestamps like "90s", "1m30s" or "1h2m3s" (YouTube,
+// Twitch).
+var unitTimeRe = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s)?$`)
+
+// StartTime returns the start offset in seconds carried by a link's t (or
+// start) parameter, in the query or the fragment: "?t=90", "&t=1m30s",
+// "#t=1:02:03". ExtractURLs keeps these parameters, so a link's timestamp
+// survives until the download. Returns 0 if there is none or it doesn't
+// parse (Twitter's "t" is a tracking token, for one).
+func StartTime(rawURL string) float64 {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0
+	}
+	fragment, _ := url.ParseQuery(u.Fragment)
+	for _, values := range []url.Values{u.Query(), fragment} {
+		for _, key := range []string{"t", "start"} {
+			if secs, ok := ParseTimestamp(values.Get(key)); ok && secs > 0 {
+				return secs
+			}
+		}
+	}
+	return 0
+}
+
+// ParseTimestamp parses a time offset given as seconds ("90", "90.5"),
+// units ("1h2m3s", "1m30s") or a clock ("1:30", "01:02:03").
+func ParseTimestamp(s string) (float64, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return secs, secs >= 0
+	}
+	if strings.Contains(s, ":") {
+		fields := strings.Split(s, ":")
+		if len(fields) > 3 {
+			return 0, false
+		}
+		var total float64
+		for i, f := range fields {
+			n, err := strconv.ParseFloat(f, 64)
+			// Only the seconds may have a fraction; minutes and seconds stay under 60
+			if err != nil || n < 0 || (i > 0 && n >= 60) || (i < len(fields)-1 && n != float64(int(n))) {
+				return 0, false
+			}
+			total = total*60 + n
+		}
+		return total, true
+	}
+	m := unitTimeRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	var total float64
+	for i, mult := range []float64{3600, 60, 1} {
+		if m[i+1] != "" {
+			n, _ := strconv.Atoi(m[i+1])
+			total += float64(n) * mult
+		}
+	}
+	return total, true
+}
+
+// sectionArg returns the yt-dlp --download-sections value for start..end
+// seconds; end 0 means to the end of the video.
+func sectionArg(start, end float64) string {
+	to := "inf"
+	if end > 0 {
+		to = strconv.FormatFloat(end, 'f', -1, 64)
+	}
+	return fmt.Sprintf("*%s-%s", strconv.FormatFloat(start, 'f', -1, 64), to)
+}
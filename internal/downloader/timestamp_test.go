@@ -0,0 +1,50 @@
+package downloader
+
+import "testing"
+
+func TestStartTime(t *testing.T) {
+	tests := []struct {
+		url  string
+		want float64
+	}{
+		{"https://youtu.be/abc?t=90", 90},
+		{"https://www.youtube.com/watch?v=abc&t=1m30s", 90},
+		{"https://www.youtube.com/watch?v=abc#t=1:02:03", 3723},
+		{"https://www.twitch.tv/videos/123?t=1h2m3s", 3723},
+		{"https://www.youtube.com/embed/abc?start=42", 42},
+		{"https://x.com/user/status/1?s=20&t=AbCdEf", 0},
+		{"https://youtu.be/abc", 0},
+		{"https://youtu.be/abc?t=0", 0},
+	}
+	for _, tt := range tests {
+		if got := StartTime(tt.url); got != tt.want {
+			t.Errorf("StartTime(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	valid := map[string]float64{
+		"90": 90, "90.5": 90.5, "1m": 60, "2h": 7200, "1H30M": 5400,
+		"1:30": 90, "01:02:03": 3723, "0:05.5": 5.5,
+	}
+	for in, want := range valid {
+		if got, ok := ParseTimestamp(in); !ok || got != want {
+			t.Errorf("ParseTimestamp(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "1:75", "1:2:3:4", "-5", "1.5:00", "m"} {
+		if got, ok := ParseTimestamp(in); ok {
+			t.Errorf("ParseTimestamp(%q) = %v, want invalid", in, got)
+		}
+	}
+}
+
+func TestSectionArg(t *testing.T) {
+	if got := sectionArg(90, 0); got != "*90-inf" {
+		t.Errorf("sectionArg(90, 0) = %q", got)
+	}
+	if got := sectionArg(12.5, 30); got != "*12.5-30" {
+		t.Errorf("sectionArg(12.5, 30) = %q", got)
+	}
+}
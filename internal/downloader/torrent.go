@@ -0,0 +1,277 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// torrentMetadataTimeout bounds fetching a magnet link's metadata from
+	// peers, so a dead swarm fails before the download timeout.
+	torrentMetadataTimeout = 5 * time.Minute
+
+	// torrentStallSeconds stops a download that got no data this long.
+	torrentStallSeconds = 600
+)
+
+// ErrNoTorrentVideo is returned for torrents without a video file.
+var ErrNoTorrentVideo = errors.New("the torrent has no video file")
+
+// torrentVideoExts are the files of a torrent that can be delivered.
+var torrentVideoExts = map[string]bool{
+	".mp4": true, ".m4v": true, ".mkv": true, ".webm": true, ".mov": true, ".avi": true,
+	".ts": true, ".wmv": true, ".flv": true, ".mpg": true, ".mpeg": true,
+}
+
+// IsTorrentURL reports whether rawURL is a magnet link or points at a
+// .torrent file.
+func IsTorrentURL(rawURL string) bool {
+	if strings.HasPrefix(strings.ToLower(rawURL), "magnet:?") {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return strings.EqualFold(path.Ext(u.Path), ".torrent")
+}
+
+// ExtractMagnets finds the magnet links in a message text; ExtractURLs only
+// finds http(s) links.
+func ExtractMagnets(text string) []string {
+	var magnets []string
+	for _, word := range strings.Fields(text) {
+		i := strings.Index(strings.ToLower(word), "magnet:?")
+		if i < 0 {
+			continue
+		}
+		magnet := strings.TrimRight(word[i:], urlTrailing+")]>")
+		if strings.Contains(strings.ToLower(magnet), "xt=urn:btih:") {
+			magnets = append(magnets, magnet)
+		}
+	}
+	return magnets
+}
+
+// SetTorrents enables downloading magnet links and .torrent files with
+// aria2c. Off, they go to yt-dlp like any link and fail.
+func (d *Downloader) SetTorrents(enabled bool) {
+	d.torrents = enabled
+}
+
+// Torrents reports whether torrent downloads are enabled.
+func (d *Downloader) Torrents() bool {
+	return d.torrents
+}
+
+// torrentFile is one file listed in a torrent.
+type torrentFile struct {
+	Index int
+	Path  string // relative to the download directory, e.g. "./Show/ep1.mkv"
+	Size  int64
+}
+
+// fetchTorrent downloads the largest video file of a torrent into workDir
+// with aria2c: it fetches the torrent's metadata (from peers for a magnet
+// link), lists the files, downloads only the chosen one and stops without
+// seeding.
+func (d *Downloader) fetchTorrent(ctx context.Context, rawURL, workDir string, opts Options, progressCb ProgressCallback) error {
+	metaDir := filepath.Join(workDir, "torrent-meta")
+	dataDir := filepath.Join(workDir, "torrent-data")
+	defer os.RemoveAll(metaDir)
+	defer os.RemoveAll(dataDir)
+
+	torrent, err := d.fetchTorrentMetadata(ctx, rawURL, metaDir)
+	if err != nil {
+		return err
+	}
+	cmd := d.command(ctx, "aria2c", "--show-files=true", torrent)
+	output, err := combinedOutput(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to list torrent files: %w", err)
+	}
+	file, ok := pickTorrentVideo(parseTorrentFiles(output))
+	if !ok {
+		return ErrNoTorrentVideo
+	}
+	if err := ensureFreeSpace(d.downloadDir, d.limits.EstimateDiskNeeds(file.Size, 0)); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("Downloading torrent", "url", rawURL, "file", file.Path, "size", file.Size)
+
+	args := []string{
+		"--dir=" + dataDir,
+		"--select-file=" + strconv.Itoa(file.Index),
+		"--seed-time=0",
+		"--bt-stop-timeout=" + strconv.Itoa(torrentStallSeconds),
+		"--bt-remove-unselected-file=true",
+		"--file-allocation=none",
+		"--summary-interval=1",
+		"--console-log-level=warn",
+		"--enable-color=false",
+	}
+	if rate := d.bandwidth.RateAt(time.Now()); rate > 0 {
+		args = append(args, "--max-overall-download-limit="+strconv.FormatInt(rate, 10))
+	}
+	cmd = d.command(ctx, "aria2c", append(args, torrent)...)
+	err = runAria2Progress(ctx, cmd, progressCb)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("download failed: aria2c: %w", err)
+	}
+
+	// Only the file goes up into workDir, where the pipeline looks for it
+	src := filepath.Join(dataDir, filepath.FromSlash(file.Path))
+	if !strings.HasPrefix(src, dataDir+string(filepath.Separator)) {
+		return fmt.Errorf("torrent file path escapes the download directory: %s", file.Path)
+	}
+	name := unsafeFileChars.ReplaceAllString(filepath.Base(file.Path), "_")
+	if err := os.Rename(src, filepath.Join(workDir, name)); err != nil {
+		return fmt.Errorf("failed to move torrent file: %w", err)
+	}
+	return nil
+}
+
+// fetchTorrentMetadata saves the .torrent of rawURL in dir and returns its
+// path: a .torrent link is fetched over HTTP, a magnet link's metadata is
+// asked from its peers.
+func (d *Downloader) fetchTorrentMetadata(ctx context.Context, rawURL, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(strings.ToLower(rawURL), "magnet:") {
+		dest := filepath.Join(dir, "download.torrent")
+		if err := d.fetchHTTP(ctx, rawURL, dest, nil); err != nil {
+			return "", fmt.Errorf("failed to fetch torrent file: %w", err)
+		}
+		return dest, nil
+	}
+
+	metaCtx, cancel := context.WithTimeout(ctx, torrentMetadataTimeout)
+	defer cancel()
+	cmd := d.command(metaCtx, "aria2c",
+		"--dir="+dir,
+		"--bt-metadata-only=true",
+		"--bt-save-metadata=true",
+		"--console-log-level=warn",
+		"--enable-color=false",
+		rawURL)
+	_, err := combinedOutput(metaCtx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if metaCtx.Err() != nil {
+			return "", fmt.Errorf("no peers sent the torrent's metadata within %s", torrentMetadataTimeout)
+		}
+		return "", fmt.Errorf("failed to fetch torrent metadata: %w", err)
+	}
+	found, _ := filepath.Glob(filepath.Join(dir, "*.torrent"))
+	if len(found) == 0 {
+		return "", fmt.Errorf("no torrent metadata saved")
+	}
+	return found[0], nil
+}
+
+var (
+	// "  2|./Big Buck Bunny/Big Buck Bunny.mp4"
+	torrentFileRe = regexp.MustCompile(`^\s*(\d+)\|(.+)$`)
+	// "   |263MiB (276,134,947)"
+	torrentSizeRe = regexp.MustCompile(`^\s*\|.*\(([\d,]+)\)\s*$`)
+)
+
+// parseTorrentFiles reads the file list of "aria2c --show-files", each
+// file an index|path line followed by a |size (bytes) line.
+func parseTorrentFiles(output []byte) []torrentFile {
+	var files []torrentFile
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := torrentFileRe.FindStringSubmatch(line); m != nil {
+			index, _ := strconv.Atoi(m[1])
+			files = append(files, torrentFile{Index: index, Path: strings.TrimSpace(m[2])})
+		} else if m := torrentSizeRe.FindStringSubmatch(line); m != nil && len(files) > 0 {
+			files[len(files)-1].Size, _ = strconv.ParseInt(strings.ReplaceAll(m[1], ",", ""), 10, 64)
+		}
+	}
+	return files
+}
+
+// pickTorrentVideo returns the largest video file: the feature of a movie
+// torrent rather than its trailer or samples.
+func pickTorrentVideo(files []torrentFile) (torrentFile, bool) {
+	var best torrentFile
+	found := false
+	for _, f := range files {
+		if !torrentVideoExts[strings.ToLower(path.Ext(f.Path))] {
+			continue
+		}
+		if !found || f.Size > best.Size {
+			best, found = f, true
+		}
+	}
+	return best, found
+}
+
+// aria2ProgressRe matches aria2c's summary line,
+// "[#2089b0 400.0KiB/33.2MiB(1%) CN:1 SD:3 DL:115.7KiB ETA:4m51s]".
+var aria2ProgressRe = regexp.MustCompile(`\[#\w+ (\S+)/(\S+)\((\d+)%\).*?DL:(\S+?)(?: ETA:(\S+?))?\]`)
+
+// runAria2Progress runs aria2c, reporting its summary lines as download
+// progress. The job's Pauser (see WithPauser) can pause it meanwhile.
+func runAria2Progress(ctx context.Context, cmd *exec.Cmd, progressCb ProgressCallback) error {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start aria2c: %w", err)
+	}
+	defer PauserFrom(ctx).track(cmd.Process.Pid)()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanCRLF)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		m := aria2ProgressRe.FindStringSubmatch(line)
+		if m == nil {
+			logger.FromContext(ctx).Debug("aria2c output", "line", line)
+			OutputTailFrom(ctx).add("aria2c", line)
+			continue
+		}
+		if progressCb != nil {
+			percent, _ := strconv.ParseFloat(m[3], 64)
+			progressCb(Progress{
+				Phase:      "downloading",
+				Percent:    percent,
+				Downloaded: m[1],
+				Total:      m[2],
+				Speed:      m[4] + "/s",
+				ETA:        m[5],
+			})
+		}
+	}
+	return cmd.Wait()
+}
@@ -0,0 +1,85 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTorrentURL(t *testing.T) {
+	for url, want := range map[string]bool{
+		"magnet:?xt=urn:btih:dd8255ecdc7ca55fb0bbf81323d87062db1f6d1c&dn=Big+Buck+Bunny": true,
+		"MAGNET:?xt=urn:btih:abc":                       true,
+		"https://example.com/files/movie.torrent":       true,
+		"https://example.com/files/movie.TORRENT?key=1": true,
+		"https://example.com/watch?v=movie.torrent":     false,
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ":   false,
+		"ftp://example.com/movie.torrent":               false,
+		"https://example.com/movie.mp4":                 false,
+	} {
+		assert.Equal(t, want, IsTorrentURL(url), url)
+	}
+}
+
+func TestExtractMagnets(t *testing.T) {
+	text := "grab this (magnet:?xt=urn:btih:abc123&dn=Movie&tr=udp%3A%2F%2Ftracker) and magnet:?dn=no-hash too"
+	assert.Equal(t, []string{"magnet:?xt=urn:btih:abc123&dn=Movie&tr=udp%3A%2F%2Ftracker"}, ExtractMagnets(text))
+	assert.Empty(t, ExtractMagnets("https://example.com/video.mp4"))
+}
+
+const showFilesOutput = `
+Download Results:
+gid   |stat|avg speed  |path/URI
+======+====+===========+=======================================================
+
+>>> Printing the contents of file 'bbb.torrent'...
+*** BitTorrent File Information ***
+Mode: multi
+Info Hash: dd8255ecdc7ca55fb0bbf81323d87062db1f6d1c
+Piece Length: 256KiB
+The Number of Pieces: 1055
+Total Length: 263MiB (276,445,467)
+Name: Big Buck Bunny
+Magnet URI: magnet:?xt=urn:btih:dd8255ecdc7ca55fb0bbf81323d87062db1f6d1c&dn=Big%20Buck%20Bunny
+Files:
+idx|path/length
+===+===========================================================================
+  1|./Big Buck Bunny/Big Buck Bunny.en.srt
+   |140B (140)
+---+---------------------------------------------------------------------------
+  2|./Big Buck Bunny/Big Buck Bunny.mp4
+   |263MiB (276,134,947)
+---+---------------------------------------------------------------------------
+  3|./Big Buck Bunny/sample.mkv
+   |303KiB (310,380)
+---+---------------------------------------------------------------------------
+`
+
+func TestParseTorrentFiles(t *testing.T) {
+	files := parseTorrentFiles([]byte(showFilesOutput))
+	require.Len(t, files, 3)
+	assert.Equal(t, torrentFile{Index: 1, Path: "./Big Buck Bunny/Big Buck Bunny.en.srt", Size: 140}, files[0])
+	assert.Equal(t, torrentFile{Index: 2, Path: "./Big Buck Bunny/Big Buck Bunny.mp4", Size: 276134947}, files[1])
+
+	video, ok := pickTorrentVideo(files)
+	require.True(t, ok)
+	assert.Equal(t, 2, video.Index)
+
+	_, ok = pickTorrentVideo(files[:1])
+	assert.False(t, ok, "subtitles only")
+}
+
+func TestAria2ProgressLine(t *testing.T) {
+	m := aria2ProgressRe.FindStringSubmatch("[#2089b0 400.0KiB/33.2MiB(1%) CN:1 SD:3 DL:115.7KiB ETA:4m51s]")
+	require.NotNil(t, m)
+	assert.Equal(t, []string{"400.0KiB", "33.2MiB", "1", "115.7KiB", "4m51s"}, m[1:])
+
+	m = aria2ProgressRe.FindStringSubmatch("[#2089b0 0B/0B CN:0 SD:0 DL:0B]")
+	assert.Nil(t, m, "metadata phase has no percentage")
+
+	m = aria2ProgressRe.FindStringSubmatch("[#2089b0 33.2MiB/33.2MiB(100%) CN:1 SD:0 DL:0B]")
+	require.NotNil(t, m)
+	assert.Equal(t, "100", m[3])
+	assert.Equal(t, "", m[5])
+}
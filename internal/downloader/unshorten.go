@@ -0,0 +1,210 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedURL is returned when a link (or one of its redirects) points to a
+// blocked host or to a private network address.
+var ErrBlockedURL = errors.New("link points to a blocked host")
+
+const (
+	maxRedirects     = 10
+	unshortenTimeout = 10 * time.Second
+)
+
+// shortenerHosts are link shorteners whose URLs are resolved before download.
+var shortenerHosts = map[string]bool{
+	"bit.ly":      true,
+	"buff.ly":     true,
+	"cutt.ly":     true,
+	"goo.gl":      true,
+	"is.gd":       true,
+	"lnkd.in":     true,
+	"ow.ly":       true,
+	"rb.gy":       true,
+	"rebrand.ly":  true,
+	"s.id":        true,
+	"shorturl.at": true,
+	"t.co":        true,
+	"t.ly":        true,
+	"tiny.cc":     true,
+	"tinyurl.com": true,
+}
+
+// IsShortLink reports whether rawURL is on a known link shortener.
+func IsShortLink(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return shortenerHosts[strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")]
+}
+
+// Unshortener follows redirects of short links to their final URL, refusing
+// blocked hosts and private addresses along the way.
+type Unshortener struct {
+	client       *http.Client
+	blocked      map[string]bool
+	allowPrivate bool // tests only: httptest servers listen on loopback
+}
+
+// NewUnshortener creates an Unshortener that rejects the given hosts and
+// their subdomains.
+func NewUnshortener(blocked []string) *Unshortener {
+	u := &Unshortener{blocked: make(map[string]bool)}
+	for _, host := range blocked {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			u.blocked[host] = true
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: unshortenTimeout,
+		// Checked after DNS resolution, so hostnames pointing at internal
+		// addresses are caught too
+		Control: func(network, address string, c syscall.RawConn) error {
+			if u.allowPrivate {
+				return nil
+			}
+			return refusePrivate(network, address, c)
+		},
+	}
+	u.client = &http.Client{
+		Timeout:   unshortenTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		// Redirects are followed by hand so every hop is checked
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return u
+}
+
+// IsBlocked reports whether rawURL's host (or a parent domain) is blocked.
+func (u *Unshortener) IsBlocked(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for host != "" {
+		if u.blocked[host] {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return false
+}
+
+// Resolve follows up to maxRedirects redirects from rawURL and returns the
+// final URL. Each hop must be http(s) and not blocked.
+func (u *Unshortener) Resolve(ctx context.Context, rawURL string) (string, error) {
+	current := rawURL
+	for hop := 0; hop <= maxRedirects; hop++ {
+		parsed, err := url.Parse(current)
+		if err != nil {
+			return "", fmt.Errorf("invalid redirect URL %q: %w", current, err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return "", fmt.Errorf("%w: unsupported scheme %q", ErrBlockedURL, parsed.Scheme)
+		}
+		if u.IsBlocked(current) {
+			return "", fmt.Errorf("%w: %s", ErrBlockedURL, parsed.Hostname())
+		}
+
+		resp, err := u.do(ctx, http.MethodHead, current)
+		// Some shorteners reject HEAD; retry those with GET
+		if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusForbidden) {
+			resp, err = u.do(ctx, http.MethodGet, current)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", current, err)
+		}
+
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			return current, nil
+		}
+		next, err := parsed.Parse(location)
+		if err != nil {
+			return "", fmt.Errorf("invalid redirect location %q: %w", location, err)
+		}
+		current = next.String()
+	}
+	return "", fmt.Errorf("too many redirects resolving %s", rawURL)
+}
+
+// do sends a request and discards the body; only status and headers are used.
+func (u *Unshortener) do(ctx context.Context, method, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; sushe-bot)")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	return resp, nil
+}
+
+// isPrivateIP reports whether ip is loopback, link-local, private or unspecified.
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// LoadHostList reads a blocklist file: one host per line, "#" comments
+// allowed. Hosts-file lines ("0.0.0.0 evil.example") use the last field.
+func LoadHostList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hosts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		hosts = append(hosts, fields[len(fields)-1])
+	}
+	return hosts, scanner.Err()
+}
+
+// refusePrivate is a net.Dialer Control that fails connections to private
+// network addresses. It runs after DNS resolution, so hostnames pointing at
+// internal addresses are caught too.
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedURL, host)
+	}
+	return nil
+}
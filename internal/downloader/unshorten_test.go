@@ -0,0 +1,96 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsShortLink(t *testing.T) {
+	if !IsShortLink("https://bit.ly/abc") || !IsShortLink("https://www.tinyurl.com/abc") {
+		t.Error("expected bit.ly and tinyurl.com to be short links")
+	}
+	if IsShortLink("https://www.youtube.com/watch?v=x") || IsShortLink("not a url") {
+		t.Error("unexpected short link match")
+	}
+}
+
+func TestUnshortenerResolve(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/short", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hop", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		// Pretend HEAD is not supported on this hop
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.Redirect(w, r, "/video", http.StatusFound)
+	})
+	mux.HandleFunc("/video", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u := NewUnshortener(nil)
+	u.allowPrivate = true
+
+	got, err := u.Resolve(context.Background(), srv.URL+"/short")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got != srv.URL+"/video" {
+		t.Errorf("Resolve = %q, want %q", got, srv.URL+"/video")
+	}
+
+	if _, err := u.Resolve(context.Background(), srv.URL+"/loop"); err == nil {
+		t.Error("expected error for redirect loop")
+	}
+}
+
+func TestUnshortenerBlocksPrivateAndListedHosts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://evil.example/x", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	// Loopback is refused by default
+	u := NewUnshortener([]string{"evil.example"})
+	if _, err := u.Resolve(context.Background(), srv.URL); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("loopback: err = %v, want ErrBlockedURL", err)
+	}
+
+	// A redirect to a blocked host is refused before it is requested
+	u.allowPrivate = true
+	if _, err := u.Resolve(context.Background(), srv.URL); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("blocked redirect: err = %v, want ErrBlockedURL", err)
+	}
+
+	if !u.IsBlocked("https://cdn.evil.example/a") || u.IsBlocked("https://notevil.example/") {
+		t.Error("IsBlocked should match the host and its subdomains only")
+	}
+}
+
+func TestLoadHostList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist")
+	content := "# comment\nevil.example\n0.0.0.0 tracker.example # hosts format\n\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := LoadHostList(path)
+	if err != nil {
+		t.Fatalf("LoadHostList: %v", err)
+	}
+	if want := []string{"evil.example", "tracker.example"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("hosts = %v, want %v", hosts, want)
+	}
+}
@@ -0,0 +1,38 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DefaultUpdateCommand updates a yt-dlp release binary in place; pip or
+// package-manager installs need their own command (SUSHE_UPDATE_COMMAND).
+const DefaultUpdateCommand = "yt-dlp -U"
+
+// YtdlpVersion returns the installed yt-dlp version, e.g. "2026.09.30".
+func (d *Downloader) YtdlpVersion(ctx context.Context) (string, error) {
+	cmd := d.command(ctx, "yt-dlp", "--version")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("yt-dlp --version failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// UpdateYtdlp runs the update command line (split on spaces, no shell) and
+// returns its output. It runs outside the sandbox (see Downloader.SetSandbox), which
+// would keep it from replacing the yt-dlp binary.
+func UpdateYtdlp(ctx context.Context, commandLine string) (string, error) {
+	fields := strings.Fields(commandLine)
+	if len(fields) == 0 {
+		return "", errors.New("no update command")
+	}
+	output, err := exec.CommandContext(ctx, fields[0], fields[1:]...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%s failed: %w", fields[0], err)
+	}
+	return string(output), nil
+}
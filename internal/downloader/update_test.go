@@ -0,0 +1,21 @@
+package downloader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateYtdlp(t *testing.T) {
+	output, err := UpdateYtdlp(context.Background(), "echo  Updated yt-dlp")
+	require.NoError(t, err)
+	assert.Equal(t, "Updated yt-dlp\n", output)
+
+	_, err = UpdateYtdlp(context.Background(), "false")
+	assert.ErrorContains(t, err, "false failed")
+
+	_, err = UpdateYtdlp(context.Background(), " ")
+	assert.Error(t, err)
+}
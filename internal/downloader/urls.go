@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// urlTrailing are characters that end a sentence or markdown emphasis
+// rather than a URL when they come last: "see https://youtu.be/abc."
+const urlTrailing = ".,;:!?'\"*~"
+
+// urlClosers maps closing brackets to their openers. A closer ends a URL
+// unless it matches an opener inside it, so Wikipedia-style paths keep
+// theirs: "(https://en.wikipedia.org/wiki/Go_(game))".
+var urlClosers = map[rune]rune{')': '(', ']': '[', '}': '{'}
+
+// ExtractURLs finds the http(s) URLs in a message text. URLs may be glued to
+// surrounding text: wrapped in brackets, quotes or markdown links
+// ("[title](https://...)"), followed by punctuation, or next to CJK text
+// and brackets (「https://...」). The scheme is lowercased; query strings and
+// fragments such as "?t=90" are kept.
+func ExtractURLs(text string) []string {
+	var urls []string
+	lower := strings.ToLower(text)
+	for i := 0; i < len(text); {
+		start := nextScheme(lower, i)
+		if start < 0 {
+			break
+		}
+		end := scanURL(text, start)
+		if u := cleanURL(text[start:end]); u != "" {
+			urls = append(urls, u)
+		}
+		i = end
+	}
+	return urls
+}
+
+// nextScheme returns the offset of the next "http://" or "https://" in
+// lower at or after i, -1 if there is none.
+func nextScheme(lower string, i int) int {
+	for {
+		j := strings.Index(lower[i:], "http")
+		if j < 0 {
+			return -1
+		}
+		j += i
+		rest := lower[j:]
+		if strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://") {
+			return j
+		}
+		i = j + len("http")
+	}
+}
+
+// scanURL returns where the URL starting at start ends: at whitespace,
+// quotes and angle brackets, CJK punctuation, or a closing bracket that
+// closes nothing within the URL.
+func scanURL(text string, start int) int {
+	depth := make(map[rune]int)
+	for i := start; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case unicode.IsSpace(r), r == '<', r == '>', r == '"', r == '`', isCJKPunct(r):
+			return i
+		case r == '(' || r == '[' || r == '{':
+			depth[r]++
+		case urlClosers[r] != 0:
+			opener := urlClosers[r]
+			if depth[opener] == 0 {
+				return i
+			}
+			depth[opener]--
+		}
+		i += size
+	}
+	return len(text)
+}
+
+// isCJKPunct reports whether r is CJK or full-width punctuation, such as
+// 「」、。（）！, which never belongs to a URL someone pastes.
+func isCJKPunct(r rune) bool {
+	switch {
+	case r >= 0x3000 && r <= 0x303F: // CJK symbols and punctuation
+		return true
+	case r >= 0xFF01 && r <= 0xFF0F, r >= 0xFF1A && r <= 0xFF20, r >= 0xFF3B && r <= 0xFF40, r >= 0xFF5B && r <= 0xFF65:
+		// Full-width punctuation (not letters or digits)
+		return true
+	}
+	return false
+}
+
+// cleanURL drops trailing punctuation from a scanned URL and checks it
+// parses as an http(s) URL with a host. Returns "" if it doesn't.
+func cleanURL(s string) string {
+	s = strings.TrimRight(s, urlTrailing)
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	// Lowercase the scheme, keep the rest as written
+	return u.Scheme + s[len(u.Scheme):]
+}
@@ -0,0 +1,37 @@
+package downloader
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractURLs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"https://youtu.be/abc", []string{"https://youtu.be/abc"}},
+		{"look https://youtu.be/abc?t=90, and https://vimeo.com/1.", []string{"https://youtu.be/abc?t=90", "https://vimeo.com/1"}},
+		{"Wow!!! https://youtu.be/abc!!!", []string{"https://youtu.be/abc"}},
+		{"(see https://en.wikipedia.org/wiki/Go_(game))", []string{"https://en.wikipedia.org/wiki/Go_(game)"}},
+		{"https://en.wikipedia.org/wiki/Go_(game)", []string{"https://en.wikipedia.org/wiki/Go_(game)"}},
+		{"[my video](https://youtu.be/abc)", []string{"https://youtu.be/abc"}},
+		{"[https://youtu.be/abc](https://youtu.be/abc)", []string{"https://youtu.be/abc", "https://youtu.be/abc"}},
+		{"**https://youtu.be/abc**", []string{"https://youtu.be/abc"}},
+		{"<https://youtu.be/abc>", []string{"https://youtu.be/abc"}},
+		{`"https://youtu.be/abc"`, []string{"https://youtu.be/abc"}},
+		{"これ見て「https://youtu.be/abc」、すごい", []string{"https://youtu.be/abc"}},
+		{"動画：https://youtu.be/abc。次はhttps://youtu.be/def（公式）", []string{"https://youtu.be/abc", "https://youtu.be/def"}},
+		{"link:https://x.com/user/status/1", []string{"https://x.com/user/status/1"}},
+		{"HTTPS://YouTu.be/abc", []string{"https://YouTu.be/abc"}},
+		{"https://ru.wikipedia.org/wiki/Кот", []string{"https://ru.wikipedia.org/wiki/Кот"}},
+		{"https://youtube.com/watch?v=abc_def_", []string{"https://youtube.com/watch?v=abc_def_"}},
+		{"no links here, just http and https:// alone", nil},
+		{"httpx://example.com ftp://example.com", nil},
+	}
+	for _, tt := range tests {
+		if got := ExtractURLs(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExtractURLs(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Usage accumulates resource usage of the yt-dlp/ffmpeg subprocesses run for one job.
+// Attach it to a context with WithUsage; the downloader records every finished
+// subprocess started with that context.
+type Usage struct {
+	mu        sync.Mutex
+	peakRSS   int64         // bytes, max over all subprocesses
+	cpuTime   time.Duration // user + system time, summed over all subprocesses
+	processes int
+}
+
+// Add records the resource usage of a finished process.
+func (u *Usage) Add(ps *os.ProcessState) {
+	if u == nil || ps == nil {
+		return
+	}
+	rss := int64(0)
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok && ru != nil {
+		rss = int64(ru.Maxrss)
+		if runtime.GOOS == "linux" {
+			rss *= 1024 // Linux reports ru_maxrss in KB, macOS in bytes
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if rss > u.peakRSS {
+		u.peakRSS = rss
+	}
+	u.cpuTime += ps.UserTime() + ps.SystemTime()
+	u.processes++
+}
+
+// PeakRSS returns the largest resident set size of any recorded subprocess, in bytes.
+func (u *Usage) PeakRSS() int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.peakRSS
+}
+
+// CPUTime returns the total CPU time (user + system) of all recorded subprocesses.
+func (u *Usage) CPUTime() time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.cpuTime
+}
+
+// Processes returns the number of recorded subprocesses.
+func (u *Usage) Processes() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.processes
+}
+
+type usageKey struct{}
+
+// WithUsage returns a context that records subprocess resource usage into u.
+func WithUsage(ctx context.Context, u *Usage) context.Context {
+	return context.WithValue(ctx, usageKey{}, u)
+}
+
+// UsageFrom returns the Usage attached to ctx, or nil.
+func UsageFrom(ctx context.Context) *Usage {
+	u, _ := ctx.Value(usageKey{}).(*Usage)
+	return u
+}
+
+// recordUsage adds a finished command's resource usage to the job's Usage, if any.
+func recordUsage(ctx context.Context, cmd *exec.Cmd) {
+	if cmd.ProcessState == nil {
+		return
+	}
+	UsageFrom(ctx).Add(cmd.ProcessState)
+}
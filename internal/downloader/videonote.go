@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	// VideoNoteSize is the side of a video note (round video) in pixels.
+	VideoNoteSize = 384
+
+	// VideoNoteMaxDuration is the longest video note Telegram accepts, in seconds.
+	VideoNoteMaxDuration = 60
+)
+
+// ConvertToVideoNote center-crops a video to a square, scales it to
+// VideoNoteSize and cuts it to VideoNoteMaxDuration, as Telegram requires for
+// video notes. Returns the path of the new MP4.
+func (d *Downloader) ConvertToVideoNote(ctx context.Context, filePath string) (string, error) {
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(dir, baseName+"_note.mp4")
+
+	args := videoNoteArgs(filePath, outPath)
+	logger.FromContext(ctx).Debug("Running ffmpeg video note conversion", "args", args)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Error("ffmpeg video note conversion failed", "error", err, "output", string(output))
+		return "", fmt.Errorf("failed to convert to video note: %w", err)
+	}
+	return outPath, nil
+}
+
+// videoNoteArgs returns the ffmpeg arguments for a square H.264/AAC video note.
+func videoNoteArgs(input, output string) []string {
+	size := strconv.Itoa(VideoNoteSize)
+	return []string{
+		"-i", input,
+		"-t", strconv.Itoa(VideoNoteMaxDuration),
+		"-vf", "crop='min(iw,ih)':'min(iw,ih)',scale=" + size + ":" + size + ",setsar=1",
+		"-c:v", "libx264",
+		"-preset", "fast",
+		"-crf", "26",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "96k",
+		"-movflags", "+faststart",
+		"-y",
+		output,
+	}
+}
@@ -0,0 +1,15 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVideoNoteArgs(t *testing.T) {
+	args := strings.Join(videoNoteArgs("/tmp/in.mp4", "/tmp/in_note.mp4"), " ")
+	for _, want := range []string{"-t 60", "crop='min(iw,ih)':'min(iw,ih)'", "scale=384:384", "libx264", "/tmp/in_note.mp4"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("videoNoteArgs = %q, missing %q", args, want)
+		}
+	}
+}
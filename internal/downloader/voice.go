@@ -0,0 +1,44 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// voiceBitrate is the Opus bitrate for voice messages: plenty for speech,
+// and keeps hour-long podcasts around 15MB.
+const voiceBitrate = "32k"
+
+// ConvertToVoice transcodes an audio file to mono OGG/Opus, the format
+// Telegram requires for voice messages. Returns the path of the .ogg file.
+func (d *Downloader) ConvertToVoice(ctx context.Context, filePath string) (string, error) {
+	dir := filepath.Dir(filePath)
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(dir, baseName+"_voice.ogg")
+
+	args := []string{
+		"-i", filePath,
+		"-vn",
+		"-map_metadata", "-1",
+		"-c:a", "libopus",
+		"-b:a", voiceBitrate,
+		"-ac", "1",
+		"-application", "voip",
+		"-y",
+		outPath,
+	}
+	logger.FromContext(ctx).Debug("Running ffmpeg voice conversion", "args", args)
+
+	cmd := d.command(ctx, "ffmpeg", args...)
+	output, err := combinedOutput(ctx, cmd)
+	recordUsage(ctx, cmd)
+	if err != nil {
+		logger.FromContext(ctx).Error("ffmpeg voice conversion failed", "error", err, "output", string(output))
+		return "", fmt.Errorf("failed to convert to voice: %w", err)
+	}
+	return outPath, nil
+}
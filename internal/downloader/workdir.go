@@ -0,0 +1,115 @@
+package downloader
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// maxWorkDirAttempts bounds the retries on work directory name collisions.
+const maxWorkDirAttempts = 5
+
+// workDirRegistry tracks the work directories of running jobs and what
+// each belongs to, so concurrent jobs never share or remove each other's.
+type workDirRegistry struct {
+	mu     sync.Mutex
+	owners map[string]string // work dir → owner (the URL being downloaded)
+}
+
+// claim registers dir for owner, false if it is already taken.
+func (r *workDirRegistry) claim(dir, owner string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.owners == nil {
+		r.owners = make(map[string]string)
+	}
+	if _, taken := r.owners[dir]; taken {
+		return false
+	}
+	r.owners[dir] = owner
+	return true
+}
+
+// release unregisters dir and returns its owner.
+func (r *workDirRegistry) release(dir string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	owner, ok := r.owners[dir]
+	delete(r.owners, dir)
+	return owner, ok
+}
+
+// owner returns who dir belongs to.
+func (r *workDirRegistry) owner(dir string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	owner, ok := r.owners[dir]
+	return owner, ok
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// newWorkDir creates a work directory for one download of owner, named by
+// a UUID. The name is claimed in the registry before the directory is
+// created, and os.Mkdir fails on leftovers from an earlier run, so two jobs
+// never end up in the same directory.
+func (d *Downloader) newWorkDir(owner string) (string, error) {
+	if err := os.MkdirAll(d.downloadDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create download directory: %w", err)
+	}
+	for i := 0; i < maxWorkDirAttempts; i++ {
+		id, err := newUUID()
+		if err != nil {
+			return "", fmt.Errorf("failed to name work directory: %w", err)
+		}
+		dir := filepath.Join(d.downloadDir, id)
+		if !d.workDirs.claim(dir, owner) {
+			continue
+		}
+		err = os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		d.workDirs.release(dir)
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("failed to create work directory: %w", err)
+		}
+	}
+	return "", errors.New("failed to create work directory: too many name collisions")
+}
+
+// RemoveWorkDir deletes a work directory created for a download and
+// releases its name. A nil Downloader only deletes it.
+func (d *Downloader) RemoveWorkDir(dir string) {
+	dir = filepath.Clean(dir)
+	if err := os.RemoveAll(dir); err != nil {
+		logger.Warn("Failed to remove work directory", "dir", dir, "error", err)
+	}
+	if d == nil {
+		return
+	}
+	if owner, ok := d.workDirs.release(dir); ok {
+		logger.Debug("Removed work directory", "dir", dir, "owner", owner)
+	}
+}
+
+// WorkDirOwner returns the URL a running download's work directory belongs
+// to, false if dir isn't in use.
+func (d *Downloader) WorkDirOwner(dir string) (string, bool) {
+	return d.workDirs.owner(filepath.Clean(dir))
+}
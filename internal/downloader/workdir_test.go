@@ -0,0 +1,91 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestNewUUID(t *testing.T) {
+	id, err := newUUID()
+	if err != nil {
+		t.Fatalf("newUUID: %v", err)
+	}
+	if len(id) != 36 || id[14] != '4' {
+		t.Errorf("newUUID() = %q, want a version 4 UUID", id)
+	}
+}
+
+func TestNewWorkDirConcurrent(t *testing.T) {
+	d := &Downloader{downloadDir: t.TempDir()}
+	const jobs = 50
+	dirs := make([]string, jobs)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dir, err := d.newWorkDir("https://example.com/v")
+			if err != nil {
+				t.Errorf("newWorkDir: %v", err)
+				return
+			}
+			dirs[i] = dir
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if seen[dir] {
+			t.Fatalf("work dir %s handed out twice", dir)
+		}
+		seen[dir] = true
+		if filepath.Dir(dir) != d.downloadDir {
+			t.Errorf("work dir %s not in the download dir", dir)
+		}
+		if owner, ok := d.WorkDirOwner(dir); !ok || owner != "https://example.com/v" {
+			t.Errorf("WorkDirOwner(%s) = %q, %v", dir, owner, ok)
+		}
+	}
+}
+
+func TestRemoveWorkDir(t *testing.T) {
+	d := &Downloader{downloadDir: t.TempDir()}
+	dir, err := d.newWorkDir("u")
+	if err != nil {
+		t.Fatalf("newWorkDir: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "video.mp4"), []byte("x"), 0644)
+
+	d.RemoveWorkDir(dir + "/")
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("work dir still exists: %v", err)
+	}
+	if _, ok := d.WorkDirOwner(dir); ok {
+		t.Error("removed work dir still registered")
+	}
+
+	// A nil downloader still deletes
+	other := filepath.Join(t.TempDir(), "job")
+	os.Mkdir(other, 0755)
+	(*Downloader)(nil).RemoveWorkDir(other)
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Errorf("dir still exists after nil RemoveWorkDir: %v", err)
+	}
+}
+
+func TestNewWorkDirSkipsClaimed(t *testing.T) {
+	d := &Downloader{downloadDir: t.TempDir()}
+	var r workDirRegistry
+	if !r.claim("/a", "x") || r.claim("/a", "y") {
+		t.Error("claim should succeed once per dir")
+	}
+	if owner, _ := r.release("/a"); owner != "x" {
+		t.Errorf("release owner = %q, want x", owner)
+	}
+	if _, err := d.newWorkDir("u"); err != nil {
+		t.Errorf("newWorkDir: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// homeDirName is the HOME of yt-dlp runs inside a work directory. Globs
+// of the work directory match it too (and it sorts first), so they go
+// through skipHome before a file is picked as the download.
+const homeDirName = ".home"
+
+// skipHome drops directories, the yt-dlp HOME among them, from a glob of a
+// work directory.
+func skipHome(files []string) []string {
+	kept := files[:0]
+	for _, f := range files {
+		if filepath.Base(f) == homeDirName {
+			continue
+		}
+		if info, err := os.Stat(f); err == nil && info.IsDir() {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// SetYtdlpConfig makes every yt-dlp call load the config file at path,
+// the only one it reads: the host's /etc/yt-dlp.conf and ~/.config/yt-dlp
+// are always ignored. "" for none.
+func (d *Downloader) SetYtdlpConfig(path string) {
+	d.ytdlpConfig = path
+}
+
+// configArgs returns the yt-dlp flags that pin its configuration: no
+// config files except the bot's own, and the bot's cache directory even
+// though HOME points elsewhere.
+func (d *Downloader) configArgs() []string {
+	args := []string{"--ignore-config"}
+	if d.ytdlpConfig != "" {
+		args = append(args, "--config-locations", d.ytdlpConfig)
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		args = append(args, "--cache-dir", filepath.Join(dir, "yt-dlp"))
+	}
+	return args
+}
+
+// ytdlpIn is ytdlp for a job's download, run in workDir with a HOME of its
+// own inside it, removed with the work directory.
+func (d *Downloader) ytdlpIn(ctx context.Context, workDir string, args ...string) *exec.Cmd {
+	cmd := d.ytdlp(ctx, args...)
+	cmd.Dir = workDir
+	home := filepath.Join(workDir, homeDirName)
+	if err := os.MkdirAll(home, 0700); err != nil {
+		logger.FromContext(ctx).Warn("Failed to create yt-dlp home", "dir", home, "error", err)
+		return cmd
+	}
+	setEnv(cmd, "HOME", home)
+	return cmd
+}
+
+// sharedHome returns the HOME of yt-dlp runs outside a job's work
+// directory (probes, listings): an empty directory of the bot's, never the
+// user's home with its plugins and configs.
+func (d *Downloader) sharedHome() (string, error) {
+	home := filepath.Join(d.downloadDir, homeDirName)
+	return home, os.MkdirAll(home, 0700)
+}
+
+// setEnv sets key in cmd's environment, replacing an inherited value.
+func setEnv(cmd *exec.Cmd, key, value string) {
+	prefix := key + "="
+	env := cmd.Env[:0:0]
+	for _, kv := range cmd.Env {
+		if !strings.HasPrefix(kv, prefix) {
+			env = append(env, kv)
+		}
+	}
+	cmd.Env = append(env, prefix+value)
+}
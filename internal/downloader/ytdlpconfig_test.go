@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestYtdlpIgnoresConfig(t *testing.T) {
+	d := &Downloader{downloadDir: t.TempDir()}
+	line := strings.Join(d.ytdlp(context.Background(), "-J", "url").Args, " ")
+	if !strings.Contains(line, "--ignore-config") || strings.Contains(line, "--config-locations") {
+		t.Errorf("yt-dlp args = %q, want --ignore-config only", line)
+	}
+
+	d.SetYtdlpConfig("/etc/sushe/yt-dlp.conf")
+	line = strings.Join(d.ytdlp(context.Background(), "-J", "url").Args, " ")
+	if !strings.Contains(line, "--ignore-config --config-locations /etc/sushe/yt-dlp.conf") {
+		t.Errorf("yt-dlp args = %q, want the bot's config file", line)
+	}
+}
+
+func TestYtdlpHome(t *testing.T) {
+	t.Setenv("HOME", "/home/operator")
+	d := &Downloader{downloadDir: t.TempDir()}
+
+	cmd := d.ytdlp(context.Background(), "-J", "url")
+	if got := envValue(cmd.Env, "HOME"); got != filepath.Join(d.downloadDir, homeDirName) {
+		t.Errorf("shared HOME = %q", got)
+	}
+
+	workDir := t.TempDir()
+	cmd = d.ytdlpIn(context.Background(), workDir, "url")
+	home := filepath.Join(workDir, homeDirName)
+	if cmd.Dir != workDir || envValue(cmd.Env, "HOME") != home {
+		t.Errorf("job command Dir = %q, HOME = %q", cmd.Dir, envValue(cmd.Env, "HOME"))
+	}
+	if _, err := os.Stat(home); err != nil {
+		t.Errorf("job HOME not created: %v", err)
+	}
+	n := 0
+	for _, kv := range cmd.Env {
+		if strings.HasPrefix(kv, "HOME=") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("HOME set %d times", n)
+	}
+}
+
+// envValue returns key's value in env, "" if unset.
+func envValue(env []string, key string) string {
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, key+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func TestSkipHome(t *testing.T) {
+	workDir := t.TempDir()
+	video := filepath.Join(workDir, "clip.mp4")
+	if err := os.WriteFile(video, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{homeDirName, "fragments"} {
+		if err := os.Mkdir(filepath.Join(workDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(workDir, "*"))
+	if got := skipHome(files); len(got) != 1 || got[0] != video {
+		t.Errorf("skipHome(%v) = %v, want only %s", files, got, video)
+	}
+}
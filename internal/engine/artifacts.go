@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fitz123/sushe/internal/format"
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// Artifact is a file found in a job's work directory at cleanup.
+type Artifact struct {
+	Path      string // relative to the work directory
+	Size      int64
+	Delivered bool // part of the result: uploaded, and cached by file_id
+	Removed   bool // gone after cleanup
+}
+
+// ArtifactReport lists the files a job produced and what cleanup did with
+// them, to track down files left behind in the download directory.
+type ArtifactReport struct {
+	WorkDir   string
+	Artifacts []Artifact
+}
+
+// Leftovers returns the artifacts cleanup failed to remove.
+func (r ArtifactReport) Leftovers() []Artifact {
+	var left []Artifact
+	for _, a := range r.Artifacts {
+		if !a.Removed {
+			left = append(left, a)
+		}
+	}
+	return left
+}
+
+// String lists the artifacts one per line with size and state, e.g.
+// "video.mp4 12.0 MB delivered, deleted".
+func (r ArtifactReport) String() string {
+	var b strings.Builder
+	var total int64
+	for _, a := range r.Artifacts {
+		role, state := "intermediate", "deleted"
+		if a.Delivered {
+			role = "delivered"
+		}
+		if !a.Removed {
+			state = "LEFT OVER"
+		}
+		fmt.Fprintf(&b, "%s %s %s, %s\n", a.Path, format.Size(a.Size), role, state)
+		total += a.Size
+	}
+	fmt.Fprintf(&b, "%d files, %s in %s", len(r.Artifacts), format.Size(total), r.WorkDir)
+	return b.String()
+}
+
+// CleanupReport is Cleanup that also reports the files it found and whether
+// each is gone afterwards. The report is logged at debug level.
+func (e *Engine) CleanupReport(result *ProcessResult) ArtifactReport {
+	if result == nil || result.WorkDir == "" {
+		return ArtifactReport{}
+	}
+	report := ArtifactReport{WorkDir: result.WorkDir, Artifacts: listArtifacts(result.WorkDir, deliveredPaths(result))}
+	e.Cleanup(result)
+	for i := range report.Artifacts {
+		_, err := os.Lstat(filepath.Join(result.WorkDir, report.Artifacts[i].Path))
+		report.Artifacts[i].Removed = os.IsNotExist(err)
+	}
+	logger.Debug("Job artifacts", "dir", result.WorkDir, "files", len(report.Artifacts),
+		"leftovers", len(report.Leftovers()), "report", report.String())
+	return report
+}
+
+// deliveredPaths returns the files of result that are sent to the user.
+func deliveredPaths(result *ProcessResult) map[string]bool {
+	delivered := make(map[string]bool)
+	add := func(paths ...string) {
+		for _, p := range paths {
+			if p != "" {
+				delivered[filepath.Clean(p)] = true
+			}
+		}
+	}
+	add(result.FilePaths...)
+	add(result.FramePaths...)
+	add(result.SubtitlePaths...)
+	add(result.ThumbnailPath)
+	for _, p := range result.Parts {
+		add(p.ThumbnailPath)
+	}
+	return delivered
+}
+
+// listArtifacts returns the regular files under dir in lexical order.
+func listArtifacts(dir string, delivered map[string]bool) []Artifact {
+	var artifacts []Artifact
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		artifacts = append(artifacts, Artifact{
+			Path:      rel,
+			Size:      info.Size(),
+			Delivered: delivered[filepath.Clean(path)],
+		})
+		return nil
+	})
+	return artifacts
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/fitz123/sushe/internal/downloader"
 	"github.com/fitz123/sushe/internal/logger"
@@ -14,49 +15,157 @@ import (
 // It does NOT upload — it returns local file paths and metadata.
 type Engine struct {
 	downloader *downloader.Downloader
+
+	// compressOvershoot is the max percent over the upload limit at which a
+	// video is compressed into one file instead of split; 0 always splits.
+	compressOvershoot int
+
+	// splitChapters splits oversized videos on chapters unless the user
+	// picked another delivery.
+	splitChapters bool
 }
 
 // NewEngine creates a new Engine with a fresh Downloader instance.
 func NewEngine() *Engine {
 	return &Engine{
-		downloader: downloader.New(),
+		downloader:        downloader.New(),
+		compressOvershoot: downloader.DefaultCompressOvershoot,
 	}
 }
 
 // Process downloads and processes a single video URL.
 // Returns a ProcessResult with file paths and metadata. Caller is responsible for upload and cleanup.
 func (e *Engine) Process(ctx context.Context, url string, progressCb ProgressCallback) (*ProcessResult, error) {
+	return e.ProcessWithOptions(ctx, url, downloader.Options{}, progressCb)
+}
+
+// ProcessWithOptions is Process with a quality cap, audio-only or voice
+// extraction, or an archive (all tracks, MKV) copy. Long audio is split into chapters
+// instead of by size.
+func (e *Engine) ProcessWithOptions(ctx context.Context, url string, opts downloader.Options, progressCb ProgressCallback) (*ProcessResult, error) {
 	dlCb := adaptProgressCb(progressCb)
+	if e.splitChapters && opts.Oversize == "" {
+		opts.SplitChapters = true
+	}
+	var subtitleChoices []string
+	if opts.SubtitleLang == downloader.SubtitleAuto {
+		opts.SubtitleLang, subtitleChoices = e.negotiateSubtitles(ctx, url, opts.SubtitlePrefer)
+	}
 
-	result, err := e.downloader.DownloadWithProgress(ctx, url, dlCb)
+	result, err := e.downloader.DownloadWithOptions(ctx, url, opts, dlCb)
 	if err != nil {
 		return nil, err
 	}
 
 	workDir := filepath.Dir(result.FilePath)
 
+	// Stills need none of the video processing below
+	if len(result.FramePaths) > 0 {
+		return &ProcessResult{
+			FilePath:   result.FilePath,
+			FilePaths:  []string{result.FilePath},
+			FileName:   result.FileName,
+			Title:      result.Title,
+			FileSize:   result.FileSize,
+			FramePaths: result.FramePaths,
+			FrameTimes: result.FrameTimes,
+			WorkDir:    workDir,
+		}, nil
+	}
+
+	// Post media was prepared for an album by the downloader
+	if len(result.Media) > 0 {
+		pr := &ProcessResult{
+			FilePath: result.FilePath,
+			FileName: result.FileName,
+			Title:    result.Title,
+			FileSize: result.FileSize,
+			Media:    result.Media,
+			WorkDir:  workDir,
+		}
+		for _, m := range result.Media {
+			pr.FilePaths = append(pr.FilePaths, m.Path)
+		}
+		return pr, nil
+	}
+
+	if !opts.AudioOnly && !opts.Archive && !opts.Voice {
+		if err := e.compressIfClose(ctx, result, opts.Oversize, dlCb); err != nil {
+			e.downloader.RemoveWorkDir(workDir)
+			return nil, err
+		}
+		if err := e.fitPartLimit(ctx, result, opts.MaxParts, dlCb); err != nil {
+			e.downloader.RemoveWorkDir(workDir)
+			return nil, err
+		}
+	}
+
 	pr := &ProcessResult{
-		FilePath:  result.FilePath,
-		FilePaths: []string{result.FilePath},
-		FileName:  result.FileName,
-		Title:     result.Title,
-		Duration:  result.Duration,
-		Width:     result.Width,
-		Height:    result.Height,
-		FileSize:  result.FileSize,
-		IsSplit:   false,
-		WorkDir:   workDir,
+		FilePath:    result.FilePath,
+		FilePaths:   []string{result.FilePath},
+		FileName:    result.FileName,
+		Title:       result.Title,
+		Duration:    result.Duration,
+		Width:       result.Width,
+		Height:      result.Height,
+		FileSize:    result.FileSize,
+		IsSplit:     false,
+		IsAudio:     opts.AudioOnly,
+		IsArchive:   opts.Archive,
+		IsVoice:     opts.Voice,
+		IsDocument:  opts.Oversize == downloader.OversizeDocument && e.downloader.Limits().NeedsSplit(result.FileSize),
+		IsAnimation: result.IsAnimation,
+		IsVideoNote: result.IsVideoNote,
+		Performer:   result.Performer,
+		WorkDir:     workDir,
+
+		ThumbnailPath: result.ThumbnailPath,
+		StartTime:     result.StartTime,
+		EndTime:       result.EndTime,
+
+		SubtitleChoices: subtitleChoices,
 	}
 
 	// Check if splitting is needed
-	if downloader.NeedsSplit(result.FileSize) {
-		parts, err := e.downloader.SplitVideo(ctx, result.FilePath, dlCb)
+	var parts []downloader.PartInfo
+	switch {
+	case opts.AudioOnly && downloader.NeedsAudioSplit(result.Duration):
+		parts, err = e.downloader.SplitAudio(ctx, result.FilePath, result.Title, dlCb)
+		if err != nil {
+			e.downloader.RemoveWorkDir(workDir)
+			return nil, fmt.Errorf("failed to split audio: %w", err)
+		}
+	case opts.Archive && e.downloader.Limits().NeedsSplit(result.FileSize):
+		parts, err = e.downloader.SplitArchive(ctx, result.FilePath, dlCb)
+		if err != nil {
+			e.downloader.RemoveWorkDir(workDir)
+			return nil, fmt.Errorf("failed to split archive: %w", err)
+		}
+	case opts.Voice:
+		// Voice messages are sent whole; Opus at voice bitrate stays small
+	case !opts.AudioOnly && e.downloader.Limits().NeedsSplit(result.FileSize):
+		if opts.ChapterSplit() {
+			parts, err = e.downloader.SplitByChapters(ctx, result.FilePath, dlCb)
+			if err != nil {
+				if ctx.Err() != nil {
+					e.downloader.RemoveWorkDir(workDir)
+					return nil, err
+				}
+				logger.FromContext(ctx).Info("Can't split by chapters, splitting by size", "file", result.FilePath, "error", err)
+				parts = nil
+			}
+		}
+		if parts == nil {
+			parts, err = e.downloader.SplitVideo(ctx, result.FilePath, dlCb)
+		}
 		if err != nil {
 			// Cleanup on split failure
-			os.RemoveAll(workDir)
+			e.downloader.RemoveWorkDir(workDir)
 			return nil, fmt.Errorf("failed to split video: %w", err)
 		}
+	}
 
+	if len(parts) > 0 {
 		pr.IsSplit = true
 		pr.FilePaths = make([]string, len(parts))
 		pr.Parts = make([]PartResult, len(parts))
@@ -66,25 +175,183 @@ func (e *Engine) Process(ctx context.Context, url string, progressCb ProgressCal
 				FilePath: p.FilePath,
 				PartNum:  p.PartNum,
 				FileSize: p.FileSize,
+				Title:    p.Title,
 			}
 		}
 	}
 
+	if !opts.AudioOnly && !opts.Archive && !opts.Voice && !pr.IsAnimation && !pr.IsDocument {
+		e.attachThumbnails(ctx, pr)
+	}
+
+	// Burned-in subtitles were handled by the downloader
+	if opts.SubtitleLang != "" && !opts.BurnSubtitles && !opts.AudioOnly && !opts.Archive && !opts.Voice && !pr.IsAnimation {
+		subs, err := e.downloader.DownloadSubtitles(ctx, url, workDir, opts.SubtitleLang)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Failed to download subtitles", "url", url, "lang", opts.SubtitleLang, "error", err)
+		}
+		pr.SubtitlePaths = subs
+	}
+
 	return pr, nil
 }
 
+// negotiateSubtitles picks the subtitle language of a SubtitleAuto
+// download: prefer (the requester's language), else the video's original
+// language, else English. With none of those, it returns "" and the
+// languages the video does have, to offer instead.
+func (e *Engine) negotiateSubtitles(ctx context.Context, url, prefer string) (string, []string) {
+	info, err := e.downloader.ProbeInfo(ctx, url)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Failed to probe subtitles, skipping them", "url", url, "error", err)
+		return "", nil
+	}
+	lang := downloader.NegotiateSubtitleLang(info.Subtitles, prefer, info.Language, "en")
+	logger.FromContext(ctx).Debug("Negotiated subtitle language", "url", url, "prefer", prefer, "original", info.Language, "lang", lang)
+	if lang == "" {
+		return "", info.Subtitles
+	}
+	return lang, nil
+}
+
+// attachThumbnails makes sure a video result and each of its parts have a
+// thumbnail, so Telegram doesn't show a grey square. The platform thumbnail
+// from the download is used for the video (or its first part); missing ones
+// are extracted as a frame. Failures only cost the thumbnail.
+func (e *Engine) attachThumbnails(ctx context.Context, pr *ProcessResult) {
+	if !pr.IsSplit {
+		if pr.ThumbnailPath != "" {
+			return
+		}
+		thumb, err := e.downloader.ExtractThumbnail(ctx, pr.FilePath, pr.Duration)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Failed to extract thumbnail", "file", pr.FilePath, "error", err)
+			return
+		}
+		pr.ThumbnailPath = thumb
+		return
+	}
+
+	partDuration := pr.Duration / float64(len(pr.Parts))
+	for i := range pr.Parts {
+		part := &pr.Parts[i]
+		if i == 0 && pr.ThumbnailPath != "" {
+			part.ThumbnailPath = pr.ThumbnailPath
+			continue
+		}
+		thumb, err := e.downloader.ExtractThumbnail(ctx, part.FilePath, partDuration)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Failed to extract thumbnail", "file", part.FilePath, "error", err)
+			continue
+		}
+		part.ThumbnailPath = thumb
+	}
+	if pr.ThumbnailPath == "" && len(pr.Parts) > 0 {
+		pr.ThumbnailPath = pr.Parts[0].ThumbnailPath
+	}
+}
+
+// fitPartLimit compresses a video that would be split into more than
+// maxParts parts to a size that needs at most that many, updating result in
+// place. It returns ErrTooManyParts if the video is too long to stay
+// watchable at that size or compression fails.
+func (e *Engine) fitPartLimit(ctx context.Context, result *downloader.DownloadResult, maxParts int, dlCb downloader.ProgressCallback) error {
+	if !e.downloader.Limits().TooManyParts(result.FileSize, maxParts) {
+		return nil
+	}
+	target := e.downloader.Limits().PartLimitTarget(maxParts)
+	if _, err := downloader.CompressionBitrate(target, result.Duration); err != nil {
+		return fmt.Errorf("%w: %d parts needed, %d allowed (%v)", downloader.ErrTooManyParts,
+			e.downloader.Limits().CalculateNumParts(result.FileSize), maxParts, err)
+	}
+	logger.FromContext(ctx).Info("Compressing to fit the part limit", "file", result.FilePath, "size", result.FileSize, "maxParts", maxParts)
+	compressed, err := e.downloader.CompressToSize(ctx, result.FilePath, target, dlCb)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: compression failed: %v", downloader.ErrTooManyParts, err)
+	}
+	info, err := os.Stat(compressed)
+	if err != nil {
+		return fmt.Errorf("%w: compressed file disappeared: %v", downloader.ErrTooManyParts, err)
+	}
+	os.Remove(result.FilePath)
+	result.FilePath = compressed
+	result.FileName = filepath.Base(compressed)
+	result.FileSize = info.Size()
+	return nil
+}
+
+// compressIfClose re-encodes a video that is only slightly over the upload
+// limit (or any oversized video, if the user asked to compress) into a single
+// file that fits, updating result in place. A failed compression is logged
+// and left to splitting; only cancellation is returned.
+func (e *Engine) compressIfClose(ctx context.Context, result *downloader.DownloadResult, oversize string, dlCb downloader.ProgressCallback) error {
+	switch oversize {
+	case downloader.OversizeCompress:
+		if !e.downloader.Limits().NeedsSplit(result.FileSize) {
+			return nil
+		}
+	case "":
+		if !e.downloader.Limits().ShouldCompress(result.FileSize, e.compressOvershoot) {
+			return nil
+		}
+	default:
+		return nil // The user chose parts
+	}
+	compressed, err := e.downloader.CompressToSize(ctx, result.FilePath, e.downloader.Limits().Compress, dlCb)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		logger.FromContext(ctx).Warn("Compression failed, splitting instead", "file", result.FilePath, "error", err)
+		return nil
+	}
+	info, err := os.Stat(compressed)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Compressed file disappeared, splitting instead", "file", compressed, "error", err)
+		return nil
+	}
+	os.Remove(result.FilePath)
+	result.FilePath = compressed
+	result.FileName = filepath.Base(compressed)
+	result.FileSize = info.Size()
+	return nil
+}
+
+// PlaylistBudget caps how much of a playlist ProcessPlaylistWithin
+// downloads, e.g. what is left of the requester's daily quota. Zero fields
+// have no cap.
+type PlaylistBudget struct {
+	Videos int
+	Bytes  int64
+}
+
 // ProcessPlaylist downloads and processes all videos in a playlist.
 // Returns a slice of ProcessResults. Failed individual videos are logged and skipped.
 func (e *Engine) ProcessPlaylist(ctx context.Context, url string, progressCb func(videoNum, totalVideos int, phase string, percent float64)) ([]*ProcessResult, error) {
+	return e.ProcessPlaylistWithin(ctx, url, PlaylistBudget{}, progressCb)
+}
+
+// ProcessPlaylistWithin is ProcessPlaylist stopping once budget is used up:
+// after budget.Videos videos, or after the video that brings their total
+// size to budget.Bytes.
+func (e *Engine) ProcessPlaylistWithin(ctx context.Context, url string, budget PlaylistBudget, progressCb func(videoNum, totalVideos int, phase string, percent float64)) ([]*ProcessResult, error) {
 	info, err := e.downloader.GetPlaylistInfo(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get playlist info: %w", err)
 	}
 
 	var results []*ProcessResult
+	var totalSize int64
 
 	for i, entry := range info.Entries {
 		videoNum := i + 1
+		if (budget.Videos > 0 && len(results) >= budget.Videos) || (budget.Bytes > 0 && totalSize >= budget.Bytes) {
+			logger.FromContext(ctx).Info("Playlist budget used up", "videos", len(results), "size", totalSize, "skipped", len(info.Entries)-i)
+			break
+		}
 
 		// Per-video progress adapter
 		var dlCb downloader.ProgressCallback
@@ -94,13 +361,20 @@ func (e *Engine) ProcessPlaylist(ctx context.Context, url string, progressCb fun
 			}
 		}
 
-		result, err := e.downloader.DownloadPlaylistVideo(ctx, url, i, dlCb)
+		// Entries skipped for length shift positions; download by source index
+		result, err := e.downloader.DownloadPlaylistVideo(ctx, url, entry.Index-1, dlCb)
 		if err != nil {
-			logger.Error("Failed to download playlist video", "index", i, "title", entry.Title, "error", err)
+			logger.FromContext(ctx).Error("Failed to download playlist video", "index", i, "title", entry.Title, "error", err)
 			continue
 		}
 
 		workDir := filepath.Dir(result.FilePath)
+		if err := e.compressIfClose(ctx, result, "", dlCb); err != nil {
+			logger.FromContext(ctx).Error("Failed to compress playlist video", "index", i, "title", entry.Title, "error", err)
+			e.downloader.RemoveWorkDir(workDir)
+			continue
+		}
+
 		pr := &ProcessResult{
 			FilePath:  result.FilePath,
 			FilePaths: []string{result.FilePath},
@@ -112,14 +386,16 @@ func (e *Engine) ProcessPlaylist(ctx context.Context, url string, progressCb fun
 			FileSize:  result.FileSize,
 			IsSplit:   false,
 			WorkDir:   workDir,
+
+			ThumbnailPath: result.ThumbnailPath,
 		}
 
 		// Check if splitting is needed
-		if downloader.NeedsSplit(result.FileSize) {
+		if e.downloader.Limits().NeedsSplit(result.FileSize) {
 			parts, err := e.downloader.SplitVideo(ctx, result.FilePath, dlCb)
 			if err != nil {
-				logger.Error("Failed to split playlist video", "index", i, "title", entry.Title, "error", err)
-				os.RemoveAll(workDir)
+				logger.FromContext(ctx).Error("Failed to split playlist video", "index", i, "title", entry.Title, "error", err)
+				e.downloader.RemoveWorkDir(workDir)
 				continue
 			}
 
@@ -136,7 +412,9 @@ func (e *Engine) ProcessPlaylist(ctx context.Context, url string, progressCb fun
 			}
 		}
 
+		e.attachThumbnails(ctx, pr)
 		results = append(results, pr)
+		totalSize += pr.FileSize
 	}
 
 	if len(results) == 0 {
@@ -155,10 +433,142 @@ func (e *Engine) IsPlaylist(ctx context.Context, url string) (bool, *downloader.
 	return true, info, nil
 }
 
+// SetPlaylistLimit caps how many videos ProcessPlaylist takes from a playlist.
+func (e *Engine) SetPlaylistLimit(n int) {
+	e.downloader.SetPlaylistLimit(n)
+}
+
+// SetTorrents enables downloading magnet links and .torrent files
+// (SUSHE_TORRENTS).
+func (e *Engine) SetTorrents(enabled bool) {
+	e.downloader.SetTorrents(enabled)
+}
+
+// Torrents reports whether torrent downloads are enabled.
+func (e *Engine) Torrents() bool {
+	return e.downloader.Torrents()
+}
+
+// PlaylistLimit returns the maximum number of videos taken from a playlist.
+func (e *Engine) PlaylistLimit() int {
+	return e.downloader.PlaylistLimit()
+}
+
+// SetUploadLimit sets the Bot API server's per-file upload limit in bytes,
+// which videos are split and compressed to fit. Call it before any
+// download starts.
+func (e *Engine) SetUploadLimit(limit int64) {
+	e.downloader.SetUploadLimit(limit)
+}
+
+// UploadLimits returns the upload sizes videos are split and compressed for.
+func (e *Engine) UploadLimits() downloader.UploadLimits {
+	return e.downloader.Limits()
+}
+
+// SetCompressOvershoot sets how far over the upload limit (in percent) a
+// video may be to get compressed into one file instead of split; 0 disables.
+func (e *Engine) SetCompressOvershoot(percent int) {
+	e.compressOvershoot = percent
+}
+
+// SetCredentials passes a cookies file and a netrc file to yt-dlp; empty
+// paths are ignored. used, if not nil, runs after each yt-dlp call that
+// was given them.
+func (e *Engine) SetCredentials(cookiesFile, netrcFile string, used func()) {
+	e.downloader.SetCredentials(cookiesFile, netrcFile, used)
+}
+
+// SetYtdlpConfig makes yt-dlp load the config file at path and no other.
+func (e *Engine) SetYtdlpConfig(path string) {
+	e.downloader.SetYtdlpConfig(path)
+}
+
+// SetSandbox sets the constraints of the download subprocesses (see
+// downloader.Sandbox).
+func (e *Engine) SetSandbox(s downloader.Sandbox) {
+	e.downloader.SetSandbox(s)
+}
+
+// SetSplitChapters makes oversized videos split on their chapter boundaries
+// (when they have chapters) unless the user picked another delivery.
+func (e *Engine) SetSplitChapters(enabled bool) {
+	e.splitChapters = enabled
+}
+
+// SetBandwidthSchedule limits yt-dlp's download rate by time of day.
+func (e *Engine) SetBandwidthSchedule(s downloader.BandwidthSchedule) {
+	e.downloader.SetBandwidthSchedule(s)
+}
+
+// Probe returns metadata (title, dimensions, expected size) for a URL without downloading it.
+func (e *Engine) Probe(ctx context.Context, url string) (*downloader.VideoInfo, error) {
+	return e.downloader.ProbeInfo(ctx, url)
+}
+
+// ListChannel lists a channel's uploads since the given time (zero for all)
+// without downloading them.
+func (e *Engine) ListChannel(ctx context.Context, url string, since time.Time) ([]downloader.ChannelVideo, error) {
+	return e.downloader.ListChannel(ctx, url, since)
+}
+
+// LatestUploads lists a channel's newest n uploads, newest first.
+func (e *Engine) LatestUploads(ctx context.Context, url string, n int) ([]downloader.ChannelVideo, error) {
+	return e.downloader.LatestUploads(ctx, url, n)
+}
+
+// SearchYouTube returns the top YouTube search result for query, e.g. the
+// title of a page that failed to download, as an alternative source.
+func (e *Engine) SearchYouTube(ctx context.Context, query string) (*downloader.PlaylistEntry, error) {
+	return e.downloader.SearchYouTube(ctx, query)
+}
+
+// MediaInfo probes a local media file with ffprobe.
+func (e *Engine) MediaInfo(filePath string) (*downloader.MediaInfo, error) {
+	return e.downloader.GetMediaInfo(filePath)
+}
+
+// YtdlpVersion returns the installed yt-dlp's version.
+func (e *Engine) YtdlpVersion(ctx context.Context) (string, error) {
+	return e.downloader.YtdlpVersion(ctx)
+}
+
+// BenchmarkProfiles returns the encoder settings /benchmark can try with
+// this ffmpeg build.
+func (e *Engine) BenchmarkProfiles(ctx context.Context) ([]downloader.EncoderProfile, error) {
+	return e.downloader.BenchmarkProfiles(ctx)
+}
+
+// Benchmark encodes a generated test clip with each profile and reports
+// their speed and size (see downloader.Benchmark).
+func (e *Engine) Benchmark(ctx context.Context, profiles []downloader.EncoderProfile, progress func(i int, p downloader.EncoderProfile)) ([]downloader.BenchmarkResult, error) {
+	return e.downloader.Benchmark(ctx, profiles, progress)
+}
+
 // Cleanup removes the work directory for a ProcessResult.
 func (e *Engine) Cleanup(result *ProcessResult) {
 	if result != nil && result.WorkDir != "" {
-		os.RemoveAll(result.WorkDir)
+		e.downloader.RemoveWorkDir(result.WorkDir)
 		logger.Debug("Cleaned up work directory", "dir", result.WorkDir)
 	}
 }
+
+// ProcessSynthetic produces a tiny generated test video instead of
+// downloading a URL (see downloader.SyntheticVideo).
+func (e *Engine) ProcessSynthetic(ctx context.Context, progressCb ProgressCallback) (*ProcessResult, error) {
+	result, err := e.downloader.SyntheticVideo(ctx, adaptProgressCb(progressCb))
+	if err != nil {
+		return nil, err
+	}
+	return &ProcessResult{
+		FilePath:  result.FilePath,
+		FilePaths: []string{result.FilePath},
+		FileName:  result.FileName,
+		Title:     result.Title,
+		Duration:  result.Duration,
+		Width:     result.Width,
+		Height:    result.Height,
+		FileSize:  result.FileSize,
+		WorkDir:   filepath.Dir(result.FilePath),
+	}, nil
+}
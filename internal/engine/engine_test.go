@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -71,6 +72,11 @@ func TestProcessResultSplit(t *testing.T) {
 	assert.Equal(t, 3, pr.Parts[2].PartNum)
 }
 
+func TestPartResultLabel(t *testing.T) {
+	assert.Equal(t, "Part 2/3", PartResult{PartNum: 2}.Label(3))
+	assert.Equal(t, "Part 1/3: Intro – Setup", PartResult{PartNum: 1, Title: "Intro – Setup"}.Label(3))
+}
+
 func TestCleanupRemovesWorkDir(t *testing.T) {
 	// Create a temp directory
 	tmpDir := t.TempDir()
@@ -181,3 +187,46 @@ func TestNewEngine(t *testing.T) {
 	assert.NotNil(t, eng)
 	assert.NotNil(t, eng.downloader)
 }
+
+func TestCleanupReport(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "job")
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, "subs"), 0755))
+	video := filepath.Join(workDir, "video.mp4")
+	require.NoError(t, os.WriteFile(video, []byte("video"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "source.webm"), []byte("source!"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "subs", "video.en.srt"), []byte("1"), 0644))
+
+	eng := &Engine{}
+	report := eng.CleanupReport(&ProcessResult{FilePath: video, FilePaths: []string{video}, WorkDir: workDir})
+
+	require.Len(t, report.Artifacts, 3)
+	assert.Equal(t, Artifact{Path: "source.webm", Size: 7, Removed: true}, report.Artifacts[0])
+	assert.Equal(t, filepath.Join("subs", "video.en.srt"), report.Artifacts[1].Path)
+	assert.Equal(t, Artifact{Path: "video.mp4", Size: 5, Delivered: true, Removed: true}, report.Artifacts[2])
+	assert.Empty(t, report.Leftovers())
+	assert.Contains(t, report.String(), "video.mp4 5 B delivered, deleted")
+
+	_, err := os.Stat(workDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCleanupReportNilResult(t *testing.T) {
+	eng := &Engine{}
+	assert.Empty(t, eng.CleanupReport(nil).Artifacts)
+}
+
+func TestFitPartLimit(t *testing.T) {
+	eng := NewEngine()
+	size := 5 * eng.UploadLimits().Split
+
+	// Under the cap, or no cap: nothing to do
+	result := &downloader.DownloadResult{FilePath: "/nonexistent.mp4", FileSize: size, Duration: 3600}
+	require.NoError(t, eng.fitPartLimit(context.Background(), result, 5, nil))
+	require.NoError(t, eng.fitPartLimit(context.Background(), result, 0, nil))
+
+	// Too long to compress into one part watchably
+	result.Duration = 100 * 3600
+	err := eng.fitPartLimit(context.Background(), result, 1, nil)
+	assert.ErrorIs(t, err, downloader.ErrTooManyParts)
+	assert.Equal(t, size, result.FileSize)
+}
@@ -14,9 +14,21 @@ type ProgressCallback func(phase string, percent float64, detail string)
 
 // PartResult describes a single split video part.
 type PartResult struct {
-	FilePath string
-	PartNum  int
-	FileSize int64
+	FilePath      string
+	PartNum       int
+	FileSize      int64
+	ThumbnailPath string // JPEG frame from this part, "" if extraction failed
+	Title         string // chapter title(s) when split by chapters
+}
+
+// Label returns the part's caption line, e.g. "Part 2/5" or
+// "Part 2/5: Chapter title".
+func (p PartResult) Label(total int) string {
+	label := fmt.Sprintf("Part %d/%d", p.PartNum, total)
+	if p.Title != "" {
+		label += ": " + p.Title
+	}
+	return label
 }
 
 // ProcessResult contains the result of processing a single video URL.
@@ -31,6 +43,21 @@ type ProcessResult struct {
 	Height    int
 	FileSize  int64        // Total size (pre-split original)
 	IsSplit   bool
+	IsAudio   bool         // Audio-only extraction (MP3); parts are chapters
+	IsArchive bool         // Multi-track MKV, uploaded as a document
+	IsVoice   bool         // OGG/Opus for a Telegram voice message
+	IsDocument bool        // Oversized video whose parts are sent as files
+	IsAnimation bool       // Silent MP4 from an animated GIF/WebP or a short silent clip
+	IsVideoNote bool       // Square MP4 up to a minute for a Telegram video note
+	Performer string       // Artist/uploader for audio
+	ThumbnailPath string   // JPEG thumbnail for video uploads, "" if none
+	SubtitlePaths []string // SRT files in the requested language, sent as documents
+	SubtitleChoices []string // Languages the video has subtitles in when none suited a SubtitleAuto request
+	StartTime     float64  // Offset in the source the file starts at (link timestamp, /clip), seconds
+	EndTime       float64  // Offset in the source the file ends at (/clip), 0 if at the end
+	FramePaths    []string  // /frames JPEG stills, delivered instead of the video
+	FrameTimes    []float64 // Offset of each still in seconds
+	Media         []downloader.MediaItem // Photos and videos of a multi-item post, delivered as an album
 	Parts     []PartResult // Populated if IsSplit is true
 	WorkDir   string       // Directory to clean up
 }
@@ -0,0 +1,72 @@
+// Package errsink reports errors worth an operator's attention (handler
+// panics, a site whose extraction keeps failing) to an external error
+// tracker such as Sentry, with a stack trace and the job's context. The
+// sink is created at startup and handed to whatever captures events;
+// without one (nil), captures are dropped.
+package errsink
+
+import (
+	"runtime"
+	"strings"
+)
+
+// Level is the severity of an event.
+type Level string
+
+const (
+	Error Level = "error"
+	Fatal Level = "fatal"
+)
+
+// Frame is one call in a stack trace.
+type Frame struct {
+	Function string // package-qualified, e.g. github.com/fitz123/sushe/internal/bot.(*BotService).runJob
+	File     string
+	Line     int
+}
+
+// Event is one captured error.
+type Event struct {
+	Type    string // kind of error, e.g. "panic" or "extraction"
+	Message string
+	Level   Level             // "" means Error
+	Stack   []Frame           // innermost call first; nil if the error has no useful stack
+	Tags    map[string]string // short indexed values: job, site, phase
+	Extra   map[string]string // longer context: url, tool output
+}
+
+// Sink delivers events to an error tracker. Capture must not block.
+type Sink interface {
+	Capture(e Event)
+}
+
+// Capture sends e to s, defaulting its level to Error. A nil s drops it.
+func Capture(s Sink, e Event) {
+	if s == nil {
+		return
+	}
+	if e.Level == "" {
+		e.Level = Error
+	}
+	s.Capture(e)
+}
+
+// Stack returns the calling goroutine's stack, innermost call first,
+// skipping skip callers above Stack's caller. Called from a deferred
+// recover, the runtime's panic frames are left out, so the trace starts
+// where the panic happened.
+func Stack(skip int) []Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack []Frame
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "runtime.") {
+			stack = append(stack, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			return stack
+		}
+	}
+}
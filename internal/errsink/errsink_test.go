@@ -0,0 +1,153 @@
+package errsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+type recordingSink struct {
+	events []Event
+}
+
+func (r *recordingSink) Capture(e Event) { r.events = append(r.events, e) }
+
+func TestCaptureWithoutSink(t *testing.T) {
+	Capture(nil, Event{Message: "dropped"}) // must not panic
+}
+
+func TestCaptureDefaultsLevel(t *testing.T) {
+	r := &recordingSink{}
+	Capture(r, Event{Type: "panic", Message: "boom"})
+	Capture(r, Event{Message: "worse", Level: Fatal})
+	require.Len(t, r.events, 2)
+	assert.Equal(t, Error, r.events[0].Level)
+	assert.Equal(t, Fatal, r.events[1].Level)
+}
+
+func TestStackFromRecover(t *testing.T) {
+	var stack []Frame
+	func() {
+		defer func() {
+			recover()
+			stack = Stack(0)
+		}()
+		panicHere()
+	}()
+	require.NotEmpty(t, stack)
+	assert.Contains(t, stack[0].Function, "TestStackFromRecover")
+	assert.Contains(t, stack[1].Function, "panicHere")
+	for _, f := range stack {
+		assert.False(t, strings.HasPrefix(f.Function, "runtime."), f.Function)
+	}
+}
+
+func panicHere() { panic("boom") }
+
+func TestNewSentryDSN(t *testing.T) {
+	s, err := NewSentry("https://abc123@o1.ingest.sentry.io/42", "")
+	require.NoError(t, err)
+	defer s.Close(time.Second)
+	assert.Equal(t, "https://o1.ingest.sentry.io/api/42/envelope/", s.endpoint)
+	assert.Equal(t, "abc123", s.key)
+
+	s, err = NewSentry("http://key@sentry.local:9000/prefix/7", "")
+	require.NoError(t, err)
+	defer s.Close(time.Second)
+	assert.Equal(t, "http://sentry.local:9000/prefix/api/7/envelope/", s.endpoint)
+
+	for _, dsn := range []string{"", "https://sentry.io/42", "https://key@sentry.io/", "not a dsn"} {
+		_, err := NewSentry(dsn, "")
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestSentryDelivers(t *testing.T) {
+	var mu sync.Mutex
+	var auth string
+	var lines [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		auth = r.Header.Get("X-Sentry-Auth")
+		lines = bytes.Split(bytes.TrimSpace(body), []byte("\n"))
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "://", "://pubkey@", 1) + "/5"
+	s, err := NewSentry(dsn, "production")
+	require.NoError(t, err)
+	s.Capture(Event{
+		Type:    "panic",
+		Message: "boom",
+		Level:   Fatal,
+		Stack: []Frame{
+			{Function: "github.com/fitz123/sushe/internal/bot.(*BotService).runJob", File: "/src/bot.go", Line: 10},
+			{Function: "github.com/fitz123/sushe/internal/queue.(*Queue).run", File: "/src/queue.go", Line: 20},
+		},
+		Tags:  map[string]string{"job": "j1"},
+		Extra: map[string]string{"url": "https://example.com/v"},
+	})
+	s.Close(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, auth, "sentry_key=pubkey")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"type": "event"}`, string(lines[1]))
+
+	var ev struct {
+		EventID     string            `json:"event_id"`
+		Level       string            `json:"level"`
+		Environment string            `json:"environment"`
+		Tags        map[string]string `json:"tags"`
+		Extra       map[string]string `json:"extra"`
+		Exception   struct {
+			Values []struct {
+				Type       string `json:"type"`
+				Value      string `json:"value"`
+				Stacktrace struct {
+					Frames []struct {
+						Function string `json:"function"`
+						Module   string `json:"module"`
+						Lineno   int    `json:"lineno"`
+						InApp    bool   `json:"in_app"`
+					} `json:"frames"`
+				} `json:"stacktrace"`
+			} `json:"values"`
+		} `json:"exception"`
+	}
+	require.NoError(t, json.Unmarshal(lines[2], &ev))
+	assert.Len(t, ev.EventID, 32)
+	assert.Equal(t, "fatal", ev.Level)
+	assert.Equal(t, "production", ev.Environment)
+	assert.Equal(t, "j1", ev.Tags["job"])
+	assert.Equal(t, "https://example.com/v", ev.Extra["url"])
+	require.Len(t, ev.Exception.Values, 1)
+	exc := ev.Exception.Values[0]
+	assert.Equal(t, "panic", exc.Type)
+	assert.Equal(t, "boom", exc.Value)
+	frames := exc.Stacktrace.Frames
+	require.Len(t, frames, 2)
+	// Outermost call first
+	assert.Equal(t, "github.com/fitz123/sushe/internal/queue", frames[0].Module)
+	assert.Equal(t, 10, frames[1].Lineno)
+	assert.True(t, frames[1].InApp)
+}
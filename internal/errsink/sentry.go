@@ -0,0 +1,234 @@
+package errsink
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+const (
+	sentryTimeout   = 10 * time.Second
+	sentryQueueSize = 64
+	sentryClient    = "sushe/1.0"
+)
+
+// appModule marks this program's frames as in-app in Sentry's trace view.
+const appModule = "github.com/fitz123/sushe/"
+
+// Sentry posts events to a Sentry project (sentry.io or self-hosted, via
+// the envelope endpoint of its DSN). Delivery is asynchronous and
+// best-effort: events are queued and dropped if the queue is full.
+type Sentry struct {
+	endpoint    string
+	key         string
+	dsn         string
+	environment string
+	serverName  string
+	client      *http.Client
+	done        chan struct{}
+
+	mu     sync.RWMutex // Guards closing events against concurrent Capture
+	events chan Event
+	closed bool
+}
+
+// NewSentry starts a sink for dsn ("https://<key>@<host>/<project>"),
+// tagging events with environment if set.
+func NewSentry(dsn, environment string) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	key := u.User.Username()
+	path, project := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		path, project = "/"+project[:i], project[i+1:]
+	}
+	if u.Scheme == "" || u.Host == "" || key == "" || project == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: want https://<key>@<host>/<project>")
+	}
+	serverName, _ := os.Hostname()
+	s := &Sentry{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path, project),
+		key:         key,
+		dsn:         dsn,
+		environment: environment,
+		serverName:  serverName,
+		client:      &http.Client{Timeout: sentryTimeout},
+		events:      make(chan Event, sentryQueueSize),
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Capture queues an event without blocking.
+func (s *Sentry) Capture(e Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- e:
+	default:
+		logger.Warn("Sentry queue full, dropping event", "type", e.Type)
+	}
+}
+
+// Close stops accepting events and waits up to timeout for queued ones to
+// be delivered.
+func (s *Sentry) Close(timeout time.Duration) {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(timeout):
+		logger.Warn("Sentry events still pending at shutdown", "pending", len(s.events))
+	}
+}
+
+// run delivers queued events in order until Close.
+func (s *Sentry) run() {
+	defer close(s.done)
+	for e := range s.events {
+		if err := s.post(e); err != nil {
+			logger.Warn("Failed to send event to Sentry", "type", e.Type, "error", err)
+		}
+	}
+}
+
+// post sends one event as an envelope.
+func (s *Sentry) post(e Event) error {
+	body, err := s.envelope(e, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sentryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", sentryClient, s.key))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry returned %s", resp.Status)
+	}
+	return nil
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type sentryException struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace *struct {
+		Frames []sentryFrame `json:"frames"`
+	} `json:"stacktrace,omitempty"`
+}
+
+type sentryEvent struct {
+	EventID     string `json:"event_id"`
+	Timestamp   string `json:"timestamp"`
+	Platform    string `json:"platform"`
+	Level       Level  `json:"level"`
+	Logger      string `json:"logger"`
+	ServerName  string `json:"server_name,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// envelope encodes e as a Sentry envelope: a header line, an item header
+// line and the event.
+func (s *Sentry) envelope(e Event, now time.Time) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	ev := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   now.Format(time.RFC3339),
+		Platform:    "go",
+		Level:       e.Level,
+		Logger:      "sushe",
+		ServerName:  s.serverName,
+		Environment: s.environment,
+		Tags:        e.Tags,
+		Extra:       e.Extra,
+	}
+	exc := sentryException{Type: e.Type, Value: e.Message}
+	if len(e.Stack) > 0 {
+		exc.Stacktrace = &struct {
+			Frames []sentryFrame `json:"frames"`
+		}{}
+		// Sentry lists the outermost call first
+		for i := len(e.Stack) - 1; i >= 0; i-- {
+			f := e.Stack[i]
+			exc.Stacktrace.Frames = append(exc.Stacktrace.Frames, sentryFrame{
+				Function: f.Function,
+				Module:   frameModule(f.Function),
+				AbsPath:  f.File,
+				Lineno:   f.Line,
+				InApp:    strings.HasPrefix(f.Function, appModule),
+			})
+		}
+	}
+	ev.Exception.Values = []sentryException{exc}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(map[string]string{"event_id": ev.EventID, "dsn": s.dsn, "sent_at": ev.Timestamp}); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(map[string]string{"type": "event"}); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(ev); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// frameModule returns the package path of a frame's function:
+// "github.com/a/b.(*T).M" is in "github.com/a/b".
+func frameModule(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
@@ -0,0 +1,163 @@
+// Package failcache remembers links that recently failed for a reason that
+// won't fix itself (removed, private, region-locked, ...), so a repeat request
+// within the cool-down is answered right away instead of running yt-dlp again.
+package failcache
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/filecache"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+// DefaultCooldown is how long a failure is answered from the cache.
+const DefaultCooldown = time.Hour
+
+// Class is the kind of a cached failure.
+type Class string
+
+const (
+	Removed     Class = "removed"
+	Private     Class = "private"
+	GeoBlocked  Class = "geo"
+	Login       Class = "login"
+	Unsupported Class = "unsupported"
+)
+
+// classPatterns map lowercase yt-dlp error fragments to failure classes.
+// Anything else (network errors, timeouts, throttling) may work on retry
+// and is not cached.
+var classPatterns = []struct {
+	class    Class
+	patterns []string
+}{
+	{Private, []string{"private video", "video is private"}},
+	{GeoBlocked, []string{"not available in your country", "geo restrict", "blocked it in your country"}},
+	{Login, []string{"sign in to confirm your age", "login required", "requires authentication", "members-only", "join this channel"}},
+	{Unsupported, []string{"unsupported url"}},
+	{Removed, []string{"video unavailable", "has been removed", "no longer available", "video is not available", "http error 404", "does not exist"}},
+}
+
+// Classify returns the failure class of a download error message, or "" if
+// the failure may be transient.
+func Classify(message string) Class {
+	message = strings.ToLower(message)
+	for _, c := range classPatterns {
+		for _, p := range c.patterns {
+			if strings.Contains(message, p) {
+				return c.class
+			}
+		}
+	}
+	return ""
+}
+
+// Describe returns a user-facing explanation of a failure class.
+func (c Class) Describe() string {
+	switch c {
+	case Removed:
+		return "This video was removed or is unavailable"
+	case Private:
+		return "This video is private"
+	case GeoBlocked:
+		return "This video is blocked in the bot's region"
+	case Login:
+		return "This video needs a login (age-restricted or members-only)"
+	case Unsupported:
+		return "This site or link isn't supported"
+	}
+	return "This link failed to download"
+}
+
+// Entry is a cached failure.
+type Entry struct {
+	Class   Class     `json:"class"`
+	Checked time.Time `json:"checked"`
+}
+
+// Cache maps canonical URLs to recent failures, persisted as JSON.
+type Cache struct {
+	mu       sync.Mutex
+	entries  map[string]Entry
+	path     string
+	cooldown time.Duration
+}
+
+// New creates a cache backed by path (empty disables persistence) that
+// answers failures for cooldown after they were seen.
+func New(path string, cooldown time.Duration) *Cache {
+	c := &Cache{entries: make(map[string]Entry), path: path, cooldown: cooldown}
+	if path != "" {
+		if err := store.LoadJSON(path, &c.entries); err != nil {
+			logger.Warn("Failed to load failure cache", "error", err)
+		}
+	}
+	return c
+}
+
+// Get returns the failure recorded for rawURL if it is still cooling down.
+func (c *Cache) Get(rawURL string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[filecache.CanonicalURL(rawURL)]
+	if !ok || time.Since(entry.Checked) >= c.cooldown {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// RetryAt returns when a cached failure stops being answered from the cache.
+func (c *Cache) RetryAt(entry Entry) time.Time {
+	return entry.Checked.Add(c.cooldown)
+}
+
+// Record caches a download failure if its class is permanent enough.
+// Returns the class, or "" if nothing was cached.
+func (c *Cache) Record(rawURL string, err error) Class {
+	if err == nil || c.cooldown <= 0 {
+		return ""
+	}
+	class := Classify(err.Error())
+	if class == "" {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[filecache.CanonicalURL(rawURL)] = Entry{Class: class, Checked: time.Now()}
+	c.pruneLocked()
+	c.saveLocked()
+	return class
+}
+
+// Delete forgets rawURL, e.g. after it downloaded successfully.
+func (c *Cache) Delete(rawURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := filecache.CanonicalURL(rawURL)
+	if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.saveLocked()
+	}
+}
+
+// pruneLocked drops entries past their cool-down. Must hold c.mu.
+func (c *Cache) pruneLocked() {
+	for key, entry := range c.entries {
+		if time.Since(entry.Checked) >= c.cooldown {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// saveLocked persists the cache. Must hold c.mu.
+func (c *Cache) saveLocked() {
+	if c.path == "" {
+		return
+	}
+	if err := store.SaveJSON(c.path, c.entries); err != nil {
+		logger.Warn("Failed to save failure cache", "error", err)
+	}
+}
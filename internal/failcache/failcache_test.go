@@ -0,0 +1,72 @@
+package failcache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		message string
+		want    Class
+	}{
+		{"download failed: exit status 1: ERROR: [youtube] abc: Video unavailable. This video has been removed by the uploader", Removed},
+		{"ERROR: [youtube] abc: Private video. Sign in if you've been granted access", Private},
+		{"ERROR: [youtube] abc: The uploader has not made this video available in your country", ""},
+		{"ERROR: [youtube] abc: This video is not available in your country", GeoBlocked},
+		{"ERROR: [youtube] abc: Sign in to confirm your age", Login},
+		{"ERROR: Unsupported URL: https://example.com/", Unsupported},
+		{"download failed: exit status 1: ERROR: unable to download video data: HTTP Error 503", ""},
+		{"context deadline exceeded", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Classify(tt.message), tt.message)
+	}
+}
+
+func TestRecordAndGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failures.json")
+	c := New(path, time.Hour)
+
+	assert.Equal(t, Removed, c.Record("https://youtu.be/abc", errors.New("ERROR: Video unavailable")))
+	assert.Empty(t, c.Record("https://youtu.be/def", errors.New("connection reset by peer")))
+
+	entry, ok := c.Get("https://www.youtube.com/watch?v=abc")
+	require.True(t, ok, "lookups use the canonical URL")
+	assert.Equal(t, Removed, entry.Class)
+	_, ok = c.Get("https://youtu.be/def")
+	assert.False(t, ok, "transient failures are not cached")
+
+	reloaded := New(path, time.Hour)
+	_, ok = reloaded.Get("https://youtu.be/abc")
+	assert.True(t, ok, "entries persist")
+
+	reloaded.Delete("https://youtu.be/abc")
+	_, ok = reloaded.Get("https://youtu.be/abc")
+	assert.False(t, ok)
+}
+
+func TestCooldownExpires(t *testing.T) {
+	c := New("", time.Hour)
+	c.entries["https://youtube.com/watch?v=abc"] = Entry{Class: Private, Checked: time.Now().Add(-2 * time.Hour)}
+
+	_, ok := c.Get("https://youtu.be/abc")
+	assert.False(t, ok)
+}
+
+func TestDisabled(t *testing.T) {
+	c := New("", 0)
+	assert.Empty(t, c.Record("https://youtu.be/abc", errors.New("Video unavailable")))
+}
@@ -0,0 +1,165 @@
+// Package feed watches RSS and Atom feeds (podcasts, YouTube channel feeds,
+// ...) and reports their new items, so their media can go through the normal
+// download pipeline.
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxFeedSize caps how much of a feed document is read.
+const maxFeedSize = 10 << 20
+
+// Item is one entry of a feed.
+type Item struct {
+	ID        string // guid / id, the link if the feed has none
+	Title     string
+	Link      string // what to download: the media enclosure, else the item's page
+	Published time.Time
+}
+
+// rssDoc mirrors the parts of an RSS 2.0 document used here.
+type rssDoc struct {
+	Items []struct {
+		GUID      string `xml:"guid"`
+		Title     string `xml:"title"`
+		Link      string `xml:"link"`
+		PubDate   string `xml:"pubDate"`
+		Enclosure struct {
+			URL  string `xml:"url,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"enclosure"`
+	} `xml:"channel>item"`
+}
+
+// atomDoc mirrors the parts of an Atom document used here.
+type atomDoc struct {
+	Entries []struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Links     []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// Parse reads an RSS 2.0 or Atom document. Items are in document order,
+// which for most feeds is newest first; items without a link are dropped.
+func Parse(r io.Reader) ([]Item, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	var root struct{ XMLName xml.Name }
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+	switch root.XMLName.Local {
+	case "rss":
+		return parseRSS(data)
+	case "feed":
+		return parseAtom(data)
+	}
+	return nil, fmt.Errorf("not an RSS or Atom feed: <%s>", root.XMLName.Local)
+}
+
+func parseRSS(data []byte) ([]Item, error) {
+	var doc rssDoc
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid RSS feed: %w", err)
+	}
+	var items []Item
+	for _, it := range doc.Items {
+		item := Item{ID: strings.TrimSpace(it.GUID), Title: strings.TrimSpace(it.Title), Link: strings.TrimSpace(it.Link)}
+		if isMedia(it.Enclosure.Type) && it.Enclosure.URL != "" {
+			item.Link = strings.TrimSpace(it.Enclosure.URL)
+		}
+		item.Published = parseTime(it.PubDate)
+		if item = withID(item); item.Link != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func parseAtom(data []byte) ([]Item, error) {
+	var doc atomDoc
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid Atom feed: %w", err)
+	}
+	var items []Item
+	for _, e := range doc.Entries {
+		item := Item{ID: strings.TrimSpace(e.ID), Title: strings.TrimSpace(e.Title)}
+		for _, l := range e.Links {
+			switch {
+			case l.Rel == "enclosure" && isMedia(l.Type):
+				item.Link = l.Href
+			case (l.Rel == "" || l.Rel == "alternate") && item.Link == "":
+				item.Link = l.Href
+			}
+		}
+		item.Link = strings.TrimSpace(item.Link)
+		item.Published = parseTime(e.Published)
+		if item.Published.IsZero() {
+			item.Published = parseTime(e.Updated)
+		}
+		if item = withID(item); item.Link != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// isMedia reports whether an enclosure's MIME type is audio or video.
+func isMedia(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "audio/") || strings.HasPrefix(mimeType, "video/")
+}
+
+// withID falls back to the link for items without an ID.
+func withID(item Item) Item {
+	if item.ID == "" {
+		item.ID = item.Link
+	}
+	return item
+}
+
+// parseTime parses the RFC 822 (RSS) and RFC 3339 (Atom) dates feeds use,
+// zero if it can't.
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Fetch downloads and parses the feed at url.
+func Fetch(ctx context.Context, client *http.Client, url string) ([]Item, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch feed: HTTP %d", resp.StatusCode)
+	}
+	return Parse(resp.Body)
+}
@@ -0,0 +1,137 @@
+package feed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+const samplePodcast = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>A Podcast</title>
+    <item>
+      <title>Episode 2</title>
+      <link>https://podcast.example.com/2</link>
+      <guid>ep-2</guid>
+      <pubDate>Tue, 13 Oct 2026 08:00:00 +0000</pubDate>
+      <enclosure url="https://cdn.example.com/ep2.mp3" type="audio/mpeg" length="1"/>
+    </item>
+    <item>
+      <title>Show notes only</title>
+      <link>https://podcast.example.com/notes</link>
+      <enclosure url="https://cdn.example.com/notes.pdf" type="application/pdf"/>
+    </item>
+    <item>
+      <title>No link</title>
+    </item>
+  </channel>
+</rss>`
+
+const sampleYouTube = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+  <title>A Channel</title>
+  <link rel="alternate" href="https://www.youtube.com/channel/UC1"/>
+  <entry>
+    <id>yt:video:abc</id>
+    <yt:videoId>abc</yt:videoId>
+    <title>New video</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=abc"/>
+    <published>2026-10-14T10:00:00+00:00</published>
+  </entry>
+</feed>`
+
+func TestParse(t *testing.T) {
+	items, err := Parse(strings.NewReader(samplePodcast))
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "ep-2", items[0].ID)
+	assert.Equal(t, "Episode 2", items[0].Title)
+	assert.Equal(t, "https://cdn.example.com/ep2.mp3", items[0].Link, "media enclosure wins over the page")
+	assert.Equal(t, time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC), items[0].Published.UTC())
+	// Not a media enclosure: the page link, which is also the ID
+	assert.Equal(t, "https://podcast.example.com/notes", items[1].Link)
+	assert.Equal(t, "https://podcast.example.com/notes", items[1].ID)
+
+	items, err = Parse(strings.NewReader(sampleYouTube))
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "yt:video:abc", items[0].ID)
+	assert.Equal(t, "https://www.youtube.com/watch?v=abc", items[0].Link)
+	assert.Equal(t, time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC), items[0].Published.UTC())
+
+	_, err = Parse(strings.NewReader(`<html><body>not a feed</body></html>`))
+	assert.Error(t, err)
+	_, err = Parse(strings.NewReader(`not xml`))
+	assert.Error(t, err)
+}
+
+func TestParseURLs(t *testing.T) {
+	assert.Equal(t, []string{"https://a/rss", "https://b/atom"}, ParseURLs(" https://a/rss, https://b/atom "))
+	assert.Empty(t, ParseURLs(""))
+}
+
+func TestNewDisabled(t *testing.T) {
+	var w *Watcher = New(Config{})
+	assert.Nil(t, w)
+	// A nil watcher returns right away
+	w.Run(make(chan struct{}), func(string, Item) { t.Fatal("unexpected item") })
+}
+
+func TestFresh(t *testing.T) {
+	w := New(Config{URLs: []string{"https://a/rss"}})
+	first := []Item{{ID: "2", Link: "l2"}, {ID: "1", Link: "l1"}}
+	assert.Empty(t, w.fresh("https://a/rss", first), "first poll only records")
+
+	items := append([]Item{{ID: "4", Link: "l4"}, {ID: "3", Link: "l3"}}, first...)
+	assert.Equal(t, []Item{{ID: "3", Link: "l3"}, {ID: "4", Link: "l4"}}, w.fresh("https://a/rss", items))
+	assert.Empty(t, w.fresh("https://a/rss", items))
+}
+
+func TestRunPersistsSeen(t *testing.T) {
+	var mu sync.Mutex
+	doc := sampleYouTube
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		rw.Write([]byte(doc))
+	}))
+	defer srv.Close()
+
+	state := filepath.Join(t.TempDir(), "feeds.json")
+	cfg := Config{URLs: []string{srv.URL}, Interval: time.Hour, StatePath: state}
+	var got []Item
+	New(cfg).poll(func(_ string, item Item) { got = append(got, item) })
+	assert.Empty(t, got)
+
+	mu.Lock()
+	doc = strings.Replace(sampleYouTube, "<entry>", `<entry>
+    <id>yt:video:def</id>
+    <title>Newer video</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=def"/>
+  </entry>
+  <entry>`, 1)
+	mu.Unlock()
+
+	// A restarted watcher remembers what it has seen
+	New(cfg).poll(func(feedURL string, item Item) {
+		assert.Equal(t, srv.URL, feedURL)
+		got = append(got, item)
+	})
+	require.Len(t, got, 1)
+	assert.Equal(t, "https://www.youtube.com/watch?v=def", got[0].Link)
+}
@@ -0,0 +1,147 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+const (
+	// DefaultInterval is how often feeds are polled when Config.Interval is unset.
+	DefaultInterval = 30 * time.Minute
+
+	// maxSeen is how many item IDs per feed are remembered; older ones have
+	// long dropped out of the feed.
+	maxSeen = 500
+
+	fetchTimeout = time.Minute
+)
+
+// Config selects the feeds to watch. No URLs disables the watcher.
+type Config struct {
+	URLs      []string
+	Interval  time.Duration // 0 means DefaultInterval
+	StatePath string        // Where seen items are kept; empty keeps them in memory
+}
+
+// Watcher polls feeds and hands their new items to a callback. A nil
+// *Watcher does nothing, so callers don't need to check whether feeds are on.
+type Watcher struct {
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex
+	seen map[string][]string // feed URL → IDs of items already handled, oldest first
+}
+
+// New creates a watcher for cfg, or returns nil if cfg has no URLs.
+func New(cfg Config) *Watcher {
+	if len(cfg.URLs) == 0 {
+		return nil
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	w := &Watcher{
+		cfg:    cfg,
+		client: &http.Client{Timeout: fetchTimeout},
+		seen:   make(map[string][]string),
+	}
+	if cfg.StatePath != "" {
+		if err := store.LoadJSON(cfg.StatePath, &w.seen); err != nil {
+			logger.Warn("Failed to load feed state", "error", err)
+		}
+	}
+	return w
+}
+
+// ParseURLs splits a comma or whitespace separated list of feed URLs.
+func ParseURLs(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' })
+}
+
+// Run polls the feeds right away and then every interval until stop is
+// closed, calling handle for each new item, oldest first. Items already in
+// a feed the first time it is polled are not new.
+func (w *Watcher) Run(stop <-chan struct{}, handle func(feedURL string, item Item)) {
+	if w == nil {
+		return
+	}
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		w.poll(handle)
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// poll checks every feed once.
+func (w *Watcher) poll(handle func(feedURL string, item Item)) {
+	for _, url := range w.cfg.URLs {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		items, err := Fetch(ctx, w.client, url)
+		cancel()
+		if err != nil {
+			logger.Warn("Failed to poll feed", "feed", url, "error", err)
+			continue
+		}
+		for _, item := range w.fresh(url, items) {
+			logger.Info("New feed item", "feed", url, "title", item.Title, "link", item.Link)
+			handle(url, item)
+		}
+	}
+}
+
+// fresh records the items of feedURL and returns those not seen before,
+// oldest first. On the first poll of a feed it only records them.
+func (w *Watcher) fresh(feedURL string, items []Item) []Item {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seenIDs, known := w.seen[feedURL]
+	seen := make(map[string]bool, len(seenIDs))
+	for _, id := range seenIDs {
+		seen[id] = true
+	}
+
+	var fresh []Item
+	// Feeds list newest first; go oldest first
+	for i := len(items) - 1; i >= 0; i-- {
+		if seen[items[i].ID] {
+			continue
+		}
+		seen[items[i].ID] = true
+		seenIDs = append(seenIDs, items[i].ID)
+		fresh = append(fresh, items[i])
+	}
+	if len(fresh) == 0 && known {
+		return nil
+	}
+	if len(seenIDs) > maxSeen {
+		seenIDs = seenIDs[len(seenIDs)-maxSeen:]
+	}
+	w.seen[feedURL] = seenIDs
+	w.saveLocked()
+	if !known {
+		return nil
+	}
+	return fresh
+}
+
+// saveLocked persists the seen items. Must hold w.mu.
+func (w *Watcher) saveLocked() {
+	if w.cfg.StatePath == "" {
+		return
+	}
+	if err := store.SaveJSON(w.cfg.StatePath, w.seen); err != nil {
+		logger.Warn("Failed to save feed state", "error", err)
+	}
+}
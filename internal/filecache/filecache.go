@@ -0,0 +1,170 @@
+// Package filecache remembers the Telegram file_ids of uploaded results, so a
+// URL that was already downloaded can be answered by re-sending the files
+// instead of running yt-dlp again.
+package filecache
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+// DefaultMaxEntries bounds the cache; the oldest entries are evicted first.
+const DefaultMaxEntries = 5000
+
+// File is one uploaded Telegram file.
+type File struct {
+	Kind    string `json:"kind"` // "video", "audio", "voice", "animation", "videonote" or "document"
+	FileID  string `json:"file_id"`
+	Caption string `json:"caption,omitempty"`
+	NSFW    bool   `json:"nsfw,omitempty"` // flagged by the thumbnail classifier, sent as a spoiler in groups
+}
+
+// Entry is the set of files sent for one request, in upload order (split
+// parts and chapters have several).
+type Entry struct {
+	Files   []File    `json:"files"`
+	Created time.Time `json:"created"`
+}
+
+// Cache maps request keys to uploaded files, persisted as JSON.
+type Cache struct {
+	mu         sync.Mutex
+	entries    map[string]Entry
+	path       string
+	maxEntries int
+}
+
+// New creates a cache backed by path (empty disables persistence) and loads
+// any existing entries.
+func New(path string, maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	c := &Cache{entries: make(map[string]Entry), path: path, maxEntries: maxEntries}
+	if path != "" {
+		if err := store.LoadJSON(path, &c.entries); err != nil {
+			logger.Warn("Failed to load file cache", "error", err)
+		}
+	}
+	return c
+}
+
+// Key identifies a request: the canonical URL plus the output mode, since
+// the same URL yields different files as video, audio or voice.
+func Key(rawURL, mode string) string {
+	if mode == "" {
+		return CanonicalURL(rawURL)
+	}
+	return CanonicalURL(rawURL) + "#" + mode
+}
+
+// Get returns the cached files for key.
+func (c *Cache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+// Put stores the files uploaded for key.
+func (c *Cache) Put(key string, files []File) {
+	if len(files) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = Entry{Files: files, Created: time.Now()}
+	c.evictLocked()
+	c.saveLocked()
+}
+
+// Delete removes key, e.g. after Telegram rejected a stale file_id.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.saveLocked()
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evictLocked drops the oldest entries beyond maxEntries. Must hold c.mu.
+func (c *Cache) evictLocked() {
+	if len(c.entries) <= c.maxEntries {
+		return
+	}
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].Created.Before(c.entries[keys[j]].Created)
+	})
+	for _, k := range keys[:len(keys)-c.maxEntries] {
+		delete(c.entries, k)
+	}
+}
+
+// saveLocked persists the cache. Must hold c.mu.
+func (c *Cache) saveLocked() {
+	if c.path == "" {
+		return
+	}
+	if err := store.SaveJSON(c.path, c.entries); err != nil {
+		logger.Warn("Failed to save file cache", "error", err)
+	}
+}
+
+// trackingParams are query parameters that don't change what a URL points to.
+var trackingParams = map[string]bool{
+	"si": true, "feature": true, "fbclid": true, "gclid": true, "igshid": true,
+	"ref": true, "ref_src": true, "s": true, "t": true,
+}
+
+// CanonicalURL normalizes a URL so trivially different links to the same
+// video share a cache entry: lowercase host without "www."/"m.", youtu.be and
+// shorts links rewritten to watch?v=, tracking parameters and fragments removed.
+func CanonicalURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	host = strings.TrimPrefix(host, "m.")
+	u.Scheme = "https"
+	u.Host = host
+	u.Fragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	q := u.Query()
+	switch {
+	case host == "youtu.be" && len(u.Path) > 1:
+		q.Set("v", strings.TrimPrefix(u.Path, "/"))
+		u.Host, u.Path = "youtube.com", "/watch"
+	case host == "youtube.com" && strings.HasPrefix(u.Path, "/shorts/"):
+		q.Set("v", strings.TrimPrefix(u.Path, "/shorts/"))
+		u.Path = "/watch"
+	}
+	for key := range q {
+		if trackingParams[key] || strings.HasPrefix(key, "utm_") {
+			q.Del(key)
+		}
+	}
+	u.RawQuery = q.Encode() // Encode sorts keys
+	return u.String()
+}
@@ -0,0 +1,61 @@
+package filecache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://youtu.be/abc123?si=xyz", "https://youtube.com/watch?v=abc123"},
+		{"https://www.youtube.com/watch?v=abc123&feature=share", "https://youtube.com/watch?v=abc123"},
+		{"https://m.youtube.com/watch?v=abc123", "https://youtube.com/watch?v=abc123"},
+		{"https://youtube.com/shorts/abc123", "https://youtube.com/watch?v=abc123"},
+		{"http://Example.com/video/?utm_source=x&id=5#top", "https://example.com/video?id=5"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CanonicalURL(tt.in), tt.in)
+	}
+}
+
+func TestKeySeparatesModes(t *testing.T) {
+	assert.Equal(t, Key("https://youtu.be/a", ""), Key("https://www.youtube.com/watch?v=a", ""))
+	assert.NotEqual(t, Key("https://youtu.be/a", ""), Key("https://youtu.be/a", "audio"))
+}
+
+func TestCachePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filecache.json")
+	c := New(path, 0)
+	c.Put("k", []File{{Kind: "video", FileID: "f1"}, {Kind: "video", FileID: "f2"}})
+	c.Put("empty", nil)
+
+	reloaded := New(path, 0)
+	entry, ok := reloaded.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, []string{"f1", "f2"}, []string{entry.Files[0].FileID, entry.Files[1].FileID})
+	_, ok = reloaded.Get("empty")
+	assert.False(t, ok)
+
+	reloaded.Delete("k")
+	assert.Equal(t, 0, New(path, 0).Len())
+}
+
+func TestCacheEvictsOldest(t *testing.T) {
+	c := New("", 2)
+	c.Put("a", []File{{FileID: "1"}})
+	time.Sleep(time.Millisecond)
+	c.Put("b", []File{{FileID: "2"}})
+	time.Sleep(time.Millisecond)
+	c.Put("c", []File{{FileID: "3"}})
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
@@ -0,0 +1,212 @@
+// Package format renders sizes, durations, speeds and percentages for
+// user-facing messages, so every message rounds and abbreviates the same way.
+// Functions format in English; Locale methods format for a specific locale.
+package format
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Locale holds the language-specific parts of formatting.
+type Locale struct {
+	Tag     string
+	Decimal string    // Decimal separator
+	Units   [7]string // B, KB, MB, GB, TB, PB, EB
+	Hour    string
+	Minute  string
+	Second  string
+	PerSec  string // Suffix for rates, e.g. "/s"
+}
+
+var (
+	// English is the default locale.
+	English = Locale{
+		Tag:     "en",
+		Decimal: ".",
+		Units:   [7]string{"B", "KB", "MB", "GB", "TB", "PB", "EB"},
+		Hour:    "h",
+		Minute:  "m",
+		Second:  "s",
+		PerSec:  "/s",
+	}
+	// Russian uses a decimal comma and Cyrillic unit abbreviations.
+	Russian = Locale{
+		Tag:     "ru",
+		Decimal: ",",
+		Units:   [7]string{"Б", "КБ", "МБ", "ГБ", "ТБ", "ПБ", "ЭБ"},
+		Hour:    "ч",
+		Minute:  "м",
+		Second:  "с",
+		PerSec:  "/с",
+	}
+)
+
+var locales = map[string]Locale{
+	English.Tag: English,
+	Russian.Tag: Russian,
+}
+
+// Lookup returns the locale for a language tag such as "ru" or "ru-RU".
+func Lookup(tag string) (Locale, bool) {
+	base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+	l, ok := locales[base]
+	return l, ok
+}
+
+// Size formats a byte count with binary units, e.g. "1.5 GB".
+func Size(bytes int64) string { return English.Size(bytes) }
+
+// Speed formats a transfer rate in bytes per second, e.g. "2.3 MB/s".
+func Speed(bytesPerSec float64) string { return English.Speed(bytesPerSec) }
+
+// Duration formats an exact duration to the second, e.g. "1h05m", "3m07s".
+func Duration(d time.Duration) string { return English.Duration(d) }
+
+// Wait formats an estimate to the minute, e.g. "<1m", "12m", "2h05m".
+func Wait(d time.Duration) string { return English.Wait(d) }
+
+// Percent formats progress as a whole percentage, e.g. "45%".
+func Percent(p float64) string { return English.Percent(p) }
+
+// Size formats a byte count with binary units and one decimal.
+func (l Locale) Size(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d %s", bytes, l.Units[0])
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return l.decimal(float64(bytes)/float64(div)) + " " + l.Units[exp+1]
+}
+
+// Speed formats a transfer rate in bytes per second.
+func (l Locale) Speed(bytesPerSec float64) string {
+	return l.Size(int64(bytesPerSec)) + l.PerSec
+}
+
+// Duration formats d rounded to the second. Hours show minutes, minutes
+// show seconds; smaller units are dropped.
+func (l Locale) Duration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%d%s%02d%s", h, l.Hour, m, l.Minute)
+	case m > 0:
+		return fmt.Sprintf("%d%s%02d%s", m, l.Minute, s, l.Second)
+	}
+	return fmt.Sprintf("%d%s", s, l.Second)
+}
+
+// Wait formats an estimate rounded to the minute; anything under a minute
+// is "<1m".
+func (l Locale) Wait(d time.Duration) string {
+	if d < time.Minute {
+		return "<1" + l.Minute
+	}
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%d%s", int(d.Minutes()), l.Minute)
+	}
+	return fmt.Sprintf("%d%s%02d%s", int(d.Hours()), l.Hour, int(d.Minutes())%60, l.Minute)
+}
+
+// Clock formats a position in a video as "1:05" or "1:02:03", the same in
+// every locale.
+func Clock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	s := int(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// Chunks splits text into messages of at most limit UTF-16 code units
+// (how Telegram measures message length), breaking between lines where it
+// can and inside a line only when the line alone is too long.
+func Chunks(text string, limit int) []string {
+	var chunks []string
+	var cur strings.Builder
+	size := 0
+	flush := func() {
+		if chunk := strings.TrimSpace(cur.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		cur.Reset()
+		size = 0
+	}
+	for _, line := range strings.Split(text, "\n") {
+		n := utf16Len(line)
+		if size > 0 && size+1+n > limit {
+			flush()
+		}
+		for n > limit {
+			// Cut an overlong line at the limit, on a rune boundary
+			cut, units := 0, 0
+			for i, r := range line {
+				if units+utf16RuneLen(r) > limit {
+					cut = i
+					break
+				}
+				units += utf16RuneLen(r)
+			}
+			if cut == 0 {
+				_, cut = utf8.DecodeRuneInString(line)
+			}
+			cur.WriteString(line[:cut])
+			flush()
+			line = line[cut:]
+			n = utf16Len(line)
+		}
+		if size > 0 {
+			cur.WriteByte('\n')
+			size++
+		}
+		cur.WriteString(line)
+		size += n
+	}
+	flush()
+	return chunks
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// Percent formats progress rounded down, so 100% only shows when done.
+func (l Locale) Percent(p float64) string {
+	return fmt.Sprintf("%d%%", int(math.Floor(math.Max(0, math.Min(p, 100)))))
+}
+
+// decimal formats v with one decimal and the locale's separator.
+func (l Locale) decimal(v float64) string {
+	s := fmt.Sprintf("%.1f", v)
+	if l.Decimal != "." {
+		s = strings.Replace(s, ".", l.Decimal, 1)
+	}
+	return s
+}
@@ -0,0 +1,68 @@
+package format
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSize(t *testing.T) {
+	assert.Equal(t, "512 B", English.Size(512))
+	assert.Equal(t, "1.0 KB", English.Size(1024))
+	assert.Equal(t, "1.5 MB", English.Size(1536*1024))
+	assert.Equal(t, "1.9 GB", English.Size(1900*1024*1024))
+	assert.Equal(t, "1,5 МБ", Russian.Size(1536*1024))
+}
+
+func TestSpeed(t *testing.T) {
+	assert.Equal(t, "2.5 MB/s", English.Speed(2.5*1024*1024))
+	assert.Equal(t, "2,5 МБ/с", Russian.Speed(2.5*1024*1024))
+}
+
+func TestDuration(t *testing.T) {
+	assert.Equal(t, "0s", English.Duration(0))
+	assert.Equal(t, "45s", English.Duration(44600*time.Millisecond))
+	assert.Equal(t, "3m07s", English.Duration(3*time.Minute+7*time.Second))
+	assert.Equal(t, "1h05m", English.Duration(time.Hour+5*time.Minute+30*time.Second))
+	assert.Equal(t, "1ч05м", Russian.Duration(time.Hour+5*time.Minute))
+}
+
+func TestClock(t *testing.T) {
+	assert.Equal(t, "0:00", Clock(0))
+	assert.Equal(t, "1:05", Clock(65*time.Second+900*time.Millisecond))
+	assert.Equal(t, "1:02:03", Clock(time.Hour+2*time.Minute+3*time.Second))
+}
+
+func TestWait(t *testing.T) {
+	assert.Equal(t, "<1m", English.Wait(30*time.Second))
+	assert.Equal(t, "12m", English.Wait(12*time.Minute+10*time.Second))
+	assert.Equal(t, "2h05m", English.Wait(2*time.Hour+5*time.Minute))
+	assert.Equal(t, "<1м", Russian.Wait(0))
+}
+
+func TestPercent(t *testing.T) {
+	assert.Equal(t, "0%", English.Percent(-5))
+	assert.Equal(t, "45%", English.Percent(45.9))
+	assert.Equal(t, "99%", English.Percent(99.9))
+	assert.Equal(t, "100%", English.Percent(100))
+	assert.Equal(t, "100%", English.Percent(130))
+}
+
+func TestChunks(t *testing.T) {
+	assert.Equal(t, []string{"short"}, Chunks("short", 10))
+	assert.Equal(t, []string{"one\ntwo", "three"}, Chunks("one\ntwo\nthree", 8))
+	assert.Equal(t, []string{"abcd", "efgh", "ij"}, Chunks("abcdefghij", 4))
+	// Emoji count as two units
+	assert.Equal(t, []string{"😀a", "😀"}, Chunks("😀a😀", 3))
+	assert.Empty(t, Chunks("\n\n", 10))
+}
+
+func TestLookup(t *testing.T) {
+	l, ok := Lookup("ru-RU")
+	assert.True(t, ok)
+	assert.Equal(t, Russian, l)
+
+	_, ok = Lookup("xx")
+	assert.False(t, ok)
+}
@@ -0,0 +1,178 @@
+// Package health serves liveness and readiness probes for container
+// orchestration: /healthz answers while the process is up, /readyz runs
+// checks of what downloads need.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/downloader"
+	"github.com/fitz123/sushe/internal/format"
+	"github.com/fitz123/sushe/internal/logger"
+	tele "gopkg.in/telebot.v3"
+)
+
+// checkTimeout bounds each readiness check.
+const checkTimeout = 5 * time.Second
+
+// Check is one readiness check. Run returns nil when all is well.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of a check in the /readyz response.
+type Result struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the JSON body of /healthz and /readyz.
+type Report struct {
+	Status string   `json:"status"` // "ok" or "unavailable"
+	Uptime string   `json:"uptime"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Handler serves /healthz, which only says the process is up so a Telegram
+// outage doesn't get the bot restarted, and /readyz, which runs checks
+// concurrently and answers 503 if any fails.
+func Handler(checks ...Check) http.Handler {
+	started := time.Now()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: "ok", Uptime: uptime(started)})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := Report{Status: "ok", Uptime: uptime(started), Checks: Run(r.Context(), checks)}
+		code := http.StatusOK
+		for _, c := range report.Checks {
+			if !c.OK {
+				report.Status = "unavailable"
+				code = http.StatusServiceUnavailable
+			}
+		}
+		writeReport(w, code, report)
+	})
+	return mux
+}
+
+// Run runs checks concurrently, each with a timeout, returning the results
+// in the order of checks.
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			results[i] = Result{Name: c.Name, OK: true}
+			if err := c.Run(ctx); err != nil {
+				results[i] = Result{Name: c.Name, Error: err.Error()}
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
+
+func uptime(started time.Time) string {
+	return time.Since(started).Truncate(time.Second).String()
+}
+
+func writeReport(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// errGetMe and errUnreachable are what /readyz reports for failed Bot and
+// Reachable checks. The endpoint is unauthenticated and the underlying
+// errors carry request URLs, which include the bot token, so those are
+// only logged.
+var (
+	errGetMe       = errors.New("getMe failed")
+	errUnreachable = errors.New("connection failed")
+)
+
+// Bot checks that the bot can talk to Telegram: getMe through its Bot API
+// server.
+func Bot(bot *tele.Bot) Check {
+	return Check{Name: "telegram", Run: func(ctx context.Context) error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := bot.Raw("getMe", nil)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				logger.Warn("Readiness check getMe failed", "error", err)
+				return errGetMe
+			}
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("getMe: %w", ctx.Err())
+		}
+	}}
+}
+
+// Reachable checks that the Bot API server at apiURL accepts connections.
+func Reachable(apiURL string) Check {
+	return Check{Name: "bot_api " + apiURL, Run: func(ctx context.Context) error {
+		u, err := url.Parse(apiURL)
+		if err != nil {
+			return fmt.Errorf("invalid URL: %w", err)
+		}
+		host := u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			host = net.JoinHostPort(u.Hostname(), port)
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", host)
+		if err != nil {
+			logger.Warn("Readiness check couldn't reach Bot API server", "error", err)
+			return errUnreachable
+		}
+		return conn.Close()
+	}}
+}
+
+// DiskSpace checks that dir has at least min bytes free.
+func DiskSpace(dir string, min int64) Check {
+	return Check{Name: "disk " + dir, Run: func(ctx context.Context) error {
+		free, err := downloader.FreeDiskSpace(dir)
+		if err != nil {
+			return err
+		}
+		if free < min {
+			return fmt.Errorf("%s free, need %s", format.Size(free), format.Size(min))
+		}
+		return nil
+	}}
+}
+
+// Command checks that the executable name is on PATH.
+func Command(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error {
+		_, err := exec.LookPath(name)
+		return err
+	}}
+}
@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tele "gopkg.in/telebot.v3"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func ok(name string) Check {
+	return Check{Name: name, Run: func(context.Context) error { return nil }}
+}
+
+func failing(name string) Check {
+	return Check{Name: name, Run: func(context.Context) error { return errors.New("broken") }}
+}
+
+func get(t *testing.T, h http.Handler, path string) (int, Report) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var report Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestReadyz(t *testing.T) {
+	code, report := get(t, Handler(ok("a"), ok("b")), "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", report.Status)
+	assert.Equal(t, []Result{{Name: "a", OK: true}, {Name: "b", OK: true}}, report.Checks)
+
+	code, report = get(t, Handler(ok("a"), failing("b")), "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, Result{Name: "b", Error: "broken"}, report.Checks[1])
+}
+
+func TestHealthzIgnoresChecks(t *testing.T) {
+	code, report := get(t, Handler(failing("telegram")), "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", report.Status)
+	assert.Empty(t, report.Checks)
+}
+
+func TestRunTimesOut(t *testing.T) {
+	slow := Check{Name: "slow", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := Run(ctx, []Check{slow})
+	assert.False(t, results[0].OK)
+}
+
+func TestReachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	assert.NoError(t, Reachable(srv.URL).Run(context.Background()))
+	srv.Close()
+	assert.Error(t, Reachable(srv.URL).Run(context.Background()))
+}
+
+func TestBot(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"sushe"}}`))
+	}))
+	defer srv.Close()
+	b, err := tele.NewBot(tele.Settings{Token: "1:x", URL: srv.URL, Offline: true})
+	require.NoError(t, err)
+	assert.NoError(t, Bot(b).Run(context.Background()))
+
+	// The token in the failed request's URL stays out of the report
+	srv.Close()
+	b, err = tele.NewBot(tele.Settings{Token: "1:secret", URL: srv.URL, Offline: true})
+	require.NoError(t, err)
+	result := Run(context.Background(), []Check{Bot(b)})[0]
+	assert.False(t, result.OK)
+	assert.NotContains(t, result.Error, "secret")
+}
+
+func TestDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, DiskSpace(dir, 1).Run(context.Background()))
+	assert.Error(t, DiskSpace(dir, 1<<62).Run(context.Background()))
+	assert.Error(t, DiskSpace(dir+"/missing", 1).Run(context.Background()))
+}
+
+func TestCommand(t *testing.T) {
+	assert.NoError(t, Command("sh").Run(context.Background()))
+	assert.Error(t, Command("no-such-tool-sushe").Run(context.Background()))
+}
@@ -0,0 +1,315 @@
+// Package history keeps each user's delivered downloads with the Telegram
+// files they were sent as, so /find can search them by title and uploader
+// and send them again. Persisted as an append-only JSON Lines log.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/fitz123/sushe/internal/filecache"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+// DefaultMaxPerUser bounds each user's history; the oldest entries go first.
+const DefaultMaxPerUser = 1000
+
+// Entry is one delivered download.
+type Entry struct {
+	ID       string           `json:"id"` // the job's ID
+	URL      string           `json:"url"`
+	Title    string           `json:"title"`
+	Uploader string           `json:"uploader,omitempty"`
+	Files    []filecache.File `json:"files"`
+	Time     time.Time        `json:"time"`
+}
+
+// record is one line of the log: an entry added for a user.
+type record struct {
+	UserID int64 `json:"user"`
+	Entry  Entry `json:"entry"`
+}
+
+// indexed is an entry with the words of its title and uploader, sorted.
+type indexed struct {
+	Entry
+	words []string
+}
+
+// History maps user IDs to their entries, oldest first.
+type History struct {
+	mu         sync.Mutex
+	path       string
+	users      map[int64][]indexed
+	maxPerUser int
+	logLines   int // records in the log, replaced and trimmed ones included
+}
+
+// New creates a history backed by the log at path (empty disables
+// persistence) and loads any existing entries.
+func New(path string, maxPerUser int) *History {
+	if maxPerUser <= 0 {
+		maxPerUser = DefaultMaxPerUser
+	}
+	h := &History{path: path, users: make(map[int64][]indexed), maxPerUser: maxPerUser}
+	if path == "" {
+		return h
+	}
+	if err := h.load(); err != nil {
+		logger.Warn("Failed to load download history", "error", err)
+	}
+	return h
+}
+
+// Add records a delivered download for userID. An entry with the same ID
+// (a job delivered again, e.g. after a restart) is replaced. Only the new
+// entry is written: it is appended to the log, which is compacted once
+// replaced and trimmed entries make up most of it.
+func (h *History) Add(userID int64, e Entry) {
+	if len(e.Files) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.addLocked(userID, e)
+	h.appendLocked(record{UserID: userID, Entry: e})
+}
+
+func (h *History) addLocked(userID int64, e Entry) {
+	entries := h.users[userID]
+	for i, old := range entries {
+		if old.ID == e.ID {
+			entries = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+	entries = append(entries, indexed{Entry: e, words: words(e.Title + " " + e.Uploader)})
+	if len(entries) > h.maxPerUser {
+		entries = entries[len(entries)-h.maxPerUser:]
+	}
+	h.users[userID] = entries
+}
+
+// Get returns userID's entry with the given ID.
+func (h *History) Get(userID int64, id string) (Entry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.users[userID] {
+		if e.ID == id {
+			return e.Entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Search returns up to limit of userID's entries, newest first, whose title
+// or uploader has a word starting with each word of query ("cat vid"
+// matches "Funny cats video"). Case and accents don't matter.
+func (h *History) Search(userID int64, query string, limit int) []Entry {
+	terms := words(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := h.users[userID]
+	var found []Entry
+	for i := len(entries) - 1; i >= 0 && len(found) < limit; i-- {
+		if matches(entries[i].words, terms) {
+			found = append(found, entries[i].Entry)
+		}
+	}
+	return found
+}
+
+// Len returns the number of userID's entries.
+func (h *History) Len(userID int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.users[userID])
+}
+
+// UserCount is how many downloads one user had delivered.
+type UserCount struct {
+	UserID    int64
+	Downloads int
+}
+
+// Top returns the limit users with the most downloads delivered since the
+// given time, most first.
+func (h *History) Top(since time.Time, limit int) []UserCount {
+	h.mu.Lock()
+	var counts []UserCount
+	for userID, entries := range h.users {
+		n := 0
+		for _, e := range entries {
+			if !e.Time.Before(since) {
+				n++
+			}
+		}
+		if n > 0 {
+			counts = append(counts, UserCount{UserID: userID, Downloads: n})
+		}
+	}
+	h.mu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Downloads != counts[j].Downloads {
+			return counts[i].Downloads > counts[j].Downloads
+		}
+		return counts[i].UserID < counts[j].UserID
+	})
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts
+}
+
+// matches reports whether every term is a prefix of one of the words.
+func matches(words, terms []string) bool {
+	for _, term := range terms {
+		i := sort.SearchStrings(words, term)
+		if i == len(words) || !strings.HasPrefix(words[i], term) {
+			return false
+		}
+	}
+	return true
+}
+
+// words splits text into sorted, lowercased words of letters and digits.
+func words(text string) []string {
+	fields := strings.FieldsFunc(fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(fields)
+	return fields
+}
+
+// accents maps accented letters (and Russian ё) to their base letter.
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c", "ё", "е",
+)
+
+// fold lowercases text and strips accents.
+func fold(text string) string {
+	return accents.Replace(strings.ToLower(text))
+}
+
+// load replays the log. A line that can't be read (the last one, cut
+// short by a crash) is skipped, and the log rewritten without it so the
+// next record doesn't end up on the same line.
+func (h *History) load() error {
+	f, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", h.path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	skipped := 0
+	for line := 1; scanner.Scan(); line++ {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			logger.Warn("Skipping unreadable download history record", "line", line, "error", err)
+			skipped++
+			continue
+		}
+		h.addLocked(r.UserID, r.Entry)
+		h.logLines++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", h.path, err)
+	}
+	if skipped > 0 {
+		return h.compactLocked()
+	}
+	return nil
+}
+
+// appendLocked writes r at the end of the log, compacting it first when
+// it is more than twice the entries kept. Must hold h.mu.
+func (h *History) appendLocked(r record) {
+	if h.path == "" {
+		return
+	}
+	kept := 0
+	for _, entries := range h.users {
+		kept += len(entries)
+	}
+	if h.logLines >= 2*kept && h.logLines >= h.maxPerUser {
+		if err := h.compactLocked(); err != nil {
+			logger.Warn("Failed to compact download history", "error", err)
+		}
+		return // the compacted log holds r
+	}
+
+	line, err := json.Marshal(r)
+	if err == nil {
+		err = appendLine(h.path, line)
+	}
+	if err != nil {
+		logger.Warn("Failed to save download history", "error", err)
+		return
+	}
+	h.logLines++
+}
+
+// compactLocked rewrites the log with only the entries kept, each user's
+// oldest first. Must hold h.mu.
+func (h *History) compactLocked() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	lines := 0
+	for userID, entries := range h.users {
+		for _, e := range entries {
+			if err := enc.Encode(record{UserID: userID, Entry: e.Entry}); err != nil {
+				return fmt.Errorf("failed to encode history entry: %w", err)
+			}
+			lines++
+		}
+	}
+	if err := store.WriteFileAtomic(h.path, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	h.logLines = lines
+	return nil
+}
+
+// appendLine appends line and a newline to the file at path in one write.
+func appendLine(path string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
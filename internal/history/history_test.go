@@ -0,0 +1,137 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/filecache"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func entry(id, title, uploader string) Entry {
+	return Entry{ID: id, Title: title, Uploader: uploader, Files: []filecache.File{{Kind: "video", FileID: "f-" + id}}}
+}
+
+func ids(entries []Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	h := New("", 0)
+	h.Add(1, entry("a", "Funny cats compilation", "PetChannel"))
+	h.Add(1, entry("b", "Café tour of Paris", "Travel Vlogs"))
+	h.Add(1, entry("c", "Cats vs dogs", ""))
+	h.Add(2, entry("d", "Cats of another user", ""))
+
+	assert.Equal(t, []string{"c", "a"}, ids(h.Search(1, "cat", 10)), "newest first, prefix match")
+	assert.Equal(t, []string{"a"}, ids(h.Search(1, "CATS funny", 10)), "every word must match")
+	assert.Equal(t, []string{"b"}, ids(h.Search(1, "cafe", 10)), "accents ignored")
+	assert.Equal(t, []string{"b"}, ids(h.Search(1, "vlog", 10)), "uploader searched")
+	assert.Equal(t, []string{"c"}, ids(h.Search(1, "cats", 1)), "limit")
+	assert.Empty(t, h.Search(1, "birds", 10))
+	assert.Empty(t, h.Search(1, "  ,, ", 10))
+	assert.Equal(t, []string{"d"}, ids(h.Search(2, "cats", 10)), "per user")
+}
+
+func TestAddReplacesAndTrims(t *testing.T) {
+	h := New("", 3)
+	for i := 0; i < 5; i++ {
+		h.Add(1, entry(fmt.Sprint(i), fmt.Sprintf("video %d", i), ""))
+	}
+	h.Add(1, entry("3", "video three again", ""))
+	h.Add(1, Entry{ID: "nofiles", Title: "video"})
+
+	assert.Equal(t, 3, h.Len(1))
+	assert.Equal(t, []string{"3", "4", "2"}, ids(h.Search(1, "video", 10)))
+	_, ok := h.Get(1, "0")
+	assert.False(t, ok, "oldest trimmed")
+	e, ok := h.Get(1, "3")
+	require.True(t, ok)
+	assert.Equal(t, "video three again", e.Title)
+}
+
+func TestTop(t *testing.T) {
+	h := New("", 0)
+	old := entry("old", "old video", "")
+	old.Time = time.Now().Add(-48 * time.Hour)
+	h.Add(1, old)
+	h.Add(1, entry("a", "video", ""))
+	h.Add(2, entry("b", "video", ""))
+	h.Add(2, entry("c", "video", ""))
+	h.Add(3, old)
+
+	since := time.Now().Add(-24 * time.Hour)
+	assert.Equal(t, []UserCount{{UserID: 2, Downloads: 2}, {UserID: 1, Downloads: 1}}, h.Top(since, 10))
+	assert.Equal(t, []UserCount{{UserID: 2, Downloads: 2}}, h.Top(since, 1))
+}
+
+func TestPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := New(path, 0)
+	e := entry("a", "Saved video", "")
+	e.Time = time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	h.Add(7, e)
+
+	reloaded := New(path, 0)
+	got, ok := reloaded.Get(7, "a")
+	require.True(t, ok)
+	assert.Equal(t, e, got)
+}
+
+func lines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Count(string(data), "\n")
+}
+
+func TestAppendsAndCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := New(path, 3)
+	h.Add(1, entry("a", "first", ""))
+	h.Add(2, entry("b", "second", ""))
+	h.Add(1, entry("a", "first again", ""))
+	assert.Equal(t, 3, lines(t, path), "each Add appends one record")
+
+	for i := 0; i < 5; i++ {
+		h.Add(1, entry(fmt.Sprint(i), "video", ""))
+	}
+	assert.LessOrEqual(t, lines(t, path), 2*4, "replaced and trimmed entries are dropped")
+
+	reloaded := New(path, 3)
+	assert.Equal(t, []string{"4", "3", "2"}, ids(reloaded.Search(1, "video", 10)))
+	assert.Equal(t, 1, reloaded.Len(2))
+}
+
+func TestSkipsTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	New(path, 0).Add(1, entry("a", "kept", ""))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"user":1,"entry":{"id":"b","ti`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	h := New(path, 0)
+	h.Add(1, entry("c", "after crash", ""))
+
+	reloaded := New(path, 0)
+	assert.Equal(t, 2, reloaded.Len(1), "the cut record is dropped, the next one kept")
+	_, ok := reloaded.Get(1, "c")
+	assert.True(t, ok)
+}
@@ -0,0 +1,125 @@
+// Package library files downloaded videos into a media library directory
+// (e.g. a NAS share), routing episodes of web series into show and season
+// folders named the way Plex, Jellyfin and Kodi expect.
+package library
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Episode is what a title says about a series episode.
+type Episode struct {
+	Show    string
+	Season  int
+	Episode int
+	Title   string // episode title, "" if the title has none
+}
+
+// episodePatterns match the season and episode numbers in a title:
+// "S01E02", "1x02" and "Season 1 Episode 2" (case-insensitive).
+var episodePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bS(\d{1,2})[ ._-]?E(\d{1,3})\b`),
+	regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`),
+	regexp.MustCompile(`(?i)\bSeason[ ._-]*(\d{1,2})[ ._,-]*(?:Episode|Ep\.?)[ ._-]*(\d{1,3})\b`),
+}
+
+var (
+	// unsafeChars can't appear in file names on common NAS file systems.
+	unsafeChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+	spaces      = regexp.MustCompile(`\s+`)
+)
+
+// ParseEpisode finds a season/episode marker in title and splits the title
+// around it, e.g. "My Show S01E02 - Pilot" or "My.Show.1x02.Pilot". Returns
+// false for titles without a marker or without a show name before it.
+func ParseEpisode(title string) (Episode, bool) {
+	for _, re := range episodePatterns {
+		m := re.FindStringSubmatchIndex(title)
+		if m == nil {
+			continue
+		}
+		season, _ := strconv.Atoi(title[m[2]:m[3]])
+		episode, _ := strconv.Atoi(title[m[4]:m[5]])
+		show := cleanName(title[:m[0]])
+		if show == "" || episode == 0 {
+			return Episode{}, false
+		}
+		return Episode{Show: show, Season: season, Episode: episode, Title: cleanName(title[m[1]:])}, true
+	}
+	return Episode{}, false
+}
+
+// cleanName turns a title fragment into a file name: dots and underscores
+// used as separators become spaces, unsafe characters are dropped and
+// leading/trailing separators trimmed.
+func cleanName(s string) string {
+	if !strings.Contains(s, " ") {
+		s = strings.NewReplacer(".", " ", "_", " ").Replace(s)
+	}
+	s = unsafeChars.ReplaceAllString(s, " ")
+	s = spaces.ReplaceAllString(s, " ")
+	return strings.Trim(s, " -_.|,")
+}
+
+// Path returns where a video titled title with extension ext (".mp4") goes
+// under root: "Show/Season 01/Show - S01E02 - Title.mp4" for episodes,
+// "Title.mp4" otherwise. part numbers the files of a split video (" - pt2",
+// which media servers stack into one item); 0 for a whole video.
+func Path(root, title, ext string, part int) string {
+	suffix := ext
+	if part > 0 {
+		suffix = fmt.Sprintf(" - pt%d%s", part, ext)
+	}
+	ep, ok := ParseEpisode(title)
+	if !ok {
+		name := cleanName(title)
+		if name == "" {
+			name = "Video"
+		}
+		return filepath.Join(root, name+suffix)
+	}
+	name := fmt.Sprintf("%s - S%02dE%02d", ep.Show, ep.Season, ep.Episode)
+	if ep.Title != "" {
+		name += " - " + ep.Title
+	}
+	return filepath.Join(root, ep.Show, fmt.Sprintf("Season %02d", ep.Season), name+suffix)
+}
+
+// Store copies src to dest, creating its directories, hard-linking when
+// both are on the same file system. An existing dest is replaced.
+func Store(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create library directory: %w", err)
+	}
+	os.Remove(dest)
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dest + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create library file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to copy into library: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
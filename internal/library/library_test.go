@@ -0,0 +1,61 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEpisode(t *testing.T) {
+	tests := []struct {
+		title string
+		want  Episode
+	}{
+		{"My Show S01E02 - Pilot", Episode{Show: "My Show", Season: 1, Episode: 2, Title: "Pilot"}},
+		{"My.Show.s2e10.The.Return", Episode{Show: "My Show", Season: 2, Episode: 10, Title: "The Return"}},
+		{"Web Series | 3x04 | Finale", Episode{Show: "Web Series", Season: 3, Episode: 4, Title: "Finale"}},
+		{"Cooking Club - Season 1, Episode 7", Episode{Show: "Cooking Club", Season: 1, Episode: 7}},
+		{"Show: S01E01: Who/What?", Episode{Show: "Show", Season: 1, Episode: 1, Title: "Who What"}},
+	}
+	for _, tt := range tests {
+		got, ok := ParseEpisode(tt.title)
+		require.True(t, ok, tt.title)
+		assert.Equal(t, tt.want, got, tt.title)
+	}
+
+	for _, title := range []string{"Just a video", "S01E02 without a show", "Top 10 of 2024", "1920x1080 test"} {
+		_, ok := ParseEpisode(title)
+		assert.False(t, ok, title)
+	}
+}
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("/nas", "My Show", "Season 01", "My Show - S01E02 - Pilot.mp4"),
+		Path("/nas", "My Show S01E02 - Pilot", ".mp4", 0))
+	assert.Equal(t, filepath.Join("/nas", "My Show", "Season 01", "My Show - S01E02 - pt2.mp4"),
+		Path("/nas", "My Show S01E02", ".mp4", 2))
+	assert.Equal(t, filepath.Join("/nas", "Cat video.mp4"), Path("/nas", "Cat video", ".mp4", 0))
+	assert.Equal(t, filepath.Join("/nas", "Video.mp4"), Path("/nas", "???", ".mp4", 0))
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mp4")
+	require.NoError(t, os.WriteFile(src, []byte("video"), 0644))
+
+	dest := filepath.Join(dir, "lib", "Show", "Season 01", "Show - S01E01.mp4")
+	require.NoError(t, Store(src, dest))
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	// Replaces an existing file
+	require.NoError(t, os.WriteFile(src+"2", []byte("newer"), 0644))
+	require.NoError(t, Store(src+"2", dest))
+	data, err = os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "newer", string(data))
+}
@@ -1,11 +1,16 @@
 package logger
 
 import (
+	"context"
+	"io"
 	"log/slog"
 	"os"
 )
 
-var log *slog.Logger
+var (
+	log      *slog.Logger
+	minLevel slog.Level
+)
 
 func Init(level string) {
 	var logLevel slog.Level
@@ -22,11 +27,48 @@ func Init(level string) {
 		logLevel = slog.LevelInfo
 	}
 
+	minLevel = logLevel
 	log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logLevel,
 	}))
 }
 
+// TeeTo also writes every log line to w (e.g. a RotatingFile), at the
+// level set by Init.
+func TeeTo(w io.Writer) {
+	log = slog.New(slog.NewTextHandler(io.MultiWriter(os.Stdout, w), &slog.HandlerOptions{
+		Level: minLevel,
+	}))
+}
+
+// With returns a child logger adding args to each of its lines, e.g. the
+// job ID that ties together a download's lines from queue to upload.
+func With(args ...any) *slog.Logger {
+	return log.With(args...)
+}
+
+type ctxKey struct{}
+
+// NewContext returns ctx carrying l, for FromContext further down the call
+// chain (the engine and downloader log with the job's logger this way).
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the package logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return log
+}
+
+// DebugEnabled reports whether debug messages are logged, for callers that
+// would otherwise do extra work only to log it.
+func DebugEnabled() bool {
+	return log.Enabled(context.Background(), slog.LevelDebug)
+}
+
 func Debug(msg string, args ...any) {
 	log.Debug(msg, args...)
 }
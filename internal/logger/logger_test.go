@@ -0,0 +1,25 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log
+	log = slog.New(slog.NewTextHandler(&buf, nil))
+	defer func() { log = prev }()
+
+	FromContext(context.Background()).Info("plain")
+	assert.NotContains(t, buf.String(), "job=")
+
+	buf.Reset()
+	ctx := NewContext(context.Background(), With("job", "abc123"))
+	FromContext(ctx).Info("downloading", "url", "https://example.com")
+	assert.Contains(t, buf.String(), "msg=downloading job=abc123 url=https://example.com")
+}
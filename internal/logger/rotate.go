@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat suffixes rotated log files; it sorts chronologically.
+const backupTimeFormat = "20060102-150405.000"
+
+// RotatingFile is an io.Writer appending to a log file. When a write would
+// take the file past MaxSize, or the file was started more than MaxAge
+// ago, it is renamed with a timestamp suffix (sushe.log.20261015-140501.000)
+// and a new one started. Only the newest MaxBackups rotated files are kept.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+// OpenRotatingFile opens (or creates) the log file at path. A zero
+// maxSize, maxAge or maxBackups means no limit.
+func OpenRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	r := &RotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the log file for appending. An existing file counts as
+// started when the newest backup was rotated out (its mtime), else now.
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file, r.size, r.started = f, info.Size(), time.Now()
+	if backups := r.backups(); info.Size() > 0 && len(backups) > 0 {
+		if b, err := os.Stat(backups[len(backups)-1]); err == nil {
+			r.started = b.ModTime()
+		}
+	}
+	return nil
+}
+
+// Write appends p, rotating the file first if it is due.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.due(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file must be rotated before writing n bytes.
+// An empty file is never rotated, so a line longer than maxSize still goes out.
+func (r *RotatingFile) due(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	return (r.maxSize > 0 && r.size+n > r.maxSize) || (r.maxAge > 0 && time.Since(r.started) >= r.maxAge)
+}
+
+// rotate renames the current file to a backup, opens a new one and drops
+// the backups over the limit.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	r.file = nil
+	backup := r.path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.started = time.Now()
+
+	if backups := r.backups(); r.maxBackups > 0 && len(backups) > r.maxBackups {
+		for _, old := range backups[:len(backups)-r.maxBackups] {
+			os.Remove(old)
+		}
+	}
+	return nil
+}
+
+// backups returns the rotated files, oldest first.
+func (r *RotatingFile) backups() []string {
+	paths, _ := filepath.Glob(r.path + ".*")
+	sort.Strings(paths)
+	return paths
+}
+
+// Close closes the log file; later writes fail.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "sushe.log")
+	r, err := OpenRotatingFile(path, 20, 0, 2)
+	require.NoError(t, err)
+	defer r.Close()
+
+	line := []byte("0123456789abcde\n") // 16 bytes: one line per file
+	for i := 0; i < 4; i++ {
+		_, err := r.Write(line)
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond) // distinct backup names
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(line), string(data))
+	assert.Len(t, r.backups(), 2, "older backups are dropped")
+}
+
+func TestRotatingFileByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sushe.log")
+	r, err := OpenRotatingFile(path, 0, time.Hour, 0)
+	require.NoError(t, err)
+	defer r.Close()
+
+	r.Write([]byte("old\n"))
+	r.started = time.Now().Add(-2 * time.Hour)
+	r.Write([]byte("new\n"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(data))
+	backups := r.backups()
+	require.Len(t, backups, 1)
+	old, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "old\n", string(old))
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sushe.log")
+	require.NoError(t, os.WriteFile(path, []byte("before restart\n"), 0644))
+	r, err := OpenRotatingFile(path, 0, 0, 0)
+	require.NoError(t, err)
+	r.Write([]byte("after\n"))
+	require.NoError(t, r.Close())
+
+	_, err = r.Write([]byte("closed\n"))
+	assert.Error(t, err)
+	data, _ := os.ReadFile(path)
+	assert.True(t, strings.HasPrefix(string(data), "before restart\nafter\n"))
+}
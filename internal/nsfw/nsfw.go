@@ -0,0 +1,94 @@
+// Package nsfw scores video thumbnails for adult content with a classifier
+// the operator provides: an HTTP endpoint (a hosted API, or a local model
+// served on localhost) or a local command.
+package nsfw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// maxResponseSize bounds a classifier's answer.
+const maxResponseSize = 64 * 1024
+
+// Classifier scores an image from 0 (safe) to 1 (explicit).
+type Classifier interface {
+	Score(ctx context.Context, imagePath string) (float64, error)
+}
+
+// HTTP posts the image as the request body (image/jpeg) to URL, with
+// Token as a bearer token if set, and reads a JSON answer with the score
+// in "score" or "nsfw", e.g. {"score": 0.93}.
+type HTTP struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (h HTTP) Score(ctx context.Context, imagePath string) (float64, error) {
+	image, err := os.ReadFile(imagePath)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(image))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "image/jpeg")
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("classifier returned %s", resp.Status)
+	}
+
+	var answer struct {
+		Score *float64 `json:"score"`
+		NSFW  *float64 `json:"nsfw"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&answer); err != nil {
+		return 0, fmt.Errorf("invalid classifier response: %w", err)
+	}
+	switch {
+	case answer.Score != nil:
+		return *answer.Score, nil
+	case answer.NSFW != nil:
+		return *answer.NSFW, nil
+	}
+	return 0, fmt.Errorf("classifier response has no score")
+}
+
+// Command runs Path with the image path as its only argument; it prints
+// the score on stdout.
+type Command struct {
+	Path string
+}
+
+func (c Command) Score(ctx context.Context, imagePath string) (float64, error) {
+	out, err := exec.CommandContext(ctx, c.Path, imagePath).Output()
+	if err != nil {
+		return 0, fmt.Errorf("classifier failed: %w", err)
+	}
+	score, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid classifier output: %w", err)
+	}
+	return score, nil
+}
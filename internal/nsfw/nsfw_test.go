@@ -0,0 +1,70 @@
+package nsfw
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func thumbnail(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "thumb.jpg")
+	require.NoError(t, os.WriteFile(path, []byte("jpeg"), 0644))
+	return path
+}
+
+func TestHTTPScore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "jpeg", string(body))
+		assert.Equal(t, "image/jpeg", r.Header.Get("Content-Type"))
+		switch r.Header.Get("Authorization") {
+		case "Bearer k":
+			w.Write([]byte(`{"score":0.93}`))
+		case "":
+			w.Write([]byte(`{"nsfw":0.1,"sfw":0.9}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	image := thumbnail(t)
+
+	score, err := HTTP{URL: srv.URL, Token: "k"}.Score(context.Background(), image)
+	require.NoError(t, err)
+	assert.Equal(t, 0.93, score)
+
+	score, err = HTTP{URL: srv.URL}.Score(context.Background(), image)
+	require.NoError(t, err)
+	assert.Equal(t, 0.1, score)
+
+	_, err = HTTP{URL: srv.URL, Token: "wrong"}.Score(context.Background(), image)
+	assert.Error(t, err)
+}
+
+func TestHTTPScoreMissing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"label":"safe"}`))
+	}))
+	defer srv.Close()
+	_, err := HTTP{URL: srv.URL}.Score(context.Background(), thumbnail(t))
+	assert.Error(t, err)
+}
+
+func TestCommandScore(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "classify")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\ntest -f \"$1\" && echo 0.85\n"), 0755))
+
+	score, err := Command{Path: script}.Score(context.Background(), thumbnail(t))
+	require.NoError(t, err)
+	assert.Equal(t, 0.85, score)
+
+	_, err = Command{Path: script}.Score(context.Background(), "/missing.jpg")
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+// Package progress fans the progress of running jobs out to any number of
+// listeners, such as the web dashboard's event stream.
+package progress
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a listener may fall behind before
+// events are dropped for it.
+const subscriberBuffer = 64
+
+// Event is a job's progress as shown in its Telegram status message, or,
+// with Result set, how it ended.
+type Event struct {
+	JobID   string    `json:"job_id"`
+	URL     string    `json:"url"`
+	ChatID  int64     `json:"chat_id"`
+	User    string    `json:"user,omitempty"`
+	Phase   string    `json:"phase,omitempty"`   // "downloading", "encoding", ...
+	Percent float64   `json:"percent,omitempty"` // of the current phase
+	Status  string    `json:"status,omitempty"`  // status message text
+	Result  string    `json:"result,omitempty"`  // "completed", "failed" or "cancelled" once done
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Done reports whether e is a job's last event.
+func (e Event) Done() bool {
+	return e.Result != ""
+}
+
+// Bus passes published events on to every subscriber. A subscriber that
+// doesn't keep up misses events rather than slowing down the jobs.
+type Bus struct {
+	mu      sync.Mutex
+	subs    map[chan Event]struct{}
+	running map[string]Event // latest event of each running job
+}
+
+// NewBus creates an empty bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{}), running: make(map[string]Event)}
+}
+
+// Publish sends e to all subscribers. A nil bus drops it.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e.Done() {
+		delete(b.running, e.JobID)
+	} else {
+		b.running[e.JobID] = e
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of events published from now on and a
+// function that unsubscribes and closes it.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Running returns the latest event of each job still running, oldest
+// first, so a new listener can show them before the next update.
+func (b *Bus) Running() []Event {
+	b.mu.Lock()
+	events := make([]Event, 0, len(b.running))
+	for _, e := range b.running {
+		events = append(events, e)
+	}
+	b.mu.Unlock()
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
@@ -0,0 +1,59 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	b := NewBus()
+	events, unsubscribe := b.Subscribe()
+
+	b.Publish(Event{JobID: "a", Phase: "downloading", Percent: 40})
+	e := <-events
+	assert.Equal(t, "a", e.JobID)
+	assert.Equal(t, 40.0, e.Percent)
+	assert.False(t, e.Time.IsZero(), "publish stamps the time")
+
+	unsubscribe()
+	unsubscribe()
+	_, open := <-events
+	assert.False(t, open)
+	b.Publish(Event{JobID: "a"}) // no subscriber left, nothing blocks
+}
+
+func TestSlowSubscriberDropsEvents(t *testing.T) {
+	b := NewBus()
+	events, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+	for i := 0; i < subscriberBuffer*2; i++ {
+		b.Publish(Event{JobID: "a", Percent: float64(i)})
+	}
+	assert.Len(t, events, subscriberBuffer)
+}
+
+func TestRunning(t *testing.T) {
+	b := NewBus()
+	start := time.Now()
+	b.Publish(Event{JobID: "b", Phase: "downloading", Time: start.Add(time.Second)})
+	b.Publish(Event{JobID: "a", Phase: "downloading", Time: start})
+	b.Publish(Event{JobID: "a", Phase: "encoding", Time: start.Add(2 * time.Second)})
+
+	running := b.Running()
+	require.Len(t, running, 2)
+	assert.Equal(t, "b", running[0].JobID)
+	assert.Equal(t, "encoding", running[1].Phase, "only the latest event per job")
+
+	b.Publish(Event{JobID: "b", Result: "completed"})
+	running = b.Running()
+	require.Len(t, running, 1)
+	assert.Equal(t, "a", running[0].JobID)
+}
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Publish(Event{JobID: "a"})
+}
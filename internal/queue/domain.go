@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// domainAliases maps short-link hosts to the site they belong to, so both
+// share one concurrency limit.
+var domainAliases = map[string]string{
+	"youtu.be":      "youtube.com",
+	"vm.tiktok.com": "tiktok.com",
+	"x.com":         "twitter.com",
+}
+
+// limitKey returns the configured domain in limits that rawURL falls under
+// (the host itself or a parent domain), or "" if none applies.
+func limitKey(rawURL string, limits map[string]int) string {
+	if len(limits) == 0 {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	if alias, ok := domainAliases[host]; ok {
+		host = alias
+	}
+	for host != "" {
+		if _, ok := limits[host]; ok {
+			return host
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return ""
+}
+
+// ParseDomainLimits parses a comma-separated "domain=limit" list such as
+// "youtube.com=1,vimeo.com=2" into Config.DomainLimits.
+func ParseDomainLimits(raw string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		domain, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid domain limit %q: want domain=limit", item)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("invalid limit for %s: %q", domain, value)
+		}
+		limits[strings.ToLower(strings.TrimSpace(domain))] = limit
+	}
+	return limits, nil
+}
@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitKey(t *testing.T) {
+	limits := map[string]int{"youtube.com": 1, "vimeo.com": 2}
+
+	assert.Equal(t, "youtube.com", limitKey("https://www.youtube.com/watch?v=x", limits))
+	assert.Equal(t, "youtube.com", limitKey("https://m.youtube.com/watch?v=x", limits))
+	assert.Equal(t, "youtube.com", limitKey("https://youtu.be/x", limits))
+	assert.Equal(t, "vimeo.com", limitKey("https://vimeo.com/123", limits))
+	assert.Equal(t, "", limitKey("https://example.com/v.mp4", limits))
+	assert.Equal(t, "", limitKey("https://notyoutube.com/x", limits))
+	assert.Equal(t, "", limitKey("https://www.youtube.com/watch?v=x", nil))
+}
+
+func TestParseDomainLimits(t *testing.T) {
+	limits, err := ParseDomainLimits(" YouTube.com=1, vimeo.com = 2 ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"youtube.com": 1, "vimeo.com": 2}, limits)
+
+	_, err = ParseDomainLimits("youtube.com")
+	assert.Error(t, err)
+	_, err = ParseDomainLimits("youtube.com=0")
+	assert.Error(t, err)
+}
+
+func TestDomainLimitSkipsToOtherDomains(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 3)
+
+	q := New(Config{Workers: 2, DomainLimits: map[string]int{"youtube.com": 1}},
+		func(ctx context.Context, job *Job) error {
+			started <- job.ID
+			if job.ID == "yt1" {
+				<-release
+			}
+			return nil
+		})
+	q.Submit(&Job{ID: "yt1", URL: "https://www.youtube.com/watch?v=1"})
+	q.Submit(&Job{ID: "yt2", URL: "https://youtu.be/2"})
+	q.Submit(&Job{ID: "other", URL: "https://vimeo.com/3"})
+	q.Start()
+	defer q.Stop()
+
+	// yt2 must wait for yt1, so the second worker picks up the vimeo job
+	first, second := <-started, <-started
+	assert.ElementsMatch(t, []string{"yt1", "other"}, []string{first, second})
+
+	close(release)
+	assert.Equal(t, "yt2", <-started)
+	waitIdle(q)
+}
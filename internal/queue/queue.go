@@ -0,0 +1,552 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/errsink"
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+// DefaultWorkers is the number of concurrent jobs when Config.Workers is unset.
+const DefaultWorkers = 2
+
+var (
+	// ErrStopped is returned by Submit after Stop has been called.
+	ErrStopped = errors.New("queue is stopped")
+	// ErrCancelled is the cancellation cause of a job stopped via Cancel.
+	ErrCancelled = errors.New("job cancelled")
+	// ErrQueueFull is returned by Submit when Config.MaxPending jobs are already waiting.
+	ErrQueueFull = errors.New("queue is full")
+	// ErrUserLimit is returned by Submit when the user already has Config.MaxPerUser jobs.
+	ErrUserLimit = errors.New("too many jobs for this user")
+)
+
+// Job is a single download request waiting for or running on a worker.
+// All fields are plain data so a job can be reconstructed without the
+// Telegram update that created it.
+type Job struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	ChatID      int64     `json:"chat_id"`
+	ThreadID    int       `json:"thread_id,omitempty"`
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	StatusMsgID int       `json:"status_msg_id,omitempty"`
+	Created     time.Time `json:"created"`
+
+	// Quality is the user's pick: a max video height such as "720", or
+	// "audio" for audio only. Empty means the default (best up to 1080p).
+	Quality string `json:"quality,omitempty"`
+
+	// Oversize is how to deliver a video over the upload limit if the user
+	// was asked: "split", "compress" or "document". Empty means automatic.
+	Oversize string `json:"oversize,omitempty"`
+
+	// Subtitles is the language whose subtitles are sent along with a video,
+	// taken from the requester's /subs setting. Empty means none.
+	Subtitles string `json:"subtitles,omitempty"`
+
+	// BurnSubtitles burns the Subtitles language into the video instead of
+	// sending .srt files.
+	BurnSubtitles bool `json:"burn_subtitles,omitempty"`
+
+	// LanguageCode is the requester's Telegram language, preferred when
+	// Subtitles is "auto".
+	LanguageCode string `json:"language_code,omitempty"`
+
+	// ClipStart and ClipEnd limit the download to this range of the video,
+	// in seconds (/clip). ClipEnd 0 means the whole video.
+	ClipStart float64 `json:"clip_start,omitempty"`
+	ClipEnd   float64 `json:"clip_end,omitempty"`
+
+	// Stabilize smooths out camera shake during a forced re-encode ("stab").
+	Stabilize bool `json:"stabilize,omitempty"`
+
+	// Scheduled is set for downloads queued by /later, whose requester is
+	// told when they finish.
+	Scheduled bool `json:"scheduled,omitempty"`
+
+	// LiveMinutes records an ongoing live stream for at most this many
+	// minutes from when the job starts. 0 for regular videos.
+	LiveMinutes int `json:"live_minutes,omitempty"`
+
+	// LowPriority jobs (/backfill) only start when no other job is waiting.
+	LowPriority bool `json:"low_priority,omitempty"`
+
+	// Captions is the requester's /captions setting: "parts" to caption
+	// uploads with only their part number, "none" for no caption,
+	// "description" for full captions plus the video's description. Empty
+	// means full captions.
+	Captions string `json:"captions,omitempty"`
+
+	// TargetChatID is the chat results are uploaded to (the requester's
+	// /target), if not ChatID. Status messages stay in ChatID.
+	TargetChatID int64 `json:"target_chat_id,omitempty"`
+
+	// Caption is extra text added under the title of uploads, from a /preset.
+	Caption string `json:"caption,omitempty"`
+
+	// NSFW is set once the thumbnail classifier flags the job's video.
+	NSFW bool `json:"nsfw,omitempty"`
+
+	// Frames and FrameTimes select stills to extract instead of sending the
+	// video (/frames): a count of evenly spaced frames, or offsets in seconds.
+	Frames     int       `json:"frames,omitempty"`
+	FrameTimes []float64 `json:"frame_times,omitempty"`
+
+	// Restored is set when the job was reloaded from the state file after a restart.
+	Restored bool `json:"-"`
+}
+
+// Handler runs a job. The context is cancelled when the queue stops.
+type Handler func(ctx context.Context, job *Job) error
+
+// Config controls queue concurrency and persistence.
+type Config struct {
+	Workers   int    // Max concurrently running jobs (global cap)
+	StateFile string // JSON file recording queued/running jobs; empty disables persistence
+
+	MaxPending int // Max jobs waiting for a worker; 0 means unlimited
+	MaxPerUser int // Max queued plus running jobs per user; 0 means unlimited
+
+	// MaxRunningPerUser caps how many of a user's jobs run at once; the rest
+	// wait while other users' jobs take the free workers. 0 means unlimited.
+	MaxRunningPerUser int
+
+	// Unlimited users (bot admins) aren't bound by MaxPerUser or
+	// MaxRunningPerUser. MaxPending still applies to them.
+	Unlimited map[int64]struct{}
+
+	// DomainLimits caps concurrent jobs per source domain (e.g. "youtube.com": 1)
+	// so parallel fetches don't trigger rate limits. Subdomains count toward
+	// the configured domain. Unlisted domains are only bound by Workers.
+	DomainLimits map[string]int
+
+	// Errors receives handler panics; nil drops them.
+	Errors errsink.Sink
+}
+
+// Queue is a FIFO job queue served by a fixed pool of workers.
+type Queue struct {
+	cfg     Config
+	handler Handler
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []*Job
+	running map[string]*Job
+	cancels map[string]context.CancelCauseFunc
+	stopped bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a queue. Call Start to launch the workers.
+func New(cfg Config, handler Handler) *Queue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		cfg:     cfg,
+		handler: handler,
+		running: make(map[string]*Job),
+		cancels: make(map[string]context.CancelCauseFunc),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// NewJobID returns a short random job identifier.
+func NewJobID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Start re-queues jobs left over from a previous run and launches the worker pool.
+func (q *Queue) Start() {
+	q.restore()
+	logger.Info("Starting job queue", "workers", q.cfg.Workers)
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+}
+
+// Stop rejects new jobs, cancels running ones and waits for workers to exit.
+// Queued and interrupted jobs stay in the state file and resume on next Start.
+// Safe to call multiple times.
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+	q.cancel()
+	q.wg.Wait()
+}
+
+// Submit adds a job to the end of the queue. It returns the job's position
+// among jobs waiting for a worker (1 = next in line), or 0 if a worker is
+// free to start it right away.
+func (q *Queue) Submit(job *Job) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped {
+		return 0, ErrStopped
+	}
+	if err := q.admitLocked(job.UserID); err != nil {
+		return 0, err
+	}
+	if job.ID == "" {
+		job.ID = NewJobID()
+	}
+	if job.Created.IsZero() {
+		job.Created = time.Now()
+	}
+
+	// Regular jobs go ahead of waiting low-priority ones
+	ahead := len(q.pending)
+	if !job.LowPriority {
+		ahead = 0
+		for _, p := range q.pending {
+			if !p.LowPriority {
+				ahead++
+			}
+		}
+	}
+	position := ahead + 1 - (q.cfg.Workers - len(q.running))
+	if position < 0 {
+		position = 0
+	}
+	if position == 0 && !q.userFreeLocked(job, true) {
+		// A free worker, but the user's earlier jobs take their share
+		position = 1
+	}
+
+	// The queue keeps its own copy: the caller's and the handler's are
+	// theirs to change while saveLocked and Jobs read this one
+	stored := *job
+	q.pending = append(q.pending, &stored)
+	q.saveLocked()
+	q.cond.Signal()
+	return position, nil
+}
+
+// Admit reports whether a job from userID would currently be accepted, so
+// callers can reject it before doing any work. Submit checks again.
+func (q *Queue) Admit(userID int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		return ErrStopped
+	}
+	return q.admitLocked(userID)
+}
+
+func (q *Queue) admitLocked(userID int64) error {
+	if q.cfg.MaxPending > 0 && len(q.pending) >= q.cfg.MaxPending {
+		return ErrQueueFull
+	}
+	if _, unlimited := q.cfg.Unlimited[userID]; q.cfg.MaxPerUser > 0 && userID != 0 && !unlimited {
+		var n int
+		for _, job := range q.pending {
+			if job.UserID == userID {
+				n++
+			}
+		}
+		for _, job := range q.running {
+			if job.UserID == userID {
+				n++
+			}
+		}
+		if n >= q.cfg.MaxPerUser {
+			return ErrUserLimit
+		}
+	}
+	return nil
+}
+
+// Cancel stops a job: a pending job is dropped from the queue, a running job
+// has its context cancelled with cause ErrCancelled. Returns false if no such
+// job is queued or running.
+func (q *Queue) Cancel(jobID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if cancel, ok := q.cancels[jobID]; ok {
+		cancel(ErrCancelled)
+		return true
+	}
+	for i, job := range q.pending {
+		if job.ID == jobID {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			q.saveLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// IsCancelled reports whether ctx (as passed to a Handler) was cancelled via Cancel.
+func IsCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrCancelled)
+}
+
+// Jobs returns a snapshot of running jobs (oldest first) followed by pending
+// jobs in queue order.
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.running)+len(q.pending))
+	for _, job := range q.sortedRunningLocked() {
+		jobs = append(jobs, *job)
+	}
+	for _, job := range q.pending {
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
+
+// SetStatusMsg records the job's current status message, so Jobs and a
+// restart after the job was interrupted use it. Handlers change their own
+// copy of the job; this updates the queue's.
+func (q *Queue) SetStatusMsg(jobID string, msgID int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := q.running[jobID]
+	for _, p := range q.pending {
+		if job == nil && p.ID == jobID {
+			job = p
+		}
+	}
+	if job == nil || job.StatusMsgID == msgID {
+		return
+	}
+	job.StatusMsgID = msgID
+	q.saveLocked()
+}
+
+// IsRunning reports whether the job is currently on a worker.
+func (q *Queue) IsRunning(jobID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.running[jobID]
+	return ok
+}
+
+// Len returns the number of waiting and running jobs.
+func (q *Queue) Len() (pending, running int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending), len(q.running)
+}
+
+// Workers returns the size of the worker pool.
+func (q *Queue) Workers() int {
+	return q.cfg.Workers
+}
+
+// EstimateWait estimates how long a job waits for a worker when ahead jobs
+// (running and pending) are in front of it and each job takes about avg.
+func EstimateWait(ahead, workers int, avg time.Duration) time.Duration {
+	if workers < 1 {
+		workers = 1
+	}
+	// Every full round of workers ahead of the job delays it by one job duration
+	return time.Duration(ahead/workers) * avg
+}
+
+// next blocks until a job is available and marks it running. It returns a
+// copy of the job for the handler. Returns nil when the queue is stopped.
+func (q *Queue) next() *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.stopped {
+			return nil
+		}
+		if i := q.nextRunnableLocked(); i >= 0 {
+			job := q.pending[i]
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			q.running[job.ID] = job
+			q.saveLocked()
+			run := *job
+			return &run
+		}
+		q.cond.Wait()
+	}
+}
+
+// nextRunnableLocked returns the index of the oldest pending job whose domain
+// and user are below their concurrency limits, regular jobs before
+// low-priority ones, or -1. Must hold q.mu.
+func (q *Queue) nextRunnableLocked() int {
+	for _, low := range []bool{false, true} {
+		for i, job := range q.pending {
+			if job.LowPriority == low && q.domainFreeLocked(job) && q.userFreeLocked(job, false) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// domainFreeLocked reports whether job's domain is below its concurrency
+// limit. Must hold q.mu.
+func (q *Queue) domainFreeLocked(job *Job) bool {
+	key := limitKey(job.URL, q.cfg.DomainLimits)
+	if key == "" {
+		return true
+	}
+	var active int
+	for _, r := range q.running {
+		if limitKey(r.URL, q.cfg.DomainLimits) == key {
+			active++
+		}
+	}
+	return active < q.cfg.DomainLimits[key]
+}
+
+// userFreeLocked reports whether job's user has fewer than
+// MaxRunningPerUser jobs running, counting their pending jobs too if
+// withPending. Must hold q.mu.
+func (q *Queue) userFreeLocked(job *Job, withPending bool) bool {
+	if _, unlimited := q.cfg.Unlimited[job.UserID]; q.cfg.MaxRunningPerUser <= 0 || job.UserID == 0 || unlimited {
+		return true
+	}
+	var active int
+	for _, r := range q.running {
+		if r.UserID == job.UserID {
+			active++
+		}
+	}
+	if withPending {
+		for _, p := range q.pending {
+			if p.UserID == job.UserID {
+				active++
+			}
+		}
+	}
+	return active < q.cfg.MaxRunningPerUser
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for {
+		job := q.next()
+		if job == nil {
+			return
+		}
+		q.run(job)
+	}
+}
+
+// run executes a job, recovering from handler panics so a single bad job
+// can't take a worker down.
+func (q *Queue) run(job *Job) {
+	start := time.Now()
+	// The handler and everything it calls log with the job's ID
+	log := logger.With("job", job.ID)
+	ctx, cancel := context.WithCancelCause(logger.NewContext(q.ctx, log))
+	q.mu.Lock()
+	q.cancels[job.ID] = cancel
+	q.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Job handler panicked", "url", job.URL, "panic", r, "stack", string(debug.Stack()))
+			errsink.Capture(q.cfg.Errors, errsink.Event{
+				Type:    "panic",
+				Message: fmt.Sprint(r),
+				Level:   errsink.Fatal,
+				Stack:   errsink.Stack(0),
+				Tags:    map[string]string{"job": job.ID},
+				Extra:   map[string]string{"url": job.URL, "user": job.Username},
+			})
+		}
+		cancel(nil)
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		delete(q.cancels, job.ID)
+		// A domain or user slot may have freed up for a job other workers skipped
+		q.cond.Broadcast()
+		// On shutdown, keep interrupted jobs in the state file so they resume
+		if !q.stopped {
+			q.saveLocked()
+		}
+		q.mu.Unlock()
+	}()
+
+	log.Info("Job started", "url", job.URL, "user", job.Username)
+	err := q.handler(ctx, job)
+	log.Info("Job done", "url", job.URL, "ok", err == nil, "elapsed", time.Since(start).Round(time.Second))
+}
+
+// saveLocked writes running and pending jobs to the state file. Must hold q.mu.
+func (q *Queue) saveLocked() {
+	if q.cfg.StateFile == "" {
+		return
+	}
+
+	jobs := append(q.sortedRunningLocked(), q.pending...)
+
+	if err := store.SaveJSON(q.cfg.StateFile, jobs); err != nil {
+		logger.Error("Failed to persist job queue", "file", q.cfg.StateFile, "error", err)
+	}
+}
+
+// restore queues jobs recorded in the state file by a previous run,
+// interrupted ones first.
+func (q *Queue) restore() {
+	if q.cfg.StateFile == "" {
+		return
+	}
+
+	var jobs []*Job
+	if err := store.LoadJSON(q.cfg.StateFile, &jobs); err != nil {
+		logger.Error("Failed to load persisted job queue", "file", q.cfg.StateFile, "error", err)
+		return
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range jobs {
+		if job == nil || job.ID == "" {
+			continue
+		}
+		job.Restored = true
+		q.pending = append(q.pending, job)
+	}
+	logger.Info("Restored persisted jobs", "count", len(q.pending))
+}
+
+// sortedRunningLocked returns running jobs, oldest first. Must hold q.mu.
+func (q *Queue) sortedRunningLocked() []*Job {
+	jobs := make([]*Job, 0, len(q.running))
+	for _, job := range q.running {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+	return jobs
+}
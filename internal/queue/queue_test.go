@@ -0,0 +1,378 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func TestQueueRunsAllJobs(t *testing.T) {
+	var done sync.WaitGroup
+	var count atomic.Int32
+	q := New(Config{Workers: 3}, func(ctx context.Context, job *Job) error {
+		count.Add(1)
+		done.Done()
+		return nil
+	})
+	q.Start()
+	defer q.Stop()
+
+	for i := 0; i < 10; i++ {
+		done.Add(1)
+		_, err := q.Submit(&Job{URL: "https://example.com"})
+		require.NoError(t, err)
+	}
+	done.Wait()
+	assert.Equal(t, int32(10), count.Load())
+}
+
+func TestQueueRespectsWorkerCap(t *testing.T) {
+	var current, peak atomic.Int32
+	var done sync.WaitGroup
+	q := New(Config{Workers: 2}, func(ctx context.Context, job *Job) error {
+		n := current.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		current.Add(-1)
+		done.Done()
+		return nil
+	})
+	q.Start()
+	defer q.Stop()
+
+	for i := 0; i < 6; i++ {
+		done.Add(1)
+		q.Submit(&Job{})
+	}
+	done.Wait()
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestSubmitReportsPosition(t *testing.T) {
+	block := make(chan struct{})
+	q := New(Config{Workers: 1}, func(ctx context.Context, job *Job) error {
+		<-block
+		return nil
+	})
+	// Not started: the single worker is free, so the first job has nobody ahead
+	position, err := q.Submit(&Job{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, position)
+
+	position, _ = q.Submit(&Job{})
+	assert.Equal(t, 1, position)
+
+	pending, running := q.Len()
+	assert.Equal(t, 2, pending)
+	assert.Equal(t, 0, running)
+
+	close(block)
+	q.Stop()
+}
+
+func TestLowPriorityJobsRunLast(t *testing.T) {
+	started := make(chan string, 3)
+	q := New(Config{Workers: 1}, func(ctx context.Context, job *Job) error {
+		started <- job.ID
+		return nil
+	})
+	position, _ := q.Submit(&Job{ID: "backfill", LowPriority: true})
+	assert.Equal(t, 0, position)
+	q.Submit(&Job{ID: "backfill2", LowPriority: true})
+	position, _ = q.Submit(&Job{ID: "user"})
+	assert.Equal(t, 0, position, "regular jobs go ahead of low-priority ones")
+
+	q.Start()
+	defer q.Stop()
+	assert.Equal(t, "user", <-started)
+	assert.Equal(t, "backfill", <-started)
+	assert.Equal(t, "backfill2", <-started)
+}
+
+func TestSubmitAssignsIDAndTime(t *testing.T) {
+	q := New(Config{}, func(ctx context.Context, job *Job) error { return nil })
+	job := &Job{}
+	q.Submit(job)
+	assert.NotEmpty(t, job.ID)
+	assert.False(t, job.Created.IsZero())
+	assert.Equal(t, DefaultWorkers, q.cfg.Workers)
+}
+
+func TestStopCancelsRunningJobs(t *testing.T) {
+	started := make(chan struct{})
+	q := New(Config{Workers: 1}, func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	q.Start()
+	q.Submit(&Job{})
+	<-started
+	q.Stop()
+
+	_, err := q.Submit(&Job{})
+	assert.ErrorIs(t, err, ErrStopped)
+}
+
+func TestHandlerPanicDoesNotKillWorker(t *testing.T) {
+	var done sync.WaitGroup
+	done.Add(2)
+	q := New(Config{Workers: 1}, func(ctx context.Context, job *Job) error {
+		defer done.Done()
+		if job.URL == "panic" {
+			panic("boom")
+		}
+		return nil
+	})
+	q.Start()
+	defer q.Stop()
+
+	q.Submit(&Job{URL: "panic"})
+	q.Submit(&Job{URL: "ok"})
+	done.Wait()
+}
+
+func TestStateFileRestoresJobs(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "jobs.json")
+
+	// First run: one job interrupted mid-flight, one still queued
+	started := make(chan struct{})
+	q := New(Config{Workers: 1, StateFile: stateFile}, func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	q.Start()
+	q.Submit(&Job{ID: "first", URL: "https://example.com/1"})
+	q.Submit(&Job{ID: "second", URL: "https://example.com/2"})
+	<-started
+	q.Stop()
+
+	// Second run picks both up, interrupted job first
+	var mu sync.Mutex
+	var got []string
+	var done sync.WaitGroup
+	done.Add(2)
+	q2 := New(Config{Workers: 1, StateFile: stateFile}, func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		got = append(got, job.ID)
+		mu.Unlock()
+		assert.True(t, job.Restored)
+		done.Done()
+		return nil
+	})
+	q2.Start()
+	done.Wait()
+	waitIdle(q2)
+	q2.Stop()
+	assert.Equal(t, []string{"first", "second"}, got)
+
+	// Finished jobs are removed from the state file
+	q3 := New(Config{Workers: 1, StateFile: stateFile}, func(ctx context.Context, job *Job) error {
+		t.Errorf("unexpected job %s", job.ID)
+		return nil
+	})
+	q3.Start()
+	pending, running := q3.Len()
+	assert.Zero(t, pending+running)
+	q3.Stop()
+}
+
+// waitIdle blocks until the queue has no pending or running jobs.
+func waitIdle(q *Queue) {
+	for {
+		if pending, running := q.Len(); pending+running == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	started := make(chan struct{})
+	result := make(chan bool)
+	q := New(Config{Workers: 1}, func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		result <- IsCancelled(ctx)
+		return ctx.Err()
+	})
+	q.Start()
+	defer q.Stop()
+
+	q.Submit(&Job{ID: "a"})
+	<-started
+	assert.True(t, q.IsRunning("a"))
+	assert.True(t, q.Cancel("a"))
+	assert.True(t, <-result)
+}
+
+func TestCancelPendingJob(t *testing.T) {
+	q := New(Config{Workers: 1}, func(ctx context.Context, job *Job) error { return nil })
+	q.Submit(&Job{ID: "a"})
+	q.Submit(&Job{ID: "b"})
+
+	assert.True(t, q.Cancel("a"))
+	assert.False(t, q.Cancel("a"))
+	assert.False(t, q.Cancel("missing"))
+
+	jobs := q.Jobs()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "b", jobs[0].ID)
+}
+
+func TestStopIsNotCancel(t *testing.T) {
+	started := make(chan struct{})
+	result := make(chan bool, 1)
+	q := New(Config{Workers: 1}, func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		result <- IsCancelled(ctx)
+		return ctx.Err()
+	})
+	q.Start()
+	q.Submit(&Job{})
+	<-started
+	q.Stop()
+	assert.False(t, <-result)
+}
+
+func TestEstimateWait(t *testing.T) {
+	avg := time.Minute
+	assert.Equal(t, time.Duration(0), EstimateWait(0, 2, avg))
+	assert.Equal(t, time.Duration(0), EstimateWait(1, 2, avg))
+	assert.Equal(t, time.Minute, EstimateWait(2, 2, avg))
+	assert.Equal(t, time.Minute, EstimateWait(3, 2, avg))
+	assert.Equal(t, 2*time.Minute, EstimateWait(4, 2, avg))
+	assert.Equal(t, 3*time.Minute, EstimateWait(3, 0, avg))
+}
+
+func TestSubmitEnforcesCaps(t *testing.T) {
+	q := New(Config{Workers: 1, MaxPending: 3, MaxPerUser: 2}, func(ctx context.Context, job *Job) error { return nil })
+
+	_, err := q.Submit(&Job{UserID: 1})
+	require.NoError(t, err)
+	_, err = q.Submit(&Job{UserID: 1})
+	require.NoError(t, err)
+	_, err = q.Submit(&Job{UserID: 1})
+	assert.ErrorIs(t, err, ErrUserLimit)
+	assert.ErrorIs(t, q.Admit(1), ErrUserLimit)
+	assert.NoError(t, q.Admit(2))
+
+	_, err = q.Submit(&Job{UserID: 2})
+	require.NoError(t, err)
+	_, err = q.Submit(&Job{UserID: 3})
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.ErrorIs(t, q.Admit(3), ErrQueueFull)
+}
+
+func TestUnlimitedUsersSkipUserCap(t *testing.T) {
+	q := New(Config{Workers: 1, MaxPending: 3, MaxPerUser: 1, Unlimited: map[int64]struct{}{9: {}}},
+		func(ctx context.Context, job *Job) error { return nil })
+
+	for i := 0; i < 3; i++ {
+		_, err := q.Submit(&Job{UserID: 9})
+		require.NoError(t, err)
+	}
+	_, err := q.Submit(&Job{UserID: 9})
+	assert.ErrorIs(t, err, ErrQueueFull, "the queue cap still applies")
+}
+
+func TestUserRunningLimitLetsOthersThrough(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 4)
+	q := New(Config{Workers: 2, MaxRunningPerUser: 1, Unlimited: map[int64]struct{}{9: {}}},
+		func(ctx context.Context, job *Job) error {
+			started <- job.ID
+			if job.ID == "a1" {
+				<-release
+			}
+			return nil
+		})
+	q.Submit(&Job{ID: "a1", UserID: 1})
+	position, _ := q.Submit(&Job{ID: "a2", UserID: 1})
+	assert.Equal(t, 1, position, "a free worker, but user 1 already has a job")
+	q.Submit(&Job{ID: "b1", UserID: 2})
+	q.Start()
+	defer q.Stop()
+
+	// a2 waits for a1, so the second worker picks up user 2's job
+	first, second := <-started, <-started
+	assert.ElementsMatch(t, []string{"a1", "b1"}, []string{first, second})
+
+	close(release)
+	assert.Equal(t, "a2", <-started)
+	waitIdle(q)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	assert.True(t, q.userFreeLocked(&Job{UserID: 9}, true), "unlimited users have no running cap")
+}
+
+func TestRunningJobUpdatesDontRaceWithSaves(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "jobs.json")
+	running := make(chan struct{})
+	release := make(chan struct{})
+	var q *Queue
+	q = New(Config{Workers: 1, StateFile: stateFile}, func(ctx context.Context, job *Job) error {
+		close(running)
+		// The handler updates its job while other jobs are submitted and saved
+		for i := 1; ; i++ {
+			select {
+			case <-release:
+				return nil
+			default:
+			}
+			job.StatusMsgID = i
+			job.NSFW = i%2 == 0
+			q.SetStatusMsg(job.ID, i)
+		}
+	})
+	q.Start()
+	defer q.Stop()
+
+	q.Submit(&Job{ID: "first", UserID: 1})
+	<-running
+	for i := 0; i < 100; i++ {
+		_, err := q.Submit(&Job{UserID: 2})
+		require.NoError(t, err)
+		q.Jobs()
+	}
+	close(release)
+	waitIdle(q)
+}
+
+func TestSetStatusMsg(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "jobs.json")
+	q := New(Config{Workers: 1, StateFile: stateFile}, func(ctx context.Context, job *Job) error { return nil })
+	job := &Job{ID: "a", StatusMsgID: 1}
+	q.Submit(job)
+	job.StatusMsgID = 5 // the caller's copy isn't the queue's
+	assert.Equal(t, 1, q.Jobs()[0].StatusMsgID)
+
+	q.SetStatusMsg("a", 7)
+	assert.Equal(t, 7, q.Jobs()[0].StatusMsgID)
+
+	var saved []Job
+	require.NoError(t, store.LoadJSON(stateFile, &saved))
+	assert.Equal(t, 7, saved[0].StatusMsgID)
+}
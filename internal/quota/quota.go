@@ -0,0 +1,205 @@
+// Package quota counts each user's downloads and bytes per day against the
+// daily limits, persisted so a restart doesn't reset them. Days run from
+// server-local midnight.
+package quota
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+// Limits are the per-user daily caps; 0 means no cap.
+type Limits struct {
+	Downloads int
+	Bytes     int64
+}
+
+// Usage is what a user downloaded on Day ("2006-01-02", server-local).
+type Usage struct {
+	Day       string `json:"day"`
+	Downloads int    `json:"downloads"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// state is the persisted part of a Tracker.
+type state struct {
+	Users     map[int64]Usage `json:"users"`
+	Unlimited map[int64]bool  `json:"unlimited,omitempty"` // users an admin exempted
+}
+
+// Tracker holds every user's usage of the current day.
+type Tracker struct {
+	mu     sync.Mutex
+	path   string
+	limits Limits
+	state  state
+	now    func() time.Time
+}
+
+// New creates a tracker enforcing limits, backed by path (empty disables
+// persistence).
+func New(path string, limits Limits) *Tracker {
+	t := &Tracker{path: path, limits: limits, now: time.Now}
+	if path != "" {
+		if err := store.LoadJSON(path, &t.state); err != nil {
+			logger.Warn("Failed to load download quotas", "error", err)
+		}
+	}
+	if t.state.Users == nil {
+		t.state.Users = make(map[int64]Usage)
+	}
+	if t.state.Unlimited == nil {
+		t.state.Unlimited = make(map[int64]bool)
+	}
+	return t
+}
+
+// Limits returns the daily caps.
+func (t *Tracker) Limits() Limits {
+	return t.limits
+}
+
+// Enabled reports whether any daily cap is set.
+func (t *Tracker) Enabled() bool {
+	return t.limits.Downloads > 0 || t.limits.Bytes > 0
+}
+
+// Get returns userID's usage today.
+func (t *Tracker) Get(userID int64) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.todayLocked(userID)
+}
+
+// Allowed reports whether userID may start another download today, with
+// queued of their downloads already waiting or running (they count toward
+// the download cap before they finish).
+func (t *Tracker) Allowed(userID int64, queued int) bool {
+	_, ok := t.Remaining(userID, queued)
+	return ok
+}
+
+// Remaining returns what is left of userID's caps today with queued of
+// their downloads waiting or running; a zero field has no cap. ok is false
+// when nothing is left, so no further download may start.
+func (t *Tracker) Remaining(userID int64, queued int) (left Limits, ok bool) {
+	if !t.Enabled() {
+		return Limits{}, true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state.Unlimited[userID] {
+		return Limits{}, true
+	}
+	u := t.todayLocked(userID)
+	if t.limits.Downloads > 0 {
+		left.Downloads = t.limits.Downloads - u.Downloads - queued
+		if left.Downloads <= 0 {
+			return Limits{}, false
+		}
+	}
+	if t.limits.Bytes > 0 {
+		left.Bytes = t.limits.Bytes - u.Bytes
+		if left.Bytes <= 0 {
+			return Limits{}, false
+		}
+	}
+	return left, true
+}
+
+// Add counts a delivered download of size bytes for userID.
+func (t *Tracker) Add(userID int64, bytes int64) {
+	if !t.Enabled() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updateLocked(func() {
+		u := t.todayLocked(userID)
+		u.Downloads++
+		u.Bytes += bytes
+		t.state.Users[userID] = u
+	})
+}
+
+// Reset clears userID's usage today.
+func (t *Tracker) Reset(userID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updateLocked(func() {
+		delete(t.state.Users, userID)
+	})
+}
+
+// SetUnlimited exempts userID from the caps, or ends the exemption.
+func (t *Tracker) SetUnlimited(userID int64, unlimited bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updateLocked(func() {
+		if unlimited {
+			t.state.Unlimited[userID] = true
+		} else {
+			delete(t.state.Unlimited, userID)
+		}
+	})
+}
+
+// Unlimited reports whether userID is exempt from the caps.
+func (t *Tracker) Unlimited(userID int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.Unlimited[userID]
+}
+
+// ResetAt returns when the current day's usage resets: the next
+// server-local midnight.
+func (t *Tracker) ResetAt() time.Time {
+	now := t.now()
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+}
+
+// todayLocked returns userID's usage, zero if it is from an earlier day.
+// Must hold t.mu.
+func (t *Tracker) todayLocked(userID int64) Usage {
+	today := t.now().Format("2006-01-02")
+	u := t.state.Users[userID]
+	if u.Day != today {
+		return Usage{Day: today}
+	}
+	return u
+}
+
+// updateLocked applies fn to the state as saved on disk (another bot
+// instance may share it), drops earlier days and saves it. If the file
+// can't be read, fn still applies in memory so usage keeps counting.
+// Must hold t.mu.
+func (t *Tracker) updateLocked(fn func()) {
+	applied := false
+	apply := func() error {
+		if t.state.Users == nil {
+			t.state.Users = make(map[int64]Usage)
+		}
+		if t.state.Unlimited == nil {
+			t.state.Unlimited = make(map[int64]bool)
+		}
+		fn()
+		applied = true
+		today := t.now().Format("2006-01-02")
+		for userID, u := range t.state.Users {
+			if u.Day != today {
+				delete(t.state.Users, userID)
+			}
+		}
+		return nil
+	}
+	if err := store.Update(t.path, &t.state, apply); err != nil {
+		logger.Warn("Failed to save download quotas", "error", err)
+		if !applied {
+			apply()
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package quota
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+// at returns a clock stuck at t.
+func at(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+func TestDownloadLimit(t *testing.T) {
+	q := New("", Limits{Downloads: 2})
+	q.now = at(time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC))
+
+	assert.True(t, q.Allowed(1, 0))
+	assert.False(t, q.Allowed(1, 2), "queued downloads count")
+	q.Add(1, 100)
+	q.Add(1, 100)
+	assert.False(t, q.Allowed(1, 0))
+	assert.True(t, q.Allowed(2, 0), "per user")
+	assert.Equal(t, Usage{Day: "2026-10-15", Downloads: 2, Bytes: 200}, q.Get(1))
+
+	// A new day starts over
+	q.now = at(time.Date(2026, 10, 16, 0, 0, 1, 0, time.UTC))
+	assert.True(t, q.Allowed(1, 0))
+	assert.Equal(t, 0, q.Get(1).Downloads)
+}
+
+func TestByteLimit(t *testing.T) {
+	q := New("", Limits{Bytes: 1000})
+	q.Add(1, 600)
+	assert.True(t, q.Allowed(1, 5), "no download cap")
+	q.Add(1, 600)
+	assert.False(t, q.Allowed(1, 0))
+}
+
+func TestOverrides(t *testing.T) {
+	q := New("", Limits{Downloads: 1})
+	q.Add(1, 0)
+	assert.False(t, q.Allowed(1, 0))
+
+	q.Reset(1)
+	assert.True(t, q.Allowed(1, 0))
+
+	q.Add(1, 0)
+	q.SetUnlimited(1, true)
+	assert.True(t, q.Allowed(1, 0))
+	assert.True(t, q.Unlimited(1))
+	q.SetUnlimited(1, false)
+	assert.False(t, q.Allowed(1, 0))
+}
+
+func TestRemaining(t *testing.T) {
+	q := New("", Limits{Downloads: 5, Bytes: 1000})
+	q.Add(1, 300)
+	left, ok := q.Remaining(1, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Limits{Downloads: 3, Bytes: 700}, left)
+
+	q.Add(1, 800)
+	_, ok = q.Remaining(1, 0)
+	assert.False(t, ok, "bytes used up")
+
+	q = New("", Limits{Downloads: 2})
+	left, ok = q.Remaining(1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, Limits{Downloads: 2}, left, "no byte cap")
+	q.SetUnlimited(1, true)
+	left, ok = q.Remaining(1, 5)
+	assert.True(t, ok)
+	assert.Equal(t, Limits{}, left)
+}
+
+func TestDisabled(t *testing.T) {
+	q := New("", Limits{})
+	assert.False(t, q.Enabled())
+	q.Add(1, 1<<40)
+	assert.True(t, q.Allowed(1, 100))
+}
+
+func TestResetAt(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	q := New("", Limits{Downloads: 1})
+	q.now = at(time.Date(2026, 10, 15, 23, 30, 0, 0, loc))
+	assert.Equal(t, time.Date(2026, 10, 16, 0, 0, 0, 0, loc), q.ResetAt())
+}
+
+func TestPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	q := New(path, Limits{Downloads: 5})
+	q.Add(1, 42)
+	q.SetUnlimited(2, true)
+
+	reloaded := New(path, Limits{Downloads: 5})
+	assert.Equal(t, 1, reloaded.Get(1).Downloads)
+	assert.Equal(t, int64(42), reloaded.Get(1).Bytes)
+	assert.True(t, reloaded.Unlimited(2))
+}
+
+func TestSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	a := New(path, Limits{Downloads: 5})
+	b := New(path, Limits{Downloads: 5})
+
+	// Two instances on one file count each other's downloads
+	a.Add(1, 10)
+	b.Add(1, 20)
+	reloaded := New(path, Limits{Downloads: 5})
+	assert.Equal(t, Usage{Day: reloaded.Get(1).Day, Downloads: 2, Bytes: 30}, reloaded.Get(1))
+}
@@ -0,0 +1,147 @@
+// Package ratelimit smooths bursts of Telegram API calls with token buckets.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket holding up to capacity tokens, refilled at a
+// steady rate. It is not safe for concurrent use; Governor locks around it.
+type Bucket struct {
+	capacity float64
+	rate     float64 // tokens per second
+	tokens   float64
+	last     time.Time
+}
+
+// NewBucket returns a full bucket that allows burst calls at once and refills
+// at count per interval.
+func NewBucket(burst, count int, interval time.Duration, now time.Time) *Bucket {
+	return &Bucket{
+		capacity: float64(burst),
+		rate:     float64(count) / interval.Seconds(),
+		tokens:   float64(burst),
+		last:     now,
+	}
+}
+
+func (b *Bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// ready reports whether a token is available now.
+func (b *Bucket) ready(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= 1
+}
+
+// reserve takes a token, going into debt if none is left, and returns how
+// long the caller has to wait before using it.
+func (b *Bucket) reserve(now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// full reports whether the bucket has refilled completely (an idle chat).
+func (b *Bucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.capacity
+}
+
+// Config sets the limits of a Governor.
+type Config struct {
+	PerChat   int // calls per minute in one chat
+	ChatBurst int // calls a chat may make back to back
+	Global    int // calls per second across all chats
+}
+
+// pruneAbove is the number of tracked chats above which idle ones are dropped.
+const pruneAbove = 1000
+
+// Governor enforces a per-chat and a global rate shared by every job, so
+// many concurrent downloads for one chat don't trip Telegram's flood limits.
+type Governor struct {
+	cfg Config
+	now func() time.Time
+
+	mu     sync.Mutex
+	global *Bucket
+	chats  map[int64]*Bucket
+}
+
+// New returns a Governor with the given limits.
+func New(cfg Config) *Governor {
+	return newGovernor(cfg, time.Now)
+}
+
+func newGovernor(cfg Config, now func() time.Time) *Governor {
+	return &Governor{
+		cfg:    cfg,
+		now:    now,
+		global: NewBucket(cfg.Global, cfg.Global, time.Second, now()),
+		chats:  make(map[int64]*Bucket),
+	}
+}
+
+func (g *Governor) chat(chatID int64, now time.Time) *Bucket {
+	b, ok := g.chats[chatID]
+	if !ok {
+		if len(g.chats) >= pruneAbove {
+			for id, c := range g.chats {
+				if c.full(now) {
+					delete(g.chats, id)
+				}
+			}
+		}
+		b = NewBucket(g.cfg.ChatBurst, g.cfg.PerChat, time.Minute, now)
+		g.chats[chatID] = b
+	}
+	return b
+}
+
+// Allow takes a token for chatID if one is free right now and reports
+// whether the call may go ahead. Used for skippable calls such as progress
+// edits: a denied update is simply dropped.
+func (g *Governor) Allow(chatID int64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	chat := g.chat(chatID, now)
+	if !chat.ready(now) || !g.global.ready(now) {
+		return false
+	}
+	chat.reserve(now)
+	g.global.reserve(now)
+	return true
+}
+
+// Wait blocks until chatID may make a call, for messages that must be
+// delivered. Returns ctx.Err() if the context ends first; the token is
+// spent either way.
+func (g *Governor) Wait(ctx context.Context, chatID int64) error {
+	g.mu.Lock()
+	now := g.now()
+	delay := max(g.chat(chatID, now).reserve(now), g.global.reserve(now))
+	g.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced time source.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestBucketReserve(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := NewBucket(2, 60, time.Minute, start)
+
+	assert.Zero(t, b.reserve(start))
+	assert.Zero(t, b.reserve(start))
+	assert.Equal(t, time.Second, b.reserve(start), "third call waits for one refill")
+	assert.Equal(t, 2*time.Second, b.reserve(start))
+
+	// Refills never exceed capacity
+	later := start.Add(time.Hour)
+	assert.True(t, b.full(later))
+	assert.InDelta(t, 2, b.tokens, 0.001)
+}
+
+func TestGovernorAllowPerChat(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	g := newGovernor(Config{PerChat: 20, ChatBurst: 3, Global: 30}, clock.now)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, g.Allow(1), "burst call %d", i)
+	}
+	assert.False(t, g.Allow(1), "burst used up")
+	assert.True(t, g.Allow(2), "other chats are unaffected")
+
+	clock.advance(3 * time.Second) // 20/min = one token per 3s
+	assert.True(t, g.Allow(1))
+	assert.False(t, g.Allow(1))
+}
+
+func TestGovernorAllowGlobal(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	g := newGovernor(Config{PerChat: 20, ChatBurst: 3, Global: 5}, clock.now)
+
+	for chat := int64(1); chat <= 5; chat++ {
+		assert.True(t, g.Allow(chat))
+	}
+	assert.False(t, g.Allow(6), "global limit reached")
+
+	clock.advance(time.Second)
+	assert.True(t, g.Allow(6))
+}
+
+func TestGovernorDeniedAllowSpendsNothing(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	g := newGovernor(Config{PerChat: 20, ChatBurst: 1, Global: 30}, clock.now)
+
+	require.True(t, g.Allow(1))
+	for i := 0; i < 10; i++ {
+		assert.False(t, g.Allow(1))
+	}
+	clock.advance(3 * time.Second)
+	assert.True(t, g.Allow(1), "denied calls must not push the chat into debt")
+}
+
+func TestGovernorWait(t *testing.T) {
+	g := New(Config{PerChat: 600, ChatBurst: 1, Global: 30}) // one token per 100ms
+
+	require.NoError(t, g.Wait(context.Background(), 1))
+	start := time.Now()
+	require.NoError(t, g.Wait(context.Background(), 1))
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, g.Wait(ctx, 1), context.Canceled)
+}
@@ -0,0 +1,161 @@
+// Package schedule holds downloads queued for a later time with /later, e.g.
+// off-peak hours, persisted so they survive restarts.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+const (
+	// MaxPerUser is how many downloads one user may have scheduled.
+	MaxPerUser = 10
+
+	// MaxAhead is how far ahead a download may be scheduled.
+	MaxAhead = 7 * 24 * time.Hour
+)
+
+// ErrUserLimit means the user already has MaxPerUser downloads scheduled.
+var ErrUserLimit = errors.New("too many scheduled downloads")
+
+// Entry is a job waiting for its time.
+type Entry struct {
+	Job *queue.Job `json:"job"`
+	At  time.Time  `json:"at"`
+}
+
+// Schedule is the list of scheduled downloads, persisted as JSON.
+type Schedule struct {
+	mu      sync.Mutex
+	path    string
+	entries []Entry
+}
+
+// New creates a schedule backed by path (empty disables persistence).
+// Entries whose time passed while the bot was down are due right away.
+func New(path string) *Schedule {
+	s := &Schedule{path: path}
+	if path != "" {
+		if err := store.LoadJSON(path, &s.entries); err != nil {
+			logger.Warn("Failed to load download schedule", "error", err)
+		}
+	}
+	return s
+}
+
+// Add schedules job to be queued at at.
+func (s *Schedule) Add(job *queue.Job, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, e := range s.entries {
+		if e.Job.UserID == job.UserID {
+			count++
+		}
+	}
+	if count >= MaxPerUser {
+		return ErrUserLimit
+	}
+	s.entries = append(s.entries, Entry{Job: job, At: at})
+	s.saveLocked()
+	return nil
+}
+
+// Due removes and returns the entries whose time has come, earliest first.
+func (s *Schedule) Due(now time.Time) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due, rest []Entry
+	for _, e := range s.entries {
+		if e.At.After(now) {
+			rest = append(rest, e)
+		} else {
+			due = append(due, e)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	s.entries = rest
+	s.saveLocked()
+	sortEntries(due)
+	return due
+}
+
+// List returns userID's scheduled downloads, earliest first.
+func (s *Schedule) List(userID int64) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Entry
+	for _, e := range s.entries {
+		if e.Job.UserID == userID {
+			list = append(list, e)
+		}
+	}
+	sortEntries(list)
+	return list
+}
+
+// Cancel removes userID's scheduled download jobID. Returns false if there
+// is no such entry.
+func (s *Schedule) Cancel(userID int64, jobID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if e.Job.UserID == userID && e.Job.ID == jobID {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			s.saveLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// saveLocked persists the schedule. Must hold s.mu.
+func (s *Schedule) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	if err := store.SaveJSON(s.path, s.entries); err != nil {
+		logger.Warn("Failed to save download schedule", "error", err)
+	}
+}
+
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+}
+
+// ParseAt parses when a /later download should run: a time of day such as
+// "22:00" (its next occurrence in now's location) or a delay such as "2h"
+// or "90m". The result must be within MaxAhead of now.
+func ParseAt(spec string, now time.Time) (time.Time, error) {
+	if hour, minute, ok := strings.Cut(spec, ":"); ok {
+		h, errH := strconv.Atoi(hour)
+		m, errM := strconv.Atoi(minute)
+		if errH != nil || errM != nil || h < 0 || h > 23 || m < 0 || m > 59 || len(minute) != 2 {
+			return time.Time{}, fmt.Errorf("invalid time of day %q, want HH:MM", spec)
+		}
+		at := time.Date(now.Year(), now.Month(), now.Day(), h, m, 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	delay, err := time.ParseDuration(spec)
+	if err != nil || delay <= 0 {
+		return time.Time{}, fmt.Errorf("invalid time %q, want HH:MM or a delay like 2h", spec)
+	}
+	if delay > MaxAhead {
+		return time.Time{}, fmt.Errorf("%s is too far ahead, at most %s", spec, MaxAhead)
+	}
+	return now.Add(delay), nil
+}
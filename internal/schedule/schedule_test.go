@@ -0,0 +1,92 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func TestParseAt(t *testing.T) {
+	now := time.Date(2026, 10, 15, 18, 30, 0, 0, time.UTC)
+
+	at, err := ParseAt("22:00", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 15, 22, 0, 0, 0, time.UTC), at)
+
+	// Already past today: tomorrow
+	at, err = ParseAt("06:15", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 16, 6, 15, 0, 0, time.UTC), at)
+
+	at, err = ParseAt("18:30", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 1), at)
+
+	at, err = ParseAt("90m", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(90*time.Minute), at)
+
+	for _, spec := range []string{"25:00", "22:5", "noon", "-1h", "0s", "200h"} {
+		_, err := ParseAt(spec, now)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	now := time.Now()
+	s := New("")
+	require.NoError(t, s.Add(&queue.Job{ID: "late", UserID: 1}, now.Add(time.Hour)))
+	require.NoError(t, s.Add(&queue.Job{ID: "second", UserID: 1}, now.Add(-time.Minute)))
+	require.NoError(t, s.Add(&queue.Job{ID: "first", UserID: 2}, now.Add(-time.Hour)))
+
+	due := s.Due(now)
+	require.Len(t, due, 2)
+	assert.Equal(t, "first", due[0].Job.ID)
+	assert.Equal(t, "second", due[1].Job.ID)
+	assert.Empty(t, s.Due(now))
+	assert.Len(t, s.List(1), 1)
+}
+
+func TestScheduleUserLimit(t *testing.T) {
+	s := New("")
+	at := time.Now().Add(time.Hour)
+	for i := 0; i < MaxPerUser; i++ {
+		require.NoError(t, s.Add(&queue.Job{ID: queue.NewJobID(), UserID: 1}, at))
+	}
+	assert.ErrorIs(t, s.Add(&queue.Job{ID: queue.NewJobID(), UserID: 1}, at), ErrUserLimit)
+	assert.NoError(t, s.Add(&queue.Job{ID: queue.NewJobID(), UserID: 2}, at))
+}
+
+func TestScheduleCancel(t *testing.T) {
+	s := New("")
+	require.NoError(t, s.Add(&queue.Job{ID: "a", UserID: 1}, time.Now().Add(time.Hour)))
+
+	assert.False(t, s.Cancel(2, "a"), "other users can't cancel")
+	assert.True(t, s.Cancel(1, "a"))
+	assert.False(t, s.Cancel(1, "a"))
+	assert.Empty(t, s.List(1))
+}
+
+func TestSchedulePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	at := time.Date(2026, 10, 15, 22, 0, 0, 0, time.UTC)
+	s := New(path)
+	require.NoError(t, s.Add(&queue.Job{ID: "a", UserID: 1, URL: "https://example.com/v"}, at))
+
+	reloaded := New(path)
+	list := reloaded.List(1)
+	require.Len(t, list, 1)
+	assert.Equal(t, "https://example.com/v", list[0].Job.URL)
+	assert.True(t, at.Equal(list[0].At))
+}
@@ -0,0 +1,199 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+// runtimeDirPrefix names the private runtime directories, followed by the
+// PID of the process that owns one.
+const runtimeDirPrefix = "sushe-secrets-"
+
+// Files hands credential files (cookies, netrc) to subprocesses. With a key,
+// the files stay encrypted on disk: a decrypted copy lives in a private
+// runtime directory while the bot runs and is sealed back after every
+// subprocess that used it (see Seal) and on Close, so cookies refreshed by
+// yt-dlp are kept. Without a key the files are used in place, but never
+// with group or world permissions.
+type Files struct {
+	key []byte
+	dir string // private runtime directory, created on first use
+
+	mu     sync.Mutex
+	copies map[string]*runtimeCopy // by runtime copy path
+}
+
+// runtimeCopy is the decrypted copy of an encrypted source.
+type runtimeCopy struct {
+	src    string
+	sealed [sha256.Size]byte // hash of the plaintext last sealed into src
+}
+
+// NewFiles returns a credential file store; key may be nil.
+func NewFiles(key []byte) *Files {
+	return &Files{key: key, copies: make(map[string]*runtimeCopy)}
+}
+
+// Open returns the path a subprocess should read for the credential file at
+// src. A plain file is encrypted in place when a key is configured.
+func (f *Files) Open(src string) (string, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", src, err)
+	}
+
+	if !IsEncrypted(data) {
+		if f.key == nil {
+			if err := restrictMode(src); err != nil {
+				return "", err
+			}
+			return src, nil
+		}
+		sealed, err := Encrypt(f.key, data)
+		if err != nil {
+			return "", err
+		}
+		if err := store.WriteFileAtomic(src, sealed, 0600); err != nil {
+			return "", err
+		}
+		logger.Info("Encrypted credential file at rest", "file", src)
+	} else {
+		if f.key == nil {
+			return "", fmt.Errorf("%s: %w", src, ErrNoKey)
+		}
+		if data, err = Decrypt(f.key, data); err != nil {
+			return "", fmt.Errorf("%s: %w", src, err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dir == "" {
+		// MkdirTemp creates the directory with mode 0700
+		prefix := fmt.Sprintf("%s%d-", runtimeDirPrefix, os.Getpid())
+		if f.dir, err = os.MkdirTemp("", prefix); err != nil {
+			return "", fmt.Errorf("failed to create runtime secrets dir: %w", err)
+		}
+	}
+	copyPath := filepath.Join(f.dir, filepath.Base(src))
+	if err := os.WriteFile(copyPath, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", copyPath, err)
+	}
+	f.copies[copyPath] = &runtimeCopy{src: src, sealed: sha256.Sum256(data)}
+	return copyPath, nil
+}
+
+// Seal writes the runtime copies that changed since they were last sealed
+// back into their encrypted sources, so cookies a subprocess refreshed
+// survive a crash. Call it after every subprocess that used a copy.
+func (f *Files) Seal() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sealLocked()
+}
+
+// Close seals the runtime copies back into their encrypted sources and
+// removes the runtime directory.
+func (f *Files) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dir == "" {
+		return nil
+	}
+
+	err := f.sealLocked()
+	if rmErr := os.RemoveAll(f.dir); rmErr != nil && err == nil {
+		err = rmErr
+	}
+	f.dir = ""
+	f.copies = make(map[string]*runtimeCopy)
+	return err
+}
+
+func (f *Files) sealLocked() error {
+	var firstErr error
+	for copyPath, c := range f.copies {
+		data, err := os.ReadFile(copyPath)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to read %s: %w", copyPath, err)
+			}
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if sum == c.sealed {
+			continue
+		}
+		sealed, err := Encrypt(f.key, data)
+		if err == nil {
+			err = store.WriteFileAtomic(c.src, sealed, 0600)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to save %s: %w", c.src, err)
+			}
+			continue
+		}
+		c.sealed = sum
+	}
+	return firstErr
+}
+
+// RemoveStale deletes the runtime directories of sushe processes that are
+// no longer running, so decrypted copies don't outlive a crash. The
+// encrypted sources were kept up to date by Seal.
+func RemoveStale() {
+	dirs, err := filepath.Glob(filepath.Join(os.TempDir(), runtimeDirPrefix+"*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if ownerRunning(filepath.Base(dir)) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Warn("Failed to remove stale credential copies", "dir", dir, "error", err)
+			continue
+		}
+		logger.Info("Removed stale credential copies", "dir", dir)
+	}
+}
+
+// ownerRunning reports whether the process that created the runtime
+// directory name is another live process. Directories of this process's
+// PID are from an earlier run: RemoveStale runs before Open.
+func ownerRunning(name string) bool {
+	pidText, _, _ := strings.Cut(strings.TrimPrefix(name, runtimeDirPrefix), "-")
+	pid, err := strconv.Atoi(pidText)
+	if err != nil || pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	err = syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// restrictMode drops group and world permissions from a credential file.
+func restrictMode(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if info.Mode().Perm()&0077 == 0 {
+		return nil
+	}
+	logger.Warn("Credential file was readable by others, restricting to owner", "file", path, "mode", info.Mode().Perm())
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to chmod %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Package secrets reads credentials from the environment or from mounted
+// secret files, and keeps credential files (cookies, netrc) encrypted at rest.
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyEnv names the key used to encrypt credential files at rest: 32 random
+// bytes, hex or base64 encoded. Like every secret it can also be given as
+// KeyEnv+"_FILE".
+const KeyEnv = "SUSHE_SECRETS_KEY"
+
+// keySize is the AES-256 key length.
+const keySize = 32
+
+// header marks files written by Encrypt.
+var header = []byte("sushe-secret-v1\n")
+
+// ErrNoKey is returned when an encrypted file is read without a key.
+var ErrNoKey = errors.New("file is encrypted but " + KeyEnv + " is not set")
+
+// Get returns the secret named key. If key+"_FILE" is set, the secret is read
+// from that file (a Docker or Kubernetes secrets mount) and takes precedence
+// over the plain environment variable. Surrounding whitespace is trimmed.
+// An unset secret returns "" and no error.
+func Get(key string) (string, error) {
+	if path := strings.TrimSpace(os.Getenv(key + "_FILE")); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s_FILE: %w", key, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return strings.TrimSpace(os.Getenv(key)), nil
+}
+
+// Key returns the at-rest encryption key from SUSHE_SECRETS_KEY, or nil if
+// none is configured. Passphrases are refused: without a slow key
+// derivation they are cheap to guess from a stolen file, so the key must
+// be random, e.g. from `openssl rand -hex 32`.
+func Key() ([]byte, error) {
+	encoded, err := Get(KeyEnv)
+	if err != nil || encoded == "" {
+		return nil, err
+	}
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == keySize {
+		return key, nil
+	}
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if key, err := enc.DecodeString(encoded); err == nil && len(key) == keySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%s must be %d random bytes, hex or base64 encoded (e.g. openssl rand -hex 32)", KeyEnv, keySize)
+}
+
+// IsEncrypted reports whether data was produced by Encrypt.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, header)
+}
+
+// Encrypt seals plaintext with AES-256-GCM under a 32-byte key.
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append(append([]byte{}, header...), nonce...)
+	return gcm.Seal(out, nonce, plaintext, header), nil
+}
+
+// Decrypt opens data produced by Encrypt.
+func Decrypt(key, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, header) {
+		return nil, errors.New("not an encrypted secret")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(header):]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted secret is truncated")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (wrong %s?): %w", KeyEnv, err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
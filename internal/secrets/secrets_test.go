@@ -0,0 +1,166 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func testKey() []byte {
+	return []byte("0123456789abcdef0123456789abcdef")
+}
+
+func TestGet(t *testing.T) {
+	t.Setenv("SUSHE_TEST_SECRET", " from-env ")
+	v, err := Get("SUSHE_TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", v)
+
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+	t.Setenv("SUSHE_TEST_SECRET_FILE", path)
+	v, err = Get("SUSHE_TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", v, "_FILE takes precedence")
+
+	t.Setenv("SUSHE_TEST_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, err = Get("SUSHE_TEST_SECRET")
+	assert.Error(t, err)
+}
+
+func TestKey(t *testing.T) {
+	t.Setenv(KeyEnv, "")
+	key, err := Key()
+	require.NoError(t, err)
+	assert.Nil(t, key, "no key configured")
+
+	t.Setenv(KeyEnv, hex.EncodeToString(testKey()))
+	key, err = Key()
+	require.NoError(t, err)
+	assert.Equal(t, testKey(), key)
+
+	t.Setenv(KeyEnv, base64.StdEncoding.EncodeToString(testKey()))
+	key, err = Key()
+	require.NoError(t, err)
+	assert.Equal(t, testKey(), key)
+
+	t.Setenv(KeyEnv, "correct horse battery staple")
+	_, err = Key()
+	assert.Error(t, err, "passphrases are refused")
+
+	t.Setenv(KeyEnv, hex.EncodeToString(testKey()[:16]))
+	_, err = Key()
+	assert.Error(t, err, "short keys are refused")
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	sealed, err := Encrypt(testKey(), []byte("cookie"))
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(sealed))
+	assert.NotContains(t, string(sealed), "cookie")
+
+	plain, err := Decrypt(testKey(), sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "cookie", string(plain))
+
+	_, err = Decrypt([]byte("fedcba9876543210fedcba9876543210"), sealed)
+	assert.Error(t, err, "wrong key")
+}
+
+func TestFilesPlain(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "cookies.txt")
+	require.NoError(t, os.WriteFile(src, []byte("cookie"), 0644))
+
+	files := NewFiles(nil)
+	path, err := files.Open(src)
+	require.NoError(t, err)
+	assert.Equal(t, src, path, "plain files are used in place")
+
+	info, err := os.Stat(src)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.NoError(t, files.Close())
+}
+
+func TestFilesEncrypted(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "cookies.txt")
+	require.NoError(t, os.WriteFile(src, []byte("cookie"), 0600))
+
+	files := NewFiles(testKey())
+	path, err := files.Open(src)
+	require.NoError(t, err)
+	assert.NotEqual(t, src, path)
+
+	onDisk, err := os.ReadFile(src)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(onDisk), "plain source is encrypted in place")
+
+	runtime, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "cookie", string(runtime))
+
+	// Unchanged copies aren't rewritten
+	require.NoError(t, files.Seal())
+	sealed, err := os.ReadFile(src)
+	require.NoError(t, err)
+	assert.Equal(t, onDisk, sealed)
+
+	// Changes made by a subprocess are sealed back by Seal
+	require.NoError(t, os.WriteFile(path, []byte("refreshed"), 0600))
+	require.NoError(t, files.Seal())
+	assert.Equal(t, "refreshed", decryptFile(t, src))
+
+	// and on Close
+	require.NoError(t, os.WriteFile(path, []byte("refreshed again"), 0600))
+	require.NoError(t, files.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "runtime copy is removed")
+	assert.Equal(t, "refreshed again", decryptFile(t, src))
+}
+
+func decryptFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	plain, err := Decrypt(testKey(), data)
+	require.NoError(t, err)
+	return string(plain)
+}
+
+func TestRemoveStale(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	dead := filepath.Join(os.TempDir(), runtimeDirPrefix+"notapid-1")
+	own := filepath.Join(os.TempDir(), fmt.Sprintf("%s%d-1", runtimeDirPrefix, os.Getpid()))
+	live := filepath.Join(os.TempDir(), fmt.Sprintf("%s%d-1", runtimeDirPrefix, os.Getppid()))
+	for _, dir := range []string{dead, own, live} {
+		require.NoError(t, os.Mkdir(dir, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "cookies.txt"), []byte("cookie"), 0600))
+	}
+
+	RemoveStale()
+	assert.NoDirExists(t, dead)
+	assert.NoDirExists(t, own, "left by an earlier run with this PID")
+	assert.DirExists(t, live, "another running process")
+}
+
+func TestFilesEncryptedWithoutKey(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "cookies.txt")
+	sealed, err := Encrypt(testKey(), []byte("cookie"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(src, sealed, 0600))
+
+	_, err = NewFiles(nil).Open(src)
+	assert.ErrorIs(t, err, ErrNoKey)
+}
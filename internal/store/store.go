@@ -0,0 +1,126 @@
+// Package store persists small bot state (job queue, caches, settings) as
+// JSON files in the data directory.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/fitz123/sushe/internal/config"
+)
+
+// DefaultDataDir is used when SUSHE_DATA_DIR is not set. Relative paths
+// resolve against the bot's working directory.
+const DefaultDataDir = "data"
+
+// Path returns the location of a state file inside the data directory
+// (SUSHE_DATA_DIR, default "data").
+func Path(name string) string {
+	return filepath.Join(config.String("SUSHE_DATA_DIR", DefaultDataDir), name)
+}
+
+// LoadJSON decodes the JSON file at path into v. A missing file is not an
+// error: v is left untouched and LoadJSON returns nil.
+func LoadJSON(path string, v any) error {
+	_, err := loadJSON(path, v)
+	return err
+}
+
+// loadJSON is LoadJSON also reporting whether the file exists.
+func loadJSON(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return true, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return true, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// Update changes the state file at path under its Lock: *v, which must be
+// a pointer, is replaced by the file's contents (and kept as it is without
+// a file), fn changes it, and the result is saved with SaveJSON. Changes
+// another process made since v was loaded are kept rather than
+// overwritten. If the file can't be read, v and the file are left as they
+// were; if fn fails, nothing is saved. An empty path only runs fn.
+func Update(path string, v any, fn func() error) error {
+	if path == "" {
+		return fn()
+	}
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current := reflect.ValueOf(v).Elem()
+	fresh := reflect.New(current.Type())
+	found, err := loadJSON(path, fresh.Interface())
+	if err != nil {
+		return err
+	}
+	if found {
+		current.Set(fresh.Elem())
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return SaveJSON(path, v)
+}
+
+// SaveJSON writes v to path atomically (see WriteFileAtomic), so a crash
+// mid-write never leaves a truncated file behind.
+func SaveJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return WriteFileAtomic(path, data, 0600)
+}
+
+// WriteFileAtomic replaces path with data: it is written to a temp file in
+// the same directory, synced and renamed over the target, and the directory
+// is synced. Readers see either the old or the new content, never a mix.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to chmod %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sample struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestSaveAndLoadJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+	require.NoError(t, SaveJSON(path, sample{Name: "a", Count: 2}))
+
+	var got sample
+	require.NoError(t, LoadJSON(path, &got))
+	assert.Equal(t, sample{Name: "a", Count: 2}, got)
+
+	// No temp files left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLoadJSONMissingFile(t *testing.T) {
+	got := sample{Name: "unchanged"}
+	require.NoError(t, LoadJSON(filepath.Join(t.TempDir(), "missing.json"), &got))
+	assert.Equal(t, "unchanged", got.Name)
+}
+
+func TestLoadJSONCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+
+	var got sample
+	assert.Error(t, LoadJSON(path, &got))
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+	require.NoError(t, WriteFileAtomic(path, []byte("new"), 0600))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temp files left behind")
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("SUSHE_DATA_DIR", "/var/lib/sushe")
+	assert.Equal(t, "/var/lib/sushe/jobs.json", Path("jobs.json"))
+
+	t.Setenv("SUSHE_DATA_DIR", "")
+	assert.Equal(t, filepath.Join(DefaultDataDir, "jobs.json"), Path("jobs.json"))
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	unlock, err := Lock(path)
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock2, err := Lock(path)
+		if err == nil {
+			unlock2()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second Lock acquired while the first was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second Lock not acquired after unlock")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prefs.json")
+
+	// Without a file, the state in memory is the starting point
+	prefs := map[string]int{"a": 1}
+	require.NoError(t, Update(path, &prefs, func() error {
+		prefs["b"] = 2
+		return nil
+	}))
+
+	// Another process changes the file: the next update starts from it
+	require.NoError(t, SaveJSON(path, map[string]int{"a": 1, "b": 2, "c": 3}))
+	require.NoError(t, Update(path, &prefs, func() error {
+		delete(prefs, "a")
+		return nil
+	}))
+	assert.Equal(t, map[string]int{"b": 2, "c": 3}, prefs)
+
+	var saved map[string]int
+	require.NoError(t, LoadJSON(path, &saved))
+	assert.Equal(t, prefs, saved)
+
+	// A failing fn saves nothing
+	err := Update(path, &prefs, func() error {
+		prefs["d"] = 4
+		return errors.New("nope")
+	})
+	assert.EqualError(t, err, "nope")
+	saved = nil
+	require.NoError(t, LoadJSON(path, &saved))
+	assert.NotContains(t, saved, "d")
+}
+
+func TestUpdateCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prefs.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+
+	prefs := map[string]int{"a": 1}
+	called := false
+	err := Update(path, &prefs, func() error {
+		called = true
+		return nil
+	})
+	assert.Error(t, err)
+	assert.False(t, called)
+	assert.Equal(t, map[string]int{"a": 1}, prefs)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{not json", string(data), "left for the operator to fix")
+}
@@ -0,0 +1,224 @@
+package subscription
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Subscription is a single watched channel or feed.
+type Subscription struct {
+	URL   string
+	Title string
+}
+
+// opmlDoc mirrors the subset of OPML 2.0 used by feed readers and watcher tools.
+type opmlDoc struct {
+	XMLName xml.Name    `xml:"opml"`
+	Version string      `xml:"version,attr"`
+	Title   string      `xml:"head>title"`
+	Body    opmlOutline `xml:"body"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr,omitempty"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	URL      string        `xml:"url,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// Parse detects the file format from the file name (falling back to content
+// sniffing) and parses subscriptions from it.
+func Parse(r io.Reader, fileName string) ([]Subscription, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriptions file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".opml", ".xml":
+		return ParseOPML(bytes.NewReader(data))
+	case ".csv":
+		return ParseCSV(bytes.NewReader(data))
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "<") {
+		return ParseOPML(bytes.NewReader(data))
+	}
+	return ParseCSV(bytes.NewReader(data))
+}
+
+// ParseOPML extracts subscriptions from an OPML document. Nested outlines
+// (folders) are flattened. The feed URL (xmlUrl) is preferred over htmlUrl.
+func ParseOPML(r io.Reader) ([]Subscription, error) {
+	var doc opmlDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid OPML: %w", err)
+	}
+
+	var subs []Subscription
+	var walk func(outlines []opmlOutline)
+	walk = func(outlines []opmlOutline) {
+		for _, o := range outlines {
+			url := firstNonEmpty(o.XMLURL, o.HTMLURL, o.URL)
+			if url != "" {
+				subs = append(subs, Subscription{URL: url, Title: firstNonEmpty(o.Title, o.Text)})
+			}
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Body.Outlines)
+
+	return normalize(subs)
+}
+
+// ParseCSV extracts subscriptions from a CSV file. A header row is optional;
+// when present, the URL and title columns are located by name (e.g. YouTube
+// Takeout's "Channel Url,Channel Title"). Without a header, the first column
+// is the URL and the second (optional) is the title.
+func ParseCSV(r io.Reader) ([]Subscription, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty subscriptions file")
+	}
+
+	urlCol, titleCol := 0, 1
+	if !isHTTPURL(records[0][0]) {
+		urlCol, titleCol = -1, -1
+		for i, h := range records[0] {
+			h = strings.ToLower(strings.TrimSpace(h))
+			switch {
+			case urlCol == -1 && (strings.Contains(h, "url") || h == "link" || h == "feed"):
+				urlCol = i
+			case titleCol == -1 && (strings.Contains(h, "title") || h == "name"):
+				titleCol = i
+			}
+		}
+		if urlCol == -1 {
+			return nil, errors.New("CSV header has no URL column")
+		}
+		records = records[1:]
+	}
+
+	var subs []Subscription
+	for _, rec := range records {
+		if urlCol >= len(rec) {
+			continue
+		}
+		sub := Subscription{URL: rec[urlCol]}
+		if titleCol >= 0 && titleCol < len(rec) {
+			sub.Title = rec[titleCol]
+		}
+		subs = append(subs, sub)
+	}
+
+	return normalize(subs)
+}
+
+// WriteOPML writes subscriptions as an OPML 2.0 document.
+func WriteOPML(w io.Writer, subs []Subscription) error {
+	doc := opmlDoc{Version: "2.0", Title: "Sushe subscriptions"}
+	for _, s := range subs {
+		title := firstNonEmpty(s.Title, s.URL)
+		doc.Body.Outlines = append(doc.Body.Outlines, opmlOutline{
+			Text:   title,
+			Title:  title,
+			Type:   "rss",
+			XMLURL: s.URL,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode OPML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteCSV writes subscriptions as a two-column CSV with a "url,title" header.
+func WriteCSV(w io.Writer, subs []Subscription) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"url", "title"})
+	for _, s := range subs {
+		cw.Write([]string{s.URL, s.Title})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ChannelURL turns a YouTube feed URL, which feed readers export
+// (youtube.com/feeds/videos.xml?channel_id=... or ?playlist_id=...), into
+// the channel or playlist page yt-dlp lists. Other URLs are returned as is.
+func ChannelURL(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Path != "/feeds/videos.xml" ||
+		!(u.Hostname() == "youtube.com" || strings.HasSuffix(u.Hostname(), ".youtube.com")) {
+		return feedURL
+	}
+	q := u.Query()
+	if id := q.Get("channel_id"); id != "" {
+		return "https://www.youtube.com/channel/" + url.PathEscape(id)
+	}
+	if id := q.Get("playlist_id"); id != "" {
+		return "https://www.youtube.com/playlist?list=" + url.QueryEscape(id)
+	}
+	return feedURL
+}
+
+// normalize trims fields, drops non-http(s) entries and removes duplicate URLs
+// while preserving order.
+func normalize(subs []Subscription) ([]Subscription, error) {
+	seen := make(map[string]struct{}, len(subs))
+	out := make([]Subscription, 0, len(subs))
+	for _, s := range subs {
+		s.URL = strings.TrimSpace(s.URL)
+		s.Title = strings.TrimSpace(s.Title)
+		if !isHTTPURL(s.URL) {
+			continue
+		}
+		if _, dup := seen[s.URL]; dup {
+			continue
+		}
+		seen[s.URL] = struct{}{}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no valid subscription URLs found")
+	}
+	return out, nil
+}
+
+func isHTTPURL(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
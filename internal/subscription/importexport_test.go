@@ -0,0 +1,122 @@
+package subscription
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>Exported</title></head>
+  <body>
+    <outline text="YouTube">
+      <outline text="Chan A" title="Channel A" type="rss" xmlUrl="https://www.youtube.com/feeds/videos.xml?channel_id=A"/>
+      <outline text="Chan B" htmlUrl="https://www.youtube.com/@b"/>
+    </outline>
+    <outline text="Dup" xmlUrl="https://www.youtube.com/feeds/videos.xml?channel_id=A"/>
+    <outline text="Bad" xmlUrl="ftp://example.com/feed"/>
+  </body>
+</opml>`
+
+func TestParseOPML(t *testing.T) {
+	subs, err := ParseOPML(strings.NewReader(sampleOPML))
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	assert.Equal(t, Subscription{URL: "https://www.youtube.com/feeds/videos.xml?channel_id=A", Title: "Channel A"}, subs[0])
+	assert.Equal(t, Subscription{URL: "https://www.youtube.com/@b", Title: "Chan B"}, subs[1])
+}
+
+func TestParseOPMLInvalid(t *testing.T) {
+	_, err := ParseOPML(strings.NewReader("not xml"))
+	assert.Error(t, err)
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Subscription
+	}{
+		{
+			"no header",
+			"https://a.example/feed,Feed A\nhttps://b.example/feed\n",
+			[]Subscription{{URL: "https://a.example/feed", Title: "Feed A"}, {URL: "https://b.example/feed"}},
+		},
+		{
+			"url,title header",
+			"url,title\nhttps://a.example/feed,Feed A\n",
+			[]Subscription{{URL: "https://a.example/feed", Title: "Feed A"}},
+		},
+		{
+			"youtube takeout",
+			"Channel Id,Channel Url,Channel Title\nUC1,http://www.youtube.com/channel/UC1,Some Channel\n",
+			[]Subscription{{URL: "http://www.youtube.com/channel/UC1", Title: "Some Channel"}},
+		},
+		{
+			"duplicates and invalid rows dropped",
+			"https://a.example/feed\nhttps://a.example/feed\nnot-a-url\n",
+			[]Subscription{{URL: "https://a.example/feed"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCSV(strings.NewReader(tt.input))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader(""))
+	assert.Error(t, err)
+
+	_, err = ParseCSV(strings.NewReader("name,comment\nfoo,bar\n"))
+	assert.Error(t, err)
+}
+
+func TestParseDetectsFormat(t *testing.T) {
+	subs, err := Parse(strings.NewReader(sampleOPML), "subs.txt")
+	require.NoError(t, err)
+	assert.Len(t, subs, 2)
+
+	subs, err = Parse(strings.NewReader("https://a.example/feed\n"), "export")
+	require.NoError(t, err)
+	assert.Len(t, subs, 1)
+}
+
+func TestRoundTrip(t *testing.T) {
+	subs := []Subscription{
+		{URL: "https://a.example/feed", Title: "Feed, A"},
+		{URL: "https://b.example/feed?x=1&y=2", Title: ""},
+	}
+
+	var opml bytes.Buffer
+	require.NoError(t, WriteOPML(&opml, subs))
+	got, err := Parse(&opml, "subs.opml")
+	require.NoError(t, err)
+	assert.Equal(t, []Subscription{
+		{URL: "https://a.example/feed", Title: "Feed, A"},
+		{URL: "https://b.example/feed?x=1&y=2", Title: "https://b.example/feed?x=1&y=2"},
+	}, got)
+
+	var csvBuf bytes.Buffer
+	require.NoError(t, WriteCSV(&csvBuf, subs))
+	got, err = Parse(&csvBuf, "subs.csv")
+	require.NoError(t, err)
+	assert.Equal(t, subs, got)
+}
+
+func TestChannelURL(t *testing.T) {
+	assert.Equal(t, "https://www.youtube.com/channel/UC123",
+		ChannelURL("https://www.youtube.com/feeds/videos.xml?channel_id=UC123"))
+	assert.Equal(t, "https://www.youtube.com/playlist?list=PL1",
+		ChannelURL("https://youtube.com/feeds/videos.xml?playlist_id=PL1"))
+	assert.Equal(t, "https://www.youtube.com/@b", ChannelURL("https://www.youtube.com/@b"))
+	assert.Equal(t, "https://example.com/feeds/videos.xml?channel_id=X",
+		ChannelURL("https://example.com/feeds/videos.xml?channel_id=X"))
+}
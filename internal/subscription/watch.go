@@ -0,0 +1,203 @@
+package subscription
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/fitz123/sushe/internal/store"
+)
+
+const (
+	// MaxPerUser is how many channels one user may watch.
+	MaxPerUser = 20
+
+	// DefaultInterval is how often a channel is checked unless set otherwise.
+	DefaultInterval = time.Hour
+
+	// MinInterval is the shortest allowed check interval.
+	MinInterval = 15 * time.Minute
+
+	// MaxSeen is how many uploads per channel are remembered as seen; older
+	// ones have long dropped out of the latest uploads listing.
+	MaxSeen = 500
+)
+
+var (
+	// ErrUserLimit means the user already watches MaxPerUser channels.
+	ErrUserLimit = errors.New("too many subscriptions")
+	// ErrDuplicate means the channel is already watched for that chat.
+	ErrDuplicate = errors.New("already subscribed")
+)
+
+// Watch is a channel checked periodically for new uploads (/subscribe),
+// which are downloaded and posted to ChatID.
+type Watch struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	ChatID   int64  `json:"chat_id"`
+	ThreadID int    `json:"thread_id,omitempty"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username,omitempty"`
+
+	// Quality is the queue.Job quality for its downloads, "" for the default.
+	Quality string `json:"quality,omitempty"`
+
+	// Interval is the time between checks.
+	Interval time.Duration `json:"interval"`
+
+	// Seen are the upload URLs already handled, in the order seen. It starts
+	// with the uploads listed when subscribing, which are not downloaded.
+	Seen []string `json:"seen,omitempty"`
+
+	// Checked is the time of the last check, zero before the first.
+	Checked time.Time `json:"checked,omitempty"`
+}
+
+// Watches are the subscribed channels, persisted as JSON.
+type Watches struct {
+	mu      sync.Mutex
+	path    string
+	watches []*Watch
+}
+
+// NewWatches creates the subscription list backed by path (empty disables
+// persistence).
+func NewWatches(path string) *Watches {
+	w := &Watches{path: path}
+	if path != "" {
+		if err := store.LoadJSON(path, &w.watches); err != nil {
+			logger.Warn("Failed to load subscriptions", "error", err)
+		}
+	}
+	return w
+}
+
+// Add subscribes w.UserID to w.URL. A zero Interval becomes DefaultInterval.
+func (ws *Watches) Add(w Watch) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	count := 0
+	for _, cur := range ws.watches {
+		if cur.URL == w.URL && cur.ChatID == w.ChatID && cur.ThreadID == w.ThreadID {
+			return ErrDuplicate
+		}
+		if cur.UserID == w.UserID {
+			count++
+		}
+	}
+	if count >= MaxPerUser {
+		return ErrUserLimit
+	}
+	if w.Interval == 0 {
+		w.Interval = DefaultInterval
+	}
+	ws.watches = append(ws.watches, &w)
+	ws.saveLocked()
+	return nil
+}
+
+// Update applies fn to userID's subscription id. Returns the updated
+// subscription, false if there is none.
+func (ws *Watches) Update(userID int64, id string, fn func(*Watch)) (Watch, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for _, w := range ws.watches {
+		if w.UserID == userID && w.ID == id {
+			fn(w)
+			ws.saveLocked()
+			return *w, true
+		}
+	}
+	return Watch{}, false
+}
+
+// Remove drops userID's subscription id. Returns it, false if there is none.
+func (ws *Watches) Remove(userID int64, id string) (Watch, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for i, w := range ws.watches {
+		if w.UserID == userID && w.ID == id {
+			ws.watches = append(ws.watches[:i], ws.watches[i+1:]...)
+			ws.saveLocked()
+			return *w, true
+		}
+	}
+	return Watch{}, false
+}
+
+// List returns userID's subscriptions in the order they were added.
+func (ws *Watches) List(userID int64) []Watch {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var list []Watch
+	for _, w := range ws.watches {
+		if w.UserID == userID {
+			list = append(list, *w)
+		}
+	}
+	return list
+}
+
+// Due returns the subscriptions whose next check time has come.
+func (ws *Watches) Due(now time.Time) []Watch {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var due []Watch
+	for _, w := range ws.watches {
+		if w.Checked.IsZero() || !now.Before(w.Checked.Add(w.Interval)) {
+			due = append(due, *w)
+		}
+	}
+	return due
+}
+
+// Checked records a check of subscription id that listed urls and returns
+// those not seen before, in the given order.
+func (ws *Watches) Checked(id string, urls []string, now time.Time) []string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var w *Watch
+	for _, cur := range ws.watches {
+		if cur.ID == id {
+			w = cur
+			break
+		}
+	}
+	if w == nil {
+		// Unsubscribed during the check
+		return nil
+	}
+
+	seen := make(map[string]bool, len(w.Seen))
+	for _, url := range w.Seen {
+		seen[url] = true
+	}
+	var fresh []string
+	for _, url := range urls {
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		w.Seen = append(w.Seen, url)
+		fresh = append(fresh, url)
+	}
+	if len(w.Seen) > MaxSeen {
+		w.Seen = w.Seen[len(w.Seen)-MaxSeen:]
+	}
+
+	w.Checked = now
+	ws.saveLocked()
+	return fresh
+}
+
+// saveLocked persists the subscriptions. Must hold ws.mu.
+func (ws *Watches) saveLocked() {
+	if ws.path == "" {
+		return
+	}
+	if err := store.SaveJSON(ws.path, ws.watches); err != nil {
+		logger.Warn("Failed to save subscriptions", "error", err)
+	}
+}
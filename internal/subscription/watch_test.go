@@ -0,0 +1,88 @@
+package subscription
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+func TestWatchesAdd(t *testing.T) {
+	ws := NewWatches("")
+	require.NoError(t, ws.Add(Watch{ID: "a", URL: "https://youtube.com/@a", ChatID: 1, UserID: 1}))
+	assert.ErrorIs(t, ws.Add(Watch{ID: "b", URL: "https://youtube.com/@a", ChatID: 1, UserID: 2}), ErrDuplicate)
+
+	// Same channel into another chat is fine
+	require.NoError(t, ws.Add(Watch{ID: "c", URL: "https://youtube.com/@a", ChatID: 2, UserID: 1}))
+	list := ws.List(1)
+	require.Len(t, list, 2)
+	assert.Equal(t, DefaultInterval, list[0].Interval)
+
+	for i := len(list); i < MaxPerUser; i++ {
+		require.NoError(t, ws.Add(Watch{ID: "x", URL: "https://youtube.com/@x", ChatID: int64(100 + i), UserID: 1}))
+	}
+	assert.ErrorIs(t, ws.Add(Watch{ID: "y", URL: "https://youtube.com/@y", ChatID: 1, UserID: 1}), ErrUserLimit)
+}
+
+func TestWatchesUpdateRemove(t *testing.T) {
+	ws := NewWatches("")
+	require.NoError(t, ws.Add(Watch{ID: "a", URL: "https://youtube.com/@a", ChatID: 1, UserID: 1}))
+
+	_, ok := ws.Update(2, "a", func(w *Watch) { w.Quality = "audio" })
+	assert.False(t, ok, "only the owner may change it")
+	w, ok := ws.Update(1, "a", func(w *Watch) { w.Quality = "audio" })
+	require.True(t, ok)
+	assert.Equal(t, "audio", w.Quality)
+
+	_, ok = ws.Remove(2, "a")
+	assert.False(t, ok)
+	w, ok = ws.Remove(1, "a")
+	require.True(t, ok)
+	assert.Equal(t, "https://youtube.com/@a", w.URL)
+	assert.Empty(t, ws.List(1))
+}
+
+func TestWatchesChecked(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	ws := NewWatches("")
+	require.NoError(t, ws.Add(Watch{ID: "a", URL: "https://youtube.com/@a", ChatID: 1, UserID: 1, Interval: time.Hour}))
+	assert.Len(t, ws.Due(now), 1, "unchecked is due")
+
+	assert.Equal(t, []string{"v2", "v1"}, ws.Checked("a", []string{"v2", "v1"}, now))
+	assert.Empty(t, ws.Due(now.Add(30*time.Minute)))
+	assert.Len(t, ws.Due(now.Add(time.Hour)), 1)
+
+	assert.Equal(t, []string{"v4", "v3"}, ws.Checked("a", []string{"v4", "v3", "v2", "v1"}, now.Add(time.Hour)))
+	assert.Empty(t, ws.Checked("a", []string{"v4", "v3", "v2"}, now.Add(2*time.Hour)))
+	assert.Nil(t, ws.Checked("gone", []string{"v5"}, now))
+
+	urls := make([]string, MaxSeen+10)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("n%d", i)
+	}
+	assert.Len(t, ws.Checked("a", urls, now.Add(3*time.Hour)), len(urls))
+	assert.Len(t, ws.List(1)[0].Seen, MaxSeen)
+}
+
+func TestWatchesPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	ws := NewWatches(path)
+	require.NoError(t, ws.Add(Watch{ID: "a", URL: "https://youtube.com/@a", ChatID: 1, UserID: 1, Quality: "720"}))
+	ws.Checked("a", []string{"v1"}, time.Now())
+
+	list := NewWatches(path).List(1)
+	require.Len(t, list, 1)
+	assert.Equal(t, "720", list[0].Quality)
+	assert.Equal(t, []string{"v1"}, list[0].Seen)
+	assert.False(t, list[0].Checked.IsZero())
+}
@@ -0,0 +1,71 @@
+package upload
+
+import (
+	tele "gopkg.in/telebot.v3"
+)
+
+// Sender sends uploads to the bot's Bot API server.
+type Sender struct {
+	bot *tele.Bot
+
+	// remote is set when the Bot API server can't read this machine's disk
+	// (the official api.telegram.org), so files must be uploaded.
+	remote bool
+
+	// pool spreads uploads over several Bot API servers, nil for none
+	// (see SetPool)
+	pool *Pool
+}
+
+// NewSender returns a sender for uploads through bot; remote selects how
+// LocalFile passes files (see there).
+func NewSender(bot *tele.Bot, remote bool) *Sender {
+	return &Sender{bot: bot, remote: remote}
+}
+
+// SetPool routes uploads through p instead of the sender's bot; nil sends
+// them through the bot. Call it before any upload starts.
+func (s *Sender) SetPool(p *Pool) {
+	s.pool = p
+}
+
+// LocalFile returns a sendable file for path. A local Bot API server (run
+// with --local on this host) gets a file:// URI and reads the file from
+// disk itself, so no file body crosses HTTP and large files can't time out
+// mid-upload. A remote server (remote set) can't see our disk: the file is
+// streamed to it as a multipart upload, which the 50MB limit of the
+// official server keeps short.
+func (s *Sender) LocalFile(path string) tele.File {
+	if s.remote {
+		return tele.FromDisk(path)
+	}
+	return tele.FromURL("file://" + path)
+}
+
+// Thumbnail returns a video thumbnail for a local JPEG, or nil if path is
+// empty. Like the video itself it is passed with LocalFile.
+func (s *Sender) Thumbnail(path string) *tele.Photo {
+	if path == "" {
+		return nil
+	}
+	return &tele.Photo{File: s.LocalFile(path)}
+}
+
+// Send sends what to the recipient like SendWithRetry, through the pool's
+// best server if there is one.
+func (s *Sender) Send(to tele.Recipient, what interface{}, opts ...interface{}) (*tele.Message, error) {
+	return Retry(func() (*tele.Message, error) {
+		return s.Through(func(b *tele.Bot) (*tele.Message, error) {
+			return b.Send(to, what, opts...)
+		})
+	})
+}
+
+// Through runs send with the bot an upload should go through: a pool
+// server if SetPool was called, else the sender's bot.
+func (s *Sender) Through(send func(b *tele.Bot) (*tele.Message, error)) (*tele.Message, error) {
+	if s.pool != nil {
+		return s.pool.Do(send)
+	}
+	return send(s.bot)
+}
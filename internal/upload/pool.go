@@ -0,0 +1,126 @@
+package upload
+
+import (
+	"errors"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	tele "gopkg.in/telebot.v3"
+)
+
+// DefaultHealthInterval is how often Pool.Run checks the servers.
+const DefaultHealthInterval = 30 * time.Second
+
+// Pool spreads uploads over several local Bot API servers running for the
+// same bot, e.g. redundant servers near different Telegram datacenters.
+// Each upload goes to the healthy server with the fewest uploads in flight
+// (the fastest to answer on a tie); one that can't be reached is marked
+// down and the upload moves on to the next server.
+type Pool struct {
+	endpoints []*endpoint
+}
+
+type endpoint struct {
+	bot      *tele.Bot
+	down     atomic.Bool
+	inflight atomic.Int32
+	latency  atomic.Int64 // last health check round trip, ns
+}
+
+// NewPool creates a pool of bots, each talking to a different Bot API
+// server. All start out healthy.
+func NewPool(bots ...*tele.Bot) *Pool {
+	p := &Pool{}
+	for _, b := range bots {
+		p.endpoints = append(p.endpoints, &endpoint{bot: b})
+	}
+	return p
+}
+
+// Do runs send with the best server, failing over to the others while
+// servers can't be reached.
+func (p *Pool) Do(send func(b *tele.Bot) (*tele.Message, error)) (*tele.Message, error) {
+	tried := make(map[*endpoint]bool)
+	var lastErr error
+	for e := p.pick(tried); e != nil; e = p.pick(tried) {
+		tried[e] = true
+		e.inflight.Add(1)
+		msg, err := send(e.bot)
+		e.inflight.Add(-1)
+		if !unreachable(err) {
+			return msg, err
+		}
+		lastErr = err
+		if !e.down.Swap(true) {
+			logger.Warn("Bot API server down, failing over", "url", e.bot.URL, "error", err)
+		}
+	}
+	return nil, lastErr
+}
+
+// pick returns the server for the next upload among those not tried: the
+// healthy one with the fewest uploads in flight, then the lowest latency.
+// Servers marked down are only picked when no healthy one is left.
+func (p *Pool) pick(tried map[*endpoint]bool) *endpoint {
+	var best *endpoint
+	better := func(e *endpoint) bool {
+		switch {
+		case best == nil:
+			return true
+		case e.down.Load() != best.down.Load():
+			return !e.down.Load()
+		case e.inflight.Load() != best.inflight.Load():
+			return e.inflight.Load() < best.inflight.Load()
+		}
+		return e.latency.Load() < best.latency.Load()
+	}
+	for _, e := range p.endpoints {
+		if !tried[e] && better(e) {
+			best = e
+		}
+	}
+	return best
+}
+
+// unreachable reports whether err means the server itself couldn't be
+// reached, rather than Telegram refusing the request. Timeouts don't
+// count: the upload may have gone through.
+func unreachable(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !urlErr.Timeout()
+}
+
+// Check asks every server for the bot's profile, marking those that answer
+// up and the rest down.
+func (p *Pool) Check() {
+	for _, e := range p.endpoints {
+		start := time.Now()
+		_, err := e.bot.Raw("getMe", nil)
+		if err == nil {
+			e.latency.Store(int64(time.Since(start)))
+			if e.down.Swap(false) {
+				logger.Info("Bot API server back up", "url", e.bot.URL)
+			}
+			continue
+		}
+		if !e.down.Swap(true) {
+			logger.Warn("Bot API server failed health check", "url", e.bot.URL, "error", err)
+		}
+	}
+}
+
+// Run checks the servers every interval until stop is closed.
+func (p *Pool) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.Check()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
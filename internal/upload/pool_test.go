@@ -0,0 +1,125 @@
+package upload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tele "gopkg.in/telebot.v3"
+)
+
+// fakeAPI is a Bot API server answering every method, counting sends.
+func fakeAPI(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var sends atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			rw.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"sushe"}}`))
+			return
+		}
+		sends.Add(1)
+		rw.Write([]byte(`{"ok":true,"result":{"message_id":7,"chat":{"id":42}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &sends
+}
+
+func offlineBot(t *testing.T, url string) *tele.Bot {
+	b, err := tele.NewBot(tele.Settings{Token: "1:x", URL: url, Offline: true})
+	require.NoError(t, err)
+	return b
+}
+
+func sendText(b *tele.Bot) (*tele.Message, error) {
+	return b.Send(&tele.Chat{ID: 42}, "hi")
+}
+
+func TestPoolFailover(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	live, sends := fakeAPI(t)
+
+	p := NewPool(offlineBot(t, dead.URL), offlineBot(t, live.URL))
+	msg, err := p.Do(sendText)
+	require.NoError(t, err)
+	assert.Equal(t, 7, msg.ID)
+	assert.EqualValues(t, 1, sends.Load())
+	assert.True(t, p.endpoints[0].down.Load(), "unreachable server is marked down")
+
+	// Down servers are skipped while a healthy one is left
+	_, err = p.Do(sendText)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, sends.Load())
+}
+
+func TestPoolAllDown(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	p := NewPool(offlineBot(t, dead.URL))
+	_, err := p.Do(sendText)
+	assert.True(t, unreachable(err))
+}
+
+func TestPoolTelegramErrorNoFailover(t *testing.T) {
+	var calls atomic.Int32
+	refusing := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		rw.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+	}))
+	defer refusing.Close()
+	live, sends := fakeAPI(t)
+
+	p := NewPool(offlineBot(t, refusing.URL), offlineBot(t, live.URL))
+	_, err := p.Do(sendText)
+	assert.Error(t, err)
+	assert.EqualValues(t, 1, calls.Load())
+	assert.Zero(t, sends.Load(), "Telegram refusing the upload isn't retried elsewhere")
+	assert.False(t, p.endpoints[0].down.Load())
+}
+
+func TestPoolPick(t *testing.T) {
+	a, b, c := offlineBot(t, "http://a"), offlineBot(t, "http://b"), offlineBot(t, "http://c")
+	p := NewPool(a, b, c)
+	p.endpoints[0].inflight.Store(2)
+	p.endpoints[1].inflight.Store(1)
+	p.endpoints[2].inflight.Store(1)
+	p.endpoints[1].latency.Store(50)
+	p.endpoints[2].latency.Store(10)
+	assert.Same(t, c, p.pick(nil).bot, "fewest in flight, then fastest")
+
+	p.endpoints[2].down.Store(true)
+	assert.Same(t, b, p.pick(nil).bot)
+	assert.Same(t, a, p.pick(map[*endpoint]bool{p.endpoints[1]: true}).bot, "healthy before down")
+}
+
+func TestPoolCheck(t *testing.T) {
+	live, _ := fakeAPI(t)
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	p := NewPool(offlineBot(t, live.URL), offlineBot(t, dead.URL))
+	p.endpoints[0].down.Store(true)
+	p.Check()
+	assert.False(t, p.endpoints[0].down.Load(), "answering server is back up")
+	assert.Positive(t, p.endpoints[0].latency.Load())
+	assert.True(t, p.endpoints[1].down.Load())
+}
+
+func TestSenderThrough(t *testing.T) {
+	live, sends := fakeAPI(t)
+	other, otherSends := fakeAPI(t)
+
+	s := NewSender(offlineBot(t, live.URL), false)
+	_, err := s.Through(sendText)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, sends.Load(), "without a pool, the sender's bot")
+
+	s.SetPool(NewPool(offlineBot(t, other.URL)))
+	_, err = s.Through(sendText)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, sends.Load())
+	assert.EqualValues(t, 1, otherSends.Load(), "with a pool, its server")
+}
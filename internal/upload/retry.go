@@ -14,8 +14,16 @@ const maxRetries = 3
 // SendWithRetry wraps bot.Send with 429/FloodError retry logic.
 // On tele.FloodError, it sleeps for RetryAfter seconds and retries up to maxRetries times.
 func SendWithRetry(bot *tele.Bot, to tele.Recipient, what interface{}, opts ...interface{}) (*tele.Message, error) {
+	return Retry(func() (*tele.Message, error) {
+		return bot.Send(to, what, opts...)
+	})
+}
+
+// Retry calls send, retrying on tele.FloodError like SendWithRetry. It lets
+// callers wrap sends other than bot.Send, e.g. to inject failures.
+func Retry(send func() (*tele.Message, error)) (*tele.Message, error) {
 	for attempt := 0; attempt <= maxRetries; attempt++ {
-		msg, err := bot.Send(to, what, opts...)
+		msg, err := send()
 		if err == nil {
 			return msg, nil
 		}
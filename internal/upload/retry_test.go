@@ -1,11 +1,20 @@
 package upload
 
 import (
+	"errors"
+	"os"
 	"testing"
 
+	"github.com/fitz123/sushe/internal/logger"
 	"github.com/stretchr/testify/assert"
+	tele "gopkg.in/telebot.v3"
 )
 
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
 // TestSendWithRetryConstants verifies the retry configuration
 func TestSendWithRetryConstants(t *testing.T) {
 	assert.Equal(t, 3, maxRetries, "maxRetries should be 3")
@@ -19,3 +28,56 @@ func TestSendWithRetryConstants(t *testing.T) {
 // - errors.As(err, &floodErr) to detect FloodError
 // - Sleep for RetryAfter seconds
 // - Max 3 retries
+
+func TestRetryFloodError(t *testing.T) {
+	calls := 0
+	msg, err := Retry(func() (*tele.Message, error) {
+		calls++
+		if calls < 3 {
+			return nil, tele.FloodError{RetryAfter: 0}
+		}
+		return &tele.Message{ID: 42}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, msg.ID)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	calls := 0
+	_, err := Retry(func() (*tele.Message, error) {
+		calls++
+		return nil, tele.FloodError{RetryAfter: 0}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, maxRetries+1, calls)
+}
+
+func TestRetryOtherError(t *testing.T) {
+	calls := 0
+	_, err := Retry(func() (*tele.Message, error) {
+		calls++
+		return nil, errors.New("bad request")
+	})
+	assert.EqualError(t, err, "bad request")
+	assert.Equal(t, 1, calls)
+}
+
+func TestThumbnail(t *testing.T) {
+	s := NewSender(nil, false)
+	assert.Nil(t, s.Thumbnail(""))
+
+	thumb := s.Thumbnail("/tmp/work/video_thumb.jpg")
+	if assert.NotNil(t, thumb) {
+		assert.Equal(t, "file:///tmp/work/video_thumb.jpg", thumb.FileURL)
+	}
+}
+
+func TestLocalFileRemoteAPI(t *testing.T) {
+	f := NewSender(nil, true).LocalFile("/tmp/work/video.mp4")
+	assert.Equal(t, "/tmp/work/video.mp4", f.FileLocal)
+	assert.Empty(t, f.FileURL)
+
+	f = NewSender(nil, false).LocalFile("/tmp/work/video.mp4")
+	assert.Equal(t, "file:///tmp/work/video.mp4", f.FileURL)
+}
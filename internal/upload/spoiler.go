@@ -0,0 +1,116 @@
+package upload
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// replaceChunkSize is how much of a multipart upload replaceReader reads at once.
+const replaceChunkSize = 32 * 1024
+
+// telebot (v3.3.8) sends SendOptions.HasSpoiler as "spoiler"; the Bot API
+// only knows "has_spoiler", so the flag would silently do nothing.
+var (
+	spoilerJSON     = []byte(`"spoiler":`)
+	hasSpoilerJSON  = []byte(`"has_spoiler":`)
+	spoilerField    = []byte(`name="spoiler"`)
+	hasSpoilerField = []byte(`name="has_spoiler"`)
+	spoilerMethods  = []string{"/sendVideo", "/sendAnimation", "/sendPhoto"}
+)
+
+// FixSpoilerParam wraps rt (nil for http.DefaultTransport) so media sends
+// carry telebot's spoiler flag under the name the Bot API reads. JSON
+// bodies (file_id and file:// sends) are rewritten in memory, multipart
+// uploads as they stream.
+func FixSpoilerParam(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return spoilerFix{next: rt}
+}
+
+type spoilerFix struct {
+	next http.RoundTripper
+}
+
+func (f spoilerFix) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || !isSpoilerMethod(req.URL.Path) {
+		return f.next.RoundTrip(req)
+	}
+	contentType := req.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.Replace(body, spoilerJSON, hasSpoilerJSON, 1)
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		req.ContentLength = int64(len(body))
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		req = req.Clone(req.Context())
+		req.Body = &replaceReader{src: req.Body, old: spoilerField, new: hasSpoilerField}
+		req.GetBody = nil
+		req.ContentLength = -1
+	}
+	return f.next.RoundTrip(req)
+}
+
+func isSpoilerMethod(path string) bool {
+	for _, m := range spoilerMethods {
+		if strings.HasSuffix(path, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceReader replaces old with new in a stream. It holds back the last
+// len(old)-1 bytes read until more arrive, since they may begin a match.
+// new must not end with a prefix of old.
+type replaceReader struct {
+	src      io.ReadCloser
+	old, new []byte
+	pending  []byte // read, replaced, but possibly the start of a match
+	out      []byte // ready to hand out
+	eof      bool
+}
+
+func (r *replaceReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.eof {
+			if len(r.pending) == 0 {
+				return 0, io.EOF
+			}
+			r.out, r.pending = r.pending, nil
+			break
+		}
+		chunk := make([]byte, replaceChunkSize)
+		n, err := r.src.Read(chunk)
+		if err == io.EOF {
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+		buf := bytes.ReplaceAll(append(r.pending, chunk[:n]...), r.old, r.new)
+		keep := len(r.old) - 1
+		if r.eof || len(buf) <= keep {
+			r.pending = buf
+			continue
+		}
+		r.out = buf[:len(buf)-keep]
+		r.pending = append([]byte(nil), buf[len(buf)-keep:]...)
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *replaceReader) Close() error {
+	return r.src.Close()
+}
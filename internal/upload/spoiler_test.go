@@ -0,0 +1,66 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tele "gopkg.in/telebot.v3"
+)
+
+// spoilerServer answers sendVideo and records its has_spoiler parameter.
+func spoilerServer(t *testing.T, got *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			*got = r.FormValue("has_spoiler")
+		} else {
+			var params map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+			*got = params["has_spoiler"]
+		}
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1},"video":{"file_id":"v"}}}`))
+	}))
+}
+
+func TestFixSpoilerParam(t *testing.T) {
+	var got string
+	srv := spoilerServer(t, &got)
+	defer srv.Close()
+	b, err := tele.NewBot(tele.Settings{Token: "1:x", URL: srv.URL, Offline: true,
+		Client: &http.Client{Transport: FixSpoilerParam(nil)}})
+	require.NoError(t, err)
+	to := &tele.Chat{ID: 1}
+
+	// By file:// URI or file_id: JSON body
+	_, err = b.Send(to, &tele.Video{File: tele.FromURL("file:///tmp/v.mp4")}, &tele.SendOptions{HasSpoiler: true})
+	require.NoError(t, err)
+	assert.Equal(t, "true", got)
+
+	// Uploaded: multipart body
+	got = ""
+	video := &tele.Video{File: tele.FromReader(bytes.NewReader(bytes.Repeat([]byte("x"), 100000))), FileName: "v.mp4"}
+	_, err = b.Send(to, video, &tele.SendOptions{HasSpoiler: true})
+	require.NoError(t, err)
+	assert.Equal(t, "true", got)
+
+	got = ""
+	_, err = b.Send(to, &tele.Video{File: tele.FromURL("file:///tmp/v.mp4")})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestReplaceReaderAcrossChunks(t *testing.T) {
+	input := strings.Repeat("a", replaceChunkSize-5) + `name="spoiler"` + strings.Repeat("b", 10) + `name="spoiler"`
+	r := &replaceReader{src: io.NopCloser(strings.NewReader(input)), old: spoilerField, new: hasSpoilerField}
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	want := strings.ReplaceAll(input, `name="spoiler"`, `name="has_spoiler"`)
+	assert.Equal(t, want, string(out))
+}
@@ -0,0 +1,193 @@
+// Package webhook posts job events as JSON to an external endpoint (n8n,
+// Home Assistant, ...), so other systems can react to bot activity without
+// polling the REST API. Delivery is asynchronous and best-effort: events are
+// queued, retried a few times and dropped if the endpoint stays down.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+)
+
+// Type is the kind of a job event.
+type Type string
+
+const (
+	Submitted    Type = "job.submitted"
+	PhaseChanged Type = "job.phase"
+	Completed    Type = "job.completed"
+	Failed       Type = "job.failed"
+	Cancelled    Type = "job.cancelled"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body when a secret is set.
+const SignatureHeader = "X-Sushe-Signature"
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultQueueSize = 256
+	maxAttempts      = 3
+)
+
+// Event is one job event as posted to the webhook.
+type Event struct {
+	Type     Type      `json:"type"`
+	Time     time.Time `json:"time"`
+	JobID    string    `json:"job_id"`
+	URL      string    `json:"url"`
+	ChatID   int64     `json:"chat_id"`
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username,omitempty"`
+	Phase    string    `json:"phase,omitempty"`    // job.phase: downloading, encoding, uploading, ...
+	Position int       `json:"position,omitempty"` // job.submitted: place in the queue, 0 if started right away
+	Error    string    `json:"error,omitempty"`    // job.failed
+}
+
+// Config selects the endpoint. An empty URL disables webhooks.
+type Config struct {
+	URL     string
+	Secret  string        // Signs each body into SignatureHeader; empty sends unsigned
+	Timeout time.Duration // Per request; 0 means 10s
+}
+
+// Sender delivers events to the webhook in the background. A nil *Sender
+// ignores events, so callers don't need to check whether webhooks are on.
+type Sender struct {
+	cfg    Config
+	client *http.Client
+	done   chan struct{}
+
+	mu     sync.RWMutex // Guards closing events against concurrent Emit
+	events chan Event
+	closed bool
+
+	retryDelay time.Duration
+}
+
+// New starts a sender for cfg, or returns nil if cfg.URL is empty.
+func New(cfg Config) *Sender {
+	if cfg.URL == "" {
+		return nil
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	s := &Sender{
+		cfg:        cfg,
+		client:     &http.Client{Timeout: cfg.Timeout},
+		events:     make(chan Event, defaultQueueSize),
+		done:       make(chan struct{}),
+		retryDelay: time.Second,
+	}
+	go s.run()
+	return s
+}
+
+// Emit queues an event without blocking. Events are dropped (and logged)
+// when the queue is full because the endpoint is slow or down.
+func (s *Sender) Emit(e Event) {
+	if s == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- e:
+	default:
+		logger.Warn("Webhook queue full, dropping event", "type", e.Type, "job", e.JobID)
+	}
+}
+
+// Close stops accepting events (later ones are ignored) and waits up to
+// timeout for queued ones to be delivered.
+func (s *Sender) Close(timeout time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(timeout):
+		logger.Warn("Webhook events still pending at shutdown", "pending", len(s.events))
+	}
+}
+
+// run delivers queued events in order until Close.
+func (s *Sender) run() {
+	defer close(s.done)
+	for e := range s.events {
+		if err := s.deliver(e); err != nil {
+			logger.Warn("Failed to deliver webhook event", "type", e.Type, "job", e.JobID, "error", err)
+		}
+	}
+}
+
+// deliver posts an event, retrying network errors and 5xx responses.
+func (s *Sender) deliver(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil || !retry || attempt == maxAttempts {
+			return err
+		}
+		time.Sleep(s.retryDelay * time.Duration(attempt))
+	}
+}
+
+// post sends one request and reports whether a failure is worth retrying.
+func (s *Sender) post(body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sushe-webhook")
+	if s.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(s.cfg.Secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Sign returns the "sha256=<hex>" HMAC of body, as sent in SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fitz123/sushe/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error")
+	os.Exit(m.Run())
+}
+
+// recorder is a webhook endpoint that fails the first failures requests.
+type recorder struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	events   []Event
+	sigs     []string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.calls <= r.failures {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	var e Event
+	json.Unmarshal(body, &e)
+	r.events = append(r.events, e)
+	r.sigs = append(r.sigs, req.Header.Get(SignatureHeader))
+	if Sign("s3cret", body) != req.Header.Get(SignatureHeader) {
+		r.sigs[len(r.sigs)-1] = "invalid"
+	}
+}
+
+func TestDeliversSignedEventsInOrder(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	s := New(Config{URL: srv.URL, Secret: "s3cret"})
+	s.Emit(Event{Type: Submitted, JobID: "a", Position: 2})
+	s.Emit(Event{Type: PhaseChanged, JobID: "a", Phase: "downloading"})
+	s.Emit(Event{Type: Completed, JobID: "a"})
+	s.Close(5 * time.Second)
+
+	require.Len(t, rec.events, 3)
+	assert.Equal(t, Submitted, rec.events[0].Type)
+	assert.Equal(t, 2, rec.events[0].Position)
+	assert.Equal(t, "downloading", rec.events[1].Phase)
+	assert.Equal(t, Completed, rec.events[2].Type)
+	assert.False(t, rec.events[0].Time.IsZero(), "time is filled in")
+	assert.NotContains(t, rec.sigs, "invalid")
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	rec := &recorder{failures: 2}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	s := New(Config{URL: srv.URL})
+	s.retryDelay = time.Millisecond
+	s.Emit(Event{Type: Failed, JobID: "b", Error: "boom"})
+	s.Close(5 * time.Second)
+
+	assert.Equal(t, 3, rec.calls)
+	require.Len(t, rec.events, 1)
+	assert.Equal(t, "boom", rec.events[0].Error)
+}
+
+func TestDisabled(t *testing.T) {
+	s := New(Config{})
+	assert.Nil(t, s)
+	s.Emit(Event{Type: Submitted}) // no-op on nil
+	s.Close(time.Second)
+}
@@ -138,11 +138,27 @@ fi
 yt-dlp --version
 echo "yt-dlp ready"
 
+# gallery-dl downloads image posts yt-dlp has no video for
+if command -v gallery-dl &>/dev/null; then
+    echo "gallery-dl already installed, updating..."
+    sudo gallery-dl -U || true
+else
+    echo "Installing gallery-dl..."
+    sudo curl -sL https://github.com/mikf/gallery-dl/releases/latest/download/gallery-dl.bin -o /usr/local/bin/gallery-dl
+    sudo chmod a+rx /usr/local/bin/gallery-dl
+fi
+
 # Install ffmpeg if not present
 if ! command -v ffmpeg &>/dev/null; then
     echo "Installing ffmpeg..."
     sudo apt-get update -qq && sudo apt-get install -y -qq ffmpeg
 fi
+
+# aria2c downloads magnet links and .torrent files (SUSHE_TORRENTS)
+if ! command -v aria2c &>/dev/null; then
+    echo "Installing aria2..."
+    sudo apt-get update -qq && sudo apt-get install -y -qq aria2
+fi
 REMOTE
 
     success "yt-dlp setup complete"